@@ -17,17 +17,51 @@ const (
 type LivePlayer struct {
 	TouchFrames []common.TouchFrame
 	JudgeEvents []common.JudgeEvent
+	Score       common.LiveScore // 最近一次收到的实时成绩
 	mu          sync.Mutex
 }
 
+// Snapshot 获取已收到的触摸帧与判定的副本
+func (p *LivePlayer) Snapshot() ([]common.TouchFrame, []common.JudgeEvent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]common.TouchFrame(nil), p.TouchFrames...), append([]common.JudgeEvent(nil), p.JudgeEvents...)
+}
+
+// LiveScore 获取最近一次收到的实时成绩
+func (p *LivePlayer) LiveScore() common.LiveScore {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.Score
+}
+
 // Client Phira客户端
 type Client struct {
 	stream *common.ClientStream
 
 	// 状态
-	me   *common.UserInfo
-	room *common.ClientRoomState
-	mu   sync.RWMutex
+	me         *common.UserInfo
+	room       *common.ClientRoomState
+	queue      *common.QueueStatus
+	loadStatus *common.LoadStatus
+	reauthBy   time.Time        // 服务器要求重新认证的截止时间（零值表示无需重新认证）
+	avatars    map[int32]string // 玩家头像提示（来自ProfileUpdated）
+	closed     *common.RoomClosed
+	kicked     *common.Kicked
+	disconnect *common.Disconnect
+	preview    *common.ChartPreview                   // 最近一次选择谱面时的预览（服务器 V6 起下发）
+	level      *common.ChartLevel                     // 最近一次选择谱面时的难度名称与等级
+	validation *common.Result[common.ChartValidation] // 最近一次谱面预检结果
+	roomList   *common.RoomList                       // 最近一次查询的房间列表
+	history    []common.Message                       // 最近一次查询的房间消息历史
+	host       int32                                  // 当前房主（服务器 V6 起在加入房间时告知，之后随 MsgNewHost 更新；未知时为0）
+	mu         sync.RWMutex
+
+	// 房主自动关注
+	follow follower
+
+	// 事件日志
+	journal journal
 
 	// 回调
 	callbacks   map[uint16]chan interface{}
@@ -43,18 +77,44 @@ type Client struct {
 
 	// 心跳
 	pingFailCount int
+	pingMu        sync.Mutex
+	pongTime      uint64        // 最近收到的 Pong 中的服务器时间（V18起）
+	pongAt        time.Time     // 收到该 Pong 的本地时间
+	rtt           time.Duration // 平滑往返延迟，见 Latency
 	stopChan      chan struct{}
 }
 
+// Options 客户端连接选项
+type Options struct {
+	Compression bool // 请求连接压缩（服务器未允许时退回不压缩）
+	Checksum    bool // 请求帧CRC32校验（服务器未允许时退回不校验）
+
+	Journal     int    // 事件日志保留最近收到的服务器命令条数（0表示不记录），见 Client.Journal
+	JournalFile string // 事件日志同时写入的文件（为空时只保存在内存中），写满 Journal 条后轮换为 JournalFile.1
+}
+
 // NewClient 创建新客户端
 func NewClient(address string) (*Client, error) {
+	return NewClientWithOptions(address, Options{})
+}
+
+// NewClientWithOptions 按选项创建新客户端
+func NewClientWithOptions(address string, opts Options) (*Client, error) {
 	conn, err := net.Dial("tcp", address)
 	if err != nil {
 		return nil, err
 	}
 
-	// 使用版本 1
-	stream, err := common.NewClientStream(conn, 1)
+	var features common.StreamFeatures
+	if opts.Compression {
+		features |= common.FeatureDeflate
+	}
+	if opts.Checksum {
+		features |= common.FeatureChecksum
+	}
+
+	// 与服务器协商协议版本与连接特性
+	stream, err := common.NewNegotiatedClientStream(conn, common.SupportedProtocols, features)
 	if err != nil {
 		conn.Close()
 		return nil, err
@@ -65,6 +125,10 @@ func NewClient(address string) (*Client, error) {
 		callbacks: make(map[uint16]chan interface{}),
 		stopChan:  make(chan struct{}),
 	}
+	if err := client.journal.init(opts.Journal, opts.JournalFile); err != nil {
+		stream.Close()
+		return nil, err
+	}
 
 	// 启动接收循环
 	go client.recvLoop()
@@ -82,6 +146,8 @@ func (c *Client) Close() {
 
 // recvLoop 接收循环
 func (c *Client) recvLoop() {
+	defer c.stopFollow()
+	defer c.journal.close()
 	for {
 		select {
 		case <-c.stopChan:
@@ -94,7 +160,16 @@ func (c *Client) recvLoop() {
 			return
 		}
 
+		c.journal.record(cmd)
 		c.handleCommand(cmd)
+		c.ackCommand(&cmd)
+	}
+}
+
+// ackCommand 处理完带确认序号的关键命令后回复 Ack（服务器开启命令确认时，V14起）
+func (c *Client) ackCommand(cmd *common.ServerCommand) {
+	if seq, ok := cmd.Extensions.Uleb(common.ExtAckSeq); ok && c.stream.Protocol() >= common.ProtocolV14 {
+		c.stream.Send(common.ClientCommand{Type: common.ClientCmdAck, Seq: seq})
 	}
 }
 
@@ -126,7 +201,7 @@ func (c *Client) pingLoop() {
 func (c *Client) handleCommand(cmd common.ServerCommand) {
 	switch cmd.Type {
 	case common.ServerCmdPong:
-		// 心跳响应，已在Ping中处理
+		c.recordPong(cmd.Pong, time.Now())
 
 	case common.ServerCmdAuthenticate:
 		if cmd.AuthenticateResult != nil {
@@ -135,6 +210,7 @@ func (c *Client) handleCommand(cmd common.ServerCommand) {
 				c.me = &cmd.AuthenticateResult.Ok.User
 				c.room = cmd.AuthenticateResult.Ok.Room
 				c.mu.Unlock()
+				c.setHost(roomHost(&cmd))
 			}
 			c.triggerCallback(0, cmd.AuthenticateResult)
 		}
@@ -149,12 +225,23 @@ func (c *Client) handleCommand(cmd common.ServerCommand) {
 		player.mu.Lock()
 		player.TouchFrames = append(player.TouchFrames, cmd.TouchesFrames...)
 		player.mu.Unlock()
+		c.forwardFollowed(FollowUpdate{Player: cmd.TouchesPlayer, Touches: cmd.TouchesFrames})
 
 	case common.ServerCmdJudges:
 		player := c.getLivePlayer(cmd.JudgesPlayer)
 		player.mu.Lock()
 		player.JudgeEvents = append(player.JudgeEvents, cmd.JudgesEvents...)
 		player.mu.Unlock()
+		c.forwardFollowed(FollowUpdate{Player: cmd.JudgesPlayer, Judges: cmd.JudgesEvents})
+
+	case common.ServerCmdScoreUpdate:
+		if cmd.ScoreUpdate != nil {
+			player := c.getLivePlayer(cmd.ScoreUpdatePlayer)
+			player.mu.Lock()
+			player.Score = *cmd.ScoreUpdate
+			player.mu.Unlock()
+			c.forwardFollowed(FollowUpdate{Player: cmd.ScoreUpdatePlayer, Score: cmd.ScoreUpdate})
+		}
 
 	case common.ServerCmdMessage:
 		if cmd.Message != nil {
@@ -170,11 +257,47 @@ func (c *Client) handleCommand(cmd common.ServerCommand) {
 					c.room.Locked = cmd.Message.Lock
 				case common.MsgCycleRoom:
 					c.room.Cycle = cmd.Message.Cycle
+				case common.MsgLiveRoom:
+					c.room.Live = cmd.Message.Live
 				case common.MsgLeaveRoom:
 					delete(c.room.Users, cmd.Message.User)
+					delete(c.room.Teams, cmd.Message.User)
+					c.room.ReadyUsers = removeReadyUser(c.room.ReadyUsers, cmd.Message.User)
+				case common.MsgGameStart:
+					c.room.ReadyUsers = []int32{cmd.Message.User}
+				case common.MsgReady:
+					c.room.ReadyUsers = append(removeReadyUser(c.room.ReadyUsers, cmd.Message.User), cmd.Message.User)
+				case common.MsgCancelReady:
+					c.room.ReadyUsers = removeReadyUser(c.room.ReadyUsers, cmd.Message.User)
+				case common.MsgTeamChange:
+					if cmd.Message.Team == 0 {
+						delete(c.room.Teams, cmd.Message.User)
+					} else {
+						if c.room.Teams == nil {
+							c.room.Teams = make(map[int32]uint8)
+						}
+						c.room.Teams[cmd.Message.User] = cmd.Message.Team
+					}
+				}
+			}
+			if cmd.Message.Type == common.MsgSelectChart {
+				var preview common.ChartPreview
+				if ok, err := cmd.Extensions.Value(common.ExtChartPreview, &preview); ok && err == nil {
+					c.preview = &preview
+				} else {
+					c.preview = nil
+				}
+				var level common.ChartLevel
+				if ok, err := cmd.Extensions.Value(common.ExtChartLevel, &level); ok && err == nil {
+					c.level = &level
+				} else {
+					c.level = nil
 				}
 			}
 			c.mu.Unlock()
+			if cmd.Message.Type == common.MsgNewHost {
+				c.setHost(cmd.Message.User)
+			}
 		}
 
 	case common.ServerCmdChangeState:
@@ -183,10 +306,16 @@ func (c *Client) handleCommand(cmd common.ServerCommand) {
 			if c.room != nil {
 				c.room.State = *cmd.ChangeState
 				c.room.IsReady = c.room.IsHost
+				if cmd.ChangeState.Type != common.RoomStateWaitingForReady {
+					c.room.ReadyUsers = nil
+				}
 			}
 			c.mu.Unlock()
 			// 清空实时玩家数据
-			c.livePlayers = sync.Map{}
+			c.livePlayers.Range(func(key, _ interface{}) bool {
+				c.livePlayers.Delete(key)
+				return true
+			})
 		}
 
 	case common.ServerCmdChangeHost:
@@ -210,13 +339,16 @@ func (c *Client) handleCommand(cmd common.ServerCommand) {
 					users[u.ID] = u
 				}
 				c.room = &common.ClientRoomState{
-					State:   cmd.JoinRoomResult.Ok.State,
-					Live:    cmd.JoinRoomResult.Ok.Live,
-					Users:   users,
-					IsHost:  false,
-					IsReady: false,
+					State:      cmd.JoinRoomResult.Ok.State,
+					Live:       cmd.JoinRoomResult.Ok.Live,
+					Users:      users,
+					IsHost:     false,
+					IsReady:    false,
+					ReadyUsers: cmd.JoinRoomResult.Ok.ReadyUsers,
+					Teams:      cmd.JoinRoomResult.Ok.Teams,
 				}
 				c.mu.Unlock()
+				c.setHost(roomHost(&cmd))
 			}
 			c.triggerCallback(3, cmd.JoinRoomResult)
 		}
@@ -228,6 +360,10 @@ func (c *Client) handleCommand(cmd common.ServerCommand) {
 				c.room.Live = c.room.Live || cmd.OnJoinRoomUser.Monitor
 				c.room.Users[cmd.OnJoinRoomUser.ID] = *cmd.OnJoinRoomUser
 			}
+			// 身份切换时同步自身观察者状态
+			if c.me != nil && c.me.ID == cmd.OnJoinRoomUser.ID {
+				c.me.Monitor = cmd.OnJoinRoomUser.Monitor
+			}
 			c.mu.Unlock()
 		}
 
@@ -237,6 +373,7 @@ func (c *Client) handleCommand(cmd common.ServerCommand) {
 				c.mu.Lock()
 				c.room = nil
 				c.mu.Unlock()
+				c.setHost(0)
 			}
 			c.triggerCallback(4, cmd.LeaveRoomResult)
 		}
@@ -301,7 +438,227 @@ func (c *Client) handleCommand(cmd common.ServerCommand) {
 		if cmd.AbortResult != nil {
 			c.triggerCallback(12, cmd.AbortResult)
 		}
+
+	case common.ServerCmdOverflowRoom:
+		if cmd.OverflowRoomResult != nil {
+			c.triggerCallback(14, cmd.OverflowRoomResult)
+		}
+
+	case common.ServerCmdQueueJoin:
+		if cmd.QueueJoinResult != nil {
+			c.triggerCallback(13, cmd.QueueJoinResult)
+		}
+
+	case common.ServerCmdSwitchRole:
+		if cmd.SwitchRoleResult != nil {
+			c.triggerCallback(15, cmd.SwitchRoleResult)
+		}
+
+	case common.ServerCmdGlobalChat:
+		if cmd.GlobalChatResult != nil {
+			c.triggerCallback(16, cmd.GlobalChatResult)
+		}
+
+	case common.ServerCmdGlobalSubscribe:
+		if cmd.GlobalSubscribeResult != nil {
+			c.triggerCallback(17, cmd.GlobalSubscribeResult)
+		}
+
+	case common.ServerCmdSetMaxMonitors:
+		if cmd.SetMaxMonitorsResult != nil {
+			c.triggerCallback(18, cmd.SetMaxMonitorsResult)
+		}
+
+	case common.ServerCmdSubmitResult:
+		if cmd.SubmitResultResult != nil {
+			c.triggerCallback(19, cmd.SubmitResultResult)
+		}
+
+	case common.ServerCmdReauthRequired:
+		c.mu.Lock()
+		c.reauthBy = time.Now().Add(time.Duration(cmd.ReauthGrace) * time.Second)
+		c.mu.Unlock()
+
+	case common.ServerCmdReauthenticate:
+		if cmd.ReauthenticateResult != nil {
+			if cmd.ReauthenticateResult.Ok != nil {
+				c.mu.Lock()
+				c.reauthBy = time.Time{}
+				c.mu.Unlock()
+			}
+			c.triggerCallback(20, cmd.ReauthenticateResult)
+		}
+
+	case common.ServerCmdUpdateProfile:
+		if cmd.UpdateProfileResult != nil {
+			c.triggerCallback(21, cmd.UpdateProfileResult)
+		}
+
+	case common.ServerCmdMonitorChat:
+		if cmd.MonitorChatResult != nil {
+			c.triggerCallback(22, cmd.MonitorChatResult)
+		}
+
+	case common.ServerCmdProfileUpdated:
+		if p := cmd.ProfileUpdated; p != nil {
+			c.mu.Lock()
+			if c.me != nil && c.me.ID == p.ID {
+				c.me.Name = p.Name
+			}
+			if c.room != nil {
+				if u, ok := c.room.Users[p.ID]; ok {
+					u.Name = p.Name
+					c.room.Users[p.ID] = u
+				}
+			}
+			if c.avatars == nil {
+				c.avatars = make(map[int32]string)
+			}
+			c.avatars[p.ID] = p.Avatar
+			c.mu.Unlock()
+		}
+
+	case common.ServerCmdLoadProgress:
+		if cmd.LoadProgress != nil {
+			c.mu.Lock()
+			c.loadStatus = cmd.LoadProgress
+			c.mu.Unlock()
+		}
+
+	case common.ServerCmdQueueUpdate:
+		if cmd.QueueUpdate != nil {
+			c.mu.Lock()
+			c.queue = cmd.QueueUpdate
+			c.mu.Unlock()
+		}
+
+	case common.ServerCmdRoomClosed:
+		if cmd.RoomClosed != nil {
+			c.mu.Lock()
+			c.room = nil
+			c.loadStatus = nil
+			c.closed = cmd.RoomClosed
+			c.mu.Unlock()
+			c.setHost(0)
+		}
+
+	case common.ServerCmdValidateChart:
+		if cmd.ValidateChartResult != nil {
+			c.mu.Lock()
+			c.validation = cmd.ValidateChartResult
+			c.mu.Unlock()
+			c.triggerCallback(23, cmd.ValidateChartResult)
+		}
+
+	case common.ServerCmdSetRanking:
+		if cmd.SetRankingResult != nil {
+			c.triggerCallback(24, cmd.SetRankingResult)
+		}
+
+	case common.ServerCmdEmote:
+		if cmd.EmoteResult != nil {
+			c.triggerCallback(25, cmd.EmoteResult)
+		}
+
+	case common.ServerCmdSetSchedule:
+		if cmd.SetScheduleResult != nil {
+			c.triggerCallback(26, cmd.SetScheduleResult)
+		}
+
+	case common.ServerCmdListRooms:
+		if cmd.ListRoomsResult != nil {
+			if cmd.ListRoomsResult.Ok != nil {
+				c.mu.Lock()
+				c.roomList = cmd.ListRoomsResult.Ok
+				c.mu.Unlock()
+			}
+			c.triggerCallback(27, cmd.ListRoomsResult)
+		}
+
+	case common.ServerCmdShareStats:
+		if cmd.ShareStatsResult != nil {
+			c.triggerCallback(28, cmd.ShareStatsResult)
+		}
+
+	case common.ServerCmdTransferHost:
+		if cmd.TransferHostResult != nil {
+			c.triggerCallback(29, cmd.TransferHostResult)
+		}
+
+	case common.ServerCmdChatHistory:
+		if cmd.ChatHistoryResult != nil {
+			if cmd.ChatHistoryResult.Ok != nil {
+				c.mu.Lock()
+				c.history = cmd.ChatHistoryResult.Ok.Messages
+				c.mu.Unlock()
+			}
+			c.triggerCallback(30, cmd.ChatHistoryResult)
+		}
+
+	case common.ServerCmdKick:
+		if cmd.KickResult != nil {
+			c.triggerCallback(31, cmd.KickResult)
+		}
+
+	case common.ServerCmdSetPassword:
+		if cmd.SetPasswordResult != nil {
+			c.triggerCallback(32, cmd.SetPasswordResult)
+		}
+
+	case common.ServerCmdSetTeam:
+		if cmd.SetTeamResult != nil {
+			c.triggerCallback(33, cmd.SetTeamResult)
+		}
+
+	case common.ServerCmdKicked:
+		if cmd.Kicked != nil {
+			c.mu.Lock()
+			c.room = nil
+			c.loadStatus = nil
+			c.kicked = cmd.Kicked
+			// 房间解散的 Kicked 取代 RoomClosed，一并记录为房间关闭通知
+			if cmd.Kicked.Reason == common.KickReasonDisbanded && cmd.Kicked.RoomId != nil {
+				c.closed = &common.RoomClosed{RoomId: *cmd.Kicked.RoomId, Reason: cmd.Kicked.Message}
+			}
+			c.mu.Unlock()
+			c.setHost(0)
+		}
+
+	case common.ServerCmdDisconnect:
+		if cmd.Disconnect != nil {
+			c.mu.Lock()
+			c.disconnect = cmd.Disconnect
+			c.mu.Unlock()
+		}
+	}
+}
+
+// roomHost 读取加入房间或重连回复中的房主（扩展字段 ExtRoomHost），服务器未提供时返回0
+func roomHost(cmd *common.ServerCommand) int32 {
+	var host common.UserInfo
+	if ok, err := cmd.Extensions.Value(common.ExtRoomHost, &host); ok && err == nil {
+		return host.ID
+	}
+	return 0
+}
+
+// setHost 更新当前房主，开启自动关注时切换关注的玩家
+func (c *Client) setHost(host int32) {
+	c.mu.Lock()
+	c.host = host
+	c.mu.Unlock()
+	c.followHost(host)
+}
+
+// removeReadyUser 从已准备列表中移除玩家
+func removeReadyUser(users []int32, id int32) []int32 {
+	result := make([]int32, 0, len(users))
+	for _, u := range users {
+		if u != id {
+			result = append(result, u)
+		}
 	}
+	return result
 }
 
 // getLivePlayer 获取实时玩家
@@ -376,6 +733,126 @@ func (c *Client) RoomState() *common.ClientRoomState {
 	return &state
 }
 
+// Avatar 获取玩家最近一次更新的头像提示
+func (c *Client) Avatar(userID int32) string {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.avatars[userID]
+}
+
+// ChartPreview 获取最近一次选择谱面时服务器下发的谱面预览（服务器未下发时为nil）
+func (c *Client) ChartPreview() *common.ChartPreview {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.preview == nil {
+		return nil
+	}
+	preview := *c.preview
+	return &preview
+}
+
+// ChartLevel 获取最近一次选择谱面时服务器下发的难度名称与等级（服务器未下发时为nil）
+func (c *Client) ChartLevel() *common.ChartLevel {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.level == nil {
+		return nil
+	}
+	level := *c.level
+	return &level
+}
+
+// ChartValidation 获取最近一次谱面预检结果（尚未收到时为nil）
+func (c *Client) ChartValidation() *common.Result[common.ChartValidation] {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.validation == nil {
+		return nil
+	}
+	validation := *c.validation
+	return &validation
+}
+
+// RoomList 获取最近一次查询的房间列表（尚未收到时为nil）
+func (c *Client) RoomList() *common.RoomList {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.roomList == nil {
+		return nil
+	}
+	list := *c.roomList
+	return &list
+}
+
+// ChatHistory 获取最近一次查询的房间消息历史，按发送时间从早到晚排列（尚未收到时为nil）
+func (c *Client) ChatHistory() []common.Message {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return append([]common.Message(nil), c.history...)
+}
+
+// RoomClosed 获取最近一次收到的房间关闭通知
+func (c *Client) RoomClosed() *common.RoomClosed {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.closed == nil {
+		return nil
+	}
+	closed := *c.closed
+	return &closed
+}
+
+// Kicked 获取最近一次收到的移出通知（服务器 V23 起），房间被解散时也会同时更新 RoomClosed
+func (c *Client) Kicked() *common.Kicked {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.kicked == nil {
+		return nil
+	}
+	kicked := *c.kicked
+	return &kicked
+}
+
+// Disconnected 获取服务器断开连接前发送的通知（服务器 V27 起，尽力发送，未收到时为nil）
+func (c *Client) Disconnected() *common.Disconnect {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.disconnect == nil {
+		return nil
+	}
+	disconnect := *c.disconnect
+	return &disconnect
+}
+
+// QueueStatus 获取最近一次收到的排队状态
+func (c *Client) QueueStatus() *common.QueueStatus {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.queue == nil {
+		return nil
+	}
+	status := *c.queue
+	return &status
+}
+
+// LoadStatus 获取最近一次收到的谱面加载进度
+func (c *Client) LoadStatus() *common.LoadStatus {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.loadStatus == nil {
+		return nil
+	}
+	status := *c.loadStatus
+	return &status
+}
+
+// Host 当前房主的用户ID（不在房间中或服务器未告知时为0）
+func (c *Client) Host() int32 {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.host
+}
+
 // IsHost 是否是房主
 func (c *Client) IsHost() bool {
 	c.mu.RLock()
@@ -410,9 +887,9 @@ func (c *Client) LivePlayer(playerID int32) *LivePlayer {
 	return c.getLivePlayer(playerID)
 }
 
-// Ping 发送心跳
+// Ping 发送心跳（服务器 V18 起附带时间戳，双方据此测量往返延迟）
 func (c *Client) Ping() error {
-	return c.stream.Send(common.ClientCommand{Type: common.ClientCmdPing})
+	return c.stream.Send(common.ClientCommand{Type: common.ClientCmdPing, Ping: c.pingTimes(time.Now())})
 }
 
 // Authenticate 认证
@@ -430,6 +907,29 @@ func (c *Client) Chat(message string) error {
 	return c.stream.Send(common.ClientCommand{Type: common.ClientCmdChat, Message: message})
 }
 
+// GlobalChat 在全服频道发言（需要解说或观察者权限）
+func (c *Client) GlobalChat(message string) error {
+	return c.stream.Send(common.ClientCommand{Type: common.ClientCmdGlobalChat, Message: message})
+}
+
+// MonitorChat 观察者聊天（只有同一房间的观察者会收到）
+func (c *Client) MonitorChat(message string) error {
+	return c.stream.Send(common.ClientCommand{Type: common.ClientCmdMonitorChat, Message: message})
+}
+
+// Emote 在房间内发送表情（表情ID需在服务器允许的列表中），需要服务器 V15 起支持
+func (c *Client) Emote(emote string) error {
+	if c.stream.Protocol() < common.ProtocolV15 {
+		return fmt.Errorf("server does not support Emote")
+	}
+	return c.stream.Send(common.ClientCommand{Type: common.ClientCmdEmote, Name: emote})
+}
+
+// GlobalSubscribe 订阅或取消订阅全服频道
+func (c *Client) GlobalSubscribe(subscribe bool) error {
+	return c.stream.Send(common.ClientCommand{Type: common.ClientCmdGlobalSubscribe, Subscribe: subscribe})
+}
+
 // CreateRoom 创建房间
 func (c *Client) CreateRoom(roomID common.RoomId) error {
 	return c.stream.Send(common.ClientCommand{Type: common.ClientCmdCreateRoom, RoomId: roomID})
@@ -440,6 +940,27 @@ func (c *Client) JoinRoom(roomID common.RoomId, monitor bool) error {
 	return c.stream.Send(common.ClientCommand{Type: common.ClientCmdJoinRoom, RoomId: roomID, Monitor: monitor})
 }
 
+// CreateRoomWithPassword 创建有密码的房间，其他玩家须提供密码才能加入，需要服务器 V21 起支持
+func (c *Client) CreateRoomWithPassword(roomID common.RoomId, password string) error {
+	if c.stream.Protocol() < common.ProtocolV21 {
+		return fmt.Errorf("server does not support room passwords")
+	}
+	return c.stream.Send(common.ClientCommand{Type: common.ClientCmdCreateRoom, RoomId: roomID, Password: password})
+}
+
+// JoinRoomWithPassword 使用密码加入房间，需要服务器 V21 起支持
+func (c *Client) JoinRoomWithPassword(roomID common.RoomId, monitor bool, password string) error {
+	if c.stream.Protocol() < common.ProtocolV21 {
+		return fmt.Errorf("server does not support room passwords")
+	}
+	return c.stream.Send(common.ClientCommand{Type: common.ClientCmdJoinRoom, RoomId: roomID, Monitor: monitor, Password: password})
+}
+
+// QueueJoin 排队加入房间
+func (c *Client) QueueJoin(roomID common.RoomId) error {
+	return c.stream.Send(common.ClientCommand{Type: common.ClientCmdQueueJoin, RoomId: roomID})
+}
+
 // LeaveRoom 离开房间
 func (c *Client) LeaveRoom() error {
 	return c.stream.Send(common.ClientCommand{Type: common.ClientCmdLeaveRoom})
@@ -455,11 +976,135 @@ func (c *Client) CycleRoom(cycle bool) error {
 	return c.stream.Send(common.ClientCommand{Type: common.ClientCmdCycleRoom, Cycle: cycle})
 }
 
+// OverflowRoom 设置满员转观察者
+func (c *Client) OverflowRoom(overflow bool) error {
+	return c.stream.Send(common.ClientCommand{Type: common.ClientCmdOverflowRoom, Overflow: overflow})
+}
+
+// SwitchRole 在玩家与观察者身份之间切换
+func (c *Client) SwitchRole(monitor bool) error {
+	return c.stream.Send(common.ClientCommand{Type: common.ClientCmdSwitchRole, Monitor: monitor})
+}
+
+// SetMaxMonitors 设置房间观察者上限（仅房主，0表示恢复服务器默认值）
+func (c *Client) SetMaxMonitors(maxMonitors uint16) error {
+	return c.stream.Send(common.ClientCommand{Type: common.ClientCmdSetMaxMonitors, MaxMonitors: maxMonitors})
+}
+
+// SubmitResult 通过服务器代提交成绩（服务器转发至成绩服务后自动完成 Played）
+func (c *Client) SubmitResult(payload string) error {
+	return c.stream.Send(common.ClientCommand{Type: common.ClientCmdSubmitResult, Payload: payload})
+}
+
+// Reauthenticate 使用新token重新认证（不断开连接，用户必须与当前用户一致）
+func (c *Client) Reauthenticate(token string) error {
+	return c.stream.Send(common.ClientCommand{Type: common.ClientCmdReauthenticate, Token: token})
+}
+
+// UpdateProfile 修改显示名称与头像提示（名称为空表示恢复账号名称）
+func (c *Client) UpdateProfile(name, avatar string) error {
+	return c.stream.Send(common.ClientCommand{Type: common.ClientCmdUpdateProfile, Name: name, Avatar: avatar})
+}
+
+// ReauthDeadline 服务器要求重新认证的截止时间（零值表示无需重新认证）
+func (c *Client) ReauthDeadline() time.Time {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.reauthBy
+}
+
 // SelectChart 选择谱面
-func (c *Client) SelectChart(chartID int32) error {
+func (c *Client) SelectChart(chartID int64) error {
 	return c.stream.Send(common.ClientCommand{Type: common.ClientCmdSelectChart, ChartID: chartID})
 }
 
+// ValidateChart 预检谱面能否被选择（不实际选择），结果通过 ChartValidation 获取，需要服务器 V8 起支持
+func (c *Client) ValidateChart(chartID int64) error {
+	if c.stream.Protocol() < common.ProtocolV8 {
+		return fmt.Errorf("server does not support ValidateChart")
+	}
+	return c.stream.Send(common.ClientCommand{Type: common.ClientCmdValidateChart, ChartID: chartID})
+}
+
+// ListRooms 查询可加入的房间列表的第 page 页（从0开始，每页 common.RoomListPageSize 个），需要服务器 V17 起支持
+// 结果通过 RoomList 获取
+func (c *Client) ListRooms(page uint32) error {
+	if c.stream.Protocol() < common.ProtocolV17 {
+		return fmt.Errorf("server does not support ListRooms")
+	}
+	return c.stream.Send(common.ClientCommand{Type: common.ClientCmdListRooms, Page: page})
+}
+
+// SetRanking 设置房间的排名策略（房主），需要服务器 V9 起支持
+func (c *Client) SetRanking(name string) error {
+	if c.stream.Protocol() < common.ProtocolV9 {
+		return fmt.Errorf("server does not support SetRanking")
+	}
+	return c.stream.Send(common.ClientCommand{Type: common.ClientCmdSetRanking, Name: name})
+}
+
+// SetSchedule 设置房间的开放与关闭时间（房主，Unix毫秒，0表示不限制），需要服务器 V16 起支持
+func (c *Client) SetSchedule(schedule common.RoomSchedule) error {
+	if c.stream.Protocol() < common.ProtocolV16 {
+		return fmt.Errorf("server does not support SetSchedule")
+	}
+	return c.stream.Send(common.ClientCommand{Type: common.ClientCmdSetSchedule, Schedule: schedule})
+}
+
+// ShareStats 开启或关闭向房间成员展示资料卡（rks与游玩次数），从下次加入房间起生效，需要服务器 V19 起支持
+// 其他玩家的资料卡见 RoomState 中各用户的 Card
+func (c *Client) ShareStats(share bool) error {
+	if c.stream.Protocol() < common.ProtocolV19 {
+		return fmt.Errorf("server does not support ShareStats")
+	}
+	return c.stream.Send(common.ClientCommand{Type: common.ClientCmdShareStats, Share: share})
+}
+
+// TransferHost 将房主移交给房间内的其他玩家（房主，选择谱面时），需要服务器 V20 起支持
+// 移交成功后通过 ChangeHost 与 MsgNewHost 得知房主变更
+func (c *Client) TransferHost(userID int32) error {
+	if c.stream.Protocol() < common.ProtocolV20 {
+		return fmt.Errorf("server does not support TransferHost")
+	}
+	return c.stream.Send(common.ClientCommand{Type: common.ClientCmdTransferHost, UserID: userID})
+}
+
+// RequestChatHistory 查询所在房间最近的 count 条房间消息（0表示服务器保存的全部），需要服务器 V22 起支持
+// 重连或以观察者身份加入后调用以补全聊天记录，结果通过 ChatHistory 获取
+func (c *Client) RequestChatHistory(count uint16) error {
+	if c.stream.Protocol() < common.ProtocolV22 {
+		return fmt.Errorf("server does not support ChatHistory")
+	}
+	return c.stream.Send(common.ClientCommand{Type: common.ClientCmdChatHistory, Count: count})
+}
+
+// Kick 将玩家或观察者移出房间（房主，选择谱面时），需要服务器 V23 起支持
+// 被移出的一方收到 Kicked（KickReasonHost）
+func (c *Client) Kick(userID int32) error {
+	if c.stream.Protocol() < common.ProtocolV23 {
+		return fmt.Errorf("server does not support Kick")
+	}
+	return c.stream.Send(common.ClientCommand{Type: common.ClientCmdKick, UserID: userID})
+}
+
+// SetPassword 修改房间密码（空字符串表示取消）与是否为私密房间（房主），需要服务器 V25 起支持
+// 私密房间不出现在房间列表中，只能通过房间ID加入
+func (c *Client) SetPassword(password string, private bool) error {
+	if c.stream.Protocol() < common.ProtocolV25 {
+		return fmt.Errorf("server does not support SetPassword")
+	}
+	return c.stream.Send(common.ClientCommand{Type: common.ClientCmdSetPassword, Password: password, Private: private})
+}
+
+// SetTeam 为房间内的玩家分配队伍（房主，游戏中不能调整），team 为 1～common.RoomMaxTeams，0表示不分队
+// 需要服务器 V26 起支持，房间成员收到 MsgTeamChange，RoomState().Teams 随之更新
+func (c *Client) SetTeam(userID int32, team uint8) error {
+	if c.stream.Protocol() < common.ProtocolV26 {
+		return fmt.Errorf("server does not support SetTeam")
+	}
+	return c.stream.Send(common.ClientCommand{Type: common.ClientCmdSetTeam, UserID: userID, Team: team})
+}
+
 // RequestStart 请求开始游戏
 func (c *Client) RequestStart() error {
 	return c.stream.Send(common.ClientCommand{Type: common.ClientCmdRequestStart})
@@ -476,7 +1121,7 @@ func (c *Client) CancelReady() error {
 }
 
 // Played 上传成绩
-func (c *Client) Played(recordID int32) error {
+func (c *Client) Played(recordID int64) error {
 	return c.stream.Send(common.ClientCommand{Type: common.ClientCmdPlayed, RecordID: recordID})
 }
 
@@ -485,6 +1130,11 @@ func (c *Client) Abort() error {
 	return c.stream.Send(common.ClientCommand{Type: common.ClientCmdAbort})
 }
 
+// ReportLoadProgress 上报谱面加载进度（0-100）
+func (c *Client) ReportLoadProgress(progress uint8) error {
+	return c.stream.Send(common.ClientCommand{Type: common.ClientCmdLoadProgress, Progress: progress})
+}
+
 // SendTouches 发送触摸数据
 func (c *Client) SendTouches(frames []common.TouchFrame) error {
 	return c.stream.Send(common.ClientCommand{Type: common.ClientCmdTouches, Frames: frames})
@@ -494,3 +1144,30 @@ func (c *Client) SendTouches(frames []common.TouchFrame) error {
 func (c *Client) SendJudges(judges []common.JudgeEvent) error {
 	return c.stream.Send(common.ClientCommand{Type: common.ClientCmdJudges, Judges: judges})
 }
+
+// SendScoreUpdate 发送对局中的实时成绩（accuracy 为0-1），服务器转发给观察者用于直播展示
+func (c *Client) SendScoreUpdate(score, combo int32, accuracy float32) error {
+	if c.stream.Protocol() < common.ProtocolV11 {
+		return fmt.Errorf("server does not support ScoreUpdate")
+	}
+	return c.stream.Send(common.ClientCommand{
+		Type:      common.ClientCmdScoreUpdate,
+		LiveScore: common.LiveScore{Score: score, Combo: combo, Accuracy: accuracy},
+	})
+}
+
+// SendFrameBatch 将同一时段的触摸帧与判定合并为一条命令发送，服务器不支持（V7以下）时分别发送
+func (c *Client) SendFrameBatch(frames []common.TouchFrame, judges []common.JudgeEvent) error {
+	if c.stream.Protocol() >= common.ProtocolV7 {
+		return c.stream.Send(common.ClientCommand{Type: common.ClientCmdFrameBatch, Frames: frames, Judges: judges})
+	}
+	if len(frames) > 0 {
+		if err := c.SendTouches(frames); err != nil {
+			return err
+		}
+	}
+	if len(judges) > 0 {
+		return c.SendJudges(judges)
+	}
+	return nil
+}
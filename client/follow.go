@@ -0,0 +1,113 @@
+package client
+
+import (
+	"sync"
+
+	"phira-mp/common"
+)
+
+// followBuffer 关注通道的缓冲数量，消费不及时时丢弃新的数据
+const followBuffer = 256
+
+// FollowUpdate 关注玩家的实时数据
+// 关注的玩家变化（房主变更、加入房间）时先发送一条 Switched 为 true 的更新，附带已收到的该玩家本局数据
+type FollowUpdate struct {
+	Player   int32
+	Switched bool
+	Touches  []common.TouchFrame
+	Judges   []common.JudgeEvent
+	Score    *common.LiveScore
+}
+
+// follower 房主自动关注状态
+type follower struct {
+	mu     sync.Mutex
+	ch     chan FollowUpdate // 未开启时为nil
+	player int32
+}
+
+// FollowHost 开启或关闭房主自动关注，供以观察者身份加入的直播工具使用：
+// 自动跟踪当前房主（加入房间时由服务器告知，之后随 MsgNewHost 切换），只把房主的触摸帧、判定与实时成绩发送到返回的通道
+// 关闭时返回nil，之前返回的通道被关闭；连接断开时通道也会被关闭
+func (c *Client) FollowHost(enable bool) <-chan FollowUpdate {
+	c.follow.mu.Lock()
+	defer c.follow.mu.Unlock()
+	if c.follow.ch != nil {
+		close(c.follow.ch)
+		c.follow.ch = nil
+	}
+	c.follow.player = 0
+	if !enable {
+		return nil
+	}
+	c.follow.ch = make(chan FollowUpdate, followBuffer)
+	if host := c.Host(); host != 0 {
+		c.switchFollowLocked(host)
+	}
+	return c.follow.ch
+}
+
+// Followed 当前关注的玩家ID（未开启自动关注或房主未知时为0）
+func (c *Client) Followed() int32 {
+	c.follow.mu.Lock()
+	defer c.follow.mu.Unlock()
+	return c.follow.player
+}
+
+// FollowedPlayer 当前关注玩家的实时数据（未开启自动关注或房主未知时为nil）
+func (c *Client) FollowedPlayer() *LivePlayer {
+	if player := c.Followed(); player != 0 {
+		return c.getLivePlayer(player)
+	}
+	return nil
+}
+
+// followHost 房主变化时切换关注的玩家
+func (c *Client) followHost(host int32) {
+	c.follow.mu.Lock()
+	defer c.follow.mu.Unlock()
+	if c.follow.ch != nil && c.follow.player != host {
+		c.switchFollowLocked(host)
+	}
+}
+
+// switchFollowLocked 切换关注的玩家并发送切换通知（调用方需持有 follow.mu）
+func (c *Client) switchFollowLocked(player int32) {
+	c.follow.player = player
+	update := FollowUpdate{Player: player, Switched: true}
+	if player != 0 {
+		p := c.getLivePlayer(player)
+		update.Touches, update.Judges = p.Snapshot()
+		if score := p.LiveScore(); score != (common.LiveScore{}) {
+			update.Score = &score
+		}
+	}
+	c.pushFollowLocked(update)
+}
+
+// forwardFollowed 将关注玩家的实时数据发送到关注通道
+func (c *Client) forwardFollowed(update FollowUpdate) {
+	c.follow.mu.Lock()
+	defer c.follow.mu.Unlock()
+	if c.follow.ch != nil && update.Player != 0 && update.Player == c.follow.player {
+		c.pushFollowLocked(update)
+	}
+}
+
+func (c *Client) pushFollowLocked(update FollowUpdate) {
+	select {
+	case c.follow.ch <- update:
+	default:
+	}
+}
+
+// stopFollow 连接断开时关闭关注通道
+func (c *Client) stopFollow() {
+	c.follow.mu.Lock()
+	defer c.follow.mu.Unlock()
+	if c.follow.ch != nil {
+		close(c.follow.ch)
+		c.follow.ch = nil
+	}
+	c.follow.player = 0
+}
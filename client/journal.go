@@ -0,0 +1,144 @@
+package client
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"phira-mp/common"
+)
+
+// JournalEntry 事件日志中的一条记录：收到的服务器命令及接收时间
+type JournalEntry struct {
+	Time    time.Time            `json:"time"`
+	Command common.ServerCommand `json:"cmd"`
+}
+
+// journal 客户端事件日志，按环形缓冲保留最近收到的服务器命令，用于排查房间状态异常
+// 指定文件时同时按JSON Lines写入文件，文件写满 size 条后轮换为 path.1，磁盘上最多保留 2*size 条
+type journal struct {
+	mu      sync.Mutex
+	entries []JournalEntry // 环形缓冲，未开启时为nil
+	next    int            // 下一条写入的位置
+	full    bool
+
+	path  string
+	file  *os.File
+	lines int
+}
+
+func (j *journal) init(size int, path string) error {
+	if size <= 0 {
+		return nil
+	}
+	j.entries = make([]JournalEntry, size)
+	if path == "" {
+		return nil
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	j.path, j.file = path, f
+	return nil
+}
+
+// record 记录一条收到的命令
+func (j *journal) record(cmd common.ServerCommand) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.entries == nil {
+		return
+	}
+	entry := JournalEntry{Time: time.Now(), Command: cmd}
+	j.entries[j.next] = entry
+	j.next++
+	if j.next == len(j.entries) {
+		j.next, j.full = 0, true
+	}
+	if j.file != nil {
+		j.writeFileLocked(entry)
+	}
+}
+
+// writeFileLocked 追加到日志文件，写入失败时停止写文件（内存中的记录不受影响）
+func (j *journal) writeFileLocked(entry JournalEntry) {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	if _, err := j.file.Write(append(line, '\n')); err != nil {
+		j.closeFileLocked()
+		return
+	}
+	j.lines++
+	if j.lines < len(j.entries) {
+		return
+	}
+	j.file.Close()
+	j.file = nil
+	if err := os.Rename(j.path, j.path+".1"); err != nil {
+		return
+	}
+	if f, err := os.Create(j.path); err == nil {
+		j.file, j.lines = f, 0
+	}
+}
+
+func (j *journal) closeFileLocked() {
+	if j.file != nil {
+		j.file.Close()
+		j.file = nil
+	}
+}
+
+// close 连接断开时关闭日志文件
+func (j *journal) close() {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	j.closeFileLocked()
+}
+
+// snapshot 按接收顺序返回缓冲中的记录
+func (j *journal) snapshot() []JournalEntry {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if !j.full {
+		return append([]JournalEntry(nil), j.entries[:j.next]...)
+	}
+	return append(append([]JournalEntry(nil), j.entries[j.next:]...), j.entries[:j.next]...)
+}
+
+// Journal 事件日志中最近收到的服务器命令（按接收顺序），未开启事件日志时为nil
+func (c *Client) Journal() []JournalEntry {
+	if c.journal.entries == nil {
+		return nil
+	}
+	return c.journal.snapshot()
+}
+
+// ExportJournal 将事件日志按JSON Lines（每行一条 JournalEntry）写出，供用户反馈问题时附带
+func (c *Client) ExportJournal(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	for _, entry := range c.Journal() {
+		if err := enc.Encode(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExportJournalFile 将事件日志导出到文件
+func (c *Client) ExportJournalFile(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := c.ExportJournal(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,47 @@
+package client
+
+import (
+	"time"
+
+	"phira-mp/common"
+)
+
+// pingTimes 本次 Ping 携带的时间戳：发送时间，以及最近一次 Pong 的服务器时间与其后经过的毫秒数（供服务器测量延迟）
+func (c *Client) pingTimes(now time.Time) common.PingTimes {
+	c.pingMu.Lock()
+	defer c.pingMu.Unlock()
+	times := common.PingTimes{Time: uint64(now.UnixMilli())}
+	if c.pongTime != 0 {
+		times.Echo = c.pongTime
+		times.Hold = uint64(max(now.Sub(c.pongAt).Milliseconds(), 0))
+	}
+	return times
+}
+
+// recordPong 根据 Pong 回显的发送时间计算往返延迟
+func (c *Client) recordPong(pong common.PingTimes, now time.Time) {
+	if pong.Time == 0 {
+		return
+	}
+	c.pingMu.Lock()
+	defer c.pingMu.Unlock()
+	c.pongTime, c.pongAt = pong.Time, now
+	if pong.Echo == 0 || pong.Echo > uint64(now.UnixMilli()) {
+		return
+	}
+	// 时间戳精度为毫秒，本机连接等不足1毫秒的延迟记为1毫秒
+	rtt := max(now.Sub(time.UnixMilli(int64(pong.Echo))), time.Millisecond)
+	if c.rtt == 0 {
+		c.rtt = rtt
+	} else {
+		// 平滑往返延迟（同TCP的SRTT，权重1/8）
+		c.rtt += (rtt - c.rtt) / 8
+	}
+}
+
+// Latency 平滑后的往返延迟（服务器低于 V18 或尚未测得时为0）
+func (c *Client) Latency() time.Duration {
+	c.pingMu.Lock()
+	defer c.pingMu.Unlock()
+	return c.rtt
+}
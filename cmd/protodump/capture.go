@@ -0,0 +1,124 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"phira-mp/common"
+)
+
+// stream 连接一个方向的原始字节，marks 记录各段数据开始的字节位置与抓包时间
+type stream struct {
+	data  []byte
+	marks []mark
+}
+
+type mark struct {
+	offset int
+	at     time.Time
+}
+
+// append 追加一段抓到的数据
+func (s *stream) append(data []byte, at time.Time) {
+	if len(data) == 0 {
+		return
+	}
+	s.marks = append(s.marks, mark{offset: len(s.data), at: at})
+	s.data = append(s.data, data...)
+}
+
+// timeAt 字节位置 offset 所在数据段的抓包时间（没有时间信息时为零值）
+func (s *stream) timeAt(offset int) time.Time {
+	i := sort.Search(len(s.marks), func(i int) bool { return s.marks[i].offset > offset })
+	if i == 0 {
+		return time.Time{}
+	}
+	return s.marks[i-1].at
+}
+
+// connection 一条连接两个方向的数据
+type connection struct {
+	name   string
+	client stream // 客户端发往服务器
+	server stream // 服务器发往客户端
+}
+
+// byteReader 按字节读取并记录读到的位置，供压缩数据解压时定位每帧在原始字节流中的结束位置
+type byteReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *byteReader) Read(p []byte) (int, error) {
+	if r.pos >= len(r.data) {
+		return 0, io.EOF
+	}
+	n := copy(p, r.data[r.pos:])
+	r.pos += n
+	return n, nil
+}
+
+func (r *byteReader) ReadByte() (byte, error) {
+	if r.pos >= len(r.data) {
+		return 0, io.EOF
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b, nil
+}
+
+// decode 解析连接的握手并按编码方式切分两个方向的帧，按时间排序输出
+func (p *printer) decode(c *connection) {
+	format, clientLen, serverLen, err := common.ParseHandshake(c.client.data, c.server.data)
+	if err != nil {
+		p.note(c.name, "无法解析握手（抓包须从连接建立时开始）: %v", err)
+		return
+	}
+	p.handshake(c.name, format)
+
+	var frames []frame
+	for _, dir := range []direction{toServer, toClient} {
+		s, start := &c.client, clientLen
+		if dir == toClient {
+			s, start = &c.server, serverLen
+		}
+		r := &byteReader{data: s.data, pos: start}
+		fr := format.NewFrameReader(r)
+		for {
+			data, err := fr.Next()
+			if err != nil {
+				if !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+					p.note(c.name, "%s 在第 %d 字节处无法继续读取: %v", dir, r.pos, err)
+				} else if r.pos < len(s.data) {
+					p.note(c.name, "%s 末尾 %d 字节不足一帧", dir, len(s.data)-r.pos)
+				}
+				break
+			}
+			frames = append(frames, frame{at: s.timeAt(r.pos - 1), offset: int64(r.pos), dir: dir, conn: c.name, data: data})
+		}
+	}
+
+	// 原始字节流没有时间，保持先客户端后服务器的顺序
+	sort.SliceStable(frames, func(i, j int) bool { return frames[i].at.Before(frames[j].at) })
+	for _, fr := range frames {
+		p.frame(format, fr)
+	}
+}
+
+// runRaw 读取连接两个方向的原始字节流文件并输出
+func runRaw(p *printer, clientFile, serverFile string) error {
+	client, err := os.ReadFile(clientFile)
+	if err != nil {
+		return fmt.Errorf("读取客户端方向数据失败: %w", err)
+	}
+	server, err := os.ReadFile(serverFile)
+	if err != nil {
+		return fmt.Errorf("读取服务器方向数据失败: %w", err)
+	}
+	p.decode(&connection{client: stream{data: client}, server: stream{data: server}})
+	return nil
+}
@@ -0,0 +1,77 @@
+// protodump 协议分析工具：以观察者身份连接服务器，或读取抓包文件/原始字节流，
+// 逐帧输出解码后的客户端与服务器命令（时间、方向、帧长度与JSON），用于排查与其他客户端实现的兼容问题
+//
+// 用法：
+//
+//	protodump -addr 127.0.0.1:12346 -token <token> [-room <房间ID>]   以观察者身份实时查看
+//	protodump -pcap capture.pcap [-port 12346]                         读取 tcpdump 抓包文件
+//	protodump -client c2s.bin -server s2c.bin                          读取两个方向的原始字节流（如 tcpflow 输出）
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"phira-mp/common"
+)
+
+func main() {
+	addr := flag.String("addr", "", "服务器地址，以观察者身份连接并实时输出收发的命令")
+	token := flag.String("token", "", "连接服务器时认证使用的令牌")
+	room := flag.String("room", "", "连接后以观察者身份加入的房间ID（留空则只停留在大厅）")
+	compress := flag.Bool("compress", false, "连接服务器时请求连接压缩")
+	pcapFile := flag.String("pcap", "", "读取的抓包文件（pcap格式）")
+	port := flag.Int("port", 12346, "抓包文件中游戏服务器的TCP端口")
+	clientFile := flag.String("client", "", "客户端发往服务器方向的原始字节流文件（从连接建立时开始）")
+	serverFile := flag.String("server", "", "服务器发往客户端方向的原始字节流文件（从连接建立时开始）")
+	hexDump := flag.Bool("hex", false, "同时输出每帧的十六进制数据")
+	pings := flag.Bool("ping", false, "输出心跳 Ping/Pong（默认省略）")
+	flag.Parse()
+
+	p := &printer{out: os.Stdout, hex: *hexDump, pings: *pings}
+
+	var err error
+	switch {
+	case *addr != "":
+		if *token == "" {
+			log.Fatal("连接服务器须通过 -token 指定认证令牌")
+		}
+		var roomID *common.RoomId
+		if *room != "" {
+			id, err := common.NewRoomId(*room)
+			if err != nil {
+				log.Fatalf("房间ID无效: %v", err)
+			}
+			roomID = &id
+		}
+		var features common.StreamFeatures
+		if *compress {
+			features |= common.FeatureDeflate
+		}
+		err = runMonitor(p, *addr, *token, roomID, features)
+
+	case *pcapFile != "":
+		err = runPcap(p, *pcapFile, uint16(*port))
+
+	case *clientFile != "" && *serverFile != "":
+		err = runRaw(p, *clientFile, *serverFile)
+
+	default:
+		fmt.Fprintln(os.Stderr, "须指定 -addr、-pcap 或同时指定 -client 与 -server")
+		flag.Usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// maskSecret 隐藏令牌与密码，避免输出被转发时泄露
+func maskSecret(s string) string {
+	if s == "" {
+		return ""
+	}
+	return "****"
+}
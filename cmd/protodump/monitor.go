@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"phira-mp/common"
+)
+
+// runMonitor 以观察者身份连接服务器并实时输出收发的命令，直到连接断开或收到中断信号
+func runMonitor(p *printer, addr, token string, room *common.RoomId, features common.StreamFeatures) error {
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		return fmt.Errorf("连接服务器失败: %w", err)
+	}
+	stream, err := common.NewStreamClientNegotiate(conn, common.SupportedProtocols, features)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("握手失败: %w", err)
+	}
+	defer stream.Close()
+
+	format := stream.WireFormat()
+	p.handshake("", format)
+
+	var sent int64
+	send := func(cmd common.ClientCommand) error {
+		data, err := format.EncodeClient(cmd)
+		if err != nil {
+			return err
+		}
+		sent += int64(len(data))
+		p.frame(format, frame{at: time.Now(), offset: sent, dir: toServer, data: data})
+		return stream.SendRaw(data)
+	}
+
+	if err := send(common.ClientCommand{Type: common.ClientCmdAuthenticate, Token: token}); err != nil {
+		return err
+	}
+	if room != nil {
+		if err := send(common.ClientCommand{Type: common.ClientCmdJoinRoom, RoomId: *room, Monitor: true}); err != nil {
+			return err
+		}
+	}
+
+	frames := make(chan []byte)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			data, err := stream.RecvRaw()
+			if err != nil {
+				errs <- err
+				return
+			}
+			frames <- data
+		}
+	}()
+
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	ticker := time.NewTicker(common.HeartbeatInterval)
+	defer ticker.Stop()
+
+	var received int64
+	for {
+		select {
+		case data := <-frames:
+			received += int64(len(data))
+			p.frame(format, frame{at: time.Now(), offset: received, dir: toClient, data: data})
+		case now := <-ticker.C:
+			// 连接断开时接收循环静默退出，按心跳超时判断
+			if now.Sub(stream.LastRecvTime()) > common.HeartbeatDisconnectTimeout {
+				return fmt.Errorf("服务器超过 %v 没有响应，连接已断开", common.HeartbeatDisconnectTimeout)
+			}
+			ping := common.ClientCommand{Type: common.ClientCmdPing, Ping: common.PingTimes{Time: uint64(now.UnixMilli())}}
+			if err := send(ping); err != nil {
+				return err
+			}
+		case err := <-errs:
+			return fmt.Errorf("连接已断开: %w", err)
+		case <-sigChan:
+			return nil
+		}
+	}
+}
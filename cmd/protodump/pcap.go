@@ -0,0 +1,276 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"time"
+)
+
+// pcap 文件的链路层类型
+const (
+	linkNull     = 0   // BSD loopback
+	linkEthernet = 1   // 以太网
+	linkRaw      = 101 // 原始IP
+	linkLinuxSLL = 113 // Linux cooked capture（tcpdump -i any）
+)
+
+// tcpSyn TCP SYN 标志位
+const tcpSyn = 0x02
+
+// pcapReader 读取 pcap 格式（libpcap 经典格式，非 pcapng）的抓包文件
+type pcapReader struct {
+	r     *bufio.Reader
+	order binary.ByteOrder
+	nano  bool
+	link  uint32
+}
+
+func newPcapReader(r io.Reader) (*pcapReader, error) {
+	br := bufio.NewReader(r)
+	var header [24]byte
+	if _, err := io.ReadFull(br, header[:]); err != nil {
+		return nil, fmt.Errorf("读取pcap文件头失败: %w", err)
+	}
+	p := &pcapReader{r: br}
+	switch binary.LittleEndian.Uint32(header[:4]) {
+	case 0xa1b2c3d4:
+		p.order = binary.LittleEndian
+	case 0xa1b23c4d:
+		p.order, p.nano = binary.LittleEndian, true
+	case 0xd4c3b2a1:
+		p.order = binary.BigEndian
+	case 0x4d3cb2a1:
+		p.order, p.nano = binary.BigEndian, true
+	default:
+		return nil, errors.New("不是pcap格式的抓包文件（pcapng 请先用 editcap -F pcap 转换）")
+	}
+	p.link = p.order.Uint32(header[20:24])
+	return p, nil
+}
+
+// next 读取下一个数据包，文件结束时返回 io.EOF
+func (p *pcapReader) next() (time.Time, []byte, error) {
+	var header [16]byte
+	if _, err := io.ReadFull(p.r, header[:]); err != nil {
+		return time.Time{}, nil, err
+	}
+	sec := int64(p.order.Uint32(header[0:4]))
+	frac := int64(p.order.Uint32(header[4:8]))
+	if !p.nano {
+		frac *= int64(time.Microsecond)
+	}
+	data := make([]byte, p.order.Uint32(header[8:12]))
+	if _, err := io.ReadFull(p.r, data); err != nil {
+		return time.Time{}, nil, io.ErrUnexpectedEOF
+	}
+	return time.Unix(sec, frac), data, nil
+}
+
+// ipPayload 去掉链路层头部，返回IP数据包（不是IP数据包时返回nil）
+func (p *pcapReader) ipPayload(data []byte) []byte {
+	switch p.link {
+	case linkNull:
+		if len(data) < 4 {
+			return nil
+		}
+		return data[4:]
+	case linkEthernet:
+		if len(data) < 14 {
+			return nil
+		}
+		etherType, data := binary.BigEndian.Uint16(data[12:14]), data[14:]
+		for etherType == 0x8100 && len(data) >= 4 { // VLAN
+			etherType, data = binary.BigEndian.Uint16(data[2:4]), data[4:]
+		}
+		if etherType != 0x0800 && etherType != 0x86dd {
+			return nil
+		}
+		return data
+	case linkRaw:
+		return data
+	case linkLinuxSLL:
+		if len(data) < 16 {
+			return nil
+		}
+		return data[16:]
+	}
+	return nil
+}
+
+// tcpSegment 一个TCP报文段
+type tcpSegment struct {
+	src, dst net.TCPAddr
+	seq      uint32
+	flags    uint8
+	payload  []byte
+}
+
+// parseTCP 解析IPv4/IPv6中的TCP报文段（分片与IPv6扩展头不处理）
+func parseTCP(ip []byte) (tcpSegment, bool) {
+	var seg tcpSegment
+	if len(ip) < 1 {
+		return seg, false
+	}
+	switch ip[0] >> 4 {
+	case 4:
+		ihl := int(ip[0]&0x0f) * 4
+		if len(ip) < 20 || ihl < 20 || len(ip) < ihl || ip[9] != 6 {
+			return seg, false
+		}
+		if total := int(binary.BigEndian.Uint16(ip[2:4])); total >= ihl && total <= len(ip) {
+			ip = ip[:total] // 去掉以太网填充
+		}
+		seg.src.IP, seg.dst.IP = net.IP(ip[12:16]), net.IP(ip[16:20])
+		ip = ip[ihl:]
+	case 6:
+		if len(ip) < 40 || ip[6] != 6 {
+			return seg, false
+		}
+		if payload := int(binary.BigEndian.Uint16(ip[4:6])); 40+payload <= len(ip) {
+			ip = ip[:40+payload]
+		}
+		seg.src.IP, seg.dst.IP = net.IP(ip[8:24]), net.IP(ip[24:40])
+		ip = ip[40:]
+	default:
+		return seg, false
+	}
+
+	if len(ip) < 20 {
+		return seg, false
+	}
+	offset := int(ip[12]>>4) * 4
+	if offset < 20 || len(ip) < offset {
+		return seg, false
+	}
+	seg.src.Port = int(binary.BigEndian.Uint16(ip[0:2]))
+	seg.dst.Port = int(binary.BigEndian.Uint16(ip[2:4]))
+	seg.seq = binary.BigEndian.Uint32(ip[4:8])
+	seg.flags = ip[13]
+	seg.payload = ip[offset:]
+	return seg, true
+}
+
+// tcpFlow 按序列号重组一个方向的TCP数据，乱序到达的报文段缓存到可以接上时再追加
+type tcpFlow struct {
+	out     *stream
+	next    uint32
+	started bool
+	pending map[uint32]pendingSegment
+}
+
+type pendingSegment struct {
+	data []byte
+	at   time.Time
+}
+
+func (f *tcpFlow) add(seg tcpSegment, at time.Time) {
+	if seg.flags&tcpSyn != 0 {
+		f.next, f.started = seg.seq+1, true
+		return
+	}
+	if len(seg.payload) == 0 {
+		return
+	}
+	if !f.started {
+		// 抓包开始时连接已建立，从第一个报文段开始（握手通常无法解析）
+		f.next, f.started = seg.seq, true
+	}
+	if f.pending == nil {
+		f.pending = make(map[uint32]pendingSegment)
+	}
+	f.pending[seg.seq] = pendingSegment{data: seg.payload, at: at}
+
+	// 依次接上已连续的报文段，重传的部分去掉与已收到数据重叠的字节
+	for progress := true; progress; {
+		progress = false
+		for seq, p := range f.pending {
+			diff := int32(f.next - seq)
+			if diff < 0 {
+				continue
+			}
+			delete(f.pending, seq)
+			if int(diff) < len(p.data) {
+				f.out.append(p.data[diff:], p.at)
+				f.next += uint32(len(p.data)) - uint32(diff)
+			}
+			progress = true
+		}
+	}
+}
+
+// runPcap 读取抓包文件，按连接重组游戏服务器端口上的TCP数据并输出
+func runPcap(p *printer, path string, port uint16) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	reader, err := newPcapReader(file)
+	if err != nil {
+		return err
+	}
+
+	type flows struct {
+		conn           *connection
+		client, server *tcpFlow
+	}
+	byKey := make(map[string]*flows)
+	var order []*flows
+	for {
+		at, data, err := reader.next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			p.note("", "抓包文件在末尾被截断")
+			break
+		}
+		seg, ok := parseTCP(reader.ipPayload(data))
+		if !ok {
+			continue
+		}
+
+		var peer *net.TCPAddr
+		switch {
+		case seg.dst.Port == int(port):
+			peer = &seg.src
+		case seg.src.Port == int(port):
+			peer = &seg.dst
+		default:
+			continue
+		}
+		key := net.JoinHostPort(peer.IP.String(), strconv.Itoa(peer.Port))
+		f := byKey[key]
+		if f == nil || (seg.flags&tcpSyn != 0 && seg.dst.Port == int(port) && f.client.started && f.client.next != seg.seq+1) {
+			// 新连接（同一客户端端口复用时以新的初始序列号区分，重传的SYN不算）
+			c := &connection{name: key}
+			f = &flows{conn: c, client: &tcpFlow{out: &c.client}, server: &tcpFlow{out: &c.server}}
+			byKey[key] = f
+			order = append(order, f)
+		}
+		if seg.dst.Port == int(port) {
+			f.client.add(seg, at)
+		} else {
+			f.server.add(seg, at)
+		}
+	}
+
+	if len(order) == 0 {
+		p.note("", "抓包文件中没有端口 %d 的TCP连接", port)
+		return nil
+	}
+	// 多条连接时按连接依次输出，每行以客户端地址区分
+	for _, f := range order {
+		if len(order) == 1 {
+			f.conn.name = ""
+		}
+		p.decode(f.conn)
+	}
+	return nil
+}
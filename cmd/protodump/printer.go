@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"phira-mp/common"
+)
+
+// direction 帧的发送方向
+type direction bool
+
+const (
+	toServer direction = false
+	toClient direction = true
+)
+
+func (d direction) String() string {
+	if d == toClient {
+		return "S->C"
+	}
+	return "C->S"
+}
+
+// frame 一帧命令数据
+type frame struct {
+	at     time.Time // 收发或抓包时间（原始字节流没有时间，为零值）
+	offset int64     // 帧在该方向字节流中的结束位置（不含握手前的连接）
+	dir    direction
+	conn   string // 所属连接（只有一条连接时为空）
+	data   []byte
+}
+
+// printer 逐帧解码并输出
+type printer struct {
+	out   io.Writer
+	hex   bool
+	pings bool
+}
+
+// handshake 输出握手结果
+func (p *printer) handshake(conn string, f common.WireFormat) {
+	mode := "原版握手"
+	if f.Negotiated {
+		mode = "协商握手"
+	}
+	features := f.Features.String()
+	if features == "" {
+		features = "无"
+	}
+	fmt.Fprintf(p.out, "%s%s：协议版本 %d（按 V%d 解码），连接特性 %s\n", connPrefix(conn), mode, f.Version, f.Protocol(), features)
+}
+
+// note 输出说明信息（截断、解码失败等）
+func (p *printer) note(conn string, format string, args ...interface{}) {
+	fmt.Fprintf(p.out, "%s# %s\n", connPrefix(conn), fmt.Sprintf(format, args...))
+}
+
+// frame 解码并输出一帧
+func (p *printer) frame(f common.WireFormat, fr frame) {
+	var (
+		body    []byte
+		err     error
+		isPing  bool
+		decoded interface{}
+	)
+	if fr.dir == toServer {
+		var cmd common.ClientCommand
+		if cmd, err = f.DecodeClient(fr.data); err == nil {
+			cmd.Token = maskSecret(cmd.Token)
+			cmd.Password = maskSecret(cmd.Password)
+			isPing = cmd.Type == common.ClientCmdPing
+			decoded = cmd
+		}
+	} else {
+		var cmd common.ServerCommand
+		if cmd, err = f.DecodeServer(fr.data); err == nil {
+			isPing = cmd.Type == common.ServerCmdPong
+			decoded = cmd
+		}
+	}
+	if isPing && !p.pings {
+		return
+	}
+	if err == nil {
+		body, err = json.Marshal(decoded)
+	}
+
+	when := fmt.Sprintf("@%-10d", fr.offset)
+	if !fr.at.IsZero() {
+		when = fr.at.Format("15:04:05.000000")
+	}
+	if err != nil {
+		fmt.Fprintf(p.out, "%s%s %s %6dB 解码失败: %v\n", connPrefix(fr.conn), when, fr.dir, len(fr.data), err)
+	} else {
+		fmt.Fprintf(p.out, "%s%s %s %6dB %s\n", connPrefix(fr.conn), when, fr.dir, len(fr.data), body)
+	}
+	if p.hex || err != nil {
+		fmt.Fprint(p.out, hex.Dump(fr.data))
+	}
+}
+
+func connPrefix(conn string) string {
+	if conn == "" {
+		return ""
+	}
+	return "[" + conn + "] "
+}
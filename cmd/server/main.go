@@ -11,14 +11,27 @@ import (
 	"phira-mp/server"
 )
 
+const configPath = "server_config.yml"
+
 func main() {
 	// 解析命令行参数
 	host := flag.String("host", "", "服务器监听地址（留空则使用配置文件）")
 	port := flag.Int("port", 0, "服务器端口（0则使用配置文件）")
+	debugCommands := flag.Bool("debug-commands", false, "以JSON输出每条收到的客户端命令（调试用）")
+	doctor := flag.Bool("doctor", false, "检查配置与运行环境并输出报告后退出")
 	flag.Parse()
 
+	// 作为Windows服务运行时工作目录为系统目录，切换到程序所在目录以读取配置与数据文件
+	service := isService()
+	if service {
+		if err := chdirToExecutable(); err != nil {
+			log.Printf("切换工作目录失败: %v", err)
+		}
+	}
+
 	// 加载配置
-	config, err := server.LoadConfig("server_config.yml")
+	config, err := server.LoadConfig(configPath)
+	configErr := err
 	if err != nil {
 		log.Printf("加载配置失败: %v, 使用默认配置", err)
 		config = server.DefaultConfig()
@@ -31,40 +44,96 @@ func main() {
 	if *port != 0 {
 		config.Port = *port
 	}
+	if *debugCommands {
+		config.DebugCommands = true
+	}
+
+	// 启动自检：失败时以非零状态退出
+	if *doctor {
+		report := server.RunDoctor(config, configPath, configErr)
+		report.Print(os.Stdout)
+		if report.Failed() {
+			os.Exit(1)
+		}
+		return
+	}
+
+	// 加载事件钩子插件
+	if err := server.LoadPlugins(config.Plugins); err != nil {
+		log.Fatalf("%v", err)
+	}
 
 	// 创建服务器
 	srv := server.NewServer(config)
+	address := listenAddress(config)
+
+	// 由Windows服务控制管理器启动时，按服务控制请求停止
+	if service {
+		if err := runService(srv, address); err != nil {
+			log.Fatalf("服务运行失败: %v", err)
+		}
+		return
+	}
 
 	// 设置信号处理
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	upgradeChan := make(chan os.Signal, 1)
+	if len(upgradeSignals) > 0 {
+		signal.Notify(upgradeChan, upgradeSignals...)
+	}
 
 	// 启动服务器
 	go func() {
-		// 构建监听地址，处理IPv6格式
-		var address string
-		if config.Host == "" || config.Host == "0.0.0.0" {
-			// 空或IPv4格式，直接使用
-			address = fmt.Sprintf("%s:%d", config.Host, config.Port)
-		} else if config.Host == "::" {
-			// IPv6通配符地址
-			address = fmt.Sprintf("[::]:%d", config.Port)
-		} else if len(config.Host) > 0 && config.Host[0] == ':' && config.Host != "::" {
-			// 已经是 :port 格式
-			address = fmt.Sprintf("%s%d", config.Host, config.Port)
-		} else {
-			// 其他情况（包括具体IPv6地址）
-			address = fmt.Sprintf("%s:%d", config.Host, config.Port)
-		}
-
 		if err := srv.Start(address); err != nil {
 			log.Fatalf("服务器错误: %v", err)
 		}
 	}()
 
+	// 通知systemd服务已就绪，并定期发送看门狗心跳
+	stopNotify := make(chan struct{})
+	go superviseSystemd(srv, address, stopNotify)
+
 	// 等待信号
-	<-sigChan
-	log.Println("正在关闭服务器...")
-	srv.Stop()
-	log.Println("服务器已停止")
+	for {
+		select {
+		case <-upgradeChan:
+			log.Println("正在移交监听套接字给新进程...")
+			if !upgrade(srv) {
+				continue
+			}
+			// 新进程接受新连接并接管systemd通知，本进程等待现有会话结束，期间收到停止信号则立即停止
+			close(stopNotify)
+			go func() {
+				<-sigChan
+				srv.Stop()
+			}()
+			srv.Drain()
+			log.Println("服务器已停止")
+			return
+		case <-sigChan:
+			log.Println("正在关闭服务器...")
+			close(stopNotify)
+			sdNotify("STOPPING=1")
+			srv.Stop()
+			log.Println("服务器已停止")
+			return
+		}
+	}
+}
+
+// listenAddress 构建监听地址，处理IPv6格式
+func listenAddress(config server.ServerConfig) string {
+	if config.Host == "" || config.Host == "0.0.0.0" {
+		// 空或IPv4格式，直接使用
+		return fmt.Sprintf("%s:%d", config.Host, config.Port)
+	} else if config.Host == "::" {
+		// IPv6通配符地址
+		return fmt.Sprintf("[::]:%d", config.Port)
+	} else if config.Host[0] == ':' {
+		// 已经是 :port 格式
+		return fmt.Sprintf("%s%d", config.Host, config.Port)
+	}
+	// 其他情况（包括具体IPv6地址）
+	return fmt.Sprintf("%s:%d", config.Host, config.Port)
 }
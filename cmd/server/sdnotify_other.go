@@ -0,0 +1,15 @@
+//go:build !linux
+
+package main
+
+import "time"
+
+// sdNotify systemd仅在Linux上可用
+func sdNotify(state string) bool {
+	return false
+}
+
+// watchdogInterval systemd仅在Linux上可用
+func watchdogInterval() time.Duration {
+	return 0
+}
@@ -0,0 +1,18 @@
+//go:build !windows
+
+package main
+
+import (
+	"errors"
+
+	"phira-mp/server"
+)
+
+// isService 仅Windows支持以服务方式运行
+func isService() bool {
+	return false
+}
+
+func runService(srv *server.Server, address string) error {
+	return errors.New("当前平台不支持以服务方式运行")
+}
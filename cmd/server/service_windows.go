@@ -0,0 +1,82 @@
+package main
+
+import (
+	"log"
+	"time"
+
+	"golang.org/x/sys/windows/svc"
+
+	"phira-mp/server"
+)
+
+// serviceName 注册Windows服务时使用的名称（sc create phira-mp binPath= ...）
+const serviceName = "phira-mp"
+
+// serviceStartTimeout 等待服务器开始接受连接的最长时间
+const serviceStartTimeout = 30 * time.Second
+
+// isService 是否由Windows服务控制管理器启动
+func isService() bool {
+	ok, err := svc.IsWindowsService()
+	if err != nil {
+		log.Printf("检测服务运行环境失败: %v", err)
+		return false
+	}
+	return ok
+}
+
+// runService 以Windows服务方式运行，直到收到停止或关机请求
+func runService(srv *server.Server, address string) error {
+	return svc.Run(serviceName, &serviceHandler{srv: srv, address: address})
+}
+
+type serviceHandler struct {
+	srv     *server.Server
+	address string
+}
+
+func (h *serviceHandler) Execute(args []string, requests <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	changes <- svc.Status{State: svc.StartPending, WaitHint: uint32(serviceStartTimeout / time.Millisecond)}
+
+	failed := make(chan error, 1)
+	go func() {
+		failed <- h.srv.Start(h.address)
+	}()
+
+	// 开始接受连接后才报告为运行中，启动失败时以非零退出码结束服务
+	select {
+	case <-h.srv.Ready():
+	case err := <-failed:
+		log.Printf("服务器错误: %v", err)
+		return false, 1
+	case <-time.After(serviceStartTimeout):
+		log.Printf("服务器启动超时")
+		return false, 1
+	}
+
+	const accepts = svc.AcceptStop | svc.AcceptShutdown
+	changes <- svc.Status{State: svc.Running, Accepts: accepts}
+	log.Printf("Windows服务 %s 已启动", serviceName)
+
+	for {
+		select {
+		case req := <-requests:
+			switch req.Cmd {
+			case svc.Interrogate:
+				changes <- req.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				changes <- svc.Status{State: svc.StopPending}
+				log.Println("正在关闭服务器...")
+				h.srv.Stop()
+				log.Println("服务器已停止")
+				return false, 0
+			}
+		case err := <-failed:
+			if err != nil {
+				log.Printf("服务器错误: %v", err)
+				return false, 1
+			}
+			return false, 0
+		}
+	}
+}
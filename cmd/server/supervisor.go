@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"phira-mp/server"
+)
+
+// superviseSystemd 服务器开始接受连接后通知systemd就绪（Type=notify），
+// 配置了 WatchdogSec 时按其一半的间隔发送看门狗心跳
+func superviseSystemd(srv *server.Server, address string, stop <-chan struct{}) {
+	select {
+	case <-srv.Ready():
+	case <-stop:
+		return
+	}
+	if !sdNotify(fmt.Sprintf("READY=1\nSTATUS=正在监听 %s", address)) {
+		return
+	}
+	log.Printf("已通知systemd服务就绪")
+
+	interval := watchdogInterval()
+	if interval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			// 统计负载需要获取房间与用户的锁，服务器死锁时这里会阻塞，心跳随之停止，由systemd重启服务
+			counts := srv.LoadCounts()
+			sdNotify(fmt.Sprintf("WATCHDOG=1\nSTATUS=正在监听 %s，在线 %d 人，房间 %d 个", address, counts.Users, counts.Rooms))
+		case <-stop:
+			return
+		}
+	}
+}
+
+// chdirToExecutable 切换工作目录到程序所在目录
+func chdirToExecutable() error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	return os.Chdir(filepath.Dir(exe))
+}
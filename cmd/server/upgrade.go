@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"phira-mp/server"
+)
+
+// upgrade 启动新进程并移交监听套接字，失败时返回false，本进程继续正常运行
+func upgrade(srv *server.Server) bool {
+	// 看门狗心跳改由新进程发送（systemd的 WATCHDOG_PID 仍为本进程）
+	os.Unsetenv("WATCHDOG_PID")
+	pid, err := srv.Handover()
+	if err != nil {
+		log.Printf("[升级] 移交监听套接字失败: %v", err)
+		return false
+	}
+	// 由systemd管理时将主进程改为新进程，本进程退出后服务不会被视为已停止
+	sdNotify(fmt.Sprintf("MAINPID=%d\nSTATUS=已移交给新进程 %d，等待现有会话结束", pid, pid))
+	return true
+}
@@ -0,0 +1,6 @@
+package main
+
+import "os"
+
+// upgradeSignals Windows不支持移交监听套接字，不响应平滑升级信号
+var upgradeSignals []os.Signal
@@ -1,36 +1,91 @@
 package common
 
+//go:generate go run ./binarygen -output binary_gen.go
+
 import (
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"math"
 )
 
+// ErrUnexpectedEOF 数据在读取完成前结束
+var ErrUnexpectedEOF = errors.New("unexpected EOF")
+
+// ErrListTooLong 列表长度前缀超过上限（见 DecodeLimits）
+var ErrListTooLong = errors.New("list too long")
+
+// ErrIDOutOfRange 谱面或成绩ID超出旧版协议（int32）的表示范围
+var ErrIDOutOfRange = errors.New("id out of int32 range")
+
+// listPrealloc 读取列表时预分配的元素数量上限，更多的元素随读取逐步扩容
+// 长度前缀由对端控制，不能直接按其分配内存
+const listPrealloc = 64
+
 // BinaryReader 二进制数据读取器
 type BinaryReader struct {
-	data []byte
-	pos  int
+	data      []byte
+	pos       int
+	strict    bool // 严格模式：截断与未知类型均返回错误
+	narrowIDs bool // 谱面与成绩ID按旧版协议读取为int32（见 ReadID）
+
+	stringErrors bool // 失败结果按旧版协议只有错误信息，没有错误码（见 Result）
+	noTimestamps bool // 房间消息按旧版协议没有时间戳（见 Message）
+	noPingTimes  bool // Ping/Pong 按旧版协议没有时间戳（见 PingTimes）
+
+	noProfileCards bool // 用户信息按旧版协议没有资料卡（见 UserInfo）
+	noPasswords    bool // CreateRoom/JoinRoom 按旧版协议没有房间密码
+	noTeams        bool // 房间状态按旧版协议没有队伍分配
 }
 
 // NewBinaryReader 创建新的二进制读取器
+// 非严格模式下 Message 与 ServerCommand 遇到数据截断时保留已读取的字段并返回nil（兼容旧行为）
 func NewBinaryReader(data []byte) *BinaryReader {
 	return &BinaryReader{data: data, pos: 0}
 }
 
+// NewStrictBinaryReader 创建严格模式的二进制读取器
+func NewStrictBinaryReader(data []byte) *BinaryReader {
+	return &BinaryReader{data: data, strict: true}
+}
+
+// Strict 是否为严格模式
+func (r *BinaryReader) Strict() bool {
+	return r.strict
+}
+
+// skipRest 跳过剩余数据（非严格模式下忽略未知类型的内容）
+func (r *BinaryReader) skipRest() {
+	r.pos = len(r.data)
+}
+
+// tolerate 非严格模式下忽略数据截断错误
+func (r *BinaryReader) tolerate(err error) error {
+	if !r.strict && errors.Is(err, ErrUnexpectedEOF) {
+		return nil
+	}
+	return err
+}
+
 // Byte 读取一个字节
 func (r *BinaryReader) Byte() (byte, error) {
 	if r.pos >= len(r.data) {
-		return 0, fmt.Errorf("unexpected EOF")
+		return 0, ErrUnexpectedEOF
 	}
 	b := r.data[r.pos]
 	r.pos++
 	return b, nil
 }
 
+// Remaining 剩余未读取的字节数
+func (r *BinaryReader) Remaining() int {
+	return len(r.data) - r.pos
+}
+
 // Take 读取指定长度的字节
 func (r *BinaryReader) Take(n int) ([]byte, error) {
-	if r.pos+n > len(r.data) {
-		return nil, fmt.Errorf("unexpected EOF")
+	if n < 0 || n > r.Remaining() {
+		return nil, ErrUnexpectedEOF
 	}
 	result := r.data[r.pos : r.pos+n]
 	r.pos += n
@@ -46,6 +101,9 @@ func (r *BinaryReader) Uleb() (uint64, error) {
 		if err != nil {
 			return 0, err
 		}
+		if shift >= 64 {
+			return 0, fmt.Errorf("uleb128 overflow")
+		}
 		result |= uint64(b&0x7f) << shift
 		if b&0x80 == 0 {
 			break
@@ -55,14 +113,84 @@ func (r *BinaryReader) Uleb() (uint64, error) {
 	return result, nil
 }
 
+// Varint 读取ZigZag编码的有符号LEB128整数
+func (r *BinaryReader) Varint() (int64, error) {
+	v, err := r.Uleb()
+	if err != nil {
+		return 0, err
+	}
+	return int64(v>>1) ^ -int64(v&1), nil
+}
+
+// Len 读取列表的长度前缀，超过 limit（大于0时）返回 ErrListTooLong
+// 每个元素至少占一个字节，长度超过剩余数据时直接视为截断
+func (r *BinaryReader) Len(limit int) (int, error) {
+	n, err := r.Uleb()
+	if err != nil {
+		return 0, err
+	}
+	if limit > 0 && n > uint64(limit) {
+		return 0, fmt.Errorf("%w: %d > %d", ErrListTooLong, n, limit)
+	}
+	if n > uint64(r.Remaining()) {
+		return 0, ErrUnexpectedEOF
+	}
+	return int(n), nil
+}
+
+// ReadList 读取带长度前缀的列表，limit 见 Len；按读取进度逐步分配内存
+func ReadList[T any, P interface {
+	*T
+	BinaryData
+}](r *BinaryReader, limit int) ([]T, error) {
+	n, err := r.Len(limit)
+	if err != nil {
+		return nil, err
+	}
+	list := make([]T, 0, min(n, listPrealloc))
+	for i := 0; i < n; i++ {
+		var v T
+		if err := P(&v).ReadBinary(r); err != nil {
+			return nil, err
+		}
+		list = append(list, v)
+	}
+	return list, nil
+}
+
 // Read 读取实现了BinaryData接口的类型
 func (r *BinaryReader) Read(v BinaryData) error {
 	return v.ReadBinary(r)
 }
 
+// DecodeStrict 从完整的帧数据解码，读取出错或帧末尾有多余数据时返回错误
+// 用于网络收包，避免截断或错位的帧被解析为部分填充的结构体
+func DecodeStrict(data []byte, v BinaryData) error {
+	r := AcquireStrictBinaryReader(data)
+	defer ReleaseBinaryReader(r)
+	if err := v.ReadBinary(r); err != nil {
+		return fmt.Errorf("malformed frame: %w", err)
+	}
+	if n := r.Remaining(); n != 0 {
+		return fmt.Errorf("malformed frame: %d trailing bytes", n)
+	}
+	return nil
+}
+
 // BinaryWriter 二进制数据写入器
 type BinaryWriter struct {
-	data []byte
+	data         []byte
+	legacy       bool // 按原版协议编码，省略追加字段
+	noExtensions bool // 对端不支持命令末尾的扩展块
+	narrowIDs    bool // 谱面与成绩ID按旧版协议写入为int32（见 WriteID）
+	stringErrors bool // 失败结果按旧版协议只写入错误信息（见 Result）
+	noTimestamps bool // 房间消息按旧版协议不写入时间戳（见 Message）
+	noPingTimes  bool // Ping/Pong 按旧版协议不写入时间戳（见 PingTimes）
+
+	noProfileCards bool // 用户信息按旧版协议不写入资料卡（见 UserInfo）
+	noPasswords    bool // CreateRoom/JoinRoom 按旧版协议不写入房间密码
+	baseJudgements bool // 判定按旧版协议只写入原版判定（见 writeJudges）
+	noTeams        bool // 房间状态按旧版协议不写入队伍分配
 }
 
 // NewBinaryWriter 创建新的二进制写入器
@@ -75,9 +203,25 @@ func (w *BinaryWriter) Data() []byte {
 	return w.data
 }
 
-// WriteByte 写入一个字节
-func (w *BinaryWriter) WriteByte(b byte) {
+// Reset 清空已写入的数据并恢复默认编码方式，保留缓冲区
+func (w *BinaryWriter) Reset() {
+	w.data = w.data[:0]
+	w.legacy = false
+	w.noExtensions = false
+	w.narrowIDs = false
+	w.stringErrors = false
+	w.noTimestamps = false
+	w.noPingTimes = false
+	w.noProfileCards = false
+	w.noPasswords = false
+	w.baseJudgements = false
+	w.noTeams = false
+}
+
+// WriteByte 写入一个字节（实现io.ByteWriter，始终返回nil）
+func (w *BinaryWriter) WriteByte(b byte) error {
 	w.data = append(w.data, b)
+	return nil
 }
 
 // WriteBytes 写入字节切片
@@ -100,6 +244,21 @@ func (w *BinaryWriter) Uleb(v uint64) {
 	}
 }
 
+// Varint 写入ZigZag编码的有符号LEB128整数
+func (w *BinaryWriter) Varint(v int64) {
+	w.Uleb(uint64(v<<1) ^ uint64(v>>63))
+}
+
+// Extended 是否编码追加字段（原版协议客户端不识别）
+func (w *BinaryWriter) Extended() bool {
+	return !w.legacy
+}
+
+// Extensions 是否写入命令末尾的扩展块（见 Extensions）
+func (w *BinaryWriter) Extensions() bool {
+	return !w.legacy && !w.noExtensions
+}
+
 // Write 写入实现了BinaryData接口的类型
 func (w *BinaryWriter) Write(v BinaryData) error {
 	return v.WriteBinary(w)
@@ -183,6 +342,28 @@ func WriteInt32(w *BinaryWriter, v int32) {
 	w.WriteBytes(b)
 }
 
+// ReadID 读取谱面或成绩ID：V10起为变长编码的64位整数（见 Varint），之前的协议版本为int32
+func ReadID(r *BinaryReader) (int64, error) {
+	if r.narrowIDs {
+		v, err := ReadInt32(r)
+		return int64(v), err
+	}
+	return r.Varint()
+}
+
+// WriteID 写入谱面或成绩ID，按旧版协议编码时超出int32范围返回 ErrIDOutOfRange
+func WriteID(w *BinaryWriter, v int64) error {
+	if !w.narrowIDs {
+		w.Varint(v)
+		return nil
+	}
+	if v < math.MinInt32 || v > math.MaxInt32 {
+		return fmt.Errorf("%w: %d", ErrIDOutOfRange, v)
+	}
+	WriteInt32(w, int32(v))
+	return nil
+}
+
 // ReadFloat32 读取float32
 func ReadFloat32(r *BinaryReader) (float32, error) {
 	data, err := r.Take(4)
@@ -223,6 +404,9 @@ func ReadString(r *BinaryReader) (string, error) {
 	if err != nil {
 		return "", err
 	}
+	if len > uint64(r.Remaining()) {
+		return "", ErrUnexpectedEOF
+	}
 	data, err := r.Take(int(len))
 	if err != nil {
 		return "", err
@@ -235,3 +419,11 @@ func WriteString(w *BinaryWriter, v string) {
 	w.Uleb(uint64(len(v)))
 	w.WriteBytes([]byte(v))
 }
+
+// LegacyID 将ID转换为旧版协议的int32，超出范围时返回0
+func LegacyID(v int64) int32 {
+	if v < math.MinInt32 || v > math.MaxInt32 {
+		return 0
+	}
+	return int32(v)
+}
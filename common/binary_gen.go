@@ -0,0 +1,373 @@
+// Code generated by binarygen. DO NOT EDIT.
+
+package common
+
+func (pc *ProfileCard) ReadBinary(r *BinaryReader) error {
+	var err error
+	if pc.Rks, err = ReadFloat32(r); err != nil {
+		return err
+	}
+	if pc.PlayCount, err = ReadUint32(r); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (pc *ProfileCard) WriteBinary(w *BinaryWriter) error {
+	WriteFloat32(w, pc.Rks)
+	WriteUint32(w, pc.PlayCount)
+	return nil
+}
+
+func (qs *QueueStatus) ReadBinary(r *BinaryReader) error {
+	var err error
+	if err = qs.RoomId.ReadBinary(r); err != nil {
+		return err
+	}
+	if qs.Position, err = ReadUint32(r); err != nil {
+		return err
+	}
+	var n1 int
+	if n1, err = r.Len(0); err != nil {
+		return err
+	}
+	qs.Waiting = make([]UserInfo, 0, min(n1, listPrealloc))
+	for i2 := 0; i2 < n1; i2++ {
+		var e3 UserInfo
+		if err = e3.ReadBinary(r); err != nil {
+			return err
+		}
+		qs.Waiting = append(qs.Waiting, e3)
+	}
+	return nil
+}
+
+func (qs *QueueStatus) WriteBinary(w *BinaryWriter) error {
+	if err := qs.RoomId.WriteBinary(w); err != nil {
+		return err
+	}
+	WriteUint32(w, qs.Position)
+	w.Uleb(uint64(len(qs.Waiting)))
+	for i1 := range qs.Waiting {
+		if err := qs.Waiting[i1].WriteBinary(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (ls *LoadStatus) ReadBinary(r *BinaryReader) error {
+	var err error
+	if ls.Loaded, err = ReadUint32(r); err != nil {
+		return err
+	}
+	if ls.Total, err = ReadUint32(r); err != nil {
+		return err
+	}
+	if ls.Progress, err = ReadUint8(r); err != nil {
+		return err
+	}
+	if ls.Remaining, err = ReadUint32(r); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ls *LoadStatus) WriteBinary(w *BinaryWriter) error {
+	WriteUint32(w, ls.Loaded)
+	WriteUint32(w, ls.Total)
+	WriteUint8(w, ls.Progress)
+	WriteUint32(w, ls.Remaining)
+	return nil
+}
+
+func (pi *ProfileInfo) ReadBinary(r *BinaryReader) error {
+	var err error
+	if pi.ID, err = ReadInt32(r); err != nil {
+		return err
+	}
+	if pi.Name, err = ReadString(r); err != nil {
+		return err
+	}
+	if pi.Avatar, err = ReadString(r); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (pi *ProfileInfo) WriteBinary(w *BinaryWriter) error {
+	WriteInt32(w, pi.ID)
+	WriteString(w, pi.Name)
+	WriteString(w, pi.Avatar)
+	return nil
+}
+
+func (ls *LiveScore) ReadBinary(r *BinaryReader) error {
+	var err error
+	if ls.Score, err = ReadInt32(r); err != nil {
+		return err
+	}
+	if ls.Combo, err = ReadInt32(r); err != nil {
+		return err
+	}
+	if ls.Accuracy, err = ReadFloat32(r); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (ls *LiveScore) WriteBinary(w *BinaryWriter) error {
+	WriteInt32(w, ls.Score)
+	WriteInt32(w, ls.Combo)
+	WriteFloat32(w, ls.Accuracy)
+	return nil
+}
+
+func (rc *RoomClosed) ReadBinary(r *BinaryReader) error {
+	var err error
+	if err = rc.RoomId.ReadBinary(r); err != nil {
+		return err
+	}
+	if rc.Reason, err = ReadString(r); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (rc *RoomClosed) WriteBinary(w *BinaryWriter) error {
+	if err := rc.RoomId.WriteBinary(w); err != nil {
+		return err
+	}
+	WriteString(w, rc.Reason)
+	return nil
+}
+
+func (cp *ChartPreview) ReadBinary(r *BinaryReader) error {
+	var err error
+	if cp.ID, err = ReadInt32(r); err != nil {
+		return err
+	}
+	if cp.Name, err = ReadString(r); err != nil {
+		return err
+	}
+	if cp.Level, err = ReadString(r); err != nil {
+		return err
+	}
+	if cp.Difficulty, err = ReadFloat32(r); err != nil {
+		return err
+	}
+	if cp.Charter, err = ReadString(r); err != nil {
+		return err
+	}
+	if cp.Composer, err = ReadString(r); err != nil {
+		return err
+	}
+	if cp.Illustrator, err = ReadString(r); err != nil {
+		return err
+	}
+	if cp.Illustration, err = ReadString(r); err != nil {
+		return err
+	}
+	if cp.Duration, err = ReadFloat32(r); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (cp *ChartPreview) WriteBinary(w *BinaryWriter) error {
+	WriteInt32(w, cp.ID)
+	WriteString(w, cp.Name)
+	WriteString(w, cp.Level)
+	WriteFloat32(w, cp.Difficulty)
+	WriteString(w, cp.Charter)
+	WriteString(w, cp.Composer)
+	WriteString(w, cp.Illustrator)
+	WriteString(w, cp.Illustration)
+	WriteFloat32(w, cp.Duration)
+	return nil
+}
+
+func (cl *ChartLevel) ReadBinary(r *BinaryReader) error {
+	var err error
+	if cl.Name, err = ReadString(r); err != nil {
+		return err
+	}
+	if cl.Level, err = ReadString(r); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (cl *ChartLevel) WriteBinary(w *BinaryWriter) error {
+	WriteString(w, cl.Name)
+	WriteString(w, cl.Level)
+	return nil
+}
+
+func (cv *ChartValidation) ReadBinary(r *BinaryReader) error {
+	var err error
+	if err = cv.Chart.ReadBinary(r); err != nil {
+		return err
+	}
+	if cv.Reason, err = ReadString(r); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (cv *ChartValidation) WriteBinary(w *BinaryWriter) error {
+	if err := cv.Chart.WriteBinary(w); err != nil {
+		return err
+	}
+	WriteString(w, cv.Reason)
+	return nil
+}
+
+func (rl *RoomList) ReadBinary(r *BinaryReader) error {
+	var err error
+	var n1 int
+	if n1, err = r.Len(0); err != nil {
+		return err
+	}
+	rl.Rooms = make([]RoomListEntry, 0, min(n1, listPrealloc))
+	for i2 := 0; i2 < n1; i2++ {
+		var e3 RoomListEntry
+		if err = e3.ReadBinary(r); err != nil {
+			return err
+		}
+		rl.Rooms = append(rl.Rooms, e3)
+	}
+	if rl.Page, err = ReadUint32(r); err != nil {
+		return err
+	}
+	if rl.Total, err = ReadUint32(r); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (rl *RoomList) WriteBinary(w *BinaryWriter) error {
+	w.Uleb(uint64(len(rl.Rooms)))
+	for i1 := range rl.Rooms {
+		if err := rl.Rooms[i1].WriteBinary(w); err != nil {
+			return err
+		}
+	}
+	WriteUint32(w, rl.Page)
+	WriteUint32(w, rl.Total)
+	return nil
+}
+
+func (ch *ChatHistory) ReadBinary(r *BinaryReader) error {
+	var err error
+	var n1 int
+	if n1, err = r.Len(0); err != nil {
+		return err
+	}
+	ch.Messages = make([]Message, 0, min(n1, listPrealloc))
+	for i2 := 0; i2 < n1; i2++ {
+		var e3 Message
+		if err = e3.ReadBinary(r); err != nil {
+			return err
+		}
+		ch.Messages = append(ch.Messages, e3)
+	}
+	return nil
+}
+
+func (ch *ChatHistory) WriteBinary(w *BinaryWriter) error {
+	w.Uleb(uint64(len(ch.Messages)))
+	for i1 := range ch.Messages {
+		if err := ch.Messages[i1].WriteBinary(w); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (k *Kicked) ReadBinary(r *BinaryReader) error {
+	var err error
+	var has1 bool
+	if has1, err = ReadBool(r); err != nil {
+		return err
+	}
+	if has1 {
+		k.RoomId = new(RoomId)
+		if err = k.RoomId.ReadBinary(r); err != nil {
+			return err
+		}
+	}
+	if err = k.Reason.ReadBinary(r); err != nil {
+		return err
+	}
+	if k.Message, err = ReadString(r); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (k *Kicked) WriteBinary(w *BinaryWriter) error {
+	WriteBool(w, k.RoomId != nil)
+	if k.RoomId != nil {
+		if err := k.RoomId.WriteBinary(w); err != nil {
+			return err
+		}
+	}
+	if err := k.Reason.WriteBinary(w); err != nil {
+		return err
+	}
+	WriteString(w, k.Message)
+	return nil
+}
+
+func (d *Disconnect) ReadBinary(r *BinaryReader) error {
+	var err error
+	if err = d.Reason.ReadBinary(r); err != nil {
+		return err
+	}
+	if d.Message, err = ReadString(r); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (d *Disconnect) WriteBinary(w *BinaryWriter) error {
+	if err := d.Reason.WriteBinary(w); err != nil {
+		return err
+	}
+	WriteString(w, d.Message)
+	return nil
+}
+
+func (rle *RoomListEntry) ReadBinary(r *BinaryReader) error {
+	var err error
+	if err = rle.RoomId.ReadBinary(r); err != nil {
+		return err
+	}
+	if err = rle.Host.ReadBinary(r); err != nil {
+		return err
+	}
+	if rle.Players, err = ReadUint32(r); err != nil {
+		return err
+	}
+	if rle.MaxPlayers, err = ReadUint32(r); err != nil {
+		return err
+	}
+	if rle.State, err = ReadUint8(r); err != nil {
+		return err
+	}
+	return nil
+}
+
+func (rle *RoomListEntry) WriteBinary(w *BinaryWriter) error {
+	if err := rle.RoomId.WriteBinary(w); err != nil {
+		return err
+	}
+	if err := rle.Host.WriteBinary(w); err != nil {
+		return err
+	}
+	WriteUint32(w, rle.Players)
+	WriteUint32(w, rle.MaxPlayers)
+	WriteUint8(w, rle.State)
+	return nil
+}
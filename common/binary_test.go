@@ -1,6 +1,7 @@
 package common
 
 import (
+	"math"
 	"testing"
 )
 
@@ -106,6 +107,84 @@ func TestCompactPos(t *testing.T) {
 	}
 }
 
+// TestF16RoundTrip 所有float16值转为float32后再转回应得到相同的位（NaN保持为NaN）
+func TestF16RoundTrip(t *testing.T) {
+	for i := 0; i <= 0xFFFF; i++ {
+		h := uint16(i)
+		f := f16BitsToFloat32(h)
+		if h&0x7C00 == 0x7C00 && h&0x3FF != 0 {
+			if !math.IsNaN(float64(f)) {
+				t.Fatalf("%#04x 应转换为NaN，实际 %v", h, f)
+			}
+		}
+		if got := float32ToF16Bits(f); got != h {
+			t.Fatalf("%#04x -> %v -> %#04x", h, f, got)
+		}
+	}
+}
+
+// TestF16Rounding 相邻两个float16的中点按偶数舍入，略大或略小于中点时舍入到较近的一侧
+func TestF16Rounding(t *testing.T) {
+	for _, sign := range []uint16{0, 0x8000} {
+		// 0x7BFF 为最大有限值，与 0x7C00（无穷大）之间的中点同样适用
+		for h := uint16(0); h < 0x7C00; h++ {
+			lo, hi := sign|h, sign|(h+1)
+			mid := float32((float64(f16BitsToFloat32(lo)) + float64(f16BitsToFloat32(hi))) / 2)
+			if h == 0x7BFF {
+				mid = float32(math.Copysign(65520, float64(f16BitsToFloat32(lo))))
+			}
+
+			even := lo
+			if h&1 == 1 {
+				even = hi
+			}
+			if got := float32ToF16Bits(mid); got != even {
+				t.Fatalf("中点 %v 应舍入为 %#04x，实际 %#04x", mid, even, got)
+			}
+			toZero := math.Nextafter32(mid, 0)
+			if got := float32ToF16Bits(toZero); got != lo {
+				t.Fatalf("%v 应舍入为 %#04x，实际 %#04x", toZero, lo, got)
+			}
+			away := math.Nextafter32(mid, float32(math.Copysign(math.Inf(1), float64(mid))))
+			if got := float32ToF16Bits(away); got != hi {
+				t.Fatalf("%v 应舍入为 %#04x，实际 %#04x", away, hi, got)
+			}
+		}
+	}
+}
+
+// TestF16Special 测试特殊值与范围外的值
+func TestF16Special(t *testing.T) {
+	tests := []struct {
+		f    float32
+		want uint16
+	}{
+		{0, 0x0000},
+		{float32(math.Copysign(0, -1)), 0x8000},
+		{1, 0x3C00},
+		{-2, 0xC000},
+		{0.5, 0x3800},
+		{65504, 0x7BFF},
+		{1e6, 0x7C00},
+		{-1e6, 0xFC00},
+		{float32(math.Inf(1)), 0x7C00},
+		{float32(math.Inf(-1)), 0xFC00},
+		{float32(math.Ldexp(1, -24)), 0x0001}, // 最小非规格化数
+		{float32(math.Ldexp(1, -25)), 0x0000}, // 最小非规格化数的一半，偶数舍入为零
+		{float32(math.Ldexp(1.5, -25)), 0x0001},
+		{float32(math.Ldexp(1, -14)), 0x0400}, // 最小规格化数
+		{1e-10, 0x0000},
+	}
+	for _, tt := range tests {
+		if got := float32ToF16Bits(tt.f); got != tt.want {
+			t.Errorf("float32ToF16Bits(%v) = %#04x, 期望 %#04x", tt.f, got, tt.want)
+		}
+	}
+	if h := float32ToF16Bits(float32(math.NaN())); h&0x7C00 != 0x7C00 || h&0x3FF == 0 {
+		t.Errorf("NaN 转换结果不是NaN: %#04x", h)
+	}
+}
+
 func TestRoomId(t *testing.T) {
 	// 测试有效ID
 	validIDs := []string{"room1", "test-room", "test_room", "Room123"}
@@ -224,3 +303,49 @@ func BenchmarkBinaryData(b *testing.B) {
 		r.Take(len(data))
 	}
 }
+
+func TestBinaryWriterPool(t *testing.T) {
+	w := AcquireBinaryWriter()
+	w.legacy = true
+	w.noExtensions = true
+	w.WriteBytes([]byte{1, 2, 3})
+	ReleaseBinaryWriter(w)
+
+	w = AcquireBinaryWriter()
+	if len(w.Data()) != 0 || !w.Extended() || !w.Extensions() {
+		t.Errorf("pooled writer not reset: len=%d extended=%v extensions=%v", len(w.Data()), w.Extended(), w.Extensions())
+	}
+	ReleaseBinaryWriter(w)
+
+	// Oversized buffers are left to the GC instead of being pooled
+	big := AcquireBinaryWriter()
+	big.WriteBytes(make([]byte, maxPooledWriterSize+1))
+	ReleaseBinaryWriter(big)
+	if len(big.Data()) != maxPooledWriterSize+1 {
+		t.Error("oversized writer should not be reset on release")
+	}
+
+	r := AcquireStrictBinaryReader([]byte{1})
+	r.Byte()
+	ReleaseBinaryReader(r)
+	r = AcquireBinaryReader([]byte{7})
+	if b, err := r.Byte(); err != nil || b != 7 || r.Strict() {
+		t.Errorf("pooled reader not reset: b=%d err=%v strict=%v", b, err, r.Strict())
+	}
+	ReleaseBinaryReader(r)
+}
+
+func BenchmarkPooledServerCommand(b *testing.B) {
+	shim := shimFor(ProtocolLatest)
+	msg := "hello"
+	cmd := ServerCommand{Type: ServerCmdChat, ChatResult: &Result[struct{}]{Err: &msg}}
+
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		w, err := shim.encodeServer(&cmd)
+		if err != nil {
+			b.Fatal(err)
+		}
+		ReleaseBinaryWriter(w)
+	}
+}
@@ -0,0 +1,303 @@
+// binarygen 为带有 //binary:generate 标记的结构体生成 ReadBinary/WriteBinary
+//
+// 用法（在 common 目录下由 go generate 调用）：
+//
+//	//go:generate go run ./binarygen -output binary_gen.go
+//
+// 字段按声明顺序编码，支持的字段类型：
+//   - int8/uint8/byte/uint16/uint32/int32/float32/bool/string
+//   - 实现了 BinaryData 的具名类型（调用其 ReadBinary/WriteBinary）
+//   - 以上类型的切片（ULEB128 长度前缀，读取时按进度逐步分配内存）
+//   - 以上类型的指针（bool 标记是否存在）
+//
+// 带有 `binary:"-"` 标签的字段不参与编码
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// marker 结构体声明前的生成标记
+const marker = "//binary:generate"
+
+// basicTypes 基础类型对应的读写函数后缀
+var basicTypes = map[string]string{
+	"int8":    "Int8",
+	"uint8":   "Uint8",
+	"byte":    "Uint8",
+	"uint16":  "Uint16",
+	"uint32":  "Uint32",
+	"int32":   "Int32",
+	"float32": "Float32",
+	"bool":    "Bool",
+	"string":  "String",
+}
+
+func main() {
+	dir := flag.String("dir", ".", "包目录")
+	output := flag.String("output", "binary_gen.go", "输出文件名（相对于包目录）")
+	flag.Parse()
+
+	src, err := Generate(*dir, *output)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "binarygen: %v\n", err)
+		os.Exit(1)
+	}
+	if err := os.WriteFile(filepath.Join(*dir, *output), src, 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "binarygen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+// Generate 解析包目录并生成代码（跳过测试文件与输出文件本身）
+func Generate(dir, output string) ([]byte, error) {
+	fset := token.NewFileSet()
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		name := fi.Name()
+		return !strings.HasSuffix(name, "_test.go") && name != output
+	}, parser.ParseComments)
+	if err != nil {
+		return nil, err
+	}
+	if len(pkgs) != 1 {
+		return nil, fmt.Errorf("expected one package in %s, found %d", dir, len(pkgs))
+	}
+
+	var pkg *ast.Package
+	for _, p := range pkgs {
+		pkg = p
+	}
+	files := make([]string, 0, len(pkg.Files))
+	for name := range pkg.Files {
+		files = append(files, name)
+	}
+	sort.Strings(files)
+
+	g := &generator{}
+	fmt.Fprintf(&g.buf, "// Code generated by binarygen. DO NOT EDIT.\n\npackage %s\n", pkg.Name)
+	for _, name := range files {
+		for _, decl := range pkg.Files[name].Decls {
+			gen, ok := decl.(*ast.GenDecl)
+			if !ok || gen.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range gen.Specs {
+				ts := spec.(*ast.TypeSpec)
+				doc := ts.Doc
+				if doc == nil && len(gen.Specs) == 1 {
+					doc = gen.Doc
+				}
+				if !hasMarker(doc) {
+					continue
+				}
+				st, ok := ts.Type.(*ast.StructType)
+				if !ok || ts.TypeParams != nil {
+					return nil, fmt.Errorf("%s: %s is not a plain struct", fset.Position(ts.Pos()), ts.Name.Name)
+				}
+				if err := g.generate(ts.Name.Name, st); err != nil {
+					return nil, fmt.Errorf("%s: %s: %v", fset.Position(ts.Pos()), ts.Name.Name, err)
+				}
+			}
+		}
+	}
+	return format.Source(g.buf.Bytes())
+}
+
+func hasMarker(doc *ast.CommentGroup) bool {
+	if doc == nil {
+		return false
+	}
+	for _, c := range doc.List {
+		if strings.TrimSpace(c.Text) == marker {
+			return true
+		}
+	}
+	return false
+}
+
+// field 参与编码的字段
+type field struct {
+	name string
+	typ  ast.Expr
+}
+
+type generator struct {
+	buf  bytes.Buffer
+	body bytes.Buffer
+	tmp  int  // 临时变量编号
+	err  bool // 读取函数是否用到 err 变量
+}
+
+func (g *generator) generate(name string, st *ast.StructType) error {
+	var fields []field
+	for _, f := range st.Fields.List {
+		if f.Tag != nil {
+			tag, err := strconv.Unquote(f.Tag.Value)
+			if err != nil {
+				return err
+			}
+			if reflect.StructTag(tag).Get("binary") == "-" {
+				continue
+			}
+		}
+		if len(f.Names) == 0 {
+			return fmt.Errorf("embedded fields are not supported")
+		}
+		for _, n := range f.Names {
+			fields = append(fields, field{name: n.Name, typ: f.Type})
+		}
+	}
+
+	recv := receiverName(name)
+
+	g.body.Reset()
+	g.tmp, g.err = 0, false
+	for _, f := range fields {
+		if err := g.read(recv+"."+f.name, f.typ); err != nil {
+			return fmt.Errorf("field %s: %v", f.name, err)
+		}
+	}
+	fmt.Fprintf(&g.buf, "\nfunc (%s *%s) ReadBinary(r *BinaryReader) error {\n", recv, name)
+	if g.err {
+		g.buf.WriteString("var err error\n")
+	}
+	g.buf.Write(g.body.Bytes())
+	g.buf.WriteString("return nil\n}\n")
+
+	g.body.Reset()
+	g.tmp = 0
+	for _, f := range fields {
+		if err := g.write(recv+"."+f.name, f.typ); err != nil {
+			return fmt.Errorf("field %s: %v", f.name, err)
+		}
+	}
+	fmt.Fprintf(&g.buf, "\nfunc (%s *%s) WriteBinary(w *BinaryWriter) error {\n", recv, name)
+	g.buf.Write(g.body.Bytes())
+	g.buf.WriteString("return nil\n}\n")
+	return nil
+}
+
+// receiverName 取类型名中大写字母的小写作为接收者名称（QueueStatus -> qs），避开 r/w
+func receiverName(name string) string {
+	var b strings.Builder
+	for _, c := range name {
+		if c >= 'A' && c <= 'Z' {
+			b.WriteRune(c - 'A' + 'a')
+		}
+	}
+	recv := b.String()
+	if recv == "" {
+		recv = strings.ToLower(name[:1])
+	}
+	switch recv {
+	case "r", "w", "err":
+		recv += "v"
+	}
+	return recv
+}
+
+func (g *generator) next(prefix string) string {
+	g.tmp++
+	return fmt.Sprintf("%s%d", prefix, g.tmp)
+}
+
+// read 生成读取到 target 的代码，target 必须可寻址
+func (g *generator) read(target string, typ ast.Expr) error {
+	switch t := typ.(type) {
+	case *ast.Ident:
+		g.err = true
+		if fn, ok := basicTypes[t.Name]; ok {
+			fmt.Fprintf(&g.body, "if %s, err = Read%s(r); err != nil {\nreturn err\n}\n", target, fn)
+			return nil
+		}
+		fmt.Fprintf(&g.body, "if err = %s.ReadBinary(r); err != nil {\nreturn err\n}\n", target)
+		return nil
+	case *ast.StarExpr:
+		g.err = true
+		has := g.next("has")
+		fmt.Fprintf(&g.body, "var %s bool\nif %s, err = ReadBool(r); err != nil {\nreturn err\n}\nif %s {\n", has, has, has)
+		fmt.Fprintf(&g.body, "%s = new(%s)\n", target, exprString(t.X))
+		if err := g.read(deref(target, t.X), t.X); err != nil {
+			return err
+		}
+		g.body.WriteString("}\n")
+		return nil
+	case *ast.ArrayType:
+		if t.Len != nil {
+			return fmt.Errorf("arrays are not supported")
+		}
+		// 长度前缀由对端控制，按读取进度逐步扩容
+		g.err = true
+		n, i, e := g.next("n"), g.next("i"), g.next("e")
+		fmt.Fprintf(&g.body, "var %s int\nif %s, err = r.Len(0); err != nil {\nreturn err\n}\n", n, n)
+		fmt.Fprintf(&g.body, "%s = make(%s, 0, min(%s, listPrealloc))\nfor %s := 0; %s < %s; %s++ {\n", target, exprString(t), n, i, i, n, i)
+		fmt.Fprintf(&g.body, "var %s %s\n", e, exprString(t.Elt))
+		if err := g.read(e, t.Elt); err != nil {
+			return err
+		}
+		fmt.Fprintf(&g.body, "%s = append(%s, %s)\n}\n", target, target, e)
+		return nil
+	}
+	return fmt.Errorf("unsupported type %s", exprString(typ))
+}
+
+// write 生成写入 target 的代码
+func (g *generator) write(target string, typ ast.Expr) error {
+	switch t := typ.(type) {
+	case *ast.Ident:
+		if fn, ok := basicTypes[t.Name]; ok {
+			fmt.Fprintf(&g.body, "Write%s(w, %s)\n", fn, target)
+			return nil
+		}
+		fmt.Fprintf(&g.body, "if err := %s.WriteBinary(w); err != nil {\nreturn err\n}\n", target)
+		return nil
+	case *ast.StarExpr:
+		fmt.Fprintf(&g.body, "WriteBool(w, %s != nil)\nif %s != nil {\n", target, target)
+		if err := g.write(deref(target, t.X), t.X); err != nil {
+			return err
+		}
+		g.body.WriteString("}\n")
+		return nil
+	case *ast.ArrayType:
+		if t.Len != nil {
+			return fmt.Errorf("arrays are not supported")
+		}
+		i := g.next("i")
+		fmt.Fprintf(&g.body, "w.Uleb(uint64(len(%s)))\nfor %s := range %s {\n", target, i, target)
+		if err := g.write(target+"["+i+"]", t.Elt); err != nil {
+			return err
+		}
+		g.body.WriteString("}\n")
+		return nil
+	}
+	return fmt.Errorf("unsupported type %s", exprString(typ))
+}
+
+// deref 指针字段指向的值：具名类型的方法可直接通过指针调用，其余需要解引用
+func deref(target string, elem ast.Expr) string {
+	if id, ok := elem.(*ast.Ident); ok {
+		if _, basic := basicTypes[id.Name]; !basic {
+			return target
+		}
+		return "*" + target
+	}
+	return "(*" + target + ")"
+}
+
+func exprString(e ast.Expr) string {
+	var buf bytes.Buffer
+	format.Node(&buf, token.NewFileSet(), e)
+	return buf.String()
+}
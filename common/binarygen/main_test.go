@@ -0,0 +1,90 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGeneratedUpToDate 检查 common/binary_gen.go 与结构体定义一致
+func TestGeneratedUpToDate(t *testing.T) {
+	want, err := Generate("..", "binary_gen.go")
+	if err != nil {
+		t.Fatalf("生成失败: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join("..", "binary_gen.go"))
+	if err != nil {
+		t.Fatalf("读取生成文件失败: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Error("binary_gen.go 已过期，请在 common 目录下运行 go generate")
+	}
+}
+
+func TestGenerateFieldKinds(t *testing.T) {
+	dir := t.TempDir()
+	src := `package sample
+
+// Sample 示例
+//
+//binary:generate
+type Sample struct {
+	ID      int32
+	Tags    []string
+	Best    *float32
+	Owner   *UserInfo
+	Players []UserInfo
+	cache   int ` + "`binary:\"-\"`" + `
+}
+
+type Other struct {
+	Value int32
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "sample.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	out, err := Generate(dir, "binary_gen.go")
+	if err != nil {
+		t.Fatalf("生成失败: %v", err)
+	}
+	code := string(out)
+	for _, want := range []string{
+		"func (s *Sample) ReadBinary(r *BinaryReader) error",
+		"func (s *Sample) WriteBinary(w *BinaryWriter) error",
+		"s.Tags = make([]string, 0, min(n1, listPrealloc))",
+		"s.Tags = append(s.Tags, e3)",
+		"s.Best = new(float32)",
+		"if *s.Best, err = ReadFloat32(r); err != nil",
+		"if err = s.Owner.ReadBinary(r); err != nil",
+		"WriteBool(w, s.Owner != nil)",
+		"if err := s.Players[i",
+	} {
+		if !strings.Contains(code, want) {
+			t.Errorf("生成代码缺少 %q:\n%s", want, code)
+		}
+	}
+	if strings.Contains(code, "cache") || strings.Contains(code, "Other") {
+		t.Errorf("未标记的类型与忽略的字段不应生成代码:\n%s", code)
+	}
+}
+
+func TestGenerateUnsupported(t *testing.T) {
+	dir := t.TempDir()
+	src := `package sample
+
+//binary:generate
+type Bad struct {
+	Values map[string]int32
+}
+`
+	if err := os.WriteFile(filepath.Join(dir, "bad.go"), []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Generate(dir, "binary_gen.go"); err == nil {
+		t.Error("不支持的字段类型应返回错误")
+	}
+}
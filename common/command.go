@@ -3,6 +3,7 @@ package common
 import (
 	"fmt"
 	"math"
+	"strings"
 )
 
 // CompactPos 紧凑位置表示（使用float16）
@@ -49,49 +50,77 @@ func (p *CompactPos) WriteBinary(w *BinaryWriter) error {
 	return nil
 }
 
-// float32转float16 bits（简化实现）
+// float32转float16 bits（IEEE 754 binary16，就近舍入、偶数优先）
+// 超出范围的值变为无穷大，过小的值变为非规格化数或零，NaN 保留高位载荷
 func float32ToF16Bits(f float32) uint16 {
 	bits := math.Float32bits(f)
-	sign := uint16((bits >> 31) & 0x1)
-	exp := int16((bits>>23)&0xFF) - 127 + 15
-	frac := uint16((bits >> 13) & 0x3FF)
+	sign := uint16(bits>>16) & 0x8000
+	exp := int32(bits>>23) & 0xFF
+	mant := bits & 0x7FFFFF
 
-	if exp <= 0 {
-		return sign << 15
+	if exp == 0xFF {
+		if mant == 0 {
+			return sign | 0x7C00
+		}
+		// NaN：保证截断后的尾数不为零，避免变成无穷大
+		frac := uint16(mant >> 13)
+		if frac == 0 {
+			frac = 0x200
+		}
+		return sign | 0x7C00 | frac
+	}
+
+	e := exp - 127 + 15
+	if e >= 0x1F {
+		return sign | 0x7C00
+	}
+	if e <= 0 {
+		// 非规格化数：最小单位为 2^-24，小于其一半的值舍入为零
+		if e < -10 {
+			return sign
+		}
+		mant |= 0x800000
+		shift := uint32(14 - e)
+		half := mant >> shift
+		rem := mant & (1<<shift - 1)
+		halfway := uint32(1) << (shift - 1)
+		if rem > halfway || (rem == halfway && half&1 == 1) {
+			half++ // 进位到 0x400 时恰好为最小规格化数
+		}
+		return sign | uint16(half)
 	}
-	if exp >= 31 {
-		return (sign << 15) | 0x7C00
+
+	half := uint32(e)<<10 | mant>>13
+	rem := mant & 0x1FFF
+	if rem > 0x1000 || (rem == 0x1000 && half&1 == 1) {
+		half++ // 尾数进位到指数，最大值进位时恰好为无穷大
 	}
-	return (sign << 15) | (uint16(exp) << 10) | frac
+	return sign | uint16(half)
 }
 
-// float16 bits转float32
+// float16 bits转float32（精确转换）
 func f16BitsToFloat32(bits uint16) float32 {
-	sign := uint32((bits >> 15) & 0x1)
-	exp := int16((bits >> 10) & 0x1F)
+	sign := uint32(bits&0x8000) << 16
+	exp := uint32(bits>>10) & 0x1F
 	frac := uint32(bits & 0x3FF)
 
-	if exp == 0 {
+	switch exp {
+	case 0:
 		if frac == 0 {
-			return math.Float32frombits(sign << 31)
+			return math.Float32frombits(sign)
 		}
-		exp = -14
+		// 非规格化数：规格化后转为float32的规格化数
+		e := uint32(127 - 15 + 1)
 		for frac&0x400 == 0 {
 			frac <<= 1
-			exp--
+			e--
 		}
 		frac &= 0x3FF
-	} else if exp == 31 {
-		if frac == 0 {
-			return math.Float32frombits((sign << 31) | 0x7F800000)
-		}
-		return math.Float32frombits((sign << 31) | 0x7FC00000)
-	} else {
-		exp = exp - 15 + 127
+		return math.Float32frombits(sign | e<<23 | frac<<13)
+	case 0x1F:
+		return math.Float32frombits(sign | 0x7F800000 | frac<<13)
 	}
-
-	result := (sign << 31) | (uint32(exp) << 23) | (frac << 13)
-	return math.Float32frombits(result)
+	return math.Float32frombits(sign | (exp-15+127)<<23 | frac<<13)
 }
 
 // Varchar 变长字符串（带最大长度限制）
@@ -112,7 +141,7 @@ func (v *Varchar) ReadBinary(r *BinaryReader) error {
 	if err != nil {
 		return err
 	}
-	if int(length) > v.MaxLen {
+	if length > uint64(v.MaxLen) {
 		return fmt.Errorf("string too long")
 	}
 	data, err := r.Take(int(length))
@@ -165,13 +194,13 @@ func (r *RoomId) WriteBinary(w *BinaryWriter) error {
 
 // TouchFrame 触摸帧
 type TouchFrame struct {
-	Time   float32
-	Points []TouchPoint
+	Time   float32      `json:"time"`
+	Points []TouchPoint `json:"points"`
 }
 
 type TouchPoint struct {
-	ID  int8
-	Pos CompactPos
+	ID  int8       `json:"id"`
+	Pos CompactPos `json:"pos"`
 }
 
 func (t *TouchFrame) ReadBinary(r *BinaryReader) error {
@@ -181,12 +210,12 @@ func (t *TouchFrame) ReadBinary(r *BinaryReader) error {
 	}
 	t.Time = time
 
-	length, err := r.Uleb()
+	length, err := r.Len(GetDecodeLimits().TouchPoints)
 	if err != nil {
 		return err
 	}
-	t.Points = make([]TouchPoint, length)
-	for i := uint64(0); i < length; i++ {
+	t.Points = make([]TouchPoint, 0, min(length, listPrealloc))
+	for i := 0; i < length; i++ {
 		id, err := ReadInt8(r)
 		if err != nil {
 			return err
@@ -195,7 +224,7 @@ func (t *TouchFrame) ReadBinary(r *BinaryReader) error {
 		if err := pos.ReadBinary(r); err != nil {
 			return err
 		}
-		t.Points[i] = TouchPoint{ID: id, Pos: pos}
+		t.Points = append(t.Points, TouchPoint{ID: id, Pos: pos})
 	}
 	return nil
 }
@@ -210,7 +239,7 @@ func (t *TouchFrame) WriteBinary(w *BinaryWriter) error {
 	return nil
 }
 
-// Judgement 判定类型
+// Judgement 判定类型（V24起的扩展判定见 judgement.go）
 type Judgement uint8
 
 const (
@@ -238,10 +267,10 @@ func (j *Judgement) WriteBinary(w *BinaryWriter) error {
 
 // JudgeEvent 判定事件
 type JudgeEvent struct {
-	Time      float32
-	LineID    uint32
-	NoteID    uint32
-	Judgement Judgement
+	Time      float32   `json:"time"`
+	LineID    uint32    `json:"line_id"`
+	NoteID    uint32    `json:"note_id"`
+	Judgement Judgement `json:"judgement"`
 }
 
 func (j *JudgeEvent) ReadBinary(r *BinaryReader) error {
@@ -299,21 +328,65 @@ const (
 	ClientCmdCancelReady
 	ClientCmdPlayed
 	ClientCmdAbort
+	ClientCmdQueueJoin
+	ClientCmdOverflowRoom
+	ClientCmdLoadProgress
+	ClientCmdSwitchRole
+	ClientCmdGlobalChat
+	ClientCmdGlobalSubscribe
+	ClientCmdSetMaxMonitors
+	ClientCmdSubmitResult
+	ClientCmdReauthenticate
+	ClientCmdUpdateProfile
+	ClientCmdMonitorChat
+	ClientCmdFrameBatch    // 同一时段的触摸帧与判定事件合并为一条命令
+	ClientCmdValidateChart // 检查谱面能否被选择，不实际选择
+	ClientCmdSetRanking    // 房主设置房间的排名策略
+	ClientCmdScoreUpdate   // 对局中定期发送的实时成绩
+	ClientCmdAck           // 确认已收到带确认序号（ExtAckSeq）的关键命令
+	ClientCmdEmote         // 发送表情（文字聊天关闭时也可使用）
+	ClientCmdSetSchedule   // 房主设置房间的开放与关闭时间
+	ClientCmdListRooms     // 分页查询可加入的房间
+	ClientCmdShareStats    // 设置是否向房间成员展示资料卡（rks与游玩次数）
+	ClientCmdTransferHost  // 房主将房主移交给房间内的其他玩家
+	ClientCmdChatHistory   // 查询所在房间最近的房间消息
+	ClientCmdKick          // 房主将玩家或观察者移出房间
+	ClientCmdSetPassword   // 房主修改房间密码与是否为私密房间
+	ClientCmdSetTeam       // 房主为玩家分配队伍
 )
 
 // ClientCommand 客户端命令
 type ClientCommand struct {
-	Type     ClientCommandType
-	Token    string       // Authenticate
-	Message  string       // Chat
-	Frames   []TouchFrame // Touches
-	Judges   []JudgeEvent // Judges
-	RoomId   RoomId       // CreateRoom, JoinRoom
-	Monitor  bool         // JoinRoom
-	Lock     bool         // LockRoom
-	Cycle    bool         // CycleRoom
-	ChartID  int32        // SelectChart
-	RecordID int32        // Played
+	Type        ClientCommandType
+	Token       string       // Authenticate, Reauthenticate
+	Message     string       // Chat, GlobalChat, MonitorChat
+	Frames      []TouchFrame // Touches, FrameBatch
+	Judges      []JudgeEvent // Judges, FrameBatch
+	RoomId      RoomId       // CreateRoom, JoinRoom, QueueJoin
+	Monitor     bool         // JoinRoom, SwitchRole
+	Lock        bool         // LockRoom
+	Cycle       bool         // CycleRoom
+	Overflow    bool         // OverflowRoom
+	Progress    uint8        // LoadProgress（0-100）
+	Subscribe   bool         // GlobalSubscribe
+	MaxMonitors uint16       // SetMaxMonitors（0表示恢复服务器默认值）
+	ChartID     int64        // SelectChart, ValidateChart（V10前为int32）
+	RecordID    int64        // Played（V10前为int32）
+	Payload     string       // SubmitResult（原样转发给成绩服务的成绩数据）
+	Name        string       // UpdateProfile（显示名称，空字符串表示恢复账号名称）, SetRanking（排名策略名称）, Emote（表情ID）
+	Avatar      string       // UpdateProfile（头像提示，如头像URL或预设编号）
+	LiveScore   LiveScore    // ScoreUpdate
+	Seq         uint64       // Ack（已收到的最大确认序号，确认该序号及之前的所有关键命令）
+	Schedule    RoomSchedule // SetSchedule
+	Page        uint32       // ListRooms（页码，从0开始）
+	Ping        PingTimes    // Ping（V18起）
+	Share       bool         // ShareStats
+	UserID      int32        // TransferHost（新房主的用户ID）/Kick（被移出的用户ID）/SetTeam（分配队伍的玩家ID）
+	Password    string       // CreateRoom/JoinRoom（V21起，房间密码，空字符串表示没有密码）/SetPassword
+	Private     bool         // SetPassword（私密房间不出现在房间列表中，只能通过房间ID加入）
+	Team        uint8        // SetTeam（队伍编号 1～RoomMaxTeams，0表示不分队）
+	Count       uint16       // ChatHistory（最多返回的消息条数，0表示服务器保存的全部）
+	Extensions  Extensions   // 末尾的扩展字段（V6起）
 }
 
 func (c *ClientCommand) ReadBinary(r *BinaryReader) error {
@@ -325,7 +398,11 @@ func (c *ClientCommand) ReadBinary(r *BinaryReader) error {
 
 	switch c.Type {
 	case ClientCmdPing:
-		// 无数据
+		if !r.noPingTimes && r.Remaining() > 0 {
+			if err := c.Ping.ReadBinary(r); err != nil {
+				return err
+			}
+		}
 	case ClientCmdAuthenticate:
 		v := Varchar{MaxLen: 32}
 		if err := v.ReadBinary(r); err != nil {
@@ -339,31 +416,24 @@ func (c *ClientCommand) ReadBinary(r *BinaryReader) error {
 		}
 		c.Message = v.Value
 	case ClientCmdTouches:
-		length, err := r.Uleb()
+		frames, err := ReadList[TouchFrame](r, GetDecodeLimits().TouchFrames)
 		if err != nil {
 			return err
 		}
-		c.Frames = make([]TouchFrame, length)
-		for i := uint64(0); i < length; i++ {
-			if err := c.Frames[i].ReadBinary(r); err != nil {
-				return err
-			}
-		}
+		c.Frames = frames
 	case ClientCmdJudges:
-		length, err := r.Uleb()
+		judges, err := ReadList[JudgeEvent](r, GetDecodeLimits().JudgeEvents)
 		if err != nil {
 			return err
 		}
-		c.Judges = make([]JudgeEvent, length)
-		for i := uint64(0); i < length; i++ {
-			if err := c.Judges[i].ReadBinary(r); err != nil {
-				return err
-			}
-		}
+		c.Judges = judges
 	case ClientCmdCreateRoom:
 		if err := c.RoomId.ReadBinary(r); err != nil {
 			return err
 		}
+		if err := c.readPassword(r); err != nil {
+			return err
+		}
 	case ClientCmdJoinRoom:
 		if err := c.RoomId.ReadBinary(r); err != nil {
 			return err
@@ -373,6 +443,9 @@ func (c *ClientCommand) ReadBinary(r *BinaryReader) error {
 			return err
 		}
 		c.Monitor = monitor
+		if err := c.readPassword(r); err != nil {
+			return err
+		}
 	case ClientCmdLeaveRoom:
 		// 无数据
 	case ClientCmdLockRoom:
@@ -387,8 +460,8 @@ func (c *ClientCommand) ReadBinary(r *BinaryReader) error {
 			return err
 		}
 		c.Cycle = cycle
-	case ClientCmdSelectChart:
-		id, err := ReadInt32(r)
+	case ClientCmdSelectChart, ClientCmdValidateChart:
+		id, err := ReadID(r)
 		if err != nil {
 			return err
 		}
@@ -400,17 +473,170 @@ func (c *ClientCommand) ReadBinary(r *BinaryReader) error {
 	case ClientCmdCancelReady:
 		// 无数据
 	case ClientCmdPlayed:
-		id, err := ReadInt32(r)
+		id, err := ReadID(r)
 		if err != nil {
 			return err
 		}
 		c.RecordID = id
 	case ClientCmdAbort:
 		// 无数据
+	case ClientCmdQueueJoin:
+		if err := c.RoomId.ReadBinary(r); err != nil {
+			return err
+		}
+	case ClientCmdOverflowRoom:
+		overflow, err := ReadBool(r)
+		if err != nil {
+			return err
+		}
+		c.Overflow = overflow
+	case ClientCmdLoadProgress:
+		progress, err := ReadUint8(r)
+		if err != nil {
+			return err
+		}
+		c.Progress = progress
+	case ClientCmdSwitchRole:
+		monitor, err := ReadBool(r)
+		if err != nil {
+			return err
+		}
+		c.Monitor = monitor
+	case ClientCmdGlobalChat:
+		v := Varchar{MaxLen: 200}
+		if err := v.ReadBinary(r); err != nil {
+			return err
+		}
+		c.Message = v.Value
+	case ClientCmdGlobalSubscribe:
+		subscribe, err := ReadBool(r)
+		if err != nil {
+			return err
+		}
+		c.Subscribe = subscribe
+	case ClientCmdSetMaxMonitors:
+		maxMonitors, err := ReadUint16(r)
+		if err != nil {
+			return err
+		}
+		c.MaxMonitors = maxMonitors
+	case ClientCmdSubmitResult:
+		payload, err := ReadString(r)
+		if err != nil {
+			return err
+		}
+		c.Payload = payload
+	case ClientCmdReauthenticate:
+		v := Varchar{MaxLen: 32}
+		if err := v.ReadBinary(r); err != nil {
+			return err
+		}
+		c.Token = v.Value
+	case ClientCmdUpdateProfile:
+		name := Varchar{MaxLen: ProfileNameMaxLen}
+		if err := name.ReadBinary(r); err != nil {
+			return err
+		}
+		c.Name = name.Value
+		avatar := Varchar{MaxLen: ProfileAvatarMaxLen}
+		if err := avatar.ReadBinary(r); err != nil {
+			return err
+		}
+		c.Avatar = avatar.Value
+	case ClientCmdMonitorChat:
+		v := Varchar{MaxLen: 200}
+		if err := v.ReadBinary(r); err != nil {
+			return err
+		}
+		c.Message = v.Value
+	case ClientCmdSetRanking:
+		v := Varchar{MaxLen: RankingNameMaxLen}
+		if err := v.ReadBinary(r); err != nil {
+			return err
+		}
+		c.Name = v.Value
+	case ClientCmdScoreUpdate:
+		if err := c.LiveScore.ReadBinary(r); err != nil {
+			return err
+		}
+	case ClientCmdAck:
+		seq, err := r.Uleb()
+		if err != nil {
+			return err
+		}
+		c.Seq = seq
+	case ClientCmdEmote:
+		v := Varchar{MaxLen: EmoteMaxLen}
+		if err := v.ReadBinary(r); err != nil {
+			return err
+		}
+		c.Name = v.Value
+	case ClientCmdSetSchedule:
+		if err := c.Schedule.ReadBinary(r); err != nil {
+			return err
+		}
+	case ClientCmdListRooms:
+		page, err := ReadUint32(r)
+		if err != nil {
+			return err
+		}
+		c.Page = page
+	case ClientCmdShareStats:
+		share, err := ReadBool(r)
+		if err != nil {
+			return err
+		}
+		c.Share = share
+	case ClientCmdTransferHost, ClientCmdKick:
+		userID, err := ReadInt32(r)
+		if err != nil {
+			return err
+		}
+		c.UserID = userID
+	case ClientCmdSetPassword:
+		v := Varchar{MaxLen: RoomPasswordMaxLen}
+		if err := v.ReadBinary(r); err != nil {
+			return err
+		}
+		c.Password = v.Value
+		private, err := ReadBool(r)
+		if err != nil {
+			return err
+		}
+		c.Private = private
+	case ClientCmdSetTeam:
+		userID, err := ReadInt32(r)
+		if err != nil {
+			return err
+		}
+		team, err := ReadUint8(r)
+		if err != nil {
+			return err
+		}
+		c.UserID, c.Team = userID, team
+	case ClientCmdChatHistory:
+		count, err := ReadUint16(r)
+		if err != nil {
+			return err
+		}
+		c.Count = count
+	case ClientCmdFrameBatch:
+		limits := GetDecodeLimits()
+		frames, err := ReadList[TouchFrame](r, limits.TouchFrames)
+		if err != nil {
+			return err
+		}
+		c.Frames = frames
+		judges, err := ReadList[JudgeEvent](r, limits.JudgeEvents)
+		if err != nil {
+			return err
+		}
+		c.Judges = judges
 	default:
 		return fmt.Errorf("unknown client command type: %d", c.Type)
 	}
-	return nil
+	c.Extensions, err = readExtensions(r)
+	return err
 }
 
 func (c *ClientCommand) WriteBinary(w *BinaryWriter) error {
@@ -418,7 +644,9 @@ func (c *ClientCommand) WriteBinary(w *BinaryWriter) error {
 
 	switch c.Type {
 	case ClientCmdPing:
-		// 无数据
+		if !w.noPingTimes {
+			c.Ping.WriteBinary(w)
+		}
 	case ClientCmdAuthenticate:
 		v := Varchar{MaxLen: 32, Value: c.Token}
 		v.WriteBinary(w)
@@ -431,23 +659,24 @@ func (c *ClientCommand) WriteBinary(w *BinaryWriter) error {
 			f.WriteBinary(w)
 		}
 	case ClientCmdJudges:
-		w.Uleb(uint64(len(c.Judges)))
-		for _, j := range c.Judges {
-			j.WriteBinary(w)
-		}
+		writeJudges(w, c.Judges)
 	case ClientCmdCreateRoom:
 		c.RoomId.WriteBinary(w)
+		c.writePassword(w)
 	case ClientCmdJoinRoom:
 		c.RoomId.WriteBinary(w)
 		WriteBool(w, c.Monitor)
+		c.writePassword(w)
 	case ClientCmdLeaveRoom:
 		// 无数据
 	case ClientCmdLockRoom:
 		WriteBool(w, c.Lock)
 	case ClientCmdCycleRoom:
 		WriteBool(w, c.Cycle)
-	case ClientCmdSelectChart:
-		WriteInt32(w, c.ChartID)
+	case ClientCmdSelectChart, ClientCmdValidateChart:
+		if err := WriteID(w, c.ChartID); err != nil {
+			return err
+		}
 	case ClientCmdRequestStart:
 		// 无数据
 	case ClientCmdReady:
@@ -455,13 +684,97 @@ func (c *ClientCommand) WriteBinary(w *BinaryWriter) error {
 	case ClientCmdCancelReady:
 		// 无数据
 	case ClientCmdPlayed:
-		WriteInt32(w, c.RecordID)
+		if err := WriteID(w, c.RecordID); err != nil {
+			return err
+		}
 	case ClientCmdAbort:
 		// 无数据
+	case ClientCmdQueueJoin:
+		c.RoomId.WriteBinary(w)
+	case ClientCmdOverflowRoom:
+		WriteBool(w, c.Overflow)
+	case ClientCmdLoadProgress:
+		WriteUint8(w, c.Progress)
+	case ClientCmdSwitchRole:
+		WriteBool(w, c.Monitor)
+	case ClientCmdGlobalChat:
+		v := Varchar{MaxLen: 200, Value: c.Message}
+		v.WriteBinary(w)
+	case ClientCmdGlobalSubscribe:
+		WriteBool(w, c.Subscribe)
+	case ClientCmdSetMaxMonitors:
+		WriteUint16(w, c.MaxMonitors)
+	case ClientCmdSubmitResult:
+		WriteString(w, c.Payload)
+	case ClientCmdReauthenticate:
+		v := Varchar{MaxLen: 32, Value: c.Token}
+		v.WriteBinary(w)
+	case ClientCmdUpdateProfile:
+		name := Varchar{MaxLen: ProfileNameMaxLen, Value: c.Name}
+		name.WriteBinary(w)
+		avatar := Varchar{MaxLen: ProfileAvatarMaxLen, Value: c.Avatar}
+		avatar.WriteBinary(w)
+	case ClientCmdMonitorChat:
+		v := Varchar{MaxLen: 200, Value: c.Message}
+		v.WriteBinary(w)
+	case ClientCmdSetRanking:
+		v := Varchar{MaxLen: RankingNameMaxLen, Value: c.Name}
+		v.WriteBinary(w)
+	case ClientCmdScoreUpdate:
+		c.LiveScore.WriteBinary(w)
+	case ClientCmdAck:
+		w.Uleb(c.Seq)
+	case ClientCmdEmote:
+		v := Varchar{MaxLen: EmoteMaxLen, Value: c.Name}
+		v.WriteBinary(w)
+	case ClientCmdSetSchedule:
+		c.Schedule.WriteBinary(w)
+	case ClientCmdListRooms:
+		WriteUint32(w, c.Page)
+	case ClientCmdShareStats:
+		WriteBool(w, c.Share)
+	case ClientCmdTransferHost, ClientCmdKick:
+		WriteInt32(w, c.UserID)
+	case ClientCmdChatHistory:
+		WriteUint16(w, c.Count)
+	case ClientCmdSetPassword:
+		v := Varchar{MaxLen: RoomPasswordMaxLen, Value: c.Password}
+		v.WriteBinary(w)
+		WriteBool(w, c.Private)
+	case ClientCmdSetTeam:
+		WriteInt32(w, c.UserID)
+		WriteUint8(w, c.Team)
+	case ClientCmdFrameBatch:
+		w.Uleb(uint64(len(c.Frames)))
+		for _, f := range c.Frames {
+			f.WriteBinary(w)
+		}
+		writeJudges(w, c.Judges)
+	}
+	return writeExtensions(w, c.Extensions)
+}
+
+// readPassword 读取 CreateRoom/JoinRoom 末尾的房间密码（V21起，旧版客户端省略时为空）
+func (c *ClientCommand) readPassword(r *BinaryReader) error {
+	if r.noPasswords || r.Remaining() == 0 {
+		return nil
+	}
+	v := Varchar{MaxLen: RoomPasswordMaxLen}
+	if err := v.ReadBinary(r); err != nil {
+		return err
 	}
+	c.Password = v.Value
 	return nil
 }
 
+// writePassword 写入房间密码（V21起）
+func (c *ClientCommand) writePassword(w *BinaryWriter) {
+	if !w.noPasswords {
+		v := Varchar{MaxLen: RoomPasswordMaxLen, Value: c.Password}
+		v.WriteBinary(w)
+	}
+}
+
 // MessageType 消息类型
 type MessageType uint8
 
@@ -482,20 +795,37 @@ const (
 	MsgAbort
 	MsgLockRoom
 	MsgCycleRoom
+	MsgLiveRoom    // 房间直播状态变化（最后一个观察者离开时关闭）
+	MsgMonitorChat // 观察者聊天（仅投递给房间内的观察者）
+	MsgEmote       // 玩家发送的表情（Content 为表情ID）
+	MsgTeamChange  // 房主调整了玩家所在的队伍（Team 为0表示不再分队）
 )
 
 // Message 房间消息
 type Message struct {
-	Type      MessageType
-	User      int32
-	Content   string
-	Name      string
-	ChartID   int32
-	Score     int32
-	Accuracy  float32
-	FullCombo bool
-	Lock      bool
-	Cycle     bool
+	Type      MessageType `json:"type"`
+	User      int32       `json:"user,omitempty"`
+	Content   string      `json:"content,omitempty"`
+	Name      string      `json:"name,omitempty"`
+	ChartID   int64       `json:"chart_id,omitempty"`
+	Score     int32       `json:"score,omitempty"`
+	Accuracy  float32     `json:"accuracy,omitempty"`
+	FullCombo bool        `json:"full_combo,omitempty"`
+	Lock      bool        `json:"lock,omitempty"`
+	Cycle     bool        `json:"cycle,omitempty"`
+	Live      bool        `json:"live,omitempty"`
+	Team      uint8       `json:"team,omitempty"`
+
+	// Played 判定统计（追加字段，旧版数据中不存在）
+	Perfect  int32 `json:"perfect,omitempty"`
+	Good     int32 `json:"good,omitempty"`
+	Bad      int32 `json:"bad,omitempty"`
+	Miss     int32 `json:"miss,omitempty"`
+	MaxCombo int32 `json:"max_combo,omitempty"`
+
+	// Time 服务器发送消息的时间（Unix毫秒），V13起以ULEB128追加在消息字段之后，旧版本服务器的消息为0
+	// 客户端重连后按该时间排列聊天、加入、离开等事件，不受网络延迟与本地时钟影响
+	Time int64 `json:"time,omitempty"`
 }
 
 func (m *Message) ReadBinary(r *BinaryReader) error {
@@ -504,50 +834,83 @@ func (m *Message) ReadBinary(r *BinaryReader) error {
 		return err
 	}
 	m.Type = MessageType(msgType)
+	if err := m.readFields(r); err != nil {
+		return r.tolerate(err)
+	}
+	// 时间戳为追加字段，旧版数据中不存在
+	if r.noTimestamps || r.Remaining() == 0 {
+		return nil
+	}
+	t, err := r.Uleb()
+	m.Time = int64(t)
+	return r.tolerate(err)
+}
 
+// readFields 按消息类型读取字段
+func (m *Message) readFields(r *BinaryReader) (err error) {
 	switch m.Type {
-	case MsgChat:
-		m.User, _ = ReadInt32(r)
-		m.Content, _ = ReadString(r)
-	case MsgCreateRoom:
-		m.User, _ = ReadInt32(r)
-	case MsgJoinRoom:
-		m.User, _ = ReadInt32(r)
-		m.Name, _ = ReadString(r)
-	case MsgLeaveRoom:
-		m.User, _ = ReadInt32(r)
-		m.Name, _ = ReadString(r)
-	case MsgNewHost:
-		m.User, _ = ReadInt32(r)
+	case MsgChat, MsgMonitorChat, MsgEmote:
+		if m.User, err = ReadInt32(r); err != nil {
+			return err
+		}
+		m.Content, err = ReadString(r)
+	case MsgCreateRoom, MsgNewHost, MsgGameStart, MsgReady, MsgCancelReady, MsgCancelGame, MsgAbort:
+		m.User, err = ReadInt32(r)
+	case MsgJoinRoom, MsgLeaveRoom:
+		if m.User, err = ReadInt32(r); err != nil {
+			return err
+		}
+		m.Name, err = ReadString(r)
 	case MsgSelectChart:
-		m.User, _ = ReadInt32(r)
-		m.Name, _ = ReadString(r)
-		m.ChartID, _ = ReadInt32(r)
-	case MsgGameStart:
-		m.User, _ = ReadInt32(r)
-	case MsgReady:
-		m.User, _ = ReadInt32(r)
-	case MsgCancelReady:
-		m.User, _ = ReadInt32(r)
-	case MsgCancelGame:
-		m.User, _ = ReadInt32(r)
-	case MsgStartPlaying:
+		if m.User, err = ReadInt32(r); err != nil {
+			return err
+		}
+		if m.Name, err = ReadString(r); err != nil {
+			return err
+		}
+		m.ChartID, err = ReadID(r)
+	case MsgStartPlaying, MsgGameEnd:
 		// 无数据
 	case MsgPlayed:
-		m.User, _ = ReadInt32(r)
-		m.Score, _ = ReadInt32(r)
-		m.Accuracy, _ = ReadFloat32(r)
-		m.FullCombo, _ = ReadBool(r)
-	case MsgGameEnd:
-		// 无数据
-	case MsgAbort:
-		m.User, _ = ReadInt32(r)
+		if m.User, err = ReadInt32(r); err != nil {
+			return err
+		}
+		if m.Score, err = ReadInt32(r); err != nil {
+			return err
+		}
+		if m.Accuracy, err = ReadFloat32(r); err != nil {
+			return err
+		}
+		if m.FullCombo, err = ReadBool(r); err != nil {
+			return err
+		}
+		// 判定统计为追加字段，原版协议不写入
+		if r.Remaining() == 0 {
+			return nil
+		}
+		for _, field := range []*int32{&m.Perfect, &m.Good, &m.Bad, &m.Miss, &m.MaxCombo} {
+			if *field, err = ReadInt32(r); err != nil {
+				return err
+			}
+		}
 	case MsgLockRoom:
-		m.Lock, _ = ReadBool(r)
+		m.Lock, err = ReadBool(r)
 	case MsgCycleRoom:
-		m.Cycle, _ = ReadBool(r)
+		m.Cycle, err = ReadBool(r)
+	case MsgLiveRoom:
+		m.Live, err = ReadBool(r)
+	case MsgTeamChange:
+		if m.User, err = ReadInt32(r); err != nil {
+			return err
+		}
+		m.Team, err = ReadUint8(r)
+	default:
+		if r.strict {
+			return fmt.Errorf("unknown message type: %d", m.Type)
+		}
+		r.skipRest()
 	}
-	return nil
+	return err
 }
 
 func (m *Message) WriteBinary(w *BinaryWriter) error {
@@ -570,7 +933,9 @@ func (m *Message) WriteBinary(w *BinaryWriter) error {
 	case MsgSelectChart:
 		WriteInt32(w, m.User)
 		WriteString(w, m.Name)
-		WriteInt32(w, m.ChartID)
+		if err := WriteID(w, m.ChartID); err != nil {
+			return err
+		}
 	case MsgGameStart:
 		WriteInt32(w, m.User)
 	case MsgReady:
@@ -586,6 +951,13 @@ func (m *Message) WriteBinary(w *BinaryWriter) error {
 		WriteInt32(w, m.Score)
 		WriteFloat32(w, m.Accuracy)
 		WriteBool(w, m.FullCombo)
+		if w.Extended() {
+			WriteInt32(w, m.Perfect)
+			WriteInt32(w, m.Good)
+			WriteInt32(w, m.Bad)
+			WriteInt32(w, m.Miss)
+			WriteInt32(w, m.MaxCombo)
+		}
 	case MsgGameEnd:
 		// 无数据
 	case MsgAbort:
@@ -594,6 +966,17 @@ func (m *Message) WriteBinary(w *BinaryWriter) error {
 		WriteBool(w, m.Lock)
 	case MsgCycleRoom:
 		WriteBool(w, m.Cycle)
+	case MsgLiveRoom:
+		WriteBool(w, m.Live)
+	case MsgMonitorChat, MsgEmote:
+		WriteInt32(w, m.User)
+		WriteString(w, m.Content)
+	case MsgTeamChange:
+		WriteInt32(w, m.User)
+		WriteUint8(w, m.Team)
+	}
+	if !w.noTimestamps {
+		w.Uleb(uint64(max(m.Time, 0)))
 	}
 	return nil
 }
@@ -609,8 +992,8 @@ const (
 
 // RoomState 房间状态
 type RoomState struct {
-	Type    RoomStateType
-	ChartID *int32 // SelectChart时有效
+	Type    RoomStateType `json:"type"`
+	ChartID *int64        `json:"chart_id,omitempty"` // SelectChart时有效
 }
 
 func (rs *RoomState) ReadBinary(r *BinaryReader) error {
@@ -621,11 +1004,16 @@ func (rs *RoomState) ReadBinary(r *BinaryReader) error {
 	rs.Type = RoomStateType(stateType)
 
 	if rs.Type == RoomStateSelectChart {
-		hasChart, _ := ReadBool(r)
+		hasChart, err := ReadBool(r)
+		if err != nil {
+			return err
+		}
 		if hasChart {
-			id, _ := ReadInt32(r)
-			rs.ChartID = new(int32)
-			*rs.ChartID = id
+			id, err := ReadID(r)
+			if err != nil {
+				return err
+			}
+			rs.ChartID = &id
 		}
 	}
 	return nil
@@ -637,7 +1025,7 @@ func (rs *RoomState) WriteBinary(w *BinaryWriter) error {
 	if rs.Type == RoomStateSelectChart {
 		if rs.ChartID != nil {
 			WriteBool(w, true)
-			WriteInt32(w, *rs.ChartID)
+			return WriteID(w, *rs.ChartID)
 		} else {
 			WriteBool(w, false)
 		}
@@ -647,9 +1035,10 @@ func (rs *RoomState) WriteBinary(w *BinaryWriter) error {
 
 // UserInfo 用户信息
 type UserInfo struct {
-	ID      int32
-	Name    string
-	Monitor bool
+	ID      int32        `json:"id"`
+	Name    string       `json:"name"`
+	Monitor bool         `json:"monitor"`
+	Card    *ProfileCard `json:"card,omitempty"` // 资料卡（V19起，玩家开启 ShareStats 时才有）
 }
 
 func (u *UserInfo) ReadBinary(r *BinaryReader) error {
@@ -670,26 +1059,121 @@ func (u *UserInfo) ReadBinary(r *BinaryReader) error {
 		return err
 	}
 	u.Monitor = monitor
-	return nil
+
+	if r.noProfileCards {
+		return nil
+	}
+	hasCard, err := ReadBool(r)
+	if err != nil || !hasCard {
+		return err
+	}
+	u.Card = &ProfileCard{}
+	return u.Card.ReadBinary(r)
 }
 
 func (u *UserInfo) WriteBinary(w *BinaryWriter) error {
 	WriteInt32(w, u.ID)
 	WriteString(w, u.Name)
 	WriteBool(w, u.Monitor)
+	if !w.noProfileCards {
+		WriteBool(w, u.Card != nil)
+		if u.Card != nil {
+			u.Card.WriteBinary(w)
+		}
+	}
 	return nil
 }
 
+// ProfileCard 玩家资料卡，玩家通过 ShareStats 开启后随 UserInfo 发送给房间成员
+//
+//binary:generate
+type ProfileCard struct {
+	Rks       float32 `json:"rks"`        // 主站rks
+	PlayCount uint32  `json:"play_count"` // 在本服务器完成的对局数
+}
+
 // ClientRoomState 客户端房间状态
 type ClientRoomState struct {
-	ID      RoomId
-	State   RoomState
-	Live    bool
-	Locked  bool
-	Cycle   bool
-	IsHost  bool
-	IsReady bool
-	Users   map[int32]UserInfo
+	ID         RoomId             `json:"id"`
+	State      RoomState          `json:"state"`
+	Live       bool               `json:"live"`
+	Locked     bool               `json:"locked"`
+	Cycle      bool               `json:"cycle"`
+	IsHost     bool               `json:"is_host"`
+	IsReady    bool               `json:"is_ready"`
+	Users      map[int32]UserInfo `json:"users"`
+	ReadyUsers []int32            `json:"ready_users,omitempty"` // 已准备的玩家（追加字段，旧版数据中不存在）
+	Teams      map[int32]uint8    `json:"teams,omitempty"`       // 已分队玩家所在的队伍（V26起追加）
+}
+
+// readReadyUsers 读取追加在末尾的已准备玩家列表，旧版数据中不存在时返回nil
+func readReadyUsers(r *BinaryReader) ([]int32, error) {
+	if r.Remaining() == 0 {
+		return nil, nil
+	}
+	length, err := r.Len(GetDecodeLimits().Users)
+	if err != nil {
+		return nil, err
+	}
+	users := make([]int32, 0, min(length, listPrealloc))
+	for i := 0; i < length; i++ {
+		id, err := ReadInt32(r)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, id)
+	}
+	return users, nil
+}
+
+// writeReadyUsers 写入已准备玩家列表（原版协议不写入）
+func writeReadyUsers(w *BinaryWriter, users []int32) {
+	if !w.Extended() {
+		return
+	}
+	w.Uleb(uint64(len(users)))
+	for _, id := range users {
+		WriteInt32(w, id)
+	}
+}
+
+// readTeams 读取追加在已准备玩家列表之后的队伍分配（V26起），旧版数据中不存在时返回nil
+func readTeams(r *BinaryReader) (map[int32]uint8, error) {
+	if r.noTeams || r.Remaining() == 0 {
+		return nil, nil
+	}
+	length, err := r.Len(GetDecodeLimits().Users)
+	if err != nil {
+		return nil, err
+	}
+	if length == 0 {
+		return nil, nil
+	}
+	teams := make(map[int32]uint8, min(length, listPrealloc))
+	for i := 0; i < length; i++ {
+		id, err := ReadInt32(r)
+		if err != nil {
+			return nil, err
+		}
+		team, err := ReadUint8(r)
+		if err != nil {
+			return nil, err
+		}
+		teams[id] = team
+	}
+	return teams, nil
+}
+
+// writeTeams 写入队伍分配（V26前不写入）
+func writeTeams(w *BinaryWriter, teams map[int32]uint8) {
+	if w.noTeams {
+		return
+	}
+	w.Uleb(uint64(len(teams)))
+	for id, team := range teams {
+		WriteInt32(w, id)
+		WriteUint8(w, team)
+	}
 }
 
 func (crs *ClientRoomState) ReadBinary(r *BinaryReader) error {
@@ -700,27 +1184,44 @@ func (crs *ClientRoomState) ReadBinary(r *BinaryReader) error {
 		return err
 	}
 
-	crs.Live, _ = ReadBool(r)
-	crs.Locked, _ = ReadBool(r)
-	crs.Cycle, _ = ReadBool(r)
-	crs.IsHost, _ = ReadBool(r)
-	crs.IsReady, _ = ReadBool(r)
+	for _, field := range []*bool{&crs.Live, &crs.Locked, &crs.Cycle, &crs.IsHost, &crs.IsReady} {
+		v, err := ReadBool(r)
+		if err != nil {
+			return err
+		}
+		*field = v
+	}
 
 	// 读取用户map
-	length, _ := r.Uleb()
+	length, err := r.Len(GetDecodeLimits().Users)
+	if err != nil {
+		return err
+	}
 	crs.Users = make(map[int32]UserInfo)
-	for i := uint64(0); i < length; i++ {
-		key, _ := ReadInt32(r)
+	for i := 0; i < length; i++ {
+		key, err := ReadInt32(r)
+		if err != nil {
+			return err
+		}
 		var user UserInfo
-		user.ReadBinary(r)
+		if err := user.ReadBinary(r); err != nil {
+			return err
+		}
 		crs.Users[key] = user
 	}
-	return nil
+
+	if crs.ReadyUsers, err = readReadyUsers(r); err != nil {
+		return err
+	}
+	crs.Teams, err = readTeams(r)
+	return err
 }
 
 func (crs *ClientRoomState) WriteBinary(w *BinaryWriter) error {
 	crs.ID.WriteBinary(w)
-	crs.State.WriteBinary(w)
+	if err := crs.State.WriteBinary(w); err != nil {
+		return err
+	}
 	WriteBool(w, crs.Live)
 	WriteBool(w, crs.Locked)
 	WriteBool(w, crs.Cycle)
@@ -732,38 +1233,53 @@ func (crs *ClientRoomState) WriteBinary(w *BinaryWriter) error {
 		WriteInt32(w, k)
 		v.WriteBinary(w)
 	}
+	writeReadyUsers(w, crs.ReadyUsers)
+	writeTeams(w, crs.Teams)
 	return nil
 }
 
 // JoinRoomResponse 加入房间响应
 type JoinRoomResponse struct {
-	State RoomState
-	Users []UserInfo
-	Live  bool
+	State      RoomState  `json:"state"`
+	Users      []UserInfo `json:"users"`
+	Live       bool       `json:"live"`
+	ReadyUsers []int32    `json:"ready_users,omitempty"` // 已准备的玩家（追加字段，旧版数据中不存在）
+
+	// Teams 已分队玩家所在的队伍（V26起追加）
+	Teams map[int32]uint8 `json:"teams,omitempty"`
 }
 
 func (jrr *JoinRoomResponse) ReadBinary(r *BinaryReader) error {
-	if err := jrr.State.ReadBinary(r); err != nil {
+	err := jrr.State.ReadBinary(r)
+	if err != nil {
 		return err
 	}
 
-	length, _ := r.Uleb()
-	jrr.Users = make([]UserInfo, length)
-	for i := uint64(0); i < length; i++ {
-		jrr.Users[i].ReadBinary(r)
+	if jrr.Users, err = ReadList[UserInfo](r, GetDecodeLimits().Users); err != nil {
+		return err
 	}
 
-	jrr.Live, _ = ReadBool(r)
-	return nil
+	if jrr.Live, err = ReadBool(r); err != nil {
+		return err
+	}
+	if jrr.ReadyUsers, err = readReadyUsers(r); err != nil {
+		return err
+	}
+	jrr.Teams, err = readTeams(r)
+	return err
 }
 
 func (jrr *JoinRoomResponse) WriteBinary(w *BinaryWriter) error {
-	jrr.State.WriteBinary(w)
+	if err := jrr.State.WriteBinary(w); err != nil {
+		return err
+	}
 	w.Uleb(uint64(len(jrr.Users)))
 	for _, u := range jrr.Users {
 		u.WriteBinary(w)
 	}
 	WriteBool(w, jrr.Live)
+	writeReadyUsers(w, jrr.ReadyUsers)
+	writeTeams(w, jrr.Teams)
 	return nil
 }
 
@@ -791,48 +1307,112 @@ const (
 	ServerCmdCancelReady
 	ServerCmdPlayed
 	ServerCmdAbort
+	ServerCmdQueueJoin
+	ServerCmdQueueUpdate
+	ServerCmdOverflowRoom
+	ServerCmdLoadProgress
+	ServerCmdSwitchRole
+	ServerCmdGlobalChat
+	ServerCmdGlobalSubscribe
+	ServerCmdSetMaxMonitors
+	ServerCmdSubmitResult
+	ServerCmdReauthRequired
+	ServerCmdReauthenticate
+	ServerCmdUpdateProfile
+	ServerCmdProfileUpdated
+	ServerCmdMonitorChat
+	ServerCmdRoomClosed
+	ServerCmdValidateChart
+	ServerCmdSetRanking
+	ServerCmdScoreUpdate // 玩家的实时成绩（转发给观察者）
+	ServerCmdEmote
+	ServerCmdSetSchedule
+	ServerCmdListRooms
+	ServerCmdShareStats
+	ServerCmdTransferHost
+	ServerCmdChatHistory
+	ServerCmdKick
+	ServerCmdKicked // 被移出房间或断开连接的通知
+	ServerCmdSetPassword
+	ServerCmdSetTeam
+	ServerCmdDisconnect // 服务器断开连接前的通知
 )
 
 // ServerCommand 服务器命令
 type ServerCommand struct {
-	Type               ServerCommandType
-	TouchesPlayer      int32
-	TouchesFrames      []TouchFrame
-	JudgesPlayer       int32
-	JudgesEvents       []JudgeEvent
-	Message            *Message
-	ChangeState        *RoomState
-	ChangeHost         bool
-	OnJoinRoomUser     *UserInfo
-	AuthenticateResult *Result[AuthResult]
-	ChatResult         *Result[struct{}]
-	CreateRoomResult   *Result[struct{}]
-	JoinRoomResult     *Result[JoinRoomResponse]
-	LeaveRoomResult    *Result[struct{}]
-	LockRoomResult     *Result[struct{}]
-	CycleRoomResult    *Result[struct{}]
-	SelectChartResult  *Result[struct{}]
-	RequestStartResult *Result[struct{}]
-	ReadyResult        *Result[struct{}]
-	CancelReadyResult  *Result[struct{}]
-	PlayedResult       *Result[struct{}]
-	AbortResult        *Result[struct{}]
+	Type                  ServerCommandType
+	TouchesPlayer         int32
+	TouchesFrames         []TouchFrame
+	JudgesPlayer          int32
+	JudgesEvents          []JudgeEvent
+	Message               *Message
+	ChangeState           *RoomState
+	ChangeHost            bool
+	OnJoinRoomUser        *UserInfo
+	AuthenticateResult    *Result[AuthResult]
+	ChatResult            *Result[struct{}]
+	CreateRoomResult      *Result[struct{}]
+	JoinRoomResult        *Result[JoinRoomResponse]
+	LeaveRoomResult       *Result[struct{}]
+	LockRoomResult        *Result[struct{}]
+	CycleRoomResult       *Result[struct{}]
+	SelectChartResult     *Result[struct{}]
+	RequestStartResult    *Result[struct{}]
+	ReadyResult           *Result[struct{}]
+	CancelReadyResult     *Result[struct{}]
+	PlayedResult          *Result[struct{}]
+	AbortResult           *Result[struct{}]
+	QueueJoinResult       *Result[struct{}]
+	QueueUpdate           *QueueStatus
+	OverflowRoomResult    *Result[struct{}]
+	LoadProgress          *LoadStatus
+	SwitchRoleResult      *Result[struct{}]
+	GlobalChatResult      *Result[struct{}]
+	GlobalSubscribeResult *Result[struct{}]
+	SetMaxMonitorsResult  *Result[struct{}]
+	SubmitResultResult    *Result[int64] // 成功时为成绩ID（V10前为int32）
+	ReauthGrace           uint32         // ReauthRequired：需在该秒数内重新认证，否则断开连接
+	Pong                  PingTimes      // Pong（V18起）
+	ReauthenticateResult  *Result[struct{}]
+	UpdateProfileResult   *Result[struct{}]
+	ProfileUpdated        *ProfileInfo // ProfileUpdated：房间内玩家资料变更
+	MonitorChatResult     *Result[struct{}]
+	RoomClosed            *RoomClosed              // RoomClosed：所在房间被解散或移除
+	ValidateChartResult   *Result[ChartValidation] // 谱面不存在或不在房间中时为错误
+	SetRankingResult      *Result[struct{}]
+	ScoreUpdatePlayer     int32      // ScoreUpdate：玩家ID
+	ScoreUpdate           *LiveScore // ScoreUpdate：该玩家的实时成绩
+	EmoteResult           *Result[struct{}]
+	SetScheduleResult     *Result[struct{}]
+	ListRoomsResult       *Result[RoomList]
+	ShareStatsResult      *Result[struct{}]
+	TransferHostResult    *Result[struct{}]
+	ChatHistoryResult     *Result[ChatHistory]
+	KickResult            *Result[struct{}]
+	Kicked                *Kicked // Kicked：被移出的原因
+	SetPasswordResult     *Result[struct{}]
+	SetTeamResult         *Result[struct{}]
+	Disconnect            *Disconnect // Disconnect：断开连接的原因
+	Extensions            Extensions  // 末尾的扩展字段（V6起）
 }
 
 // AuthResult 认证结果
 type AuthResult struct {
-	User UserInfo
-	Room *ClientRoomState
+	User UserInfo         `json:"user"`
+	Room *ClientRoomState `json:"room,omitempty"`
 }
 
 func (ar *AuthResult) ReadBinary(r *BinaryReader) error {
 	if err := ar.User.ReadBinary(r); err != nil {
 		return err
 	}
-	hasRoom, _ := ReadBool(r)
+	hasRoom, err := ReadBool(r)
+	if err != nil {
+		return err
+	}
 	if hasRoom {
 		ar.Room = &ClientRoomState{}
-		ar.Room.ReadBinary(r)
+		return ar.Room.ReadBinary(r)
 	}
 	return nil
 }
@@ -841,17 +1421,316 @@ func (ar *AuthResult) WriteBinary(w *BinaryWriter) error {
 	ar.User.WriteBinary(w)
 	if ar.Room != nil {
 		WriteBool(w, true)
-		ar.Room.WriteBinary(w)
+		if err := ar.Room.WriteBinary(w); err != nil {
+			return err
+		}
 	} else {
 		WriteBool(w, false)
 	}
 	return nil
 }
 
+// QueueStatus 排队状态
+//
+//binary:generate
+type QueueStatus struct {
+	RoomId   RoomId     `json:"room"`
+	Position uint32     `json:"position"`          // 排队位置（从1开始），0表示不在队列中
+	Waiting  []UserInfo `json:"waiting,omitempty"` // 等待列表（仅发送给房主）
+}
+
+// LoadStatus 谱面加载阶段的整体进度
+//
+//binary:generate
+type LoadStatus struct {
+	Loaded    uint32 `json:"loaded"`    // 已加载完成的玩家数
+	Total     uint32 `json:"total"`     // 需要加载的玩家数
+	Progress  uint8  `json:"progress"`  // 平均加载进度（0-100）
+	Remaining uint32 `json:"remaining"` // 距离超时的剩余秒数
+}
+
+// 玩家资料长度上限（字节）
+const (
+	ProfileNameMaxLen   = 64
+	ProfileAvatarMaxLen = 256
+)
+
+// RankingNameMaxLen 排名策略名称长度上限（字节）
+const RankingNameMaxLen = 32
+
+// EmoteMaxLen 表情ID长度上限（字节）
+const EmoteMaxLen = 32
+
+// RoomPasswordMaxLen 房间密码长度上限（字节）
+const RoomPasswordMaxLen = 32
+
+// RoomMaxTeams 房间内的队伍数量上限，队伍编号为 1～RoomMaxTeams
+const RoomMaxTeams = 8
+
+// ProfileInfo 玩家资料（显示名称与头像提示）
+//
+//binary:generate
+type ProfileInfo struct {
+	ID     int32  `json:"id"`
+	Name   string `json:"name"`
+	Avatar string `json:"avatar,omitempty"`
+}
+
+// LiveScore 对局中的实时成绩（ScoreUpdate），由客户端定期发送，仅用于直播展示，最终成绩仍以 Played 为准
+//
+//binary:generate
+type LiveScore struct {
+	Score    int32   `json:"score"`
+	Combo    int32   `json:"combo"`
+	Accuracy float32 `json:"accuracy"` // 0-1
+}
+
+// RoomClosed 房间关闭通知（收到后客户端已不在该房间内）
+//
+//binary:generate
+type RoomClosed struct {
+	RoomId RoomId `json:"room"`
+	Reason string `json:"reason"`
+}
+
+// PingTimes Ping/Pong 携带的时间戳（V18起，Unix毫秒），双方据此测量往返延迟
+// Ping：Time 为客户端发送时间，Echo 为最近收到的 Pong 中的 Time（尚未收到时为0），Hold 为收到该 Pong 到发送本次 Ping 经过的毫秒数
+// Pong：Time 为服务器发送时间，Echo 为对应 Ping 的 Time，Hold 为0
+// 客户端的往返延迟为收到 Pong 的时间减去 Echo，服务器的往返延迟为收到 Ping 的时间减去 Echo 与 Hold
+type PingTimes struct {
+	Time uint64 `json:"time"`
+	Echo uint64 `json:"echo,omitempty"`
+	Hold uint64 `json:"hold,omitempty"`
+}
+
+func (p *PingTimes) ReadBinary(r *BinaryReader) error {
+	var err error
+	if p.Time, err = r.Uleb(); err != nil {
+		return err
+	}
+	if p.Echo, err = r.Uleb(); err != nil {
+		return err
+	}
+	p.Hold, err = r.Uleb()
+	return err
+}
+
+func (p *PingTimes) WriteBinary(w *BinaryWriter) error {
+	w.Uleb(p.Time)
+	w.Uleb(p.Echo)
+	w.Uleb(p.Hold)
+	return nil
+}
+
+// RoomSchedule 房间的开放与关闭时间（Unix毫秒，0表示不限制）
+// 开放前拒绝加入，到达关闭时间后解散房间（Lock 为 true 时改为锁定）
+type RoomSchedule struct {
+	OpenAt  int64 `json:"open_at,omitempty"`
+	CloseAt int64 `json:"close_at,omitempty"`
+	Lock    bool  `json:"lock,omitempty"`
+}
+
+func (s *RoomSchedule) ReadBinary(r *BinaryReader) error {
+	openAt, err := r.Uleb()
+	if err != nil {
+		return err
+	}
+	closeAt, err := r.Uleb()
+	if err != nil {
+		return err
+	}
+	if openAt > math.MaxInt64 || closeAt > math.MaxInt64 {
+		return fmt.Errorf("schedule time out of range")
+	}
+	s.OpenAt, s.CloseAt = int64(openAt), int64(closeAt)
+	s.Lock, err = ReadBool(r)
+	return err
+}
+
+func (s *RoomSchedule) WriteBinary(w *BinaryWriter) error {
+	w.Uleb(uint64(max(s.OpenAt, 0)))
+	w.Uleb(uint64(max(s.CloseAt, 0)))
+	WriteBool(w, s.Lock)
+	return nil
+}
+
+// ChartPreview 谱面预览（MsgSelectChart 的扩展字段 ExtChartPreview），客户端与直播工具无需再查询Phira主站
+//
+//binary:generate
+type ChartPreview struct {
+	ID           int32   `json:"id"` // 超出int32范围时为0，完整ID见 Message.ChartID
+	Name         string  `json:"name"`
+	Level        string  `json:"level"`        // 难度标签（如 "IN Lv.15"）
+	Difficulty   float32 `json:"difficulty"`   // 定数
+	Charter      string  `json:"charter"`      // 谱师
+	Composer     string  `json:"composer"`     // 曲师
+	Illustrator  string  `json:"illustrator"`  // 画师
+	Illustration string  `json:"illustration"` // 曲绘URL
+	Duration     float32 `json:"duration"`     // 时长（秒，未知时为0）
+}
+
+// ChartLevel 谱面难度（MsgSelectChart 的扩展字段 ExtChartLevel），由难度标签拆分而来，客户端可直接显示为 "IN 15"
+//
+//binary:generate
+type ChartLevel struct {
+	Name  string `json:"name"`  // 难度名称（如 "EZ"、"HD"、"IN"、"AT"、"SP"）
+	Level string `json:"level"` // 等级（如 "15"，未定级时为 "?"）
+}
+
+// ParseChartLevel 拆分Phira主站的难度标签（如 "IN Lv.15"），不含 "Lv." 时整个标签作为难度名称
+func ParseChartLevel(label string) ChartLevel {
+	name, level, ok := strings.Cut(label, "Lv.")
+	if !ok {
+		return ChartLevel{Name: strings.TrimSpace(label)}
+	}
+	return ChartLevel{Name: strings.TrimSpace(name), Level: strings.TrimSpace(level)}
+}
+
+// String 显示用的难度，如 "IN 15"
+func (l ChartLevel) String() string {
+	return strings.TrimSpace(l.Name + " " + l.Level)
+}
+
+// ChartValidation 谱面预检结果（ValidateChart），Reason 为空表示此时可以选择该谱面
+//
+//binary:generate
+type ChartValidation struct {
+	Chart  ChartPreview `json:"chart"`
+	Reason string       `json:"reason,omitempty"` // 不能选择的原因（与 SelectChart 失败时的提示相同）
+}
+
+// RoomListPageSize ListRooms 每页的房间数量
+const RoomListPageSize = 20
+
+// RoomList 可加入房间列表的一页（ListRooms），只包含未锁定且已开放的房间，按房间ID排序
+//
+//binary:generate
+type RoomList struct {
+	Rooms []RoomListEntry `json:"rooms"`
+	Page  uint32          `json:"page"`  // 页码（从0开始）
+	Total uint32          `json:"total"` // 符合条件的房间总数
+}
+
+// ChatHistory 所在房间最近的房间消息（ChatHistory），按发送时间从早到晚排列
+//
+//binary:generate
+type ChatHistory struct {
+	Messages []Message `json:"messages"`
+}
+
+// KickReason 被移出的原因（Kicked），客户端应将未知的值按 KickReasonAdmin 处理
+type KickReason uint8
+
+const (
+	KickReasonHost       KickReason = iota // 被房主移出房间
+	KickReasonAdmin                        // 被管理员移出房间或断开连接
+	KickReasonBanned                       // 被服务器封禁，随后断开连接
+	KickReasonRoomBanned                   // 被禁止进入该房间
+	KickReasonDisbanded                    // 房间被解散或移除
+)
+
+func (k *KickReason) ReadBinary(r *BinaryReader) error {
+	v, err := ReadUint8(r)
+	if err != nil {
+		return err
+	}
+	*k = KickReason(v)
+	return nil
+}
+
+func (k *KickReason) WriteBinary(w *BinaryWriter) error {
+	WriteUint8(w, uint8(*k))
+	return nil
+}
+
+// Kicked 被移出的通知（V23起），收到后客户端已不在房间内
+// RoomId 为被移出的房间（不在房间中时被断开连接则为空），Message 为可选的说明文字
+//
+//binary:generate
+type Kicked struct {
+	RoomId  *RoomId    `json:"room,omitempty"`
+	Reason  KickReason `json:"reason"`
+	Message string     `json:"message"`
+}
+
+// DisconnectReason 服务器断开连接的原因（Disconnect），客户端应将未知的值按 DisconnectReasonKicked 处理
+type DisconnectReason uint8
+
+const (
+	DisconnectReasonKicked           DisconnectReason = iota // 被管理员断开连接
+	DisconnectReasonBanned                                   // 被服务器封禁
+	DisconnectReasonHeartbeatTimeout                         // 超过心跳超时没有收到客户端的数据
+	DisconnectReasonServerShutdown                           // 服务器关闭或平滑升级
+	DisconnectReasonProtocolError                            // 客户端发送了无法解码或超长的命令
+	DisconnectReasonAuthTimeout                              // 未在认证超时内完成认证
+	DisconnectReasonSessionExpired                           // 会话超过有效期且未在宽限期内重新认证
+	DisconnectReasonReplaced                                 // 同一账号在其他连接上重新登录
+	DisconnectReasonSlowConsumer                             // 客户端读取过慢，发送队列已满
+	DisconnectReasonClosed                                   // 客户端关闭了连接（不发送通知，仅用于统计）
+
+	// DisconnectReasonCount 断开原因的数量（不是有效的原因）
+	DisconnectReasonCount
+)
+
+var disconnectReasonNames = [DisconnectReasonCount]string{
+	"kicked", "banned", "heartbeat_timeout", "server_shutdown", "protocol_error",
+	"auth_timeout", "session_expired", "replaced", "slow_consumer", "closed",
+}
+
+// String 断开原因的名称（用于统计与管理接口），未知的值返回 "unknown"
+func (d DisconnectReason) String() string {
+	if d < DisconnectReasonCount {
+		return disconnectReasonNames[d]
+	}
+	return "unknown"
+}
+
+func (d *DisconnectReason) ReadBinary(r *BinaryReader) error {
+	v, err := ReadUint8(r)
+	if err != nil {
+		return err
+	}
+	*d = DisconnectReason(v)
+	return nil
+}
+
+func (d *DisconnectReason) WriteBinary(w *BinaryWriter) error {
+	WriteUint8(w, uint8(*d))
+	return nil
+}
+
+// Disconnect 服务器断开连接前发送的最后一条命令（V27起，尽力送达），Message 为可选的说明文字
+//
+//binary:generate
+type Disconnect struct {
+	Reason  DisconnectReason `json:"reason"`
+	Message string           `json:"message"`
+}
+
+// RoomListEntry 房间列表中的房间
+//
+//binary:generate
+type RoomListEntry struct {
+	RoomId     RoomId   `json:"room"`
+	Host       UserInfo `json:"host"` // 房主（名称为显示名称）
+	Players    uint32   `json:"players"`
+	MaxPlayers uint32   `json:"max_players"`
+	State      uint8    `json:"state"` // RoomStateType
+}
+
 // Result 结果包装
 type Result[T any] struct {
-	Ok  *T
-	Err *string
+	Ok   *T
+	Err  *string
+	Code ErrorCode // 指定的错误码（V12起编码），为 ErrCodeOther 时按 Err 查找；解码时与按 Err 查找的结果相同则为 ErrCodeOther，应通过 ErrorCode 获取
+}
+
+// ErrorCode 失败结果的错误码，成功时返回 ErrCodeOther
+func (r *Result[T]) ErrorCode() ErrorCode {
+	if r.Err == nil || r.Code != ErrCodeOther {
+		return r.Code
+	}
+	return LookupErrorCode(*r.Err)
 }
 
 func (r *Result[T]) ReadBinary(reader *BinaryReader, readValue func(*BinaryReader) (T, error)) error {
@@ -865,182 +1744,188 @@ func (r *Result[T]) ReadBinary(reader *BinaryReader, readValue func(*BinaryReade
 			return err
 		}
 		r.Ok = &v
+	} else if reader.stringErrors {
+		errStr, err := ReadString(reader)
+		if err != nil {
+			return err
+		}
+		r.Err = &errStr
 	} else {
-		errStr, _ := ReadString(reader)
+		// V12起为错误码与错误信息，错误信息为空时使用错误码的默认信息
+		code, err := reader.Uleb()
+		if err != nil {
+			return err
+		}
+		if code > math.MaxUint16 {
+			return fmt.Errorf("invalid error code: %d", code)
+		}
+		errStr, err := ReadString(reader)
+		if err != nil {
+			return err
+		}
+		if errStr == "" {
+			errStr = ErrorCode(code).Message()
+		}
 		r.Err = &errStr
+		r.Code = explicitErrorCode(ErrorCode(code), errStr)
 	}
 	return nil
 }
 
+// explicitErrorCode 收到的错误码与按错误信息查找的结果相同时返回 ErrCodeOther，使解码结果与编码前一致
+func explicitErrorCode(code ErrorCode, message string) ErrorCode {
+	if code == LookupErrorCode(message) {
+		return ErrCodeOther
+	}
+	return code
+}
+
+// readResult 读取结果，读取失败时返回错误而不是部分填充的结果
+func readResult[T any](r *BinaryReader, readValue func(*BinaryReader) (T, error)) (*Result[T], error) {
+	result := &Result[T]{}
+	if err := result.ReadBinary(r, readValue); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
 func (r *Result[T]) WriteBinary(w *BinaryWriter, writeValue func(*BinaryWriter, T)) error {
 	if r.Ok != nil {
 		WriteBool(w, true)
 		writeValue(w, *r.Ok)
 	} else if r.Err != nil {
 		WriteBool(w, false)
-		WriteString(w, *r.Err)
+		r.writeError(w)
 	}
 	return nil
 }
 
+// writeError 写入失败结果的错误信息，V12起先写入错误码，与默认信息相同时省略错误信息
+func (r *Result[T]) writeError(w *BinaryWriter) {
+	if w.stringErrors {
+		WriteString(w, *r.Err)
+		return
+	}
+	code := r.ErrorCode()
+	w.Uleb(uint64(code))
+	if code != ErrCodeOther && *r.Err == code.Message() {
+		WriteString(w, "")
+	} else {
+		WriteString(w, *r.Err)
+	}
+}
+
 func (sc *ServerCommand) ReadBinary(r *BinaryReader) error {
 	cmdType, err := ReadUint8(r)
 	if err != nil {
 		return err
 	}
 	sc.Type = ServerCommandType(cmdType)
+	if err := sc.readFields(r); err != nil {
+		return r.tolerate(err)
+	}
+	sc.Extensions, err = readExtensions(r)
+	return r.tolerate(err)
+}
 
+// readFields 按命令类型读取字段
+func (sc *ServerCommand) readFields(r *BinaryReader) (err error) {
 	switch sc.Type {
 	case ServerCmdPong:
-		// 无数据
+		if !r.noPingTimes && r.Remaining() > 0 {
+			err = sc.Pong.ReadBinary(r)
+		}
 	case ServerCmdAuthenticate:
-		isOk, _ := ReadBool(r)
-		sc.AuthenticateResult = &Result[AuthResult]{}
-		if isOk {
+		sc.AuthenticateResult, err = readResult(r, func(r *BinaryReader) (AuthResult, error) {
 			var v AuthResult
-			v.ReadBinary(r)
-			sc.AuthenticateResult.Ok = &v
-		} else {
-			errStr, _ := ReadString(r)
-			sc.AuthenticateResult.Err = &errStr
-		}
-	case ServerCmdChat:
-		isOk, _ := ReadBool(r)
-		sc.ChatResult = &Result[struct{}]{}
-		if isOk {
-			sc.ChatResult.Ok = &struct{}{}
-		} else {
-			errStr, _ := ReadString(r)
-			sc.ChatResult.Err = &errStr
-		}
+			err := v.ReadBinary(r)
+			return v, err
+		})
 	case ServerCmdTouches:
-		sc.TouchesPlayer, _ = ReadInt32(r)
-		length, _ := r.Uleb()
-		sc.TouchesFrames = make([]TouchFrame, length)
-		for i := uint64(0); i < length; i++ {
-			sc.TouchesFrames[i].ReadBinary(r)
+		if sc.TouchesPlayer, err = ReadInt32(r); err != nil {
+			return err
 		}
+		sc.TouchesFrames, err = ReadList[TouchFrame](r, GetDecodeLimits().TouchFrames)
 	case ServerCmdJudges:
-		sc.JudgesPlayer, _ = ReadInt32(r)
-		length, _ := r.Uleb()
-		sc.JudgesEvents = make([]JudgeEvent, length)
-		for i := uint64(0); i < length; i++ {
-			sc.JudgesEvents[i].ReadBinary(r)
+		if sc.JudgesPlayer, err = ReadInt32(r); err != nil {
+			return err
 		}
+		sc.JudgesEvents, err = ReadList[JudgeEvent](r, GetDecodeLimits().JudgeEvents)
 	case ServerCmdMessage:
 		sc.Message = &Message{}
-		sc.Message.ReadBinary(r)
+		err = sc.Message.ReadBinary(r)
 	case ServerCmdChangeState:
 		sc.ChangeState = &RoomState{}
-		sc.ChangeState.ReadBinary(r)
+		err = sc.ChangeState.ReadBinary(r)
 	case ServerCmdChangeHost:
-		sc.ChangeHost, _ = ReadBool(r)
-	case ServerCmdCreateRoom:
-		isOk, _ := ReadBool(r)
-		sc.CreateRoomResult = &Result[struct{}]{}
-		if isOk {
-			sc.CreateRoomResult.Ok = &struct{}{}
-		} else {
-			errStr, _ := ReadString(r)
-			sc.CreateRoomResult.Err = &errStr
-		}
+		sc.ChangeHost, err = ReadBool(r)
 	case ServerCmdJoinRoom:
-		isOk, _ := ReadBool(r)
-		sc.JoinRoomResult = &Result[JoinRoomResponse]{}
-		if isOk {
+		sc.JoinRoomResult, err = readResult(r, func(r *BinaryReader) (JoinRoomResponse, error) {
 			var v JoinRoomResponse
-			v.ReadBinary(r)
-			sc.JoinRoomResult.Ok = &v
-		} else {
-			errStr, _ := ReadString(r)
-			sc.JoinRoomResult.Err = &errStr
-		}
+			err := v.ReadBinary(r)
+			return v, err
+		})
 	case ServerCmdOnJoinRoom:
 		sc.OnJoinRoomUser = &UserInfo{}
-		sc.OnJoinRoomUser.ReadBinary(r)
-	case ServerCmdLeaveRoom:
-		isOk, _ := ReadBool(r)
-		sc.LeaveRoomResult = &Result[struct{}]{}
-		if isOk {
-			sc.LeaveRoomResult.Ok = &struct{}{}
-		} else {
-			errStr, _ := ReadString(r)
-			sc.LeaveRoomResult.Err = &errStr
-		}
-	case ServerCmdLockRoom:
-		isOk, _ := ReadBool(r)
-		sc.LockRoomResult = &Result[struct{}]{}
-		if isOk {
-			sc.LockRoomResult.Ok = &struct{}{}
-		} else {
-			errStr, _ := ReadString(r)
-			sc.LockRoomResult.Err = &errStr
-		}
-	case ServerCmdCycleRoom:
-		isOk, _ := ReadBool(r)
-		sc.CycleRoomResult = &Result[struct{}]{}
-		if isOk {
-			sc.CycleRoomResult.Ok = &struct{}{}
-		} else {
-			errStr, _ := ReadString(r)
-			sc.CycleRoomResult.Err = &errStr
-		}
-	case ServerCmdSelectChart:
-		isOk, _ := ReadBool(r)
-		sc.SelectChartResult = &Result[struct{}]{}
-		if isOk {
-			sc.SelectChartResult.Ok = &struct{}{}
-		} else {
-			errStr, _ := ReadString(r)
-			sc.SelectChartResult.Err = &errStr
-		}
-	case ServerCmdRequestStart:
-		isOk, _ := ReadBool(r)
-		sc.RequestStartResult = &Result[struct{}]{}
-		if isOk {
-			sc.RequestStartResult.Ok = &struct{}{}
-		} else {
-			errStr, _ := ReadString(r)
-			sc.RequestStartResult.Err = &errStr
-		}
-	case ServerCmdReady:
-		isOk, _ := ReadBool(r)
-		sc.ReadyResult = &Result[struct{}]{}
-		if isOk {
-			sc.ReadyResult.Ok = &struct{}{}
-		} else {
-			errStr, _ := ReadString(r)
-			sc.ReadyResult.Err = &errStr
-		}
-	case ServerCmdCancelReady:
-		isOk, _ := ReadBool(r)
-		sc.CancelReadyResult = &Result[struct{}]{}
-		if isOk {
-			sc.CancelReadyResult.Ok = &struct{}{}
-		} else {
-			errStr, _ := ReadString(r)
-			sc.CancelReadyResult.Err = &errStr
-		}
-	case ServerCmdPlayed:
-		isOk, _ := ReadBool(r)
-		sc.PlayedResult = &Result[struct{}]{}
-		if isOk {
-			sc.PlayedResult.Ok = &struct{}{}
-		} else {
-			errStr, _ := ReadString(r)
-			sc.PlayedResult.Err = &errStr
+		err = sc.OnJoinRoomUser.ReadBinary(r)
+	case ServerCmdQueueUpdate:
+		sc.QueueUpdate = &QueueStatus{}
+		err = sc.QueueUpdate.ReadBinary(r)
+	case ServerCmdLoadProgress:
+		sc.LoadProgress = &LoadStatus{}
+		err = sc.LoadProgress.ReadBinary(r)
+	case ServerCmdSubmitResult:
+		sc.SubmitResultResult, err = readResult(r, ReadID)
+	case ServerCmdReauthRequired:
+		sc.ReauthGrace, err = ReadUint32(r)
+	case ServerCmdProfileUpdated:
+		sc.ProfileUpdated = &ProfileInfo{}
+		err = sc.ProfileUpdated.ReadBinary(r)
+	case ServerCmdRoomClosed:
+		sc.RoomClosed = &RoomClosed{}
+		err = sc.RoomClosed.ReadBinary(r)
+	case ServerCmdValidateChart:
+		sc.ValidateChartResult, err = readResult(r, func(r *BinaryReader) (ChartValidation, error) {
+			var v ChartValidation
+			err := v.ReadBinary(r)
+			return v, err
+		})
+	case ServerCmdListRooms:
+		sc.ListRoomsResult, err = readResult(r, func(r *BinaryReader) (RoomList, error) {
+			var v RoomList
+			err := v.ReadBinary(r)
+			return v, err
+		})
+	case ServerCmdChatHistory:
+		sc.ChatHistoryResult, err = readResult(r, func(r *BinaryReader) (ChatHistory, error) {
+			var v ChatHistory
+			err := v.ReadBinary(r)
+			return v, err
+		})
+	case ServerCmdKicked:
+		sc.Kicked = &Kicked{}
+		err = sc.Kicked.ReadBinary(r)
+	case ServerCmdDisconnect:
+		sc.Disconnect = &Disconnect{}
+		err = sc.Disconnect.ReadBinary(r)
+	case ServerCmdScoreUpdate:
+		if sc.ScoreUpdatePlayer, err = ReadInt32(r); err != nil {
+			return err
 		}
-	case ServerCmdAbort:
-		isOk, _ := ReadBool(r)
-		sc.AbortResult = &Result[struct{}]{}
-		if isOk {
-			sc.AbortResult.Ok = &struct{}{}
-		} else {
-			errStr, _ := ReadString(r)
-			sc.AbortResult.Err = &errStr
+		sc.ScoreUpdate = &LiveScore{}
+		err = sc.ScoreUpdate.ReadBinary(r)
+	default:
+		result := sc.unitResult()
+		if result == nil {
+			if r.strict {
+				return fmt.Errorf("unknown server command type: %d", sc.Type)
+			}
+			r.skipRest()
+			return nil
 		}
+		*result, err = readResult(r, func(*BinaryReader) (struct{}, error) { return struct{}{}, nil })
 	}
-	return nil
+	return err
 }
 
 func (sc *ServerCommand) WriteBinary(w *BinaryWriter) error {
@@ -1048,15 +1933,19 @@ func (sc *ServerCommand) WriteBinary(w *BinaryWriter) error {
 
 	switch sc.Type {
 	case ServerCmdPong:
-		// 无数据
+		if !w.noPingTimes {
+			sc.Pong.WriteBinary(w)
+		}
 	case ServerCmdAuthenticate:
 		if sc.AuthenticateResult != nil {
 			if sc.AuthenticateResult.Ok != nil {
 				WriteBool(w, true)
-				sc.AuthenticateResult.Ok.WriteBinary(w)
+				if err := sc.AuthenticateResult.Ok.WriteBinary(w); err != nil {
+					return err
+				}
 			} else if sc.AuthenticateResult.Err != nil {
 				WriteBool(w, false)
-				WriteString(w, *sc.AuthenticateResult.Err)
+				sc.AuthenticateResult.writeError(w)
 			}
 		}
 	case ServerCmdChat:
@@ -1065,7 +1954,7 @@ func (sc *ServerCommand) WriteBinary(w *BinaryWriter) error {
 				WriteBool(w, true)
 			} else if sc.ChatResult.Err != nil {
 				WriteBool(w, false)
-				WriteString(w, *sc.ChatResult.Err)
+				sc.ChatResult.writeError(w)
 			}
 		}
 	case ServerCmdTouches:
@@ -1076,14 +1965,15 @@ func (sc *ServerCommand) WriteBinary(w *BinaryWriter) error {
 		}
 	case ServerCmdJudges:
 		WriteInt32(w, sc.JudgesPlayer)
-		w.Uleb(uint64(len(sc.JudgesEvents)))
-		for _, j := range sc.JudgesEvents {
-			j.WriteBinary(w)
-		}
+		writeJudges(w, sc.JudgesEvents)
 	case ServerCmdMessage:
-		sc.Message.WriteBinary(w)
+		if err := sc.Message.WriteBinary(w); err != nil {
+			return err
+		}
 	case ServerCmdChangeState:
-		sc.ChangeState.WriteBinary(w)
+		if err := sc.ChangeState.WriteBinary(w); err != nil {
+			return err
+		}
 	case ServerCmdChangeHost:
 		WriteBool(w, sc.ChangeHost)
 	case ServerCmdCreateRoom:
@@ -1092,17 +1982,19 @@ func (sc *ServerCommand) WriteBinary(w *BinaryWriter) error {
 				WriteBool(w, true)
 			} else if sc.CreateRoomResult.Err != nil {
 				WriteBool(w, false)
-				WriteString(w, *sc.CreateRoomResult.Err)
+				sc.CreateRoomResult.writeError(w)
 			}
 		}
 	case ServerCmdJoinRoom:
 		if sc.JoinRoomResult != nil {
 			if sc.JoinRoomResult.Ok != nil {
 				WriteBool(w, true)
-				sc.JoinRoomResult.Ok.WriteBinary(w)
+				if err := sc.JoinRoomResult.Ok.WriteBinary(w); err != nil {
+					return err
+				}
 			} else if sc.JoinRoomResult.Err != nil {
 				WriteBool(w, false)
-				WriteString(w, *sc.JoinRoomResult.Err)
+				sc.JoinRoomResult.writeError(w)
 			}
 		}
 	case ServerCmdOnJoinRoom:
@@ -1113,7 +2005,7 @@ func (sc *ServerCommand) WriteBinary(w *BinaryWriter) error {
 				WriteBool(w, true)
 			} else if sc.LeaveRoomResult.Err != nil {
 				WriteBool(w, false)
-				WriteString(w, *sc.LeaveRoomResult.Err)
+				sc.LeaveRoomResult.writeError(w)
 			}
 		}
 	case ServerCmdLockRoom:
@@ -1122,7 +2014,7 @@ func (sc *ServerCommand) WriteBinary(w *BinaryWriter) error {
 				WriteBool(w, true)
 			} else if sc.LockRoomResult.Err != nil {
 				WriteBool(w, false)
-				WriteString(w, *sc.LockRoomResult.Err)
+				sc.LockRoomResult.writeError(w)
 			}
 		}
 	case ServerCmdCycleRoom:
@@ -1131,7 +2023,7 @@ func (sc *ServerCommand) WriteBinary(w *BinaryWriter) error {
 				WriteBool(w, true)
 			} else if sc.CycleRoomResult.Err != nil {
 				WriteBool(w, false)
-				WriteString(w, *sc.CycleRoomResult.Err)
+				sc.CycleRoomResult.writeError(w)
 			}
 		}
 	case ServerCmdSelectChart:
@@ -1140,7 +2032,7 @@ func (sc *ServerCommand) WriteBinary(w *BinaryWriter) error {
 				WriteBool(w, true)
 			} else if sc.SelectChartResult.Err != nil {
 				WriteBool(w, false)
-				WriteString(w, *sc.SelectChartResult.Err)
+				sc.SelectChartResult.writeError(w)
 			}
 		}
 	case ServerCmdRequestStart:
@@ -1149,7 +2041,7 @@ func (sc *ServerCommand) WriteBinary(w *BinaryWriter) error {
 				WriteBool(w, true)
 			} else if sc.RequestStartResult.Err != nil {
 				WriteBool(w, false)
-				WriteString(w, *sc.RequestStartResult.Err)
+				sc.RequestStartResult.writeError(w)
 			}
 		}
 	case ServerCmdReady:
@@ -1158,7 +2050,7 @@ func (sc *ServerCommand) WriteBinary(w *BinaryWriter) error {
 				WriteBool(w, true)
 			} else if sc.ReadyResult.Err != nil {
 				WriteBool(w, false)
-				WriteString(w, *sc.ReadyResult.Err)
+				sc.ReadyResult.writeError(w)
 			}
 		}
 	case ServerCmdCancelReady:
@@ -1167,7 +2059,7 @@ func (sc *ServerCommand) WriteBinary(w *BinaryWriter) error {
 				WriteBool(w, true)
 			} else if sc.CancelReadyResult.Err != nil {
 				WriteBool(w, false)
-				WriteString(w, *sc.CancelReadyResult.Err)
+				sc.CancelReadyResult.writeError(w)
 			}
 		}
 	case ServerCmdPlayed:
@@ -1176,7 +2068,7 @@ func (sc *ServerCommand) WriteBinary(w *BinaryWriter) error {
 				WriteBool(w, true)
 			} else if sc.PlayedResult.Err != nil {
 				WriteBool(w, false)
-				WriteString(w, *sc.PlayedResult.Err)
+				sc.PlayedResult.writeError(w)
 			}
 		}
 	case ServerCmdAbort:
@@ -1185,9 +2077,237 @@ func (sc *ServerCommand) WriteBinary(w *BinaryWriter) error {
 				WriteBool(w, true)
 			} else if sc.AbortResult.Err != nil {
 				WriteBool(w, false)
-				WriteString(w, *sc.AbortResult.Err)
+				sc.AbortResult.writeError(w)
+			}
+		}
+	case ServerCmdQueueJoin:
+		if sc.QueueJoinResult != nil {
+			if sc.QueueJoinResult.Ok != nil {
+				WriteBool(w, true)
+			} else if sc.QueueJoinResult.Err != nil {
+				WriteBool(w, false)
+				sc.QueueJoinResult.writeError(w)
+			}
+		}
+	case ServerCmdQueueUpdate:
+		sc.QueueUpdate.WriteBinary(w)
+	case ServerCmdLoadProgress:
+		sc.LoadProgress.WriteBinary(w)
+	case ServerCmdOverflowRoom:
+		if sc.OverflowRoomResult != nil {
+			if sc.OverflowRoomResult.Ok != nil {
+				WriteBool(w, true)
+			} else if sc.OverflowRoomResult.Err != nil {
+				WriteBool(w, false)
+				sc.OverflowRoomResult.writeError(w)
+			}
+		}
+	case ServerCmdSwitchRole:
+		if sc.SwitchRoleResult != nil {
+			if sc.SwitchRoleResult.Ok != nil {
+				WriteBool(w, true)
+			} else if sc.SwitchRoleResult.Err != nil {
+				WriteBool(w, false)
+				sc.SwitchRoleResult.writeError(w)
+			}
+		}
+	case ServerCmdGlobalChat:
+		if sc.GlobalChatResult != nil {
+			if sc.GlobalChatResult.Ok != nil {
+				WriteBool(w, true)
+			} else if sc.GlobalChatResult.Err != nil {
+				WriteBool(w, false)
+				sc.GlobalChatResult.writeError(w)
+			}
+		}
+	case ServerCmdGlobalSubscribe:
+		if sc.GlobalSubscribeResult != nil {
+			if sc.GlobalSubscribeResult.Ok != nil {
+				WriteBool(w, true)
+			} else if sc.GlobalSubscribeResult.Err != nil {
+				WriteBool(w, false)
+				sc.GlobalSubscribeResult.writeError(w)
+			}
+		}
+	case ServerCmdSetMaxMonitors:
+		if sc.SetMaxMonitorsResult != nil {
+			if sc.SetMaxMonitorsResult.Ok != nil {
+				WriteBool(w, true)
+			} else if sc.SetMaxMonitorsResult.Err != nil {
+				WriteBool(w, false)
+				sc.SetMaxMonitorsResult.writeError(w)
+			}
+		}
+	case ServerCmdSubmitResult:
+		if sc.SubmitResultResult != nil {
+			if sc.SubmitResultResult.Ok != nil {
+				WriteBool(w, true)
+				if err := WriteID(w, *sc.SubmitResultResult.Ok); err != nil {
+					return err
+				}
+			} else if sc.SubmitResultResult.Err != nil {
+				WriteBool(w, false)
+				sc.SubmitResultResult.writeError(w)
+			}
+		}
+	case ServerCmdReauthRequired:
+		WriteUint32(w, sc.ReauthGrace)
+	case ServerCmdReauthenticate:
+		if sc.ReauthenticateResult != nil {
+			if sc.ReauthenticateResult.Ok != nil {
+				WriteBool(w, true)
+			} else if sc.ReauthenticateResult.Err != nil {
+				WriteBool(w, false)
+				sc.ReauthenticateResult.writeError(w)
+			}
+		}
+	case ServerCmdUpdateProfile:
+		if sc.UpdateProfileResult != nil {
+			if sc.UpdateProfileResult.Ok != nil {
+				WriteBool(w, true)
+			} else if sc.UpdateProfileResult.Err != nil {
+				WriteBool(w, false)
+				sc.UpdateProfileResult.writeError(w)
+			}
+		}
+	case ServerCmdProfileUpdated:
+		if sc.ProfileUpdated != nil {
+			sc.ProfileUpdated.WriteBinary(w)
+		}
+	case ServerCmdMonitorChat:
+		if sc.MonitorChatResult != nil {
+			if sc.MonitorChatResult.Ok != nil {
+				WriteBool(w, true)
+			} else if sc.MonitorChatResult.Err != nil {
+				WriteBool(w, false)
+				sc.MonitorChatResult.writeError(w)
+			}
+		}
+	case ServerCmdSetRanking:
+		if sc.SetRankingResult != nil {
+			if sc.SetRankingResult.Ok != nil {
+				WriteBool(w, true)
+			} else if sc.SetRankingResult.Err != nil {
+				WriteBool(w, false)
+				sc.SetRankingResult.writeError(w)
+			}
+		}
+	case ServerCmdEmote:
+		if sc.EmoteResult != nil {
+			if sc.EmoteResult.Ok != nil {
+				WriteBool(w, true)
+			} else if sc.EmoteResult.Err != nil {
+				WriteBool(w, false)
+				sc.EmoteResult.writeError(w)
+			}
+		}
+	case ServerCmdSetSchedule:
+		if sc.SetScheduleResult != nil {
+			if sc.SetScheduleResult.Ok != nil {
+				WriteBool(w, true)
+			} else if sc.SetScheduleResult.Err != nil {
+				WriteBool(w, false)
+				sc.SetScheduleResult.writeError(w)
+			}
+		}
+	case ServerCmdShareStats:
+		if sc.ShareStatsResult != nil {
+			if sc.ShareStatsResult.Ok != nil {
+				WriteBool(w, true)
+			} else if sc.ShareStatsResult.Err != nil {
+				WriteBool(w, false)
+				sc.ShareStatsResult.writeError(w)
+			}
+		}
+	case ServerCmdTransferHost:
+		if sc.TransferHostResult != nil {
+			if sc.TransferHostResult.Ok != nil {
+				WriteBool(w, true)
+			} else if sc.TransferHostResult.Err != nil {
+				WriteBool(w, false)
+				sc.TransferHostResult.writeError(w)
+			}
+		}
+	case ServerCmdRoomClosed:
+		if sc.RoomClosed != nil {
+			sc.RoomClosed.WriteBinary(w)
+		}
+	case ServerCmdScoreUpdate:
+		WriteInt32(w, sc.ScoreUpdatePlayer)
+		if sc.ScoreUpdate != nil {
+			sc.ScoreUpdate.WriteBinary(w)
+		}
+	case ServerCmdValidateChart:
+		if sc.ValidateChartResult != nil {
+			if sc.ValidateChartResult.Ok != nil {
+				WriteBool(w, true)
+				sc.ValidateChartResult.Ok.WriteBinary(w)
+			} else if sc.ValidateChartResult.Err != nil {
+				WriteBool(w, false)
+				sc.ValidateChartResult.writeError(w)
+			}
+		}
+	case ServerCmdListRooms:
+		if sc.ListRoomsResult != nil {
+			if sc.ListRoomsResult.Ok != nil {
+				WriteBool(w, true)
+				sc.ListRoomsResult.Ok.WriteBinary(w)
+			} else if sc.ListRoomsResult.Err != nil {
+				WriteBool(w, false)
+				sc.ListRoomsResult.writeError(w)
+			}
+		}
+	case ServerCmdChatHistory:
+		if sc.ChatHistoryResult != nil {
+			if sc.ChatHistoryResult.Ok != nil {
+				WriteBool(w, true)
+				if err := sc.ChatHistoryResult.Ok.WriteBinary(w); err != nil {
+					return err
+				}
+			} else if sc.ChatHistoryResult.Err != nil {
+				WriteBool(w, false)
+				sc.ChatHistoryResult.writeError(w)
+			}
+		}
+	case ServerCmdKick:
+		if sc.KickResult != nil {
+			if sc.KickResult.Ok != nil {
+				WriteBool(w, true)
+			} else if sc.KickResult.Err != nil {
+				WriteBool(w, false)
+				sc.KickResult.writeError(w)
+			}
+		}
+	case ServerCmdKicked:
+		if sc.Kicked != nil {
+			if err := sc.Kicked.WriteBinary(w); err != nil {
+				return err
+			}
+		}
+	case ServerCmdSetPassword:
+		if sc.SetPasswordResult != nil {
+			if sc.SetPasswordResult.Ok != nil {
+				WriteBool(w, true)
+			} else if sc.SetPasswordResult.Err != nil {
+				WriteBool(w, false)
+				sc.SetPasswordResult.writeError(w)
+			}
+		}
+	case ServerCmdSetTeam:
+		if sc.SetTeamResult != nil {
+			if sc.SetTeamResult.Ok != nil {
+				WriteBool(w, true)
+			} else if sc.SetTeamResult.Err != nil {
+				WriteBool(w, false)
+				sc.SetTeamResult.writeError(w)
+			}
+		}
+	case ServerCmdDisconnect:
+		if sc.Disconnect != nil {
+			if err := sc.Disconnect.WriteBinary(w); err != nil {
+				return err
 			}
 		}
 	}
-	return nil
+	return writeExtensions(w, sc.Extensions)
 }
@@ -0,0 +1,706 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// 命令的JSON编码仅用于调试工具、日志与管理面板展示协议流量，线上传输始终使用二进制编码
+
+var clientCommandNames = [...]string{
+	ClientCmdPing:            "Ping",
+	ClientCmdAuthenticate:    "Authenticate",
+	ClientCmdChat:            "Chat",
+	ClientCmdTouches:         "Touches",
+	ClientCmdJudges:          "Judges",
+	ClientCmdCreateRoom:      "CreateRoom",
+	ClientCmdJoinRoom:        "JoinRoom",
+	ClientCmdLeaveRoom:       "LeaveRoom",
+	ClientCmdLockRoom:        "LockRoom",
+	ClientCmdCycleRoom:       "CycleRoom",
+	ClientCmdSelectChart:     "SelectChart",
+	ClientCmdRequestStart:    "RequestStart",
+	ClientCmdReady:           "Ready",
+	ClientCmdCancelReady:     "CancelReady",
+	ClientCmdPlayed:          "Played",
+	ClientCmdAbort:           "Abort",
+	ClientCmdQueueJoin:       "QueueJoin",
+	ClientCmdOverflowRoom:    "OverflowRoom",
+	ClientCmdLoadProgress:    "LoadProgress",
+	ClientCmdSwitchRole:      "SwitchRole",
+	ClientCmdGlobalChat:      "GlobalChat",
+	ClientCmdGlobalSubscribe: "GlobalSubscribe",
+	ClientCmdSetMaxMonitors:  "SetMaxMonitors",
+	ClientCmdSubmitResult:    "SubmitResult",
+	ClientCmdReauthenticate:  "Reauthenticate",
+	ClientCmdUpdateProfile:   "UpdateProfile",
+	ClientCmdMonitorChat:     "MonitorChat",
+	ClientCmdFrameBatch:      "FrameBatch",
+	ClientCmdValidateChart:   "ValidateChart",
+	ClientCmdSetRanking:      "SetRanking",
+	ClientCmdScoreUpdate:     "ScoreUpdate",
+	ClientCmdAck:             "Ack",
+	ClientCmdEmote:           "Emote",
+	ClientCmdSetSchedule:     "SetSchedule",
+	ClientCmdListRooms:       "ListRooms",
+	ClientCmdShareStats:      "ShareStats",
+	ClientCmdTransferHost:    "TransferHost",
+	ClientCmdChatHistory:     "ChatHistory",
+	ClientCmdKick:            "Kick",
+	ClientCmdSetPassword:     "SetPassword",
+	ClientCmdSetTeam:         "SetTeam",
+}
+
+var serverCommandNames = [...]string{
+	ServerCmdPong:            "Pong",
+	ServerCmdAuthenticate:    "Authenticate",
+	ServerCmdChat:            "Chat",
+	ServerCmdTouches:         "Touches",
+	ServerCmdJudges:          "Judges",
+	ServerCmdMessage:         "Message",
+	ServerCmdChangeState:     "ChangeState",
+	ServerCmdChangeHost:      "ChangeHost",
+	ServerCmdCreateRoom:      "CreateRoom",
+	ServerCmdJoinRoom:        "JoinRoom",
+	ServerCmdOnJoinRoom:      "OnJoinRoom",
+	ServerCmdLeaveRoom:       "LeaveRoom",
+	ServerCmdLockRoom:        "LockRoom",
+	ServerCmdCycleRoom:       "CycleRoom",
+	ServerCmdSelectChart:     "SelectChart",
+	ServerCmdRequestStart:    "RequestStart",
+	ServerCmdReady:           "Ready",
+	ServerCmdCancelReady:     "CancelReady",
+	ServerCmdPlayed:          "Played",
+	ServerCmdAbort:           "Abort",
+	ServerCmdQueueJoin:       "QueueJoin",
+	ServerCmdQueueUpdate:     "QueueUpdate",
+	ServerCmdOverflowRoom:    "OverflowRoom",
+	ServerCmdLoadProgress:    "LoadProgress",
+	ServerCmdSwitchRole:      "SwitchRole",
+	ServerCmdGlobalChat:      "GlobalChat",
+	ServerCmdGlobalSubscribe: "GlobalSubscribe",
+	ServerCmdSetMaxMonitors:  "SetMaxMonitors",
+	ServerCmdSubmitResult:    "SubmitResult",
+	ServerCmdReauthRequired:  "ReauthRequired",
+	ServerCmdReauthenticate:  "Reauthenticate",
+	ServerCmdUpdateProfile:   "UpdateProfile",
+	ServerCmdProfileUpdated:  "ProfileUpdated",
+	ServerCmdMonitorChat:     "MonitorChat",
+	ServerCmdRoomClosed:      "RoomClosed",
+	ServerCmdValidateChart:   "ValidateChart",
+	ServerCmdSetRanking:      "SetRanking",
+	ServerCmdScoreUpdate:     "ScoreUpdate",
+	ServerCmdEmote:           "Emote",
+	ServerCmdSetSchedule:     "SetSchedule",
+	ServerCmdListRooms:       "ListRooms",
+	ServerCmdShareStats:      "ShareStats",
+	ServerCmdTransferHost:    "TransferHost",
+	ServerCmdChatHistory:     "ChatHistory",
+	ServerCmdKick:            "Kick",
+	ServerCmdKicked:          "Kicked",
+	ServerCmdSetPassword:     "SetPassword",
+	ServerCmdSetTeam:         "SetTeam",
+	ServerCmdDisconnect:      "Disconnect",
+}
+
+var messageNames = [...]string{
+	MsgChat:         "Chat",
+	MsgCreateRoom:   "CreateRoom",
+	MsgJoinRoom:     "JoinRoom",
+	MsgLeaveRoom:    "LeaveRoom",
+	MsgNewHost:      "NewHost",
+	MsgSelectChart:  "SelectChart",
+	MsgGameStart:    "GameStart",
+	MsgReady:        "Ready",
+	MsgCancelReady:  "CancelReady",
+	MsgCancelGame:   "CancelGame",
+	MsgStartPlaying: "StartPlaying",
+	MsgPlayed:       "Played",
+	MsgGameEnd:      "GameEnd",
+	MsgAbort:        "Abort",
+	MsgLockRoom:     "LockRoom",
+	MsgCycleRoom:    "CycleRoom",
+	MsgLiveRoom:     "LiveRoom",
+	MsgMonitorChat:  "MonitorChat",
+	MsgEmote:        "Emote",
+	MsgTeamChange:   "TeamChange",
+}
+
+var roomStateNames = [...]string{
+	RoomStateSelectChart:     "SelectChart",
+	RoomStateWaitingForReady: "WaitingForReady",
+	RoomStatePlaying:         "Playing",
+}
+
+var judgementNames = [...]string{
+	JudgementPerfect:      "Perfect",
+	JudgementGood:         "Good",
+	JudgementBad:          "Bad",
+	JudgementMiss:         "Miss",
+	JudgementHoldPerfect:  "HoldPerfect",
+	JudgementHoldGood:     "HoldGood",
+	JudgementFlickPerfect: "FlickPerfect",
+	JudgementFlickMiss:    "FlickMiss",
+}
+
+// judgementFlagNames 早晚标记的名称，以 "+" 连接在判定类型名称之后（如 "Good+Early"）
+var judgementFlagNames = []struct {
+	flag Judgement
+	name string
+}{
+	{JudgementEarly, "Early"},
+	{JudgementLate, "Late"},
+}
+
+var kickReasonNames = [...]string{
+	KickReasonHost:       "Host",
+	KickReasonAdmin:      "Admin",
+	KickReasonBanned:     "Banned",
+	KickReasonRoomBanned: "RoomBanned",
+	KickReasonDisbanded:  "Disbanded",
+}
+
+// enumName 按编号取名称，未知编号显示为数字
+func enumName(names []string, v uint8) string {
+	if int(v) < len(names) && names[v] != "" {
+		return names[v]
+	}
+	return fmt.Sprintf("%d", v)
+}
+
+// parseEnum 按名称（或数字）解析编号
+func parseEnum(names []string, kind string, text []byte) (uint8, error) {
+	s := string(text)
+	for i, name := range names {
+		if name == s {
+			return uint8(i), nil
+		}
+	}
+	var v uint8
+	if _, err := fmt.Sscanf(s, "%d", &v); err == nil {
+		return v, nil
+	}
+	return 0, fmt.Errorf("unknown %s: %q", kind, s)
+}
+
+func (t ClientCommandType) String() string { return enumName(clientCommandNames[:], uint8(t)) }
+func (t ServerCommandType) String() string { return enumName(serverCommandNames[:], uint8(t)) }
+func (t MessageType) String() string       { return enumName(messageNames[:], uint8(t)) }
+func (t RoomStateType) String() string     { return enumName(roomStateNames[:], uint8(t)) }
+func (k KickReason) String() string        { return enumName(kickReasonNames[:], uint8(k)) }
+
+func (t ClientCommandType) MarshalText() ([]byte, error) { return []byte(t.String()), nil }
+func (t ServerCommandType) MarshalText() ([]byte, error) { return []byte(t.String()), nil }
+func (t MessageType) MarshalText() ([]byte, error)       { return []byte(t.String()), nil }
+func (t RoomStateType) MarshalText() ([]byte, error)     { return []byte(t.String()), nil }
+func (j Judgement) MarshalText() ([]byte, error)         { return []byte(j.String()), nil }
+func (k KickReason) MarshalText() ([]byte, error)        { return []byte(k.String()), nil }
+
+func (t *ClientCommandType) UnmarshalText(text []byte) error {
+	v, err := parseEnum(clientCommandNames[:], "client command type", text)
+	*t = ClientCommandType(v)
+	return err
+}
+
+func (t *ServerCommandType) UnmarshalText(text []byte) error {
+	v, err := parseEnum(serverCommandNames[:], "server command type", text)
+	*t = ServerCommandType(v)
+	return err
+}
+
+func (t *MessageType) UnmarshalText(text []byte) error {
+	v, err := parseEnum(messageNames[:], "message type", text)
+	*t = MessageType(v)
+	return err
+}
+
+func (t *RoomStateType) UnmarshalText(text []byte) error {
+	v, err := parseEnum(roomStateNames[:], "room state", text)
+	*t = RoomStateType(v)
+	return err
+}
+
+func (j Judgement) String() string {
+	name := enumName(judgementNames[:], uint8(j.Base()))
+	for _, f := range judgementFlagNames {
+		if j&f.flag != 0 {
+			name += "+" + f.name
+		}
+	}
+	return name
+}
+
+func (j *Judgement) UnmarshalText(text []byte) error {
+	parts := strings.Split(string(text), "+")
+	v, err := parseEnum(judgementNames[:], "judgement", []byte(parts[0]))
+	*j = Judgement(v)
+	if err != nil {
+		return err
+	}
+	for _, part := range parts[1:] {
+		found := false
+		for _, f := range judgementFlagNames {
+			if part == f.name {
+				*j |= f.flag
+				found = true
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown judgement flag: %q", part)
+		}
+	}
+	return nil
+}
+
+func (k *KickReason) UnmarshalText(text []byte) error {
+	v, err := parseEnum(kickReasonNames[:], "kick reason", text)
+	*k = KickReason(v)
+	return err
+}
+
+// MarshalText 房间ID编码为字符串
+func (r RoomId) MarshalText() ([]byte, error) {
+	return []byte(r.Value), nil
+}
+
+func (r *RoomId) UnmarshalText(text []byte) error {
+	id, err := NewRoomId(string(text))
+	if err != nil {
+		return err
+	}
+	*r = id
+	return nil
+}
+
+// compactPosJSON 坐标以浮点数展示
+type compactPosJSON struct {
+	X float32 `json:"x"`
+	Y float32 `json:"y"`
+}
+
+func (p CompactPos) MarshalJSON() ([]byte, error) {
+	return json.Marshal(compactPosJSON{X: p.XFloat(), Y: p.YFloat()})
+}
+
+func (p *CompactPos) UnmarshalJSON(data []byte) error {
+	var v compactPosJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*p = NewCompactPos(v.X, v.Y)
+	return nil
+}
+
+// MarshalJSON 成功时为 {"ok": 值}，失败时为 {"err": 错误信息, "code": 错误码}（没有对应错误码时省略 code）
+func (r Result[T]) MarshalJSON() ([]byte, error) {
+	if r.Err != nil {
+		v := struct {
+			Err  string    `json:"err"`
+			Code ErrorCode `json:"code,omitempty"`
+		}{*r.Err, r.ErrorCode()}
+		return json.Marshal(v)
+	}
+	return json.Marshal(map[string]*T{"ok": r.Ok})
+}
+
+func (r *Result[T]) UnmarshalJSON(data []byte) error {
+	var v struct {
+		Ok   *T        `json:"ok"`
+		Err  *string   `json:"err"`
+		Code ErrorCode `json:"code"`
+	}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	r.Ok, r.Err = v.Ok, v.Err
+	if r.Err != nil {
+		r.Code = explicitErrorCode(v.Code, *r.Err)
+	}
+	if r.Ok == nil && r.Err == nil {
+		r.Ok = new(T)
+	}
+	return nil
+}
+
+// clientCommandJSON 客户端命令的JSON形式（字段含义见 ClientCommand）
+type clientCommandJSON struct {
+	Type        ClientCommandType `json:"type"`
+	Token       string            `json:"token,omitempty"`
+	Message     string            `json:"message,omitempty"`
+	Frames      []TouchFrame      `json:"frames,omitempty"`
+	Judges      []JudgeEvent      `json:"judges,omitempty"`
+	RoomId      *RoomId           `json:"room,omitempty"`
+	Monitor     *bool             `json:"monitor,omitempty"`
+	Lock        *bool             `json:"lock,omitempty"`
+	Cycle       *bool             `json:"cycle,omitempty"`
+	Overflow    *bool             `json:"overflow,omitempty"`
+	Progress    *uint8            `json:"progress,omitempty"`
+	Subscribe   *bool             `json:"subscribe,omitempty"`
+	MaxMonitors *uint16           `json:"max_monitors,omitempty"`
+	ChartID     *int64            `json:"chart_id,omitempty"`
+	RecordID    *int64            `json:"record_id,omitempty"`
+	Payload     string            `json:"payload,omitempty"`
+	Name        string            `json:"name,omitempty"`
+	Avatar      string            `json:"avatar,omitempty"`
+	Score       *LiveScore        `json:"score,omitempty"`
+	Seq         *uint64           `json:"seq,omitempty"`
+	Schedule    *RoomSchedule     `json:"schedule,omitempty"`
+	Page        *uint32           `json:"page,omitempty"`
+	Ping        *PingTimes        `json:"ping,omitempty"`
+	Share       *bool             `json:"share,omitempty"`
+	UserID      *int32            `json:"user_id,omitempty"`
+	Password    string            `json:"password,omitempty"`
+	Private     *bool             `json:"private,omitempty"`
+	Team        *uint8            `json:"team,omitempty"`
+	Count       *uint16           `json:"count,omitempty"`
+	Extensions  Extensions        `json:"ext,omitempty"`
+}
+
+// MarshalJSON 按命令类型只输出相关字段
+func (c ClientCommand) MarshalJSON() ([]byte, error) {
+	v := clientCommandJSON{Type: c.Type, Extensions: c.Extensions}
+	switch c.Type {
+	case ClientCmdAuthenticate, ClientCmdReauthenticate:
+		v.Token = c.Token
+	case ClientCmdChat, ClientCmdGlobalChat, ClientCmdMonitorChat:
+		v.Message = c.Message
+	case ClientCmdTouches:
+		v.Frames = c.Frames
+	case ClientCmdJudges:
+		v.Judges = c.Judges
+	case ClientCmdFrameBatch:
+		v.Frames = c.Frames
+		v.Judges = c.Judges
+	case ClientCmdCreateRoom:
+		v.RoomId = &c.RoomId
+		v.Password = c.Password
+	case ClientCmdQueueJoin:
+		v.RoomId = &c.RoomId
+	case ClientCmdJoinRoom:
+		v.RoomId = &c.RoomId
+		v.Monitor = &c.Monitor
+		v.Password = c.Password
+	case ClientCmdSwitchRole:
+		v.Monitor = &c.Monitor
+	case ClientCmdLockRoom:
+		v.Lock = &c.Lock
+	case ClientCmdCycleRoom:
+		v.Cycle = &c.Cycle
+	case ClientCmdOverflowRoom:
+		v.Overflow = &c.Overflow
+	case ClientCmdLoadProgress:
+		v.Progress = &c.Progress
+	case ClientCmdGlobalSubscribe:
+		v.Subscribe = &c.Subscribe
+	case ClientCmdSetMaxMonitors:
+		v.MaxMonitors = &c.MaxMonitors
+	case ClientCmdSelectChart, ClientCmdValidateChart:
+		v.ChartID = &c.ChartID
+	case ClientCmdPlayed:
+		v.RecordID = &c.RecordID
+	case ClientCmdSubmitResult:
+		v.Payload = c.Payload
+	case ClientCmdUpdateProfile:
+		v.Name = c.Name
+		v.Avatar = c.Avatar
+	case ClientCmdSetRanking, ClientCmdEmote:
+		v.Name = c.Name
+	case ClientCmdScoreUpdate:
+		v.Score = &c.LiveScore
+	case ClientCmdAck:
+		v.Seq = &c.Seq
+	case ClientCmdSetSchedule:
+		v.Schedule = &c.Schedule
+	case ClientCmdListRooms:
+		v.Page = &c.Page
+	case ClientCmdShareStats:
+		v.Share = &c.Share
+	case ClientCmdTransferHost, ClientCmdKick:
+		v.UserID = &c.UserID
+	case ClientCmdChatHistory:
+		v.Count = &c.Count
+	case ClientCmdSetPassword:
+		v.Password = c.Password
+		v.Private = &c.Private
+	case ClientCmdSetTeam:
+		v.UserID = &c.UserID
+		v.Team = &c.Team
+	case ClientCmdPing:
+		if c.Ping != (PingTimes{}) {
+			v.Ping = &c.Ping
+		}
+	}
+	return json.Marshal(v)
+}
+
+func (c *ClientCommand) UnmarshalJSON(data []byte) error {
+	var v clientCommandJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*c = ClientCommand{
+		Type:       v.Type,
+		Token:      v.Token,
+		Message:    v.Message,
+		Frames:     v.Frames,
+		Judges:     v.Judges,
+		Payload:    v.Payload,
+		Name:       v.Name,
+		Avatar:     v.Avatar,
+		Password:   v.Password,
+		Extensions: v.Extensions,
+	}
+	if v.RoomId != nil {
+		c.RoomId = *v.RoomId
+	}
+	setIf(&c.Monitor, v.Monitor)
+	setIf(&c.Private, v.Private)
+	setIf(&c.Team, v.Team)
+	setIf(&c.Lock, v.Lock)
+	setIf(&c.Cycle, v.Cycle)
+	setIf(&c.Overflow, v.Overflow)
+	setIf(&c.Progress, v.Progress)
+	setIf(&c.Subscribe, v.Subscribe)
+	setIf(&c.MaxMonitors, v.MaxMonitors)
+	setIf(&c.ChartID, v.ChartID)
+	setIf(&c.RecordID, v.RecordID)
+	setIf(&c.LiveScore, v.Score)
+	setIf(&c.Seq, v.Seq)
+	setIf(&c.Schedule, v.Schedule)
+	setIf(&c.Page, v.Page)
+	setIf(&c.Ping, v.Ping)
+	setIf(&c.Share, v.Share)
+	setIf(&c.UserID, v.UserID)
+	setIf(&c.Count, v.Count)
+	return nil
+}
+
+// setIf 字段存在时赋值
+func setIf[T any](dst *T, src *T) {
+	if src != nil {
+		*dst = *src
+	}
+}
+
+// serverCommandJSON 服务器命令的JSON形式，各类命令的结果统一放在 result 字段
+type serverCommandJSON struct {
+	Type         ServerCommandType `json:"type"`
+	Player       *int32            `json:"player,omitempty"`
+	Frames       []TouchFrame      `json:"frames,omitempty"`
+	Judges       []JudgeEvent      `json:"judges,omitempty"`
+	Message      *Message          `json:"message,omitempty"`
+	State        *RoomState        `json:"state,omitempty"`
+	IsHost       *bool             `json:"is_host,omitempty"`
+	User         *UserInfo         `json:"user,omitempty"`
+	Queue        *QueueStatus      `json:"queue,omitempty"`
+	LoadProgress *LoadStatus       `json:"load,omitempty"`
+	ReauthGrace  *uint32           `json:"grace,omitempty"`
+	Profile      *ProfileInfo      `json:"profile,omitempty"`
+	Closed       *RoomClosed       `json:"closed,omitempty"`
+	Kicked       *Kicked           `json:"kicked,omitempty"`
+	Disconnect   *Disconnect       `json:"disconnect,omitempty"`
+	Score        *LiveScore        `json:"score,omitempty"`
+	Pong         *PingTimes        `json:"pong,omitempty"`
+	Result       json.RawMessage   `json:"result,omitempty"`
+	Extensions   Extensions        `json:"ext,omitempty"`
+}
+
+// MarshalJSON 按命令类型只输出相关字段
+func (sc ServerCommand) MarshalJSON() ([]byte, error) {
+	v := serverCommandJSON{Type: sc.Type, Extensions: sc.Extensions}
+	var result interface{}
+	switch sc.Type {
+	case ServerCmdPong:
+		if sc.Pong != (PingTimes{}) {
+			v.Pong = &sc.Pong
+		}
+	case ServerCmdTouches:
+		v.Player = &sc.TouchesPlayer
+		v.Frames = sc.TouchesFrames
+	case ServerCmdJudges:
+		v.Player = &sc.JudgesPlayer
+		v.Judges = sc.JudgesEvents
+	case ServerCmdMessage:
+		v.Message = sc.Message
+	case ServerCmdChangeState:
+		v.State = sc.ChangeState
+	case ServerCmdChangeHost:
+		v.IsHost = &sc.ChangeHost
+	case ServerCmdOnJoinRoom:
+		v.User = sc.OnJoinRoomUser
+	case ServerCmdQueueUpdate:
+		v.Queue = sc.QueueUpdate
+	case ServerCmdLoadProgress:
+		v.LoadProgress = sc.LoadProgress
+	case ServerCmdReauthRequired:
+		v.ReauthGrace = &sc.ReauthGrace
+	case ServerCmdProfileUpdated:
+		v.Profile = sc.ProfileUpdated
+	case ServerCmdRoomClosed:
+		v.Closed = sc.RoomClosed
+	case ServerCmdKicked:
+		v.Kicked = sc.Kicked
+	case ServerCmdDisconnect:
+		v.Disconnect = sc.Disconnect
+	case ServerCmdScoreUpdate:
+		v.Player = &sc.ScoreUpdatePlayer
+		v.Score = sc.ScoreUpdate
+	case ServerCmdAuthenticate:
+		if sc.AuthenticateResult != nil {
+			result = sc.AuthenticateResult
+		}
+	case ServerCmdJoinRoom:
+		if sc.JoinRoomResult != nil {
+			result = sc.JoinRoomResult
+		}
+	case ServerCmdSubmitResult:
+		if sc.SubmitResultResult != nil {
+			result = sc.SubmitResultResult
+		}
+	case ServerCmdValidateChart:
+		if sc.ValidateChartResult != nil {
+			result = sc.ValidateChartResult
+		}
+	case ServerCmdListRooms:
+		if sc.ListRoomsResult != nil {
+			result = sc.ListRoomsResult
+		}
+	case ServerCmdChatHistory:
+		if sc.ChatHistoryResult != nil {
+			result = sc.ChatHistoryResult
+		}
+	default:
+		if r := sc.unitResult(); r != nil && *r != nil {
+			result = *r
+		}
+	}
+	if result != nil {
+		data, err := json.Marshal(result)
+		if err != nil {
+			return nil, err
+		}
+		v.Result = data
+	}
+	return json.Marshal(v)
+}
+
+func (sc *ServerCommand) UnmarshalJSON(data []byte) error {
+	var v serverCommandJSON
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*sc = ServerCommand{
+		Type:           v.Type,
+		Message:        v.Message,
+		ChangeState:    v.State,
+		OnJoinRoomUser: v.User,
+		QueueUpdate:    v.Queue,
+		LoadProgress:   v.LoadProgress,
+		ProfileUpdated: v.Profile,
+		RoomClosed:     v.Closed,
+		Kicked:         v.Kicked,
+		Disconnect:     v.Disconnect,
+		Extensions:     v.Extensions,
+	}
+	switch v.Type {
+	case ServerCmdTouches:
+		setIf(&sc.TouchesPlayer, v.Player)
+		sc.TouchesFrames = v.Frames
+	case ServerCmdJudges:
+		setIf(&sc.JudgesPlayer, v.Player)
+		sc.JudgesEvents = v.Judges
+	case ServerCmdScoreUpdate:
+		setIf(&sc.ScoreUpdatePlayer, v.Player)
+		sc.ScoreUpdate = v.Score
+	}
+	setIf(&sc.ChangeHost, v.IsHost)
+	setIf(&sc.ReauthGrace, v.ReauthGrace)
+	setIf(&sc.Pong, v.Pong)
+
+	if len(v.Result) == 0 {
+		return nil
+	}
+	switch v.Type {
+	case ServerCmdAuthenticate:
+		return json.Unmarshal(v.Result, &sc.AuthenticateResult)
+	case ServerCmdJoinRoom:
+		return json.Unmarshal(v.Result, &sc.JoinRoomResult)
+	case ServerCmdSubmitResult:
+		return json.Unmarshal(v.Result, &sc.SubmitResultResult)
+	case ServerCmdValidateChart:
+		return json.Unmarshal(v.Result, &sc.ValidateChartResult)
+	case ServerCmdListRooms:
+		return json.Unmarshal(v.Result, &sc.ListRoomsResult)
+	case ServerCmdChatHistory:
+		return json.Unmarshal(v.Result, &sc.ChatHistoryResult)
+	}
+	r := sc.unitResult()
+	if r == nil {
+		return fmt.Errorf("server command %s has no result", v.Type)
+	}
+	return json.Unmarshal(v.Result, r)
+}
+
+// unitResult 返回无数据结果的命令对应的结果字段
+func (sc *ServerCommand) unitResult() **Result[struct{}] {
+	switch sc.Type {
+	case ServerCmdChat:
+		return &sc.ChatResult
+	case ServerCmdCreateRoom:
+		return &sc.CreateRoomResult
+	case ServerCmdLeaveRoom:
+		return &sc.LeaveRoomResult
+	case ServerCmdLockRoom:
+		return &sc.LockRoomResult
+	case ServerCmdCycleRoom:
+		return &sc.CycleRoomResult
+	case ServerCmdSelectChart:
+		return &sc.SelectChartResult
+	case ServerCmdRequestStart:
+		return &sc.RequestStartResult
+	case ServerCmdReady:
+		return &sc.ReadyResult
+	case ServerCmdCancelReady:
+		return &sc.CancelReadyResult
+	case ServerCmdPlayed:
+		return &sc.PlayedResult
+	case ServerCmdAbort:
+		return &sc.AbortResult
+	case ServerCmdQueueJoin:
+		return &sc.QueueJoinResult
+	case ServerCmdOverflowRoom:
+		return &sc.OverflowRoomResult
+	case ServerCmdSwitchRole:
+		return &sc.SwitchRoleResult
+	case ServerCmdGlobalChat:
+		return &sc.GlobalChatResult
+	case ServerCmdGlobalSubscribe:
+		return &sc.GlobalSubscribeResult
+	case ServerCmdSetMaxMonitors:
+		return &sc.SetMaxMonitorsResult
+	case ServerCmdReauthenticate:
+		return &sc.ReauthenticateResult
+	case ServerCmdUpdateProfile:
+		return &sc.UpdateProfileResult
+	case ServerCmdMonitorChat:
+		return &sc.MonitorChatResult
+	case ServerCmdSetRanking:
+		return &sc.SetRankingResult
+	case ServerCmdEmote:
+		return &sc.EmoteResult
+	case ServerCmdSetSchedule:
+		return &sc.SetScheduleResult
+	case ServerCmdShareStats:
+		return &sc.ShareStatsResult
+	case ServerCmdTransferHost:
+		return &sc.TransferHostResult
+	case ServerCmdKick:
+		return &sc.KickResult
+	case ServerCmdSetPassword:
+		return &sc.SetPasswordResult
+	case ServerCmdSetTeam:
+		return &sc.SetTeamResult
+	}
+	return nil
+}
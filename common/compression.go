@@ -0,0 +1,54 @@
+package common
+
+import (
+	"compress/flate"
+	"io"
+	"net"
+	"strings"
+)
+
+// StreamFeatures 连接可选特性（在协商握手中声明，由服务器决定是否启用）
+type StreamFeatures uint8
+
+const (
+	// FeatureDeflate 整条连接使用流式deflate压缩，每帧写入后同步刷新
+	// 同一连接的帧共享压缩字典，高频的小Touches帧压缩效果明显
+	FeatureDeflate StreamFeatures = 1 << 0
+
+	// FeatureChecksum 每帧末尾附加CRC32校验，损坏的帧会使连接立即断开，而不是读错位后解出未知命令
+	FeatureChecksum StreamFeatures = 1 << 1
+)
+
+// String 特性名称（用于日志与管理员接口），多个特性以逗号分隔
+func (f StreamFeatures) String() string {
+	var names []string
+	if f&FeatureDeflate != 0 {
+		names = append(names, "deflate")
+	}
+	if f&FeatureChecksum != 0 {
+		names = append(names, "crc32")
+	}
+	return strings.Join(names, ",")
+}
+
+// streamCodec 连接读写层，启用压缩时包装底层连接
+type streamCodec struct {
+	r io.Reader
+	w io.Writer
+	// flush 每帧写入后调用（未压缩时为nil）
+	flush func() error
+}
+
+// newStreamCodec 根据启用的特性创建读写层
+func newStreamCodec(conn net.Conn, features StreamFeatures) streamCodec {
+	if features&FeatureDeflate == 0 {
+		return streamCodec{r: conn, w: conn}
+	}
+	// BestSpeed：压缩发生在发送循环中，优先保证延迟
+	fw, _ := flate.NewWriter(conn, flate.BestSpeed)
+	return streamCodec{
+		r:     flate.NewReader(conn),
+		w:     fw,
+		flush: fw.Flush,
+	}
+}
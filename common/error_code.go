@@ -0,0 +1,166 @@
+package common
+
+import "strings"
+
+// ErrorCode 失败结果的错误码（V12起随 Result 下发），便于客户端按错误码本地化提示
+// 错误码只能在末尾追加，不能修改已有的值
+type ErrorCode uint16
+
+const (
+	ErrCodeOther                ErrorCode = iota // 其他错误，客户端应直接显示错误信息
+	ErrCodeNotInRoom                             // 不在房间中
+	ErrCodeAlreadyInRoom                         // 已在房间中
+	ErrCodeRoomNotFound                          // 房间不存在
+	ErrCodeRoomExists                            // 房间ID已被占用
+	ErrCodeRoomFull                              // 房间已满
+	ErrCodeRoomLocked                            // 房间已锁定
+	ErrCodeRoomCreationDisabled                  // 房间创建已被禁用
+	ErrCodeBanned                                // 用户已被封禁
+	ErrCodeBannedFromRoom                        // 已被禁止进入该房间
+	ErrCodeSameIP                                // 同一IP的用户已在房间中
+	ErrCodeInvalidState                          // 无效状态
+	ErrCodeGamePlaying                           // 游戏进行中
+	ErrCodeNotPlaying                            // 未在游戏中
+	ErrCodeNotHost                               // 只有房主可以执行该操作
+	ErrCodeCannotMonitor                         // 无法观察
+	ErrCodeMonitorLimit                          // 房间观察者已达上限
+	ErrCodeGuestNotAllowed                       // 游客不能执行该操作
+	ErrCodeChartNotFound                         // 谱面不存在
+	ErrCodeChartFixed                            // 该房间谱面已固定
+	ErrCodeChartRestricted                       // 谱面定数不符合房间限制
+	ErrCodeChartNotSelected                      // 未选择谱面
+	ErrCodeBusy                                  // 服务器繁忙或请求处理中，请稍后重试
+	ErrCodeAlreadyReady                          // 已准备
+	ErrCodeNotReady                              // 未准备
+	ErrCodeAlreadyAborted                        // 已放弃
+	ErrCodeAlreadyUploaded                       // 已上传
+	ErrCodeInvalidRecord                         // 无效记录或成绩数据
+	ErrCodeRecordUsed                            // 成绩已被使用
+	ErrCodeAuthFailed                            // 认证失败
+	ErrCodeRateLimited                           // 操作过于频繁
+	ErrCodeDisabled                              // 服务器未开启该功能
+	ErrCodeInvalidArgument                       // 参数无效（消息为空、名称过长等）
+	ErrCodeQueueFull                             // 等待队列已满
+	ErrCodeRejected                              // 被服务器的自定义规则拒绝，错误信息为拒绝原因
+	ErrCodeEmoteNotAllowed                       // 表情不在服务器允许的列表中
+	ErrCodeRoomNotOpen                           // 房间尚未到开放时间
+	ErrCodeWrongPassword                         // 房间密码错误
+)
+
+// errorMessages 各错误码对应的服务器错误信息
+// 第一条为默认信息（客户端收到的信息为空时使用），含格式化参数的信息按 % 之前的前缀匹配
+var errorMessages = []struct {
+	code    ErrorCode
+	message string
+}{
+	{ErrCodeNotInRoom, "不在房间中"},
+	{ErrCodeAlreadyInRoom, "已在房间中"},
+	{ErrCodeRoomNotFound, "房间不存在"},
+	{ErrCodeRoomExists, "房间ID已被占用"},
+	{ErrCodeRoomFull, "房间已满"},
+	{ErrCodeRoomLocked, "房间已锁定"},
+	{ErrCodeRoomLocked, "回放房间只能以观察者身份加入"},
+	{ErrCodeRoomLocked, "比赛房间只有白名单中的玩家可以参赛"},
+	{ErrCodeRoomCreationDisabled, "房间创建已被禁用"},
+	{ErrCodeBanned, "用户已被封禁"},
+	{ErrCodeBannedFromRoom, "已被禁止进入该房间"},
+	{ErrCodeSameIP, "同一IP的用户已在房间中"},
+	{ErrCodeInvalidState, "无效状态"},
+	{ErrCodeGamePlaying, "游戏进行中"},
+	{ErrCodeGamePlaying, "游戏中不能修改排名方式"},
+	{ErrCodeGamePlaying, "游戏中不能调整队伍"},
+	{ErrCodeNotPlaying, "未在游戏中"},
+	{ErrCodeNotHost, "只有房主可以执行该操作"},
+	{ErrCodeNotHost, "只有房主可以%s"},
+	{ErrCodeNotHost, "房主不能转为观察者"},
+	{ErrCodeCannotMonitor, "无法观察"},
+	{ErrCodeCannotMonitor, "只有观察者可以使用观察者聊天"},
+	{ErrCodeMonitorLimit, "房间观察者已达上限"},
+	{ErrCodeMonitorLimit, "观察者上限不能超过 %d"},
+	{ErrCodeGuestNotAllowed, "游客不能执行该操作"},
+	{ErrCodeGuestNotAllowed, "游客不能%s"},
+	{ErrCodeGuestNotAllowed, "游客只能以观察者身份加入"},
+	{ErrCodeGuestNotAllowed, "游客无需认证"},
+	{ErrCodeGuestNotAllowed, "该IP的游客连接数已达上限"},
+	{ErrCodeChartNotFound, "谱面不存在"},
+	{ErrCodeChartFixed, "该房间谱面已固定"},
+	{ErrCodeChartRestricted, "谱面定数不符合房间限制"},
+	{ErrCodeChartRestricted, "该房间只能选择定数不低于 %.1f 的谱面"},
+	{ErrCodeChartRestricted, "该房间只能选择定数不高于 %.1f 的谱面"},
+	{ErrCodeChartNotSelected, "未选择谱面"},
+	{ErrCodeBusy, "服务器繁忙，请稍后重试"},
+	{ErrCodeBusy, "谱面查询中"},
+	{ErrCodeBusy, "成绩确认中"},
+	{ErrCodeBusy, "服务器负载过高，暂不接受新的观察者"},
+	{ErrCodeBusy, "服务器负载过高，暂时无法创建房间"},
+	{ErrCodeAlreadyReady, "已准备"},
+	{ErrCodeNotReady, "未准备"},
+	{ErrCodeAlreadyAborted, "已放弃"},
+	{ErrCodeAlreadyUploaded, "已上传"},
+	{ErrCodeInvalidRecord, "无效记录"},
+	{ErrCodeInvalidRecord, "记录不存在"},
+	{ErrCodeInvalidRecord, "成绩数据无效"},
+	{ErrCodeRecordUsed, "成绩已被使用"},
+	{ErrCodeAuthFailed, "认证失败"},
+	{ErrCodeAuthFailed, "用户不匹配"},
+	{ErrCodeRateLimited, "操作过于频繁，请稍后再试"},
+	{ErrCodeRateLimited, "发言过于频繁，请 %d 秒后再试"},
+	{ErrCodeRateLimited, "修改过于频繁，请稍后再试"},
+	{ErrCodeRateLimited, "密码错误次数过多，请 %d 秒后再试"},
+	{ErrCodeDisabled, "服务器未开启该功能"},
+	{ErrCodeDisabled, "排队功能未启用"},
+	{ErrCodeDisabled, "服务器未启用成绩代提交"},
+	{ErrCodeDisabled, "该服务器未开启观察者聊天"},
+	{ErrCodeDisabled, "服务器不允许修改观察者上限"},
+	{ErrCodeDisabled, "没有全服频道发言权限"},
+	{ErrCodeInvalidArgument, "参数无效"},
+	{ErrCodeInvalidArgument, "消息为空"},
+	{ErrCodeInvalidArgument, "身份未改变"},
+	{ErrCodeInvalidArgument, "资料包含非法字符"},
+	{ErrCodeInvalidArgument, "名称不能超过 %d 个字符"},
+	{ErrCodeInvalidArgument, "名称与房间内其他玩家重复"},
+	{ErrCodeInvalidArgument, "未知的排名方式: %s"},
+	{ErrCodeInvalidArgument, "无效的开放时间"},
+	{ErrCodeInvalidArgument, "目标玩家不在房间中"},
+	{ErrCodeInvalidArgument, "不能移交给观察者"},
+	{ErrCodeInvalidArgument, "目标玩家已断线"},
+	{ErrCodeInvalidArgument, "已是房主"},
+	{ErrCodeInvalidArgument, "不能移出自己"},
+	{ErrCodeInvalidArgument, "无效的队伍编号"},
+	{ErrCodeInvalidArgument, "观察者不能加入队伍"},
+	{ErrCodeQueueFull, "等待队列已满"},
+	{ErrCodeQueueFull, "已在排队中"},
+	{ErrCodeRejected, "被服务器规则拒绝"},
+	{ErrCodeEmoteNotAllowed, "不支持的表情"},
+	{ErrCodeRoomNotOpen, "房间尚未开放"},
+	{ErrCodeWrongPassword, "房间密码错误"},
+	{ErrCodeWrongPassword, "该房间需要密码，请直接加入"},
+}
+
+// Message 错误码的默认错误信息，未知错误码返回空字符串
+func (c ErrorCode) Message() string {
+	for _, m := range errorMessages {
+		if m.code == c {
+			return m.message
+		}
+	}
+	return ""
+}
+
+// LookupErrorCode 按服务器错误信息查找错误码，没有对应的错误码时返回 ErrCodeOther
+func LookupErrorCode(message string) ErrorCode {
+	code, best := ErrCodeOther, 0
+	for _, m := range errorMessages {
+		i := strings.IndexByte(m.message, '%')
+		if i < 0 {
+			if message == m.message {
+				return m.code
+			}
+			continue
+		}
+		if prefix := m.message[:i]; len(prefix) > best && strings.HasPrefix(message, prefix) {
+			code, best = m.code, len(prefix)
+		}
+	}
+	return code
+}
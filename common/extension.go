@@ -0,0 +1,147 @@
+package common
+
+import "fmt"
+
+// MaxExtensions 单条命令最多携带的扩展字段数量
+const MaxExtensions = 16
+
+// ExtensionTag 扩展字段标签
+// 新的可选数据分配新标签追加在命令末尾，不识别该标签的对端直接忽略
+type ExtensionTag uint32
+
+// 已分配的扩展字段标签
+const (
+	ExtChartPreview ExtensionTag = 1 // MsgSelectChart 所在的 ServerCommand：谱面预览（ChartPreview）
+	ExtChartLevel   ExtensionTag = 2 // MsgSelectChart 所在的 ServerCommand：难度名称与等级（ChartLevel）
+	ExtRoomHost     ExtensionTag = 3 // JoinRoom 成功结果与重连时带房间状态的 Authenticate 结果：当前房主（UserInfo）
+	ExtAckSeq       ExtensionTag = 4 // 服务器开启命令确认时的 ChangeState、ChangeHost：确认序号（ULEB128），客户端（V14起）以 Ack 回复
+)
+
+// Extension 命令末尾的 TLV 扩展字段
+type Extension struct {
+	Tag  ExtensionTag `json:"tag"`
+	Data []byte       `json:"data"`
+}
+
+// Extensions 命令末尾的扩展块，编码为：数量（ULEB128）后接各字段的标签（ULEB128）、长度（ULEB128）与数据
+// 仅在协议版本支持（V6起）且不为空时写入；没有剩余数据时视为空
+type Extensions []Extension
+
+// Get 获取标签对应的数据
+func (e Extensions) Get(tag ExtensionTag) ([]byte, bool) {
+	for _, ext := range e {
+		if ext.Tag == tag {
+			return ext.Data, true
+		}
+	}
+	return nil, false
+}
+
+// Set 设置标签对应的数据（已存在时覆盖）
+func (e *Extensions) Set(tag ExtensionTag, data []byte) {
+	for i := range *e {
+		if (*e)[i].Tag == tag {
+			(*e)[i].Data = data
+			return
+		}
+	}
+	*e = append(*e, Extension{Tag: tag, Data: data})
+}
+
+// SetValue 将 BinaryData 编码后设置为标签对应的数据
+func (e *Extensions) SetValue(tag ExtensionTag, v BinaryData) error {
+	w := NewBinaryWriter()
+	if err := v.WriteBinary(w); err != nil {
+		return err
+	}
+	e.Set(tag, w.Data())
+	return nil
+}
+
+// Value 将标签对应的数据解码到 v，标签不存在时返回 false
+func (e Extensions) Value(tag ExtensionTag, v BinaryData) (bool, error) {
+	data, ok := e.Get(tag)
+	if !ok {
+		return false, nil
+	}
+	if err := DecodeStrict(data, v); err != nil {
+		return true, fmt.Errorf("extension %d: %w", tag, err)
+	}
+	return true, nil
+}
+
+// SetUleb 将整数按ULEB128编码后设置为标签对应的数据
+func (e *Extensions) SetUleb(tag ExtensionTag, v uint64) {
+	w := NewBinaryWriter()
+	w.Uleb(v)
+	e.Set(tag, w.Data())
+}
+
+// Uleb 将标签对应的数据解码为ULEB128整数，标签不存在或数据无效时返回 false
+func (e Extensions) Uleb(tag ExtensionTag) (uint64, bool) {
+	data, ok := e.Get(tag)
+	if !ok {
+		return 0, false
+	}
+	r := NewBinaryReader(data)
+	v, err := r.Uleb()
+	if err != nil || r.Remaining() != 0 {
+		return 0, false
+	}
+	return v, true
+}
+
+// readExtensions 读取命令末尾的扩展块
+func readExtensions(r *BinaryReader) (Extensions, error) {
+	if r.Remaining() == 0 {
+		return nil, nil
+	}
+	count, err := r.Uleb()
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 || count > MaxExtensions {
+		// 空扩展块不会被写入，出现时说明数据错位
+		return nil, fmt.Errorf("invalid extension count: %d", count)
+	}
+	exts := make(Extensions, 0, count)
+	for i := uint64(0); i < count; i++ {
+		tag, err := r.Uleb()
+		if err != nil {
+			return nil, err
+		}
+		if tag > uint64(^ExtensionTag(0)) {
+			return nil, fmt.Errorf("invalid extension tag: %d", tag)
+		}
+		length, err := r.Uleb()
+		if err != nil {
+			return nil, err
+		}
+		if length > uint64(r.Remaining()) {
+			return nil, ErrUnexpectedEOF
+		}
+		data, err := r.Take(int(length))
+		if err != nil {
+			return nil, err
+		}
+		exts = append(exts, Extension{Tag: ExtensionTag(tag), Data: append([]byte(nil), data...)})
+	}
+	return exts, nil
+}
+
+// writeExtensions 写入扩展块，对端不支持或没有扩展字段时不写入
+func writeExtensions(w *BinaryWriter, exts Extensions) error {
+	if !w.Extensions() || len(exts) == 0 {
+		return nil
+	}
+	if len(exts) > MaxExtensions {
+		return fmt.Errorf("too many extensions: %d", len(exts))
+	}
+	w.Uleb(uint64(len(exts)))
+	for _, ext := range exts {
+		w.Uleb(uint64(ext.Tag))
+		w.Uleb(uint64(len(ext.Data)))
+		w.WriteBytes(ext.Data)
+	}
+	return nil
+}
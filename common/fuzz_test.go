@@ -0,0 +1,161 @@
+package common
+
+import (
+	"bytes"
+	"compress/flate"
+	"testing"
+)
+
+// 协议模糊测试：服务器直接解码来自网络的数据，任何输入都不能导致 panic
+// 运行方式：go test ./common -run '^$' -fuzz FuzzClientCommand -fuzztime 1m -fuzzminimizetime 0
+// （较大的输入最小化很慢，会使执行速度长时间停在0，因此关闭最小化）
+// testdata/fuzz 中是畸形输入的回归语料（超长长度、截断、溢出等），发现的崩溃输入也保存在这里，随 go test 运行
+
+// fuzzClientSeeds 各类型客户端命令的编码，作为模糊测试的初始语料
+func fuzzClientSeeds() [][]byte {
+	roomID, _ := NewRoomId("fuzz")
+	cmds := []ClientCommand{
+		{Type: ClientCmdPing},
+		{Type: ClientCmdAuthenticate, Token: "token"},
+		{Type: ClientCmdChat, Message: "hello"},
+		{Type: ClientCmdTouches, Frames: []TouchFrame{{Time: 1, Points: []TouchPoint{{ID: 1, Pos: NewCompactPos(0.5, -0.5)}}}}},
+		{Type: ClientCmdJudges, Judges: []JudgeEvent{{Time: 1, LineID: 2, NoteID: 3, Judgement: JudgementGood}}},
+		{Type: ClientCmdJudges, Judges: []JudgeEvent{{NoteID: 4, Judgement: JudgementFlickPerfect | JudgementLate}}},
+		{Type: ClientCmdCreateRoom, RoomId: roomID},
+		{Type: ClientCmdJoinRoom, RoomId: roomID, Monitor: true},
+		{Type: ClientCmdJoinRoom, RoomId: roomID, Password: "secret"},
+		{Type: ClientCmdSelectChart, ChartID: 1 << 40},
+		{Type: ClientCmdPlayed, RecordID: 42},
+		{Type: ClientCmdLoadProgress, Progress: 50},
+		{Type: ClientCmdFrameBatch, Frames: []TouchFrame{{Time: 2}}, Judges: []JudgeEvent{{Time: 2}}},
+		{Type: ClientCmdSetRanking, Name: "acc"},
+		{Type: ClientCmdScoreUpdate, LiveScore: LiveScore{Score: 1000, Combo: 10, Accuracy: 0.99}},
+		{Type: ClientCmdAck, Seq: 1 << 33},
+		{Type: ClientCmdEmote, Name: "gg"},
+		{Type: ClientCmdListRooms, Page: 3},
+		{Type: ClientCmdShareStats, Share: true},
+		{Type: ClientCmdTransferHost, UserID: -1000001},
+		{Type: ClientCmdChatHistory, Count: 20},
+		{Type: ClientCmdKick, UserID: 2},
+		{Type: ClientCmdSetPassword, Password: "secret", Private: true},
+		{Type: ClientCmdSetTeam, UserID: 2, Team: 1},
+		{Type: ClientCmdPing, Ping: PingTimes{Time: 1 << 40, Echo: 1<<40 - 30, Hold: 12}},
+		{Type: ClientCmdSetSchedule, Schedule: RoomSchedule{OpenAt: 1 << 40, CloseAt: 1<<40 + 3600000, Lock: true}},
+	}
+	var seeds [][]byte
+	for _, cmd := range cmds {
+		w := NewBinaryWriter()
+		if err := cmd.WriteBinary(w); err == nil {
+			seeds = append(seeds, w.Data())
+		}
+	}
+	return seeds
+}
+
+// fuzzServerSeeds 各类型服务器命令的编码，作为模糊测试的初始语料
+func fuzzServerSeeds() [][]byte {
+	errMsg := "房间已锁定"
+	cmds := []ServerCommand{
+		{Type: ServerCmdPong},
+		{Type: ServerCmdAuthenticate, AuthenticateResult: &Result[AuthResult]{Ok: &AuthResult{User: UserInfo{ID: 1, Name: "A"}}}},
+		{Type: ServerCmdJoinRoom, JoinRoomResult: &Result[JoinRoomResponse]{Ok: &JoinRoomResponse{Users: []UserInfo{{ID: 1, Name: "A"}}, ReadyUsers: []int32{1}, Teams: map[int32]uint8{1: 2}}}},
+		{Type: ServerCmdLockRoom, LockRoomResult: &Result[struct{}]{Err: &errMsg}},
+		{Type: ServerCmdMessage, Message: &Message{Type: MsgPlayed, User: 1, Score: 990000, Accuracy: 0.99, Perfect: 100}},
+		{Type: ServerCmdMessage, Message: &Message{Type: MsgSelectChart, User: 1, Name: "chart", ChartID: 7}},
+		{Type: ServerCmdMessage, Message: &Message{Type: MsgTeamChange, User: 1, Team: 2}},
+		{Type: ServerCmdTouches, TouchesPlayer: 1, TouchesFrames: []TouchFrame{{Time: 1}}},
+		{Type: ServerCmdScoreUpdate, ScoreUpdatePlayer: 1, ScoreUpdate: &LiveScore{Score: 1}},
+		{Type: ServerCmdListRooms, ListRoomsResult: &Result[RoomList]{Ok: &RoomList{Rooms: []RoomListEntry{{Host: UserInfo{ID: 1, Name: "A"}, Players: 1, MaxPlayers: 8}}, Total: 1}}},
+		{Type: ServerCmdChatHistory, ChatHistoryResult: &Result[ChatHistory]{Ok: &ChatHistory{Messages: []Message{{Type: MsgChat, User: 1, Content: "hi", Time: 1 << 40}, {Type: MsgGameEnd}}}}},
+		{Type: ServerCmdKicked, Kicked: &Kicked{RoomId: &RoomId{Value: "room"}, Reason: KickReasonRoomBanned, Message: "违规"}},
+		{Type: ServerCmdDisconnect, Disconnect: &Disconnect{Reason: DisconnectReasonHeartbeatTimeout, Message: "心跳超时"}},
+	}
+	var seeds [][]byte
+	for _, cmd := range cmds {
+		preview := ChartPreview{ID: 7, Name: "chart"}
+		cmd.Extensions.SetValue(ExtChartPreview, &preview)
+		w := NewBinaryWriter()
+		if err := cmd.WriteBinary(w); err == nil {
+			seeds = append(seeds, w.Data())
+		}
+	}
+	return seeds
+}
+
+// FuzzClientCommand 服务器解码客户端命令（各协议版本），解码成功的命令重新编码后必须能再次解码
+func FuzzClientCommand(f *testing.F) {
+	for _, seed := range fuzzClientSeeds() {
+		f.Add(seed, ProtocolLatest)
+		f.Add(seed, ProtocolV1)
+	}
+	f.Fuzz(func(t *testing.T, data []byte, version uint8) {
+		shim := shimFor(version)
+		cmd, err := shim.decodeClient(data)
+		if err != nil {
+			return
+		}
+		w := shim.writer()
+		defer ReleaseBinaryWriter(w)
+		if err := cmd.WriteBinary(w); err != nil {
+			return
+		}
+		if _, err := shim.decodeClient(w.Data()); err != nil {
+			t.Fatalf("重新编码的命令无法解码: %v\n原始数据 %x\n重新编码 %x", err, data, w.Data())
+		}
+	})
+}
+
+// FuzzServerCommand 客户端解码服务器命令：严格模式（各协议版本）与兼容旧行为的非严格模式
+func FuzzServerCommand(f *testing.F) {
+	for _, seed := range fuzzServerSeeds() {
+		f.Add(seed, ProtocolLatest)
+		f.Add(seed, ProtocolV5)
+	}
+	f.Fuzz(func(t *testing.T, data []byte, version uint8) {
+		shimFor(version).decodeServer(data)
+
+		var cmd ServerCommand
+		r := AcquireBinaryReader(data)
+		defer ReleaseBinaryReader(r)
+		cmd.ReadBinary(r)
+	})
+}
+
+// FuzzStreamFrames 连接读取层：长度前缀分帧，可选deflate压缩与CRC32校验，读出的每一帧按最新协议解码
+func FuzzStreamFrames(f *testing.F) {
+	for _, seed := range fuzzClientSeeds() {
+		f.Add(fuzzFrame(seed, false), uint8(0))
+		f.Add(fuzzFrame(seed, true), uint8(FeatureChecksum))
+	}
+	f.Add([]byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x01}, uint8(0)) // 过长的长度前缀
+	f.Add([]byte{0xff, 0xff, 0xff, 0x7f}, uint8(0))             // 超过上限的帧长度
+	f.Add([]byte{0x02, 0x00}, uint8(FeatureChecksum))           // 短于校验和的帧
+	f.Add([]byte{0x01, 0x00}, uint8(FeatureDeflate))            // 不是deflate数据
+	f.Fuzz(func(t *testing.T, data []byte, features uint8) {
+		s := &Stream{features: StreamFeatures(features) & (FeatureDeflate | FeatureChecksum)}
+		s.codec.r = bytes.NewReader(data)
+		if s.features&FeatureDeflate != 0 {
+			s.codec.r = flate.NewReader(bytes.NewReader(data))
+		}
+		shim := shimFor(ProtocolLatest)
+		// 未压缩时每帧至少消耗一个字节；压缩时按输入长度限制读取次数，避免解压出的大量空帧拖慢测试
+		for i := 0; i <= len(data); i++ {
+			frame, err := s.readData()
+			if err != nil {
+				return
+			}
+			shim.decodeClient(frame)
+		}
+	})
+}
+
+// fuzzFrame 按连接格式封装一帧（长度前缀，可选CRC32校验）
+func fuzzFrame(payload []byte, checksum bool) []byte {
+	var buf bytes.Buffer
+	s := &Stream{codec: streamCodec{w: &buf}}
+	if checksum {
+		s.features = FeatureChecksum
+	}
+	s.writeData(payload)
+	return buf.Bytes()
+}
@@ -0,0 +1,89 @@
+package common
+
+// 扩展判定（V24起）：在原版的6种判定之外增加滑键判定，并可按位或上早晚标记
+// 更早版本的对端收到时按 Legacy 转换，无法转换的判定事件不发送
+const (
+	JudgementFlickPerfect Judgement = JudgementHoldGood + 1 + iota // 滑键完美
+	JudgementFlickMiss                                             // 滑键漏击
+
+	JudgementEarly Judgement = 0x40 // 早晚标记：提前
+	JudgementLate  Judgement = 0x80 // 早晚标记：延后
+
+	judgementFlags = JudgementEarly | JudgementLate
+)
+
+// JudgeClass 判定计入的成绩分类
+type JudgeClass uint8
+
+const (
+	JudgeClassUnknown JudgeClass = iota // 未知判定（对端使用了更新的判定类型），统计时忽略
+	JudgeClassPerfect
+	JudgeClassGood
+	JudgeClassBad
+	JudgeClassMiss
+)
+
+// Base 去掉早晚标记后的判定类型
+func (j Judgement) Base() Judgement {
+	return j &^ judgementFlags
+}
+
+// Early 是否带有提前标记
+func (j Judgement) Early() bool {
+	return j&JudgementEarly != 0
+}
+
+// Late 是否带有延后标记
+func (j Judgement) Late() bool {
+	return j&JudgementLate != 0
+}
+
+// Extended 是否为扩展判定（原版协议中不存在的判定类型或带有早晚标记）
+func (j Judgement) Extended() bool {
+	return j > JudgementHoldGood
+}
+
+// Class 判定计入的成绩分类，未知的判定类型返回 JudgeClassUnknown
+func (j Judgement) Class() JudgeClass {
+	switch j.Base() {
+	case JudgementPerfect, JudgementHoldPerfect, JudgementFlickPerfect:
+		return JudgeClassPerfect
+	case JudgementGood, JudgementHoldGood:
+		return JudgeClassGood
+	case JudgementBad:
+		return JudgeClassBad
+	case JudgementMiss, JudgementFlickMiss:
+		return JudgeClassMiss
+	}
+	return JudgeClassUnknown
+}
+
+// Legacy 转换为原版判定：去掉早晚标记，滑键判定按普通判定处理；未知的判定类型返回false
+func (j Judgement) Legacy() (Judgement, bool) {
+	switch base := j.Base(); base {
+	case JudgementFlickPerfect:
+		return JudgementPerfect, true
+	case JudgementFlickMiss:
+		return JudgementMiss, true
+	default:
+		return base, base <= JudgementHoldGood
+	}
+}
+
+// writeJudges 写入判定事件列表，对端不支持扩展判定时按 Legacy 转换并跳过无法转换的事件
+func writeJudges(w *BinaryWriter, judges []JudgeEvent) {
+	if w.baseJudgements {
+		legacy := make([]JudgeEvent, 0, len(judges))
+		for _, j := range judges {
+			if v, ok := j.Judgement.Legacy(); ok {
+				j.Judgement = v
+				legacy = append(legacy, j)
+			}
+		}
+		judges = legacy
+	}
+	w.Uleb(uint64(len(judges)))
+	for _, j := range judges {
+		j.WriteBinary(w)
+	}
+}
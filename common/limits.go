@@ -0,0 +1,126 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// DecodeLimits 解码时单个列表的元素数量上限，防止恶意数据包用很大的长度前缀占用内存
+// 为0的字段使用默认值
+type DecodeLimits struct {
+	TouchFrames int // 单条 Touches/FrameBatch 命令的触摸帧数
+	TouchPoints int // 单个触摸帧的触摸点数
+	JudgeEvents int // 单条 Judges/FrameBatch 命令的判定事件数
+	Users       int // 用户列表（房间成员、已准备玩家、等待队列）
+}
+
+// DefaultDecodeLimits 默认的解码上限，远大于正常客户端单次发送的数量
+var DefaultDecodeLimits = DecodeLimits{
+	TouchFrames: 4096,
+	TouchPoints: 64,
+	JudgeEvents: 8192,
+	Users:       4096,
+}
+
+var decodeLimits atomic.Pointer[DecodeLimits]
+
+func init() {
+	limits := DefaultDecodeLimits
+	decodeLimits.Store(&limits)
+}
+
+// SetDecodeLimits 设置解码上限（对之后解码的所有数据生效）
+func SetDecodeLimits(limits DecodeLimits) {
+	if limits.TouchFrames <= 0 {
+		limits.TouchFrames = DefaultDecodeLimits.TouchFrames
+	}
+	if limits.TouchPoints <= 0 {
+		limits.TouchPoints = DefaultDecodeLimits.TouchPoints
+	}
+	if limits.JudgeEvents <= 0 {
+		limits.JudgeEvents = DefaultDecodeLimits.JudgeEvents
+	}
+	if limits.Users <= 0 {
+		limits.Users = DefaultDecodeLimits.Users
+	}
+	decodeLimits.Store(&limits)
+}
+
+// GetDecodeLimits 获取当前的解码上限
+func GetDecodeLimits() DecodeLimits {
+	return *decodeLimits.Load()
+}
+
+// ErrFrameTooLarge 客户端命令编码后的长度超过该命令类型的上限（见 FrameLimits）
+var ErrFrameTooLarge = errors.New("frame too large")
+
+// FrameLimits 各客户端命令类型编码后的最大字节数（含命令类型与扩展块），超出的帧在解码前被拒绝
+// 整体帧长度仍受流层的2MB上限约束
+type FrameLimits struct {
+	Default  int                       // 未单独设置的命令类型
+	Commands map[ClientCommandType]int // 按命令类型设置
+}
+
+// DefaultFrameLimits 默认的命令长度上限：触摸与判定数据按解码上限留足余量，其余命令均为短小的控制命令
+var DefaultFrameLimits = FrameLimits{
+	Default: 4 << 10,
+	Commands: map[ClientCommandType]int{
+		ClientCmdTouches:      1 << 20,
+		ClientCmdJudges:       128 << 10,
+		ClientCmdFrameBatch:   1152 << 10,
+		ClientCmdSubmitResult: 64 << 10,
+	},
+}
+
+var frameLimits atomic.Pointer[FrameLimits]
+
+func init() {
+	limits := DefaultFrameLimits
+	frameLimits.Store(&limits)
+}
+
+// Limit 命令类型对应的长度上限
+func (l FrameLimits) Limit(t ClientCommandType) int {
+	if n, ok := l.Commands[t]; ok {
+		return n
+	}
+	return l.Default
+}
+
+// SetFrameLimits 设置命令长度上限（对之后解码的所有命令生效）
+// Default 为0时使用默认值；Commands 中未列出的命令类型沿用默认设置，值为0时改用 Default
+func SetFrameLimits(limits FrameLimits) {
+	merged := FrameLimits{Default: limits.Default, Commands: make(map[ClientCommandType]int)}
+	if merged.Default <= 0 {
+		merged.Default = DefaultFrameLimits.Default
+	}
+	for t, n := range DefaultFrameLimits.Commands {
+		merged.Commands[t] = n
+	}
+	for t, n := range limits.Commands {
+		if n > 0 {
+			merged.Commands[t] = n
+		} else {
+			delete(merged.Commands, t)
+		}
+	}
+	frameLimits.Store(&merged)
+}
+
+// GetFrameLimits 获取当前的命令长度上限
+func GetFrameLimits() FrameLimits {
+	return *frameLimits.Load()
+}
+
+// checkFrameSize 按命令类型（帧的首字节）检查客户端命令的长度
+func checkFrameSize(data []byte) error {
+	if len(data) == 0 {
+		return nil
+	}
+	t := ClientCommandType(data[0])
+	if limit := frameLimits.Load().Limit(t); len(data) > limit {
+		return fmt.Errorf("%w: %s %d > %d", ErrFrameTooLarge, t, len(data), limit)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package common
+
+import "sync"
+
+// pooledWriterSize 新建写入器的初始缓冲区容量（覆盖绝大多数命令）
+const pooledWriterSize = 256
+
+// maxPooledWriterSize 归还时保留的缓冲区容量上限，编码过大命令后的缓冲区交给GC回收，避免池中长期占用内存
+const maxPooledWriterSize = 64 * 1024
+
+var writerPool = sync.Pool{
+	New: func() interface{} {
+		return &BinaryWriter{data: make([]byte, 0, pooledWriterSize)}
+	},
+}
+
+var readerPool = sync.Pool{
+	New: func() interface{} {
+		return new(BinaryReader)
+	},
+}
+
+// AcquireBinaryWriter 从池中获取写入器（连同其缓冲区），用完后通过 ReleaseBinaryWriter 归还
+func AcquireBinaryWriter() *BinaryWriter {
+	return writerPool.Get().(*BinaryWriter)
+}
+
+// ReleaseBinaryWriter 归还写入器；归还后不能再使用 w 及其 Data() 返回的切片
+func ReleaseBinaryWriter(w *BinaryWriter) {
+	if cap(w.data) > maxPooledWriterSize {
+		return
+	}
+	w.Reset()
+	writerPool.Put(w)
+}
+
+// AcquireBinaryReader 从池中获取读取 data 的读取器，用完后通过 ReleaseBinaryReader 归还
+// data 由调用方持有：读取结果（如 Take 返回的切片）可能引用 data，因此不随读取器回收
+func AcquireBinaryReader(data []byte) *BinaryReader {
+	r := readerPool.Get().(*BinaryReader)
+	r.data = data
+	return r
+}
+
+// AcquireStrictBinaryReader 从池中获取严格模式的读取器
+func AcquireStrictBinaryReader(data []byte) *BinaryReader {
+	r := AcquireBinaryReader(data)
+	r.strict = true
+	return r
+}
+
+// ReleaseBinaryReader 归还读取器
+func ReleaseBinaryReader(r *BinaryReader) {
+	*r = BinaryReader{}
+	readerPool.Put(r)
+}
@@ -0,0 +1,360 @@
+package common
+
+import (
+	"fmt"
+	"io"
+	"net"
+)
+
+// 协议版本
+const (
+	ProtocolV1  uint8 = 1  // 原版Phira协议
+	ProtocolV2  uint8 = 2  // 扩展协议：排队、角色切换、全服频道、成绩代提交、重新认证等命令与追加字段
+	ProtocolV3  uint8 = 3  // 在V2基础上增加玩家资料修改（UpdateProfile/ProfileUpdated）
+	ProtocolV4  uint8 = 4  // 在V3基础上增加观察者聊天（MonitorChat/MsgMonitorChat）
+	ProtocolV5  uint8 = 5  // 在V4基础上增加房间关闭通知（RoomClosed）
+	ProtocolV6  uint8 = 6  // 在V5基础上增加命令末尾的扩展块（Extensions）
+	ProtocolV7  uint8 = 7  // 在V6基础上增加触摸帧与判定合并发送（FrameBatch）
+	ProtocolV8  uint8 = 8  // 在V7基础上增加谱面预检（ValidateChart）
+	ProtocolV9  uint8 = 9  // 在V8基础上增加房间排名策略设置（SetRanking）
+	ProtocolV10 uint8 = 10 // 在V9基础上将谱面与成绩ID改为变长编码的64位整数
+	ProtocolV11 uint8 = 11 // 在V10基础上增加实时成绩（ScoreUpdate）
+	ProtocolV12 uint8 = 12 // 在V11基础上失败结果增加错误码（ErrorCode）
+	ProtocolV13 uint8 = 13 // 在V12基础上房间消息增加服务器时间戳（Message.Time）
+	ProtocolV14 uint8 = 14 // 在V13基础上增加关键命令确认（Ack）
+	ProtocolV15 uint8 = 15 // 在V14基础上增加表情（Emote/MsgEmote）
+	ProtocolV16 uint8 = 16 // 在V15基础上增加房间开放时间（SetSchedule）
+	ProtocolV17 uint8 = 17 // 在V16基础上增加房间列表查询（ListRooms）
+	ProtocolV18 uint8 = 18 // 在V17基础上 Ping/Pong 增加时间戳，用于测量往返延迟
+	ProtocolV19 uint8 = 19 // 在V18基础上增加资料卡（ShareStats 与 UserInfo.Card）
+	ProtocolV20 uint8 = 20 // 在V19基础上增加房主移交（TransferHost）
+	ProtocolV21 uint8 = 21 // 在V20基础上 CreateRoom/JoinRoom 增加房间密码
+	ProtocolV22 uint8 = 22 // 在V21基础上增加房间消息历史查询（ChatHistory）
+	ProtocolV23 uint8 = 23 // 在V22基础上增加移出通知（Kicked）与房主移出玩家（Kick）
+	ProtocolV24 uint8 = 24 // 在V23基础上增加扩展判定（滑键判定与早晚标记）
+	ProtocolV25 uint8 = 25 // 在V24基础上增加房主修改房间密码与私密房间（SetPassword）
+	ProtocolV26 uint8 = 26 // 在V25基础上增加队伍（SetTeam/MsgTeamChange 与房间状态中的队伍分配）
+	ProtocolV27 uint8 = 27 // 在V26基础上增加断开连接通知（Disconnect）
+
+	ProtocolLatest = ProtocolV27
+
+	// ProtocolNegotiate 版本协商握手的首字节（原版客户端直接发送单个版本号，不会用到该值）
+	// 其后为支持的版本数量（1字节）、版本列表与请求的连接特性（1字节，见 StreamFeatures），
+	// 服务器回复选定的版本（0表示没有共同支持的版本）与启用的连接特性
+	ProtocolNegotiate uint8 = 0xFF
+)
+
+// SupportedProtocols 当前实现支持的协议版本
+var SupportedProtocols = []uint8{ProtocolV1, ProtocolV2, ProtocolV3, ProtocolV4, ProtocolV5, ProtocolV6, ProtocolV7, ProtocolV8, ProtocolV9, ProtocolV10, ProtocolV11, ProtocolV12, ProtocolV13, ProtocolV14, ProtocolV15, ProtocolV16, ProtocolV17, ProtocolV18, ProtocolV19, ProtocolV20, ProtocolV21, ProtocolV22, ProtocolV23, ProtocolV24, ProtocolV25, ProtocolV26, ProtocolV27}
+
+// protocolShim 单个协议版本的编解码兼容层
+type protocolShim struct {
+	version      uint8
+	maxClientCmd ClientCommandType // 该版本可用的最大客户端命令
+	maxServerCmd ServerCommandType // 该版本可用的最大服务器命令
+	maxMessage   MessageType       // 该版本可用的最大房间消息
+	extended     bool              // 是否编码追加字段（Played判定统计、已准备玩家列表）
+	extensions   bool              // 是否支持命令末尾的扩展块
+	wideIDs      bool              // 谱面与成绩ID是否为64位（见 ReadID/WriteID）
+	errorCodes   bool              // 失败结果是否携带错误码（见 Result）
+	timestamps   bool              // 房间消息是否携带时间戳（见 Message）
+	pingTimes    bool              // Ping/Pong 是否携带时间戳（见 PingTimes）
+	profileCards bool              // 用户信息是否携带资料卡（见 UserInfo）
+	passwords    bool              // CreateRoom/JoinRoom 是否携带房间密码
+	judgements   bool              // 判定是否可以使用扩展判定（见 writeJudges）
+	teams        bool              // 房间状态与加入房间结果是否携带队伍分配
+}
+
+// protocolShims 各协议版本的兼容层，只列出启用的能力，新增的能力字段对已有版本默认为false
+var protocolShims = map[uint8]*protocolShim{
+	ProtocolV1: {
+		version: ProtocolV1, maxClientCmd: ClientCmdAbort, maxServerCmd: ServerCmdAbort, maxMessage: MsgCycleRoom,
+	},
+	ProtocolV2: {
+		version: ProtocolV2, maxClientCmd: ClientCmdReauthenticate, maxServerCmd: ServerCmdReauthenticate, maxMessage: MsgLiveRoom,
+		extended: true,
+	},
+	ProtocolV3: {
+		version: ProtocolV3, maxClientCmd: ClientCmdUpdateProfile, maxServerCmd: ServerCmdProfileUpdated, maxMessage: MsgLiveRoom,
+		extended: true,
+	},
+	ProtocolV4: {
+		version: ProtocolV4, maxClientCmd: ClientCmdMonitorChat, maxServerCmd: ServerCmdMonitorChat, maxMessage: MsgMonitorChat,
+		extended: true,
+	},
+	ProtocolV5: {
+		version: ProtocolV5, maxClientCmd: ClientCmdMonitorChat, maxServerCmd: ServerCmdRoomClosed, maxMessage: MsgMonitorChat,
+		extended: true,
+	},
+	ProtocolV6: {
+		version: ProtocolV6, maxClientCmd: ClientCmdMonitorChat, maxServerCmd: ServerCmdRoomClosed, maxMessage: MsgMonitorChat,
+		extended: true, extensions: true,
+	},
+	ProtocolV7: {
+		version: ProtocolV7, maxClientCmd: ClientCmdFrameBatch, maxServerCmd: ServerCmdRoomClosed, maxMessage: MsgMonitorChat,
+		extended: true, extensions: true,
+	},
+	ProtocolV8: {
+		version: ProtocolV8, maxClientCmd: ClientCmdValidateChart, maxServerCmd: ServerCmdValidateChart, maxMessage: MsgMonitorChat,
+		extended: true, extensions: true,
+	},
+	ProtocolV9: {
+		version: ProtocolV9, maxClientCmd: ClientCmdSetRanking, maxServerCmd: ServerCmdSetRanking, maxMessage: MsgMonitorChat,
+		extended: true, extensions: true,
+	},
+	ProtocolV10: {
+		version: ProtocolV10, maxClientCmd: ClientCmdSetRanking, maxServerCmd: ServerCmdSetRanking, maxMessage: MsgMonitorChat,
+		extended: true, extensions: true, wideIDs: true,
+	},
+	ProtocolV11: {
+		version: ProtocolV11, maxClientCmd: ClientCmdScoreUpdate, maxServerCmd: ServerCmdScoreUpdate, maxMessage: MsgMonitorChat,
+		extended: true, extensions: true, wideIDs: true,
+	},
+	ProtocolV12: {
+		version: ProtocolV12, maxClientCmd: ClientCmdScoreUpdate, maxServerCmd: ServerCmdScoreUpdate, maxMessage: MsgMonitorChat,
+		extended: true, extensions: true, wideIDs: true, errorCodes: true,
+	},
+	ProtocolV13: {
+		version: ProtocolV13, maxClientCmd: ClientCmdScoreUpdate, maxServerCmd: ServerCmdScoreUpdate, maxMessage: MsgMonitorChat,
+		extended: true, extensions: true, wideIDs: true, errorCodes: true, timestamps: true,
+	},
+	ProtocolV14: {
+		version: ProtocolV14, maxClientCmd: ClientCmdAck, maxServerCmd: ServerCmdScoreUpdate, maxMessage: MsgMonitorChat,
+		extended: true, extensions: true, wideIDs: true, errorCodes: true, timestamps: true,
+	},
+	ProtocolV15: {
+		version: ProtocolV15, maxClientCmd: ClientCmdEmote, maxServerCmd: ServerCmdEmote, maxMessage: MsgEmote,
+		extended: true, extensions: true, wideIDs: true, errorCodes: true, timestamps: true,
+	},
+	ProtocolV16: {
+		version: ProtocolV16, maxClientCmd: ClientCmdSetSchedule, maxServerCmd: ServerCmdSetSchedule, maxMessage: MsgEmote,
+		extended: true, extensions: true, wideIDs: true, errorCodes: true, timestamps: true,
+	},
+	ProtocolV17: {
+		version: ProtocolV17, maxClientCmd: ClientCmdListRooms, maxServerCmd: ServerCmdListRooms, maxMessage: MsgEmote,
+		extended: true, extensions: true, wideIDs: true, errorCodes: true, timestamps: true,
+	},
+	ProtocolV18: {
+		version: ProtocolV18, maxClientCmd: ClientCmdListRooms, maxServerCmd: ServerCmdListRooms, maxMessage: MsgEmote,
+		extended: true, extensions: true, wideIDs: true, errorCodes: true, timestamps: true, pingTimes: true,
+	},
+	ProtocolV19: {
+		version: ProtocolV19, maxClientCmd: ClientCmdShareStats, maxServerCmd: ServerCmdShareStats, maxMessage: MsgEmote,
+		extended: true, extensions: true, wideIDs: true, errorCodes: true, timestamps: true, pingTimes: true, profileCards: true,
+	},
+	ProtocolV20: {
+		version: ProtocolV20, maxClientCmd: ClientCmdTransferHost, maxServerCmd: ServerCmdTransferHost, maxMessage: MsgEmote,
+		extended: true, extensions: true, wideIDs: true, errorCodes: true, timestamps: true, pingTimes: true, profileCards: true,
+	},
+	ProtocolV21: {
+		version: ProtocolV21, maxClientCmd: ClientCmdTransferHost, maxServerCmd: ServerCmdTransferHost, maxMessage: MsgEmote,
+		extended: true, extensions: true, wideIDs: true, errorCodes: true, timestamps: true, pingTimes: true, profileCards: true, passwords: true,
+	},
+	ProtocolV22: {
+		version: ProtocolV22, maxClientCmd: ClientCmdChatHistory, maxServerCmd: ServerCmdChatHistory, maxMessage: MsgEmote,
+		extended: true, extensions: true, wideIDs: true, errorCodes: true, timestamps: true, pingTimes: true, profileCards: true, passwords: true,
+	},
+	ProtocolV23: {
+		version: ProtocolV23, maxClientCmd: ClientCmdKick, maxServerCmd: ServerCmdKicked, maxMessage: MsgEmote,
+		extended: true, extensions: true, wideIDs: true, errorCodes: true, timestamps: true, pingTimes: true, profileCards: true, passwords: true,
+	},
+	ProtocolV24: {
+		version: ProtocolV24, maxClientCmd: ClientCmdKick, maxServerCmd: ServerCmdKicked, maxMessage: MsgEmote,
+		extended: true, extensions: true, wideIDs: true, errorCodes: true, timestamps: true, pingTimes: true, profileCards: true, passwords: true, judgements: true,
+	},
+	ProtocolV25: {
+		version: ProtocolV25, maxClientCmd: ClientCmdSetPassword, maxServerCmd: ServerCmdSetPassword, maxMessage: MsgEmote,
+		extended: true, extensions: true, wideIDs: true, errorCodes: true, timestamps: true, pingTimes: true, profileCards: true, passwords: true, judgements: true,
+	},
+	ProtocolV26: {
+		version: ProtocolV26, maxClientCmd: ClientCmdSetTeam, maxServerCmd: ServerCmdSetTeam, maxMessage: MsgTeamChange,
+		extended: true, extensions: true, wideIDs: true, errorCodes: true, timestamps: true, pingTimes: true, profileCards: true, passwords: true, judgements: true, teams: true,
+	},
+	ProtocolV27: {
+		version: ProtocolV27, maxClientCmd: ClientCmdSetTeam, maxServerCmd: ServerCmdDisconnect, maxMessage: MsgTeamChange,
+		extended: true, extensions: true, wideIDs: true, errorCodes: true, timestamps: true, pingTimes: true, profileCards: true, passwords: true, judgements: true, teams: true,
+	},
+}
+
+// shimFor 获取协议版本对应的兼容层，未知版本按原版协议处理
+func shimFor(version uint8) *protocolShim {
+	if shim, ok := protocolShims[version]; ok {
+		return shim
+	}
+	return protocolShims[ProtocolV1]
+}
+
+// SelectProtocol 从客户端支持的版本中选择双方都支持的最高版本，没有时返回0
+func SelectProtocol(offered []uint8) uint8 {
+	var selected uint8
+	for _, v := range offered {
+		if _, ok := protocolShims[v]; ok && v > selected {
+			selected = v
+		}
+	}
+	return selected
+}
+
+// ServerCommandSupported 判断服务器命令能否发送给指定协议版本的客户端
+func ServerCommandSupported(version uint8, cmd *ServerCommand) bool {
+	shim := shimFor(version)
+	if cmd.Type > shim.maxServerCmd {
+		return false
+	}
+	if cmd.Type == ServerCmdMessage && cmd.Message != nil && cmd.Message.Type > shim.maxMessage {
+		return false
+	}
+	return true
+}
+
+// ClientCommandSupported 判断客户端命令能否发送给指定协议版本的服务器
+func ClientCommandSupported(version uint8, cmd *ClientCommand) bool {
+	return cmd.Type <= shimFor(version).maxClientCmd
+}
+
+// writer 从池中获取按协议版本编码的写入器
+func (p *protocolShim) writer() *BinaryWriter {
+	w := AcquireBinaryWriter()
+	w.legacy = !p.extended
+	w.noExtensions = !p.extensions
+	w.narrowIDs = !p.wideIDs
+	w.stringErrors = !p.errorCodes
+	w.noTimestamps = !p.timestamps
+	w.noPingTimes = !p.pingTimes
+	w.noProfileCards = !p.profileCards
+	w.noPasswords = !p.passwords
+	w.baseJudgements = !p.judgements
+	w.noTeams = !p.teams
+	return w
+}
+
+// encodeServer 按协议版本编码服务器命令，不支持的命令返回nil
+// 返回的写入器来自池，由调用方发送后归还
+func (p *protocolShim) encodeServer(cmd *ServerCommand) (*BinaryWriter, error) {
+	if !ServerCommandSupported(p.version, cmd) {
+		return nil, nil
+	}
+	w := p.writer()
+	if err := cmd.WriteBinary(w); err != nil {
+		ReleaseBinaryWriter(w)
+		return nil, err
+	}
+	return w, nil
+}
+
+// decodeClient 按协议版本解码客户端命令，超过该命令类型长度上限的帧不解码直接返回 ErrFrameTooLarge
+func (p *protocolShim) decodeClient(data []byte) (ClientCommand, error) {
+	if err := checkFrameSize(data); err != nil {
+		return ClientCommand{}, err
+	}
+	var cmd ClientCommand
+	r := AcquireBinaryReader(data)
+	defer ReleaseBinaryReader(r)
+	r.narrowIDs = !p.wideIDs
+	r.stringErrors = !p.errorCodes
+	r.noTimestamps = !p.timestamps
+	r.noPingTimes = !p.pingTimes
+	r.noProfileCards = !p.profileCards
+	r.noPasswords = !p.passwords
+	r.noTeams = !p.teams
+	if err := cmd.ReadBinary(r); err != nil {
+		return ClientCommand{}, err
+	}
+	if cmd.Type > p.maxClientCmd {
+		return ClientCommand{}, fmt.Errorf("协议版本 %d 不支持客户端命令 %d", p.version, cmd.Type)
+	}
+	return cmd, nil
+}
+
+// decodeServer 按协议版本解码服务器命令（同 DecodeStrict）
+func (p *protocolShim) decodeServer(data []byte) (ServerCommand, error) {
+	var cmd ServerCommand
+	r := AcquireStrictBinaryReader(data)
+	defer ReleaseBinaryReader(r)
+	r.narrowIDs = !p.wideIDs
+	r.stringErrors = !p.errorCodes
+	r.noTimestamps = !p.timestamps
+	r.noPingTimes = !p.pingTimes
+	r.noProfileCards = !p.profileCards
+	r.noPasswords = !p.passwords
+	r.noTeams = !p.teams
+	if err := cmd.ReadBinary(r); err != nil {
+		return ServerCommand{}, fmt.Errorf("malformed frame: %w", err)
+	}
+	if n := r.Remaining(); n != 0 {
+		return ServerCommand{}, fmt.Errorf("malformed frame: %d trailing bytes", n)
+	}
+	return cmd, nil
+}
+
+// handshakeResult 握手结果
+type handshakeResult struct {
+	version    uint8
+	negotiated bool
+	features   StreamFeatures
+}
+
+// acceptHandshake 读取客户端握手：原版客户端发送单个版本号，新版客户端发送版本列表进行协商
+// allowed 为服务器允许启用的连接特性
+func acceptHandshake(conn net.Conn, allowed StreamFeatures) (handshakeResult, error) {
+	buf := make([]byte, 1)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return handshakeResult{}, err
+	}
+	if buf[0] != ProtocolNegotiate {
+		return handshakeResult{version: buf[0]}, nil
+	}
+
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return handshakeResult{}, err
+	}
+	offered := make([]byte, int(buf[0])+1)
+	if _, err := io.ReadFull(conn, offered); err != nil {
+		return handshakeResult{}, err
+	}
+	requested := StreamFeatures(offered[len(offered)-1])
+	offered = offered[:len(offered)-1]
+
+	selected := SelectProtocol(offered)
+	features := requested & allowed
+	if selected == 0 {
+		features = 0
+	}
+	if _, err := conn.Write([]byte{selected, uint8(features)}); err != nil {
+		return handshakeResult{}, err
+	}
+	if selected == 0 {
+		return handshakeResult{}, fmt.Errorf("没有共同支持的协议版本: %v", offered)
+	}
+	return handshakeResult{version: selected, negotiated: true, features: features}, nil
+}
+
+// negotiateHandshake 向服务器发送支持的版本列表与请求的连接特性，读取选定的版本与启用的特性
+func negotiateHandshake(conn net.Conn, versions []uint8, features StreamFeatures) (handshakeResult, error) {
+	if len(versions) == 0 || len(versions) > 255 {
+		return handshakeResult{}, fmt.Errorf("invalid protocol version list")
+	}
+	hello := append([]byte{ProtocolNegotiate, uint8(len(versions))}, versions...)
+	hello = append(hello, uint8(features))
+	if _, err := conn.Write(hello); err != nil {
+		return handshakeResult{}, err
+	}
+
+	buf := make([]byte, 2)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		return handshakeResult{}, err
+	}
+	if buf[0] == 0 {
+		return handshakeResult{}, fmt.Errorf("服务器不支持以下任何协议版本: %v", versions)
+	}
+	enabled := StreamFeatures(buf[1])
+	if enabled&^features != 0 {
+		return handshakeResult{}, fmt.Errorf("服务器启用了未请求的连接特性: %d", enabled)
+	}
+	for _, v := range versions {
+		if v == buf[0] {
+			return handshakeResult{version: v, negotiated: true, features: enabled}, nil
+		}
+	}
+	return handshakeResult{}, fmt.Errorf("服务器选择了未声明的协议版本: %d", buf[0])
+}
@@ -1,7 +1,10 @@
 package common
 
 import (
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"hash/crc32"
 	"io"
 	"net"
 	"sync"
@@ -14,52 +17,70 @@ const (
 	HeartbeatDisconnectTimeout = 10 * time.Second
 )
 
+// frameChecksumSize 启用 FeatureChecksum 时每帧末尾的CRC32（小端序，计入帧长度）
+const frameChecksumSize = 4
+
+// ErrFrameChecksum 帧校验失败，此后的数据已不可信，连接应当断开
+var ErrFrameChecksum = errors.New("frame checksum mismatch")
+
+// ErrSendQueueFull 发送队列已满（对方读取过慢），命令未发送，见 ServerStream.TrySend
+var ErrSendQueueFull = errors.New("send queue full")
+
 // Stream 网络流
 type Stream struct {
-	conn    net.Conn
-	version uint8
-
-	sendChan chan []byte
+	conn       net.Conn
+	version    uint8
+	negotiated bool           // 是否经过版本协商（原版客户端只发送单个版本号）
+	shim       *protocolShim  // 按协议版本编解码命令
+	features   StreamFeatures // 协商启用的连接特性
+	codec      streamCodec    // 连接读写层（启用压缩时包装conn）
+
+	sendChan chan outFrame
 	recvChan chan []byte
 
 	stopChan chan struct{}
 	wg       sync.WaitGroup
 
+	recvDone chan struct{} // 接收循环因帧错误退出时关闭
+	recvErr  error
+	sendDone chan struct{} // 发送循环退出（写出失败或连接关闭）时关闭
+
 	mu       sync.RWMutex
 	lastRecv time.Time
+	readErr  error // 接收循环退出的原因（见 ReadError）
 }
 
-// NewStream 创建新的Stream（服务器端）- 读取客户端发送的版本号
-func NewStream(conn net.Conn) (*Stream, error) {
-	if err := conn.(*net.TCPConn).SetNoDelay(true); err != nil {
+// NewStream 创建新的Stream（服务器端）- 读取客户端发送的版本号或进行版本协商
+// allowed 为允许客户端启用的连接特性
+func NewStream(conn net.Conn, allowed StreamFeatures) (*Stream, error) {
+	if err := setNoDelay(conn); err != nil {
 		return nil, err
 	}
 
-	// 读取客户端发送的版本号
-	versionBuf := make([]byte, 1)
-	if _, err := io.ReadFull(conn, versionBuf); err != nil {
+	hs, err := acceptHandshake(conn, allowed)
+	if err != nil {
 		return nil, err
 	}
+	return newStream(conn, hs), nil
+}
 
-	s := &Stream{
-		conn:     conn,
-		version:  versionBuf[0],
-		sendChan: make(chan []byte, 1024),
-		recvChan: make(chan []byte, 1024),
-		stopChan: make(chan struct{}),
-		lastRecv: time.Now(),
+// setNoDelay 关闭TCP连接的Nagle算法，包装过的连接（如PROXY Protocol）通过 NetConn 取得底层连接，不是TCP连接时忽略
+func setNoDelay(conn net.Conn) error {
+	for {
+		switch c := conn.(type) {
+		case *net.TCPConn:
+			return c.SetNoDelay(true)
+		case interface{ NetConn() net.Conn }:
+			conn = c.NetConn()
+		default:
+			return nil
+		}
 	}
-
-	s.wg.Add(2)
-	go s.sendLoop()
-	go s.recvLoop()
-
-	return s, nil
 }
 
-// NewStreamClient 客户端创建Stream - 发送版本号给服务器
+// NewStreamClient 客户端创建Stream - 发送单个版本号给服务器（原版握手，按原版协议编解码）
 func NewStreamClient(conn net.Conn, version uint8) (*Stream, error) {
-	if err := conn.(*net.TCPConn).SetNoDelay(true); err != nil {
+	if err := setNoDelay(conn); err != nil {
 		return nil, err
 	}
 
@@ -67,35 +88,150 @@ func NewStreamClient(conn net.Conn, version uint8) (*Stream, error) {
 	if _, err := conn.Write([]byte{version}); err != nil {
 		return nil, err
 	}
+	return newStream(conn, handshakeResult{version: version}), nil
+}
+
+// NewStreamClientNegotiate 客户端创建Stream - 发送支持的版本列表与请求的连接特性，由服务器选定
+func NewStreamClientNegotiate(conn net.Conn, versions []uint8, features StreamFeatures) (*Stream, error) {
+	if err := setNoDelay(conn); err != nil {
+		return nil, err
+	}
+
+	hs, err := negotiateHandshake(conn, versions, features)
+	if err != nil {
+		return nil, err
+	}
+	return newStream(conn, hs), nil
+}
+
+func newStream(conn net.Conn, hs handshakeResult) *Stream {
+	shim := protocolShims[ProtocolV1]
+	if hs.negotiated {
+		shim = shimFor(hs.version)
+	}
 
 	s := &Stream{
-		conn:     conn,
-		version:  version,
-		sendChan: make(chan []byte, 1024),
-		recvChan: make(chan []byte, 1024),
-		stopChan: make(chan struct{}),
-		lastRecv: time.Now(),
+		conn:       conn,
+		version:    hs.version,
+		negotiated: hs.negotiated,
+		shim:       shim,
+		features:   hs.features,
+		codec:      newStreamCodec(conn, hs.features),
+		sendChan:   make(chan outFrame, 1024),
+		recvChan:   make(chan []byte, 1024),
+		stopChan:   make(chan struct{}),
+		recvDone:   make(chan struct{}),
+		sendDone:   make(chan struct{}),
+		lastRecv:   time.Now(),
 	}
 
 	s.wg.Add(2)
 	go s.sendLoop()
 	go s.recvLoop()
 
-	return s, nil
+	return s
 }
 
-// Version 获取版本号
+// Version 获取版本号（协商后为选定的版本，否则为客户端发送的版本号）
 func (s *Stream) Version() uint8 {
 	return s.version
 }
 
+// Protocol 获取实际使用的协议版本（未经协商的连接按原版协议处理）
+func (s *Stream) Protocol() uint8 {
+	return s.shim.version
+}
+
+// Negotiated 是否经过版本协商
+func (s *Stream) Negotiated() bool {
+	return s.negotiated
+}
+
+// WireFormat 获取连接握手后确定的编码方式
+func (s *Stream) WireFormat() WireFormat {
+	return WireFormat{Version: s.version, Negotiated: s.negotiated, Features: s.features}
+}
+
+// Features 获取协商启用的连接特性
+func (s *Stream) Features() StreamFeatures {
+	return s.features
+}
+
+// RemoteAddr 获取远程地址（启用PROXY Protocol时为真实客户端地址）
+func (s *Stream) RemoteAddr() net.Addr {
+	return s.conn.RemoteAddr()
+}
+
+// outFrame 待发送的帧，writer 非nil时数据来自池中的写入器，写出后归还
+// sent 非nil时为 Drain 的标记帧，不写出数据，发送循环处理到该帧时关闭 sent
+type outFrame struct {
+	data   []byte
+	writer *BinaryWriter
+	sent   chan struct{}
+}
+
 // SendRaw 发送原始数据
 func (s *Stream) SendRaw(data []byte) error {
+	return s.send(outFrame{data: data})
+}
+
+// sendWriter 发送池中写入器的数据，发送循环写出后归还写入器
+func (s *Stream) sendWriter(w *BinaryWriter) error {
+	return s.send(outFrame{data: w.Data(), writer: w})
+}
+
+func (s *Stream) send(frame outFrame) error {
+	select {
+	case s.sendChan <- frame:
+		return nil
+	case <-s.stopChan:
+		if frame.writer != nil {
+			ReleaseBinaryWriter(frame.writer)
+		}
+		return fmt.Errorf("stream closed")
+	}
+}
+
+// trySend 不等待发送队列，队列已满时丢弃该帧并返回 ErrSendQueueFull
+func (s *Stream) trySend(frame outFrame) error {
 	select {
-	case s.sendChan <- data:
+	case s.sendChan <- frame:
 		return nil
 	case <-s.stopChan:
+		if frame.writer != nil {
+			ReleaseBinaryWriter(frame.writer)
+		}
 		return fmt.Errorf("stream closed")
+	default:
+		if frame.writer != nil {
+			ReleaseBinaryWriter(frame.writer)
+		}
+		return ErrSendQueueFull
+	}
+}
+
+// Drain 等待此前排队的帧全部写出，超时或连接已关闭时返回false（用于断开连接前确保最后的通知送达）
+func (s *Stream) Drain(timeout time.Duration) bool {
+	sent := make(chan struct{})
+	if s.send(outFrame{sent: sent}) != nil {
+		return false
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-sent:
+		return true
+	case <-timer.C:
+		return false
+	case <-s.stopChan:
+		return false
+	case <-s.sendDone:
+		select {
+		case <-sent:
+			return true
+		default:
+			return false
+		}
 	}
 }
 
@@ -106,6 +242,14 @@ func (s *Stream) RecvRaw() ([]byte, error) {
 		return data, nil
 	case <-s.stopChan:
 		return nil, fmt.Errorf("stream closed")
+	case <-s.recvDone:
+		// 先交付出错前已收到的帧
+		select {
+		case data := <-s.recvChan:
+			return data, nil
+		default:
+		}
+		return nil, s.recvErr
 	}
 }
 
@@ -116,6 +260,14 @@ func (s *Stream) Close() {
 	s.wg.Wait()
 }
 
+// ReadError 接收循环因读取出错（对方关闭连接、连接中断或帧错误）而退出时的错误，仍在接收时为nil
+// 除帧校验失败外 RecvRaw 不会返回读取错误，可据此区分对方已关闭连接与长时间没有发送数据
+func (s *Stream) ReadError() error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.readErr
+}
+
 // LastRecvTime 获取最后接收时间
 func (s *Stream) LastRecvTime() time.Time {
 	s.mu.RLock()
@@ -125,11 +277,20 @@ func (s *Stream) LastRecvTime() time.Time {
 
 func (s *Stream) sendLoop() {
 	defer s.wg.Done()
+	defer close(s.sendDone)
 
 	for {
 		select {
-		case data := <-s.sendChan:
-			if err := s.writeData(data); err != nil {
+		case frame := <-s.sendChan:
+			if frame.sent != nil {
+				close(frame.sent)
+				continue
+			}
+			err := s.writeData(frame.data)
+			if frame.writer != nil {
+				ReleaseBinaryWriter(frame.writer)
+			}
+			if err != nil {
 				return
 			}
 		case <-s.stopChan:
@@ -150,6 +311,13 @@ func (s *Stream) recvLoop() {
 
 		data, err := s.readData()
 		if err != nil {
+			s.mu.Lock()
+			s.readErr = err
+			s.mu.Unlock()
+			if errors.Is(err, ErrFrameChecksum) {
+				s.recvErr = err
+				close(s.recvDone)
+			}
 			return
 		}
 
@@ -166,9 +334,14 @@ func (s *Stream) recvLoop() {
 }
 
 func (s *Stream) writeData(data []byte) error {
+	checksum := s.features&FeatureChecksum != 0
+
 	// 写入长度（ULEB128编码）
 	lenBuf := make([]byte, 0, 5)
 	x := uint32(len(data))
+	if checksum {
+		x += frameChecksumSize
+	}
 	for {
 		b := byte(x & 0x7f)
 		x >>= 7
@@ -181,22 +354,37 @@ func (s *Stream) writeData(data []byte) error {
 		}
 	}
 
-	if _, err := s.conn.Write(lenBuf); err != nil {
+	if _, err := s.codec.w.Write(lenBuf); err != nil {
 		return err
 	}
-	if _, err := s.conn.Write(data); err != nil {
+	if _, err := s.codec.w.Write(data); err != nil {
 		return err
 	}
+	if checksum {
+		var sum [frameChecksumSize]byte
+		binary.LittleEndian.PutUint32(sum[:], crc32.ChecksumIEEE(data))
+		if _, err := s.codec.w.Write(sum[:]); err != nil {
+			return err
+		}
+	}
+	if s.codec.flush != nil {
+		return s.codec.flush()
+	}
 	return nil
 }
 
 func (s *Stream) readData() ([]byte, error) {
+	return readFrame(s.codec.r, s.features)
+}
+
+// readFrame 读取一帧（长度前缀与可选的CRC32校验），返回帧数据
+func readFrame(r io.Reader, features StreamFeatures) ([]byte, error) {
 	// 读取长度（ULEB128编码）
 	var length uint32
 	var pos uint
 	for {
 		b := make([]byte, 1)
-		if _, err := io.ReadFull(s.conn, b); err != nil {
+		if _, err := io.ReadFull(r, b); err != nil {
 			return nil, err
 		}
 		length |= uint32(b[0]&0x7f) << pos
@@ -215,10 +403,22 @@ func (s *Stream) readData() ([]byte, error) {
 
 	// 读取数据
 	buffer := make([]byte, length)
-	if _, err := io.ReadFull(s.conn, buffer); err != nil {
+	if _, err := io.ReadFull(r, buffer); err != nil {
 		return nil, err
 	}
 
+	if features&FeatureChecksum != 0 {
+		if length < frameChecksumSize {
+			return nil, fmt.Errorf("%w: frame too short (%d)", ErrFrameChecksum, length)
+		}
+		data := buffer[:length-frameChecksumSize]
+		want := binary.LittleEndian.Uint32(buffer[length-frameChecksumSize:])
+		if got := crc32.ChecksumIEEE(data); got != want {
+			return nil, fmt.Errorf("%w: got %08x, want %08x", ErrFrameChecksum, got, want)
+		}
+		return data, nil
+	}
+
 	return buffer, nil
 }
 
@@ -227,22 +427,31 @@ type ServerStream struct {
 	*Stream
 }
 
-// NewServerStream 创建服务器端Stream
-func NewServerStream(conn net.Conn) (*ServerStream, error) {
-	stream, err := NewStream(conn)
+// NewServerStream 创建服务器端Stream，allowed 为允许客户端启用的连接特性
+func NewServerStream(conn net.Conn, allowed StreamFeatures) (*ServerStream, error) {
+	stream, err := NewStream(conn, allowed)
 	if err != nil {
 		return nil, err
 	}
 	return &ServerStream{Stream: stream}, nil
 }
 
-// Send 发送服务器命令
+// Send 发送服务器命令，客户端协议版本不支持的命令直接丢弃
 func (s *ServerStream) Send(cmd ServerCommand) error {
-	w := NewBinaryWriter()
-	if err := cmd.WriteBinary(w); err != nil {
+	w, err := s.shim.encodeServer(&cmd)
+	if err != nil || w == nil {
 		return err
 	}
-	return s.SendRaw(w.Data())
+	return s.sendWriter(w)
+}
+
+// TrySend 发送服务器命令但不等待发送队列，队列已满时丢弃命令并返回 ErrSendQueueFull
+func (s *ServerStream) TrySend(cmd ServerCommand) error {
+	w, err := s.shim.encodeServer(&cmd)
+	if err != nil || w == nil {
+		return err
+	}
+	return s.trySend(outFrame{data: w.Data(), writer: w})
 }
 
 // Recv 接收客户端命令
@@ -251,9 +460,8 @@ func (s *ServerStream) Recv() (ClientCommand, error) {
 	if err != nil {
 		return ClientCommand{}, err
 	}
-	r := NewBinaryReader(data)
-	var cmd ClientCommand
-	if err := cmd.ReadBinary(r); err != nil {
+	cmd, err := s.shim.decodeClient(data)
+	if err != nil {
 		return ClientCommand{}, fmt.Errorf("解码命令失败: %w", err)
 	}
 	return cmd, nil
@@ -264,7 +472,7 @@ type ClientStream struct {
 	*Stream
 }
 
-// NewClientStream 创建客户端Stream
+// NewClientStream 创建客户端Stream（原版握手）
 func NewClientStream(conn net.Conn, version uint8) (*ClientStream, error) {
 	stream, err := NewStreamClient(conn, version)
 	if err != nil {
@@ -273,13 +481,26 @@ func NewClientStream(conn net.Conn, version uint8) (*ClientStream, error) {
 	return &ClientStream{Stream: stream}, nil
 }
 
+// NewNegotiatedClientStream 创建客户端Stream，与服务器协商协议版本与连接特性
+func NewNegotiatedClientStream(conn net.Conn, versions []uint8, features StreamFeatures) (*ClientStream, error) {
+	stream, err := NewStreamClientNegotiate(conn, versions, features)
+	if err != nil {
+		return nil, err
+	}
+	return &ClientStream{Stream: stream}, nil
+}
+
 // Send 发送客户端命令
 func (c *ClientStream) Send(cmd ClientCommand) error {
-	w := NewBinaryWriter()
+	if !ClientCommandSupported(c.shim.version, &cmd) {
+		return fmt.Errorf("服务器协议版本 %d 不支持该命令", c.shim.version)
+	}
+	w := c.shim.writer()
 	if err := cmd.WriteBinary(w); err != nil {
+		ReleaseBinaryWriter(w)
 		return err
 	}
-	return c.SendRaw(w.Data())
+	return c.sendWriter(w)
 }
 
 // Recv 接收服务器命令
@@ -288,10 +509,5 @@ func (c *ClientStream) Recv() (ServerCommand, error) {
 	if err != nil {
 		return ServerCommand{}, err
 	}
-	r := NewBinaryReader(data)
-	var cmd ServerCommand
-	if err := cmd.ReadBinary(r); err != nil {
-		return ServerCommand{}, err
-	}
-	return cmd, nil
+	return c.shim.decodeServer(data)
 }
@@ -0,0 +1,93 @@
+package common
+
+import (
+	"compress/flate"
+	"fmt"
+	"io"
+)
+
+// WireFormat 一条连接握手后确定的编码方式，用于抓包分析等不经过 Stream 的场景编解码命令帧
+type WireFormat struct {
+	Version    uint8          // 协商后为选定的版本，否则为客户端发送的版本号
+	Negotiated bool           // 是否经过版本协商（未经协商的连接按原版协议编解码）
+	Features   StreamFeatures // 协商启用的连接特性
+}
+
+// ParseHandshake 从连接两个方向的原始字节开头解析握手，返回编码方式与两个方向握手占用的字节数
+// 原版握手只有客户端发送的版本号，协商握手还包括服务器回复的版本与连接特性
+func ParseHandshake(client, server []byte) (f WireFormat, clientLen, serverLen int, err error) {
+	if len(client) == 0 {
+		return WireFormat{}, 0, 0, io.ErrUnexpectedEOF
+	}
+	if client[0] != ProtocolNegotiate {
+		return WireFormat{Version: client[0]}, 1, 0, nil
+	}
+
+	if len(client) < 2 || len(client) < int(client[1])+3 {
+		return WireFormat{}, 0, 0, fmt.Errorf("客户端握手不完整: %w", io.ErrUnexpectedEOF)
+	}
+	clientLen = int(client[1]) + 3
+	if len(server) < 2 {
+		return WireFormat{}, 0, 0, fmt.Errorf("服务器握手不完整: %w", io.ErrUnexpectedEOF)
+	}
+	if server[0] == 0 {
+		return WireFormat{}, 0, 0, fmt.Errorf("没有共同支持的协议版本: %v", client[2:clientLen-1])
+	}
+	f = WireFormat{Version: server[0], Negotiated: true, Features: StreamFeatures(server[1])}
+	return f, clientLen, 2, nil
+}
+
+// shim 按编码方式选择协议兼容层（与 Stream 一致）
+func (f WireFormat) shim() *protocolShim {
+	if !f.Negotiated {
+		return protocolShims[ProtocolV1]
+	}
+	return shimFor(f.Version)
+}
+
+// Protocol 实际使用的协议版本
+func (f WireFormat) Protocol() uint8 {
+	return f.shim().version
+}
+
+// DecodeClient 解码一帧客户端命令
+func (f WireFormat) DecodeClient(data []byte) (ClientCommand, error) {
+	return f.shim().decodeClient(data)
+}
+
+// DecodeServer 解码一帧服务器命令（同 DecodeStrict）
+func (f WireFormat) DecodeServer(data []byte) (ServerCommand, error) {
+	return f.shim().decodeServer(data)
+}
+
+// EncodeClient 编码客户端命令为帧数据（不含长度前缀），可通过 Stream.SendRaw 发送
+func (f WireFormat) EncodeClient(cmd ClientCommand) ([]byte, error) {
+	if !ClientCommandSupported(f.Protocol(), &cmd) {
+		return nil, fmt.Errorf("协议版本 %d 不支持该命令", f.Protocol())
+	}
+	w := f.shim().writer()
+	defer ReleaseBinaryWriter(w)
+	if err := cmd.WriteBinary(w); err != nil {
+		return nil, err
+	}
+	return append([]byte(nil), w.Data()...), nil
+}
+
+// FrameReader 从单向的原始字节流（握手之后的部分）中逐帧读取，启用压缩时先解压
+type FrameReader struct {
+	r        io.Reader
+	features StreamFeatures
+}
+
+// NewFrameReader 创建帧读取器；启用压缩且 r 实现了 io.ByteReader 时，解压不会多读 r 中当前帧之后的数据
+func (f WireFormat) NewFrameReader(r io.Reader) *FrameReader {
+	if f.Features&FeatureDeflate != 0 {
+		r = flate.NewReader(r)
+	}
+	return &FrameReader{r: r, features: f.Features}
+}
+
+// Next 读取下一帧的数据，数据结束时返回 io.EOF，帧不完整时返回 io.ErrUnexpectedEOF
+func (r *FrameReader) Next() ([]byte, error) {
+	return readFrame(r.r, r.features)
+}
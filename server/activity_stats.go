@@ -0,0 +1,408 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 活动统计默认参数
+const (
+	DefaultActivitySampleInterval = 60 // 在线人数采样间隔（秒）
+	DefaultActivityRetentionDays  = 30 // 统计数据保留天数
+)
+
+// ActivityBucket 单个小时的活动统计
+type ActivityBucket struct {
+	Hour      int64 `json:"hour"`       // 小时起始时间（Unix秒）
+	Matches   int   `json:"matches"`    // 开局次数
+	Players   int   `json:"players"`    // 开局玩家人次
+	Samples   int   `json:"samples"`    // 在线人数采样次数
+	UserSum   int   `json:"user_sum"`   // 采样在线人数之和
+	PeakUsers int   `json:"peak_users"` // 采样在线人数峰值
+}
+
+// AvgUsers 平均在线人数
+func (b *ActivityBucket) AvgUsers() float64 {
+	if b.Samples == 0 {
+		return 0
+	}
+	return float64(b.UserSum) / float64(b.Samples)
+}
+
+// ActivityStats 按小时聚合的服务器与房间活动统计
+type ActivityStats struct {
+	mu     sync.Mutex
+	saveMu sync.Mutex
+	path   string
+	dirty  bool // 上次保存后是否有变更
+
+	Server map[int64]*ActivityBucket            `json:"server"` // 小时 -> 统计
+	Rooms  map[string]map[int64]*ActivityBucket `json:"rooms"`  // 房间ID -> 小时 -> 统计
+
+	Peaks      PeakSet            `json:"peaks"`       // 历史负载峰值
+	DailyPeaks map[int64]*PeakSet `json:"daily_peaks"` // 当天0点 -> 当日负载峰值
+
+	storeFreeze // 平滑升级后停止写文件（见 handover.go）
+}
+
+// NewActivityStats 创建活动统计
+func NewActivityStats(path string) *ActivityStats {
+	return &ActivityStats{
+		path:       path,
+		Server:     make(map[int64]*ActivityBucket),
+		Rooms:      make(map[string]map[int64]*ActivityBucket),
+		DailyPeaks: make(map[int64]*PeakSet),
+	}
+}
+
+// Load 从文件加载统计
+func (a *ActivityStats) Load() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	data, err := os.ReadFile(a.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if err := json.Unmarshal(data, a); err != nil {
+		return err
+	}
+	if a.Server == nil {
+		a.Server = make(map[int64]*ActivityBucket)
+	}
+	if a.Rooms == nil {
+		a.Rooms = make(map[string]map[int64]*ActivityBucket)
+	}
+	if a.DailyPeaks == nil {
+		a.DailyPeaks = make(map[int64]*PeakSet)
+	}
+	return nil
+}
+
+// Save 保存统计到文件（无变更时跳过）
+func (a *ActivityStats) Save() error {
+	a.saveMu.Lock()
+	defer a.saveMu.Unlock()
+	if a.Frozen() {
+		return nil
+	}
+
+	a.mu.Lock()
+	if !a.dirty {
+		a.mu.Unlock()
+		return nil
+	}
+	data, err := json.Marshal(a)
+	a.dirty = false
+	a.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(a.path, data, false); err != nil {
+		a.markDirty()
+		return err
+	}
+	return nil
+}
+
+// markDirty 标记有未保存的变更（保存失败时调用，下次保存重试）
+func (a *ActivityStats) markDirty() {
+	a.mu.Lock()
+	a.dirty = true
+	a.mu.Unlock()
+}
+
+// bucket 获取（必要时创建）指定小时的统计，调用方需持有锁
+func bucket(buckets map[int64]*ActivityBucket, at time.Time) *ActivityBucket {
+	hour := at.Truncate(time.Hour).Unix()
+	b, ok := buckets[hour]
+	if !ok {
+		b = &ActivityBucket{Hour: hour}
+		buckets[hour] = b
+	}
+	return b
+}
+
+// roomBuckets 获取（必要时创建）房间的统计表，调用方需持有锁
+func (a *ActivityStats) roomBuckets(roomID string) map[int64]*ActivityBucket {
+	buckets, ok := a.Rooms[roomID]
+	if !ok {
+		buckets = make(map[int64]*ActivityBucket)
+		a.Rooms[roomID] = buckets
+	}
+	return buckets
+}
+
+// RecordMatch 记录一次开局
+func (a *ActivityStats) RecordMatch(roomID string, players int, at time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.dirty = true
+	for _, b := range []*ActivityBucket{bucket(a.Server, at), bucket(a.roomBuckets(roomID), at)} {
+		b.Matches++
+		b.Players += players
+	}
+}
+
+// Sample 记录一次在线人数采样
+// rooms 为房间ID -> 房间内人数（含观察者）
+func (a *ActivityStats) Sample(online int, rooms map[string]int, at time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.dirty = true
+	addSample(bucket(a.Server, at), online)
+	for roomID, count := range rooms {
+		addSample(bucket(a.roomBuckets(roomID), at), count)
+	}
+}
+
+func addSample(b *ActivityBucket, count int) {
+	b.Samples++
+	b.UserSum += count
+	if count > b.PeakUsers {
+		b.PeakUsers = count
+	}
+}
+
+// Prune 删除早于指定时间的统计
+// 返回值：删除的小时统计与每日峰值数量
+func (a *ActivityStats) Prune(before time.Time) int {
+	cutoff := before.Truncate(time.Hour).Unix()
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	pruned := pruneBuckets(a.Server, cutoff)
+	for roomID, buckets := range a.Rooms {
+		pruned += pruneBuckets(buckets, cutoff)
+		if len(buckets) == 0 {
+			delete(a.Rooms, roomID)
+		}
+	}
+	pruned += a.pruneDailyPeaks(before)
+	if pruned > 0 {
+		a.dirty = true
+	}
+	return pruned
+}
+
+func pruneBuckets(buckets map[int64]*ActivityBucket, cutoff int64) int {
+	pruned := 0
+	for hour := range buckets {
+		if hour < cutoff {
+			delete(buckets, hour)
+			pruned++
+		}
+	}
+	return pruned
+}
+
+// Query 查询 [from, to) 内的小时统计（按时间升序）
+// roomID 为空时查询服务器整体统计
+func (a *ActivityStats) Query(roomID string, from, to time.Time) []ActivityBucket {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	buckets := a.Server
+	if roomID != "" {
+		buckets = a.Rooms[roomID]
+	}
+	start, end := from.Truncate(time.Hour).Unix(), to.Unix()
+	result := make([]ActivityBucket, 0)
+	for hour, b := range buckets {
+		if hour >= start && hour < end {
+			result = append(result, *b)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Hour < result[j].Hour
+	})
+	return result
+}
+
+// ActivityPoint 活动统计序列中的一个点
+type ActivityPoint struct {
+	Time      int64   `json:"time"` // 起始时间（Unix秒）
+	Matches   int     `json:"matches"`
+	Players   int     `json:"players"`
+	AvgUsers  float64 `json:"avg_users"`
+	PeakUsers int     `json:"peak_users"`
+}
+
+// ActivityHour 一天中某个小时的平均活动
+type ActivityHour struct {
+	Hour     int     `json:"hour"` // 0-23（服务器本地时间）
+	Matches  float64 `json:"matches"`
+	AvgUsers float64 `json:"avg_users"`
+}
+
+// ActivityReport 活动统计报告
+type ActivityReport struct {
+	Series     []ActivityPoint `json:"series"`
+	Heatmap    [7][24]float64  `json:"heatmap"`     // 星期(0=周日) x 小时 的平均在线人数
+	Hours      []ActivityHour  `json:"hours"`       // 各小时平均活动（按小时排序）
+	PeakHours  []int           `json:"peak_hours"`  // 平均在线人数最高的3个小时
+	QuietHours []int           `json:"quiet_hours"` // 平均在线人数最低的3个小时
+}
+
+// BuildActivityReport 将小时统计汇总为序列、热力图与高峰时段
+// daily 为 true 时序列按天聚合；loc 决定日期与小时的划分
+func BuildActivityReport(buckets []ActivityBucket, daily bool, loc *time.Location) ActivityReport {
+	report := ActivityReport{Series: make([]ActivityPoint, 0), Hours: make([]ActivityHour, 0)}
+
+	var (
+		heatSum   [7][24]float64
+		heatCount [7][24]int
+		hourAvg   [24]float64
+		hourMatch [24]int
+		hourCount [24]int
+	)
+
+	// 按天聚合时平均在线人数取当天各小时平均值的均值
+	var point *ActivityPoint
+	hours := 0
+	flush := func() {
+		if point != nil {
+			point.AvgUsers /= float64(hours)
+			report.Series = append(report.Series, *point)
+		}
+	}
+
+	for i := range buckets {
+		b := &buckets[i]
+		at := time.Unix(b.Hour, 0).In(loc)
+
+		weekday, hour := int(at.Weekday()), at.Hour()
+		heatSum[weekday][hour] += b.AvgUsers()
+		heatCount[weekday][hour]++
+		hourAvg[hour] += b.AvgUsers()
+		hourMatch[hour] += b.Matches
+		hourCount[hour]++
+
+		pointTime := b.Hour
+		if daily {
+			pointTime = time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, loc).Unix()
+		}
+		if point == nil || point.Time != pointTime {
+			flush()
+			point = &ActivityPoint{Time: pointTime}
+			hours = 0
+		}
+		point.Matches += b.Matches
+		point.Players += b.Players
+		point.AvgUsers += b.AvgUsers()
+		if b.PeakUsers > point.PeakUsers {
+			point.PeakUsers = b.PeakUsers
+		}
+		hours++
+	}
+	flush()
+
+	for weekday := range heatSum {
+		for hour := range heatSum[weekday] {
+			if heatCount[weekday][hour] > 0 {
+				report.Heatmap[weekday][hour] = heatSum[weekday][hour] / float64(heatCount[weekday][hour])
+			}
+		}
+	}
+
+	for hour := 0; hour < 24; hour++ {
+		if hourCount[hour] == 0 {
+			continue
+		}
+		report.Hours = append(report.Hours, ActivityHour{
+			Hour:     hour,
+			Matches:  float64(hourMatch[hour]) / float64(hourCount[hour]),
+			AvgUsers: hourAvg[hour] / float64(hourCount[hour]),
+		})
+	}
+
+	ranked := append([]ActivityHour(nil), report.Hours...)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		return ranked[i].AvgUsers > ranked[j].AvgUsers
+	})
+	report.PeakHours = make([]int, 0, 3)
+	report.QuietHours = make([]int, 0, 3)
+	for i := 0; i < len(ranked) && i < 3; i++ {
+		report.PeakHours = append(report.PeakHours, ranked[i].Hour)
+		report.QuietHours = append(report.QuietHours, ranked[len(ranked)-1-i].Hour)
+	}
+	return report
+}
+
+// getActivityStatsPath 获取活动统计文件路径
+func (s *Server) getActivityStatsPath() string {
+	if s.config.ActivityStatsPath != "" {
+		return s.config.ActivityStatsPath
+	}
+	if home := os.Getenv("PHIRA_MP_HOME"); home != "" {
+		return filepath.Join(home, "activity_stats.json")
+	}
+	return "activity_stats.json"
+}
+
+// GetActivityStats 获取活动统计
+func (s *Server) GetActivityStats() *ActivityStats {
+	return s.activityStats
+}
+
+// recordMatchActivity 记录房间开局
+func (s *Server) recordMatchActivity(room *Room) {
+	if s.activityStats == nil {
+		return
+	}
+	s.activityStats.RecordMatch(room.ID.Value, len(room.GetUsers()), time.Now())
+	s.observePeaks()
+}
+
+// sampleActivity 采样当前在线人数与各房间人数
+func (s *Server) sampleActivity() {
+	online := 0
+	s.users.Range(func(_, value interface{}) bool {
+		if !value.(*User).IsDisconnected() {
+			online++
+		}
+		return true
+	})
+	rooms := make(map[string]int)
+	for _, room := range s.GetAllRooms() {
+		rooms[room.ID.Value] = len(room.GetAllUsers())
+	}
+	s.activityStats.Sample(online, rooms, time.Now())
+	s.observePeaks()
+}
+
+// activityLoop 定期采样在线人数并保存统计
+func (s *Server) activityLoop() {
+	interval := time.Duration(s.config.ActivitySampleInterval) * time.Second
+	if interval <= 0 || s.activityStats == nil {
+		return
+	}
+	retention := s.config.ActivityRetentionDays
+	if retention <= 0 {
+		retention = DefaultActivityRetentionDays
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			s.sampleActivity()
+			s.activityStats.Prune(time.Now().AddDate(0, 0, -retention))
+			if err := s.activityStats.Save(); err != nil {
+				log.Printf("保存活动统计失败: %v", err)
+			}
+		}
+	}
+}
@@ -2,30 +2,78 @@ package server
 
 import (
 	"encoding/json"
+	"log"
 	"os"
+	"sort"
 	"sync"
+	"time"
 )
 
 // AdminData 管理员数据
 type AdminData struct {
-	mu sync.RWMutex
+	mu     sync.RWMutex
+	saveMu sync.Mutex // 保证同一时间只有一次写文件
+	dirty  bool       // 上次保存后是否有修改
 
 	// 服务器级封禁（禁止进入服务器）
-	BannedUsers map[int32]bool `json:"banned_users"`
+	BannedUsers map[int32]*BanEntry `json:"banned_users"`
 
 	// 房间级封禁（禁止进入特定房间）
-	RoomBans map[string]map[int32]bool `json:"room_bans"` // roomId -> userId -> banned
+	RoomBans map[string]map[int32]*BanEntry `json:"room_bans"` // roomId -> userId -> 封禁信息
+
+	storeFreeze // 平滑升级后停止写文件（见 handover.go）
+}
+
+// BanEntry 封禁信息
+type BanEntry struct {
+	Reason    string `json:"reason,omitempty"`
+	CreatedAt int64  `json:"created_at,omitempty"` // 封禁时间（毫秒时间戳）
+	ExpiresAt int64  `json:"expires_at,omitempty"` // 到期时间（毫秒时间戳），0表示永久
+
+	revoked bool // 旧版数据中的 false
+}
+
+// UnmarshalJSON 兼容旧版数据（userId -> true）
+func (e *BanEntry) UnmarshalJSON(data []byte) error {
+	var legacy bool
+	if err := json.Unmarshal(data, &legacy); err == nil {
+		*e = BanEntry{revoked: !legacy}
+		return nil
+	}
+	type plain BanEntry
+	return json.Unmarshal(data, (*plain)(e))
+}
+
+// Active 封禁在指定时间是否仍然有效
+func (e *BanEntry) Active(now time.Time) bool {
+	return e != nil && !e.revoked && (e.ExpiresAt == 0 || now.UnixMilli() < e.ExpiresAt)
+}
+
+// BanRecord 带用户ID的封禁信息，用于列表展示
+type BanRecord struct {
+	UserID int32 `json:"user_id"`
+	BanEntry
+}
+
+// newBanEntry 创建封禁信息，duration<=0 表示永久
+func newBanEntry(reason string, duration time.Duration) *BanEntry {
+	now := time.Now()
+	entry := &BanEntry{Reason: reason, CreatedAt: now.UnixMilli()}
+	if duration > 0 {
+		entry.ExpiresAt = now.Add(duration).UnixMilli()
+	}
+	return entry
 }
 
 // NewAdminData 创建新的管理员数据
 func NewAdminData() *AdminData {
 	return &AdminData{
-		BannedUsers: make(map[int32]bool),
-		RoomBans:    make(map[string]map[int32]bool),
+		BannedUsers: make(map[int32]*BanEntry),
+		RoomBans:    make(map[string]map[int32]*BanEntry),
 	}
 }
 
-// Load 从文件加载数据
+// Load 从文件加载数据，文件损坏时尝试从上一版本的备份恢复
 func (a *AdminData) Load(path string) error {
 	a.mu.Lock()
 	defer a.mu.Unlock()
@@ -39,38 +87,97 @@ func (a *AdminData) Load(path string) error {
 		return err
 	}
 
-	return json.Unmarshal(data, a)
+	if err := json.Unmarshal(data, a); err != nil {
+		backup, berr := os.ReadFile(backupPath(path))
+		if berr != nil || json.Unmarshal(backup, a) != nil {
+			return err
+		}
+		log.Printf("管理员数据 %s 已损坏（%v），已从备份恢复", path, err)
+		a.dirty = true
+	}
+	if a.BannedUsers == nil {
+		a.BannedUsers = make(map[int32]*BanEntry)
+	}
+	if a.RoomBans == nil {
+		a.RoomBans = make(map[string]map[int32]*BanEntry)
+	}
+	if a.pruneLocked(time.Now()) > 0 {
+		a.dirty = true
+	}
+	return nil
 }
 
-// Save 保存数据到文件
+// Save 保存数据到文件（先写临时文件再替换，原文件保留为 .bak 备份）
 func (a *AdminData) Save(path string) error {
-	a.mu.RLock()
-	defer a.mu.RUnlock()
+	a.saveMu.Lock()
+	defer a.saveMu.Unlock()
+	if a.Frozen() {
+		return nil
+	}
 
+	a.mu.Lock()
 	data, err := json.MarshalIndent(a, "", "  ")
+	a.dirty = false
+	a.mu.Unlock()
 	if err != nil {
 		return err
 	}
 
-	return os.WriteFile(path, data, 0644)
+	if err := writeFileAtomic(path, data, true); err != nil {
+		a.markDirty()
+		return err
+	}
+	return nil
+}
+
+// Dirty 上次保存后是否有修改
+func (a *AdminData) Dirty() bool {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return a.dirty
+}
+
+func (a *AdminData) markDirty() {
+	a.mu.Lock()
+	a.dirty = true
+	a.mu.Unlock()
 }
 
 // IsUserBanned 检查用户是否被服务器封禁
 func (a *AdminData) IsUserBanned(userID int32) bool {
 	a.mu.RLock()
 	defer a.mu.RUnlock()
-	return a.BannedUsers[userID]
+	return a.BannedUsers[userID].Active(time.Now())
 }
 
-// BanUser 封禁/解封用户
+// BanUser 永久封禁/解封用户
 func (a *AdminData) BanUser(userID int32, banned bool) {
-	a.mu.Lock()
-	defer a.mu.Unlock()
 	if banned {
-		a.BannedUsers[userID] = true
+		a.BanUserWithReason(userID, "", 0)
 	} else {
-		delete(a.BannedUsers, userID)
+		a.UnbanUser(userID)
+	}
+}
+
+// BanUserWithReason 封禁用户并记录原因，duration<=0 表示永久
+func (a *AdminData) BanUserWithReason(userID int32, reason string, duration time.Duration) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.BannedUsers[userID] = newBanEntry(reason, duration)
+	a.dirty = true
+}
+
+// UnbanUser 解除服务器封禁，返回用户此前是否处于封禁中
+func (a *AdminData) UnbanUser(userID int32) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	entry, ok := a.BannedUsers[userID]
+	if !ok {
+		return false
 	}
+	delete(a.BannedUsers, userID)
+	a.dirty = true
+	return entry.Active(time.Now())
 }
 
 // IsUserBannedFromRoom 检查用户是否被禁止进入特定房间
@@ -79,29 +186,48 @@ func (a *AdminData) IsUserBannedFromRoom(userID int32, roomID string) bool {
 	defer a.mu.RUnlock()
 
 	if roomBans, ok := a.RoomBans[roomID]; ok {
-		return roomBans[userID]
+		return roomBans[userID].Active(time.Now())
 	}
 	return false
 }
 
-// BanUserFromRoom 封禁/解封用户进入房间
+// BanUserFromRoom 永久封禁/解封用户进入房间
 func (a *AdminData) BanUserFromRoom(userID int32, roomID string, banned bool) {
+	if banned {
+		a.BanUserFromRoomWithReason(userID, roomID, "", 0)
+	} else {
+		a.UnbanUserFromRoom(userID, roomID)
+	}
+}
+
+// BanUserFromRoomWithReason 禁止用户进入房间并记录原因，duration<=0 表示永久
+func (a *AdminData) BanUserFromRoomWithReason(userID int32, roomID string, reason string, duration time.Duration) {
 	a.mu.Lock()
 	defer a.mu.Unlock()
 
 	if a.RoomBans[roomID] == nil {
-		a.RoomBans[roomID] = make(map[int32]bool)
+		a.RoomBans[roomID] = make(map[int32]*BanEntry)
 	}
+	a.RoomBans[roomID][userID] = newBanEntry(reason, duration)
+	a.dirty = true
+}
 
-	if banned {
-		a.RoomBans[roomID][userID] = true
-	} else {
-		delete(a.RoomBans[roomID], userID)
-		// 如果房间没有封禁用户了，删除该房间的map
-		if len(a.RoomBans[roomID]) == 0 {
-			delete(a.RoomBans, roomID)
-		}
+// UnbanUserFromRoom 解除房间封禁，返回用户此前是否处于封禁中
+func (a *AdminData) UnbanUserFromRoom(userID int32, roomID string) bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	entry, ok := a.RoomBans[roomID][userID]
+	if !ok {
+		return false
+	}
+	delete(a.RoomBans[roomID], userID)
+	// 如果房间没有封禁用户了，删除该房间的map
+	if len(a.RoomBans[roomID]) == 0 {
+		delete(a.RoomBans, roomID)
 	}
+	a.dirty = true
+	return entry.Active(time.Now())
 }
 
 // GetBannedUsers 获取所有被封禁的用户
@@ -109,9 +235,12 @@ func (a *AdminData) GetBannedUsers() []int32 {
 	a.mu.RLock()
 	defer a.mu.RUnlock()
 
+	now := time.Now()
 	result := make([]int32, 0, len(a.BannedUsers))
-	for userID := range a.BannedUsers {
-		result = append(result, userID)
+	for userID, entry := range a.BannedUsers {
+		if entry.Active(now) {
+			result = append(result, userID)
+		}
 	}
 	return result
 }
@@ -122,11 +251,119 @@ func (a *AdminData) GetRoomBans(roomID string) []int32 {
 	defer a.mu.RUnlock()
 
 	if roomBans, ok := a.RoomBans[roomID]; ok {
+		now := time.Now()
 		result := make([]int32, 0, len(roomBans))
-		for userID := range roomBans {
-			result = append(result, userID)
+		for userID, entry := range roomBans {
+			if entry.Active(now) {
+				result = append(result, userID)
+			}
 		}
 		return result
 	}
 	return nil
 }
+
+// UserBanList 获取服务器封禁列表（按用户ID排序，不含已过期的封禁）
+func (a *AdminData) UserBanList() []BanRecord {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return banRecords(a.BannedUsers, time.Now())
+}
+
+// RoomBanList 获取房间封禁列表（按用户ID排序，不含已过期的封禁）
+func (a *AdminData) RoomBanList(roomID string) []BanRecord {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+	return banRecords(a.RoomBans[roomID], time.Now())
+}
+
+func banRecords(bans map[int32]*BanEntry, now time.Time) []BanRecord {
+	result := make([]BanRecord, 0, len(bans))
+	for userID, entry := range bans {
+		if entry.Active(now) {
+			result = append(result, BanRecord{UserID: userID, BanEntry: *entry})
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].UserID < result[j].UserID })
+	return result
+}
+
+// PruneExpiredBans 清理已过期的封禁，返回清理数量
+func (a *AdminData) PruneExpiredBans() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	n := a.pruneLocked(time.Now())
+	if n > 0 {
+		a.dirty = true
+	}
+	return n
+}
+
+// ClearNotes 清空指定用户在服务器与各房间封禁中的原因（封禁保持不变），返回清空数量
+func (a *AdminData) ClearNotes(userID int32) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	n := 0
+	clearNote := func(entry *BanEntry) {
+		if entry != nil && entry.Reason != "" {
+			entry.Reason = ""
+			n++
+		}
+	}
+	clearNote(a.BannedUsers[userID])
+	for _, bans := range a.RoomBans {
+		clearNote(bans[userID])
+	}
+	if n > 0 {
+		a.dirty = true
+	}
+	return n
+}
+
+// PruneNotes 清空创建时间早于 before 的封禁原因（封禁保持不变），返回清空数量
+func (a *AdminData) PruneNotes(before time.Time) int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	cutoff := before.UnixMilli()
+	n := 0
+	prune := func(entry *BanEntry) {
+		if entry.Reason != "" && entry.CreatedAt < cutoff {
+			entry.Reason = ""
+			n++
+		}
+	}
+	for _, entry := range a.BannedUsers {
+		prune(entry)
+	}
+	for _, bans := range a.RoomBans {
+		for _, entry := range bans {
+			prune(entry)
+		}
+	}
+	if n > 0 {
+		a.dirty = true
+	}
+	return n
+}
+
+func (a *AdminData) pruneLocked(now time.Time) int {
+	n := 0
+	for userID, entry := range a.BannedUsers {
+		if !entry.Active(now) {
+			delete(a.BannedUsers, userID)
+			n++
+		}
+	}
+	for roomID, bans := range a.RoomBans {
+		for userID, entry := range bans {
+			if !entry.Active(now) {
+				delete(bans, userID)
+				n++
+			}
+		}
+		if len(bans) == 0 {
+			delete(a.RoomBans, roomID)
+		}
+	}
+	return n
+}
@@ -0,0 +1,270 @@
+package server
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 管理员Cookie会话（供浏览器管理面板使用，无需在JS中保存token）
+const (
+	AdminSessionCookie = "phira_admin_session" // 会话Cookie名（HttpOnly）
+	AdminCSRFHeader    = "X-CSRF-Token"        // 非GET请求必须携带的CSRF头
+	AdminSessionExpire = 4 * time.Hour         // 会话有效期
+)
+
+// AdminSession 管理员Cookie会话
+type AdminSession struct {
+	ID        string
+	CSRFToken string
+	Identity  string // 登录方式或OIDC用户，仅用于日志
+	ClientIP  string
+	ExpiresAt time.Time
+}
+
+// AdminSessionManager 管理员Cookie会话管理器
+type AdminSessionManager struct {
+	mu       sync.Mutex
+	sessions map[string]*AdminSession
+}
+
+// NewAdminSessionManager 创建管理员会话管理器
+func NewAdminSessionManager() *AdminSessionManager {
+	return &AdminSessionManager{sessions: make(map[string]*AdminSession)}
+}
+
+// Create 创建会话（顺带清理过期会话）
+func (m *AdminSessionManager) Create(identity, clientIP string) *AdminSession {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	for id, session := range m.sessions {
+		if now.After(session.ExpiresAt) {
+			delete(m.sessions, id)
+		}
+	}
+
+	session := &AdminSession{
+		ID:        randomHex(32),
+		CSRFToken: randomHex(32),
+		Identity:  identity,
+		ClientIP:  clientIP,
+		ExpiresAt: now.Add(AdminSessionExpire),
+	}
+	m.sessions[session.ID] = session
+	return session
+}
+
+// Get 获取未过期的会话
+func (m *AdminSessionManager) Get(id string) *AdminSession {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session, ok := m.sessions[id]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(session.ExpiresAt) {
+		delete(m.sessions, id)
+		return nil
+	}
+	return session
+}
+
+// Revoke 注销会话
+func (m *AdminSessionManager) Revoke(id string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, id)
+}
+
+// randomHex 生成 n 字节的随机十六进制串
+func randomHex(n int) string {
+	bytes := make([]byte, n)
+	if _, err := rand.Read(bytes); err != nil {
+		return generateUUID() + generateUUID()
+	}
+	return hex.EncodeToString(bytes)
+}
+
+// isSameOrigin 检查请求来源是否与本站一致（无Origin/Referer时视为同源，如直接访问）
+func isSameOrigin(r *http.Request) bool {
+	if site := r.Header.Get("Sec-Fetch-Site"); site != "" && site != "same-origin" && site != "none" {
+		return false
+	}
+	source := r.Header.Get("Origin")
+	if source == "" {
+		source = r.Header.Get("Referer")
+	}
+	if source == "" {
+		return true
+	}
+	u, err := url.Parse(source)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(u.Host, r.Host)
+}
+
+// isSafeMethod 是否为只读请求（无需CSRF校验）
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// cookieSession 获取请求Cookie中的管理员会话
+func (h *HTTPServer) cookieSession(r *http.Request) *AdminSession {
+	cookie, err := r.Cookie(AdminSessionCookie)
+	if err != nil || cookie.Value == "" {
+		return nil
+	}
+	return h.adminSessions.Get(cookie.Value)
+}
+
+// checkCookieSession 校验Cookie会话、来源与CSRF token
+// 返回值：错误码（空字符串表示通过）
+func (h *HTTPServer) checkCookieSession(r *http.Request, session *AdminSession) string {
+	if !isSameOrigin(r) {
+		return "cross-origin"
+	}
+	if !isSafeMethod(r.Method) && r.Header.Get(AdminCSRFHeader) != session.CSRFToken {
+		return "csrf-failed"
+	}
+	return ""
+}
+
+// setSessionCookie 写入会话Cookie（HttpOnly + SameSite=Strict，HTTPS下附加Secure）
+func setSessionCookie(w http.ResponseWriter, r *http.Request, value string, expires time.Time) {
+	maxAge := int(time.Until(expires).Seconds())
+	if value == "" {
+		maxAge = -1
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     AdminSessionCookie,
+		Value:    value,
+		Path:     "/",
+		Expires:  expires,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https"),
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// authenticateAdminCredential 校验永久token、临时token或OIDC令牌
+// 返回值：登录方式（用于日志），是否通过
+func (h *HTTPServer) authenticateAdminCredential(token, clientIP string) (string, bool) {
+	if token == "" {
+		return "", false
+	}
+	if h.config.AdminToken != "" && token == h.config.AdminToken {
+		return "admin_token", true
+	}
+	if h.oidc != nil && LooksLikeJWT(token) {
+		claims, err := h.oidc.Verify(token)
+		if err != nil {
+			log.Printf("[安全] IP %s OIDC令牌验证失败: %v", clientIP, err)
+			return "", false
+		}
+		return "oidc:" + claims.Display(), true
+	}
+	// 配置了永久token时临时token不可用（与OTP接口一致）
+	if h.config.AdminToken == "" && h.otpManager.ValidateTempToken(token, clientIP) {
+		return "otp", true
+	}
+	return "", false
+}
+
+// AdminLoginRequest 管理员登录请求
+type AdminLoginRequest struct {
+	Token string `json:"token"` // 永久token、OTP临时token或OIDC令牌
+}
+
+// handleAdminLogin 用token换取HttpOnly会话Cookie与CSRF token
+func (h *HTTPServer) handleAdminLogin(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method-not-allowed")
+		return
+	}
+	if !isSameOrigin(r) {
+		writeError(w, http.StatusForbidden, "cross-origin")
+		return
+	}
+
+	clientIP := h.getClientIP(r)
+	if !h.authLimiter.AllowAttempt(clientIP) {
+		writeError(w, http.StatusTooManyRequests, "too-many-requests")
+		log.Printf("[安全] IP %s 触发认证限流，封禁 %v", clientIP, h.authLimiter.GetBlockTimeRemaining(clientIP))
+		return
+	}
+
+	var req AdminLoginRequest
+	if err := parseBody(r, &req); err != nil || req.Token == "" {
+		writeError(w, http.StatusBadRequest, "bad-request")
+		return
+	}
+
+	identity, ok := h.authenticateAdminCredential(req.Token, clientIP)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		log.Printf("[安全] IP %s 管理员登录失败，剩余尝试次数: %d", clientIP, h.authLimiter.GetRemainingAttempts(clientIP))
+		return
+	}
+	h.authLimiter.RecordSuccess(clientIP)
+
+	session := h.adminSessions.Create(identity, clientIP)
+	setSessionCookie(w, r, session.ID, session.ExpiresAt)
+	log.Printf("管理员通过 %s 登录管理面板 (IP: %s)", identity, clientIP)
+
+	writeOK(w, map[string]interface{}{
+		"csrfToken": session.CSRFToken,
+		"expiresAt": session.ExpiresAt.UnixMilli(),
+		"expiresIn": AdminSessionExpire.Milliseconds(),
+	})
+}
+
+// handleAdminSession 查询当前Cookie会话（页面刷新后重新获取CSRF token）
+func (h *HTTPServer) handleAdminSession(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method-not-allowed")
+		return
+	}
+	session := h.cookieSession(r)
+	if session == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	if code := h.checkCookieSession(r, session); code != "" {
+		writeError(w, http.StatusForbidden, code)
+		return
+	}
+	writeOK(w, map[string]interface{}{
+		"csrfToken": session.CSRFToken,
+		"expiresAt": session.ExpiresAt.UnixMilli(),
+	})
+}
+
+// handleAdminLogout 注销Cookie会话
+func (h *HTTPServer) handleAdminLogout(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method-not-allowed")
+		return
+	}
+	session := h.cookieSession(r)
+	if session == nil {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+	if code := h.checkCookieSession(r, session); code != "" {
+		writeError(w, http.StatusForbidden, code)
+		return
+	}
+	h.adminSessions.Revoke(session.ID)
+	setSessionCookie(w, r, "", time.Unix(0, 0))
+	writeOK(w, nil)
+}
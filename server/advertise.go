@@ -0,0 +1,63 @@
+package server
+
+import (
+	"net"
+	"sort"
+	"strconv"
+)
+
+// 对外公布地址类型
+const (
+	AddressTypeIPv4   = "ipv4"
+	AddressTypeIPv6   = "ipv6"
+	AddressTypeDomain = "domain"
+)
+
+// AdvertiseAddress 对外公布的服务器连接地址
+type AdvertiseAddress struct {
+	Host     string `yaml:"host" json:"host"`               // IP或域名
+	Port     int    `yaml:"port" json:"port"`               // 端口（0则使用服务器监听端口）
+	Type     string `yaml:"type" json:"type"`               // ipv4, ipv6, domain（为空时自动识别）
+	Priority int    `yaml:"priority" json:"priority"`       // 优先级，数值越小越优先
+	Region   string `yaml:"region" json:"region,omitempty"` // 所在地区（可选，供客户端就近选择）
+	Address  string `yaml:"-" json:"address"`               // host:port（IPv6自动加方括号）
+}
+
+// addressType 根据主机名识别地址类型
+func addressType(host string) string {
+	ip := net.ParseIP(host)
+	switch {
+	case ip == nil:
+		return AddressTypeDomain
+	case ip.To4() != nil:
+		return AddressTypeIPv4
+	default:
+		return AddressTypeIPv6
+	}
+}
+
+// AdvertisedAddresses 获取对外公布的地址列表（按优先级排序，忽略未填写主机的条目）
+func (s *Server) AdvertisedAddresses() []AdvertiseAddress {
+	if len(s.config.AdvertiseAddresses) == 0 {
+		return nil
+	}
+
+	result := make([]AdvertiseAddress, 0, len(s.config.AdvertiseAddresses))
+	for _, addr := range s.config.AdvertiseAddresses {
+		if addr.Host == "" {
+			continue
+		}
+		if addr.Port <= 0 {
+			addr.Port = s.config.Port
+		}
+		if addr.Type == "" {
+			addr.Type = addressType(addr.Host)
+		}
+		addr.Address = net.JoinHostPort(addr.Host, strconv.Itoa(addr.Port))
+		result = append(result, addr)
+	}
+	sort.SliceStable(result, func(i, j int) bool {
+		return result[i].Priority < result[j].Priority
+	})
+	return result
+}
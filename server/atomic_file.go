@@ -0,0 +1,74 @@
+package server
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// storeFreeze 整文件存储的写入开关：平滑升级后新进程读取并接管同一份文件，
+// 旧进程冻结后不再保存，避免用旧数据覆盖新进程写入的内容（冻结后的修改只保留在内存中）
+type storeFreeze struct {
+	frozen atomic.Bool
+}
+
+// Freeze 设置是否冻结（冻结后 Save 直接返回）
+func (f *storeFreeze) Freeze(frozen bool) {
+	f.frozen.Store(frozen)
+}
+
+// Frozen 是否已冻结
+func (f *storeFreeze) Frozen() bool {
+	return f.frozen.Load()
+}
+
+// backupPath 数据文件上一版本的备份路径
+func backupPath(path string) string {
+	return path + ".bak"
+}
+
+// writeFileAtomic 先写入同目录的临时文件并刷盘，再重命名替换目标文件，
+// 写入过程中崩溃不会留下写了一半的文件；backup 为true时先将原文件复制为 .bak（原文件已损坏时保留旧备份）
+func writeFileAtomic(path string, data []byte, backup bool) error {
+	dir := filepath.Dir(path)
+	if dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.CreateTemp(dir, filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Chmod(tmp, 0644); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if backup {
+		if old, err := os.ReadFile(path); err == nil && json.Valid(old) {
+			if err := os.WriteFile(backupPath(path), old, 0644); err != nil {
+				os.Remove(tmp)
+				return err
+			}
+		}
+	}
+	return os.Rename(tmp, path)
+}
@@ -15,6 +15,7 @@ type authCacheEntry struct {
 	id   int32
 	name string
 	lang string
+	rks  float32
 	at   time.Time
 }
 
@@ -29,22 +30,22 @@ var globalAuthCache = &authCache{
 }
 
 // get 查询缓存，若命中且未过期则返回用户基本信息
-func (c *authCache) get(token string) (id int32, name, lang string, ok bool) {
+func (c *authCache) get(token string) (id int32, name, lang string, rks float32, ok bool) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	entry, exists := c.entries[token]
 	if !exists {
-		return 0, "", "", false
+		return 0, "", "", 0, false
 	}
 	if time.Since(entry.at) > authCacheTTL {
 		delete(c.entries, token)
-		return 0, "", "", false
+		return 0, "", "", 0, false
 	}
-	return entry.id, entry.name, entry.lang, true
+	return entry.id, entry.name, entry.lang, entry.rks, true
 }
 
 // set 写入缓存，同时清理过期条目
-func (c *authCache) set(token string, id int32, name, lang string) {
+func (c *authCache) set(token string, id int32, name, lang string, rks float32) {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 	// 清理过期条目，避免内存泄漏
@@ -58,6 +59,14 @@ func (c *authCache) set(token string, id int32, name, lang string) {
 		id:   id,
 		name: name,
 		lang: lang,
+		rks:  rks,
 		at:   now,
 	}
 }
+
+// delete 删除缓存（重新校验token时使用）
+func (c *authCache) delete(token string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.entries, token)
+}
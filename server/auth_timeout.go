@@ -0,0 +1,60 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"time"
+)
+
+// DefaultAuthTimeout 连接建立后完成握手与认证的默认秒数
+const DefaultAuthTimeout = 10
+
+// authTimeout 连接建立后须完成认证的时间（0表示不限制）
+func (s *Server) authTimeout() time.Duration {
+	if s.config.AuthTimeout <= 0 {
+		return 0
+	}
+	return time.Duration(s.config.AuthTimeout) * time.Second
+}
+
+// setAuthDeadline 为尚未握手的连接设置读写截止时间，返回是否设置
+func (s *Server) setAuthDeadline(conn net.Conn) bool {
+	timeout := s.authTimeout()
+	if timeout == 0 {
+		return false
+	}
+	return conn.SetDeadline(time.Now().Add(timeout)) == nil
+}
+
+// isTimeout 是否为读写超时错误
+func isTimeout(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// authExpired 会话是否超过认证时间仍未完成认证，超时时记录并返回true
+func (s *Session) authExpired(now time.Time) bool {
+	timeout := s.server.authTimeout()
+	if timeout == 0 || s.authenticated.Load() || now.Sub(s.ConnectedAt) <= timeout {
+		return false
+	}
+	s.server.reapedAuth.Add(1)
+	log.Printf("会话 %s 未在 %v 内完成认证，断开连接", s.ID, timeout)
+	return true
+}
+
+// ReapedConnections 未在认证超时内完成握手与完成认证而被断开的连接数
+func (s *Server) ReapedConnections() (handshake, auth int64) {
+	return s.reapedHandshake.Load(), s.reapedAuth.Load()
+}
+
+// writeAuthTimeoutMetrics 以Prometheus文本格式输出未完成认证而被断开的连接数
+func (h *HTTPServer) writeAuthTimeoutMetrics(w io.Writer) {
+	fmt.Fprintln(w, "# HELP phira_unauthenticated_reaped_total 未在认证超时内完成握手或认证而被断开的连接数")
+	fmt.Fprintln(w, "# TYPE phira_unauthenticated_reaped_total counter")
+	fmt.Fprintf(w, "phira_unauthenticated_reaped_total{stage=%q} %d\n", "handshake", h.server.reapedHandshake.Load())
+	fmt.Fprintf(w, "phira_unauthenticated_reaped_total{stage=%q} %d\n", "auth", h.server.reapedAuth.Load())
+}
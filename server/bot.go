@@ -0,0 +1,366 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"math/rand"
+	"net/http"
+	"time"
+
+	"phira-mp/common"
+)
+
+// BotIDBase 模拟玩家ID起点，模拟玩家ID从该值开始递减分配（介于系统ID与游客ID之间，不会与Phira用户ID冲突）
+const BotIDBase int32 = -100_000
+
+// 模拟玩家默认参数
+const (
+	DefaultBotPlaySeconds = 60 // 谱面未提供时长时每局的游玩秒数
+	maxBotPlaySeconds     = 600
+	botTickInterval       = 100 * time.Millisecond // 每次发送触摸帧、判定与实时成绩的间隔
+)
+
+// IsBot 是否为模拟玩家
+func (u *User) IsBot() bool {
+	return u.ID <= BotIDBase && u.ID > GuestIDBase
+}
+
+// Bot 服务器端的模拟玩家，没有会话，按房间状态自动准备、加载、发送模拟的触摸与判定数据并提交模拟成绩
+// 用于客户端与直播界面的测试，成绩不经过Phira主站，不写入回放与对局历史
+type Bot struct {
+	User *User
+	room *Room
+	play time.Duration // 每局游玩时长（0表示按谱面时长）
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// botGame 模拟玩家一局内的状态
+type botGame struct {
+	start    time.Time
+	notes    uint32
+	combo    int32
+	maxCombo int32
+	counts   [4]int32 // Perfect、Good、Bad、Miss
+}
+
+// SpawnBots 向房间加入 count 个模拟玩家（仅在选谱阶段），返回实际加入的模拟玩家（房间满员时可能少于 count）
+func (s *Server) SpawnBots(room *Room, count int, play time.Duration) ([]*Bot, error) {
+	if !s.config.Bots {
+		return nil, fmt.Errorf("未开启模拟玩家")
+	}
+	if room.GetState() != InternalStateSelectChart {
+		return nil, fmt.Errorf("游戏进行中")
+	}
+
+	var bots []*Bot
+	for i := 0; i < count; i++ {
+		seq := s.botSeq.Add(1)
+		user := NewUser(BotIDBase-seq, fmt.Sprintf("Bot%d", seq), "zh-CN", s)
+		if !room.AddUser(user, false) {
+			break
+		}
+		user.SetRoom(room)
+		room.Broadcast(common.ServerCommand{
+			Type:           common.ServerCmdOnJoinRoom,
+			OnJoinRoomUser: &common.UserInfo{ID: user.ID, Name: user.DisplayName()},
+		})
+		room.SendMessage(common.Message{Type: common.MsgJoinRoom, User: user.ID, Name: user.DisplayName()})
+
+		bot := &Bot{User: user, room: room, play: play, stop: make(chan struct{}), done: make(chan struct{})}
+		room.botsMu.Lock()
+		room.bots = append(room.bots, bot)
+		room.botsMu.Unlock()
+		go bot.run()
+		bots = append(bots, bot)
+	}
+	if len(bots) == 0 {
+		return nil, fmt.Errorf("房间已满")
+	}
+	log.Printf("[管理员] 房间 `%s` 加入 %d 个模拟玩家", room.ID.Value, len(bots))
+	return bots, nil
+}
+
+// RemoveBots 移除房间内的所有模拟玩家（对局中视为放弃），返回移除的数量
+func (r *Room) RemoveBots() int {
+	r.botsMu.Lock()
+	bots := r.bots
+	r.bots = nil
+	r.botsMu.Unlock()
+
+	for _, bot := range bots {
+		close(bot.stop)
+		<-bot.done
+	}
+	return len(bots)
+}
+
+// HasBots 房间内是否有模拟玩家
+func (r *Room) HasBots() bool {
+	r.botsMu.Lock()
+	defer r.botsMu.Unlock()
+	return len(r.bots) > 0
+}
+
+// removeBot 模拟玩家自行离开后从列表中移除
+func (r *Room) removeBot(bot *Bot) {
+	r.botsMu.Lock()
+	defer r.botsMu.Unlock()
+	for i, b := range r.bots {
+		if b == bot {
+			r.bots = append(r.bots[:i], r.bots[i+1:]...)
+			return
+		}
+	}
+}
+
+// hasHumans 房间内是否还有真实玩家或观察者
+func (r *Room) hasHumans() bool {
+	return len(humanUsers(r.GetUsers())) > 0 || len(r.GetMonitors()) > 0
+}
+
+// humanUsers 过滤掉模拟玩家
+func humanUsers(users []*User) []*User {
+	var humans []*User
+	for _, u := range users {
+		if !u.IsBot() {
+			humans = append(humans, u)
+		}
+	}
+	return humans
+}
+
+// run 按房间状态驱动模拟玩家，被移除、房间解散或只剩模拟玩家时离开
+func (b *Bot) run() {
+	defer close(b.done)
+	ticker := time.NewTicker(botTickInterval)
+	defer ticker.Stop()
+
+	var game *botGame
+	for {
+		select {
+		case <-b.stop:
+			b.leave()
+			return
+		case <-ticker.C:
+		}
+
+		room := b.room
+		if b.User.GetRoom() != room || room.server.GetRoom(room.ID) != room {
+			room.removeBot(b)
+			return
+		}
+		if !room.hasHumans() {
+			room.removeBot(b)
+			b.leave()
+			return
+		}
+
+		switch room.GetState() {
+		case InternalStateWaitForReady:
+			game = nil
+			b.ready()
+		case InternalStateLoading:
+			b.load()
+		case InternalStatePlaying:
+			if game == nil {
+				game = &botGame{start: time.Now()}
+			}
+			b.tick(game)
+		default:
+			game = nil
+		}
+	}
+}
+
+// ready 与 Ready 命令相同
+func (b *Bot) ready() {
+	room := b.room
+	if _, loaded := room.started.LoadOrStore(b.User.ID, true); loaded {
+		return
+	}
+	room.SendMessage(common.Message{Type: common.MsgReady, User: b.User.ID})
+	BroadcastRoomUpdate(room)
+	room.CheckAllReady()
+}
+
+// load 每次上报增加25%的加载进度
+func (b *Bot) load() {
+	room := b.room
+	progress := room.GetLoadProgress(b.User.ID)
+	if progress >= 100 {
+		return
+	}
+	room.SetLoadProgress(b.User.ID, progress+25)
+	room.BroadcastLoadProgress()
+	room.checkAllLoaded()
+}
+
+// playDuration 每局游玩时长
+func (b *Bot) playDuration() time.Duration {
+	if b.play > 0 {
+		return b.play
+	}
+	if chart := b.room.GetChart(); chart != nil && chart.Duration > 0 {
+		return time.Duration(chart.Duration * float32(time.Second))
+	}
+	return DefaultBotPlaySeconds * time.Second
+}
+
+// tick 发送一个触摸帧与一个判定（仅转发给观察者与WebSocket订阅者，不写入回放），到时后提交成绩
+func (b *Bot) tick(game *botGame) {
+	room := b.room
+	id := b.User.ID
+	if _, done := room.results.Load(id); done {
+		return
+	}
+	if _, aborted := room.aborted.Load(id); aborted {
+		return
+	}
+
+	elapsed := time.Since(game.start)
+	if elapsed >= b.playDuration() {
+		b.submit(game)
+		return
+	}
+
+	t := float32(elapsed.Seconds())
+	frame := common.TouchFrame{Time: t, Points: []common.TouchPoint{
+		{ID: 0, Pos: common.NewCompactPos(rand.Float32()*2-1, rand.Float32()*2-1)},
+	}}
+	judge := common.JudgeEvent{Time: t, LineID: uint32(rand.Intn(4)), NoteID: game.notes, Judgement: randomJudgement()}
+	game.notes++
+	game.counts[judge.Judgement]++
+	if judge.Judgement <= common.JudgementGood {
+		game.combo++
+		if game.combo > game.maxCombo {
+			game.maxCombo = game.combo
+		}
+	} else {
+		game.combo = 0
+	}
+
+	room.RecordJudges(id, []common.JudgeEvent{judge})
+	if room.IsLive() {
+		room.BroadcastMonitors(common.ServerCommand{Type: common.ServerCmdTouches, TouchesPlayer: id, TouchesFrames: []common.TouchFrame{frame}})
+		room.BroadcastMonitors(common.ServerCommand{Type: common.ServerCmdJudges, JudgesPlayer: id, JudgesEvents: []common.JudgeEvent{judge}})
+	}
+	record := game.record(id)
+	room.forwardScore(id, common.LiveScore{Score: record.Score, Combo: game.combo, Accuracy: record.Accuracy})
+}
+
+// randomJudgement 按常见的判定分布随机生成判定
+func randomJudgement() common.Judgement {
+	switch n := rand.Intn(100); {
+	case n < 90:
+		return common.JudgementPerfect
+	case n < 97:
+		return common.JudgementGood
+	case n < 99:
+		return common.JudgementBad
+	default:
+		return common.JudgementMiss
+	}
+}
+
+// record 按已产生的判定计算成绩（Phigros计分：准度占90万分，最大连击占10万分）
+func (g *botGame) record(player int32) *Record {
+	record := &Record{
+		Player:   player,
+		Perfect:  g.counts[common.JudgementPerfect],
+		Good:     g.counts[common.JudgementGood],
+		Bad:      g.counts[common.JudgementBad],
+		Miss:     g.counts[common.JudgementMiss],
+		MaxCombo: g.maxCombo,
+	}
+	if g.notes == 0 {
+		return record
+	}
+	total := float32(g.notes)
+	record.Accuracy = (float32(record.Perfect) + 0.65*float32(record.Good)) / total
+	record.Score = int32(900000*record.Accuracy + 100000*float32(g.maxCombo)/total)
+	record.FullCombo = record.Bad+record.Miss == 0
+	return record
+}
+
+// submit 提交模拟成绩（不经过Phira主站与成绩登记）
+func (b *Bot) submit(game *botGame) {
+	room := b.room
+	record := game.record(b.User.ID)
+	room.results.Store(b.User.ID, record)
+	room.SendMessage(room.playedMessage(b.User.ID, record))
+	room.CheckAllReady()
+}
+
+// leave 离开房间，对局中视为放弃（与 LeaveRoom 命令相同）
+func (b *Bot) leave() {
+	room := b.room
+	if b.User.GetRoom() != room {
+		return
+	}
+	if room.GetState() == InternalStatePlaying {
+		if _, done := room.results.Load(b.User.ID); !done {
+			room.aborted.Store(b.User.ID, true)
+			room.SendMessage(common.Message{Type: common.MsgAbort, User: b.User.ID})
+			room.CheckAllReady()
+		}
+	}
+	if room.OnUserLeave(b.User) {
+		room.server.RemoveRoom(room.ID, "房间为空")
+	}
+}
+
+// BotSpawnRequest 加入模拟玩家请求
+type BotSpawnRequest struct {
+	Count       int `json:"count"`       // 数量（1-64）
+	PlaySeconds int `json:"playSeconds"` // 每局游玩秒数（0表示按谱面时长，未知时为60秒）
+}
+
+// handleAdminRoomBots 处理 POST /admin/rooms/{id}/bots（加入模拟玩家）与 DELETE（移除全部模拟玩家）
+func (h *HTTPServer) handleAdminRoomBots(w http.ResponseWriter, r *http.Request, room *Room) {
+	switch r.Method {
+	case http.MethodPost:
+	case http.MethodDelete:
+		removed := room.RemoveBots()
+		log.Printf("[管理员] 房间 `%s` 移除 %d 个模拟玩家", room.ID.Value, removed)
+		writeOK(w, map[string]interface{}{"roomid": room.ID.Value, "removed": removed})
+		return
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method-not-allowed")
+		return
+	}
+
+	if !h.server.config.Bots {
+		writeError(w, http.StatusForbidden, "bots-disabled")
+		return
+	}
+	var req BotSpawnRequest
+	if err := parseBody(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad-request")
+		return
+	}
+	if req.Count < 1 || req.Count > 64 {
+		writeError(w, http.StatusBadRequest, "bad-count")
+		return
+	}
+	if req.PlaySeconds < 0 || req.PlaySeconds > maxBotPlaySeconds {
+		writeError(w, http.StatusBadRequest, "bad-play-seconds")
+		return
+	}
+	if room.GetState() != InternalStateSelectChart {
+		writeError(w, http.StatusConflict, "room-playing")
+		return
+	}
+
+	bots, err := h.server.SpawnBots(room, req.Count, time.Duration(req.PlaySeconds)*time.Second)
+	if err != nil {
+		writeError(w, http.StatusConflict, "room-full")
+		return
+	}
+	infos := make([]UserBrief, 0, len(bots))
+	for _, bot := range bots {
+		infos = append(infos, UserBrief{ID: bot.User.ID, Name: bot.User.Name})
+	}
+	writeOK(w, map[string]interface{}{"roomid": room.ID.Value, "bots": infos})
+}
@@ -0,0 +1,61 @@
+package server
+
+import "phira-mp/common"
+
+// handleValidateChart 处理谱面预检：查询谱面并检查此时能否选择，不修改房间谱面
+// 房主浏览谱面时客户端可据此提前显示是否可选，其他成员也可以预检（原因为“只有房主可以选择谱面”）
+func (s *Session) handleValidateChart(chartID int64) error {
+	fail := func(msg string) error {
+		return s.Send(common.ServerCommand{
+			Type:                common.ServerCmdValidateChart,
+			ValidateChartResult: &common.Result[common.ChartValidation]{Err: strPtr(msg)},
+		})
+	}
+
+	room := s.User.GetRoom()
+	if room == nil {
+		return fail("不在房间中")
+	}
+
+	// 与 SelectChart 相同在工作池中查询，同一会话同时只进行一次预检
+	if !s.validatingChart.CompareAndSwap(false, true) {
+		return fail("谱面查询中")
+	}
+	if !s.server.fetchPool.Submit(func() {
+		defer s.validatingChart.Store(false)
+		s.reportAsync(s.validateChart(room, chartID))
+	}) {
+		s.validatingChart.Store(false)
+		return fail("服务器繁忙，请稍后重试")
+	}
+	return nil
+}
+
+// validateChart 查询谱面并回复预检结果（在工作池中执行）
+func (s *Session) validateChart(room *Room, chartID int64) error {
+	chart, err := FetchChart(chartID)
+	if err != nil {
+		return s.Send(common.ServerCommand{
+			Type:                common.ServerCmdValidateChart,
+			ValidateChartResult: &common.Result[common.ChartValidation]{Err: strPtr("谱面不存在")},
+		})
+	}
+	return s.Send(common.ServerCommand{
+		Type: common.ServerCmdValidateChart,
+		ValidateChartResult: &common.Result[common.ChartValidation]{Ok: &common.ChartValidation{
+			Chart:  chart.Preview(),
+			Reason: s.chartSelectable(room, chart),
+		}},
+	})
+}
+
+// chartSelectable 检查当前用户此时能否将谱面设为房间谱面，返回不能选择的原因（可以选择时为空）
+func (s *Session) chartSelectable(room *Room, chart *Chart) string {
+	if s.User.GetRoom() != room || room.GetState() != InternalStateSelectChart {
+		return "无效状态"
+	}
+	if room.CheckHost(s.User) != nil {
+		return "只有房主可以选择谱面"
+	}
+	return room.checkChart(chart)
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"sync"
+
+	"phira-mp/common"
+)
+
+// DefaultChatHistorySize 每个房间默认保存的房间消息条数
+const DefaultChatHistorySize = 50
+
+// chatHistory 房间最近的房间消息（环形缓冲区），重连的玩家与后加入的观察者据此补全聊天记录
+type chatHistory struct {
+	mu       sync.Mutex
+	messages []common.Message // 容量达到上限后循环覆盖
+	next     int              // 下一条消息写入的位置（已写满时也是最早一条消息的位置）
+}
+
+// add 保存一条房间消息，size 为保存的条数上限（0表示不保存）
+func (h *chatHistory) add(msg common.Message, size int) {
+	if size <= 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.messages) < size {
+		h.messages = append(h.messages, msg)
+		h.next = len(h.messages) % size
+		return
+	}
+	h.messages[h.next] = msg
+	h.next = (h.next + 1) % len(h.messages)
+}
+
+// last 最近的 n 条消息（n 为0时返回全部），按发送时间从早到晚排列
+func (h *chatHistory) last(n int) []common.Message {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	ordered := make([]common.Message, 0, len(h.messages))
+	if len(h.messages) > 0 {
+		ordered = append(ordered, h.messages[h.next:]...)
+		ordered = append(ordered, h.messages[:h.next]...)
+	}
+	if n > 0 && n < len(ordered) {
+		ordered = ordered[len(ordered)-n:]
+	}
+	return ordered
+}
+
+// ChatHistory 房间最近的 n 条房间消息（n 为0时返回保存的全部），按发送时间从早到晚排列
+func (r *Room) ChatHistory(n int) []common.Message {
+	return r.history.last(n)
+}
+
+// handleChatHistory 处理房间消息历史查询
+func (s *Session) handleChatHistory(count uint16) error {
+	room := s.User.GetRoom()
+	if room == nil {
+		return s.Send(common.ServerCommand{
+			Type:              common.ServerCmdChatHistory,
+			ChatHistoryResult: &common.Result[common.ChatHistory]{Err: strPtr("不在房间中")},
+		})
+	}
+	history := common.ChatHistory{Messages: room.ChatHistory(int(count))}
+	return s.Send(common.ServerCommand{
+		Type:              common.ServerCmdChatHistory,
+		ChatHistoryResult: &common.Result[common.ChatHistory]{Ok: &history},
+	})
+}
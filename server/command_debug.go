@@ -0,0 +1,31 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"phira-mp/common"
+)
+
+// DebugCommandJSON 以JSON输出客户端命令用于调试日志，token打码
+func DebugCommandJSON(cmd common.ClientCommand) string {
+	if cmd.Token != "" {
+		cmd.Token = maskToken(cmd.Token)
+	}
+	if cmd.Password != "" {
+		cmd.Password = "****"
+	}
+	data, err := json.Marshal(cmd)
+	if err != nil {
+		return fmt.Sprintf(`{"type":%q,"error":%q}`, cmd.Type, err.Error())
+	}
+	return string(data)
+}
+
+// maskToken 只保留token前4位
+func maskToken(token string) string {
+	if len(token) <= 4 {
+		return "****"
+	}
+	return token[:4] + "****"
+}
@@ -0,0 +1,198 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"phira-mp/common"
+)
+
+// commandLatencyBuckets 命令处理耗时直方图的桶上界（秒）
+// 正常命令应在毫秒以内完成，较慢的桶用于发现阻塞接收循环的外部请求
+var commandLatencyBuckets = []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1, 5}
+
+// commandLatencyQuantiles 输出的分位数
+var commandLatencyQuantiles = []float64{0.5, 0.95, 0.99}
+
+// commandHistogram 单个命令类型的耗时统计（无锁，Touches 等高频命令也可记录）
+type commandHistogram struct {
+	errors  atomic.Uint64
+	sum     atomic.Int64    // 纳秒
+	max     atomic.Int64    // 纳秒
+	buckets []atomic.Uint64 // 与 commandLatencyBuckets 对应（非累计），末尾为超出最大上界的计数
+}
+
+// CommandLatency 按命令类型统计 Session.handleCommand 的处理耗时
+type CommandLatency struct {
+	histograms [256]atomic.Pointer[commandHistogram]
+}
+
+// CommandLatencyStats 单个命令类型的耗时统计快照（秒）
+type CommandLatencyStats struct {
+	Count  uint64  `json:"count"`
+	Errors uint64  `json:"errors"`
+	Avg    float64 `json:"avg"`
+	P50    float64 `json:"p50"`
+	P95    float64 `json:"p95"`
+	P99    float64 `json:"p99"`
+	Max    float64 `json:"max"`
+}
+
+// NewCommandLatency 创建命令耗时统计
+func NewCommandLatency() *CommandLatency {
+	return &CommandLatency{}
+}
+
+// histogram 获取命令类型的直方图，首次使用时创建
+func (c *CommandLatency) histogram(cmd common.ClientCommandType) *commandHistogram {
+	slot := &c.histograms[uint8(cmd)]
+	if h := slot.Load(); h != nil {
+		return h
+	}
+	slot.CompareAndSwap(nil, &commandHistogram{buckets: make([]atomic.Uint64, len(commandLatencyBuckets)+1)})
+	return slot.Load()
+}
+
+// Observe 记录一次命令处理
+func (c *CommandLatency) Observe(cmd common.ClientCommandType, latency time.Duration, failed bool) {
+	h := c.histogram(cmd)
+	if failed {
+		h.errors.Add(1)
+	}
+	h.sum.Add(int64(latency))
+	for {
+		max := h.max.Load()
+		if int64(latency) <= max || h.max.CompareAndSwap(max, int64(latency)) {
+			break
+		}
+	}
+
+	seconds := latency.Seconds()
+	i := 0
+	for i < len(commandLatencyBuckets) && seconds > commandLatencyBuckets[i] {
+		i++
+	}
+	h.buckets[i].Add(1)
+}
+
+// snapshot 读取直方图的计数（各项分别读取，并发写入时可能有轻微偏差）
+func (h *commandHistogram) snapshot() (count uint64, buckets []uint64) {
+	buckets = make([]uint64, len(h.buckets))
+	for i := range h.buckets {
+		buckets[i] = h.buckets[i].Load()
+		count += buckets[i]
+	}
+	return count, buckets
+}
+
+// quantile 按桶内线性插值估算分位数（与 Prometheus histogram_quantile 相同），超出最大上界时取最大值
+func (h *commandHistogram) quantile(q float64, count uint64, buckets []uint64) float64 {
+	if count == 0 {
+		return 0
+	}
+	rank := q * float64(count)
+	var cumulative uint64
+	lower := 0.0
+	for i, n := range buckets {
+		if float64(cumulative+n) >= rank && n > 0 {
+			if i == len(commandLatencyBuckets) {
+				return time.Duration(h.max.Load()).Seconds()
+			}
+			upper := commandLatencyBuckets[i]
+			return lower + (upper-lower)*(rank-float64(cumulative))/float64(n)
+		}
+		cumulative += n
+		if i < len(commandLatencyBuckets) {
+			lower = commandLatencyBuckets[i]
+		}
+	}
+	return lower
+}
+
+// Snapshot 各命令类型的耗时统计，键为命令名称
+func (c *CommandLatency) Snapshot() map[string]CommandLatencyStats {
+	result := make(map[string]CommandLatencyStats)
+	for i := range c.histograms {
+		h := c.histograms[i].Load()
+		if h == nil {
+			continue
+		}
+		count, buckets := h.snapshot()
+		stats := CommandLatencyStats{
+			Count:  count,
+			Errors: h.errors.Load(),
+			P50:    h.quantile(0.5, count, buckets),
+			P95:    h.quantile(0.95, count, buckets),
+			P99:    h.quantile(0.99, count, buckets),
+			Max:    time.Duration(h.max.Load()).Seconds(),
+		}
+		if count > 0 {
+			stats.Avg = time.Duration(h.sum.Load()).Seconds() / float64(count)
+		}
+		result[common.ClientCommandType(i).String()] = stats
+	}
+	return result
+}
+
+// WriteMetrics 以Prometheus文本格式输出命令处理耗时直方图与估算的分位数
+func (c *CommandLatency) WriteMetrics(w io.Writer) {
+	type entry struct {
+		name    string
+		h       *commandHistogram
+		count   uint64
+		buckets []uint64
+	}
+	var entries []entry
+	for i := range c.histograms {
+		if h := c.histograms[i].Load(); h != nil {
+			count, buckets := h.snapshot()
+			entries = append(entries, entry{common.ClientCommandType(i).String(), h, count, buckets})
+		}
+	}
+
+	fmt.Fprintln(w, "# HELP phira_command_errors_total 命令处理返回错误的次数")
+	fmt.Fprintln(w, "# TYPE phira_command_errors_total counter")
+	for _, e := range entries {
+		fmt.Fprintf(w, "phira_command_errors_total{command=%q} %d\n", e.name, e.h.errors.Load())
+	}
+
+	fmt.Fprintln(w, "# HELP phira_command_duration_seconds 命令处理耗时（接收循环中 handleCommand 的执行时间）")
+	fmt.Fprintln(w, "# TYPE phira_command_duration_seconds histogram")
+	for _, e := range entries {
+		var cumulative uint64
+		for i, bound := range commandLatencyBuckets {
+			cumulative += e.buckets[i]
+			fmt.Fprintf(w, "phira_command_duration_seconds_bucket{command=%q,le=\"%g\"} %d\n", e.name, bound, cumulative)
+		}
+		fmt.Fprintf(w, "phira_command_duration_seconds_bucket{command=%q,le=\"+Inf\"} %d\n", e.name, e.count)
+		fmt.Fprintf(w, "phira_command_duration_seconds_sum{command=%q} %g\n", e.name, time.Duration(e.h.sum.Load()).Seconds())
+		fmt.Fprintf(w, "phira_command_duration_seconds_count{command=%q} %d\n", e.name, e.count)
+	}
+
+	fmt.Fprintln(w, "# HELP phira_command_duration_quantile_seconds 命令处理耗时分位数（服务器启动以来，按直方图估算）")
+	fmt.Fprintln(w, "# TYPE phira_command_duration_quantile_seconds gauge")
+	for _, e := range entries {
+		for _, q := range commandLatencyQuantiles {
+			fmt.Fprintf(w, "phira_command_duration_quantile_seconds{command=%q,quantile=\"%g\"} %g\n", e.name, q, e.h.quantile(q, e.count, e.buckets))
+		}
+	}
+}
+
+// GetCommandLatency 获取命令处理耗时统计
+func (s *Server) GetCommandLatency() *CommandLatency {
+	return s.commandLatency
+}
+
+// handleAdminCommandStats 查询各命令类型的处理耗时
+func (h *HTTPServer) handleAdminCommandStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method-not-allowed")
+		return
+	}
+	writeOK(w, map[string]interface{}{
+		"commands": h.server.commandLatency.Snapshot(),
+	})
+}
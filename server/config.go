@@ -4,12 +4,45 @@ import (
 	"os"
 
 	"gopkg.in/yaml.v3"
+
+	"phira-mp/common"
 )
 
 // Chart 谱面信息
 type Chart struct {
-	ID   int32  `json:"id"`
-	Name string `json:"name"`
+	ID           int64   `json:"id"`
+	Name         string  `json:"name"`
+	Level        string  `json:"level"`        // 难度标签（如 "IN Lv.15"）
+	Difficulty   float32 `json:"difficulty"`   // 定数
+	Charter      string  `json:"charter"`      // 谱师
+	Composer     string  `json:"composer"`     // 曲师
+	Illustrator  string  `json:"illustrator"`  // 画师
+	Illustration string  `json:"illustration"` // 曲绘URL
+	Duration     float32 `json:"duration"`     // 时长（秒，主站未提供时为0）
+
+	// 由 Level 拆分的难度名称与等级（如 "IN" 与 "15"），由 FetchChart 填充
+	DifficultyName string `json:"difficulty_name"`
+	LevelNumber    string `json:"level_number"`
+}
+
+// ChartLevel 选择谱面时随 MsgSelectChart 广播的难度名称与等级
+func (c *Chart) ChartLevel() common.ChartLevel {
+	return common.ChartLevel{Name: c.DifficultyName, Level: c.LevelNumber}
+}
+
+// Preview 选择谱面时随 MsgSelectChart 广播的谱面预览
+func (c *Chart) Preview() common.ChartPreview {
+	return common.ChartPreview{
+		ID:           common.LegacyID(c.ID),
+		Name:         c.Name,
+		Level:        c.Level,
+		Difficulty:   c.Difficulty,
+		Charter:      c.Charter,
+		Composer:     c.Composer,
+		Illustrator:  c.Illustrator,
+		Illustration: c.Illustration,
+		Duration:     c.Duration,
+	}
 }
 
 // ServerConfig 服务器配置
@@ -24,10 +57,182 @@ type ServerConfig struct {
 	AdminToken      string  `yaml:"admin_token"`       // 管理员token
 	AdminDataPath   string  `yaml:"admin_data_path"`   // 管理员数据文件路径
 	DefaultMaxUsers int     `yaml:"default_max_users"` // 每个房间默认最大玩家数
+	RoomQueueSize   int     `yaml:"room_queue_size"`   // 房间满员时等待队列的最大长度（0表示禁用排队）
+
+	// 调试：以JSON输出每条解码后的客户端命令（token打码，Touches/Judges等高频命令同样输出，仅用于排查问题）
+	DebugCommands bool `yaml:"debug_commands"`
+
+	// 谱面加载阶段：全员准备后等待客户端上报加载完成再开始，超时未加载完成的玩家视为放弃
+	ChartLoadTimeout int `yaml:"chart_load_timeout"` // 加载超时秒数（0表示禁用加载阶段）
+
+	// 会话有效期：认证超过 session_max_age 秒后向Phira主站重新校验token，被拒绝时要求客户端在宽限期内重新认证
+	SessionMaxAge      int `yaml:"session_max_age"`      // 会话有效期秒数（0表示不限制）
+	SessionReauthGrace int `yaml:"session_reauth_grace"` // 要求重新认证后的宽限秒数（默认60，对局中会等到对局结束）
+
+	// Played 成绩确认重试：刚上传的成绩可能暂时查询不到，失败后在后台按1秒起的指数回退重试
+	PlayedRetries int `yaml:"played_retries"` // 重试次数（0表示不重试，直接返回"记录不存在"）
+
+	// 谱面与成绩查询：SelectChart 与 Played 需要请求Phira主站，在工作池中执行，查询完成后再回复结果，避免慢请求阻塞该玩家的其他命令与心跳
+	FetchWorkers int `yaml:"fetch_workers"` // 工作协程数（0表示在接收循环中同步查询）
+
+	// 关闭服务器时依次停止监听、会话、房间、回放录制、HTTP/WebSocket 与存储，每个组件最多等待的秒数（0则使用默认10秒）
+	ShutdownTimeout int `yaml:"shutdown_timeout"`
+
+	// 平滑升级：移交监听套接字给新进程后，等待本进程现有会话结束的最长秒数，超时后断开剩余会话（0则使用默认600秒）
+	HandoverDrainTimeout int `yaml:"handover_drain_timeout"`
+
+	// 解码上限：单条命令中列表的最大元素数量，超出时按解码失败断开连接（0表示使用默认值）
+	MaxTouchFrames int `yaml:"max_touch_frames"` // Touches/FrameBatch 的触摸帧数（默认4096）
+	MaxTouchPoints int `yaml:"max_touch_points"` // 单个触摸帧的触摸点数（默认64）
+	MaxJudgeEvents int `yaml:"max_judge_events"` // Judges/FrameBatch 的判定事件数（默认8192）
+
+	// 命令长度上限：客户端命令编码后的最大字节数，超出时不解码并断开连接（0表示使用默认值）
+	MaxFrameSize  int                              `yaml:"max_frame_size"`  // 未单独设置的命令（默认4096）
+	MaxFrameSizes map[common.ClientCommandType]int `yaml:"max_frame_sizes"` // 按命令名称设置，如 Touches: 1048576
+
+	// 等待准备阶段房主断线或离开时的处理策略：cancel（回到选谱）、transfer（移交房主，默认）、start（其余玩家均已准备时直接开始，否则回到选谱）
+	HostLeavePolicy string `yaml:"host_leave_policy"`
+
+	// 房主闲置检测：选谱阶段房主长时间无操作时提醒，超时后循环模式下自动轮换房主
+	HostIdleWarn    int `yaml:"host_idle_warn"`    // 提醒房主的闲置秒数（0表示不提醒）
+	HostIdleTimeout int `yaml:"host_idle_timeout"` // 判定闲置超时的秒数（0表示禁用闲置检测）
+
+	// 房间关闭时间：设置了关闭时间的房间在关闭前提醒房间成员
+	RoomCloseWarning int `yaml:"room_close_warning"` // 关闭前提醒的秒数（0表示不提醒）
+
+	// 观察者上限：限制每个房间的观察者人数，避免触摸数据转发量过大
+	MaxMonitors      int `yaml:"max_monitors"`       // 每个房间默认观察者上限（0表示不限制）
+	MaxMonitorsLimit int `yaml:"max_monitors_limit"` // 房主可设置的观察者上限最大值（0表示房主不能修改）
+
+	// 游客模式：无Phira token的用户以游客身份连接，只能以观察者身份加入未锁定的房间
+	GuestMode        bool `yaml:"guest_mode"`         // 是否允许游客连接
+	GuestMaxPerIP    int  `yaml:"guest_max_per_ip"`   // 同一IP同时在线的游客数上限（0表示不限制）
+	GuestCommandRate int  `yaml:"guest_command_rate"` // 游客每秒最多处理的命令数（0表示不限制）
+
+	// 模拟玩家：允许管理员通过 POST /admin/rooms/{id}/bots 向房间加入服务器端的模拟玩家，用于测试客户端与直播界面（成绩为模拟数据，不要在正式服务器开启）
+	Bots bool `yaml:"bots"`
+
+	// 关键命令确认：向支持的客户端（协议 V14 起）发送 ChangeState、ChangeHost 时附带确认序号，客户端处理后回复 Ack；
+	// 超时未确认时视为客户端状态不同步，主动重新下发房间状态并计入 phira_desync_total 指标
+	AckDelivery   bool `yaml:"ack_delivery"`   // 是否开启（默认关闭）
+	DesyncTimeout int  `yaml:"desync_timeout"` // 未确认的超时秒数（默认10）
+
+	// 事件钩子插件：启动时加载的Go插件（-buildmode=plugin 编译的 .so 文件）路径，插件导出 server.Hook 类型的变量 Hook
+	Plugins []string `yaml:"plugins"`
+
+	// 全服频道：管理员与指定解说可向所有房间或订阅用户发送通知
+	GlobalChatCasters  []int32 `yaml:"global_chat_casters"`  // 允许在全服频道发言的用户ID（直播模式下的观察者同样允许）
+	GlobalChatScope    string  `yaml:"global_chat_scope"`    // 默认投递范围: all (所有房间及订阅用户), subscribers (仅订阅用户)
+	GlobalChatInterval int     `yaml:"global_chat_interval"` // 同一发送者两次发言的最小间隔秒数（0表示不限制）
+
+	// 观察者聊天：房间内的观察者之间互相发言，消息不会投递给玩家
+	MonitorChat         bool `yaml:"monitor_chat"`          // 是否开启观察者聊天
+	MonitorChatInterval int  `yaml:"monitor_chat_interval"` // 同一观察者两次发言的最小间隔秒数（0表示不限制）
+
+	// 表情：玩家在房间内发送服务器允许的表情，不受文字聊天关闭的影响
+	Emotes        []string `yaml:"emotes"`         // 允许的表情ID（为空表示关闭表情）
+	EmoteInterval int      `yaml:"emote_interval"` // 同一用户两次发送表情的最小间隔秒数（0表示不限制）
+
+	// 房间消息历史：每个房间保存最近的房间消息，重连的玩家与后加入的观察者通过 ChatHistory 命令查询
+	ChatHistorySize int `yaml:"chat_history_size"` // 每个房间保存的消息条数（0表示不保存）
+
+	// 认证超时：连接建立后须在该秒数内完成握手与认证，否则断开连接（0表示不限制）
+	AuthTimeout int `yaml:"auth_timeout"`
+
+	// 过载降级：CPU使用率或出站带宽持续超过阈值时逐级暂停接受观察者、合并触摸数据、推迟管理员快照、拒绝创建房间
+	LoadShedCPU       float64 `yaml:"load_shed_cpu"`       // CPU使用率阈值（0-1，0表示不按CPU降级）
+	LoadShedBandwidth int64   `yaml:"load_shed_bandwidth"` // 游戏连接出站带宽阈值（字节/秒，0表示不按带宽降级）
+	LoadShedInterval  int     `yaml:"load_shed_interval"`  // 负载采样间隔秒数，每次采样最多升降一级
+
+	// 活动统计：定期采样在线人数并记录开局次数，按小时聚合
+	ActivityStatsPath      string `yaml:"activity_stats_path"`      // 统计文件路径（默认使用PHIRA_MP_HOME或工作目录下的activity_stats.json）
+	ActivitySampleInterval int    `yaml:"activity_sample_interval"` // 在线人数采样间隔秒数（0表示禁用采样）
+	ActivityRetentionDays  int    `yaml:"activity_retention_days"`  // 统计保留天数
+
+	// 成绩重复使用检测：记录最近使用过的成绩ID，拒绝同一成绩在其他房间或对局中再次提交
+	RecordLedgerPath string `yaml:"record_ledger_path"` // 文件路径（默认使用PHIRA_MP_HOME或工作目录下的used_records.json）
+	RecordLedgerSize int    `yaml:"record_ledger_size"` // 最多记录的成绩数量（超出时淘汰最早的，0表示禁用检测）
+
+	// 对局历史：保存每局结算，供 GET /rooms/{id}/leaderboard 按房间ID与时间范围统计累计排行
+	GameHistoryPath string `yaml:"game_history_path"` // 文件路径（默认使用PHIRA_MP_HOME或工作目录下的game_history.json）
+	GameHistorySize int    `yaml:"game_history_size"` // 最多保留的对局数量（超出时淘汰最早的，0表示禁用）
+
+	// 比赛房间结算导出目录：比赛房间结算后将结果写入该目录（为空时只输出到日志）
+	ContestResultDir string `yaml:"contest_result_dir"`
+
+	// 数据保留期限：回放、对局历史、已使用成绩与封禁备注到期后自动删除（用户数据可通过 POST /admin/users/{id}/purge 立即清除）
+	Retention RetentionConfig `yaml:"retention"`
+
+	// 最近离线用户：用户断线被移除后保留其ID、名称、IP与所在房间，供管理员事后查询与封禁（仅保存在内存中）
+	RecentUsersSize int `yaml:"recent_users_size"` // 最多保留的用户数量（超出时淘汰最早离开的，0表示禁用）
+
+	// 对外公布的连接地址（IPv4、IPv6、域名、备用端口等），随房间列表下发供客户端选择最佳线路
+	AdvertiseAddresses []AdvertiseAddress `yaml:"advertise_addresses"`
+
+	// 管理员OIDC登录：管理员接口额外接受由该签发者签发的Bearer令牌（可与admin_token/OTP同时使用）
+	AdminOIDC OIDCConfig `yaml:"admin_oidc"`
+
+	// Phira主站API（用户认证、谱面与成绩查询）
+	PhiraAPI PhiraAPIConfig `yaml:"phira_api"`
+
+	// 公开接口（/room、/replay/*）按IP限流，超出配额返回429
+	PublicRateLimit PublicRateLimitConfig `yaml:"public_rate_limit"`
+
+	// 第三方成绩服务：配置后 Played 上传的成绩ID改为向该服务校验
+	RecordProvider RecordProviderConfig `yaml:"record_provider"`
+
+	// 成绩代提交：客户端通过 SubmitResult 将成绩数据交给服务器，由服务器转发至成绩服务并自动完成 Played
+	ScoreSubmit ScoreSubmitConfig `yaml:"score_submit"`
+
+	// 回放下载签名链接：/replay/auth 返回带HMAC签名与过期时间的下载链接，下载时无需session token
+	ReplaySignKey   string `yaml:"replay_sign_key"`   // 签名密钥（留空则每次启动随机生成，重启后旧链接失效）
+	ReplayURLBase   string `yaml:"replay_url_base"`   // 下载链接前缀（如CDN地址 https://cdn.example.com），留空生成相对路径
+	ReplayURLExpire int    `yaml:"replay_url_expire"` // 下载链接有效秒数（0则使用默认600秒）
+
+	// 回放对象存储（S3兼容），配置后录制完成的回放将上传并通过签名链接下载
+	ReplayStorage ReplayStorageConfig `yaml:"replay_storage"`
+
+	// 回放静态加密（AES-GCM）：录制完成的回放文件加密后落盘与上传，下载与截取片段时由服务器解密
+	ReplayEncryption ReplayEncryptionConfig `yaml:"replay_encryption"`
+
+	// 允许客户端在协商握手中启用连接压缩（deflate），降低大型直播房间Touches广播的带宽
+	StreamCompression bool `yaml:"stream_compression"`
+
+	// 允许客户端在协商握手中启用帧CRC32校验，校验失败时立即断开连接（客户端可重连恢复）
+	StreamChecksum bool `yaml:"stream_checksum"`
 
 	// TCP代理真实IP支持
 	TCPProxyProtocol bool   `yaml:"tcp_proxy_protocol"` // 是否启用TCP代理协议（HAProxy PROXY Protocol）
 	RealIPHeader     string `yaml:"real_ip_header"`     // HTTP真实IP头（X-Forwarded-For, X-Real-IP等）
+	ShowClientIP     bool   `yaml:"show_client_ip"`     // 管理员接口显示完整客户端IP（默认打码显示）
+
+	// 公开房间列表页：在HTTP服务根路径 / 提供只读的房间状态页面，便于社区服直接分享链接
+	PublicPage bool `yaml:"public_page"`
+
+	// 官方房间模板（启动时自动创建，房间清空后自动重建）
+	RoomTemplates []RoomTemplate `yaml:"room_templates"`
+}
+
+// 谱面策略
+const (
+	ChartPolicyFree  = "free"  // 房主自由选择谱面
+	ChartPolicyFixed = "fixed" // 固定为模板指定的谱面，房主不可更改
+)
+
+// RoomTemplate 官方房间模板
+type RoomTemplate struct {
+	ID          string  `yaml:"id"`           // 房间ID
+	MaxUsers    int     `yaml:"max_users"`    // 最大玩家数（0则使用默认值）
+	Locked      bool    `yaml:"locked"`       // 是否锁定
+	Cycle       bool    `yaml:"cycle"`        // 是否开启循环模式
+	ChartPolicy string  `yaml:"chart_policy"` // 谱面策略: free (默认), fixed
+	ChartID     int64   `yaml:"chart_id"`     // 预选谱面ID（0表示不预选，fixed策略下必填）
+	Monitors    []int32 `yaml:"monitors"`     // 额外允许观察该房间的用户ID列表（直播模式启用时生效）
+	UniqueIP    bool    `yaml:"unique_ip"`    // 拒绝与房间内已有用户相同IP的加入（防止多开）
+
+	// 定数限制：房主只能选择定数在范围内的谱面（0表示不限制）
+	MinDifficulty float32 `yaml:"min_difficulty"`
+	MaxDifficulty float32 `yaml:"max_difficulty"`
 }
 
 // DefaultConfig 返回默认配置
@@ -43,6 +248,72 @@ func DefaultConfig() ServerConfig {
 		AdminToken:      "",      // 默认无管理员token
 		AdminDataPath:   "",      // 默认使用PHIRA_MP_HOME或工作目录
 		DefaultMaxUsers: 8,       // 默认每个房间最大8人
+		RoomQueueSize:   0,       // 默认禁用排队
+		PlayedRetries:   DefaultPlayedRetries,
+		FetchWorkers:    DefaultFetchWorkers,
+		HostLeavePolicy: HostLeaveTransfer,
+		DesyncTimeout:   DefaultDesyncTimeout,
+
+		// 设置了关闭时间的房间默认在关闭前5分钟提醒
+		RoomCloseWarning: DefaultRoomCloseWarning,
+
+		// 会话默认不限制有效期；启用后token失效时给予60秒宽限
+		SessionReauthGrace: DefaultSessionReauthGrace,
+
+		// 游客模式默认关闭；开启后同一IP最多3名游客，每秒最多5条命令
+		GuestMode:        false,
+		GuestMaxPerIP:    DefaultGuestMaxPerIP,
+		GuestCommandRate: DefaultGuestCommandRate,
+
+		// 全服频道默认投递到所有房间，每人每5秒最多发言一次
+		GlobalChatScope:    GlobalChatScopeAll,
+		GlobalChatInterval: 5,
+
+		// 观察者聊天默认关闭，开启后每人每2秒最多发言一次
+		MonitorChat:         false,
+		MonitorChatInterval: 2,
+
+		// 表情默认开启，每人每秒最多发送一次
+		Emotes:        DefaultEmotes,
+		EmoteInterval: 1,
+
+		// 每个房间保存最近50条房间消息
+		ChatHistorySize: DefaultChatHistorySize,
+
+		// 连接建立后10秒内须完成认证
+		AuthTimeout: DefaultAuthTimeout,
+
+		// 过载降级默认关闭
+		LoadShedInterval: DefaultLoadShedInterval,
+
+		// 活动统计每分钟采样一次，保留30天
+		ActivitySampleInterval: DefaultActivitySampleInterval,
+		ActivityRetentionDays:  DefaultActivityRetentionDays,
+
+		// 默认记录最近10万条已使用成绩
+		RecordLedgerSize: DefaultRecordLedgerSize,
+
+		// 默认保留最近1万局结算
+		GameHistorySize: DefaultGameHistorySize,
+
+		// 回放默认保留4天，其余数据仅按数量淘汰
+		Retention: RetentionConfig{ReplayDays: DefaultReplayRetentionDays},
+
+		// 默认保留最近1000名离线用户
+		RecentUsersSize: DefaultRecentUsersSize,
+
+		// Phira主站API默认超时10秒，失败重试3次
+		PhiraAPI: DefaultPhiraAPIConfig(),
+
+		// 公开接口限流：/room 每秒2次（突发10次），/replay/* 每秒1次（突发10次）
+		PublicRateLimit: DefaultPublicRateLimitConfig(),
+
+		// 默认提供公开房间列表页
+		PublicPage: true,
+
+		// 默认允许客户端请求连接压缩
+		StreamCompression: true,
+		StreamChecksum:    true,
 
 		// TCP代理真实IP支持默认关闭
 		TCPProxyProtocol: false,
@@ -73,7 +344,7 @@ func (c *ServerConfig) IsDebugEnabled() bool {
 
 // Record 游戏记录
 type Record struct {
-	ID        int32   `json:"id"`
+	ID        int64   `json:"id"`
 	Player    int32   `json:"player"`
 	Score     int32   `json:"score"`
 	Perfect   int32   `json:"perfect"`
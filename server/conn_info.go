@@ -0,0 +1,52 @@
+package server
+
+import "phira-mp/common"
+
+// 连接传输方式
+const (
+	TransportTCP      = "tcp"       // 直连TCP
+	TransportTCPProxy = "tcp+proxy" // 经过PROXY Protocol代理的TCP
+)
+
+// ConnectionInfo 用户当前连接的元数据（用于管理员接口）
+type ConnectionInfo struct {
+	IP              string `json:"ip,omitempty"`          // 客户端IP（按配置打码）
+	ConnectedAt     int64  `json:"connected_at"`          // 本次连接建立时间（毫秒）
+	ProtocolVersion uint8  `json:"protocol_version"`      // 实际使用的协议版本（原版客户端为1）
+	Transport       string `json:"transport"`             // 传输方式
+	Compression     string `json:"compression,omitempty"` // 连接压缩算法（未启用时省略）
+	Checksum        bool   `json:"checksum,omitempty"`    // 是否启用帧CRC32校验
+}
+
+// DisplayIP 按配置返回用于展示的客户端IP
+func (s *Server) DisplayIP(ip string) string {
+	if s.config.ShowClientIP {
+		return ip
+	}
+	return MaskIP(ip)
+}
+
+// ConnectionInfo 获取用户当前连接的元数据（从未建立连接时返回nil）
+func (u *User) ConnectionInfo() *ConnectionInfo {
+	session := u.GetSession()
+	if session == nil {
+		return nil
+	}
+
+	info := &ConnectionInfo{
+		ConnectedAt: session.ConnectedAt.UnixMilli(),
+		Transport:   session.Transport,
+	}
+	if session.Stream != nil && session.Stream.Stream != nil {
+		info.ProtocolVersion = session.Stream.Protocol()
+		features := session.Stream.Features()
+		info.Compression = (features & common.FeatureDeflate).String()
+		info.Checksum = features&common.FeatureChecksum != 0
+	}
+	if u.server != nil {
+		info.IP = u.server.DisplayIP(u.GetIP())
+	} else {
+		info.IP = MaskIP(u.GetIP())
+	}
+	return info
+}
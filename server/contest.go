@@ -0,0 +1,241 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"phira-mp/common"
+)
+
+// ErrContestMonitorOnly 不在白名单中的用户以玩家身份加入比赛房间时返回给客户端的错误
+const ErrContestMonitorOnly = "比赛房间只有白名单中的玩家可以参赛"
+
+// contestState 比赛模式的白名单（整体替换，不原地修改）
+type contestState struct {
+	whitelist map[int32]bool
+}
+
+// ContestInfo 管理员房间信息中的比赛模式状态
+type ContestInfo struct {
+	Whitelist []int32 `json:"whitelist"`
+}
+
+// ContestResult 比赛房间结算后导出的结果
+type ContestResult struct {
+	RoomID    string       `json:"roomid"`
+	Whitelist []int32      `json:"whitelist"`
+	Summary   *GameSummary `json:"summary"`
+}
+
+// SetContest 启用比赛模式（或更新白名单）：只有白名单中的用户可以以玩家身份参赛，对局由管理员手动开始，结算后房间自动解散
+// 房间内现有的玩家与观察者自动加入白名单，避免误踢
+func (r *Room) SetContest(whitelist []int32) {
+	state := &contestState{whitelist: make(map[int32]bool, len(whitelist))}
+	for _, id := range whitelist {
+		state.whitelist[id] = true
+	}
+	for _, u := range r.GetAllUsers() {
+		state.whitelist[u.ID] = true
+	}
+	r.contest.Store(state)
+}
+
+// ClearContest 关闭比赛模式，恢复为普通房间
+func (r *Room) ClearContest() {
+	r.contest.Store(nil)
+}
+
+// IsContest 是否为比赛房间
+func (r *Room) IsContest() bool {
+	return r.contest.Load() != nil
+}
+
+// CanCompete 用户能否以玩家身份加入房间（普通房间总是可以，比赛房间须在白名单中）
+func (r *Room) CanCompete(userID int32) bool {
+	state := r.contest.Load()
+	return state == nil || state.whitelist[userID]
+}
+
+// GetContest 比赛模式状态（普通房间返回nil）
+func (r *Room) GetContest() *ContestInfo {
+	state := r.contest.Load()
+	if state == nil {
+		return nil
+	}
+	info := &ContestInfo{Whitelist: make([]int32, 0, len(state.whitelist))}
+	for id := range state.whitelist {
+		info.Whitelist = append(info.Whitelist, id)
+	}
+	sort.Slice(info.Whitelist, func(i, j int) bool { return info.Whitelist[i] < info.Whitelist[j] })
+	return info
+}
+
+// 管理员手动开始比赛失败的原因（错误信息即管理接口返回的错误码）
+var (
+	ErrNotContest  = errors.New("not-contest")
+	ErrNotWaiting  = errors.New("invalid-state")
+	ErrNotAllReady = errors.New("not-all-ready")
+)
+
+// StartContest 管理员手动开始比赛：房间须处于等待准备阶段，force 为false时要求全部玩家（不包括观察者）已准备
+func (r *Room) StartContest(force bool) error {
+	if !r.IsContest() {
+		return ErrNotContest
+	}
+	if r.GetState() != InternalStateWaitForReady {
+		return ErrNotWaiting
+	}
+	if !force && !allStarted(&r.started, r.GetUsers()) {
+		return ErrNotAllReady
+	}
+	log.Printf("房间 `%s` 管理员开始比赛（强制: %v）", r.ID.Value, force)
+	BroadcastRoomLog(r.ID.Value, fmt.Sprintf("管理员开始比赛（强制: %v）", force))
+	r.beginGame()
+	return nil
+}
+
+// finishContest 比赛房间结算后导出结果并解散房间
+func (r *Room) finishContest(summary *GameSummary) {
+	result := ContestResult{RoomID: r.ID.Value, Summary: summary}
+	if info := r.GetContest(); info != nil {
+		result.Whitelist = info.Whitelist
+	}
+	data, err := json.Marshal(result)
+	if err != nil {
+		log.Printf("房间 `%s` 比赛结果编码失败: %v", r.ID.Value, err)
+	} else {
+		log.Printf("房间 `%s` 比赛结束 - 结果: %s", r.ID.Value, data)
+		if dir := r.server.config.ContestResultDir; dir != "" {
+			if err := writeContestResult(dir, r.ID.Value, summary.EndedAt, data); err != nil {
+				log.Printf("房间 `%s` 比赛结果导出失败: %v", r.ID.Value, err)
+			}
+		}
+	}
+
+	r.server.DisbandRoom(r, "比赛已结束，房间已解散", "比赛结束")
+}
+
+// writeContestResult 将比赛结果写入目录下的 contest-<房间ID>-<结束时间>.json（先写临时文件再替换，崩溃时不会留下不完整的文件）
+func writeContestResult(dir, roomID string, endedAt int64, data []byte) error {
+	name := fmt.Sprintf("contest-%s-%s.json", roomID, time.UnixMilli(endedAt).Format("20060102-150405"))
+	return writeFileAtomic(filepath.Join(dir, name), data, false)
+}
+
+// contestResults 列出目录下导出的比赛结果文件
+func contestResults(dir string) []string {
+	paths, _ := filepath.Glob(filepath.Join(dir, "contest-*.json"))
+	return paths
+}
+
+// loadContestResult 读取导出的比赛结果
+func loadContestResult(path string) (*ContestResult, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var result ContestResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// PruneContestResults 删除结束时间早于 cutoff 的比赛结果文件
+// 返回删除的文件数量
+func PruneContestResults(dir string, cutoff time.Time) int {
+	n := 0
+	for _, path := range contestResults(dir) {
+		result, err := loadContestResult(path)
+		if err != nil || result.Summary == nil || !time.UnixMilli(result.Summary.EndedAt).Before(cutoff) {
+			continue
+		}
+		if os.Remove(path) == nil {
+			n++
+		}
+	}
+	return n
+}
+
+// PurgeContestResultUser 从比赛结果中移除用户（白名单、个人排名、放弃名单与队伍成员，其他玩家的名次不变），
+// 移除后没有成绩的结果文件整个删除
+// 返回涉及的文件数量
+func PurgeContestResultUser(dir string, userID int32) int {
+	n := 0
+	for _, path := range contestResults(dir) {
+		result, err := loadContestResult(path)
+		if err != nil {
+			continue
+		}
+		changed := false
+		result.Whitelist, changed = removeUserID(result.Whitelist, userID)
+		if summary := result.Summary; summary != nil {
+			ranking := make([]RankEntry, 0, len(summary.Ranking))
+			for _, entry := range summary.Ranking {
+				if entry.UserID != userID {
+					ranking = append(ranking, entry)
+				}
+			}
+			if len(ranking) != len(summary.Ranking) {
+				summary.Ranking = ranking
+				changed = true
+			}
+			var removed bool
+			if summary.Aborted, removed = removeUserID(summary.Aborted, userID); removed {
+				changed = true
+			}
+			for i := range summary.Teams {
+				if summary.Teams[i].Players, removed = removeUserID(summary.Teams[i].Players, userID); removed {
+					changed = true
+				}
+			}
+		}
+		if !changed {
+			continue
+		}
+		if result.Summary == nil || len(result.Summary.Ranking) == 0 {
+			if os.Remove(path) != nil {
+				continue
+			}
+		} else {
+			data, err := json.Marshal(result)
+			if err != nil || writeFileAtomic(path, data, false) != nil {
+				continue
+			}
+		}
+		n++
+	}
+	return n
+}
+
+// removeUserID 从ID列表中移除用户，返回新列表与是否移除
+func removeUserID(ids []int32, userID int32) ([]int32, bool) {
+	kept := make([]int32, 0, len(ids))
+	for _, id := range ids {
+		if id != userID {
+			kept = append(kept, id)
+		}
+	}
+	if len(kept) == len(ids) {
+		return ids, false
+	}
+	return kept, true
+}
+
+// sendContestNotice 向房间成员发送比赛模式变化的系统消息
+func (r *Room) sendContestNotice(enabled bool) {
+	content := "房间已关闭比赛模式"
+	if enabled {
+		content = "房间已启用比赛模式，对局由管理员开始，结算后房间将自动解散"
+	}
+	r.SendMessage(common.Message{
+		Type:    common.MsgChat,
+		User:    0,
+		Content: content,
+	})
+}
@@ -0,0 +1,181 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultReplayRetentionDays 回放默认保留天数
+const DefaultReplayRetentionDays = 4
+
+// retentionInterval 按保留期限清理数据的间隔
+const retentionInterval = time.Hour
+
+// RetentionConfig 各类数据的保留期限（天），到期的数据由后台定期删除
+// 在线人数等活动统计不含用户信息，保留期限仍由 activity_retention_days 配置
+type RetentionConfig struct {
+	ReplayDays       int `yaml:"replay_days"`        // 回放（含片段与分享链接），0则使用默认4天
+	GameHistoryDays  int `yaml:"game_history_days"`  // 对局历史与导出的比赛结果，0表示仅按 game_history_size 淘汰
+	RecordLedgerDays int `yaml:"record_ledger_days"` // 已使用成绩登记，0表示仅按 record_ledger_size 淘汰
+	BanNoteDays      int `yaml:"ban_note_days"`      // 封禁原因（备注），到期后清空原因，封禁本身不受影响；0表示永久保留
+}
+
+// replayRetention 回放保留时长
+func (c RetentionConfig) replayRetention() time.Duration {
+	days := c.ReplayDays
+	if days <= 0 {
+		days = DefaultReplayRetentionDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// retentionCutoff 按天数计算的清理截止时间，days<=0 时返回零值表示不清理
+func retentionCutoff(now time.Time, days int) time.Time {
+	if days <= 0 {
+		return time.Time{}
+	}
+	return now.AddDate(0, 0, -days)
+}
+
+// applyRetention 清理超过保留期限的对局历史、比赛结果、已使用成绩与封禁备注（回放由 ReplayRecorder 的清理循环处理）
+func (s *Server) applyRetention(now time.Time) {
+	retention := s.config.Retention
+	if cutoff := retentionCutoff(now, retention.GameHistoryDays); !cutoff.IsZero() && s.gameHistory != nil {
+		if n := s.gameHistory.Prune(cutoff.UnixMilli()); n > 0 {
+			log.Printf("清理过期对局历史 %d 局", n)
+		}
+	}
+	if cutoff := retentionCutoff(now, retention.GameHistoryDays); !cutoff.IsZero() && s.config.ContestResultDir != "" {
+		if n := PruneContestResults(s.config.ContestResultDir, cutoff); n > 0 {
+			log.Printf("清理过期比赛结果 %d 个", n)
+		}
+	}
+	if cutoff := retentionCutoff(now, retention.RecordLedgerDays); !cutoff.IsZero() && s.recordLedger != nil {
+		if n := s.recordLedger.Prune(cutoff); n > 0 {
+			log.Printf("清理过期已使用成绩 %d 条", n)
+		}
+	}
+	if cutoff := retentionCutoff(now, retention.BanNoteDays); !cutoff.IsZero() && s.httpServer != nil {
+		if n := s.httpServer.adminData.PruneNotes(cutoff); n > 0 {
+			log.Printf("清理过期封禁备注 %d 条", n)
+			s.httpServer.saveAdminData()
+		}
+	}
+}
+
+// retentionLoop 定期按保留期限清理数据
+func (s *Server) retentionLoop() {
+	ticker := time.NewTicker(retentionInterval)
+	defer ticker.Stop()
+	s.applyRetention(time.Now())
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			s.applyRetention(time.Now())
+		}
+	}
+}
+
+// PurgeResult 用户数据清除结果（各类数据删除的数量）
+type PurgeResult struct {
+	UserID         int32 `json:"user_id"`
+	Replays        int   `json:"replays"`         // 回放与片段
+	Manifests      int   `json:"manifests"`       // 移除了该用户的对局清单（移除后没有玩家的清单整个删除）
+	Games          int   `json:"games"`           // 移除了该用户成绩的对局（移除后没有成绩的对局整局删除）
+	ContestResults int   `json:"contest_results"` // 移除了该用户的比赛结果文件（移除后没有成绩的文件整个删除）
+	Records        int   `json:"records"`         // 已使用成绩登记
+	BanNotes       int   `json:"ban_notes"`       // 清空的封禁原因
+	RecentUser     bool  `json:"recent_user"`     // 最近离线用户记录
+}
+
+// PurgeUser 删除服务器保存的指定用户数据并立即写入磁盘
+// 封禁本身保留（仅清空原因），在线用户的会话与进行中的对局不受影响
+func (s *Server) PurgeUser(userID int32) (PurgeResult, error) {
+	result := PurgeResult{UserID: userID}
+	var errs []string
+
+	if recorder := s.replayRecorder; recorder != nil {
+		for _, entry := range recorder.GetReplayIndex().ListUser(userID) {
+			if err := recorder.DeleteReplay(entry.ID); err != nil {
+				errs = append(errs, fmt.Sprintf("replay %s: %v", entry.ID, err))
+				continue
+			}
+			result.Replays++
+		}
+		// 未登记在索引中的残留文件
+		if err := os.RemoveAll(filepath.Join(ReplayDir, fmt.Sprintf("%d", userID))); err != nil {
+			errs = append(errs, fmt.Sprintf("replay dir: %v", err))
+		}
+		result.Manifests = PurgeGameManifestUser(ReplayDir, userID)
+	}
+
+	if s.gameHistory != nil {
+		if result.Games = s.gameHistory.PurgeUser(userID); result.Games > 0 {
+			if err := s.gameHistory.Save(); err != nil {
+				errs = append(errs, fmt.Sprintf("game history: %v", err))
+			}
+		}
+	}
+
+	if dir := s.config.ContestResultDir; dir != "" {
+		result.ContestResults = PurgeContestResultUser(dir, userID)
+	}
+
+	if s.recordLedger != nil {
+		if result.Records = s.recordLedger.PurgeUser(userID); result.Records > 0 {
+			if err := s.recordLedger.Save(); err != nil {
+				errs = append(errs, fmt.Sprintf("record ledger: %v", err))
+			}
+		}
+	}
+
+	if s.httpServer != nil {
+		if result.BanNotes = s.httpServer.adminData.ClearNotes(userID); result.BanNotes > 0 {
+			if err := s.httpServer.flushAdminData(); err != nil {
+				errs = append(errs, fmt.Sprintf("admin data: %v", err))
+			}
+		}
+	}
+
+	if s.recentUsers != nil && s.recentUsers.Get(userID) != nil {
+		s.recentUsers.Remove(userID)
+		result.RecentUser = true
+	}
+
+	log.Printf("[管理员] 清除用户 %d 的数据: 回放 %d, 对局清单 %d, 对局 %d, 比赛结果 %d, 成绩登记 %d, 封禁备注 %d",
+		userID, result.Replays, result.Manifests, result.Games, result.ContestResults, result.Records, result.BanNotes)
+	if len(errs) > 0 {
+		return result, fmt.Errorf("purge user %d: %s", userID, strings.Join(errs, "; "))
+	}
+	return result, nil
+}
+
+// handleAdminUserPurge 处理 POST /admin/users/{id}/purge
+func (h *HTTPServer) handleAdminUserPurge(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method-not-allowed")
+		return
+	}
+
+	path := strings.TrimSuffix(r.URL.Path, "/purge")
+	userID, ok := parseUserIDFromPath(path, "/admin/users/")
+	if !ok {
+		writeError(w, http.StatusBadRequest, "bad-user-id")
+		return
+	}
+
+	result, err := h.server.PurgeUser(userID)
+	if err != nil {
+		log.Printf("清除用户 %d 的数据失败: %v", userID, err)
+		writeError(w, http.StatusInternalServerError, "purge-incomplete")
+		return
+	}
+	writeOK(w, map[string]interface{}{"purged": result})
+}
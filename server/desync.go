@@ -0,0 +1,155 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"sync"
+	"time"
+
+	"phira-mp/common"
+)
+
+// DesyncCheckInterval 检查关键命令确认情况的间隔
+const DesyncCheckInterval = time.Second
+
+// DefaultDesyncTimeout 关键命令超过该秒数未确认时视为客户端状态不同步
+const DefaultDesyncTimeout = 10
+
+// maxPendingAcks 单个会话记录的未确认关键命令数量上限，达到上限时不再等待超时直接重新同步
+const maxPendingAcks = 64
+
+// isCriticalCommand 需要客户端确认的关键命令：客户端漏处理后房间状态或房主身份会与服务器不一致，且之后不会自行恢复
+func isCriticalCommand(t common.ServerCommandType) bool {
+	return t == common.ServerCmdChangeState || t == common.ServerCmdChangeHost
+}
+
+// ackTracker 会话的关键命令确认状态（开启 ack_delivery 且客户端支持 Ack 时创建）
+type ackTracker struct {
+	mu      sync.Mutex
+	seq     uint64       // 最近分配的确认序号
+	pending []pendingAck // 未确认的关键命令，按序号递增
+}
+
+type pendingAck struct {
+	seq    uint64
+	sentAt time.Time
+}
+
+// stamp 为关键命令分配确认序号并附加到扩展字段
+func (a *ackTracker) stamp(cmd *common.ServerCommand) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.seq++
+	if len(a.pending) < maxPendingAcks {
+		a.pending = append(a.pending, pendingAck{seq: a.seq, sentAt: time.Now()})
+	}
+	// 广播的命令共用扩展字段，复制后再设置
+	exts := append(common.Extensions(nil), cmd.Extensions...)
+	exts.SetUleb(common.ExtAckSeq, a.seq)
+	cmd.Extensions = exts
+}
+
+// ack 客户端确认序号 seq 及之前的关键命令
+func (a *ackTracker) ack(seq uint64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	n := 0
+	for n < len(a.pending) && a.pending[n].seq <= seq {
+		n++
+	}
+	a.pending = a.pending[n:]
+}
+
+// overdue 未确认的关键命令数量，以及是否已超时（或达到数量上限）
+func (a *ackTracker) overdue(now time.Time, timeout time.Duration) (int, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if len(a.pending) == 0 {
+		return 0, false
+	}
+	return len(a.pending), len(a.pending) >= maxPendingAcks || now.Sub(a.pending[0].sentAt) >= timeout
+}
+
+// reset 重新同步前丢弃未确认的关键命令（由重新下发的状态取代）
+func (a *ackTracker) reset() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pending = nil
+}
+
+// handleAck 处理客户端对关键命令的确认
+func (s *Session) handleAck(seq uint64) error {
+	if s.acks != nil {
+		s.acks.ack(seq)
+	}
+	return nil
+}
+
+// resync 客户端长时间未确认关键命令时，重新下发当前房间状态与房主身份（同样需要确认）
+func (s *Session) resync(pending int, timeout time.Duration) {
+	s.acks.reset()
+	s.server.desyncs.Add(1)
+
+	user := s.User
+	if user == nil || user.GetSession() != s {
+		return
+	}
+	room := user.GetRoom()
+	if room == nil {
+		log.Printf("[同步] 用户 `%s(%d)` 有 %d 条关键命令超过 %v 未确认（已不在房间中）", user.Name, user.ID, pending, timeout)
+		return
+	}
+	log.Printf("[同步] 用户 `%s(%d)` 有 %d 条关键命令超过 %v 未确认，重新下发房间 `%s` 的状态", user.Name, user.ID, pending, timeout, room.ID.Value)
+
+	var chartID *int64
+	if chart := room.GetChart(); chart != nil {
+		chartID = &chart.ID
+	}
+	state := room.GetState().ToClientState(chartID)
+	s.Send(common.ServerCommand{Type: common.ServerCmdChangeState, ChangeState: &state})
+	s.Send(common.ServerCommand{Type: common.ServerCmdChangeHost, ChangeHost: room.GetHost().ID == user.ID})
+}
+
+// desyncCheckLoop 定期检查各会话的关键命令确认情况，超时未确认的会话主动重新同步
+func (s *Server) desyncCheckLoop() {
+	if !s.config.AckDelivery {
+		return
+	}
+	timeout := time.Duration(s.config.DesyncTimeout) * time.Second
+	if timeout <= 0 {
+		timeout = DefaultDesyncTimeout * time.Second
+	}
+
+	ticker := time.NewTicker(min(DesyncCheckInterval, timeout/2))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case now := <-ticker.C:
+			s.sessions.Range(func(_, value interface{}) bool {
+				session, ok := value.(*Session)
+				if !ok || session.acks == nil {
+					return true
+				}
+				if pending, overdue := session.acks.overdue(now, timeout); overdue {
+					session.resync(pending, timeout)
+				}
+				return true
+			})
+		}
+	}
+}
+
+// writeDesyncMetrics 以Prometheus文本格式输出客户端状态不同步次数
+func (h *HTTPServer) writeDesyncMetrics(w io.Writer) {
+	fmt.Fprintln(w, "# HELP phira_desync_total 关键命令超时未确认、主动重新同步客户端房间状态的次数")
+	fmt.Fprintln(w, "# TYPE phira_desync_total counter")
+	fmt.Fprintf(w, "phira_desync_total %d\n", h.server.desyncs.Load())
+}
+
+// DesyncCount 检测到客户端状态不同步（并重新同步）的次数
+func (s *Server) DesyncCount() int64 {
+	return s.desyncs.Load()
+}
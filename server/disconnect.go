@@ -0,0 +1,109 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"phira-mp/common"
+)
+
+// DisconnectRecord 用户最近一次断开连接的原因（管理员用户详情）
+type DisconnectRecord struct {
+	Reason  string `json:"reason"` // 见 common.DisconnectReason.String
+	Message string `json:"message,omitempty"`
+	At      int64  `json:"at"` // 断开时间（毫秒时间戳）
+}
+
+// disconnectCause 会话断开的原因，只记录第一次
+type disconnectCause struct {
+	reason  common.DisconnectReason
+	message string
+}
+
+// notifyDisconnect 记录会话断开的原因，V27起的客户端收到 Disconnect 通知，并等待此前排队的命令写出
+// 客户端不再读取时发送队列可能已满，因此最多等待 kickDrainTimeout（随后关闭连接时放弃发送）
+// 已记录过原因或对端已关闭连接时不再通知；不关闭连接
+func (s *Session) notifyDisconnect(reason common.DisconnectReason, message string) {
+	if !s.cause.CompareAndSwap(nil, &disconnectCause{reason: reason, message: message}) {
+		return
+	}
+	if reason == common.DisconnectReasonClosed || s.Stream.ReadError() != nil {
+		return
+	}
+
+	sent := make(chan struct{})
+	go func() {
+		defer close(sent)
+		s.Send(common.ServerCommand{
+			Type:       common.ServerCmdDisconnect,
+			Disconnect: &common.Disconnect{Reason: reason, Message: message},
+		})
+		s.Stream.Drain(kickDrainTimeout)
+	}()
+	select {
+	case <-sent:
+	case <-time.After(kickDrainTimeout):
+	}
+}
+
+// Disconnect 通知客户端断开的原因后关闭连接
+func (s *Session) Disconnect(reason common.DisconnectReason, message string) {
+	s.notifyDisconnect(reason, message)
+	s.Stop()
+}
+
+// recordDisconnect 会话断开时按原因计数，并记录到用户（未记录原因时视为客户端关闭了连接）
+func (s *Session) recordDisconnect() {
+	cause := s.cause.Load()
+	if cause == nil {
+		cause = &disconnectCause{reason: common.DisconnectReasonClosed}
+	}
+	if cause.reason < common.DisconnectReasonCount {
+		s.server.disconnects[cause.reason].Add(1)
+	}
+	if s.User != nil {
+		s.User.lastDisconnect.Store(&DisconnectRecord{
+			Reason:  cause.reason.String(),
+			Message: cause.message,
+			At:      time.Now().UnixMilli(),
+		})
+	}
+}
+
+// LastDisconnect 用户最近一次断开连接的原因（本次运行中未断开过时为nil）
+func (u *User) LastDisconnect() *DisconnectRecord {
+	return u.lastDisconnect.Load()
+}
+
+// DisconnectCounts 按原因统计的断开连接次数（只包含发生过的原因）
+func (s *Server) DisconnectCounts() map[string]int64 {
+	counts := make(map[string]int64)
+	for reason := common.DisconnectReason(0); reason < common.DisconnectReasonCount; reason++ {
+		if n := s.disconnects[reason].Load(); n > 0 {
+			counts[reason.String()] = n
+		}
+	}
+	return counts
+}
+
+// handleAdminDisconnectStats 处理查询按原因统计的断开连接次数
+func (h *HTTPServer) handleAdminDisconnectStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method-not-allowed")
+		return
+	}
+	writeOK(w, map[string]interface{}{
+		"disconnects": h.server.DisconnectCounts(),
+	})
+}
+
+// writeDisconnectMetrics 以Prometheus文本格式输出按原因统计的断开连接次数
+func (h *HTTPServer) writeDisconnectMetrics(w io.Writer) {
+	fmt.Fprintln(w, "# HELP phira_disconnects_total 按原因统计的游戏连接断开次数")
+	fmt.Fprintln(w, "# TYPE phira_disconnects_total counter")
+	for reason := common.DisconnectReason(0); reason < common.DisconnectReasonCount; reason++ {
+		fmt.Fprintf(w, "phira_disconnects_total{reason=%q} %d\n", reason.String(), h.server.disconnects[reason].Load())
+	}
+}
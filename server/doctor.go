@@ -0,0 +1,298 @@
+package server
+
+import (
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DoctorStatus 自检项结果
+type DoctorStatus int
+
+const (
+	DoctorOK DoctorStatus = iota
+	DoctorWarn
+	DoctorFail
+)
+
+func (s DoctorStatus) String() string {
+	switch s {
+	case DoctorOK:
+		return " OK "
+	case DoctorWarn:
+		return "WARN"
+	default:
+		return "FAIL"
+	}
+}
+
+// doctorClockSkew 与Phira主站时间相差超过该值时告警（影响OIDC令牌、签名链接等有效期判断）
+const doctorClockSkew = 2 * time.Minute
+
+// DoctorCheck 单个自检项
+type DoctorCheck struct {
+	Name   string
+	Status DoctorStatus
+	Detail string
+}
+
+// DoctorReport 启动自检报告
+type DoctorReport struct {
+	Checks []DoctorCheck
+}
+
+func (r *DoctorReport) add(name string, status DoctorStatus, format string, args ...interface{}) {
+	r.Checks = append(r.Checks, DoctorCheck{Name: name, Status: status, Detail: fmt.Sprintf(format, args...)})
+}
+
+// Failed 是否存在失败项
+func (r *DoctorReport) Failed() bool {
+	for _, c := range r.Checks {
+		if c.Status == DoctorFail {
+			return true
+		}
+	}
+	return false
+}
+
+// Print 输出自检报告
+func (r *DoctorReport) Print(w io.Writer) {
+	var warns, fails int
+	for _, c := range r.Checks {
+		fmt.Fprintf(w, "[%s] %s: %s\n", c.Status, c.Name, c.Detail)
+		switch c.Status {
+		case DoctorWarn:
+			warns++
+		case DoctorFail:
+			fails++
+		}
+	}
+	fmt.Fprintf(w, "共 %d 项检查，%d 项警告，%d 项失败\n", len(r.Checks), warns, fails)
+}
+
+// RunDoctor 检查配置与运行环境：配置合法性、端口占用、数据目录写权限、Phira主站连通性与系统时钟
+// configErr 为加载配置文件时的错误（nil表示加载成功或文件不存在）
+func RunDoctor(config ServerConfig, configPath string, configErr error) *DoctorReport {
+	report := &DoctorReport{}
+
+	switch {
+	case configErr != nil:
+		report.add("配置文件", DoctorFail, "%s 解析失败: %v", configPath, configErr)
+	case !fileExists(configPath):
+		report.add("配置文件", DoctorWarn, "%s 不存在，使用默认配置", configPath)
+	default:
+		report.add("配置文件", DoctorOK, "%s", configPath)
+	}
+	problems := ValidateConfig(config)
+	if len(problems) == 0 {
+		report.add("配置项", DoctorOK, "未发现问题")
+	}
+	for _, p := range problems {
+		report.add("配置项", DoctorFail, "%s", p)
+	}
+
+	doctorCheckPort(report, "游戏端口", config.Host, config.Port)
+	if config.HTTPService {
+		doctorCheckPort(report, "HTTP端口", config.Host, config.HTTPPort)
+		if config.AdminToken == "" && !config.AdminOIDC.Enabled() {
+			report.add("管理员认证", DoctorWarn, "未配置 admin_token 或 admin_oidc，管理员接口仅能通过OTP访问")
+		}
+	}
+
+	doctorCheckDir(report, "回放目录", ReplayDir)
+	doctorCheckFile(report, "管理员数据", adminDataPath(config.AdminDataPath))
+
+	doctorCheckUpstream(report, config.PhiraAPI)
+	return report
+}
+
+// ValidateConfig 检查配置项取值，返回发现的问题
+func ValidateConfig(config ServerConfig) []string {
+	var problems []string
+	checkPort := func(name string, port int) {
+		if port <= 0 || port > 65535 {
+			problems = append(problems, fmt.Sprintf("%s 超出范围: %d", name, port))
+		}
+	}
+	checkPort("port", config.Port)
+	if config.HTTPService {
+		checkPort("http_port", config.HTTPPort)
+		if config.HTTPPort == config.Port {
+			problems = append(problems, fmt.Sprintf("http_port 与 port 相同: %d", config.Port))
+		}
+	}
+	if config.Host != "" && net.ParseIP(config.Host) == nil {
+		if _, err := net.LookupHost(config.Host); err != nil {
+			problems = append(problems, fmt.Sprintf("host 无法解析: %s", config.Host))
+		}
+	}
+
+	if config.AdminOIDC.Configured() {
+		if problem := config.AdminOIDC.Problem(); problem != "" {
+			problems = append(problems, problem+"（OIDC登录不会启用）")
+		}
+	}
+
+	switch config.LogLevel {
+	case "", "debug", "info", "warn", "error":
+	default:
+		problems = append(problems, fmt.Sprintf("log_level 无效: %s（可选 debug, info, warn, error）", config.LogLevel))
+	}
+	switch config.HostLeavePolicy {
+	case "", HostLeaveCancel, HostLeaveTransfer, HostLeaveStart:
+	default:
+		problems = append(problems, fmt.Sprintf("host_leave_policy 无效: %s", config.HostLeavePolicy))
+	}
+	switch config.GlobalChatScope {
+	case "", GlobalChatScopeAll, GlobalChatScopeSubscribers:
+	default:
+		problems = append(problems, fmt.Sprintf("global_chat_scope 无效: %s", config.GlobalChatScope))
+	}
+	if enc := config.ReplayEncryption; enc.Key != "" && enc.KeyURL == "" {
+		if key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(enc.Key)); err != nil {
+			problems = append(problems, "replay_encryption.key 不是有效的base64")
+		} else if n := len(key); n != 16 && n != 24 && n != 32 {
+			problems = append(problems, fmt.Sprintf("replay_encryption.key 长度无效: %d 字节（需为16、24或32字节）", n))
+		}
+	}
+	if config.MaxMonitorsLimit > 0 && config.MaxMonitors > config.MaxMonitorsLimit {
+		problems = append(problems, fmt.Sprintf("max_monitors (%d) 大于 max_monitors_limit (%d)", config.MaxMonitors, config.MaxMonitorsLimit))
+	}
+	if config.LoadShedCPU < 0 || config.LoadShedCPU > 1 {
+		problems = append(problems, fmt.Sprintf("load_shed_cpu 应在0到1之间: %g", config.LoadShedCPU))
+	}
+
+	seen := make(map[string]bool)
+	for _, tpl := range config.RoomTemplates {
+		if tpl.ID == "" {
+			problems = append(problems, "room_templates 存在未填写 id 的模板")
+			continue
+		}
+		if seen[tpl.ID] {
+			problems = append(problems, fmt.Sprintf("room_templates 房间ID重复: %s", tpl.ID))
+		}
+		seen[tpl.ID] = true
+		switch tpl.ChartPolicy {
+		case "", ChartPolicyFree:
+		case ChartPolicyFixed:
+			if tpl.ChartID == 0 {
+				problems = append(problems, fmt.Sprintf("room_templates %s 使用 fixed 策略但未填写 chart_id", tpl.ID))
+			}
+		default:
+			problems = append(problems, fmt.Sprintf("room_templates %s 的 chart_policy 无效: %s", tpl.ID, tpl.ChartPolicy))
+		}
+		if tpl.MinDifficulty < 0 || tpl.MaxDifficulty < 0 || (tpl.MaxDifficulty > 0 && tpl.MinDifficulty > tpl.MaxDifficulty) {
+			problems = append(problems, fmt.Sprintf("room_templates %s 的定数范围无效: %g ~ %g", tpl.ID, tpl.MinDifficulty, tpl.MaxDifficulty))
+		}
+	}
+	return problems
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
+
+// doctorCheckPort 检查端口能否监听
+func doctorCheckPort(report *DoctorReport, name, host string, port int) {
+	addr := net.JoinHostPort(host, strconv.Itoa(port))
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		report.add(name, DoctorFail, "%s 无法监听: %v", addr, err)
+		return
+	}
+	ln.Close()
+	report.add(name, DoctorOK, "%s 可用", addr)
+}
+
+// doctorCheckDir 检查目录能否创建并写入文件
+func doctorCheckDir(report *DoctorReport, name, dir string) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		report.add(name, DoctorFail, "%s 无法创建: %v", dir, err)
+		return
+	}
+	f, err := os.CreateTemp(dir, ".doctor-*")
+	if err != nil {
+		report.add(name, DoctorFail, "%s 不可写: %v", dir, err)
+		return
+	}
+	f.Close()
+	os.Remove(f.Name())
+	report.add(name, DoctorOK, "%s 可写", dir)
+}
+
+// doctorCheckFile 检查数据文件可写（不存在时检查所在目录）
+func doctorCheckFile(report *DoctorReport, name, path string) {
+	if fileExists(path) {
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+		if err != nil {
+			report.add(name, DoctorFail, "%s 不可写: %v", path, err)
+			return
+		}
+		f.Close()
+		report.add(name, DoctorOK, "%s 可写", path)
+		return
+	}
+	dir := filepath.Dir(path)
+	f, err := os.CreateTemp(dir, ".doctor-*")
+	if err != nil {
+		report.add(name, DoctorFail, "%s 无法创建（目录 %s 不可写）: %v", path, dir, err)
+		return
+	}
+	f.Close()
+	os.Remove(f.Name())
+	report.add(name, DoctorOK, "%s 不存在，首次保存时创建", path)
+}
+
+// doctorCheckUpstream 检查Phira主站连通性，并以响应的Date头校对本机时钟
+func doctorCheckUpstream(report *DoctorReport, config PhiraAPIConfig) {
+	now := time.Now()
+	if now.Year() < 2024 {
+		report.add("系统时钟", DoctorFail, "本机时间异常: %s", now.Format(time.RFC3339))
+		return
+	}
+
+	baseURL := config.BaseURL
+	if baseURL == "" {
+		baseURL = Host
+	}
+	timeout := time.Duration(config.Timeout) * time.Second
+	if timeout <= 0 {
+		timeout = DefaultPhiraAPITimeout * time.Second
+	}
+	client := &http.Client{Timeout: timeout}
+
+	start := time.Now()
+	resp, err := client.Get(baseURL)
+	if err != nil {
+		report.add("Phira主站", DoctorFail, "%s 无法访问: %v", baseURL, err)
+		report.add("系统时钟", DoctorWarn, "无法与Phira主站校对，本机时间 %s", now.Format(time.RFC3339))
+		return
+	}
+	resp.Body.Close()
+	rtt := time.Since(start)
+	report.add("Phira主站", DoctorOK, "%s 可访问（HTTP %d，耗时 %dms）", baseURL, resp.StatusCode, rtt.Milliseconds())
+
+	remote, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		report.add("系统时钟", DoctorWarn, "Phira主站响应缺少Date头，本机时间 %s", now.Format(time.RFC3339))
+		return
+	}
+	// Date头精度为秒，按请求中点估算本机时间
+	skew := start.Add(rtt / 2).Sub(remote)
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > doctorClockSkew {
+		report.add("系统时钟", DoctorWarn, "与Phira主站相差 %s，请检查时间同步", skew.Round(time.Second))
+		return
+	}
+	report.add("系统时钟", DoctorOK, "与Phira主站相差 %s", skew.Round(time.Second))
+}
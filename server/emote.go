@@ -0,0 +1,58 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"time"
+
+	"phira-mp/common"
+)
+
+// DefaultEmotes 默认允许的表情ID
+var DefaultEmotes = []string{"gg", "thumbs_up", "clap", "laugh", "wow", "cry"}
+
+// handleEmote 处理表情：与文字聊天相互独立，文字聊天关闭时玩家仍可用服务器允许的表情互动
+func (s *Session) handleEmote(emote string) error {
+	fail := func(msg string) error {
+		return s.Send(common.ServerCommand{
+			Type:        common.ServerCmdEmote,
+			EmoteResult: &common.Result[struct{}]{Err: strPtr(msg)},
+		})
+	}
+
+	if len(s.server.config.Emotes) == 0 {
+		return fail("服务器未开启该功能")
+	}
+	if s.server.IsUserBanned(s.User.ID) {
+		return fail("用户已被封禁")
+	}
+
+	room := s.User.GetRoom()
+	if room == nil {
+		return fail("不在房间中")
+	}
+	if s.User.IsGuest() {
+		return fail("游客不能发送表情")
+	}
+	if !slices.Contains(s.server.config.Emotes, emote) {
+		return fail("不支持的表情")
+	}
+
+	interval := time.Duration(s.server.config.EmoteInterval) * time.Second
+	if ok, wait := s.server.emotes.Allow(s.User.ID, interval); !ok {
+		return fail(fmt.Sprintf("发言过于频繁，请 %d 秒后再试", int(wait.Seconds())+1))
+	}
+
+	room.SendMessage(common.Message{
+		Type:    common.MsgEmote,
+		User:    s.User.ID,
+		Content: emote,
+	})
+	log.Printf("[表情] 房间 `%s` %s(%d): %s", room.ID.Value, s.User.Name, s.User.ID, emote)
+
+	return s.Send(common.ServerCommand{
+		Type:        common.ServerCmdEmote,
+		EmoteResult: &common.Result[struct{}]{Ok: &struct{}{}},
+	})
+}
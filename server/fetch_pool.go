@@ -0,0 +1,79 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// 查询工作池参数
+const (
+	DefaultFetchWorkers = 16  // 默认工作协程数
+	fetchQueuePerWorker = 16  // 每个工作协程对应的排队任务数
+	fetchQueueMin       = 256 // 排队任务数下限
+)
+
+// fetchPool 执行谱面、成绩查询等需要请求Phira主站的任务，避免一次慢请求阻塞会话接收循环（心跳与其他命令）
+// workers 为0时在调用方协程中同步执行
+type fetchPool struct {
+	tasks   chan func()
+	running atomic.Int32 // 正在执行的任务数
+}
+
+// newFetchPool 创建工作池，服务器停止后工作协程退出
+func newFetchPool(workers int, stop <-chan struct{}) *fetchPool {
+	if workers <= 0 {
+		return &fetchPool{}
+	}
+	queue := workers * fetchQueuePerWorker
+	if queue < fetchQueueMin {
+		queue = fetchQueueMin
+	}
+	p := &fetchPool{tasks: make(chan func(), queue)}
+	for i := 0; i < workers; i++ {
+		go p.worker(stop)
+	}
+	return p
+}
+
+func (p *fetchPool) worker(stop <-chan struct{}) {
+	for {
+		select {
+		case task := <-p.tasks:
+			p.running.Add(1)
+			task()
+			p.running.Add(-1)
+		case <-stop:
+			return
+		}
+	}
+}
+
+// Submit 提交任务，队列已满时返回false（调用方应回复服务器繁忙）
+func (p *fetchPool) Submit(task func()) bool {
+	if p.tasks == nil {
+		task()
+		return true
+	}
+	select {
+	case p.tasks <- task:
+		return true
+	default:
+		return false
+	}
+}
+
+// Wait 等待正在执行的任务完成（服务器停止后工作协程不再领取排队中的任务）
+func (p *fetchPool) Wait(ctx context.Context) error {
+	ticker := time.NewTicker(lifecycleDrainInterval)
+	defer ticker.Stop()
+	for p.running.Load() > 0 {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("仍有 %d 个查询未完成", p.running.Load())
+		case <-ticker.C:
+		}
+	}
+	return nil
+}
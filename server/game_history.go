@@ -0,0 +1,372 @@
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultGameHistorySize 默认保留的对局结算数量
+const DefaultGameHistorySize = 10000
+
+// HistoryGame 对局历史中的一局
+type HistoryGame struct {
+	RoomID string `json:"room_id"`
+	GameSummary
+}
+
+// LeaderboardEntry 累计排行中的一项
+type LeaderboardEntry struct {
+	Rank         int     `json:"rank"` // 从1开始，累计分数相同的玩家名次相同
+	UserID       int32   `json:"user_id"`
+	Name         string  `json:"name"`
+	Points       float64 `json:"points"`         // 各局排名分数之和（按每局当时的排名策略计算）
+	Games        int     `json:"games"`          // 有成绩的对局数
+	Wins         int     `json:"wins"`           // 获得第一名的对局数
+	TotalScore   int64   `json:"total_score"`    // 各局分数之和
+	BestScore    int32   `json:"best_score"`     // 单局最高分数
+	LastPlayedAt int64   `json:"last_played_at"` // 最近一局结束时间（Unix毫秒）
+}
+
+// Leaderboard 房间在时间范围内的累计排行
+type Leaderboard struct {
+	RoomID  string             `json:"room_id"`
+	From    int64              `json:"from"` // Unix毫秒（含）
+	To      int64              `json:"to"`   // Unix毫秒（含）
+	Games   int                `json:"games"`
+	Players []LeaderboardEntry `json:"players"`
+}
+
+// GameHistory 对局历史（按结束时间从旧到新，超出容量时淘汰最早的对局）
+// 房间解散后同一房间ID再次创建时历史仍然连续，便于按房间ID统计比赛时段内的累计成绩
+type GameHistory struct {
+	mu       sync.Mutex
+	saveMu   sync.Mutex
+	path     string
+	capacity int
+	dirty    bool
+
+	games []*HistoryGame
+
+	storeFreeze // 平滑升级后停止写文件（见 handover.go）
+}
+
+// NewGameHistory 创建对局历史
+func NewGameHistory(path string, capacity int) *GameHistory {
+	if capacity <= 0 {
+		capacity = DefaultGameHistorySize
+	}
+	return &GameHistory{path: path, capacity: capacity}
+}
+
+// Add 追加一局结算
+func (h *GameHistory) Add(roomID string, summary *GameSummary) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.games = append(h.games, &HistoryGame{RoomID: roomID, GameSummary: *summary})
+	if over := len(h.games) - h.capacity; over > 0 {
+		h.games = append(h.games[:0:0], h.games[over:]...)
+	}
+	h.dirty = true
+}
+
+// Prune 删除结束时间早于 before（Unix毫秒）的对局，返回删除数量
+func (h *GameHistory) Prune(before int64) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n := 0
+	for n < len(h.games) && h.games[n].EndedAt < before {
+		n++
+	}
+	if n > 0 {
+		h.games = append(h.games[:0:0], h.games[n:]...)
+		h.dirty = true
+	}
+	return n
+}
+
+// PurgeUser 从各局结算中移除用户（其他玩家的名次不变），移除后没有成绩的对局整局删除
+// 返回涉及的对局数量
+func (h *GameHistory) PurgeUser(userID int32) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n := 0
+	kept := h.games[:0]
+	for _, game := range h.games {
+		ranking := make([]RankEntry, 0, len(game.Ranking))
+		for _, entry := range game.Ranking {
+			if entry.UserID != userID {
+				ranking = append(ranking, entry)
+			}
+		}
+		aborted := make([]int32, 0, len(game.Aborted))
+		for _, id := range game.Aborted {
+			if id != userID {
+				aborted = append(aborted, id)
+			}
+		}
+		if len(ranking) == len(game.Ranking) && len(aborted) == len(game.Aborted) {
+			kept = append(kept, game)
+			continue
+		}
+		n++
+		if len(ranking) > 0 {
+			game.Ranking = ranking
+			game.Aborted = aborted
+			kept = append(kept, game)
+		}
+	}
+	for i := len(kept); i < len(h.games); i++ {
+		h.games[i] = nil
+	}
+	h.games = kept
+	if n > 0 {
+		h.dirty = true
+	}
+	return n
+}
+
+// Len 当前保留的对局数量
+func (h *GameHistory) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.games)
+}
+
+// PlayCount 用户有成绩的对局数（只统计仍保留在历史中的对局）
+func (h *GameHistory) PlayCount(userID int32) int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	n := 0
+	for _, game := range h.games {
+		for _, entry := range game.Ranking {
+			if entry.UserID == userID {
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
+
+// Games 房间在时间范围内（结束时间，Unix毫秒，含两端）的对局
+func (h *GameHistory) Games(roomID string, from, to int64) []HistoryGame {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var games []HistoryGame
+	for _, game := range h.games {
+		if game.RoomID == roomID && game.EndedAt >= from && game.EndedAt <= to {
+			games = append(games, *game)
+		}
+	}
+	return games
+}
+
+// Leaderboard 汇总房间在时间范围内各局的成绩，按累计排名分数排序
+func (h *GameHistory) Leaderboard(roomID string, from, to int64) *Leaderboard {
+	games := h.Games(roomID, from, to)
+	players := make(map[int32]*LeaderboardEntry)
+	for _, game := range games {
+		for _, entry := range game.Ranking {
+			p, ok := players[entry.UserID]
+			if !ok {
+				p = &LeaderboardEntry{UserID: entry.UserID}
+				players[entry.UserID] = p
+			}
+			// 对局按时间顺序遍历，名称取最近一局
+			p.Name = entry.Name
+			p.Points += entry.Points
+			p.Games++
+			if entry.Rank == 1 {
+				p.Wins++
+			}
+			p.TotalScore += int64(entry.Score)
+			if entry.Score > p.BestScore {
+				p.BestScore = entry.Score
+			}
+			p.LastPlayedAt = game.EndedAt
+		}
+	}
+
+	entries := make([]LeaderboardEntry, 0, len(players))
+	for _, p := range players {
+		entries = append(entries, *p)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Points != entries[j].Points {
+			return entries[i].Points > entries[j].Points
+		}
+		return entries[i].UserID < entries[j].UserID
+	})
+	for i := range entries {
+		if i > 0 && entries[i].Points == entries[i-1].Points {
+			entries[i].Rank = entries[i-1].Rank
+		} else {
+			entries[i].Rank = i + 1
+		}
+	}
+
+	return &Leaderboard{
+		RoomID:  roomID,
+		From:    from,
+		To:      to,
+		Games:   len(games),
+		Players: entries,
+	}
+}
+
+// Load 从文件加载
+func (h *GameHistory) Load() error {
+	data, err := os.ReadFile(h.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var games []*HistoryGame
+	if err := json.Unmarshal(data, &games); err != nil {
+		return err
+	}
+	if over := len(games) - h.capacity; over > 0 {
+		games = games[over:]
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.games = append(games, h.games...)
+	return nil
+}
+
+// Save 保存到文件（无变更时跳过）
+func (h *GameHistory) Save() error {
+	h.saveMu.Lock()
+	defer h.saveMu.Unlock()
+	if h.Frozen() {
+		return nil
+	}
+
+	h.mu.Lock()
+	if !h.dirty {
+		h.mu.Unlock()
+		return nil
+	}
+	data, err := json.Marshal(h.games)
+	h.dirty = false
+	h.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(h.path, data, false); err != nil {
+		h.markDirty()
+		return err
+	}
+	return nil
+}
+
+// markDirty 标记有未保存的修改（保存失败时调用，下次保存重试）
+func (h *GameHistory) markDirty() {
+	h.mu.Lock()
+	h.dirty = true
+	h.mu.Unlock()
+}
+
+// getGameHistoryPath 获取对局历史文件路径
+func (s *Server) getGameHistoryPath() string {
+	if s.config.GameHistoryPath != "" {
+		return s.config.GameHistoryPath
+	}
+	if home := os.Getenv("PHIRA_MP_HOME"); home != "" {
+		return filepath.Join(home, "game_history.json")
+	}
+	return "game_history.json"
+}
+
+// GetGameHistory 获取对局历史（未启用时为nil）
+func (s *Server) GetGameHistory() *GameHistory {
+	return s.gameHistory
+}
+
+// parseHistoryTime 解析时间参数：Unix毫秒或RFC 3339时间
+func parseHistoryTime(value string) (int64, bool) {
+	if ms, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return ms, true
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t.UnixMilli(), true
+	}
+	return 0, false
+}
+
+// handleRoomLeaderboard 处理 GET /rooms/{id}/leaderboard?from=&to=
+// from 默认不限，to 默认当前时间
+func (h *HTTPServer) handleRoomLeaderboard(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method-not-allowed")
+		return
+	}
+
+	roomID, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/rooms/"), "/leaderboard")
+	if !ok {
+		writeError(w, http.StatusNotFound, "not-found")
+		return
+	}
+	if !isValidRoomID(roomID) {
+		writeError(w, http.StatusBadRequest, "bad-room-id")
+		return
+	}
+
+	history := h.server.GetGameHistory()
+	if history == nil {
+		writeError(w, http.StatusNotFound, "history-disabled")
+		return
+	}
+
+	query := r.URL.Query()
+	from, to := int64(0), time.Now().UnixMilli()
+	if v := query.Get("from"); v != "" {
+		if from, ok = parseHistoryTime(v); !ok {
+			writeError(w, http.StatusBadRequest, "bad-from")
+			return
+		}
+	}
+	if v := query.Get("to"); v != "" {
+		if to, ok = parseHistoryTime(v); !ok {
+			writeError(w, http.StatusBadRequest, "bad-to")
+			return
+		}
+	}
+	if to < from {
+		writeError(w, http.StatusBadRequest, "bad-range")
+		return
+	}
+
+	writeOK(w, history.Leaderboard(roomID, from, to))
+}
+
+// gameHistoryLoop 定期保存对局历史
+func (s *Server) gameHistoryLoop() {
+	if s.gameHistory == nil {
+		return
+	}
+	ticker := time.NewTicker(recordLedgerSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			if err := s.gameHistory.Save(); err != nil {
+				log.Printf("保存对局历史失败: %v", err)
+			}
+		}
+	}
+}
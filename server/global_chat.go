@@ -0,0 +1,161 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"phira-mp/common"
+)
+
+// 全服频道投递范围
+const (
+	GlobalChatScopeAll         = "all"         // 投递到所有房间及订阅用户
+	GlobalChatScopeSubscribers = "subscribers" // 仅投递给订阅用户
+)
+
+// GlobalChatAdminID 管理员通过HTTP发言时使用的发送者ID
+const GlobalChatAdminID int32 = 0
+
+// GlobalChat 全服频道发言限流
+type GlobalChat struct {
+	mu       sync.Mutex
+	lastPost map[int32]time.Time
+}
+
+// NewGlobalChat 创建全服频道
+func NewGlobalChat() *GlobalChat {
+	return &GlobalChat{lastPost: make(map[int32]time.Time)}
+}
+
+// Allow 检查发送者是否可以发言，允许时记录本次发言时间
+// 返回值：是否允许，以及不允许时的剩余等待时间
+func (g *GlobalChat) Allow(sender int32, interval time.Duration) (bool, time.Duration) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	now := time.Now()
+	if last, ok := g.lastPost[sender]; ok && interval > 0 {
+		if wait := interval - now.Sub(last); wait > 0 {
+			return false, wait
+		}
+	}
+	g.lastPost[sender] = now
+	return true, 0
+}
+
+// IsGlobalCaster 用户是否可以在全服频道发言
+func (s *Server) IsGlobalCaster(user *User) bool {
+	if user.CanMonitor() {
+		return true
+	}
+	for _, id := range s.config.GlobalChatCasters {
+		if id == user.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// IsGlobalSubscribed 用户是否订阅了全服频道
+func (u *User) IsGlobalSubscribed() bool {
+	return u.globalChat.Load()
+}
+
+// SetGlobalSubscribed 设置全服频道订阅状态
+func (u *User) SetGlobalSubscribed(subscribed bool) {
+	u.globalChat.Store(subscribed)
+}
+
+// PostGlobalChat 向全服频道发送消息
+// 返回值：收到消息的用户数，以及被限流时的错误
+func (s *Server) PostGlobalChat(sender int32, name, content, scope string) (int, error) {
+	interval := time.Duration(s.config.GlobalChatInterval) * time.Second
+	if ok, wait := s.globalChat.Allow(sender, interval); !ok {
+		return 0, fmt.Errorf("发言过于频繁，请 %d 秒后再试", int(wait.Seconds())+1)
+	}
+	if scope == "" {
+		scope = s.config.GlobalChatScope
+	}
+
+	cmd := common.ServerCommand{
+		Type: common.ServerCmdMessage,
+		Message: &common.Message{
+			Type:    common.MsgChat,
+			User:    0,
+			Content: fmt.Sprintf("[全服] %s: %s", name, content),
+		},
+	}
+
+	delivered := make(map[int32]bool)
+	if scope != GlobalChatScopeSubscribers {
+		for _, room := range s.GetAllRooms() {
+			for _, u := range room.GetAllUsers() {
+				delivered[u.ID] = true
+			}
+			// 经房间广播发送，与房间内其他消息的先后顺序对所有成员一致
+			room.Broadcast(cmd)
+		}
+	}
+	s.users.Range(func(_, value interface{}) bool {
+		u := value.(*User)
+		if u.IsGlobalSubscribed() && !delivered[u.ID] && !u.IsDisconnected() {
+			delivered[u.ID] = true
+			u.Send(cmd)
+		}
+		return true
+	})
+
+	log.Printf("[全服频道] %s(%d): %s (范围: %s, 送达: %d)", name, sender, content, scope, len(delivered))
+	return len(delivered), nil
+}
+
+// handleGlobalChat 处理全服频道发言
+func (s *Session) handleGlobalChat(message string) error {
+	if s.server.IsUserBanned(s.User.ID) {
+		return s.Send(common.ServerCommand{
+			Type:             common.ServerCmdGlobalChat,
+			GlobalChatResult: &common.Result[struct{}]{Err: strPtr("用户已被封禁")},
+		})
+	}
+
+	if !s.server.IsGlobalCaster(s.User) {
+		return s.Send(common.ServerCommand{
+			Type:             common.ServerCmdGlobalChat,
+			GlobalChatResult: &common.Result[struct{}]{Err: strPtr("没有全服频道发言权限")},
+		})
+	}
+
+	if message == "" {
+		return s.Send(common.ServerCommand{
+			Type:             common.ServerCmdGlobalChat,
+			GlobalChatResult: &common.Result[struct{}]{Err: strPtr("消息为空")},
+		})
+	}
+
+	if _, err := s.server.PostGlobalChat(s.User.ID, s.User.Name, message, ""); err != nil {
+		return s.Send(common.ServerCommand{
+			Type:             common.ServerCmdGlobalChat,
+			GlobalChatResult: &common.Result[struct{}]{Err: strPtr(err.Error())},
+		})
+	}
+
+	return s.Send(common.ServerCommand{
+		Type:             common.ServerCmdGlobalChat,
+		GlobalChatResult: &common.Result[struct{}]{Ok: &struct{}{}},
+	})
+}
+
+// handleGlobalSubscribe 处理全服频道订阅
+func (s *Session) handleGlobalSubscribe(subscribe bool) error {
+	s.User.SetGlobalSubscribed(subscribe)
+	action := "取消订阅"
+	if subscribe {
+		action = "订阅"
+	}
+	log.Printf("用户 `%s(%d)` %s全服频道", s.User.Name, s.User.ID, action)
+	return s.Send(common.ServerCommand{
+		Type:                  common.ServerCmdGlobalSubscribe,
+		GlobalSubscribeResult: &common.Result[struct{}]{Ok: &struct{}{}},
+	})
+}
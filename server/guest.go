@@ -0,0 +1,115 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"phira-mp/common"
+)
+
+// 游客模式默认参数
+const (
+	DefaultGuestMaxPerIP    = 3 // 同一IP同时在线的游客数上限
+	DefaultGuestCommandRate = 5 // 游客每秒最多处理的命令数
+)
+
+// GuestIDBase 游客ID起点，游客ID从该值开始递减分配（不会与Phira用户ID冲突）
+const GuestIDBase int32 = -1_000_000
+
+// 游客相关错误提示
+const (
+	ErrGuestCreateRoom  = "游客不能创建房间"
+	ErrGuestMonitorOnly = "游客只能以观察者身份加入"
+)
+
+// IsGuest 是否为游客
+func (u *User) IsGuest() bool {
+	return u.ID <= GuestIDBase
+}
+
+// IsGuestModeEnabled 是否允许游客连接
+func (s *Server) IsGuestModeEnabled() bool {
+	return s.config.GuestMode
+}
+
+// newGuestUser 分配游客ID并创建游客用户
+func (s *Server) newGuestUser() *User {
+	seq := s.guestSeq.Add(1)
+	return NewUser(GuestIDBase-seq, fmt.Sprintf("游客%d", seq), "zh-CN", s)
+}
+
+// countGuestsByIP 统计指定IP当前在线的游客数
+func (s *Server) countGuestsByIP(ip string) int {
+	count := 0
+	s.users.Range(func(_, value interface{}) bool {
+		user := value.(*User)
+		if user.IsGuest() && !user.IsDisconnected() && user.GetIP() == ip {
+			count++
+		}
+		return true
+	})
+	return count
+}
+
+// handleGuestAuthenticate 处理游客认证（未携带token且服务器开启游客模式）
+func (s *Session) handleGuestAuthenticate() error {
+	ip := s.RemoteIP()
+	if limit := s.server.config.GuestMaxPerIP; limit > 0 && s.server.countGuestsByIP(ip) >= limit {
+		s.Send(common.ServerCommand{
+			Type: common.ServerCmdAuthenticate,
+			AuthenticateResult: &common.Result[common.AuthResult]{
+				Err: strPtr("该IP的游客连接数已达上限"),
+			},
+		})
+		return fmt.Errorf("IP %s 的游客连接数已达上限", ip)
+	}
+
+	user := s.server.newGuestUser()
+	user.SetSession(s)
+	user.SetIP(ip)
+	s.server.AddUser(user)
+	s.User = user
+	s.authenticated.Store(true)
+	if rate := s.server.config.GuestCommandRate; rate > 0 {
+		s.guestLimiter = newCommandLimiter(rate)
+	}
+
+	if err := s.Send(common.ServerCommand{
+		Type: common.ServerCmdAuthenticate,
+		AuthenticateResult: &common.Result[common.AuthResult]{
+			Ok: &common.AuthResult{User: user.ToInfo()},
+		},
+	}); err != nil {
+		return err
+	}
+
+	log.Printf("游客 `%s(%d)` 认证成功 (会话: %s, 协议版本: %d)", user.Name, user.ID, s.ID, s.Stream.Version())
+	return nil
+}
+
+// commandLimiter 令牌桶命令限流（仅在会话接收协程中使用，无需加锁）
+type commandLimiter struct {
+	rate   float64 // 每秒补充的令牌数，同时也是桶容量
+	tokens float64
+	last   time.Time
+}
+
+// newCommandLimiter 创建每秒最多 rate 条命令的限流器
+func newCommandLimiter(rate int) *commandLimiter {
+	return &commandLimiter{rate: float64(rate), tokens: float64(rate), last: time.Now()}
+}
+
+// Allow 消耗一个令牌，令牌不足时返回 false
+func (l *commandLimiter) Allow(now time.Time) bool {
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.rate {
+		l.tokens = l.rate
+	}
+	l.last = now
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
@@ -0,0 +1,266 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// HandoverEnv 平滑升级时告知新进程继承的监听套接字，值为逗号分隔的名称，按顺序对应文件描述符3、4……
+const HandoverEnv = "PHIRA_MP_LISTENERS"
+
+// 可移交的监听套接字名称
+const (
+	handoverGame = "game"
+	handoverHTTP = "http"
+)
+
+// DefaultHandoverDrainTimeout 移交后等待现有会话结束的默认秒数
+const DefaultHandoverDrainTimeout = 600
+
+// inheritedFiles 从父进程继承的监听套接字（按名称），每个只能取出一次
+var inheritedFiles struct {
+	once  sync.Once
+	mu    sync.Mutex
+	files map[string]*os.File
+}
+
+// startedByHandover 本进程是否由平滑升级启动（从父进程继承了监听套接字）
+func startedByHandover() bool {
+	loadInheritedFiles()
+	inheritedFiles.mu.Lock()
+	defer inheritedFiles.mu.Unlock()
+	return inheritedFiles.files != nil
+}
+
+// loadInheritedFiles 从环境变量读取继承的监听套接字（只读取一次）
+func loadInheritedFiles() {
+	inheritedFiles.once.Do(func() {
+		value := os.Getenv(HandoverEnv)
+		if value == "" {
+			return
+		}
+		// 避免由本进程启动的其他程序误认为继承了套接字
+		os.Unsetenv(HandoverEnv)
+		inheritedFiles.files = make(map[string]*os.File)
+		for i, name := range strings.Split(value, ",") {
+			if name != "" {
+				inheritedFiles.files[name] = os.NewFile(uintptr(3+i), name)
+			}
+		}
+	})
+}
+
+// takeInheritedFile 取出继承的文件，没有继承该名称时返回nil
+func takeInheritedFile(name string) *os.File {
+	loadInheritedFiles()
+	inheritedFiles.mu.Lock()
+	defer inheritedFiles.mu.Unlock()
+	f := inheritedFiles.files[name]
+	delete(inheritedFiles.files, name)
+	return f
+}
+
+// listen 优先使用从父进程继承的监听套接字，没有继承时监听 address
+func listen(name, address string) (net.Listener, error) {
+	f := takeInheritedFile(name)
+	if f == nil {
+		return net.Listen("tcp", address)
+	}
+	defer f.Close()
+	listener, err := net.FileListener(f)
+	if err != nil {
+		return nil, fmt.Errorf("继承的监听套接字 %s 无效: %w", name, err)
+	}
+	log.Printf("[升级] 继承监听套接字 %s（%s）", name, listener.Addr())
+	return listener, nil
+}
+
+// listenerFile 复制监听套接字的文件描述符，用于传给新进程
+func listenerFile(listener net.Listener) (*os.File, error) {
+	tcp, ok := listener.(*net.TCPListener)
+	if !ok {
+		return nil, fmt.Errorf("不支持移交 %T", listener)
+	}
+	return tcp.File()
+}
+
+// freezable 可冻结写入的整文件存储（见 storeFreeze）
+type freezable interface {
+	Freeze(frozen bool)
+}
+
+// handoverStores 新旧进程共用的整文件存储（只包含已启用的）
+func (s *Server) handoverStores() []freezable {
+	var stores []freezable
+	if s.activityStats != nil {
+		stores = append(stores, s.activityStats)
+	}
+	if s.recordLedger != nil {
+		stores = append(stores, s.recordLedger)
+	}
+	if s.gameHistory != nil {
+		stores = append(stores, s.gameHistory)
+	}
+	if s.replayRecorder != nil && s.replayRecorder.GetReplayIndex() != nil {
+		stores = append(stores, s.replayRecorder.GetReplayIndex())
+	}
+	if s.httpServer != nil && s.httpServer.adminData != nil {
+		stores = append(stores, s.httpServer.adminData)
+	}
+	return stores
+}
+
+// freezeStores 移交前写入尚未保存的数据，随后冻结整文件存储：新进程启动时读取并接管这些文件，
+// 本进程此后的修改（排空期间结束的对局、回放索引等）只保留在内存中，不会覆盖新进程写入的内容
+func (s *Server) freezeStores() {
+	if s.activityStats != nil {
+		if err := s.activityStats.Save(); err != nil {
+			log.Printf("[升级] 保存活动统计失败: %v", err)
+		}
+	}
+	if s.recordLedger != nil {
+		if err := s.recordLedger.Save(); err != nil {
+			log.Printf("[升级] 保存已使用成绩失败: %v", err)
+		}
+	}
+	if s.gameHistory != nil {
+		if err := s.gameHistory.Save(); err != nil {
+			log.Printf("[升级] 保存对局历史失败: %v", err)
+		}
+	}
+	if s.replayRecorder != nil && s.replayRecorder.GetReplayIndex() != nil {
+		if err := s.replayRecorder.GetReplayIndex().Save(); err != nil {
+			log.Printf("[升级] 保存回放索引失败: %v", err)
+		}
+	}
+	if s.httpServer != nil {
+		s.httpServer.flushAdminData()
+	}
+	for _, store := range s.handoverStores() {
+		store.Freeze(true)
+	}
+}
+
+// unfreezeStores 移交失败时恢复写入
+func (s *Server) unfreezeStores() {
+	for _, store := range s.handoverStores() {
+		store.Freeze(false)
+	}
+}
+
+// Handover 以相同的参数启动新的服务器进程（通常是已替换的新版本程序），并将游戏端口与HTTP服务的监听套接字移交给它，返回新进程的PID
+// 移交后新旧进程同时接受连接，调用方应随后调用 Drain 让本进程停止接受连接并等待现有会话结束
+// 新进程启动时会读取数据文件，因此先写入尚未保存的数据并冻结整文件存储（见 freezeStores）
+func (s *Server) Handover() (int, error) {
+	select {
+	case <-s.ready:
+	default:
+		return 0, errors.New("服务器尚未开始接受连接")
+	}
+
+	var names []string
+	var files []*os.File
+	defer func() {
+		for _, f := range files {
+			f.Close()
+		}
+	}()
+
+	f, err := listenerFile(s.listener)
+	if err != nil {
+		return 0, err
+	}
+	names, files = append(names, handoverGame), append(files, f)
+
+	if h := s.httpServer; h != nil && h.listener != nil {
+		f, err := listenerFile(h.listener)
+		if err != nil {
+			return 0, err
+		}
+		names, files = append(names, handoverHTTP), append(files, f)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.ExtraFiles = files
+	for _, env := range os.Environ() {
+		if !strings.HasPrefix(env, HandoverEnv+"=") {
+			cmd.Env = append(cmd.Env, env)
+		}
+	}
+	cmd.Env = append(cmd.Env, HandoverEnv+"="+strings.Join(names, ","))
+	if s.config.ReplaySignKey == "" {
+		log.Printf("[升级] 未配置 replay_sign_key，新进程使用新的随机密钥，本进程签发的回放下载链接将失效")
+	}
+	s.freezeStores()
+	if err := cmd.Start(); err != nil {
+		s.unfreezeStores()
+		return 0, fmt.Errorf("启动新进程失败: %w", err)
+	}
+	// 新进程独立运行，不等待其退出
+	pid := cmd.Process.Pid
+	cmd.Process.Release()
+
+	log.Printf("[升级] 已启动新进程 %d，移交监听套接字 %s", pid, strings.Join(names, ", "))
+	return pid, nil
+}
+
+// handoverDrainTimeout 移交后等待现有会话结束的时间
+func (s *Server) handoverDrainTimeout() time.Duration {
+	seconds := s.config.HandoverDrainTimeout
+	if seconds <= 0 {
+		seconds = DefaultHandoverDrainTimeout
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// Drain 停止接受新的游戏连接与HTTP请求（已建立的连接与WebSocket不受影响），
+// 等待现有会话全部断开（最多 handover_drain_timeout 秒）或服务器被停止，然后停止服务器
+func (s *Server) Drain() {
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	if h := s.httpServer; h != nil && h.listener != nil {
+		h.listener.Close()
+	}
+	log.Printf("[升级] 已停止接受新连接，等待 %d 个会话结束", s.sessionCount())
+
+	deadline := time.NewTimer(s.handoverDrainTimeout())
+	defer deadline.Stop()
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for s.sessionCount() > 0 {
+		select {
+		case <-s.stopChan:
+			return
+		case <-deadline.C:
+			log.Printf("[升级] 等待超时，断开剩余 %d 个会话", s.sessionCount())
+			s.Stop()
+			return
+		case <-ticker.C:
+		}
+	}
+	log.Printf("[升级] 现有会话已全部结束")
+	s.Stop()
+}
+
+// sessionCount 当前会话数量
+func (s *Server) sessionCount() int {
+	n := 0
+	s.sessions.Range(func(_, _ interface{}) bool {
+		n++
+		return true
+	})
+	return n
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"plugin"
+	"sync"
+
+	"phira-mp/common"
+)
+
+// Hook 房间事件钩子，用于在不修改服务器的情况下实现自定义规则（如“20点前不能选择Lv.16以上的谱面”）
+// 钩子按需实现 JoinHook、ChartHook、GameEndHook 中的一个或多个；嵌入服务器时通过 RegisterHook 注册，
+// 或编译为Go插件并在配置文件 plugins 中列出
+type Hook interface {
+	Name() string
+}
+
+// JoinHook 玩家或观察者加入房间前调用，返回错误时拒绝加入，错误信息作为原因发给该玩家
+type JoinHook interface {
+	OnJoin(room *Room, user *User, monitor bool) error
+}
+
+// ChartHook 房主选择谱面（谱面信息查询完成）后、设置为房间谱面前调用，返回错误时拒绝选择，错误信息作为原因发给房主
+type ChartHook interface {
+	OnSelectChart(room *Room, user *User, chart *Chart) error
+}
+
+// GameEndHook 对局结束、结算生成后调用，不能否决
+type GameEndHook interface {
+	OnGameEnd(room *Room, summary *GameSummary)
+}
+
+// PluginSymbol Go插件中导出钩子的变量名，类型为 server.Hook（如 var Hook server.Hook = myHook{}）
+const PluginSymbol = "Hook"
+
+var hooks = struct {
+	sync.RWMutex
+	list []Hook
+}{}
+
+// RegisterHook 注册事件钩子（名称不能为空或与已注册的钩子重复），钩子按注册顺序调用
+func RegisterHook(h Hook) error {
+	name := h.Name()
+	if name == "" {
+		return errors.New("hook name is empty")
+	}
+	if _, ok := h.(JoinHook); !ok {
+		if _, ok := h.(ChartHook); !ok {
+			if _, ok := h.(GameEndHook); !ok {
+				return fmt.Errorf("hook %s handles no events", name)
+			}
+		}
+	}
+	hooks.Lock()
+	defer hooks.Unlock()
+	for _, existing := range hooks.list {
+		if existing.Name() == name {
+			return fmt.Errorf("hook already registered: %s", name)
+		}
+	}
+	hooks.list = append(hooks.list, h)
+	return nil
+}
+
+// UnregisterHook 按名称移除事件钩子，返回是否存在
+func UnregisterHook(name string) bool {
+	hooks.Lock()
+	defer hooks.Unlock()
+	for i, h := range hooks.list {
+		if h.Name() == name {
+			hooks.list = append(hooks.list[:i:i], hooks.list[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// HookNames 已注册的事件钩子名称（按注册顺序）
+func HookNames() []string {
+	hooks.RLock()
+	defer hooks.RUnlock()
+	names := make([]string, 0, len(hooks.list))
+	for _, h := range hooks.list {
+		names = append(names, h.Name())
+	}
+	return names
+}
+
+// LoadPlugins 打开Go插件（-buildmode=plugin 编译的 .so 文件）并注册其导出的钩子
+// 插件必须与服务器使用相同版本的Go与依赖编译，且仅支持 Linux、macOS 等平台上启用cgo的构建
+func LoadPlugins(paths []string) error {
+	for _, path := range paths {
+		p, err := plugin.Open(path)
+		if err != nil {
+			return fmt.Errorf("加载插件 %s 失败: %w", path, err)
+		}
+		sym, err := p.Lookup(PluginSymbol)
+		if err != nil {
+			return fmt.Errorf("插件 %s 未导出 %s: %w", path, PluginSymbol, err)
+		}
+		// Lookup 返回变量的指针
+		h, ok := sym.(*Hook)
+		if !ok || *h == nil {
+			return fmt.Errorf("插件 %s 导出的 %s 不是 server.Hook", path, PluginSymbol)
+		}
+		if err := RegisterHook(*h); err != nil {
+			return fmt.Errorf("插件 %s: %w", path, err)
+		}
+		log.Printf("已加载插件 %s（钩子 %s）", path, (*h).Name())
+	}
+	return nil
+}
+
+func registeredHooks() []Hook {
+	hooks.RLock()
+	defer hooks.RUnlock()
+	return hooks.list
+}
+
+// callHook 调用单个钩子，钩子 panic 时记录日志并视为放行，避免插件错误影响服务器
+func callHook(h Hook, event string, fn func() error) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("[钩子] %s 处理 %s 时 panic: %v", h.Name(), event, p)
+			err = nil
+		}
+	}()
+	return fn()
+}
+
+// hookJoin 依次调用 JoinHook，返回第一个拒绝的原因
+func hookJoin(room *Room, user *User, monitor bool) error {
+	for _, h := range registeredHooks() {
+		if jh, ok := h.(JoinHook); ok {
+			if err := callHook(h, "join", func() error { return jh.OnJoin(room, user, monitor) }); err != nil {
+				log.Printf("[钩子] %s 拒绝玩家 `%s(%d)` 加入房间 `%s`: %v", h.Name(), user.Name, user.ID, room.ID.Value, err)
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// hookSelectChart 依次调用 ChartHook，返回第一个拒绝的原因
+func hookSelectChart(room *Room, user *User, chart *Chart) error {
+	for _, h := range registeredHooks() {
+		if ch, ok := h.(ChartHook); ok {
+			if err := callHook(h, "select_chart", func() error { return ch.OnSelectChart(room, user, chart) }); err != nil {
+				log.Printf("[钩子] %s 拒绝房间 `%s` 选择谱面 `%s(%d)`: %v", h.Name(), room.ID.Value, chart.Name, chart.ID, err)
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// hookGameEnd 依次调用 GameEndHook
+func hookGameEnd(room *Room, summary *GameSummary) {
+	for _, h := range registeredHooks() {
+		if gh, ok := h.(GameEndHook); ok {
+			callHook(h, "game_end", func() error {
+				gh.OnGameEnd(room, summary)
+				return nil
+			})
+		}
+	}
+}
+
+// SendSystemChat 以系统身份（用户ID 0）向房间发送聊天消息，供钩子向玩家发送提示
+func (r *Room) SendSystemChat(content string) {
+	r.SendMessage(common.Message{Type: common.MsgChat, User: 0, Content: content})
+}
@@ -0,0 +1,180 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"sync"
+
+	"phira-mp/common"
+)
+
+// 等待准备阶段房主断线或离开时的处理策略（配置 host_leave_policy）
+const (
+	HostLeaveCancel   = "cancel"   // 取消开始，回到选谱阶段
+	HostLeaveTransfer = "transfer" // 移交房主并保留已选谱面与准备状态，全员准备后照常开始
+	HostLeaveStart    = "start"    // 其余玩家均已准备时立即开始，否则取消开始
+)
+
+// hostLeavePolicy 获取房主离开策略（未配置或无效时为 transfer）
+func (s *Server) hostLeavePolicy() string {
+	switch s.config.HostLeavePolicy {
+	case HostLeaveCancel, HostLeaveStart:
+		return s.config.HostLeavePolicy
+	default:
+		return HostLeaveTransfer
+	}
+}
+
+// onHostLeaveWaitForReady 等待准备阶段房主断线或离开时按策略处理，
+// 避免只有房主能取消准备导致房间卡住
+// 返回值：是否已处理（房间内没有其他玩家时不处理）
+func (r *Room) onHostLeaveWaitForReady(host *User) bool {
+	if r.GetState() != InternalStateWaitForReady || r.GetHost().ID != host.ID {
+		return false
+	}
+
+	var others []*User
+	for _, u := range r.GetUsers() {
+		if u.ID != host.ID {
+			others = append(others, u)
+		}
+	}
+	if len(others) == 0 {
+		return false
+	}
+
+	policy := r.server.hostLeavePolicy()
+	log.Printf("房间 `%s` 房主 %s(%d) 在等待准备阶段离开，处理策略: %s", r.ID.Value, host.Name, host.ID, policy)
+
+	if policy == HostLeaveStart && !allStarted(&r.started, others) {
+		policy = HostLeaveCancel
+	}
+	if policy == HostLeaveCancel {
+		r.cancelGame(host)
+		return true
+	}
+
+	// 优先移交给已准备的玩家
+	newHost := others[0]
+	for _, u := range others {
+		if _, ok := r.started.Load(u.ID); ok {
+			newHost = u
+			break
+		}
+	}
+	r.transferHost(host, newHost)
+
+	// 移交后若全员已准备则直接开始
+	r.CheckAllReady()
+	BroadcastRoomUpdate(r)
+	return true
+}
+
+// allStarted 检查玩家是否均已准备
+func allStarted(started *sync.Map, users []*User) bool {
+	for _, u := range users {
+		if _, ok := started.Load(u.ID); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// cancelGame 取消开始并清空游戏状态，回到选谱阶段
+func (r *Room) cancelGame(by *User) {
+	clearSyncMap(&r.started)
+	clearSyncMap(&r.results)
+	clearSyncMap(&r.aborted)
+
+	r.SendMessage(common.Message{
+		Type: common.MsgCancelGame,
+		User: by.ID,
+	})
+	r.SetState(InternalStateSelectChart)
+	r.OnStateChange()
+}
+
+// transferHost 将房主移交给指定玩家（原房主已断线或离开，重连后通过房间状态得知）
+func (r *Room) transferHost(oldHost, newHost *User) {
+	r.SetHost(newHost)
+
+	log.Printf("房间 `%s` 房主变更: %s(%d) -> %s(%d) (原房主离开)",
+		r.ID.Value, oldHost.Name, oldHost.ID, newHost.Name, newHost.ID)
+	BroadcastRoomLog(r.ID.Value, fmt.Sprintf("房主变更: %s(%d) -> %s(%d)", oldHost.Name, oldHost.ID, newHost.Name, newHost.ID))
+
+	r.SendMessage(common.Message{
+		Type: common.MsgNewHost,
+		User: newHost.ID,
+	})
+	newHost.Send(common.ServerCommand{
+		Type:       common.ServerCmdChangeHost,
+		ChangeHost: true,
+	})
+}
+
+// handleTransferHost 处理房主主动移交房主：新房主须为房间内已连接的玩家（非观察者），只能在选择谱面时移交
+func (s *Session) handleTransferHost(userID int32) error {
+	fail := func(msg string) error {
+		return s.Send(common.ServerCommand{
+			Type:               common.ServerCmdTransferHost,
+			TransferHostResult: &common.Result[struct{}]{Err: strPtr(msg)},
+		})
+	}
+
+	room := s.User.GetRoom()
+	if room == nil {
+		return fail("不在房间中")
+	}
+	oldHost := room.GetHost()
+	if oldHost.ID != s.User.ID {
+		return fail("只有房主可以移交房主")
+	}
+	if userID == s.User.ID {
+		return fail("已是房主")
+	}
+	if room.GetState() != InternalStateSelectChart {
+		return fail("无效状态")
+	}
+
+	var newHost *User
+	for _, u := range room.GetAllUsers() {
+		if u.ID == userID {
+			newHost = u
+			break
+		}
+	}
+	if newHost == nil {
+		return fail("目标玩家不在房间中")
+	}
+	if newHost.IsMonitor() {
+		return fail("不能移交给观察者")
+	}
+	if newHost.GetSession() == nil || newHost.IsDisconnected() {
+		return fail("目标玩家已断线")
+	}
+
+	room.SetHost(newHost)
+
+	log.Printf("房间 `%s` 房主变更: %s(%d) -> %s(%d) (房主移交)",
+		room.ID.Value, oldHost.Name, oldHost.ID, newHost.Name, newHost.ID)
+	BroadcastRoomLog(room.ID.Value, fmt.Sprintf("房主移交: %s(%d) -> %s(%d)", oldHost.Name, oldHost.ID, newHost.Name, newHost.ID))
+
+	room.SendMessage(common.Message{
+		Type: common.MsgNewHost,
+		User: newHost.ID,
+	})
+	oldHost.Send(common.ServerCommand{
+		Type:       common.ServerCmdChangeHost,
+		ChangeHost: false,
+	})
+	newHost.Send(common.ServerCommand{
+		Type:       common.ServerCmdChangeHost,
+		ChangeHost: true,
+	})
+	BroadcastRoomUpdate(room)
+
+	return s.Send(common.ServerCommand{
+		Type:               common.ServerCmdTransferHost,
+		TransferHostResult: &common.Result[struct{}]{Ok: &struct{}{}},
+	})
+}
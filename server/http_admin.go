@@ -1,24 +1,40 @@
 package server
 
 import (
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"phira-mp/common"
 )
 
 // AdminRoomInfo 管理员房间信息
 type AdminRoomInfo struct {
-	RoomID    string          `json:"roomid"`
-	MaxUsers  int             `json:"max_users"`
-	Live      bool            `json:"live"`
-	Locked    bool            `json:"locked"`
-	Cycle     bool            `json:"cycle"`
-	Host      UserBrief       `json:"host"`
-	State     interface{}     `json:"state"`
-	Chart     *ChartInfo      `json:"chart,omitempty"`
-	Users     []AdminUserInfo `json:"users"`
-	Monitors  []AdminUserInfo `json:"monitors"`
+	RoomID      string               `json:"roomid"`
+	MaxUsers    int                  `json:"max_users"`
+	MaxMonitors int                  `json:"max_monitors"`
+	Official    bool                 `json:"official,omitempty"`
+	Live        bool                 `json:"live"`
+	Locked      bool                 `json:"locked"`
+	Password    bool                 `json:"password,omitempty"` // 设置了房间密码
+	Private     bool                 `json:"private,omitempty"`  // 私密房间（不出现在房间列表中）
+	Cycle       bool                 `json:"cycle"`
+	Overflow    bool                 `json:"overflow"`
+	UniqueIP    bool                 `json:"unique_ip"`
+	IPExempt    []int32              `json:"ip_exempt,omitempty"`
+	Host        UserBrief            `json:"host"`
+	State       interface{}          `json:"state"`
+	Chart       *ChartInfo           `json:"chart,omitempty"`
+	Users       []AdminUserInfo      `json:"users"`
+	Monitors    []AdminUserInfo      `json:"monitors"`
+	Teams       map[int32]uint8      `json:"teams,omitempty"` // 已分队玩家所在的队伍
+	Queue       []UserBrief          `json:"queue,omitempty"`
+	Ranking     string               `json:"ranking"`             // 排名策略
+	LastGame    *GameSummary         `json:"last_game,omitempty"` // 最近一局的结算
+	Schedule    *common.RoomSchedule `json:"schedule,omitempty"`  // 开放与关闭时间
+	Contest     *ContestInfo         `json:"contest,omitempty"`   // 比赛模式（普通房间没有该字段）
 }
 
 // AdminRoomStateInfo 管理员房间状态信息
@@ -38,10 +54,20 @@ type AdminUserInfo struct {
 	IsHost    bool    `json:"is_host"`
 	GameTime  float32 `json:"game_time"`
 	Language  string  `json:"language"`
+	IP        string  `json:"ip,omitempty"` // 客户端IP（按配置打码）
 	Monitor   bool    `json:"monitor,omitempty"`
+	Guest     bool    `json:"guest,omitempty"` // 是否为游客
+	IdleTime  int64   `json:"idle_time"`
+	Latency   float64 `json:"latency_ms,omitempty"` // 平滑往返延迟（毫秒，V18起的客户端）
+	AFK       bool    `json:"afk,omitempty"`
 	Finished  bool    `json:"finished,omitempty"`
 	Aborted   bool    `json:"aborted,omitempty"`
-	RecordID  *int32  `json:"record_id,omitempty"`
+	Pending   bool    `json:"pending,omitempty"` // 成绩确认中
+	RecordID  *int64  `json:"record_id,omitempty"`
+
+	// 玩家自定义的显示资料（未修改时为空）
+	DisplayName string `json:"display_name,omitempty"`
+	Avatar      string `json:"avatar,omitempty"`
 }
 
 // handleAdminRooms 处理获取所有房间详情
@@ -81,6 +107,14 @@ func (h *HTTPServer) handleAdminRoomDetail(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	// 房间封禁可针对尚未创建或已解散的房间，不要求房间存在
+	if rest := strings.TrimPrefix(path, "/admin/rooms/"+roomID); strings.HasPrefix(rest, "/bans") {
+		if rest = strings.TrimPrefix(rest, "/bans"); rest == "" || rest[0] == '/' {
+			h.handleAdminRoomBans(w, r, roomID, rest)
+			return
+		}
+	}
+
 	room := h.server.GetRoom(roomId)
 	if room == nil {
 		writeError(w, http.StatusNotFound, "room-not-found")
@@ -101,6 +135,18 @@ func (h *HTTPServer) handleAdminRoomDetail(w http.ResponseWriter, r *http.Reques
 		// 修改最大人数
 		h.handleAdminRoomMaxUsers(w, r, room)
 
+	case strings.HasSuffix(path, "/max_monitors"):
+		// 修改观察者上限
+		h.handleAdminRoomMaxMonitors(w, r, room)
+
+	case strings.HasSuffix(path, "/password"):
+		// 修改房间密码与私密房间
+		h.handleAdminRoomPassword(w, r, room)
+
+	case strings.HasSuffix(path, "/unique_ip"):
+		// 同IP限制
+		h.handleAdminRoomUniqueIP(w, r, room)
+
 	case strings.HasSuffix(path, "/chat"):
 		// 向房间发送消息
 		h.handleAdminRoomChat(w, r, room)
@@ -109,6 +155,18 @@ func (h *HTTPServer) handleAdminRoomDetail(w http.ResponseWriter, r *http.Reques
 		// 解散房间
 		h.handleAdminRoomDisband(w, r, room)
 
+	case strings.HasSuffix(path, "/schedule"):
+		// 设置开放与关闭时间
+		h.handleAdminRoomSchedule(w, r, room)
+
+	case strings.HasSuffix(path, "/bots"):
+		// 加入或移除模拟玩家
+		h.handleAdminRoomBots(w, r, room)
+
+	case strings.HasSuffix(path, "/playback"):
+		// 回放房间播放控制
+		h.handleAdminRoomPlayback(w, r, room)
+
 	default:
 		writeError(w, http.StatusNotFound, "not-found")
 	}
@@ -138,14 +196,83 @@ func (h *HTTPServer) handleAdminRoomMaxUsers(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	// TODO: 实际修改房间最大人数（需要在Room结构中添加maxUsers字段）
-	// 这里先返回成功
+	room.SetMaxUsers(req.MaxUsers)
+	BroadcastRoomUpdate(room)
+	room.AdmitQueued()
+
 	writeOK(w, map[string]interface{}{
 		"roomid":    room.ID.Value,
 		"max_users": req.MaxUsers,
 	})
 }
 
+// UpdateMaxMonitorsRequest 更新观察者上限请求
+type UpdateMaxMonitorsRequest struct {
+	MaxMonitors int `json:"maxMonitors"`
+}
+
+// handleAdminRoomMaxMonitors 处理修改房间观察者上限（不受房主可设置范围限制）
+func (h *HTTPServer) handleAdminRoomMaxMonitors(w http.ResponseWriter, r *http.Request, room *Room) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method-not-allowed")
+		return
+	}
+
+	var req UpdateMaxMonitorsRequest
+	if err := parseBody(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad-request")
+		return
+	}
+
+	// 验证范围 0-1000，0表示恢复服务器默认值
+	if req.MaxMonitors < 0 || req.MaxMonitors > 1000 {
+		writeError(w, http.StatusBadRequest, "bad-max-monitors")
+		return
+	}
+
+	room.SetMaxMonitors(req.MaxMonitors)
+	BroadcastRoomUpdate(room)
+
+	writeOK(w, map[string]interface{}{
+		"roomid":       room.ID.Value,
+		"max_monitors": room.GetMaxMonitors(),
+	})
+}
+
+// UpdateUniqueIPRequest 更新同IP限制请求
+type UpdateUniqueIPRequest struct {
+	Enabled *bool   `json:"enabled"`
+	Exempt  []int32 `json:"exempt"` // 允许共用IP的用户ID（为nil时保持不变）
+}
+
+// handleAdminRoomUniqueIP 处理房间同IP限制设置
+func (h *HTTPServer) handleAdminRoomUniqueIP(w http.ResponseWriter, r *http.Request, room *Room) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method-not-allowed")
+		return
+	}
+
+	var req UpdateUniqueIPRequest
+	if err := parseBody(r, &req); err != nil || (req.Enabled == nil && req.Exempt == nil) {
+		writeError(w, http.StatusBadRequest, "bad-request")
+		return
+	}
+
+	if req.Enabled != nil {
+		room.SetUniqueIP(*req.Enabled)
+	}
+	if req.Exempt != nil {
+		room.SetIPExempt(req.Exempt)
+	}
+	BroadcastRoomLog(room.ID.Value, fmt.Sprintf("同IP限制: %v, 豁免用户: %v", room.IsUniqueIP(), room.GetIPExempt()))
+
+	writeOK(w, map[string]interface{}{
+		"roomid":    room.ID.Value,
+		"unique_ip": room.IsUniqueIP(),
+		"ip_exempt": room.GetIPExempt(),
+	})
+}
+
 // AdminRoomChatRequest 向房间发送消息请求
 type AdminRoomChatRequest struct {
 	Message string `json:"message"`
@@ -191,30 +318,46 @@ func (h *HTTPServer) handleAdminRoomDisband(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// 发送解散通知
-	room.SendMessage(common.Message{
-		Type:    common.MsgChat,
-		User:    0,
-		Content: "房间已被管理员解散",
+	h.server.DisbandRoom(room, "房间已被管理员解散", "管理员解散")
+
+	writeOK(w, map[string]interface{}{
+		"roomid": room.ID.Value,
 	})
+}
+
+// AdminRoomScheduleRequest 设置房间开放与关闭时间请求（Unix毫秒，0表示不限制）
+type AdminRoomScheduleRequest struct {
+	OpenAt  int64 `json:"openAt"`
+	CloseAt int64 `json:"closeAt"`
+	Lock    bool  `json:"lock"` // 到达关闭时间时锁定房间而不是解散
+}
 
-	// 停止回放录制（如果有）
-	if recorder := h.server.GetReplayRecorder(); recorder != nil {
-		recorder.StopRecording(room.ID.Value)
+// handleAdminRoomSchedule 处理设置房间开放与关闭时间
+func (h *HTTPServer) handleAdminRoomSchedule(w http.ResponseWriter, r *http.Request, room *Room) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method-not-allowed")
+		return
 	}
 
-	// 断开所有用户连接
-	for _, user := range room.GetAllUsers() {
-		if session := user.GetSession(); session != nil {
-			session.Stop()
-		}
+	var req AdminRoomScheduleRequest
+	if err := parseBody(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad-request")
+		return
+	}
+
+	schedule := common.RoomSchedule{OpenAt: req.OpenAt, CloseAt: req.CloseAt, Lock: req.Lock}
+	if !validSchedule(schedule, time.Now()) {
+		writeError(w, http.StatusBadRequest, "bad-schedule")
+		return
 	}
 
-	// 移除房间
-	h.server.RemoveRoom(room.ID, "管理员解散")
+	room.SetSchedule(&schedule)
+	BroadcastRoomLog(room.ID.Value, fmt.Sprintf("管理员设置开放时间: %d, 关闭时间: %d, 到时锁定: %v", req.OpenAt, req.CloseAt, req.Lock))
+	BroadcastRoomUpdate(room)
 
 	writeOK(w, map[string]interface{}{
-		"roomid": room.ID.Value,
+		"roomid":   room.ID.Value,
+		"schedule": room.GetSchedule(),
 	})
 }
 
@@ -234,6 +377,26 @@ func (h *HTTPServer) handleAdminUserDetail(w http.ResponseWriter, r *http.Reques
 
 	user := h.server.GetUser(userID)
 	if user == nil {
+		// 已离开服务器的用户从最近离线记录中查询
+		if recent := h.server.GetRecentUsers(); recent != nil {
+			if entry := recent.Get(userID); entry != nil {
+				writeOK(w, map[string]interface{}{
+					"user": map[string]interface{}{
+						"id":        entry.ID,
+						"name":      entry.Name,
+						"connected": false,
+						"recent":    true,
+						"last_room": entry.Room,
+						"last_ip":   h.server.DisplayIP(entry.IP),
+						"last_seen": entry.LastSeen,
+						"banned":    h.adminData.IsUserBanned(userID),
+
+						"last_disconnect": entry.LastDisconnect,
+					},
+				})
+				return
+			}
+		}
 		writeError(w, http.StatusNotFound, "user-not-found")
 		return
 	}
@@ -247,21 +410,26 @@ func (h *HTTPServer) handleAdminUserDetail(w http.ResponseWriter, r *http.Reques
 
 	writeOK(w, map[string]interface{}{
 		"user": map[string]interface{}{
-			"id":        user.ID,
-			"name":      user.Name,
-			"monitor":   user.IsMonitor(),
-			"connected": !user.IsDisconnected(),
-			"room":      roomID,
-			"banned":    h.adminData.IsUserBanned(userID),
+			"id":         user.ID,
+			"name":       user.Name,
+			"monitor":    user.IsMonitor(),
+			"connected":  !user.IsDisconnected(),
+			"room":       roomID,
+			"banned":     h.adminData.IsUserBanned(userID),
+			"connection": user.ConnectionInfo(),
+
+			"last_disconnect": user.LastDisconnect(),
 		},
 	})
 }
 
 // AdminBanUserRequest 封禁用户请求
 type AdminBanUserRequest struct {
-	UserID     int32 `json:"userId"`
-	Banned     bool  `json:"banned"`
-	Disconnect bool  `json:"disconnect"`
+	UserID     int32  `json:"userId"`
+	Banned     bool   `json:"banned"`
+	Disconnect bool   `json:"disconnect"`
+	Reason     string `json:"reason"`
+	Duration   int64  `json:"duration"` // 封禁时长（秒），0表示永久
 }
 
 // handleAdminBanUser 处理封禁/解封用户
@@ -277,8 +445,17 @@ func (h *HTTPServer) handleAdminBanUser(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if req.Duration < 0 || len(req.Reason) > 200 {
+		writeError(w, http.StatusBadRequest, "bad-request")
+		return
+	}
+
 	// 封禁/解封用户
-	h.adminData.BanUser(req.UserID, req.Banned)
+	if req.Banned {
+		h.adminData.BanUserWithReason(req.UserID, req.Reason, time.Duration(req.Duration)*time.Second)
+	} else {
+		h.adminData.UnbanUser(req.UserID)
+	}
 	h.saveAdminData()
 
 	// 如果需要断开连接
@@ -296,10 +473,8 @@ func (h *HTTPServer) handleAdminBanUser(w http.ResponseWriter, r *http.Request)
 				})
 			}
 			
-			// 断开连接
-			if session := user.GetSession(); session != nil {
-				session.Stop()
-			}
+			// 通知被封禁后断开连接
+			user.notifyKicked(room, common.KickReasonBanned, req.Reason, true)
 			
 			// 从房间移除
 			if room != nil {
@@ -317,9 +492,11 @@ func (h *HTTPServer) handleAdminBanUser(w http.ResponseWriter, r *http.Request)
 
 // AdminBanRoomRequest 房间级封禁请求
 type AdminBanRoomRequest struct {
-	UserID int32  `json:"userId"`
-	RoomID string `json:"roomId"`
-	Banned bool   `json:"banned"`
+	UserID   int32  `json:"userId"`
+	RoomID   string `json:"roomId"`
+	Banned   bool   `json:"banned"`
+	Reason   string `json:"reason"`
+	Duration int64  `json:"duration"` // 封禁时长（秒），0表示永久
 }
 
 // handleAdminBanRoom 处理房间级封禁
@@ -341,10 +518,95 @@ func (h *HTTPServer) handleAdminBanRoom(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if req.Duration < 0 || len(req.Reason) > 200 {
+		writeError(w, http.StatusBadRequest, "bad-request")
+		return
+	}
+
 	// 封禁/解封用户进入房间
-	h.adminData.BanUserFromRoom(req.UserID, req.RoomID, req.Banned)
+	if req.Banned {
+		h.adminData.BanUserFromRoomWithReason(req.UserID, req.RoomID, req.Reason, time.Duration(req.Duration)*time.Second)
+	} else {
+		h.adminData.UnbanUserFromRoom(req.UserID, req.RoomID)
+	}
+	h.saveAdminData()
+
+	// 用户正在该房间中时将其移出
+	if req.Banned {
+		if user := h.server.GetUser(req.UserID); user != nil {
+			if room := user.GetRoom(); room != nil && room.ID.Value == req.RoomID {
+				h.server.KickFromRoom(room, user, common.KickReasonRoomBanned, req.Reason)
+			}
+		}
+	}
+
+	writeOK(w, nil)
+}
+
+// handleAdminBanUsers 服务器封禁列表
+// GET /admin/ban/users 列出封禁；DELETE /admin/ban/users/{userId} 解除封禁
+func (h *HTTPServer) handleAdminBanUsers(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/admin/ban/users" || r.URL.Path == "/admin/ban/users/" {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "method-not-allowed")
+			return
+		}
+		if h.adminData.PruneExpiredBans() > 0 {
+			h.saveAdminData()
+		}
+		writeOK(w, map[string]interface{}{"bans": h.adminData.UserBanList()})
+		return
+	}
+
+	userID, ok := parseUserIDFromPath(r.URL.Path, "/admin/ban/users/")
+	if !ok {
+		writeError(w, http.StatusBadRequest, "bad-user-id")
+		return
+	}
+	if r.Method != http.MethodDelete {
+		writeError(w, http.StatusMethodNotAllowed, "method-not-allowed")
+		return
+	}
+	if !h.adminData.UnbanUser(userID) {
+		writeError(w, http.StatusNotFound, "ban-not-found")
+		return
+	}
 	h.saveAdminData()
+	writeOK(w, nil)
+}
 
+// handleAdminRoomBans 房间封禁列表（房间不存在时也可管理）
+// GET /admin/rooms/{id}/bans 列出封禁；DELETE /admin/rooms/{id}/bans/{userId} 解除封禁
+func (h *HTTPServer) handleAdminRoomBans(w http.ResponseWriter, r *http.Request, roomID, rest string) {
+	if rest == "" || rest == "/" {
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "method-not-allowed")
+			return
+		}
+		if h.adminData.PruneExpiredBans() > 0 {
+			h.saveAdminData()
+		}
+		writeOK(w, map[string]interface{}{
+			"room_id": roomID,
+			"bans":    h.adminData.RoomBanList(roomID),
+		})
+		return
+	}
+
+	userID, ok := parseUserIDFromPath(rest, "/")
+	if !ok {
+		writeError(w, http.StatusBadRequest, "bad-user-id")
+		return
+	}
+	if r.Method != http.MethodDelete {
+		writeError(w, http.StatusMethodNotAllowed, "method-not-allowed")
+		return
+	}
+	if !h.adminData.UnbanUserFromRoom(userID, roomID) {
+		writeError(w, http.StatusNotFound, "ban-not-found")
+		return
+	}
+	h.saveAdminData()
 	writeOK(w, nil)
 }
 
@@ -391,6 +653,49 @@ func (h *HTTPServer) handleAdminBroadcast(w http.ResponseWriter, r *http.Request
 	})
 }
 
+// AdminGlobalChatRequest 全服频道发言请求
+type AdminGlobalChatRequest struct {
+	Message string `json:"message"`
+	Scope   string `json:"scope"` // all, subscribers（为空时使用配置的默认范围）
+}
+
+// handleAdminGlobalChat 处理管理员在全服频道发言
+func (h *HTTPServer) handleAdminGlobalChat(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method-not-allowed")
+		return
+	}
+
+	var req AdminGlobalChatRequest
+	if err := parseBody(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad-request")
+		return
+	}
+
+	if len(req.Message) == 0 {
+		writeError(w, http.StatusBadRequest, "bad-message")
+		return
+	}
+	if len(req.Message) > 200 {
+		writeError(w, http.StatusBadRequest, "message-too-long")
+		return
+	}
+	if req.Scope != "" && req.Scope != GlobalChatScopeAll && req.Scope != GlobalChatScopeSubscribers {
+		writeError(w, http.StatusBadRequest, "bad-scope")
+		return
+	}
+
+	delivered, err := h.server.PostGlobalChat(GlobalChatAdminID, "管理员", req.Message, req.Scope)
+	if err != nil {
+		writeError(w, http.StatusTooManyRequests, "rate-limited")
+		return
+	}
+
+	writeOK(w, map[string]interface{}{
+		"delivered": delivered,
+	})
+}
+
 // ReplayConfigResponse 回放配置响应
 type ReplayConfigResponse struct {
 	OK      bool `json:"ok"`
@@ -432,6 +737,62 @@ func (h *HTTPServer) handleAdminReplayConfig(w http.ResponseWriter, r *http.Requ
 	}
 }
 
+// handleAdminActivityStats 处理活动统计查询
+// 参数: room（房间ID，为空时查询整个服务器）、days（最近天数，默认7）、granularity（hour或day，默认hour）
+func (h *HTTPServer) handleAdminActivityStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method-not-allowed")
+		return
+	}
+
+	query := r.URL.Query()
+	roomID := query.Get("room")
+
+	days := 7
+	if v := query.Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 365 {
+			writeError(w, http.StatusBadRequest, "bad-days")
+			return
+		}
+		days = n
+	}
+
+	granularity := query.Get("granularity")
+	if granularity == "" {
+		granularity = "hour"
+	}
+	if granularity != "hour" && granularity != "day" {
+		writeError(w, http.StatusBadRequest, "bad-granularity")
+		return
+	}
+
+	stats := h.server.GetActivityStats()
+	if stats == nil {
+		writeError(w, http.StatusServiceUnavailable, "stats-disabled")
+		return
+	}
+
+	now := time.Now()
+	from := now.AddDate(0, 0, -days)
+	report := BuildActivityReport(stats.Query(roomID, from, now), granularity == "day", time.Local)
+
+	zone, offset := now.Zone()
+	writeOK(w, map[string]interface{}{
+		"room":        roomID,
+		"granularity": granularity,
+		"from":        from.Unix(),
+		"to":          now.Unix(),
+		"timezone":    zone,
+		"utc_offset":  offset,
+		"series":      report.Series,
+		"heatmap":     report.Heatmap,
+		"hours":       report.Hours,
+		"peak_hours":  report.PeakHours,
+		"quiet_hours": report.QuietHours,
+	})
+}
+
 // handleAdminRoomCreationConfig 处理房间创建配置
 func (h *HTTPServer) handleAdminRoomCreationConfig(w http.ResponseWriter, r *http.Request) {
 	switch r.Method {
@@ -475,6 +836,8 @@ func buildAdminRoomInfo(room *Room) AdminRoomInfo {
 		stateInfo = AdminRoomStateInfo{Type: "select_chart"}
 	case InternalStateWaitForReady:
 		stateInfo = AdminRoomStateInfo{Type: "waiting_for_ready"}
+	case InternalStateLoading:
+		stateInfo = AdminRoomStateInfo{Type: "loading"}
 	case InternalStatePlaying:
 		// 收集已完成和放弃的玩家
 		var finishedUsers []int32
@@ -518,14 +881,22 @@ func buildAdminRoomInfo(room *Room) AdminRoomInfo {
 			IsHost:    u.ID == host.ID,
 			GameTime:  float32(u.gameTime.Load()),
 			Language:  u.Lang,
+			IP:        room.server.DisplayIP(u.GetIP()),
+			Guest:     u.IsGuest(),
+			IdleTime:  int64(u.IdleFor().Seconds()),
+			Latency:   u.latencyMs(),
+			AFK:       u.IsAFK(),
 		}
+		userInfo.DisplayName, userInfo.Avatar = u.loadProfile().Name, u.Avatar()
 		
 		// 如果房间在游戏中，添加游戏状态信息
 		if roomState == InternalStatePlaying {
 			_, finished := room.results.Load(u.ID)
 			_, aborted := room.aborted.Load(u.ID)
+			_, pending := room.pendingResults.Load(u.ID)
 			userInfo.Finished = finished
 			userInfo.Aborted = aborted
+			userInfo.Pending = pending
 			
 			// 如果有成绩，添加record_id
 			if finished {
@@ -545,34 +916,55 @@ func buildAdminRoomInfo(room *Room) AdminRoomInfo {
 	monitorInfos := make([]AdminUserInfo, 0, len(monitors))
 	for _, u := range monitors {
 		monitorInfos = append(monitorInfos, AdminUserInfo{
-			ID:        u.ID,
-			Name:      u.Name,
-			Connected: !u.IsDisconnected(),
-			IsHost:    false,
-			GameTime:  float32(u.gameTime.Load()),
-			Language:  u.Lang,
-			Monitor:   true,
+			ID:          u.ID,
+			Name:        u.Name,
+			Connected:   !u.IsDisconnected(),
+			IsHost:      false,
+			GameTime:    float32(u.gameTime.Load()),
+			Language:    u.Lang,
+			IP:          room.server.DisplayIP(u.GetIP()),
+			Monitor:     true,
+			Guest:       u.IsGuest(),
+			IdleTime:    int64(u.IdleFor().Seconds()),
+			Latency:     u.latencyMs(),
+			AFK:         u.IsAFK(),
+			DisplayName: u.loadProfile().Name,
+			Avatar:      u.Avatar(),
 		})
 	}
 
 	info := AdminRoomInfo{
-		RoomID:   room.ID.Value,
-		MaxUsers: RoomMaxUsers,
-		Live:     room.IsLive(),
-		Locked:   room.IsLocked(),
-		Cycle:    room.IsCycle(),
-		Host:     UserBrief{ID: host.ID, Name: host.Name},
-		State:    stateInfo,
-		Users:    userInfos,
-		Monitors: monitorInfos,
+		RoomID:      room.ID.Value,
+		MaxUsers:    room.GetMaxUsers(),
+		MaxMonitors: room.GetMaxMonitors(),
+		Official:    room.IsOfficial(),
+		Live:        room.IsLive(),
+		Locked:      room.IsLocked(),
+		Password:    room.HasPassword(),
+		Private:     room.IsPrivate(),
+		Cycle:       room.IsCycle(),
+		Overflow:    room.IsOverflow(),
+		UniqueIP:    room.IsUniqueIP(),
+		IPExempt:    room.GetIPExempt(),
+		Host:        UserBrief{ID: host.ID, Name: host.Name},
+		State:       stateInfo,
+		Users:       userInfos,
+		Monitors:    monitorInfos,
+		Teams:       room.GetTeams(),
+		Ranking:     room.GetAggregator().Name(),
+		LastGame:    room.GetLastSummary(),
+		Schedule:    room.GetSchedule(),
+		Contest:     room.GetContest(),
+	}
+
+	// 添加等待队列
+	for _, u := range room.GetQueue() {
+		info.Queue = append(info.Queue, UserBrief{ID: u.ID, Name: u.Name})
 	}
 
 	// 添加谱面信息
 	if chart := room.GetChart(); chart != nil {
-		info.Chart = &ChartInfo{
-			ID:   chart.ID,
-			Name: chart.Name,
-		}
+		info.Chart = newChartInfo(chart)
 	}
 
 	return info
@@ -633,12 +1025,28 @@ func (h *HTTPServer) handleAdminContestConfig(w http.ResponseWriter, r *http.Req
 		return
 	}
 
-	// TODO: 实现比赛房间配置（需要在Room结构中添加比赛模式相关字段）
-	// enabled=true: 启用比赛模式（手动开始 + 结算后解散）
-	// enabled=false: 关闭比赛模式
-	// whitelist为空时，默认取当前房间内所有用户/观战者为白名单
+	// enabled=true: 启用比赛模式（手动开始 + 结算后解散），白名单自动补上房间内现有的用户/观察者
+	// enabled=false: 关闭比赛模式，等待准备中的房间按普通规则检查是否全员准备
+	wasContest := room.IsContest()
+	if req.Enabled {
+		room.SetContest(req.Whitelist)
+	} else {
+		room.ClearContest()
+	}
+	contest := room.GetContest()
+	BroadcastRoomLog(room.ID.Value, fmt.Sprintf("管理员设置比赛模式: %v", req.Enabled))
+	if wasContest != req.Enabled {
+		room.sendContestNotice(req.Enabled)
+	}
+	BroadcastRoomUpdate(room)
+	if !req.Enabled {
+		room.CheckAllReady()
+	}
 
-	writeOK(w, nil)
+	writeOK(w, map[string]interface{}{
+		"roomid":  room.ID.Value,
+		"contest": contest,
+	})
 }
 
 // ContestWhitelistRequest 白名单请求
@@ -658,11 +1066,21 @@ func (h *HTTPServer) handleAdminContestWhitelist(w http.ResponseWriter, r *http.
 		writeError(w, http.StatusBadRequest, "bad-request")
 		return
 	}
+	if !room.IsContest() {
+		writeError(w, http.StatusBadRequest, "not-contest")
+		return
+	}
 
-	// TODO: 实现白名单更新（需要在Room结构中添加白名单字段）
-	// 自动把当前已经在房间内的用户/观战者补进白名单
+	// 自动把当前已经在房间内的用户/观察者补进白名单
+	room.SetContest(req.UserIDs)
+	contest := room.GetContest()
+	BroadcastRoomLog(room.ID.Value, fmt.Sprintf("管理员更新比赛白名单: %v", contest.Whitelist))
+	BroadcastRoomUpdate(room)
 
-	writeOK(w, nil)
+	writeOK(w, map[string]interface{}{
+		"roomid":  room.ID.Value,
+		"contest": contest,
+	})
 }
 
 // ContestStartRequest 比赛开始请求
@@ -682,38 +1100,14 @@ func (h *HTTPServer) handleAdminContestStart(w http.ResponseWriter, r *http.Requ
 		writeError(w, http.StatusBadRequest, "bad-request")
 		return
 	}
-
-	// 检查房间状态
-	if room.GetState() != InternalStateWaitForReady {
-		writeError(w, http.StatusBadRequest, "invalid-state")
+	if err := room.StartContest(req.Force); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// 检查是否全员ready
-	if !req.Force {
-		allReady := true
-		for _, user := range room.GetAllUsers() {
-			if _, ok := room.started.Load(user.ID); !ok {
-				allReady = false
-				break
-			}
-		}
-		if !allReady {
-			writeError(w, http.StatusBadRequest, "not-all-ready")
-			return
-		}
-	}
-
-	// 开始游戏
-	room.SendMessage(common.Message{Type: common.MsgStartPlaying})
-	room.ResetGameTime()
-	room.SetState(InternalStatePlaying)
-	room.Broadcast(common.ServerCommand{
-		Type:        common.ServerCmdChangeState,
-		ChangeState: &common.RoomState{Type: common.RoomStatePlaying},
+	writeOK(w, map[string]interface{}{
+		"roomid": room.ID.Value,
 	})
-
-	writeOK(w, nil)
 }
 
 // handleAdminUserOperations 处理用户相关操作（查询、断开、移动）
@@ -732,10 +1126,77 @@ func (h *HTTPServer) handleAdminUserOperations(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	// 检查是否是清除用户数据请求
+	if strings.HasSuffix(path, "/purge") {
+		h.handleAdminUserPurge(w, r)
+		return
+	}
+
 	// 否则是查询用户详情
 	h.handleAdminUserDetail(w, r)
 }
 
+// handleAdminUserSearch 按名称搜索用户（账号名称或显示名称，不区分大小写的前缀/子串匹配）
+func (h *HTTPServer) handleAdminUserSearch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method-not-allowed")
+		return
+	}
+
+	query := r.URL.Query()
+	name := strings.TrimSpace(query.Get("name"))
+	if name == "" || len(name) > 64 {
+		writeError(w, http.StatusBadRequest, "bad-name")
+		return
+	}
+
+	limit := 0
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > userSearchMaxLimit {
+			writeError(w, http.StatusBadRequest, "bad-limit")
+			return
+		}
+		limit = n
+	}
+
+	users := h.server.SearchUsers(name, limit)
+	if users == nil {
+		users = []UserSearchResult{}
+	}
+	writeOK(w, map[string]interface{}{"users": users})
+}
+
+// handleAdminRecentUsers 最近离线用户列表（按离开时间从新到旧）
+func (h *HTTPServer) handleAdminRecentUsers(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method-not-allowed")
+		return
+	}
+
+	recent := h.server.GetRecentUsers()
+	if recent == nil {
+		writeError(w, http.StatusServiceUnavailable, "recent-users-disabled")
+		return
+	}
+
+	limit := 100
+	if v := r.URL.Query().Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 {
+			writeError(w, http.StatusBadRequest, "bad-limit")
+			return
+		}
+		limit = n
+	}
+
+	users := recent.List(limit)
+	for i := range users {
+		users[i].IP = h.server.DisplayIP(users[i].IP)
+	}
+	writeOK(w, map[string]interface{}{"users": users})
+}
+
 // handleAdminUserDisconnect 处理断开用户连接
 func (h *HTTPServer) handleAdminUserDisconnect(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
@@ -768,11 +1229,8 @@ func (h *HTTPServer) handleAdminUserDisconnect(w http.ResponseWriter, r *http.Re
 		})
 	}
 
-	// 断开连接
-	session := user.GetSession()
-	if session != nil {
-		session.Stop()
-	}
+	// 通知被管理员断开后断开连接
+	user.notifyKicked(room, common.KickReasonAdmin, "", true)
 
 	// 从房间移除用户并触发结算检查
 	if room != nil {
@@ -841,6 +1299,16 @@ func (h *HTTPServer) handleAdminUserMove(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if !req.Monitor && user.IsGuest() {
+		writeError(w, http.StatusBadRequest, "guest-monitor-only")
+		return
+	}
+
+	if req.Monitor && targetRoom.IsMonitorsFull() {
+		writeError(w, http.StatusBadRequest, "monitor-limit")
+		return
+	}
+
 	// 获取源房间
 	sourceRoom := user.GetRoom()
 	if sourceRoom != nil {
@@ -851,12 +1319,18 @@ func (h *HTTPServer) handleAdminUserMove(w http.ResponseWriter, r *http.Request)
 		}
 		// 从源房间移除
 		sourceRoom.RemoveUser(user.ID)
+		sourceRoom.refreshLive()
 	}
 
 	// 添加到目标房间
 	targetRoom.AddUser(user, req.Monitor)
 	user.SetRoom(targetRoom)
 	user.SetMonitor(req.Monitor)
+	if req.Monitor {
+		targetRoom.refreshLive()
+	} else {
+		targetRoom.claimHost(user)
+	}
 
 	writeOK(w, nil)
 }
@@ -1,20 +1,26 @@
 package server
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
 	"os"
-	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
+
+	"phira-mp/common"
 )
 
 // RoomListResponse 房间列表响应
 type RoomListResponse struct {
-	Rooms []RoomInfo `json:"rooms"`
-	Total int        `json:"total"`
+	Rooms     []RoomInfo         `json:"rooms"`
+	Total     int                `json:"total"`
+	Addresses []AdvertiseAddress `json:"addresses,omitempty"` // 服务器连接地址（按优先级排序）
 }
 
 // RoomInfo 房间信息
@@ -26,6 +32,9 @@ type RoomInfo struct {
 	State   string      `json:"state"`
 	Chart   *ChartInfo  `json:"chart,omitempty"`
 	Players []UserBrief `json:"players"`
+
+	Schedule *common.RoomSchedule `json:"schedule,omitempty"` // 开放与关闭时间（Unix毫秒）
+	Password bool                 `json:"password,omitempty"` // 加入需要密码
 }
 
 // UserBrief 用户简要信息
@@ -36,8 +45,20 @@ type UserBrief struct {
 
 // ChartInfo 谱面信息
 type ChartInfo struct {
-	ID   int32  `json:"id"`
-	Name string `json:"name"`
+	ID             int64  `json:"id"`
+	Name           string `json:"name"`
+	DifficultyName string `json:"difficulty_name,omitempty"` // 难度名称（如 "IN"）
+	LevelNumber    string `json:"level_number,omitempty"`    // 等级（如 "15"）
+}
+
+// newChartInfo 房间列表中的谱面信息
+func newChartInfo(chart *Chart) *ChartInfo {
+	return &ChartInfo{
+		ID:             chart.ID,
+		Name:           chart.Name,
+		DifficultyName: chart.DifficultyName,
+		LevelNumber:    chart.LevelNumber,
+	}
 }
 
 // handleRoomList 处理获取房间列表请求
@@ -47,15 +68,28 @@ func (h *HTTPServer) handleRoomList(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 条件请求：房间列表未变化时返回304（可通过 wait 参数长轮询等待变化）
+	if h.waitRoomList(w, r) {
+		return
+	}
+	etag, modified, _ := h.server.roomList.current()
+
 	rooms := h.server.GetAllRooms()
 	roomInfos := make([]RoomInfo, 0, len(rooms))
 
 	for _, room := range rooms {
+		// 私密房间只能通过房间ID加入，不出现在公开列表中
+		if room.IsPrivate() {
+			continue
+		}
+
 		host := room.GetHost()
 		state := "select_chart"
 		switch room.GetState() {
 		case InternalStateWaitForReady:
 			state = "waiting_for_ready"
+		case InternalStateLoading:
+			state = "loading"
 		case InternalStatePlaying:
 			state = "playing"
 		}
@@ -66,7 +100,7 @@ func (h *HTTPServer) handleRoomList(w http.ResponseWriter, r *http.Request) {
 		for _, u := range users {
 			players = append(players, UserBrief{
 				ID:   u.ID,
-				Name: u.Name,
+				Name: u.DisplayName(),
 			})
 		}
 
@@ -74,25 +108,29 @@ func (h *HTTPServer) handleRoomList(w http.ResponseWriter, r *http.Request) {
 			RoomID:  room.ID.Value,
 			Cycle:   room.IsCycle(),
 			Lock:    room.IsLocked(),
-			Host:    UserBrief{ID: host.ID, Name: host.Name},
+			Host:    UserBrief{ID: host.ID, Name: host.DisplayName()},
 			State:   state,
 			Players: players,
+
+			Schedule: room.GetSchedule(),
+			Password: room.HasPassword(),
 		}
 
 		// 添加谱面信息
 		if chart := room.GetChart(); chart != nil {
-			info.Chart = &ChartInfo{
-				ID:   chart.ID,
-				Name: chart.Name,
-			}
+			info.Chart = newChartInfo(chart)
 		}
 
 		roomInfos = append(roomInfos, info)
 	}
 
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	w.Header().Set("Cache-Control", "no-cache")
 	writeOK(w, RoomListResponse{
-		Rooms: roomInfos,
-		Total: len(roomInfos),
+		Rooms:     roomInfos,
+		Total:     len(roomInfos),
+		Addresses: h.server.AdvertisedAddresses(),
 	})
 }
 
@@ -114,14 +152,19 @@ type ReplayAuthResponse struct {
 
 // ChartReplay 谱面回放信息
 type ChartReplay struct {
-	ChartID int32        `json:"chartId"`
+	ChartID int64        `json:"chartId"`
 	Replays []ReplayInfo `json:"replays"`
 }
 
 // ReplayInfo 回放信息
 type ReplayInfo struct {
-	Timestamp int64 `json:"timestamp"`
-	RecordID  int32 `json:"recordId"`
+	ID        string `json:"id"`
+	Timestamp int64  `json:"timestamp"`
+	RecordID  int64  `json:"recordId"`
+	ClipOf    string `json:"clipOf,omitempty"` // 片段的源回放ID
+
+	DownloadURL       string `json:"downloadUrl,omitempty"`       // 带签名的下载链接
+	DownloadExpiresAt int64  `json:"downloadExpiresAt,omitempty"` // 下载链接过期时间（毫秒时间戳）
 }
 
 // SessionTokenInfo session token信息
@@ -131,11 +174,47 @@ type SessionTokenInfo struct {
 	ExpiresAt time.Time
 }
 
-// ReplaySessionManager 回放会话管理器
+// ReplaySessionManager 回放会话管理器（HTTP 请求并发访问，须通过方法读写）
 type ReplaySessionManager struct {
+	mu     sync.Mutex
 	tokens map[string]*SessionTokenInfo
 }
 
+// Get 获取未过期的 session token，已过期的同时移除
+func (m *ReplaySessionManager) Get(token string) (*SessionTokenInfo, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	info, ok := m.tokens[token]
+	if !ok {
+		return nil, false
+	}
+	if time.Now().After(info.ExpiresAt) {
+		delete(m.tokens, token)
+		return nil, false
+	}
+	return info, true
+}
+
+// Put 保存 session token，并清理已过期的
+func (m *ReplaySessionManager) Put(info *SessionTokenInfo) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for token, old := range m.tokens {
+		if now.After(old.ExpiresAt) {
+			delete(m.tokens, token)
+		}
+	}
+	m.tokens[info.Token] = info
+}
+
+// Delete 移除 session token
+func (m *ReplaySessionManager) Delete(token string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.tokens, token)
+}
+
 var replaySessions = &ReplaySessionManager{
 	tokens: make(map[string]*SessionTokenInfo),
 }
@@ -164,14 +243,23 @@ func (h *HTTPServer) handleReplayAuth(w http.ResponseWriter, r *http.Request) {
 	sessionToken := generateUUID()
 	expiresAt := time.Now().Add(SessionTokenExpire)
 
-	replaySessions.tokens[sessionToken] = &SessionTokenInfo{
+	replaySessions.Put(&SessionTokenInfo{
 		UserID:    user.ID,
 		Token:     sessionToken,
 		ExpiresAt: expiresAt,
-	}
+	})
 
-	// 获取用户的回放列表
-	charts := getUserReplays(user.ID)
+	// 获取用户的回放列表，并为每个回放生成签名下载链接
+	charts := h.getUserReplays(user.ID)
+	now := time.Now()
+	for i := range charts {
+		for j := range charts[i].Replays {
+			replay := &charts[i].Replays[j]
+			link, linkExpiresAt := h.replaySigner.Sign(replay.ID, now)
+			replay.DownloadURL = link
+			replay.DownloadExpiresAt = linkExpiresAt.UnixMilli()
+		}
+	}
 
 	writeOK(w, map[string]interface{}{
 		"userId":       user.ID,
@@ -181,83 +269,96 @@ func (h *HTTPServer) handleReplayAuth(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
-// getUserReplays 获取用户回放列表
-func getUserReplays(userID int32) []ChartReplay {
-	recordDir := filepath.Join("record", fmt.Sprintf("%d", userID))
-
-	entries, err := os.ReadDir(recordDir)
-	if err != nil {
+// getUserReplays 获取用户回放列表（按谱面分组）
+func (h *HTTPServer) getUserReplays(userID int32) []ChartReplay {
+	recorder := h.server.GetReplayRecorder()
+	if recorder == nil {
 		return []ChartReplay{}
 	}
 
-	chartMap := make(map[int32][]ReplayInfo)
-
-	for _, entry := range entries {
-		if entry.IsDir() {
-			continue
-		}
-
-		// 解析文件名: {timestamp}.phirarec
-		name := entry.Name()
-		if !strings.HasSuffix(name, ".phirarec") {
-			continue
+	chartMap := make(map[int64][]ReplayInfo)
+	var chartOrder []int64
+	for _, entry := range recorder.GetReplayIndex().ListUser(userID) {
+		if _, ok := chartMap[entry.ChartID]; !ok {
+			chartOrder = append(chartOrder, entry.ChartID)
 		}
-
-		// 读取文件头获取chartID
-		filepath := filepath.Join(recordDir, name)
-		chartID, timestamp, recordID := parseReplayFileHeader(filepath)
-		if chartID == 0 {
-			continue
-		}
-
-		chartMap[chartID] = append(chartMap[chartID], ReplayInfo{
-			Timestamp: timestamp,
-			RecordID:  recordID,
+		chartMap[entry.ChartID] = append(chartMap[entry.ChartID], ReplayInfo{
+			ID:        entry.ID,
+			Timestamp: entry.Timestamp,
+			RecordID:  entry.RecordID,
+			ClipOf:    entry.ClipOf,
 		})
 	}
 
 	// 转换为响应格式
 	result := make([]ChartReplay, 0, len(chartMap))
-	for chartID, replays := range chartMap {
+	for _, chartID := range chartOrder {
 		result = append(result, ChartReplay{
 			ChartID: chartID,
-			Replays: replays,
+			Replays: chartMap[chartID],
 		})
 	}
 
 	return result
 }
 
-// parseReplayFileHeader 解析回放文件头
-func parseReplayFileHeader(path string) (chartID int32, timestamp int64, recordID int32) {
+// readReplayHeader 读取回放文件头（加密的回放文件头同样为明文）
+func readReplayHeader(path string) (chartID int64, userID int32, recordID int64, encrypted, ok bool) {
 	file, err := os.Open(path)
 	if err != nil {
-		return 0, 0, 0
+		return 0, 0, 0, false, false
 	}
 	defer file.Close()
 
 	// 读取文件头 (14字节)
-	header := make([]byte, 14)
-	if _, err := file.Read(header); err != nil {
-		return 0, 0, 0
+	header := make([]byte, replayHeaderSize)
+	if _, err := io.ReadFull(file, header); err != nil {
+		return 0, 0, 0, false, false
 	}
 
-	// 验证文件标识 (0x504D)
-	if binary.LittleEndian.Uint16(header[0:2]) != 0x504D {
-		return 0, 0, 0
+	// 验证文件标识 (明文 0x504D，加密 0x454D)
+	switch binary.LittleEndian.Uint16(header[0:2]) {
+	case replayMagic:
+	case replayMagicEncrypted:
+		encrypted = true
+	default:
+		return 0, 0, 0, false, false
 	}
 
-	chartID = int32(binary.LittleEndian.Uint32(header[2:6]))
-	// userID在header[6:10]，不需要
-	recordID = int32(binary.LittleEndian.Uint32(header[10:14]))
+	chartID = int64(int32(binary.LittleEndian.Uint32(header[2:6])))
+	userID = int32(binary.LittleEndian.Uint32(header[6:10]))
+	recordID = int64(int32(binary.LittleEndian.Uint32(header[10:14])))
+	return chartID, userID, recordID, encrypted, true
+}
+
+// findUserReplay 按回放ID（或旧版的谱面ID+时间戳）查找用户自己的回放
+func (h *HTTPServer) findUserReplay(userID int32, id, chartIDStr, timestampStr string) *ReplayEntry {
+	recorder := h.server.GetReplayRecorder()
+	if recorder == nil {
+		return nil
+	}
+	index := recorder.GetReplayIndex()
 
-	// 从文件名获取时间戳
-	filename := filepath.Base(path)
-	filename = strings.TrimSuffix(filename, ".phirarec")
-	ts, _ := strconv.ParseInt(filename, 10, 64)
-	timestamp = ts
+	var entry *ReplayEntry
+	if id != "" {
+		entry = index.Get(id)
+	} else {
+		chartID, err := strconv.ParseInt(chartIDStr, 10, 64)
+		if err != nil {
+			return nil
+		}
+		timestamp, err := strconv.ParseInt(timestampStr, 10, 64)
+		if err != nil {
+			return nil
+		}
+		entry = index.Find(userID, chartID, timestamp)
+	}
 
-	return chartID, timestamp, recordID
+	// 只允许访问自己的回放
+	if entry == nil || entry.UserID != userID {
+		return nil
+	}
+	return entry
 }
 
 // handleReplayDownload 处理回放下载
@@ -267,49 +368,85 @@ func (h *HTTPServer) handleReplayDownload(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	// 获取参数
-	sessionToken := r.URL.Query().Get("sessionToken")
-	chartIDStr := r.URL.Query().Get("chartId")
-	timestampStr := r.URL.Query().Get("timestamp")
+	// 签名链接：无状态校验，可由CDN缓存
+	query := r.URL.Query()
+	if sig := query.Get("sig"); sig != "" {
+		h.handleSignedReplayDownload(w, r, query.Get("id"), query.Get("expires"), sig)
+		return
+	}
+
+	// 获取参数（优先使用回放ID，兼容旧版的谱面ID+时间戳）
+	sessionToken := query.Get("sessionToken")
+	id := query.Get("id")
+	chartIDStr := query.Get("chartId")
+	timestampStr := query.Get("timestamp")
 
-	if sessionToken == "" || chartIDStr == "" || timestampStr == "" {
+	if sessionToken == "" || (id == "" && (chartIDStr == "" || timestampStr == "")) {
 		writeError(w, http.StatusBadRequest, "bad-request")
 		return
 	}
 
 	// 验证session token
-	session, ok := replaySessions.tokens[sessionToken]
-	if !ok || time.Now().After(session.ExpiresAt) {
+	session, ok := replaySessions.Get(sessionToken)
+	if !ok {
 		writeError(w, http.StatusUnauthorized, "unauthorized")
 		return
 	}
 
-	chartID, err := strconv.ParseInt(chartIDStr, 10, 32)
-	if err != nil {
-		writeError(w, http.StatusBadRequest, "bad-request")
+	entry := h.findUserReplay(session.UserID, id, chartIDStr, timestampStr)
+	if entry == nil {
+		writeError(w, http.StatusNotFound, "not-found")
 		return
 	}
 
-	timestamp, err := strconv.ParseInt(timestampStr, 10, 64)
-	if err != nil {
-		writeError(w, http.StatusBadRequest, "bad-request")
+	h.serveReplay(w, r, entry)
+}
+
+// handleSignedReplayDownload 处理签名链接下载
+func (h *HTTPServer) handleSignedReplayDownload(w http.ResponseWriter, r *http.Request, id, expires, sig string) {
+	remaining, ok := h.replaySigner.Verify(id, expires, sig, time.Now())
+	if !ok {
+		writeError(w, http.StatusForbidden, "invalid-signature")
 		return
 	}
 
-	// 构建文件路径
-	filepath := filepath.Join("record", fmt.Sprintf("%d", session.UserID),
-		fmt.Sprintf("%d", chartID), fmt.Sprintf("%d.phirarec", timestamp))
-
-	// 检查文件是否存在
-	if _, err := os.Stat(filepath); os.IsNotExist(err) {
+	recorder := h.server.GetReplayRecorder()
+	if recorder == nil {
 		writeError(w, http.StatusNotFound, "not-found")
 		return
 	}
+	entry := recorder.GetReplayIndex().Get(id)
+	if entry == nil {
+		writeError(w, http.StatusNotFound, "not-found")
+		return
+	}
+
+	// 回放文件不会变化，允许CDN在链接有效期内缓存
+	w.Header().Set("Cache-Control", fmt.Sprintf("public, max-age=%d", int(remaining.Seconds())))
+	h.serveReplay(w, r, entry)
+}
+
+// serveReplay 发送回放文件（已上传至对象存储的回放跳转到签名链接，加密的回放由服务器解密后发送）
+func (h *HTTPServer) serveReplay(w http.ResponseWriter, r *http.Request, entry *ReplayEntry) {
+	recorder := h.server.GetReplayRecorder()
+	if entry.Encrypted {
+		h.serveEncryptedReplay(w, recorder, entry)
+		return
+	}
+	if recorder != nil && recorder.GetStorage() != nil && entry.ObjectKey != "" {
+		storage := recorder.GetStorage()
+		http.Redirect(w, r, storage.PresignGet(entry.ObjectKey, storage.URLExpire()), http.StatusFound)
+		return
+	}
 
 	// 打开文件
-	file, err := os.Open(filepath)
+	file, err := os.Open(entry.Path)
 	if err != nil {
-		writeError(w, http.StatusInternalServerError, "internal-error")
+		if os.IsNotExist(err) {
+			writeError(w, http.StatusNotFound, "not-found")
+		} else {
+			writeError(w, http.StatusInternalServerError, "internal-error")
+		}
 		return
 	}
 	defer file.Close()
@@ -321,16 +458,48 @@ func (h *HTTPServer) handleReplayDownload(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	writeReplayBody(w, file, stat.Size(), entry)
+}
+
+// serveEncryptedReplay 解密后发送回放（本地文件不存在时从对象存储读取）
+func (h *HTTPServer) serveEncryptedReplay(w http.ResponseWriter, recorder *ReplayRecorder, entry *ReplayEntry) {
+	if recorder == nil {
+		writeError(w, http.StatusNotFound, "not-found")
+		return
+	}
+	data, err := os.ReadFile(entry.Path)
+	if os.IsNotExist(err) && recorder.GetStorage() != nil && entry.ObjectKey != "" {
+		data, err = recorder.GetStorage().GetObject(entry.ObjectKey)
+	}
+	if err != nil {
+		if os.IsNotExist(err) {
+			writeError(w, http.StatusNotFound, "not-found")
+		} else {
+			writeError(w, http.StatusInternalServerError, "internal-error")
+		}
+		return
+	}
+	plain, err := decodeReplayData(data, recorder.cipher)
+	if err != nil {
+		log.Printf("读取回放 %s 失败: %v", entry.ID, err)
+		writeError(w, http.StatusInternalServerError, "decrypt-failed")
+		return
+	}
+	writeReplayBody(w, bytes.NewReader(plain), int64(len(plain)), entry)
+}
+
+// writeReplayBody 以下载附件形式限速发送回放内容
+func writeReplayBody(w http.ResponseWriter, body io.Reader, size int64, entry *ReplayEntry) {
 	// 设置响应头
 	w.Header().Set("Content-Type", "application/octet-stream")
-	w.Header().Set("Content-Length", fmt.Sprintf("%d", stat.Size()))
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%d.phirarec\"", timestamp))
+	w.Header().Set("Content-Length", fmt.Sprintf("%d", size))
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=\"%d.phirarec\"", entry.Timestamp))
 
 	// 限速50KB/s传输
 	const rateLimit = 50 * 1024 // 50KB/s
 	buffer := make([]byte, rateLimit)
 	for {
-		n, err := file.Read(buffer)
+		n, err := body.Read(buffer)
 		if n > 0 {
 			w.Write(buffer[:n])
 			// 限速：每秒传输50KB
@@ -345,7 +514,8 @@ func (h *HTTPServer) handleReplayDownload(w http.ResponseWriter, r *http.Request
 // ReplayDeleteRequest 删除回放请求
 type ReplayDeleteRequest struct {
 	SessionToken string `json:"sessionToken"`
-	ChartID      int32  `json:"chartId"`
+	ID           string `json:"id"`
+	ChartID      int64  `json:"chartId"`
 	Timestamp    int64  `json:"timestamp"`
 }
 
@@ -363,31 +533,207 @@ func (h *HTTPServer) handleReplayDelete(w http.ResponseWriter, r *http.Request)
 	}
 
 	// 验证session token
-	session, ok := replaySessions.tokens[req.SessionToken]
-	if !ok || time.Now().After(session.ExpiresAt) {
+	session, ok := replaySessions.Get(req.SessionToken)
+	if !ok {
 		writeError(w, http.StatusUnauthorized, "unauthorized")
 		return
 	}
 
-	// 构建文件路径
-	filepath := filepath.Join("record", fmt.Sprintf("%d", session.UserID),
-		fmt.Sprintf("%d", req.ChartID), fmt.Sprintf("%d.phirarec", req.Timestamp))
+	entry := h.findUserReplay(session.UserID, req.ID,
+		strconv.FormatInt(req.ChartID, 10), strconv.FormatInt(req.Timestamp, 10))
+	if entry == nil {
+		writeError(w, http.StatusNotFound, "not-found")
+		return
+	}
+
+	// 删除文件、索引条目及对象存储中的副本
+	if err := h.server.GetReplayRecorder().DeleteReplay(entry.ID); err != nil {
+		writeError(w, http.StatusInternalServerError, "internal-error")
+		return
+	}
+
+	writeOK(w, nil)
+}
 
-	// 检查文件是否存在
-	if _, err := os.Stat(filepath); os.IsNotExist(err) {
+// ReplayShareRequest 创建回放分享请求
+type ReplayShareRequest struct {
+	SessionToken string `json:"sessionToken"`
+	ID           string `json:"id"`
+	ExpiresIn    int64  `json:"expiresIn"` // 有效秒数，0表示永不过期
+}
+
+// handleReplayShare 处理创建回放分享链接
+func (h *HTTPServer) handleReplayShare(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method-not-allowed")
+		return
+	}
+
+	var req ReplayShareRequest
+	if err := parseBody(r, &req); err != nil || req.ID == "" || req.ExpiresIn < 0 {
+		writeError(w, http.StatusBadRequest, "bad-request")
+		return
+	}
+
+	// 验证session token
+	session, ok := replaySessions.Get(req.SessionToken)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	// 只能分享自己的回放
+	entry := h.findUserReplay(session.UserID, req.ID, "", "")
+	if entry == nil {
+		writeError(w, http.StatusNotFound, "not-found")
+		return
+	}
+
+	index := h.server.GetReplayRecorder().GetReplayIndex()
+	share := index.AddShare(entry.ID, time.Duration(req.ExpiresIn)*time.Second)
+	if err := index.Save(); err != nil {
+		log.Printf("保存回放索引失败: %v", err)
+	}
+
+	writeOK(w, map[string]interface{}{
+		"shareToken": share.Token,
+		"path":       "/replay/shared/" + share.Token,
+		"expiresAt":  share.ExpiresAt,
+	})
+}
+
+// ReplayClipRequest 截取回放片段请求
+type ReplayClipRequest struct {
+	SessionToken string  `json:"sessionToken"`
+	ID           string  `json:"id"`
+	Start        float64 `json:"start"` // 起始时间（秒）
+	End          float64 `json:"end"`   // 结束时间（秒）
+}
+
+// handleReplayClip 处理截取回放片段
+func (h *HTTPServer) handleReplayClip(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method-not-allowed")
+		return
+	}
+
+	var req ReplayClipRequest
+	if err := parseBody(r, &req); err != nil || req.ID == "" {
+		writeError(w, http.StatusBadRequest, "bad-request")
+		return
+	}
+	if req.Start < 0 || req.End <= req.Start {
+		writeError(w, http.StatusBadRequest, "bad-range")
+		return
+	}
+
+	// 验证session token
+	session, ok := replaySessions.Get(req.SessionToken)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	// 只能截取自己的回放
+	entry := h.findUserReplay(session.UserID, req.ID, "", "")
+	if entry == nil {
 		writeError(w, http.StatusNotFound, "not-found")
 		return
 	}
+	recorder := h.server.GetReplayRecorder()
+	if recorder.GetReplayIndex().CountClips(entry.ID) >= MaxReplayClipsPerReplay {
+		writeError(w, http.StatusTooManyRequests, "too-many-clips")
+		return
+	}
 
-	// 删除文件
-	if err := os.Remove(filepath); err != nil {
+	clip, records, err := recorder.ClipReplay(entry.ID, req.Start, req.End)
+	if err != nil {
+		log.Printf("截取回放 %s 失败: %v", entry.ID, err)
 		writeError(w, http.StatusInternalServerError, "internal-error")
 		return
 	}
 
+	writeOK(w, map[string]interface{}{
+		"id":        clip.ID,
+		"timestamp": clip.Timestamp,
+		"records":   records,
+	})
+}
+
+// ReplayUnshareRequest 撤销回放分享请求
+type ReplayUnshareRequest struct {
+	SessionToken string `json:"sessionToken"`
+	ShareToken   string `json:"shareToken"`
+}
+
+// handleReplayUnshare 处理撤销回放分享链接
+func (h *HTTPServer) handleReplayUnshare(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method-not-allowed")
+		return
+	}
+
+	var req ReplayUnshareRequest
+	if err := parseBody(r, &req); err != nil || req.ShareToken == "" {
+		writeError(w, http.StatusBadRequest, "bad-request")
+		return
+	}
+
+	// 验证session token
+	session, ok := replaySessions.Get(req.SessionToken)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "unauthorized")
+		return
+	}
+
+	index := h.server.GetReplayRecorder().GetReplayIndex()
+	share := index.GetShare(req.ShareToken)
+	if share == nil || h.findUserReplay(session.UserID, share.ReplayID, "", "") == nil {
+		writeError(w, http.StatusNotFound, "not-found")
+		return
+	}
+
+	index.RemoveShare(share.Token)
+	if err := index.Save(); err != nil {
+		log.Printf("保存回放索引失败: %v", err)
+	}
+
 	writeOK(w, nil)
 }
 
+// handleReplayShared 处理通过分享链接下载回放（无需session token）
+func (h *HTTPServer) handleReplayShared(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method-not-allowed")
+		return
+	}
+
+	token := strings.TrimPrefix(r.URL.Path, "/replay/shared/")
+	if token == "" || strings.Contains(token, "/") {
+		writeError(w, http.StatusBadRequest, "bad-request")
+		return
+	}
+
+	recorder := h.server.GetReplayRecorder()
+	if recorder == nil {
+		writeError(w, http.StatusNotFound, "not-found")
+		return
+	}
+
+	share := recorder.GetReplayIndex().GetShare(token)
+	if share == nil {
+		writeError(w, http.StatusNotFound, "not-found")
+		return
+	}
+	entry := recorder.GetReplayIndex().Get(share.ReplayID)
+	if entry == nil {
+		writeError(w, http.StatusNotFound, "not-found")
+		return
+	}
+
+	h.serveReplay(w, r, entry)
+}
+
 // ==================== OTP接口 ====================
 
 // OTPRequestResponse OTP请求响应
@@ -3,6 +3,7 @@ package server
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net"
@@ -31,6 +32,7 @@ type HTTPServer struct {
 	adminData  *AdminData
 	otpManager *OTPManager
 	httpServer *http.Server
+	listener   net.Listener // HTTP服务的监听套接字（平滑升级时移交给新进程）
 	mu         sync.RWMutex
 
 	// 运行时配置
@@ -42,6 +44,23 @@ type HTTPServer struct {
 
 	// 认证限流器
 	authLimiter *AuthLimiter
+
+	// OIDC令牌校验器（未配置时为nil）
+	oidc *OIDCVerifier
+
+	// 浏览器管理面板的Cookie会话
+	adminSessions *AdminSessionManager
+
+	// 回放下载链接签名器
+	replaySigner *ReplayURLSigner
+
+	// 公开接口限流器（未启用时为nil）
+	roomLimiter   *RateLimiter
+	replayLimiter *RateLimiter
+
+	// 管理员数据延迟保存
+	adminSaveMu    sync.Mutex
+	adminSaveTimer *time.Timer
 }
 
 // HTTPConfig HTTP配置
@@ -73,6 +92,19 @@ func NewHTTPServer(server *Server, config HTTPConfig) *HTTPServer {
 		roomCreationEnabled: true,
 		realIPHeader:        server.config.RealIPHeader,
 		authLimiter:         NewAuthLimiter(),
+		adminSessions:       NewAdminSessionManager(),
+		roomLimiter:         NewRateLimiter("room", server.config.PublicRateLimit.Room),
+		replayLimiter:       NewRateLimiter("replay", server.config.PublicRateLimit.Replay),
+	}
+
+	// 回放下载签名链接
+	urlExpire := time.Duration(server.config.ReplayURLExpire) * time.Second
+	httpServer.replaySigner = NewReplayURLSigner(server.config.ReplaySignKey, server.config.ReplayURLBase, urlExpire)
+
+	if server.config.AdminOIDC.Enabled() {
+		httpServer.oidc = NewOIDCVerifier(server.config.AdminOIDC)
+	} else if server.config.AdminOIDC.Configured() {
+		log.Printf("[安全] %s，已禁用OIDC登录", server.config.AdminOIDC.Problem())
 	}
 
 	// 加载管理员数据
@@ -93,26 +125,53 @@ func (h *HTTPServer) Start() error {
 	mux.HandleFunc("/ws", h.HandleWebSocket)
 
 	// 公共接口
-	mux.HandleFunc("/room", h.handleRoomList)
+	mux.HandleFunc("/room", h.withRateLimit(h.roomLimiter, h.handleRoomList))
+	mux.HandleFunc("/rooms/", h.withRateLimit(h.roomLimiter, h.handleRoomLeaderboard))
+	mux.HandleFunc("/healthz", h.handleHealthz)
+	if h.server.config.PublicPage {
+		mux.Handle(PublicPagePath, PublicPageHandler())
+	}
 
 	// 回放接口
-	mux.HandleFunc("/replay/auth", h.handleReplayAuth)
-	mux.HandleFunc("/replay/download", h.handleReplayDownload)
-	mux.HandleFunc("/replay/delete", h.handleReplayDelete)
+	mux.HandleFunc("/replay/auth", h.withRateLimit(h.replayLimiter, h.handleReplayAuth))
+	mux.HandleFunc("/replay/download", h.withRateLimit(h.replayLimiter, h.handleReplayDownload))
+	mux.HandleFunc("/replay/delete", h.withRateLimit(h.replayLimiter, h.handleReplayDelete))
+	mux.HandleFunc("/replay/clip", h.withRateLimit(h.replayLimiter, h.handleReplayClip))
+	mux.HandleFunc("/replay/share", h.withRateLimit(h.replayLimiter, h.handleReplayShare))
+	mux.HandleFunc("/replay/unshare", h.withRateLimit(h.replayLimiter, h.handleReplayUnshare))
+	mux.HandleFunc("/replay/shared/", h.withRateLimit(h.replayLimiter, h.handleReplayShared))
 
 	// OTP接口（仅在未配置永久token时可用）
 	mux.HandleFunc("/admin/otp/request", h.handleOTPRequest)
 	mux.HandleFunc("/admin/otp/verify", h.handleOTPVerify)
+	mux.HandleFunc("/admin/login", h.handleAdminLogin)
+	mux.HandleFunc("/admin/session", h.handleAdminSession)
+	mux.HandleFunc("/admin/logout", h.handleAdminLogout)
+
+	// 内置管理面板
+	mux.Handle(AdminUIPath, AdminUIHandler())
+	mux.Handle("/admin/ui", http.RedirectHandler(AdminUIPath, http.StatusMovedPermanently))
 
 	// 管理员接口
 	mux.HandleFunc("/admin/rooms", h.withAdminAuth(h.handleAdminRooms))
 	mux.HandleFunc("/admin/rooms/", h.withAdminAuth(h.handleAdminRoomDetail))
 	mux.HandleFunc("/admin/users/", h.withAdminAuth(h.handleAdminUserOperations))
+	mux.HandleFunc("/admin/users/search", h.withAdminAuth(h.handleAdminUserSearch))
+	mux.HandleFunc("/admin/users/recent", h.withAdminAuth(h.handleAdminRecentUsers))
 	mux.HandleFunc("/admin/ban/user", h.withAdminAuth(h.handleAdminBanUser))
 	mux.HandleFunc("/admin/ban/room", h.withAdminAuth(h.handleAdminBanRoom))
+	mux.HandleFunc("/admin/ban/users", h.withAdminAuth(h.handleAdminBanUsers))
+	mux.HandleFunc("/admin/ban/users/", h.withAdminAuth(h.handleAdminBanUsers))
 	mux.HandleFunc("/admin/broadcast", h.withAdminAuth(h.handleAdminBroadcast))
+	mux.HandleFunc("/admin/global-chat", h.withAdminAuth(h.handleAdminGlobalChat))
+	mux.HandleFunc("/admin/stats/activity", h.withAdminAuth(h.handleAdminActivityStats))
+	mux.HandleFunc("/admin/stats/peaks", h.withAdminAuth(h.handleAdminPeakStats))
+	mux.HandleFunc("/admin/stats/commands", h.withAdminAuth(h.handleAdminCommandStats))
+	mux.HandleFunc("/admin/stats/disconnects", h.withAdminAuth(h.handleAdminDisconnectStats))
+	mux.HandleFunc("/metrics", h.withAdminAuth(h.handleMetrics))
 	mux.HandleFunc("/admin/replay/config", h.withAdminAuth(h.handleAdminReplayConfig))
 	mux.HandleFunc("/admin/room-creation/config", h.withAdminAuth(h.handleAdminRoomCreationConfig))
+	mux.HandleFunc("/admin/replay-rooms", h.withAdminAuth(h.handleAdminReplayRooms))
 
 	// 比赛房间接口
 	mux.HandleFunc("/admin/contest/rooms/", h.withAdminAuth(h.handleAdminContest))
@@ -122,9 +181,16 @@ func (h *HTTPServer) Start() error {
 		Handler: withCORS(mux),
 	}
 
+	listener, err := listen(handoverHTTP, h.httpServer.Addr)
+	if err != nil {
+		return err
+	}
+	h.listener = listener
+
 	log.Printf("HTTP服务正在偷听 %d", h.config.Port)
 	go func() {
-		if err := h.httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		// 平滑升级时监听套接字在 Drain 中关闭，已建立的连接继续服务
+		if err := h.httpServer.Serve(listener); err != nil && err != http.ErrServerClosed && !errors.Is(err, net.ErrClosed) {
 			log.Printf("HTTP服务错误: %v", err)
 		}
 	}()
@@ -132,16 +198,27 @@ func (h *HTTPServer) Start() error {
 	return nil
 }
 
-// Stop 停止HTTP服务
+// Stop 停止HTTP服务（最多等待5秒）
 func (h *HTTPServer) Stop() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return h.Shutdown(ctx)
+}
+
+// Shutdown 停止HTTP服务并关闭该服务的WebSocket连接，等待进行中的请求直到 ctx 结束
+func (h *HTTPServer) Shutdown(ctx context.Context) error {
 	// 停止认证限流器
 	if h.authLimiter != nil {
 		h.authLimiter.Stop()
 	}
 
+	// 写入尚未保存的管理员数据
+	h.flushAdminData()
+
+	// WebSocket连接已被接管，http.Server.Shutdown 不会关闭
+	hub.closeServer(h)
+
 	if h.httpServer != nil {
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-		defer cancel()
 		return h.httpServer.Shutdown(ctx)
 	}
 	return nil
@@ -177,8 +254,13 @@ func (h *HTTPServer) SetRoomCreationEnabled(enabled bool) {
 
 // 获取admin_data.json路径
 func (h *HTTPServer) getAdminDataPath() string {
-	if h.config.AdminDataPath != "" {
-		return h.config.AdminDataPath
+	return adminDataPath(h.config.AdminDataPath)
+}
+
+// adminDataPath 解析admin_data.json路径（未配置时使用默认位置）
+func adminDataPath(configured string) string {
+	if configured != "" {
+		return configured
 	}
 
 	// 优先使用PHIRA_MP_HOME环境变量
@@ -198,12 +280,36 @@ func (h *HTTPServer) loadAdminData() {
 	}
 }
 
-// 保存管理员数据
+// adminDataSaveDelay 管理员数据修改后延迟保存的时间，合并短时间内的多次修改
+const adminDataSaveDelay = time.Second
+
+// 保存管理员数据（在后台延迟写入，不阻塞HTTP请求）
 func (h *HTTPServer) saveAdminData() {
+	h.adminSaveMu.Lock()
+	defer h.adminSaveMu.Unlock()
+	if h.adminSaveTimer == nil {
+		h.adminSaveTimer = time.AfterFunc(adminDataSaveDelay, func() { h.flushAdminData() })
+	}
+}
+
+// flushAdminData 立即保存有修改的管理员数据（失败时数据保持未保存状态，下次保存重试）
+func (h *HTTPServer) flushAdminData() error {
+	h.adminSaveMu.Lock()
+	if h.adminSaveTimer != nil {
+		h.adminSaveTimer.Stop()
+		h.adminSaveTimer = nil
+	}
+	h.adminSaveMu.Unlock()
+
+	if !h.adminData.Dirty() {
+		return nil
+	}
 	path := h.getAdminDataPath()
 	if err := h.adminData.Save(path); err != nil {
 		log.Printf("保存管理员数据失败: %v", err)
+		return err
 	}
+	return nil
 }
 
 // JSON响应辅助函数
@@ -283,6 +389,36 @@ func (h *HTTPServer) withAdminAuth(handler http.HandlerFunc) http.HandlerFunc {
 			return
 		}
 
+		// 未携带token时使用浏览器Cookie会话（需同源，非GET请求需携带CSRF token）
+		if extractToken(r) == "" {
+			if session := h.cookieSession(r); session != nil {
+				if code := h.checkCookieSession(r, session); code != "" {
+					writeError(w, http.StatusForbidden, code)
+					log.Printf("[安全] IP %s 管理员Cookie会话校验失败: %s", clientIP, code)
+					return
+				}
+				handler(w, r)
+				return
+			}
+		}
+
+		// 检查OIDC令牌（与永久token相同时按永久token处理）
+		if token := extractToken(r); h.oidc != nil && LooksLikeJWT(token) && token != h.config.AdminToken {
+			claims, err := h.oidc.Verify(token)
+			if err != nil {
+				remaining := h.authLimiter.GetRemainingAttempts(clientIP)
+				writeError(w, http.StatusUnauthorized, "unauthorized")
+				log.Printf("[安全] IP %s OIDC令牌验证失败: %v，剩余尝试次数: %d", clientIP, err, remaining)
+				return
+			}
+			h.authLimiter.RecordSuccess(clientIP)
+			if h.server.IsDebugEnabled() {
+				log.Printf("[DEBUG] 管理员 %s 通过OIDC访问 %s", claims.Display(), r.URL.Path)
+			}
+			handler(w, r)
+			return
+		}
+
 		// 检查是否配置了永久token
 		if h.config.AdminToken != "" {
 			token := extractToken(r)
@@ -322,6 +458,7 @@ func (h *HTTPServer) withAdminAuth(handler http.HandlerFunc) http.HandlerFunc {
 }
 
 // getClientIP 获取客户端IP（HTTP服务使用配置的头）
+// 只信任显式配置的 real_ip_header：未经反向代理时客户端可以任意伪造 X-Forwarded-For 等请求头，绕过按IP的限流与锁定
 func (h *HTTPServer) getClientIP(r *http.Request) string {
 	// 如果配置了特定的真实IP头，优先使用
 	if h.realIPHeader != "" {
@@ -334,26 +471,12 @@ func (h *HTTPServer) getClientIP(r *http.Request) string {
 		}
 	}
 
-	// 默认使用标准方法
-	return getClientIPFromRequest(r)
+	// 默认使用连接的对端地址
+	return remoteIP(r)
 }
 
-// getClientIPFromRequest 从请求中获取客户端IP（标准方法）
-func getClientIPFromRequest(r *http.Request) string {
-	// 检查X-Forwarded-For
-	if xff := r.Header.Get("X-Forwarded-For"); xff != "" {
-		ips := strings.Split(xff, ",")
-		if len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
-		}
-	}
-
-	// 检查X-Real-IP
-	if xri := r.Header.Get("X-Real-IP"); xri != "" {
-		return xri
-	}
-
-	// 使用RemoteAddr
+// remoteIP 请求连接的对端IP（RemoteAddr）
+func remoteIP(r *http.Request) string {
 	host, _, err := net.SplitHostPort(r.RemoteAddr)
 	if err != nil {
 		return r.RemoteAddr
@@ -429,7 +552,8 @@ func withCORS(next http.Handler) http.Handler {
 		// 设置CORS响应头
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Admin-Token")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Admin-Token, X-CSRF-Token, If-None-Match, If-Modified-Since")
+		w.Header().Set("Access-Control-Expose-Headers", "ETag, Last-Modified, Retry-After")
 		w.Header().Set("Access-Control-Max-Age", "86400")
 
 		// 处理预检请求
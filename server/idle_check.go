@@ -0,0 +1,125 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"phira-mp/common"
+)
+
+// IdleCheckInterval 房主闲置检测间隔
+const IdleCheckInterval = time.Second
+
+// MarkActive 记录用户活动
+func (u *User) MarkActive() {
+	u.lastActive.Store(time.Now().UnixNano())
+}
+
+// IdleFor 用户已闲置的时长
+func (u *User) IdleFor() time.Duration {
+	last := u.lastActive.Load()
+	if last == 0 {
+		return 0
+	}
+	return time.Since(time.Unix(0, last))
+}
+
+// IsAFK 用户是否处于闲置状态（未启用闲置检测时始终为false）
+func (u *User) IsAFK() bool {
+	if u.server == nil {
+		return false
+	}
+	timeout := u.server.config.HostIdleTimeout
+	return timeout > 0 && u.IdleFor() >= time.Duration(timeout)*time.Second
+}
+
+// hostIdleFor 房主在选谱阶段的闲置时长
+// 从房主最后一次操作、成为房主、进入选谱阶段三者中最晚的时间起算
+func (r *Room) hostIdleFor() time.Duration {
+	idle := r.GetHost().IdleFor()
+	if since := time.Since(time.Unix(0, r.idleSince.Load())); since < idle {
+		idle = since
+	}
+	return idle
+}
+
+// resetHostIdle 重置房主闲置计时
+func (r *Room) resetHostIdle() {
+	r.idleSince.Store(time.Now().UnixNano())
+	r.idleWarned.Store(false)
+	r.idleHandled.Store(false)
+}
+
+// checkHostIdle 检查房主是否在选谱阶段闲置过久
+func (r *Room) checkHostIdle(warn, timeout time.Duration) {
+	if r.GetState() != InternalStateSelectChart || r.hasPlaceholderHost() {
+		return
+	}
+	host := r.GetHost()
+	idle := r.hostIdleFor()
+
+	if idle < warn && idle < timeout {
+		r.idleWarned.Store(false)
+		r.idleHandled.Store(false)
+		return
+	}
+
+	if warn > 0 && idle >= warn && idle < timeout && !r.idleWarned.Swap(true) {
+		host.Send(common.ServerCommand{
+			Type: common.ServerCmdMessage,
+			Message: &common.Message{
+				Type:    common.MsgChat,
+				User:    0,
+				Content: fmt.Sprintf("你已闲置 %d 秒，请尽快选择谱面", int(idle.Seconds())),
+			},
+		})
+		return
+	}
+
+	if idle < timeout || r.idleHandled.Swap(true) {
+		return
+	}
+
+	log.Printf("房间 `%s` 房主 %s(%d) 闲置 %v", r.ID.Value, host.Name, host.ID, idle.Round(time.Second))
+	BroadcastRoomLog(r.ID.Value, fmt.Sprintf("房主 %s(%d) 闲置超时", host.Name, host.ID))
+
+	// 循环模式下直接轮换房主，否则仅提醒房间内玩家
+	if r.IsCycle() && len(r.GetUsers()) > 1 {
+		r.SendMessage(common.Message{
+			Type:    common.MsgChat,
+			User:    0,
+			Content: fmt.Sprintf("房主 %s 闲置超时，已自动轮换房主", host.Name),
+		})
+		r.CycleHost()
+		return
+	}
+	r.SendMessage(common.Message{
+		Type:    common.MsgChat,
+		User:    0,
+		Content: fmt.Sprintf("房主 %s 已闲置 %d 秒", host.Name, int(idle.Seconds())),
+	})
+	BroadcastRoomUpdate(r)
+}
+
+// idleCheckLoop 定期检查所有房间的房主闲置情况
+func (s *Server) idleCheckLoop() {
+	timeout := time.Duration(s.config.HostIdleTimeout) * time.Second
+	warn := time.Duration(s.config.HostIdleWarn) * time.Second
+	if timeout <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(IdleCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			for _, room := range s.GetAllRooms() {
+				room.checkHostIdle(warn, timeout)
+			}
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package server
+
+import (
+	"sync"
+
+	"phira-mp/common"
+)
+
+// JudgeStats 根据判定流统计的玩家成绩
+type JudgeStats struct {
+	mu       sync.Mutex
+	perfect  int32
+	good     int32
+	bad      int32
+	miss     int32
+	combo    int32
+	maxCombo int32
+
+	early   int32 // 带提前标记的判定数（V24起的扩展判定）
+	late    int32 // 带延后标记的判定数
+	unknown int32 // 无法分类的判定数（客户端使用了服务器不认识的判定类型），不计入成绩也不影响连击
+}
+
+// Add 累加判定事件，扩展判定按所属的成绩分类统计
+func (s *JudgeStats) Add(judges []common.JudgeEvent) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, j := range judges {
+		switch j.Judgement.Class() {
+		case common.JudgeClassPerfect:
+			s.perfect++
+			s.combo++
+		case common.JudgeClassGood:
+			s.good++
+			s.combo++
+		case common.JudgeClassBad:
+			s.bad++
+			s.combo = 0
+		case common.JudgeClassMiss:
+			s.miss++
+			s.combo = 0
+		default:
+			s.unknown++
+			continue
+		}
+		if j.Judgement.Early() {
+			s.early++
+		}
+		if j.Judgement.Late() {
+			s.late++
+		}
+		if s.combo > s.maxCombo {
+			s.maxCombo = s.combo
+		}
+	}
+}
+
+// Snapshot 获取当前统计
+func (s *JudgeStats) Snapshot() (perfect, good, bad, miss, maxCombo int32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.perfect, s.good, s.bad, s.miss, s.maxCombo
+}
+
+// Timing 带提前、延后标记的判定数，以及无法分类而被忽略的判定数
+func (s *JudgeStats) Timing() (early, late, unknown int32) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.early, s.late, s.unknown
+}
+
+// RecordJudges 记录玩家判定（仅在游戏中统计）
+func (r *Room) RecordJudges(userID int32, judges []common.JudgeEvent) {
+	if r.GetState() != InternalStatePlaying {
+		return
+	}
+	stats, _ := r.judgeStats.LoadOrStore(userID, &JudgeStats{})
+	stats.(*JudgeStats).Add(judges)
+}
+
+// GetJudgeStats 获取玩家本局的判定统计
+func (r *Room) GetJudgeStats(userID int32) *JudgeStats {
+	if stats, ok := r.judgeStats.Load(userID); ok {
+		return stats.(*JudgeStats)
+	}
+	return nil
+}
+
+// playedMessage 构建成绩消息，判定统计优先取自成绩记录，记录中缺失时使用判定流统计
+func (r *Room) playedMessage(userID int32, record *Record) common.Message {
+	msg := common.Message{
+		Type:      common.MsgPlayed,
+		User:      userID,
+		Score:     record.Score,
+		Accuracy:  record.Accuracy,
+		FullCombo: record.FullCombo,
+		Perfect:   record.Perfect,
+		Good:      record.Good,
+		Bad:       record.Bad,
+		Miss:      record.Miss,
+		MaxCombo:  record.MaxCombo,
+	}
+	if record.Perfect+record.Good+record.Bad+record.Miss == 0 {
+		if stats := r.GetJudgeStats(userID); stats != nil {
+			msg.Perfect, msg.Good, msg.Bad, msg.Miss, msg.MaxCombo = stats.Snapshot()
+		}
+	}
+	return msg
+}
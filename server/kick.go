@@ -0,0 +1,103 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"phira-mp/common"
+)
+
+// kickDrainTimeout 断开连接前等待移出通知写出的最长时间
+const kickDrainTimeout = time.Second
+
+// kickedCommand 移出通知，room 为nil表示不在房间中
+func kickedCommand(room *Room, reason common.KickReason, message string) common.ServerCommand {
+	kicked := &common.Kicked{Reason: reason, Message: message}
+	if room != nil {
+		id := room.ID
+		kicked.RoomId = &id
+	}
+	return common.ServerCommand{Type: common.ServerCmdKicked, Kicked: kicked}
+}
+
+// notifyKicked 通知用户被移出（V23起的客户端收到 Kicked，更早的客户端收不到该通知）
+// disconnect 为true时随后发送断开连接通知（V27起），等待通知写出后断开连接
+func (u *User) notifyKicked(room *Room, reason common.KickReason, message string, disconnect bool) {
+	session := u.GetSession()
+	if session == nil {
+		return
+	}
+	session.Send(kickedCommand(room, reason, message))
+	if disconnect {
+		cause := common.DisconnectReasonKicked
+		if reason == common.KickReasonBanned {
+			cause = common.DisconnectReasonBanned
+		}
+		session.Disconnect(cause, message)
+	}
+}
+
+// KickFromRoom 将用户移出房间并通知其原因，游戏中的玩家视为放弃；用户不在该房间中时返回false
+func (s *Server) KickFromRoom(room *Room, user *User, reason common.KickReason, message string) bool {
+	if user.GetRoom() != room {
+		return false
+	}
+	if room.GetState() == InternalStatePlaying && !user.IsMonitor() {
+		room.aborted.Store(user.ID, true)
+		room.SendMessage(common.Message{
+			Type: common.MsgAbort,
+			User: user.ID,
+		})
+	}
+	if room.OnUserLeave(user) {
+		s.RemoveRoom(room.ID, "房间为空")
+	} else {
+		room.CheckAllReady()
+	}
+	user.notifyKicked(room, reason, message, false)
+	return true
+}
+
+// handleKick 处理房主将玩家或观察者移出房间，只能在选择谱面时移出
+func (s *Session) handleKick(userID int32) error {
+	fail := func(msg string) error {
+		return s.Send(common.ServerCommand{
+			Type:       common.ServerCmdKick,
+			KickResult: &common.Result[struct{}]{Err: strPtr(msg)},
+		})
+	}
+
+	room := s.User.GetRoom()
+	if room == nil {
+		return fail("不在房间中")
+	}
+	if room.GetHost().ID != s.User.ID {
+		return fail("只有房主可以移出玩家")
+	}
+	if userID == s.User.ID {
+		return fail("不能移出自己")
+	}
+	if room.GetState() != InternalStateSelectChart {
+		return fail("无效状态")
+	}
+
+	var target *User
+	for _, u := range room.GetAllUsers() {
+		if u.ID == userID {
+			target = u
+			break
+		}
+	}
+	if target == nil || !s.server.KickFromRoom(room, target, common.KickReasonHost, "") {
+		return fail("目标玩家不在房间中")
+	}
+
+	log.Printf("房间 `%s` 房主 %s(%d) 移出了 %s(%d)", room.ID.Value, s.User.Name, s.User.ID, target.Name, target.ID)
+	BroadcastRoomLog(room.ID.Value, fmt.Sprintf("房主移出了玩家 %s(%d)", target.Name, target.ID))
+
+	return s.Send(common.ServerCommand{
+		Type:       common.ServerCmdKick,
+		KickResult: &common.Result[struct{}]{Ok: &struct{}{}},
+	})
+}
@@ -0,0 +1,54 @@
+package server
+
+import (
+	"time"
+
+	"phira-mp/common"
+)
+
+// handlePing 回复心跳：V18起回显客户端的发送时间并附带服务器时间，同时根据客户端回显的上一次 Pong 时间测量往返延迟
+func (s *Session) handlePing(ping common.PingTimes) error {
+	now := time.Now()
+	if s.User != nil {
+		if rtt, ok := pingRTT(ping, now); ok {
+			s.User.recordRTT(rtt)
+		}
+	}
+	return s.Send(common.ServerCommand{
+		Type: common.ServerCmdPong,
+		Pong: common.PingTimes{Time: uint64(now.UnixMilli()), Echo: ping.Time},
+	})
+}
+
+// pingRTT 由 Ping 中回显的服务器时间与客户端停留时长计算往返延迟，数据无效时返回false
+func pingRTT(ping common.PingTimes, now time.Time) (time.Duration, bool) {
+	nowMs := uint64(now.UnixMilli())
+	if ping.Echo == 0 || ping.Echo > nowMs || ping.Hold > nowMs-ping.Echo {
+		return 0, false
+	}
+	rtt := time.Duration(nowMs-ping.Echo-ping.Hold) * time.Millisecond
+	return rtt, rtt <= common.HeartbeatDisconnectTimeout
+}
+
+// recordRTT 记录一次往返延迟并更新平滑值
+func (u *User) recordRTT(rtt time.Duration) {
+	srtt := time.Duration(u.srtt.Load())
+	if srtt == 0 {
+		srtt = rtt
+	} else {
+		// 平滑往返延迟（同TCP的SRTT，权重1/8）
+		srtt += (rtt - srtt) / 8
+	}
+	// 时间戳精度为毫秒，不足1毫秒的延迟记为1毫秒（0表示尚未测得）
+	u.srtt.Store(int64(max(srtt, time.Millisecond)))
+}
+
+// Latency 用户连接的平滑往返延迟（客户端低于 V18 或尚未测得时为0）
+func (u *User) Latency() time.Duration {
+	return time.Duration(u.srtt.Load())
+}
+
+// latencyMs 往返延迟的毫秒数（用于管理接口与WebSocket）
+func (u *User) latencyMs() float64 {
+	return float64(u.Latency().Microseconds()) / 1000
+}
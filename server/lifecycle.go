@@ -0,0 +1,254 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"time"
+
+	"phira-mp/common"
+)
+
+// DefaultShutdownTimeout 单个组件停止的默认超时秒数
+const DefaultShutdownTimeout = 10
+
+// lifecycleDrainInterval 停止时等待会话断开与查询任务完成的检查间隔
+const lifecycleDrainInterval = 20 * time.Millisecond
+
+// errServerStopped 服务器已停止后不能再启动组件
+var errServerStopped = errors.New("服务器已停止")
+
+// lifecycleComponent 服务器组件：start 与 stop 均可为nil
+// stop 应在 ctx 结束前返回，超时后不再等待并继续停止后续组件
+type lifecycleComponent struct {
+	name  string
+	start func() error
+	stop  func(ctx context.Context) error
+}
+
+// Lifecycle 按依赖顺序启动与停止服务器组件
+// 组件按注册顺序启动、按相反顺序停止：被依赖的组件（存储、HTTP服务）先注册，
+// 停止时先关闭对外入口（监听、会话），再停止依赖它们的组件，避免写入已关闭的录制文件或向已关闭的WebSocket广播
+type Lifecycle struct {
+	mu         sync.Mutex
+	components []lifecycleComponent
+	stopped    bool
+	timeout    time.Duration // 每个组件停止的超时时间
+	stopOnce   sync.Once
+}
+
+// NewLifecycle 创建生命周期管理器，timeout 为每个组件停止的超时时间
+func NewLifecycle(timeout time.Duration) *Lifecycle {
+	return &Lifecycle{timeout: timeout}
+}
+
+// Add 注册组件（在 Start 之前调用）
+func (l *Lifecycle) Add(name string, start func() error, stop func(ctx context.Context) error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.components = append(l.components, lifecycleComponent{name: name, start: start, stop: stop})
+}
+
+// Start 按注册顺序启动组件，任一组件失败时返回错误（由调用方调用 Stop 停止已启动的组件）
+func (l *Lifecycle) Start() error {
+	l.mu.Lock()
+	components := l.components
+	l.mu.Unlock()
+
+	for _, c := range components {
+		if c.start == nil {
+			continue
+		}
+		// 启动过程中收到停止请求时不再启动后续组件
+		l.mu.Lock()
+		if l.stopped {
+			l.mu.Unlock()
+			return errServerStopped
+		}
+		err := c.start()
+		l.mu.Unlock()
+		if err != nil {
+			return fmt.Errorf("%s: %w", c.name, err)
+		}
+	}
+	return nil
+}
+
+// Stop 按注册的相反顺序停止所有组件（只执行一次），每个组件最多等待 timeout
+func (l *Lifecycle) Stop() {
+	l.stopOnce.Do(func() {
+		l.mu.Lock()
+		l.stopped = true
+		components := l.components
+		l.mu.Unlock()
+
+		begin := time.Now()
+		for i := len(components) - 1; i >= 0; i-- {
+			if components[i].stop != nil {
+				l.stopComponent(components[i])
+			}
+		}
+		log.Printf("[关闭] 所有组件已停止，耗时 %v", time.Since(begin).Round(time.Millisecond))
+	})
+}
+
+// stopComponent 停止单个组件，超时后记录日志并继续
+func (l *Lifecycle) stopComponent(c lifecycleComponent) {
+	ctx, cancel := context.WithTimeout(context.Background(), l.timeout)
+	defer cancel()
+
+	begin := time.Now()
+	done := make(chan error, 1)
+	go func() { done <- c.stop(ctx) }()
+
+	select {
+	case err := <-done:
+		elapsed := time.Since(begin).Round(time.Millisecond)
+		if err != nil {
+			log.Printf("[关闭] %s 停止出错（%v）: %v", c.name, elapsed, err)
+		} else {
+			log.Printf("[关闭] %s 已停止（%v）", c.name, elapsed)
+		}
+	case <-ctx.Done():
+		log.Printf("[关闭] %s 停止超时（%v），继续停止后续组件", c.name, l.timeout)
+	}
+}
+
+// shutdownTimeout 每个组件停止的超时时间
+func (s *Server) shutdownTimeout() time.Duration {
+	seconds := s.config.ShutdownTimeout
+	if seconds <= 0 {
+		seconds = DefaultShutdownTimeout
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// newLifecycle 注册服务器组件，启动顺序：存储 → HTTP/WebSocket → 回放录制 → 房间 → 会话 → 监听
+func (s *Server) newLifecycle() *Lifecycle {
+	l := NewLifecycle(s.shutdownTimeout())
+	l.Add("storage", s.startStorage, s.stopStorage)
+	l.Add("http", s.startHTTP, s.stopHTTP)
+	l.Add("recorder", nil, s.stopRecorder)
+	l.Add("rooms", s.startRooms, s.stopRooms)
+	l.Add("sessions", nil, s.stopSessions)
+	l.Add("listener", s.startListener, s.stopListener)
+	return l
+}
+
+// startStorage 启动统计采样与定期保存（数据已在 NewServer 中加载）
+func (s *Server) startStorage() error {
+	go s.activityLoop()
+	go s.recordLedgerLoop()
+	go s.gameHistoryLoop()
+	go s.retentionLoop()
+	return nil
+}
+
+// stopStorage 写入活动统计、已使用成绩与对局历史
+func (s *Server) stopStorage(ctx context.Context) error {
+	var errs []error
+	if s.activityStats != nil {
+		if err := s.activityStats.Save(); err != nil {
+			errs = append(errs, fmt.Errorf("活动统计: %w", err))
+		}
+	}
+	if s.recordLedger != nil {
+		if err := s.recordLedger.Save(); err != nil {
+			errs = append(errs, fmt.Errorf("已使用成绩: %w", err))
+		}
+	}
+	if s.gameHistory != nil {
+		if err := s.gameHistory.Save(); err != nil {
+			errs = append(errs, fmt.Errorf("对局历史: %w", err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (s *Server) startHTTP() error {
+	if err := s.httpServer.Start(); err != nil {
+		return fmt.Errorf("启动HTTP服务失败: %w", err)
+	}
+	return nil
+}
+
+// stopHTTP 关闭HTTP服务与WebSocket连接，写入管理员数据
+func (s *Server) stopHTTP(ctx context.Context) error {
+	if s.httpServer == nil {
+		return nil
+	}
+	return s.httpServer.Shutdown(ctx)
+}
+
+// stopRecorder 结束所有录制并完成索引与上传
+func (s *Server) stopRecorder(ctx context.Context) error {
+	if s.replayRecorder != nil {
+		s.replayRecorder.StopAllRecordings()
+	}
+	return nil
+}
+
+// startRooms 创建官方房间并启动房主闲置检测、关键命令确认、房间关闭时间检查与过载降级
+func (s *Server) startRooms() error {
+	s.EnsureOfficialRooms()
+	go s.idleCheckLoop()
+	go s.desyncCheckLoop()
+	go s.roomScheduleLoop()
+	go s.loadShedLoop()
+	return nil
+}
+
+// stopRooms 停止房间的加载计时，并等待进行中的谱面与成绩查询完成（查询结果会写入回放与对局历史）
+func (s *Server) stopRooms(ctx context.Context) error {
+	s.rooms.Range(func(_, value interface{}) bool {
+		value.(*Room).stopLoadTimer()
+		return true
+	})
+	return s.fetchPool.Wait(ctx)
+}
+
+// stopSessions 关闭所有会话，等待会话完成断线处理
+func (s *Server) stopSessions(ctx context.Context) error {
+	s.sessions.Range(func(_, value interface{}) bool {
+		if session, ok := value.(*Session); ok {
+			go session.Disconnect(common.DisconnectReasonServerShutdown, "服务器正在关闭")
+		}
+		return true
+	})
+
+	ticker := time.NewTicker(lifecycleDrainInterval)
+	defer ticker.Stop()
+	for {
+		if s.sessionCount() == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("仍有 %d 个会话未断开", s.sessionCount())
+		case <-ticker.C:
+		}
+	}
+}
+
+// startListener 监听游戏端口（平滑升级时使用旧进程移交的监听套接字）
+func (s *Server) startListener() error {
+	listener, err := listen(handoverGame, s.address)
+	if err != nil {
+		return err
+	}
+	s.listener = listener
+	return nil
+}
+
+// stopListener 停止接受新连接（平滑升级时监听已在 Drain 中关闭）
+func (s *Server) stopListener(ctx context.Context) error {
+	if s.listener != nil {
+		if err := s.listener.Close(); err != nil && !errors.Is(err, net.ErrClosed) {
+			return err
+		}
+	}
+	return nil
+}
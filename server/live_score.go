@@ -0,0 +1,60 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"phira-mp/common"
+)
+
+// scoreUpdateInterval 同一玩家转发实时成绩的最小间隔，更频繁的更新直接丢弃
+const scoreUpdateInterval = 100 * time.Millisecond
+
+// handleScoreUpdate 处理对局中的实时成绩，转发给观察者与WebSocket订阅者
+// 实时成绩仅用于直播展示，不参与结算；不在对局中的更新直接丢弃
+func (s *Session) handleScoreUpdate(score common.LiveScore) error {
+	room := s.User.GetRoom()
+	if room == nil || s.User.IsMonitor() || room.GetState() != InternalStatePlaying {
+		return nil
+	}
+	if score.Score < 0 || score.Combo < 0 || !(score.Accuracy >= 0 && score.Accuracy <= 1) {
+		return fmt.Errorf("无效的实时成绩: %+v", score)
+	}
+
+	now := time.Now().UnixNano()
+	last := s.User.lastScoreUpdate.Load()
+	if now-last < int64(scoreUpdateInterval) || !s.User.lastScoreUpdate.CompareAndSwap(last, now) {
+		return nil
+	}
+
+	room.forwardScore(s.User.ID, score)
+	return nil
+}
+
+// BroadcastRoomScore 向房间的WebSocket订阅者推送玩家的实时成绩
+func BroadcastRoomScore(roomID string, player int32, score common.LiveScore) {
+	msg := WebSocketMessage{
+		Type: "score_update",
+		Data: map[string]interface{}{
+			"player":    player,
+			"score":     score.Score,
+			"combo":     score.Combo,
+			"accuracy":  score.Accuracy,
+			"timestamp": time.Now().UnixMilli(),
+		},
+	}
+
+	msgBytes, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("序列化实时成绩失败: %v", err)
+		return
+	}
+
+	hub.broadcast <- &BroadcastMessage{
+		roomID:  roomID,
+		message: msgBytes,
+		isAdmin: false,
+	}
+}
@@ -0,0 +1,307 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"runtime/metrics"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"phira-mp/common"
+)
+
+// LoadShedLevel 过载降级等级，每一级包含更低等级的全部措施
+type LoadShedLevel int32
+
+const (
+	LoadShedNone      LoadShedLevel = iota // 正常
+	LoadShedMonitors                       // 暂停接受新的观察者
+	LoadShedCoalesce                       // 加大触摸数据合并：转发给观察者的触摸帧按 loadShedTouchInterval 合并发送
+	LoadShedSnapshots                      // 推迟管理员全量快照推送，每 loadShedSnapshotDelay 最多推送一次
+	LoadShedRooms                          // 拒绝创建新房间
+)
+
+var loadShedNames = [...]string{
+	LoadShedNone:      "none",
+	LoadShedMonitors:  "monitors",
+	LoadShedCoalesce:  "coalesce",
+	LoadShedSnapshots: "snapshots",
+	LoadShedRooms:     "rooms",
+}
+
+func (l LoadShedLevel) String() string {
+	if l >= 0 && int(l) < len(loadShedNames) {
+		return loadShedNames[l]
+	}
+	return fmt.Sprintf("LoadShedLevel(%d)", int32(l))
+}
+
+const (
+	// DefaultLoadShedInterval 负载采样间隔（秒）
+	DefaultLoadShedInterval = 5
+	// loadShedRecover 负载降到阈值的该比例以下才降低一级，避免在阈值附近反复升降
+	loadShedRecover = 0.8
+	// loadShedTouchInterval LoadShedCoalesce 起转发给观察者的触摸帧合并间隔
+	loadShedTouchInterval = 250 * time.Millisecond
+	// loadShedSnapshotDelay LoadShedSnapshots 起管理员全量快照的最短推送间隔
+	loadShedSnapshotDelay = 5 * time.Second
+)
+
+// 降级时拒绝请求的错误信息
+const (
+	ErrLoadShedMonitor    = "服务器负载过高，暂不接受新的观察者"
+	ErrLoadShedCreateRoom = "服务器负载过高，暂时无法创建房间"
+)
+
+// LoadSample 一次负载采样
+type LoadSample struct {
+	CPU       float64 // Go运行时的CPU使用率（0-1，按 GOMAXPROCS 计）
+	Bandwidth int64   // 游戏连接的出站带宽（字节/秒）
+}
+
+// LoadShedder 过载降级：负载超过阈值时每次采样升高一级，降到阈值的 loadShedRecover 以下时每次降低一级
+type LoadShedder struct {
+	cpuThreshold       float64 // 0表示不按CPU降级
+	bandwidthThreshold int64   // 0表示不按带宽降级
+
+	level atomic.Int32 // LoadShedLevel
+	last  atomic.Value // LoadSample - 最近一次采样
+}
+
+// NewLoadShedder 创建过载降级控制，两个阈值均为0时始终不降级
+func NewLoadShedder(cpu float64, bandwidth int64) *LoadShedder {
+	l := &LoadShedder{cpuThreshold: cpu, bandwidthThreshold: bandwidth}
+	l.last.Store(LoadSample{})
+	return l
+}
+
+// Enabled 是否配置了降级阈值
+func (l *LoadShedder) Enabled() bool {
+	return l.cpuThreshold > 0 || l.bandwidthThreshold > 0
+}
+
+// Level 当前降级等级
+func (l *LoadShedder) Level() LoadShedLevel {
+	return LoadShedLevel(l.level.Load())
+}
+
+// LastSample 最近一次负载采样
+func (l *LoadShedder) LastSample() LoadSample {
+	return l.last.Load().(LoadSample)
+}
+
+// pressure 负载与阈值之比的最大值
+func (l *LoadShedder) pressure(sample LoadSample) float64 {
+	var p float64
+	if l.cpuThreshold > 0 {
+		p = sample.CPU / l.cpuThreshold
+	}
+	if l.bandwidthThreshold > 0 {
+		p = max(p, float64(sample.Bandwidth)/float64(l.bandwidthThreshold))
+	}
+	return p
+}
+
+// Observe 根据一次负载采样调整降级等级，返回调整前后的等级
+func (l *LoadShedder) Observe(sample LoadSample) (from, to LoadShedLevel) {
+	l.last.Store(sample)
+	from = l.Level()
+	to = from
+	if !l.Enabled() {
+		return from, to
+	}
+	switch p := l.pressure(sample); {
+	case p >= 1 && from < LoadShedRooms:
+		to = from + 1
+	case p < loadShedRecover && from > LoadShedNone:
+		to = from - 1
+	}
+	l.level.Store(int32(to))
+	return from, to
+}
+
+// loadMeter 读取两次采样之间的CPU使用率与出站带宽
+type loadMeter struct {
+	samples  []metrics.Sample
+	lastAt   time.Time
+	lastSent int64
+	lastIdle float64
+	lastCPU  float64
+}
+
+func newLoadMeter() *loadMeter {
+	return &loadMeter{samples: []metrics.Sample{
+		{Name: "/cpu/classes/idle:cpu-seconds"},
+		{Name: "/cpu/classes/total:cpu-seconds"},
+	}}
+}
+
+// sample 与上一次采样比较得到负载（首次采样只记录基准，返回false）
+func (m *loadMeter) sample(sent int64, now time.Time) (LoadSample, bool) {
+	metrics.Read(m.samples)
+	var idle, total float64
+	if m.samples[0].Value.Kind() == metrics.KindFloat64 && m.samples[1].Value.Kind() == metrics.KindFloat64 {
+		idle, total = m.samples[0].Value.Float64(), m.samples[1].Value.Float64()
+	}
+
+	var sample LoadSample
+	ok := !m.lastAt.IsZero()
+	if ok {
+		if dt := total - m.lastCPU; dt > 0 {
+			sample.CPU = min(max(1-(idle-m.lastIdle)/dt, 0), 1)
+		}
+		if elapsed := now.Sub(m.lastAt).Seconds(); elapsed > 0 {
+			sample.Bandwidth = int64(float64(sent-m.lastSent) / elapsed)
+		}
+	}
+	m.lastAt, m.lastSent, m.lastIdle, m.lastCPU = now, sent, idle, total
+	return sample, ok
+}
+
+// loadShedLoop 定期采样负载并调整降级等级（未配置阈值时不运行）
+func (s *Server) loadShedLoop() {
+	if !s.loadShed.Enabled() {
+		return
+	}
+	interval := time.Duration(s.config.LoadShedInterval) * time.Second
+	if interval <= 0 {
+		interval = DefaultLoadShedInterval * time.Second
+	}
+
+	meter := newLoadMeter()
+	meter.sample(s.bytesSent.Load(), time.Now())
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case now := <-ticker.C:
+			if sample, ok := meter.sample(s.bytesSent.Load(), now); ok {
+				s.ObserveLoad(sample)
+			}
+		}
+	}
+}
+
+// ObserveLoad 根据负载采样调整降级等级，等级变化时记录日志
+func (s *Server) ObserveLoad(sample LoadSample) LoadShedLevel {
+	from, to := s.loadShed.Observe(sample)
+	if from != to {
+		log.Printf("[过载降级] 等级 %s -> %s（CPU %.0f%%，出站带宽 %d B/s）", from, to, sample.CPU*100, sample.Bandwidth)
+		if from >= LoadShedCoalesce && to < LoadShedCoalesce {
+			s.flushCoalescedTouches()
+		}
+	}
+	return to
+}
+
+// LoadShedLevel 当前过载降级等级
+func (s *Server) LoadShedLevel() LoadShedLevel {
+	return s.loadShed.Level()
+}
+
+// shedding 当前是否已达到指定的降级等级
+func (s *Server) shedding(level LoadShedLevel) bool {
+	return s.loadShed.Level() >= level
+}
+
+// countingConn 统计写出的字节数（游戏连接的出站带宽）
+type countingConn struct {
+	net.Conn
+	sent *atomic.Int64
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.sent.Add(int64(n))
+	return n, err
+}
+
+// NetConn 返回被包装的连接
+func (c *countingConn) NetConn() net.Conn {
+	return c.Conn
+}
+
+// touchCoalescer 降级时合并转发给观察者的触摸帧，每个玩家每 loadShedTouchInterval 最多发送一次
+type touchCoalescer struct {
+	mu      sync.Mutex
+	pending map[int32][]common.TouchFrame
+	timer   *time.Timer
+}
+
+// add 缓存触摸帧，首次缓存时安排发送
+func (c *touchCoalescer) add(r *Room, player int32, frames []common.TouchFrame) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pending == nil {
+		c.pending = make(map[int32][]common.TouchFrame)
+	}
+	c.pending[player] = append(c.pending[player], frames...)
+	if c.timer == nil {
+		c.timer = time.AfterFunc(loadShedTouchInterval, func() { c.flush(r) })
+	}
+}
+
+// flush 将缓存的触摸帧按玩家各合并为一条命令发送给观察者
+func (c *touchCoalescer) flush(r *Room) {
+	c.mu.Lock()
+	pending := c.pending
+	c.pending = nil
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	c.mu.Unlock()
+
+	for player, frames := range pending {
+		r.BroadcastMonitors(common.ServerCommand{
+			Type:          common.ServerCmdTouches,
+			TouchesPlayer: player,
+			TouchesFrames: frames,
+		})
+	}
+}
+
+// flushCoalescedTouches 降级解除后立即发送所有房间缓存的触摸帧
+func (s *Server) flushCoalescedTouches() {
+	for _, room := range s.GetAllRooms() {
+		room.touchBatch.flush(room)
+	}
+}
+
+// writeLoadShedMetrics 以Prometheus文本格式输出过载降级等级与最近一次负载采样
+func (h *HTTPServer) writeLoadShedMetrics(w io.Writer) {
+	sample := h.server.loadShed.LastSample()
+	fmt.Fprintln(w, "# HELP phira_load_shed_level 过载降级等级（0正常，1暂停接受观察者，2合并触摸数据，3推迟管理员快照，4拒绝创建房间）")
+	fmt.Fprintln(w, "# TYPE phira_load_shed_level gauge")
+	fmt.Fprintf(w, "phira_load_shed_level %d\n", h.server.loadShed.Level())
+	fmt.Fprintln(w, "# HELP phira_load_cpu_ratio 最近一次采样的Go运行时CPU使用率（0-1）")
+	fmt.Fprintln(w, "# TYPE phira_load_cpu_ratio gauge")
+	fmt.Fprintf(w, "phira_load_cpu_ratio %g\n", sample.CPU)
+	fmt.Fprintln(w, "# HELP phira_outbound_bytes_total 游戏连接写出的字节数")
+	fmt.Fprintln(w, "# TYPE phira_outbound_bytes_total counter")
+	fmt.Fprintf(w, "phira_outbound_bytes_total %d\n", h.server.bytesSent.Load())
+}
+
+// handleHealthz 健康检查：服务正常时返回200，过载降级时 status 为 degraded 并附带降级等级
+func (h *HTTPServer) handleHealthz(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		writeError(w, http.StatusMethodNotAllowed, "method-not-allowed")
+		return
+	}
+	level := h.server.loadShed.Level()
+	status := "ok"
+	if level > LoadShedNone {
+		status = "degraded"
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"status":    status,
+		"shedLevel": int(level),
+		"shed":      level.String(),
+	})
+}
@@ -0,0 +1,23 @@
+package server
+
+import (
+	"net/http"
+)
+
+// handleMetrics 以Prometheus文本格式输出运行指标（需要管理员认证，抓取时使用 Bearer token）
+func (h *HTTPServer) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method-not-allowed")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	WriteUpstreamMetrics(w)
+	h.writeRateLimitMetrics(w)
+	h.writePeakMetrics(w)
+	h.server.commandLatency.WriteMetrics(w)
+	h.writeDesyncMetrics(w)
+	h.writeLoadShedMetrics(w)
+	h.writeAuthTimeoutMetrics(w)
+	h.writeDisconnectMetrics(w)
+}
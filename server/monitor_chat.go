@@ -0,0 +1,57 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"phira-mp/common"
+)
+
+// handleMonitorChat 处理观察者聊天：消息只投递给同一房间的观察者，对局中的玩家不会收到
+func (s *Session) handleMonitorChat(message string) error {
+	fail := func(msg string) error {
+		return s.Send(common.ServerCommand{
+			Type:              common.ServerCmdMonitorChat,
+			MonitorChatResult: &common.Result[struct{}]{Err: strPtr(msg)},
+		})
+	}
+
+	if !s.server.config.MonitorChat {
+		return fail("该服务器未开启观察者聊天")
+	}
+	if s.server.IsUserBanned(s.User.ID) {
+		return fail("用户已被封禁")
+	}
+
+	room := s.User.GetRoom()
+	if room == nil {
+		return fail("不在房间中")
+	}
+	if !s.User.IsMonitor() {
+		return fail("只有观察者可以使用观察者聊天")
+	}
+	if message == "" {
+		return fail("消息为空")
+	}
+
+	interval := time.Duration(s.server.config.MonitorChatInterval) * time.Second
+	if ok, wait := s.server.monitorChat.Allow(s.User.ID, interval); !ok {
+		return fail(fmt.Sprintf("发言过于频繁，请 %d 秒后再试", int(wait.Seconds())+1))
+	}
+
+	room.BroadcastMonitors(common.ServerCommand{
+		Type: common.ServerCmdMessage,
+		Message: &common.Message{
+			Type:    common.MsgMonitorChat,
+			User:    s.User.ID,
+			Content: message,
+		},
+	})
+	log.Printf("[观察者聊天] 房间 `%s` %s(%d): %s", room.ID.Value, s.User.Name, s.User.ID, message)
+
+	return s.Send(common.ServerCommand{
+		Type:              common.ServerCmdMonitorChat,
+		MonitorChatResult: &common.Result[struct{}]{Ok: &struct{}{}},
+	})
+}
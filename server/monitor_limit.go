@@ -0,0 +1,83 @@
+package server
+
+import (
+	"fmt"
+	"log"
+
+	"phira-mp/common"
+)
+
+// ErrMonitorLimit 观察者已达上限时返回给客户端的错误
+const ErrMonitorLimit = "房间观察者已达上限"
+
+// GetMaxMonitors 获取房间观察者上限（0表示不限制）
+// 房主设置过上限时使用房主的设置，否则使用服务器默认值
+func (r *Room) GetMaxMonitors() int {
+	if max := r.maxMonitors.Load(); max > 0 {
+		return int(max)
+	}
+	if r.server == nil {
+		return 0
+	}
+	return r.server.config.MaxMonitors
+}
+
+// SetMaxMonitors 设置房间观察者上限（0表示恢复服务器默认值）
+func (r *Room) SetMaxMonitors(maxMonitors int) {
+	r.maxMonitors.Store(int32(maxMonitors))
+}
+
+// monitorsFull 观察者是否已满（调用方需持有monitors锁）
+func (r *Room) monitorsFull() bool {
+	max := r.GetMaxMonitors()
+	return max > 0 && len(r.monitorList) >= max
+}
+
+// IsMonitorsFull 观察者是否已满
+func (r *Room) IsMonitorsFull() bool {
+	r.monitors.RLock()
+	defer r.monitors.RUnlock()
+	return r.monitorsFull()
+}
+
+// handleSetMaxMonitors 处理房主设置观察者上限
+func (s *Session) handleSetMaxMonitors(maxMonitors int) error {
+	room := s.User.GetRoom()
+	if room == nil {
+		return s.Send(common.ServerCommand{
+			Type:                 common.ServerCmdSetMaxMonitors,
+			SetMaxMonitorsResult: &common.Result[struct{}]{Err: strPtr("不在房间中")},
+		})
+	}
+
+	if err := room.CheckHost(s.User); err != nil {
+		return s.Send(common.ServerCommand{
+			Type:                 common.ServerCmdSetMaxMonitors,
+			SetMaxMonitorsResult: &common.Result[struct{}]{Err: strPtr("只有房主可以设置观察者上限")},
+		})
+	}
+
+	limit := s.server.config.MaxMonitorsLimit
+	if limit <= 0 {
+		return s.Send(common.ServerCommand{
+			Type:                 common.ServerCmdSetMaxMonitors,
+			SetMaxMonitorsResult: &common.Result[struct{}]{Err: strPtr("服务器不允许修改观察者上限")},
+		})
+	}
+	if maxMonitors > limit {
+		return s.Send(common.ServerCommand{
+			Type:                 common.ServerCmdSetMaxMonitors,
+			SetMaxMonitorsResult: &common.Result[struct{}]{Err: strPtr(fmt.Sprintf("观察者上限不能超过 %d", limit))},
+		})
+	}
+
+	room.SetMaxMonitors(maxMonitors)
+	log.Printf("房间 `%s` 观察者上限设置为 %d", room.ID.Value, room.GetMaxMonitors())
+	BroadcastRoomLog(room.ID.Value, fmt.Sprintf("观察者上限设置为 %d", room.GetMaxMonitors()))
+	BroadcastRoomUpdate(room)
+
+	return s.Send(common.ServerCommand{
+		Type:                 common.ServerCmdSetMaxMonitors,
+		SetMaxMonitorsResult: &common.Result[struct{}]{Ok: &struct{}{}},
+	})
+}
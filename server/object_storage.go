@@ -0,0 +1,249 @@
+package server
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ReplayStorageConfig 回放对象存储配置（S3兼容）
+type ReplayStorageConfig struct {
+	Endpoint  string `yaml:"endpoint"`   // 服务地址，如 https://s3.amazonaws.com
+	Region    string `yaml:"region"`     // 区域（默认 us-east-1）
+	Bucket    string `yaml:"bucket"`     // 存储桶
+	Prefix    string `yaml:"prefix"`     // 对象键前缀，如 replays/
+	AccessKey string `yaml:"access_key"` // 访问密钥ID
+	SecretKey string `yaml:"secret_key"` // 访问密钥
+	PathStyle bool   `yaml:"path_style"` // 使用路径风格地址（MinIO等需要开启）
+	URLExpire int    `yaml:"url_expire"` // 下载签名链接有效秒数（0则使用默认600秒）
+}
+
+// DefaultReplayURLExpire 下载签名链接默认有效期
+const DefaultReplayURLExpire = 10 * time.Minute
+
+// Enabled 是否配置了对象存储
+func (c ReplayStorageConfig) Enabled() bool {
+	return c.Endpoint != "" && c.Bucket != ""
+}
+
+// ObjectStorage S3兼容对象存储客户端（AWS Signature V4）
+type ObjectStorage struct {
+	config ReplayStorageConfig
+	base   *url.URL
+	client *http.Client
+}
+
+// NewObjectStorage 创建对象存储客户端
+func NewObjectStorage(config ReplayStorageConfig) (*ObjectStorage, error) {
+	base, err := url.Parse(strings.TrimRight(config.Endpoint, "/"))
+	if err != nil || base.Host == "" {
+		return nil, fmt.Errorf("无效的对象存储地址: %s", config.Endpoint)
+	}
+	if config.Region == "" {
+		config.Region = "us-east-1"
+	}
+	return &ObjectStorage{
+		config: config,
+		base:   base,
+		client: &http.Client{Timeout: 60 * time.Second},
+	}, nil
+}
+
+// ObjectKey 根据相对路径生成对象键
+func (s *ObjectStorage) ObjectKey(name string) string {
+	return s.config.Prefix + strings.TrimLeft(name, "/")
+}
+
+// ObjectURL 对象的访问地址（未签名）
+func (s *ObjectStorage) ObjectURL(key string) string {
+	host, path := s.locate(key)
+	return s.base.Scheme + "://" + host + path
+}
+
+// URLExpire 下载签名链接有效期
+func (s *ObjectStorage) URLExpire() time.Duration {
+	if s.config.URLExpire > 0 {
+		return time.Duration(s.config.URLExpire) * time.Second
+	}
+	return DefaultReplayURLExpire
+}
+
+// PutObject 上传对象
+func (s *ObjectStorage) PutObject(key string, body []byte, contentType string) error {
+	req, err := s.newSignedRequest(http.MethodPut, key, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	return s.do(req)
+}
+
+// GetObject 下载对象内容
+func (s *ObjectStorage) GetObject(key string) ([]byte, error) {
+	req, err := s.newSignedRequest(http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("对象存储返回 %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// DeleteObject 删除对象
+func (s *ObjectStorage) DeleteObject(key string) error {
+	req, err := s.newSignedRequest(http.MethodDelete, key, nil)
+	if err != nil {
+		return err
+	}
+	return s.do(req)
+}
+
+// PresignGet 生成带签名的下载链接
+func (s *ObjectStorage) PresignGet(key string, expires time.Duration) string {
+	return s.presign(http.MethodGet, key, expires, time.Now().UTC())
+}
+
+// do 发送请求并检查响应状态
+func (s *ObjectStorage) do(req *http.Request) error {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("对象存储返回 %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// locate 计算对象的主机名与路径
+func (s *ObjectStorage) locate(key string) (host, path string) {
+	basePath := strings.TrimRight(s.base.Path, "/")
+	if s.config.PathStyle {
+		return s.base.Host, basePath + "/" + s.config.Bucket + "/" + awsEscapePath(key)
+	}
+	return s.config.Bucket + "." + s.base.Host, basePath + "/" + awsEscapePath(key)
+}
+
+// newSignedRequest 创建带Authorization头的请求
+func (s *ObjectStorage) newSignedRequest(method, key string, body []byte) (*http.Request, error) {
+	host, path := s.locate(key)
+	req, err := http.NewRequest(method, s.base.Scheme+"://"+host+path, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+
+	now := time.Now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + host + "\n" +
+		"x-amz-content-sha256:" + payloadHash + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+	canonicalRequest := strings.Join([]string{method, path, "", canonicalHeaders, signedHeaders, payloadHash}, "\n")
+
+	scope := s.scope(now)
+	signature := s.sign(now, scope, canonicalRequest)
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.config.AccessKey, scope, signedHeaders, signature))
+	return req, nil
+}
+
+// presign 生成查询参数签名的链接
+func (s *ObjectStorage) presign(method, key string, expires time.Duration, now time.Time) string {
+	host, path := s.locate(key)
+	scope := s.scope(now)
+
+	query := map[string]string{
+		"X-Amz-Algorithm":     "AWS4-HMAC-SHA256",
+		"X-Amz-Credential":    s.config.AccessKey + "/" + scope,
+		"X-Amz-Date":          now.Format("20060102T150405Z"),
+		"X-Amz-Expires":       fmt.Sprintf("%d", int64(expires/time.Second)),
+		"X-Amz-SignedHeaders": "host",
+	}
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, awsEscape(k)+"="+awsEscape(query[k]))
+	}
+	canonicalQuery := strings.Join(parts, "&")
+
+	canonicalRequest := strings.Join([]string{method, path, canonicalQuery, "host:" + host + "\n", "host", "UNSIGNED-PAYLOAD"}, "\n")
+	signature := s.sign(now, scope, canonicalRequest)
+	return s.base.Scheme + "://" + host + path + "?" + canonicalQuery + "&X-Amz-Signature=" + signature
+}
+
+// scope 签名范围
+func (s *ObjectStorage) scope(now time.Time) string {
+	return now.Format("20060102") + "/" + s.config.Region + "/s3/aws4_request"
+}
+
+// sign 计算请求签名
+func (s *ObjectStorage) sign(now time.Time, scope, canonicalRequest string) string {
+	stringToSign := "AWS4-HMAC-SHA256\n" + now.Format("20060102T150405Z") + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+
+	key := hmacSHA256([]byte("AWS4"+s.config.SecretKey), now.Format("20060102"))
+	key = hmacSHA256(key, s.config.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// awsEscape 按SigV4规则编码（仅保留 A-Z a-z 0-9 - _ . ~）
+func awsEscape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+// awsEscapePath 编码对象键，保留路径分隔符
+func awsEscapePath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, seg := range segments {
+		segments[i] = awsEscape(seg)
+	}
+	return strings.Join(segments, "/")
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"fmt"
+	"log"
+
+	"phira-mp/common"
+)
+
+const (
+	// OfficialHostID 官方房间无人时的占位房主ID
+	OfficialHostID int32 = 0
+)
+
+// NewOfficialRoom 根据模板创建官方房间
+// 官方房间创建时没有玩家，房主为占位用户，第一个加入的玩家成为房主
+func NewOfficialRoom(tpl *RoomTemplate, server *Server) (*Room, error) {
+	id, err := common.NewRoomId(tpl.ID)
+	if err != nil {
+		return nil, err
+	}
+	if err := tpl.validate(); err != nil {
+		return nil, err
+	}
+
+	r := &Room{
+		ID:       id,
+		server:   server,
+		template: tpl,
+	}
+	r.host.Store(newOfficialHost(server))
+	r.state.Store(int32(InternalStateSelectChart))
+	r.maxUsers.Store(RoomMaxUsers)
+	if tpl.MaxUsers > 0 {
+		r.maxUsers.Store(int32(tpl.MaxUsers))
+	}
+	r.userList = []*User{}
+	r.SetLocked(tpl.Locked)
+	r.SetCycle(tpl.Cycle)
+	r.SetUniqueIP(tpl.UniqueIP)
+	r.resetHostIdle()
+
+	if tpl.ChartID != 0 {
+		r.SetChart(&Chart{ID: tpl.ChartID, Name: fmt.Sprintf("ID(%d)", tpl.ChartID)})
+		// 异步获取谱面名称，避免阻塞启动
+		go func() {
+			if chart, err := FetchChart(tpl.ChartID); err == nil {
+				r.SetChart(chart)
+			}
+		}()
+	}
+
+	return r, nil
+}
+
+// newOfficialHost 创建官方房间的占位房主
+func newOfficialHost(server *Server) *User {
+	return NewUser(OfficialHostID, "官方房间", "", server)
+}
+
+// validate 检查模板的谱面策略（ValidateConfig 对同样的问题给出提示）
+func (tpl *RoomTemplate) validate() error {
+	switch tpl.ChartPolicy {
+	case "", ChartPolicyFree:
+	case ChartPolicyFixed:
+		if tpl.ChartID == 0 {
+			return fmt.Errorf("使用 fixed 策略但未填写 chart_id")
+		}
+	default:
+		return fmt.Errorf("chart_policy 无效: %s", tpl.ChartPolicy)
+	}
+	return nil
+}
+
+// IsOfficial 是否为官方房间
+func (r *Room) IsOfficial() bool {
+	return r.template != nil
+}
+
+// IsChartFixed 谱面是否被模板固定
+func (r *Room) IsChartFixed() bool {
+	return r.template != nil && r.template.ChartPolicy == ChartPolicyFixed
+}
+
+// checkChart 检查谱面是否符合房间模板的谱面策略与定数限制，返回不符合的原因
+func (r *Room) checkChart(chart *Chart) string {
+	if r.template == nil {
+		return ""
+	}
+	if r.template.ChartPolicy == ChartPolicyFixed {
+		return "该房间谱面已固定"
+	}
+	if r.template.MinDifficulty > 0 && chart.Difficulty < r.template.MinDifficulty {
+		return fmt.Sprintf("该房间只能选择定数不低于 %.1f 的谱面", r.template.MinDifficulty)
+	}
+	if r.template.MaxDifficulty > 0 && chart.Difficulty > r.template.MaxDifficulty {
+		return fmt.Sprintf("该房间只能选择定数不高于 %.1f 的谱面", r.template.MaxDifficulty)
+	}
+	return ""
+}
+
+// hasPlaceholderHost 是否仍由占位房主持有
+func (r *Room) hasPlaceholderHost() bool {
+	return r.IsOfficial() && r.GetHost().ID == OfficialHostID
+}
+
+// CanMonitor 检查用户能否观察该房间（游客总是可以观察，启用直播模式时比赛房间任何人都可以观战）
+func (r *Room) CanMonitor(user *User) bool {
+	if user.CanMonitor() || user.IsGuest() {
+		return true
+	}
+	if !r.server.config.LiveMode {
+		return false
+	}
+	if r.IsContest() {
+		return true
+	}
+	if r.template == nil {
+		return false
+	}
+	for _, id := range r.template.Monitors {
+		if id == user.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// claimHost 官方房间无真实房主时，由新加入的玩家接任
+func (r *Room) claimHost(user *User) {
+	if !r.hasPlaceholderHost() {
+		return
+	}
+	r.SetHost(user)
+
+	log.Printf("官方房间 `%s` 房主变更为 %s(%d)", r.ID.Value, user.Name, user.ID)
+	BroadcastRoomLog(r.ID.Value, fmt.Sprintf("房主变更为 %s(%d)", user.Name, user.ID))
+
+	r.SendMessage(common.Message{
+		Type: common.MsgNewHost,
+		User: user.ID,
+	})
+	user.Send(common.ServerCommand{
+		Type:       common.ServerCmdChangeHost,
+		ChangeHost: true,
+	})
+}
+
+// EnsureOfficialRooms 创建所有缺失的官方房间
+func (s *Server) EnsureOfficialRooms() {
+	for i := range s.config.RoomTemplates {
+		tpl := &s.config.RoomTemplates[i]
+		if tpl.ChartPolicy == "" {
+			tpl.ChartPolicy = ChartPolicyFree
+		}
+		s.ensureOfficialRoom(tpl)
+	}
+}
+
+// ensureOfficialRoom 若模板对应的房间不存在则创建
+func (s *Server) ensureOfficialRoom(tpl *RoomTemplate) {
+	room, err := NewOfficialRoom(tpl, s)
+	if err != nil {
+		log.Printf("官方房间模板 `%s` 无效: %v", tpl.ID, err)
+		return
+	}
+	if _, loaded := s.rooms.LoadOrStore(room.ID, room); loaded {
+		return
+	}
+	s.touchRoomList()
+	log.Printf("官方房间 `%s` 已创建 (最大人数: %d, 谱面策略: %s)", room.ID.Value, room.GetMaxUsers(), tpl.ChartPolicy)
+	BroadcastAdminUpdate(s)
+}
+
+// findRoomTemplate 按房间ID查找模板
+func (s *Server) findRoomTemplate(id common.RoomId) *RoomTemplate {
+	for i := range s.config.RoomTemplates {
+		if s.config.RoomTemplates[i].ID == id.Value {
+			return &s.config.RoomTemplates[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,400 @@
+package server
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// OIDCConfig 管理员OIDC登录配置
+type OIDCConfig struct {
+	Issuer          string   `yaml:"issuer"`           // 签发者，如 https://sso.example.com/realms/main
+	Audience        string   `yaml:"audience"`         // 令牌受众（通常为客户端ID，必填）
+	JWKSURL         string   `yaml:"jwks_url"`         // 公钥地址（留空则通过 /.well-known/openid-configuration 发现）
+	AllowedSubjects []string `yaml:"allowed_subjects"` // 允许的 sub
+	AllowedGroups   []string `yaml:"allowed_groups"`   // 允许的用户组
+	GroupsClaim     string   `yaml:"groups_claim"`     // 用户组所在的声明名（默认 groups）
+}
+
+// Configured 是否填写了OIDC配置（不一定完整）
+func (c OIDCConfig) Configured() bool {
+	return c.Issuer != ""
+}
+
+// Problem 已填写的OIDC配置缺少的必要项（配置完整时返回空字符串）
+// 共用或公开的签发者会为任何人签发令牌，因此必须限定受众并配置白名单
+func (c OIDCConfig) Problem() string {
+	switch {
+	case c.Audience == "":
+		return "admin_oidc 未配置 audience"
+	case len(c.AllowedSubjects) == 0 && len(c.AllowedGroups) == 0:
+		return "admin_oidc 未配置 allowed_subjects 或 allowed_groups"
+	}
+	return ""
+}
+
+// Enabled 是否启用OIDC（配置不完整时不启用）
+func (c OIDCConfig) Enabled() bool {
+	return c.Configured() && c.Problem() == ""
+}
+
+// OIDC 相关时间参数
+const (
+	oidcClockSkew       = time.Minute      // 允许的时钟偏差
+	oidcKeysTTL         = time.Hour        // 公钥缓存时间
+	oidcRefreshInterval = 30 * time.Second // 遇到未知kid时两次刷新公钥的最小间隔
+)
+
+// OIDCClaims 通过验证的令牌声明
+type OIDCClaims struct {
+	Subject string
+	Name    string
+	Groups  []string
+}
+
+// Display 日志中显示的管理员身份
+func (c *OIDCClaims) Display() string {
+	if c.Name != "" {
+		return fmt.Sprintf("%s(%s)", c.Name, c.Subject)
+	}
+	return c.Subject
+}
+
+// OIDCVerifier 校验签发者签发的JWT（RS256/RS384/RS512/ES256/ES384）
+type OIDCVerifier struct {
+	config OIDCConfig
+	client *http.Client
+
+	mu        sync.Mutex
+	keys      map[string]crypto.PublicKey // kid -> 公钥
+	fetchedAt time.Time
+}
+
+// NewOIDCVerifier 创建OIDC令牌校验器
+func NewOIDCVerifier(config OIDCConfig) *OIDCVerifier {
+	config.Issuer = strings.TrimRight(config.Issuer, "/")
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = "groups"
+	}
+	return &OIDCVerifier{
+		config: config,
+		client: &http.Client{Timeout: 10 * time.Second},
+		keys:   make(map[string]crypto.PublicKey),
+	}
+}
+
+// LooksLikeJWT 粗略判断token是否为JWT，用于与静态token/临时token区分
+func LooksLikeJWT(token string) bool {
+	return strings.Count(token, ".") == 2
+}
+
+// Verify 校验令牌签名、签发者、受众、有效期以及 sub/用户组白名单
+func (v *OIDCVerifier) Verify(token string) (*OIDCClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("令牌格式错误")
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("令牌头无效: %w", err)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("签名编码无效")
+	}
+	key, err := v.key(header.Kid)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyJWTSignature(header.Alg, key, parts[0]+"."+parts[1], signature); err != nil {
+		return nil, err
+	}
+
+	var claims map[string]interface{}
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("令牌内容无效: %w", err)
+	}
+	return v.checkClaims(claims, time.Now())
+}
+
+// checkClaims 校验标准声明与白名单
+func (v *OIDCVerifier) checkClaims(claims map[string]interface{}, now time.Time) (*OIDCClaims, error) {
+	if iss, _ := claims["iss"].(string); strings.TrimRight(iss, "/") != v.config.Issuer {
+		return nil, fmt.Errorf("签发者不匹配: %s", iss)
+	}
+	if v.config.Audience == "" || !containsString(claimStrings(claims["aud"]), v.config.Audience) {
+		return nil, fmt.Errorf("受众不匹配")
+	}
+	exp, ok := claims["exp"].(float64)
+	if !ok || now.After(time.Unix(int64(exp), 0).Add(oidcClockSkew)) {
+		return nil, fmt.Errorf("令牌已过期")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(oidcClockSkew).Before(time.Unix(int64(nbf), 0)) {
+		return nil, fmt.Errorf("令牌尚未生效")
+	}
+
+	result := &OIDCClaims{Groups: claimStrings(claims[v.config.GroupsClaim])}
+	result.Subject, _ = claims["sub"].(string)
+	if result.Name, _ = claims["preferred_username"].(string); result.Name == "" {
+		result.Name, _ = claims["email"].(string)
+	}
+	if result.Subject == "" {
+		return nil, fmt.Errorf("令牌缺少sub")
+	}
+
+	if containsString(v.config.AllowedSubjects, result.Subject) {
+		return result, nil
+	}
+	for _, group := range result.Groups {
+		if containsString(v.config.AllowedGroups, group) {
+			return result, nil
+		}
+	}
+	return nil, fmt.Errorf("用户 %s 不在允许列表中", result.Display())
+}
+
+// key 获取kid对应的公钥，缓存过期或kid未知时刷新
+func (v *OIDCVerifier) key(kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	age := time.Since(v.fetchedAt)
+	key, ok := v.lookup(kid)
+	if ok && age < oidcKeysTTL {
+		return key, nil
+	}
+	// 签发者轮换密钥时kid未知，限制刷新频率避免被伪造令牌反复触发
+	if age >= oidcKeysTTL || (!ok && age >= oidcRefreshInterval) {
+		if err := v.fetchKeys(); err != nil {
+			if ok {
+				return key, nil
+			}
+			return nil, fmt.Errorf("获取签发者公钥失败: %w", err)
+		}
+		key, ok = v.lookup(kid)
+	}
+	if !ok {
+		return nil, fmt.Errorf("未知的签名密钥: %s", kid)
+	}
+	return key, nil
+}
+
+// lookup 查找公钥，kid为空且只有一个公钥时使用该公钥，调用方需持有锁
+func (v *OIDCVerifier) lookup(kid string) (crypto.PublicKey, bool) {
+	if kid == "" && len(v.keys) == 1 {
+		for _, key := range v.keys {
+			return key, true
+		}
+	}
+	key, ok := v.keys[kid]
+	return key, ok
+}
+
+// fetchKeys 拉取JWKS，调用方需持有锁
+func (v *OIDCVerifier) fetchKeys() error {
+	jwksURL := v.config.JWKSURL
+	if jwksURL == "" {
+		var discovery struct {
+			JWKSURI string `json:"jwks_uri"`
+		}
+		if err := v.getJSON(v.config.Issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+			return err
+		}
+		if discovery.JWKSURI == "" {
+			return fmt.Errorf("发现文档缺少jwks_uri")
+		}
+		jwksURL = discovery.JWKSURI
+	}
+
+	var jwks struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := v.getJSON(jwksURL, &jwks); err != nil {
+		return err
+	}
+	keys := make(map[string]crypto.PublicKey)
+	for _, jwk := range jwks.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		if key, err := jwk.publicKey(); err == nil {
+			keys[jwk.Kid] = key
+		}
+	}
+	if len(keys) == 0 {
+		return fmt.Errorf("JWKS中没有可用的公钥")
+	}
+	v.keys = keys
+	v.fetchedAt = time.Now()
+	return nil
+}
+
+func (v *OIDCVerifier) getJSON(url string, out interface{}) error {
+	resp, err := v.client.Get(url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s 返回状态码 %d", url, resp.StatusCode)
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// jsonWebKey JWKS中的单个公钥
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		default:
+			return nil, fmt.Errorf("不支持的曲线: %s", k.Crv)
+		}
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("不支持的密钥类型: %s", k.Kty)
+	}
+}
+
+// verifyJWTSignature 按alg校验签名
+func verifyJWTSignature(alg string, key crypto.PublicKey, signed string, signature []byte) error {
+	var hash crypto.Hash
+	switch alg {
+	case "RS256", "ES256":
+		hash = crypto.SHA256
+	case "RS384", "ES384":
+		hash = crypto.SHA384
+	case "RS512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("不支持的签名算法: %s", alg)
+	}
+	digest := hashBytes(hash, []byte(signed))
+
+	switch pub := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return fmt.Errorf("签名算法与密钥不匹配")
+		}
+		if err := rsa.VerifyPKCS1v15(pub, hash, digest, signature); err != nil {
+			return fmt.Errorf("签名无效")
+		}
+	case *ecdsa.PublicKey:
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if !strings.HasPrefix(alg, "ES") || len(signature) != 2*size {
+			return fmt.Errorf("签名算法与密钥不匹配")
+		}
+		r := new(big.Int).SetBytes(signature[:size])
+		s := new(big.Int).SetBytes(signature[size:])
+		if !ecdsa.Verify(pub, digest, r, s) {
+			return fmt.Errorf("签名无效")
+		}
+	default:
+		return fmt.Errorf("不支持的密钥类型")
+	}
+	return nil
+}
+
+func hashBytes(hash crypto.Hash, data []byte) []byte {
+	switch hash {
+	case crypto.SHA384:
+		sum := sha512.Sum384(data)
+		return sum[:]
+	case crypto.SHA512:
+		sum := sha512.Sum512(data)
+		return sum[:]
+	default:
+		sum := sha256.Sum256(data)
+		return sum[:]
+	}
+}
+
+func decodeJWTPart(part string, out interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+func decodeBigInt(s string) (*big.Int, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil || len(data) == 0 {
+		return nil, fmt.Errorf("无效的密钥参数")
+	}
+	return new(big.Int).SetBytes(data), nil
+}
+
+// claimStrings 将字符串或字符串数组声明统一为切片
+func claimStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				result = append(result, s)
+			}
+		}
+		return result
+	}
+	return nil
+}
+
+func containsString(list []string, target string) bool {
+	for _, item := range list {
+		if item == target {
+			return true
+		}
+	}
+	return false
+}
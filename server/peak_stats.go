@@ -0,0 +1,213 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// LoadCounts 某一时刻的服务器负载
+type LoadCounts struct {
+	Sessions     int `json:"sessions"`      // 连接会话数
+	Users        int `json:"users"`         // 在线用户数（不含断线保留位置的用户）
+	Rooms        int `json:"rooms"`         // 房间数
+	PlayingRooms int `json:"playing_rooms"` // 正在游戏的房间数
+}
+
+// PeakValue 峰值及首次达到该值的时间
+type PeakValue struct {
+	Value int   `json:"value"`
+	At    int64 `json:"at"` // Unix秒
+}
+
+func (p *PeakValue) observe(value int, at time.Time) bool {
+	if value <= p.Value {
+		return false
+	}
+	p.Value, p.At = value, at.Unix()
+	return true
+}
+
+// PeakSet 一组负载峰值
+type PeakSet struct {
+	Sessions     PeakValue `json:"sessions"`
+	Users        PeakValue `json:"users"`
+	Rooms        PeakValue `json:"rooms"`
+	PlayingRooms PeakValue `json:"playing_rooms"`
+}
+
+// observe 用当前负载更新峰值，返回是否有变化
+func (p *PeakSet) observe(c LoadCounts, at time.Time) bool {
+	changed := p.Sessions.observe(c.Sessions, at)
+	changed = p.Users.observe(c.Users, at) || changed
+	changed = p.Rooms.observe(c.Rooms, at) || changed
+	changed = p.PlayingRooms.observe(c.PlayingRooms, at) || changed
+	return changed
+}
+
+// DailyPeak 某一天的负载峰值
+type DailyPeak struct {
+	Day int64 `json:"day"` // 当天0点（服务器本地时间，Unix秒）
+	PeakSet
+}
+
+// dayStart 获取当天0点（服务器本地时间）
+func dayStart(at time.Time) int64 {
+	y, m, d := at.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, at.Location()).Unix()
+}
+
+// ObservePeaks 用当前负载更新历史峰值与当日峰值
+func (a *ActivityStats) ObservePeaks(c LoadCounts, at time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	day := dayStart(at)
+	daily, ok := a.DailyPeaks[day]
+	if !ok {
+		daily = &PeakSet{}
+		a.DailyPeaks[day] = daily
+	}
+	allTime := a.Peaks.observe(c, at)
+	if daily.observe(c, at) || allTime {
+		a.dirty = true
+	}
+}
+
+// PeakReport 返回历史峰值、当日峰值与 [from, now] 内每日峰值（按日期升序）
+func (a *ActivityStats) PeakReport(from, now time.Time) (PeakSet, PeakSet, []DailyPeak) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var today PeakSet
+	if p, ok := a.DailyPeaks[dayStart(now)]; ok {
+		today = *p
+	}
+	start := dayStart(from)
+	days := make([]DailyPeak, 0)
+	for day, p := range a.DailyPeaks {
+		if day >= start && day <= now.Unix() {
+			days = append(days, DailyPeak{Day: day, PeakSet: *p})
+		}
+	}
+	sort.Slice(days, func(i, j int) bool {
+		return days[i].Day < days[j].Day
+	})
+	return a.Peaks, today, days
+}
+
+// pruneDailyPeaks 删除早于指定时间的每日峰值，调用方需持有锁
+func (a *ActivityStats) pruneDailyPeaks(before time.Time) int {
+	cutoff := dayStart(before)
+	pruned := 0
+	for day := range a.DailyPeaks {
+		if day < cutoff {
+			delete(a.DailyPeaks, day)
+			pruned++
+		}
+	}
+	return pruned
+}
+
+// LoadCounts 统计当前负载
+func (s *Server) LoadCounts() LoadCounts {
+	var c LoadCounts
+	s.sessions.Range(func(_, _ interface{}) bool {
+		c.Sessions++
+		return true
+	})
+	s.users.Range(func(_, value interface{}) bool {
+		if !value.(*User).IsDisconnected() {
+			c.Users++
+		}
+		return true
+	})
+	s.rooms.Range(func(_, value interface{}) bool {
+		c.Rooms++
+		if value.(*Room).GetState() == InternalStatePlaying {
+			c.PlayingRooms++
+		}
+		return true
+	})
+	return c
+}
+
+// observePeaks 在连接、用户、房间数量增加或开局时更新峰值（随活动统计采样一起启用）
+func (s *Server) observePeaks() {
+	if s.activityStats == nil || s.config.ActivitySampleInterval <= 0 {
+		return
+	}
+	s.activityStats.ObservePeaks(s.LoadCounts(), time.Now())
+}
+
+// handleAdminPeakStats 查询负载峰值
+func (h *HTTPServer) handleAdminPeakStats(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method-not-allowed")
+		return
+	}
+
+	days := 7
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n <= 0 || n > 365 {
+			writeError(w, http.StatusBadRequest, "bad-days")
+			return
+		}
+		days = n
+	}
+
+	stats := h.server.GetActivityStats()
+	if stats == nil {
+		writeError(w, http.StatusServiceUnavailable, "stats-disabled")
+		return
+	}
+
+	now := time.Now()
+	allTime, today, daily := stats.PeakReport(now.AddDate(0, 0, -days), now)
+	writeOK(w, map[string]interface{}{
+		"current":  h.server.LoadCounts(),
+		"all_time": allTime,
+		"today":    today,
+		"daily":    daily,
+	})
+}
+
+// writePeakMetrics 以Prometheus文本格式输出当前负载与峰值
+func (h *HTTPServer) writePeakMetrics(w io.Writer) {
+	c := h.server.LoadCounts()
+	current := []struct {
+		kind  string
+		value int
+	}{
+		{"sessions", c.Sessions}, {"users", c.Users}, {"rooms", c.Rooms}, {"playing_rooms", c.PlayingRooms},
+	}
+
+	fmt.Fprintln(w, "# HELP phira_load 当前负载")
+	fmt.Fprintln(w, "# TYPE phira_load gauge")
+	for _, v := range current {
+		fmt.Fprintf(w, "phira_load{kind=%q} %d\n", v.kind, v.value)
+	}
+
+	stats := h.server.GetActivityStats()
+	if stats == nil {
+		return
+	}
+	now := time.Now()
+	allTime, today, _ := stats.PeakReport(now, now)
+
+	fmt.Fprintln(w, "# HELP phira_load_peak 负载峰值（period 为 all_time 或 today）")
+	fmt.Fprintln(w, "# TYPE phira_load_peak gauge")
+	for _, p := range []struct {
+		period string
+		set    PeakSet
+	}{{"all_time", allTime}, {"today", today}} {
+		fmt.Fprintf(w, "phira_load_peak{kind=\"sessions\",period=%q} %d\n", p.period, p.set.Sessions.Value)
+		fmt.Fprintf(w, "phira_load_peak{kind=\"users\",period=%q} %d\n", p.period, p.set.Users.Value)
+		fmt.Fprintf(w, "phira_load_peak{kind=\"rooms\",period=%q} %d\n", p.period, p.set.Rooms.Value)
+		fmt.Fprintf(w, "phira_load_peak{kind=\"playing_rooms\",period=%q} %d\n", p.period, p.set.PlayingRooms.Value)
+	}
+}
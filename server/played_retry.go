@@ -0,0 +1,74 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"phira-mp/common"
+)
+
+// 成绩确认重试参数
+const (
+	DefaultPlayedRetries = 4               // 默认重试次数（共等待约15秒）
+	playedRetryBackoff   = 1 * time.Second // 首次重试前的等待时间（之后每次翻倍）
+)
+
+// startPlayedRetry 成绩查询失败时通知房间该玩家"成绩确认中"并在后台重试（玩家已在 handlePlayed 中标记），
+// 重试结束后再回复 PlayedResult，期间房间不会因该玩家未完成而结束对局
+func (s *Session) startPlayedRetry(room *Room, recordID int64, err error) {
+	log.Printf("用户 `%s(%d)` 的成绩 %d 暂时无法查询，后台重试: %v", s.User.Name, s.User.ID, recordID, err)
+	room.SendMessage(common.Message{
+		Type:    common.MsgChat,
+		User:    0,
+		Content: fmt.Sprintf("%s 的成绩正在确认中", s.User.Name),
+	})
+	BroadcastRoomUpdate(room)
+
+	go s.retryPlayed(room, recordID)
+}
+
+// retryPlayed 按指数回退重试查询成绩
+func (s *Session) retryPlayed(room *Room, recordID int64) {
+	var record *Record
+	var err error
+	backoff := playedRetryBackoff
+	for attempt := 1; attempt <= s.server.config.PlayedRetries; attempt++ {
+		select {
+		case <-time.After(backoff):
+		case <-s.server.stopChan:
+			return
+		}
+		backoff *= 2
+
+		record, err = s.server.GetRecordProvider().FetchRecord(recordID)
+		if err == nil {
+			break
+		}
+		log.Printf("用户 `%s(%d)` 的成绩 %d 第 %d 次重试失败: %v", s.User.Name, s.User.ID, recordID, attempt, err)
+	}
+	s.reportAsync(s.finishPlayed(room, recordID, record, err))
+}
+
+// finishPlayed 成绩查询结束后清除"成绩确认中"标记并回复 PlayedResult
+func (s *Session) finishPlayed(room *Room, recordID int64, record *Record, err error) error {
+	room.pendingResults.Delete(s.User.ID)
+
+	// 查询期间玩家可能已离开房间或对局已结束
+	if s.User.GetRoom() != room || room.GetState() != InternalStatePlaying {
+		log.Printf("用户 `%s(%d)` 的成绩 %d 确认时已不在对局中，忽略", s.User.Name, s.User.ID, recordID)
+		return s.Send(common.ServerCommand{
+			Type:         common.ServerCmdPlayed,
+			PlayedResult: &common.Result[struct{}]{Err: strPtr("未在游戏中")},
+		})
+	}
+
+	if err != nil {
+		BroadcastRoomUpdate(room)
+		return s.Send(common.ServerCommand{
+			Type:         common.ServerCmdPlayed,
+			PlayedResult: &common.Result[struct{}]{Err: strPtr("记录不存在")},
+		})
+	}
+	return s.completePlayed(room, record)
+}
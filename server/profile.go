@@ -0,0 +1,126 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"phira-mp/common"
+)
+
+const (
+	profileNameMaxRunes   = 20               // 显示名称最大字符数
+	profileUpdateInterval = 10 * time.Second // 两次修改资料的最小间隔
+)
+
+// userProfile 玩家自定义的显示资料（不影响账号名称，日志与管理接口仍使用账号名称）
+type userProfile struct {
+	Name      string // 显示名称，空字符串表示使用账号名称
+	Avatar    string // 头像提示（头像URL或预设编号，由客户端解释）
+	UpdatedAt time.Time
+}
+
+func (u *User) loadProfile() userProfile {
+	if p, ok := u.profile.Load().(userProfile); ok {
+		return p
+	}
+	return userProfile{}
+}
+
+// DisplayName 向客户端展示的名称，未设置时为账号名称
+func (u *User) DisplayName() string {
+	if p := u.loadProfile(); p.Name != "" {
+		return p.Name
+	}
+	return u.Name
+}
+
+// Avatar 头像提示
+func (u *User) Avatar() string {
+	return u.loadProfile().Avatar
+}
+
+// SetProfile 设置显示资料
+func (u *User) SetProfile(name, avatar string) {
+	if name == u.Name {
+		name = ""
+	}
+	u.profile.Store(userProfile{Name: name, Avatar: avatar, UpdatedAt: time.Now()})
+}
+
+// ProfileInfo 转换为资料信息
+func (u *User) ProfileInfo() common.ProfileInfo {
+	return common.ProfileInfo{
+		ID:     u.ID,
+		Name:   u.DisplayName(),
+		Avatar: u.Avatar(),
+	}
+}
+
+// validProfileText 检查资料文本是否合法（UTF-8且不含控制字符）
+func validProfileText(s string) bool {
+	if !utf8.ValidString(s) {
+		return false
+	}
+	for _, r := range s {
+		if unicode.IsControl(r) {
+			return false
+		}
+	}
+	return true
+}
+
+// handleUpdateProfile 处理修改显示资料，修改后广播给房间内所有成员
+func (s *Session) handleUpdateProfile(name, avatar string) error {
+	fail := func(msg string) error {
+		return s.Send(common.ServerCommand{
+			Type:                common.ServerCmdUpdateProfile,
+			UpdateProfileResult: &common.Result[struct{}]{Err: strPtr(msg)},
+		})
+	}
+
+	if s.User.IsGuest() {
+		return fail("游客不能修改资料")
+	}
+
+	name = strings.TrimSpace(name)
+	avatar = strings.TrimSpace(avatar)
+	if !validProfileText(name) || !validProfileText(avatar) {
+		return fail("资料包含非法字符")
+	}
+	if utf8.RuneCountInString(name) > profileNameMaxRunes {
+		return fail(fmt.Sprintf("名称不能超过 %d 个字符", profileNameMaxRunes))
+	}
+	if last := s.User.loadProfile().UpdatedAt; !last.IsZero() && time.Since(last) < profileUpdateInterval {
+		return fail("修改过于频繁，请稍后再试")
+	}
+
+	room := s.User.GetRoom()
+	if room != nil && name != "" {
+		for _, u := range room.GetAllUsers() {
+			if u.ID != s.User.ID && strings.EqualFold(u.DisplayName(), name) {
+				return fail("名称与房间内其他玩家重复")
+			}
+		}
+	}
+
+	s.User.SetProfile(name, avatar)
+	log.Printf("用户 `%s(%d)` 修改资料: 显示名称=%q", s.User.Name, s.User.ID, s.User.DisplayName())
+
+	profile := s.User.ProfileInfo()
+	update := common.ServerCommand{Type: common.ServerCmdProfileUpdated, ProfileUpdated: &profile}
+	if room != nil {
+		room.Broadcast(update)
+		BroadcastRoomUpdate(room)
+	} else {
+		s.Send(update)
+	}
+
+	return s.Send(common.ServerCommand{
+		Type:                common.ServerCmdUpdateProfile,
+		UpdateProfileResult: &common.Result[struct{}]{Ok: &struct{}{}},
+	})
+}
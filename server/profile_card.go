@@ -0,0 +1,57 @@
+package server
+
+import (
+	"log"
+
+	"phira-mp/common"
+)
+
+// ShareStats 是否向房间成员展示资料卡
+func (u *User) ShareStats() bool {
+	return u.shareStats.Load()
+}
+
+// ProfileCard 随用户信息发送的资料卡，未开启展示时为nil
+func (u *User) ProfileCard() *common.ProfileCard {
+	if !u.shareStats.Load() {
+		return nil
+	}
+	return u.card.Load()
+}
+
+// refreshProfileCard 开启展示时重新统计资料卡（加入房间时调用，游玩次数来自本服务器的对局历史）
+func (u *User) refreshProfileCard() {
+	if !u.shareStats.Load() {
+		return
+	}
+	card := &common.ProfileCard{Rks: u.Rks}
+	if u.server != nil {
+		if history := u.server.GetGameHistory(); history != nil {
+			card.PlayCount = uint32(history.PlayCount(u.ID))
+		}
+	}
+	u.card.Store(card)
+}
+
+// handleShareStats 处理玩家开启或关闭资料卡展示，从下次加入房间起生效
+func (s *Session) handleShareStats(share bool) error {
+	if share && s.User.IsGuest() {
+		return s.Send(common.ServerCommand{
+			Type:             common.ServerCmdShareStats,
+			ShareStatsResult: &common.Result[struct{}]{Err: strPtr("游客没有资料卡")},
+		})
+	}
+
+	s.User.shareStats.Store(share)
+	s.User.refreshProfileCard()
+	action := "关闭"
+	if share {
+		action = "开启"
+	}
+	log.Printf("用户 `%s(%d)` %s资料卡展示", s.User.Name, s.User.ID, action)
+
+	return s.Send(common.ServerCommand{
+		Type:             common.ServerCmdShareStats,
+		ShareStatsResult: &common.Result[struct{}]{Ok: &struct{}{}},
+	})
+}
@@ -214,6 +214,11 @@ func (c *ProxyConn) RemoteAddr() net.Addr {
 	return c.Conn.RemoteAddr()
 }
 
+// NetConn 返回被包装的连接
+func (c *ProxyConn) NetConn() net.Conn {
+	return c.Conn
+}
+
 // proxyAddr 代理地址实现
 type proxyAddr struct {
 	ip   net.IP
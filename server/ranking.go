@@ -0,0 +1,243 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"phira-mp/common"
+)
+
+// Aggregator 排名策略：把单局成绩换算为排名分数，分数越高排名越靠前
+// 嵌入服务器时可通过 RegisterAggregator 注册自定义策略，注册后房主即可通过 SetRanking 按名称选择
+type Aggregator interface {
+	Name() string                  // 策略名称（SetRanking 使用的名称，不超过 common.RankingNameMaxLen 字节）
+	Points(record *Record) float64 // 单局成绩对应的排名分数
+}
+
+// 内置排名策略
+const (
+	AggregatorScore = "score" // 按分数排名（默认）
+	AggregatorAcc   = "acc"   // 按准度排名
+	AggregatorCombo = "combo" // 按最大连击占比加权的分数排名
+)
+
+// DefaultAggregator 房主未设置时使用的排名策略
+const DefaultAggregator = AggregatorScore
+
+type funcAggregator struct {
+	name   string
+	points func(*Record) float64
+}
+
+func (a funcAggregator) Name() string                  { return a.name }
+func (a funcAggregator) Points(record *Record) float64 { return a.points(record) }
+
+// NewAggregator 由名称与换算函数创建排名策略
+func NewAggregator(name string, points func(record *Record) float64) Aggregator {
+	return funcAggregator{name: name, points: points}
+}
+
+// comboWeightedPoints 分数的一半固定计入，另一半按最大连击占总物量的比例计入
+func comboWeightedPoints(record *Record) float64 {
+	notes := record.Perfect + record.Good + record.Bad + record.Miss
+	if notes <= 0 {
+		return float64(record.Score) / 2
+	}
+	return float64(record.Score) * (0.5 + 0.5*float64(record.MaxCombo)/float64(notes))
+}
+
+var aggregators = struct {
+	sync.RWMutex
+	m map[string]Aggregator
+}{m: map[string]Aggregator{
+	AggregatorScore: NewAggregator(AggregatorScore, func(r *Record) float64 { return float64(r.Score) }),
+	AggregatorAcc:   NewAggregator(AggregatorAcc, func(r *Record) float64 { return float64(r.Accuracy) }),
+	AggregatorCombo: NewAggregator(AggregatorCombo, comboWeightedPoints),
+}}
+
+// RegisterAggregator 注册自定义排名策略（名称不能为空、过长或与已注册的策略重复）
+func RegisterAggregator(a Aggregator) error {
+	name := a.Name()
+	if name == "" || len(name) > common.RankingNameMaxLen {
+		return fmt.Errorf("invalid aggregator name: %q", name)
+	}
+	aggregators.Lock()
+	defer aggregators.Unlock()
+	if _, exists := aggregators.m[name]; exists {
+		return fmt.Errorf("aggregator already registered: %s", name)
+	}
+	aggregators.m[name] = a
+	return nil
+}
+
+// LookupAggregator 按名称查找排名策略
+func LookupAggregator(name string) (Aggregator, bool) {
+	aggregators.RLock()
+	defer aggregators.RUnlock()
+	a, ok := aggregators.m[name]
+	return a, ok
+}
+
+// AggregatorNames 已注册的排名策略名称（按名称排序）
+func AggregatorNames() []string {
+	aggregators.RLock()
+	defer aggregators.RUnlock()
+	names := make([]string, 0, len(aggregators.m))
+	for name := range aggregators.m {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// RankEntry 排名中的一项
+type RankEntry struct {
+	Rank     int     `json:"rank"` // 从1开始，分数相同的玩家名次相同
+	UserID   int32   `json:"user_id"`
+	Name     string  `json:"name"`
+	Points   float64 `json:"points"`
+	Score    int32   `json:"score"`
+	Accuracy float32 `json:"accuracy"`
+	MaxCombo int32   `json:"max_combo"`
+	Team     uint8   `json:"team,omitempty"` // 所在队伍（0表示未分队）
+}
+
+// GameSummary 单局结算
+type GameSummary struct {
+	ChartID    int64       `json:"chart_id"`
+	ChartName  string      `json:"chart_name"`
+	Aggregator string      `json:"aggregator"`
+	Ranking    []RankEntry `json:"ranking"`
+	Teams      []TeamEntry `json:"teams,omitempty"` // 队伍结算（没有玩家分队时省略）
+	Aborted    []int32     `json:"aborted,omitempty"`
+	EndedAt    int64       `json:"ended_at"` // Unix毫秒
+}
+
+// RankRecords 按排名策略对成绩排序，name 用于查询玩家名称
+func RankRecords(a Aggregator, records map[int32]*Record, name func(userID int32) string) []RankEntry {
+	entries := make([]RankEntry, 0, len(records))
+	for userID, record := range records {
+		entries = append(entries, RankEntry{
+			UserID:   userID,
+			Name:     name(userID),
+			Points:   a.Points(record),
+			Score:    record.Score,
+			Accuracy: record.Accuracy,
+			MaxCombo: record.MaxCombo,
+		})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Points != entries[j].Points {
+			return entries[i].Points > entries[j].Points
+		}
+		return entries[i].UserID < entries[j].UserID
+	})
+	for i := range entries {
+		if i > 0 && entries[i].Points == entries[i-1].Points {
+			entries[i].Rank = entries[i-1].Rank
+		} else {
+			entries[i].Rank = i + 1
+		}
+	}
+	return entries
+}
+
+// GetAggregator 获取房间的排名策略（未设置或策略已不存在时为默认策略）
+func (r *Room) GetAggregator() Aggregator {
+	if name, ok := r.ranking.Load().(string); ok {
+		if a, ok := LookupAggregator(name); ok {
+			return a
+		}
+	}
+	a, _ := LookupAggregator(DefaultAggregator)
+	return a
+}
+
+// SetAggregator 按名称设置房间的排名策略
+func (r *Room) SetAggregator(name string) error {
+	if _, ok := LookupAggregator(name); !ok {
+		return fmt.Errorf("unknown aggregator: %s", name)
+	}
+	r.ranking.Store(name)
+	return nil
+}
+
+// GetLastSummary 获取房间最近一局的结算（尚未结束过对局时为nil）
+func (r *Room) GetLastSummary() *GameSummary {
+	summary, _ := r.lastSummary.Load().(*GameSummary)
+	return summary
+}
+
+// buildSummary 按房间的排名策略生成本局结算（需在清空游戏状态前调用）
+func (r *Room) buildSummary() *GameSummary {
+	a := r.GetAggregator()
+	summary := &GameSummary{
+		Aggregator: a.Name(),
+		EndedAt:    time.Now().UnixMilli(),
+	}
+	if chart := r.GetChart(); chart != nil {
+		summary.ChartID = chart.ID
+		summary.ChartName = chart.Name
+	}
+
+	records := make(map[int32]*Record)
+	r.results.Range(func(key, value interface{}) bool {
+		records[key.(int32)] = value.(*Record)
+		return true
+	})
+	summary.Ranking = RankRecords(a, records, r.userName)
+	summary.Teams = r.rankTeams(summary.Ranking)
+
+	r.aborted.Range(func(key, value interface{}) bool {
+		summary.Aborted = append(summary.Aborted, key.(int32))
+		return true
+	})
+	sort.Slice(summary.Aborted, func(i, j int) bool { return summary.Aborted[i] < summary.Aborted[j] })
+	return summary
+}
+
+// userName 查找房间内玩家的名称（已离开时为占位名称）
+func (r *Room) userName(userID int32) string {
+	for _, u := range r.GetAllUsers() {
+		if u.ID == userID {
+			return u.Name
+		}
+	}
+	return fmt.Sprintf("玩家%d", userID)
+}
+
+// handleSetRanking 处理房主设置排名策略
+func (s *Session) handleSetRanking(name string) error {
+	fail := func(msg string) error {
+		return s.Send(common.ServerCommand{
+			Type:             common.ServerCmdSetRanking,
+			SetRankingResult: &common.Result[struct{}]{Err: strPtr(msg)},
+		})
+	}
+
+	room := s.User.GetRoom()
+	if room == nil {
+		return fail("不在房间中")
+	}
+	if err := room.CheckHost(s.User); err != nil {
+		return fail("只有房主可以设置排名方式")
+	}
+	if room.GetState() == InternalStatePlaying {
+		return fail("游戏中不能修改排名方式")
+	}
+	if err := room.SetAggregator(name); err != nil {
+		return fail(fmt.Sprintf("未知的排名方式: %s", name))
+	}
+
+	log.Printf("房间 `%s` 排名方式设置为 %s", room.ID.Value, name)
+	BroadcastRoomLog(room.ID.Value, fmt.Sprintf("排名方式设置为 %s", name))
+	BroadcastRoomUpdate(room)
+
+	return s.Send(common.ServerCommand{
+		Type:             common.ServerCmdSetRanking,
+		SetRankingResult: &common.Result[struct{}]{Ok: &struct{}{}},
+	})
+}
@@ -0,0 +1,151 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// RateLimitConfig 单组公开接口的限流配置（按客户端IP计算，令牌桶）
+type RateLimitConfig struct {
+	Rate  float64 `yaml:"rate"`  // 每秒补充的请求数（0表示不限流）
+	Burst int     `yaml:"burst"` // 允许的突发请求数（桶容量，默认与 rate 相同）
+}
+
+// PublicRateLimitConfig 公开接口限流配置（与管理员认证限流互相独立）
+type PublicRateLimitConfig struct {
+	Room   RateLimitConfig `yaml:"room"`   // GET /room
+	Replay RateLimitConfig `yaml:"replay"` // /replay/*
+}
+
+// DefaultPublicRateLimitConfig 默认公开接口限流配置
+func DefaultPublicRateLimitConfig() PublicRateLimitConfig {
+	return PublicRateLimitConfig{
+		Room:   RateLimitConfig{Rate: 2, Burst: 10},
+		Replay: RateLimitConfig{Rate: 1, Burst: 10},
+	}
+}
+
+// rateLimitPruneInterval 清理空闲IP记录的间隔
+const rateLimitPruneInterval = time.Minute
+
+// rateBucket 单个IP的令牌桶
+type rateBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter 按IP的令牌桶限流器
+type RateLimiter struct {
+	name  string
+	rate  float64
+	burst float64
+
+	mu        sync.Mutex
+	buckets   map[string]*rateBucket
+	lastPrune time.Time
+
+	allowed   atomic.Uint64
+	throttled atomic.Uint64
+}
+
+// NewRateLimiter 创建限流器；rate 为0时返回nil（不限流）
+func NewRateLimiter(name string, config RateLimitConfig) *RateLimiter {
+	if config.Rate <= 0 {
+		return nil
+	}
+	burst := float64(config.Burst)
+	if burst < 1 {
+		burst = math.Max(1, math.Ceil(config.Rate))
+	}
+	return &RateLimiter{
+		name:      name,
+		rate:      config.Rate,
+		burst:     burst,
+		buckets:   make(map[string]*rateBucket),
+		lastPrune: time.Now(),
+	}
+}
+
+// Allow 消耗一个令牌；被限流时返回需要等待的时间
+func (l *RateLimiter) Allow(ip string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.lastPrune) >= rateLimitPruneInterval {
+		l.prune(now)
+	}
+
+	b, ok := l.buckets[ip]
+	if !ok {
+		b = &rateBucket{tokens: l.burst, last: now}
+		l.buckets[ip] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		l.throttled.Add(1)
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	l.allowed.Add(1)
+	return true, 0
+}
+
+// prune 清理已回满的令牌桶，调用方需持有锁
+func (l *RateLimiter) prune(now time.Time) {
+	full := time.Duration(l.burst / l.rate * float64(time.Second))
+	for ip, b := range l.buckets {
+		if now.Sub(b.last) >= full {
+			delete(l.buckets, ip)
+		}
+	}
+	l.lastPrune = now
+}
+
+// withRateLimit 公开接口限流中间件，超出配额时返回429与 Retry-After
+func (h *HTTPServer) withRateLimit(limiter *RateLimiter, next http.HandlerFunc) http.HandlerFunc {
+	if limiter == nil {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodOptions {
+			next(w, r)
+			return
+		}
+		ok, wait := limiter.Allow(h.getClientIP(r), time.Now())
+		if !ok {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			writeError(w, http.StatusTooManyRequests, "too-many-requests")
+			return
+		}
+		next(w, r)
+	}
+}
+
+// writeRateLimitMetrics 以Prometheus文本格式输出公开接口限流统计
+func (h *HTTPServer) writeRateLimitMetrics(w io.Writer) {
+	limiters := []*RateLimiter{h.roomLimiter, h.replayLimiter}
+
+	fmt.Fprintln(w, "# HELP phira_http_public_requests_total 公开接口放行的请求数")
+	fmt.Fprintln(w, "# TYPE phira_http_public_requests_total counter")
+	for _, l := range limiters {
+		if l != nil {
+			fmt.Fprintf(w, "phira_http_public_requests_total{group=%q} %d\n", l.name, l.allowed.Load())
+		}
+	}
+
+	fmt.Fprintln(w, "# HELP phira_http_throttled_total 公开接口因超出配额被拒绝的请求数")
+	fmt.Fprintln(w, "# TYPE phira_http_throttled_total counter")
+	for _, l := range limiters {
+		if l != nil {
+			fmt.Fprintf(w, "phira_http_throttled_total{group=%q} %d\n", l.name, l.throttled.Load())
+		}
+	}
+}
@@ -0,0 +1,110 @@
+package server
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// DefaultRecentUsersSize 默认保留的最近离线用户数量
+const DefaultRecentUsersSize = 1000
+
+// RecentUser 最近离开服务器的用户（仅保存在内存中，重启后清空）
+type RecentUser struct {
+	ID          int32  `json:"id"`
+	Name        string `json:"name"`
+	DisplayName string `json:"display_name"`
+	IP          string `json:"ip,omitempty"`   // 最近一次连接的IP（管理员接口按配置打码）
+	Room        string `json:"room,omitempty"` // 最近所在的房间
+	LastSeen    int64  `json:"last_seen"`      // 离开服务器的时间（毫秒时间戳）
+
+	LastDisconnect *DisconnectRecord `json:"last_disconnect,omitempty"` // 最近一次断开连接的原因
+}
+
+// RecentUsers 最近离开服务器的用户（LRU，超出容量时淘汰最早离开的）
+// 用户断线并被移除后管理员仍可查到其ID，以便事后封禁
+type RecentUsers struct {
+	mu       sync.Mutex
+	capacity int
+
+	order *list.List              // 从旧到新的 *RecentUser
+	index map[int32]*list.Element // 用户ID -> order 中的元素
+}
+
+// NewRecentUsers 创建最近离线用户缓存
+func NewRecentUsers(capacity int) *RecentUsers {
+	if capacity <= 0 {
+		capacity = DefaultRecentUsersSize
+	}
+	return &RecentUsers{
+		capacity: capacity,
+		order:    list.New(),
+		index:    make(map[int32]*list.Element),
+	}
+}
+
+// Add 记录离开服务器的用户，同一用户只保留最近一次
+func (c *RecentUsers) Add(user *User, now time.Time) {
+	entry := &RecentUser{
+		ID:          user.ID,
+		Name:        user.Name,
+		DisplayName: user.DisplayName(),
+		IP:          user.GetIP(),
+		Room:        user.LastRoom(),
+		LastSeen:    now.UnixMilli(),
+
+		LastDisconnect: user.LastDisconnect(),
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.index[user.ID]; ok {
+		c.order.Remove(elem)
+	}
+	c.index[user.ID] = c.order.PushBack(entry)
+	for c.order.Len() > c.capacity {
+		oldest := c.order.Front()
+		c.order.Remove(oldest)
+		delete(c.index, oldest.Value.(*RecentUser).ID)
+	}
+}
+
+// Remove 用户重新连接后从缓存中移除
+func (c *RecentUsers) Remove(userID int32) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.index[userID]; ok {
+		c.order.Remove(elem)
+		delete(c.index, userID)
+	}
+}
+
+// Get 获取指定用户的最近离线记录
+func (c *RecentUsers) Get(userID int32) *RecentUser {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if elem, ok := c.index[userID]; ok {
+		entry := *elem.Value.(*RecentUser)
+		return &entry
+	}
+	return nil
+}
+
+// List 按离开时间从新到旧列出，limit<=0 表示全部
+func (c *RecentUsers) List(limit int) []RecentUser {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	result := make([]RecentUser, 0, c.order.Len())
+	for elem := c.order.Back(); elem != nil; elem = elem.Prev() {
+		if limit > 0 && len(result) >= limit {
+			break
+		}
+		result = append(result, *elem.Value.(*RecentUser))
+	}
+	return result
+}
+
+// GetRecentUsers 获取最近离线用户缓存（未启用时为nil）
+func (s *Server) GetRecentUsers() *RecentUsers {
+	return s.recentUsers
+}
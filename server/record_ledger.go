@@ -0,0 +1,237 @@
+package server
+
+import (
+	"container/list"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// DefaultRecordLedgerSize 默认记录的已使用成绩数量
+const DefaultRecordLedgerSize = 100000
+
+// recordLedgerSaveInterval 已使用成绩的保存间隔
+const recordLedgerSaveInterval = 30 * time.Second
+
+// UsedRecord 已使用的成绩
+type UsedRecord struct {
+	RecordID int64  `json:"record_id"`
+	UserID   int32  `json:"user_id"`
+	RoomID   string `json:"room_id"`
+	UsedAt   int64  `json:"used_at"` // Unix毫秒
+}
+
+// RecordLedger 已使用成绩ID登记表（LRU，超出容量时淘汰最早的记录）
+// 防止同一成绩ID在多个房间或多局中重复提交
+type RecordLedger struct {
+	mu       sync.Mutex
+	saveMu   sync.Mutex
+	path     string
+	capacity int
+	dirty    bool
+
+	order *list.List              // 从旧到新的 *UsedRecord
+	index map[int64]*list.Element // 成绩ID -> order 中的元素
+
+	storeFreeze // 平滑升级后停止写文件（见 handover.go）
+}
+
+// NewRecordLedger 创建已使用成绩登记表
+func NewRecordLedger(path string, capacity int) *RecordLedger {
+	if capacity <= 0 {
+		capacity = DefaultRecordLedgerSize
+	}
+	return &RecordLedger{
+		path:     path,
+		capacity: capacity,
+		order:    list.New(),
+		index:    make(map[int64]*list.Element),
+	}
+}
+
+// Consume 登记成绩ID；若该成绩已被使用则返回之前的使用记录且不做修改
+func (l *RecordLedger) Consume(recordID int64, userID int32, roomID string, now time.Time) *UsedRecord {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if elem, ok := l.index[recordID]; ok {
+		used := *elem.Value.(*UsedRecord)
+		return &used
+	}
+	l.add(&UsedRecord{RecordID: recordID, UserID: userID, RoomID: roomID, UsedAt: now.UnixMilli()})
+	l.dirty = true
+	return nil
+}
+
+// Prune 删除使用时间早于 before 的登记，返回删除数量
+func (l *RecordLedger) Prune(before time.Time) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	cutoff := before.UnixMilli()
+	n := 0
+	for elem := l.order.Front(); elem != nil; {
+		next := elem.Next()
+		if used := elem.Value.(*UsedRecord); used.UsedAt < cutoff {
+			l.order.Remove(elem)
+			delete(l.index, used.RecordID)
+			n++
+		}
+		elem = next
+	}
+	if n > 0 {
+		l.dirty = true
+	}
+	return n
+}
+
+// PurgeUser 删除指定用户的全部登记，返回删除数量
+func (l *RecordLedger) PurgeUser(userID int32) int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	n := 0
+	for elem := l.order.Front(); elem != nil; {
+		next := elem.Next()
+		if used := elem.Value.(*UsedRecord); used.UserID == userID {
+			l.order.Remove(elem)
+			delete(l.index, used.RecordID)
+			n++
+		}
+		elem = next
+	}
+	if n > 0 {
+		l.dirty = true
+	}
+	return n
+}
+
+// Len 当前登记的成绩数量
+func (l *RecordLedger) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.order.Len()
+}
+
+// add 追加记录并按容量淘汰，调用方需持有锁
+func (l *RecordLedger) add(used *UsedRecord) {
+	l.index[used.RecordID] = l.order.PushBack(used)
+	for l.order.Len() > l.capacity {
+		oldest := l.order.Front()
+		l.order.Remove(oldest)
+		delete(l.index, oldest.Value.(*UsedRecord).RecordID)
+	}
+}
+
+// Load 从文件加载
+func (l *RecordLedger) Load() error {
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var records []*UsedRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, used := range records {
+		if _, ok := l.index[used.RecordID]; !ok {
+			l.add(used)
+		}
+	}
+	return nil
+}
+
+// Save 保存到文件（无变更时跳过）
+func (l *RecordLedger) Save() error {
+	l.saveMu.Lock()
+	defer l.saveMu.Unlock()
+	if l.Frozen() {
+		return nil
+	}
+
+	l.mu.Lock()
+	if !l.dirty {
+		l.mu.Unlock()
+		return nil
+	}
+	records := make([]*UsedRecord, 0, l.order.Len())
+	for elem := l.order.Front(); elem != nil; elem = elem.Next() {
+		records = append(records, elem.Value.(*UsedRecord))
+	}
+	data, err := json.Marshal(records)
+	l.dirty = false
+	l.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(l.path, data, false); err != nil {
+		l.markDirty()
+		return err
+	}
+	return nil
+}
+
+// markDirty 标记有未保存的修改（保存失败时调用，下次保存重试）
+func (l *RecordLedger) markDirty() {
+	l.mu.Lock()
+	l.dirty = true
+	l.mu.Unlock()
+}
+
+// getRecordLedgerPath 获取已使用成绩文件路径
+func (s *Server) getRecordLedgerPath() string {
+	if s.config.RecordLedgerPath != "" {
+		return s.config.RecordLedgerPath
+	}
+	if home := os.Getenv("PHIRA_MP_HOME"); home != "" {
+		return filepath.Join(home, "used_records.json")
+	}
+	return "used_records.json"
+}
+
+// GetRecordLedger 获取已使用成绩登记表（未启用时为nil）
+func (s *Server) GetRecordLedger() *RecordLedger {
+	return s.recordLedger
+}
+
+// consumeRecord 登记玩家使用的成绩，重复使用时返回false
+func (s *Server) consumeRecord(room *Room, user *User, record *Record) bool {
+	if s.recordLedger == nil {
+		return true
+	}
+	used := s.recordLedger.Consume(record.ID, user.ID, room.ID.Value, time.Now())
+	if used == nil {
+		return true
+	}
+	log.Printf("[安全] 用户 `%s(%d)` 在房间 `%s` 重复使用成绩 %d（已于 %s 在房间 `%s` 使用）",
+		user.Name, user.ID, room.ID.Value, record.ID,
+		time.UnixMilli(used.UsedAt).Format("2006-01-02 15:04:05"), used.RoomID)
+	return false
+}
+
+// recordLedgerLoop 定期保存已使用成绩
+func (s *Server) recordLedgerLoop() {
+	if s.recordLedger == nil {
+		return
+	}
+	ticker := time.NewTicker(recordLedgerSaveInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case <-ticker.C:
+			if err := s.recordLedger.Save(); err != nil {
+				log.Printf("保存已使用成绩失败: %v", err)
+			}
+		}
+	}
+}
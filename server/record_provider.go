@@ -0,0 +1,132 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RecordProvider 成绩来源，handlePlayed 通过它校验玩家上传的成绩ID
+type RecordProvider interface {
+	FetchRecord(recordID int64) (*Record, error)
+}
+
+// RecordProviderConfig 第三方成绩服务配置
+type RecordProviderConfig struct {
+	URL              string `yaml:"url"`               // 成绩查询地址，{id} 会被替换为成绩ID，如 https://scores.example.com/api/record/{id}
+	APIKey           string `yaml:"api_key"`           // API密钥
+	APIKeyHeader     string `yaml:"api_key_header"`    // 携带API密钥的请求头（默认 Authorization，值为 Bearer <api_key>）
+	Timeout          int    `yaml:"timeout"`           // 请求超时秒数（默认10）
+	FallbackOfficial bool   `yaml:"fallback_official"` // 第三方服务查询失败时回退到Phira主站
+}
+
+// Enabled 是否配置了第三方成绩服务
+func (c RecordProviderConfig) Enabled() bool {
+	return c.URL != ""
+}
+
+// PhiraRecordProvider Phira主站成绩
+type PhiraRecordProvider struct{}
+
+// FetchRecord 从Phira主站获取成绩
+func (PhiraRecordProvider) FetchRecord(recordID int64) (*Record, error) {
+	return FetchRecord(recordID)
+}
+
+// HTTPRecordProvider 第三方成绩服务（返回与Phira主站 /record/{id} 相同格式的JSON）
+type HTTPRecordProvider struct {
+	config RecordProviderConfig
+	client *http.Client
+}
+
+// NewHTTPRecordProvider 创建第三方成绩服务客户端
+func NewHTTPRecordProvider(config RecordProviderConfig) *HTTPRecordProvider {
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultPhiraAPITimeout
+	}
+	if config.APIKeyHeader == "" {
+		config.APIKeyHeader = "Authorization"
+	}
+	return &HTTPRecordProvider{
+		config: config,
+		client: &http.Client{Timeout: time.Duration(config.Timeout) * time.Second},
+	}
+}
+
+// FetchRecord 从第三方成绩服务获取成绩
+func (p *HTTPRecordProvider) FetchRecord(recordID int64) (*Record, error) {
+	req, err := http.NewRequest(http.MethodGet, strings.ReplaceAll(p.config.URL, "{id}", strconv.Itoa(int(recordID))), nil)
+	if err != nil {
+		return nil, err
+	}
+	if p.config.APIKey != "" {
+		value := p.config.APIKey
+		if strings.EqualFold(p.config.APIKeyHeader, "Authorization") {
+			value = "Bearer " + value
+		}
+		req.Header.Set(p.config.APIKeyHeader, value)
+	}
+
+	// 与主站API共用指标，便于在 /metrics 中对比
+	stats := upstream.endpoint("record_provider")
+	start := time.Now()
+	resp, err := p.client.Do(req)
+	if err != nil {
+		stats.observe(time.Since(start), true)
+		return nil, err
+	}
+	defer resp.Body.Close()
+	stats.observe(time.Since(start), resp.StatusCode >= 500)
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("record not found: %d", resp.StatusCode)
+	}
+
+	var record Record
+	if err := json.NewDecoder(resp.Body).Decode(&record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// fallbackRecordProvider 依次尝试多个成绩来源
+type fallbackRecordProvider []RecordProvider
+
+func (providers fallbackRecordProvider) FetchRecord(recordID int64) (*Record, error) {
+	var lastErr error
+	for _, provider := range providers {
+		record, err := provider.FetchRecord(recordID)
+		if err == nil {
+			return record, nil
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+// newRecordProvider 根据配置创建成绩来源
+func newRecordProvider(config RecordProviderConfig) RecordProvider {
+	if !config.Enabled() {
+		return PhiraRecordProvider{}
+	}
+	log.Printf("使用第三方成绩服务: %s", config.URL)
+	provider := NewHTTPRecordProvider(config)
+	if config.FallbackOfficial {
+		return fallbackRecordProvider{provider, PhiraRecordProvider{}}
+	}
+	return provider
+}
+
+// GetRecordProvider 获取成绩来源
+func (s *Server) GetRecordProvider() RecordProvider {
+	return s.recordProvider
+}
+
+// SetRecordProvider 替换成绩来源（嵌入使用时可接入自定义后端）
+func (s *Server) SetRecordProvider(provider RecordProvider) {
+	s.recordProvider = provider
+}
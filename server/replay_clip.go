@@ -0,0 +1,159 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// 回放数据流中的记录类型
+const (
+	replayRecordTouch byte = 0x01 // 触摸帧
+	replayRecordJudge byte = 0x02 // 判定事件
+)
+
+// replayHeaderSize 回放文件头长度
+const replayHeaderSize = 14
+
+// MaxReplayClipsPerReplay 单个回放最多生成的片段数
+const MaxReplayClipsPerReplay = 20
+
+// ClipReplayFile 截取回放文件中 [start, end] 秒内的记录写入新文件
+// 文件头原样保留；返回值：写入的记录数
+func ClipReplayFile(src, dst string, start, end float64) (int, error) {
+	return clipReplayFile(src, dst, start, end, nil)
+}
+
+// clipReplayFile 截取回放片段，c 非nil时源文件可以是加密的，生成的片段同样加密
+func clipReplayFile(src, dst string, start, end float64, c *ReplayCipher) (int, error) {
+	data, err := ReadReplayFile(src, c)
+	if err != nil {
+		return 0, err
+	}
+	reader := bufio.NewReader(bytes.NewReader(data))
+
+	header := make([]byte, replayHeaderSize)
+	if _, err := io.ReadFull(reader, header); err != nil {
+		return 0, fmt.Errorf("读取回放文件头失败: %w", err)
+	}
+	if binary.LittleEndian.Uint16(header[0:2]) != replayMagic {
+		return 0, fmt.Errorf("无效的回放文件")
+	}
+
+	var out bytes.Buffer
+	writer := bufio.NewWriter(&out)
+	written, err := clipReplayRecords(reader, writer, header, start, end)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	clip := out.Bytes()
+	if c != nil {
+		if clip, err = c.Seal(clip); err != nil {
+			return 0, err
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+		return 0, err
+	}
+	if err := os.WriteFile(dst, clip, 0644); err != nil {
+		os.Remove(dst)
+		return 0, err
+	}
+	return written, nil
+}
+
+// clipReplayRecords 逐条复制时间范围内的记录
+func clipReplayRecords(r *bufio.Reader, w *bufio.Writer, header []byte, start, end float64) (int, error) {
+	if _, err := w.Write(header); err != nil {
+		return 0, err
+	}
+
+	written := 0
+	lengthBytes := make([]byte, binary.MaxVarintLen64)
+	for {
+		kind, err := r.ReadByte()
+		if err == io.EOF {
+			return written, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		length, err := binary.ReadUvarint(r)
+		if err != nil {
+			return 0, fmt.Errorf("回放数据损坏: %w", err)
+		}
+		data := make([]byte, length)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return 0, fmt.Errorf("回放数据损坏: %w", err)
+		}
+
+		// 触摸帧与判定事件的前4字节均为时间
+		if (kind != replayRecordTouch && kind != replayRecordJudge) || len(data) < 4 {
+			continue
+		}
+		t := float64(binary.LittleEndian.Uint32(data[0:4]))
+		if t < start || t > end {
+			continue
+		}
+
+		n := binary.PutUvarint(lengthBytes, length)
+		w.WriteByte(kind)
+		w.Write(lengthBytes[:n])
+		if _, err := w.Write(data); err != nil {
+			return 0, err
+		}
+		written++
+	}
+}
+
+// ClipReplay 从已存储的回放中截取片段，作为新回放登记到索引
+func (r *ReplayRecorder) ClipReplay(id string, start, end float64) (*ReplayEntry, int, error) {
+	source := r.index.Get(id)
+	if source == nil {
+		return nil, 0, fmt.Errorf("回放不存在")
+	}
+	if r.index.CountClips(id) >= MaxReplayClipsPerReplay {
+		return nil, 0, fmt.Errorf("片段数量已达上限")
+	}
+
+	timestamp := time.Now().UnixMilli()
+	path := replayPath(ReplayDir, source.UserID, source.ChartID, timestamp)
+	records, err := clipReplayFile(source.Path, path, start, end, r.cipher)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	entry := &ReplayEntry{
+		UserID:    source.UserID,
+		ChartID:   source.ChartID,
+		Timestamp: timestamp,
+		RecordID:  source.RecordID,
+		Path:      path,
+		ClipOf:    source.ID,
+		ClipStart: start,
+		ClipEnd:   end,
+		Encrypted: r.cipher != nil,
+	}
+	if r.storage != nil {
+		if err := r.uploadReplay(entry); err != nil {
+			log.Printf("上传回放片段 %s 失败: %v", entry.Path, err)
+		}
+	}
+	entry.ID = r.index.Put(entry)
+	if err := r.index.Save(); err != nil {
+		log.Printf("保存回放索引失败: %v", err)
+	}
+
+	log.Printf("生成回放片段: %s [%.0fs, %.0fs] -> %s (%d 条记录)", source.ID, start, end, entry.ID, records)
+	return entry, records, nil
+}
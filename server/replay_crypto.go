@@ -0,0 +1,171 @@
+package server
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// 回放文件标识（文件头前2字节，小端序）
+const (
+	replayMagic          = 0x504D // 明文回放
+	replayMagicEncrypted = 0x454D // 加密回放：文件头其余字段仍为明文，之后为随机数与 AES-GCM 密文
+)
+
+// replayNonceSize AES-GCM 随机数长度
+const replayNonceSize = 12
+
+// replayKeyFetchTimeout 从 key_url 获取密钥的超时时间
+const replayKeyFetchTimeout = 10 * time.Second
+
+// ReplayEncryptionConfig 回放文件静态加密（AES-GCM），密钥为base64编码的16、24或32字节
+// 加密后的文件格式：14字节文件头（标识为 0x454D，作为附加认证数据）+ 12字节随机数 + 记录数据的密文
+type ReplayEncryptionConfig struct {
+	Key    string `yaml:"key"`     // base64编码的密钥
+	KeyURL string `yaml:"key_url"` // 启动时以GET请求获取密钥（响应体为base64编码的密钥，如KMS或密钥服务地址），优先于key
+}
+
+// Enabled 是否配置了回放加密
+func (c ReplayEncryptionConfig) Enabled() bool {
+	return c.Key != "" || c.KeyURL != ""
+}
+
+// ReplayCipher 回放文件加解密
+type ReplayCipher struct {
+	aead cipher.AEAD
+}
+
+// NewReplayCipher 由密钥创建回放加解密器（密钥长度16、24或32字节）
+func NewReplayCipher(key []byte) (*ReplayCipher, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &ReplayCipher{aead: aead}, nil
+}
+
+// LoadReplayCipher 按配置加载密钥并创建加解密器，未配置加密时返回nil
+func LoadReplayCipher(config ReplayEncryptionConfig) (*ReplayCipher, error) {
+	if !config.Enabled() {
+		return nil, nil
+	}
+	encoded := config.Key
+	if config.KeyURL != "" {
+		fetched, err := fetchReplayKey(config.KeyURL)
+		if err != nil {
+			return nil, err
+		}
+		encoded = fetched
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+	if err != nil {
+		return nil, fmt.Errorf("invalid replay key: %w", err)
+	}
+	return NewReplayCipher(key)
+}
+
+// fetchReplayKey 从密钥服务获取base64编码的密钥
+func fetchReplayKey(url string) (string, error) {
+	client := &http.Client{Timeout: replayKeyFetchTimeout}
+	resp, err := client.Get(url)
+	if err != nil {
+		return "", fmt.Errorf("fetch replay key: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("fetch replay key: HTTP %d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	if err != nil {
+		return "", fmt.Errorf("fetch replay key: %w", err)
+	}
+	return string(body), nil
+}
+
+// isEncryptedReplay 回放数据的文件头是否带有加密标识
+func isEncryptedReplay(data []byte) bool {
+	return len(data) >= 2 && binary.LittleEndian.Uint16(data[0:2]) == replayMagicEncrypted
+}
+
+// Seal 加密明文回放文件的内容
+func (c *ReplayCipher) Seal(data []byte) ([]byte, error) {
+	if len(data) < replayHeaderSize || binary.LittleEndian.Uint16(data[0:2]) != replayMagic {
+		return nil, fmt.Errorf("无效的回放文件")
+	}
+	header := make([]byte, replayHeaderSize, replayHeaderSize+replayNonceSize+len(data)+c.aead.Overhead())
+	copy(header, data[:replayHeaderSize])
+	binary.LittleEndian.PutUint16(header[0:2], replayMagicEncrypted)
+
+	nonce := make([]byte, replayNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append(header, nonce...)
+	return c.aead.Seal(out, nonce, data[replayHeaderSize:], header), nil
+}
+
+// Open 解密加密回放文件的内容，返回明文回放文件的内容
+func (c *ReplayCipher) Open(data []byte) ([]byte, error) {
+	if len(data) < replayHeaderSize+replayNonceSize || !isEncryptedReplay(data) {
+		return nil, fmt.Errorf("无效的加密回放文件")
+	}
+	header := data[:replayHeaderSize]
+	nonce := data[replayHeaderSize : replayHeaderSize+replayNonceSize]
+
+	out := make([]byte, replayHeaderSize, len(data))
+	copy(out, header)
+	binary.LittleEndian.PutUint16(out[0:2], replayMagic)
+	out, err := c.aead.Open(out, nonce, data[replayHeaderSize+replayNonceSize:], header)
+	if err != nil {
+		return nil, fmt.Errorf("解密回放失败（密钥不匹配或文件损坏）: %w", err)
+	}
+	return out, nil
+}
+
+// EncryptFile 原地加密明文回放文件（已加密的文件保持不变）
+func (c *ReplayCipher) EncryptFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if isEncryptedReplay(data) {
+		return nil
+	}
+	sealed, err := c.Seal(data)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, sealed, false)
+}
+
+// ReadReplayFile 读取回放文件，加密的文件使用 c 解密（c 为nil时返回错误）
+func ReadReplayFile(path string, c *ReplayCipher) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return decodeReplayData(data, c)
+}
+
+// decodeReplayData 加密的回放数据使用 c 解密，明文数据原样返回
+func decodeReplayData(data []byte, c *ReplayCipher) ([]byte, error) {
+	if !isEncryptedReplay(data) {
+		return data, nil
+	}
+	if c == nil {
+		return nil, fmt.Errorf("回放已加密，但未配置回放加密密钥")
+	}
+	return c.Open(data)
+}
@@ -0,0 +1,427 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReplayDir 回放文件根目录
+const ReplayDir = "record"
+
+// ReplayIndexPath 回放索引文件路径
+var ReplayIndexPath = filepath.Join(ReplayDir, "index.json")
+
+// ReplayEntry 回放索引条目
+type ReplayEntry struct {
+	ID         string `json:"id"` // 回放ID（创建后不变）
+	UserID     int32  `json:"userId"`
+	ChartID    int64  `json:"chartId"`
+	Timestamp  int64  `json:"timestamp"`
+	RecordID   int64  `json:"recordId"`
+	Path       string `json:"path"`                 // 本地文件路径
+	ObjectKey  string `json:"objectKey,omitempty"`  // 对象存储键（未上传时为空）
+	ObjectURL  string `json:"objectUrl,omitempty"`  // 对象存储地址
+	UploadedAt int64  `json:"uploadedAt,omitempty"` // 上传完成时间（毫秒）
+	Encrypted  bool   `json:"encrypted,omitempty"`  // 文件已加密（见 ReplayEncryptionConfig），下载时由服务器解密
+
+	// 片段信息（由其他回放截取生成时填写）
+	ClipOf    string  `json:"clipOf,omitempty"`    // 源回放ID
+	ClipStart float64 `json:"clipStart,omitempty"` // 截取起始时间（秒）
+	ClipEnd   float64 `json:"clipEnd,omitempty"`   // 截取结束时间（秒）
+}
+
+// ReplayShare 回放分享链接
+type ReplayShare struct {
+	Token     string `json:"token"`
+	ReplayID  string `json:"replayId"`
+	CreatedAt int64  `json:"createdAt"`
+	ExpiresAt int64  `json:"expiresAt,omitempty"` // 过期时间（毫秒），0表示永不过期
+}
+
+// Expired 分享是否已过期
+func (s *ReplayShare) Expired() bool {
+	return s.ExpiresAt > 0 && time.Now().UnixMilli() >= s.ExpiresAt
+}
+
+// ReplayIndex 回放索引
+type ReplayIndex struct {
+	mu      sync.RWMutex
+	saveMu  sync.Mutex
+	path    string
+	Entries map[string]*ReplayEntry `json:"entries"` // 回放ID -> 条目
+	Shares  map[string]*ReplayShare `json:"shares"`  // 分享token -> 分享
+
+	storeFreeze // 平滑升级后停止写文件（见 handover.go）
+}
+
+// NewReplayIndex 创建回放索引
+func NewReplayIndex(path string) *ReplayIndex {
+	return &ReplayIndex{
+		path:    path,
+		Entries: make(map[string]*ReplayEntry),
+		Shares:  make(map[string]*ReplayShare),
+	}
+}
+
+// NewReplayID 生成回放ID
+func NewReplayID() string {
+	return strings.ReplaceAll(generateUUID(), "-", "")
+}
+
+// replayPath 回放文件的标准存放路径: record/{用户ID}/{谱面ID}/{时间戳}.phirarec
+func replayPath(dir string, userID int32, chartID, timestamp int64) string {
+	return filepath.Join(dir, fmt.Sprintf("%d", userID), fmt.Sprintf("%d", chartID), fmt.Sprintf("%d.phirarec", timestamp))
+}
+
+// Load 从文件加载索引
+func (idx *ReplayIndex) Load() error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	data, err := os.ReadFile(idx.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	var loaded struct {
+		Entries map[string]*ReplayEntry `json:"entries"`
+		Shares  map[string]*ReplayShare `json:"shares"`
+	}
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return err
+	}
+
+	// 旧版索引以文件路径为键且没有ID，加载时补齐
+	idx.Entries = make(map[string]*ReplayEntry, len(loaded.Entries))
+	for _, entry := range loaded.Entries {
+		if entry.ID == "" {
+			entry.ID = NewReplayID()
+		}
+		idx.Entries[entry.ID] = entry
+	}
+	idx.Shares = loaded.Shares
+	if idx.Shares == nil {
+		idx.Shares = make(map[string]*ReplayShare)
+	}
+	return nil
+}
+
+// Save 保存索引到文件
+func (idx *ReplayIndex) Save() error {
+	idx.saveMu.Lock()
+	defer idx.saveMu.Unlock()
+	if idx.Frozen() {
+		return nil
+	}
+
+	idx.mu.RLock()
+	data, err := json.MarshalIndent(idx, "", "  ")
+	idx.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	// 先写入临时文件再替换，避免写入中断导致索引损坏
+	return writeFileAtomic(idx.path, data, false)
+}
+
+// Put 添加或更新条目（ID为空时分配新ID）
+// 返回值：条目ID
+func (idx *ReplayIndex) Put(entry *ReplayEntry) string {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	copied := *entry
+	if copied.ID == "" {
+		copied.ID = NewReplayID()
+	}
+	copied.Path = filepath.Clean(entry.Path)
+	idx.Entries[copied.ID] = &copied
+	return copied.ID
+}
+
+// Get 按ID获取条目
+func (idx *ReplayIndex) Get(id string) *ReplayEntry {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	if entry, ok := idx.Entries[id]; ok {
+		copied := *entry
+		return &copied
+	}
+	return nil
+}
+
+// FindByPath 按本地路径查找条目
+func (idx *ReplayIndex) FindByPath(path string) *ReplayEntry {
+	path = filepath.Clean(path)
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	for _, entry := range idx.Entries {
+		if entry.Path == path {
+			copied := *entry
+			return &copied
+		}
+	}
+	return nil
+}
+
+// Find 按用户、谱面与时间戳查找条目（兼容旧版下载参数）
+func (idx *ReplayIndex) Find(userID int32, chartID, timestamp int64) *ReplayEntry {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	for _, entry := range idx.Entries {
+		if entry.UserID == userID && entry.ChartID == chartID && entry.Timestamp == timestamp {
+			copied := *entry
+			return &copied
+		}
+	}
+	return nil
+}
+
+// Remove 删除条目
+func (idx *ReplayIndex) Remove(id string) *ReplayEntry {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	entry, ok := idx.Entries[id]
+	if !ok {
+		return nil
+	}
+	delete(idx.Entries, id)
+
+	// 回放删除后分享链接一并失效
+	for token, share := range idx.Shares {
+		if share.ReplayID == id {
+			delete(idx.Shares, token)
+		}
+	}
+	return entry
+}
+
+// CountClips 统计由指定回放截取生成的片段数
+func (idx *ReplayIndex) CountClips(id string) int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	count := 0
+	for _, entry := range idx.Entries {
+		if entry.ClipOf == id {
+			count++
+		}
+	}
+	return count
+}
+
+// AddShare 为回放创建分享链接
+// expires 为0时永不过期
+func (idx *ReplayIndex) AddShare(replayID string, expires time.Duration) *ReplayShare {
+	now := time.Now()
+	share := &ReplayShare{
+		Token:     NewReplayID(),
+		ReplayID:  replayID,
+		CreatedAt: now.UnixMilli(),
+	}
+	if expires > 0 {
+		share.ExpiresAt = now.Add(expires).UnixMilli()
+	}
+
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.Shares[share.Token] = share
+	copied := *share
+	return &copied
+}
+
+// GetShare 获取未过期的分享链接
+func (idx *ReplayIndex) GetShare(token string) *ReplayShare {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	share, ok := idx.Shares[token]
+	if !ok || share.Expired() {
+		return nil
+	}
+	copied := *share
+	return &copied
+}
+
+// RemoveShare 撤销分享链接
+func (idx *ReplayIndex) RemoveShare(token string) *ReplayShare {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	share, ok := idx.Shares[token]
+	if !ok {
+		return nil
+	}
+	delete(idx.Shares, token)
+	return share
+}
+
+// PruneShares 清理已过期的分享链接
+// 返回值：清理的数量
+func (idx *ReplayIndex) PruneShares() int {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	pruned := 0
+	for token, share := range idx.Shares {
+		if share.Expired() {
+			delete(idx.Shares, token)
+			pruned++
+		}
+	}
+	return pruned
+}
+
+// List 获取所有条目
+func (idx *ReplayIndex) List() []ReplayEntry {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	result := make([]ReplayEntry, 0, len(idx.Entries))
+	for _, entry := range idx.Entries {
+		result = append(result, *entry)
+	}
+	return result
+}
+
+// ListUser 获取用户的所有条目（按时间倒序）
+func (idx *ReplayIndex) ListUser(userID int32) []ReplayEntry {
+	idx.mu.RLock()
+	result := make([]ReplayEntry, 0)
+	for _, entry := range idx.Entries {
+		if entry.UserID == userID {
+			result = append(result, *entry)
+		}
+	}
+	idx.mu.RUnlock()
+
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Timestamp > result[j].Timestamp
+	})
+	return result
+}
+
+// Migrate 扫描回放目录，将旧版平铺存放的文件移动到标准路径，并为未登记的文件分配ID
+// 返回值：新登记的回放数量
+func (idx *ReplayIndex) Migrate(dir string) int {
+	userDirs, err := os.ReadDir(dir)
+	if err != nil {
+		return 0
+	}
+
+	// 已登记的路径只收集一次，避免逐个文件遍历索引
+	known := make(map[string]bool)
+	idx.mu.RLock()
+	for _, entry := range idx.Entries {
+		known[entry.Path] = true
+	}
+	idx.mu.RUnlock()
+
+	added := 0
+	for _, userDir := range userDirs {
+		if !userDir.IsDir() {
+			continue
+		}
+		userPath := filepath.Join(dir, userDir.Name())
+		entries, err := os.ReadDir(userPath)
+		if err != nil {
+			continue
+		}
+
+		for _, e := range entries {
+			var files []string
+			if e.IsDir() {
+				// 标准布局: {用户ID}/{谱面ID}/{时间戳}.phirarec
+				chartFiles, err := os.ReadDir(filepath.Join(userPath, e.Name()))
+				if err != nil {
+					continue
+				}
+				for _, f := range chartFiles {
+					files = append(files, filepath.Join(userPath, e.Name(), f.Name()))
+				}
+			} else {
+				// 旧版布局: {用户ID}/{时间戳}.phirarec
+				files = append(files, filepath.Join(userPath, e.Name()))
+			}
+
+			for _, file := range files {
+				if idx.migrateFile(dir, file, known) {
+					added++
+				}
+			}
+		}
+	}
+	return added
+}
+
+// EncryptPlaintext 加密索引中未加密的本地回放文件（文件不存在的条目跳过）
+// 返回值：加密的文件数量
+func (idx *ReplayIndex) EncryptPlaintext(c *ReplayCipher) int {
+	idx.mu.RLock()
+	var pending []*ReplayEntry
+	for _, entry := range idx.Entries {
+		if !entry.Encrypted && entry.Path != "" {
+			pending = append(pending, entry)
+		}
+	}
+	idx.mu.RUnlock()
+
+	encrypted := 0
+	for _, entry := range pending {
+		if err := c.EncryptFile(entry.Path); err != nil {
+			if !os.IsNotExist(err) {
+				log.Printf("加密回放 %s 失败: %v", entry.Path, err)
+			}
+			continue
+		}
+		idx.mu.Lock()
+		entry.Encrypted = true
+		idx.mu.Unlock()
+		encrypted++
+	}
+	return encrypted
+}
+
+// migrateFile 登记单个回放文件，必要时移动到标准路径（known 为已登记的路径，登记后加入）
+func (idx *ReplayIndex) migrateFile(dir, file string, known map[string]bool) bool {
+	if !strings.HasSuffix(file, ".phirarec") || known[filepath.Clean(file)] {
+		return false
+	}
+
+	chartID, userID, recordID, encrypted, ok := readReplayHeader(file)
+	if !ok {
+		return false
+	}
+	timestamp, err := strconv.ParseInt(strings.TrimSuffix(filepath.Base(file), ".phirarec"), 10, 64)
+	if err != nil {
+		return false
+	}
+
+	target := replayPath(dir, userID, chartID, timestamp)
+	if filepath.Clean(file) != target {
+		if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+			return false
+		}
+		if err := os.Rename(file, target); err != nil {
+			log.Printf("迁移回放文件 %s 失败: %v", file, err)
+			return false
+		}
+		log.Printf("迁移回放文件: %s -> %s", file, target)
+	}
+
+	idx.Put(&ReplayEntry{
+		UserID:    userID,
+		ChartID:   chartID,
+		Timestamp: timestamp,
+		RecordID:  recordID,
+		Path:      target,
+		Encrypted: encrypted,
+	})
+	known[target] = true
+	return true
+}
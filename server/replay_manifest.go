@@ -0,0 +1,187 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// GameManifestVersion 对局清单的格式版本
+const GameManifestVersion = 1
+
+// gameManifestDir 对局清单在回放目录下的子目录
+const gameManifestDir = "games"
+
+// GameManifest 对局清单：一局多人对局的房间、谱面、参与玩家、最终成绩与各玩家的回放文件，
+// 保存在 {回放目录}/games/{房间ID}/{开始时间}/manifest.json，下游工具无需查询服务器即可还原整局
+type GameManifest struct {
+	Version      int                   `json:"version"`
+	RoomID       string                `json:"room_id"`
+	ChartID      int64                 `json:"chart_id"`
+	ChartName    string                `json:"chart_name,omitempty"`
+	StartedAt    int64                 `json:"started_at"`           // 开始录制时间（Unix毫秒）
+	EndedAt      int64                 `json:"ended_at"`             // 对局结束或录制中断时间（Unix毫秒）
+	Completed    bool                  `json:"completed"`            // 对局正常结束（房间解散或服务器关闭时中断为false，没有成绩）
+	Aggregator   string                `json:"aggregator,omitempty"` // 排名策略
+	Participants []ManifestParticipant `json:"participants"`
+}
+
+// ManifestParticipant 对局清单中的玩家
+type ManifestParticipant struct {
+	UserID   int32           `json:"user_id"`
+	Name     string          `json:"name"`
+	RecordID int64           `json:"record_id,omitempty"`
+	Result   *RankEntry      `json:"result,omitempty"` // 最终成绩（未提交成绩时为空）
+	Aborted  bool            `json:"aborted,omitempty"`
+	Replay   *ManifestReplay `json:"replay,omitempty"` // 回放文件（未录制或录制失败时为空）
+}
+
+// ManifestReplay 对局清单引用的回放文件
+type ManifestReplay struct {
+	ID        string `json:"id"`   // 回放ID（见回放索引）
+	File      string `json:"file"` // 相对于回放目录的路径（以 / 分隔）
+	Size      int64  `json:"size"`
+	SHA256    string `json:"sha256"`              // 文件内容（加密后）的SHA-256
+	Encrypted bool   `json:"encrypted,omitempty"` // 文件已加密，需由服务器解密
+	ObjectURL string `json:"object_url,omitempty"`
+}
+
+// participant 按用户ID查找玩家，不存在时追加
+func (m *GameManifest) participant(userID int32, name string) *ManifestParticipant {
+	for i := range m.Participants {
+		if m.Participants[i].UserID == userID {
+			return &m.Participants[i]
+		}
+	}
+	m.Participants = append(m.Participants, ManifestParticipant{UserID: userID, Name: name})
+	return &m.Participants[len(m.Participants)-1]
+}
+
+// SetResults 按本局结算填写各玩家的最终成绩并标记对局正常结束
+func (m *GameManifest) SetResults(summary *GameSummary) {
+	m.Completed = true
+	m.EndedAt = summary.EndedAt
+	m.Aggregator = summary.Aggregator
+	for _, entry := range summary.Ranking {
+		result := entry
+		m.participant(entry.UserID, entry.Name).Result = &result
+	}
+	for _, userID := range summary.Aborted {
+		m.participant(userID, "").Aborted = true
+	}
+}
+
+// AddReplay 登记玩家的回放文件，计算文件大小与校验和（dir 为回放目录）
+func (m *GameManifest) AddReplay(dir string, name string, entry *ReplayEntry) error {
+	file, err := os.Open(entry.Path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return err
+	}
+
+	rel, err := filepath.Rel(dir, entry.Path)
+	if err != nil {
+		rel = filepath.Base(entry.Path)
+	}
+	p := m.participant(entry.UserID, name)
+	p.RecordID = entry.RecordID
+	p.Replay = &ManifestReplay{
+		ID:        entry.ID,
+		File:      filepath.ToSlash(rel),
+		Size:      size,
+		SHA256:    hex.EncodeToString(hash.Sum(nil)),
+		Encrypted: entry.Encrypted,
+		ObjectURL: entry.ObjectURL,
+	}
+	return nil
+}
+
+// Path 对局清单的文件路径（dir 为回放目录）
+func (m *GameManifest) Path(dir string) string {
+	return filepath.Join(dir, gameManifestDir, m.RoomID, strconv.FormatInt(m.StartedAt, 10), "manifest.json")
+}
+
+// Save 写入对局清单（dir 为回放目录）
+func (m *GameManifest) Save(dir string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(m.Path(dir), data, false)
+}
+
+// LoadGameManifest 读取对局清单
+func LoadGameManifest(path string) (*GameManifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m GameManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// gameManifests 回放目录下所有对局清单的路径
+func gameManifests(dir string) []string {
+	paths, _ := filepath.Glob(filepath.Join(dir, gameManifestDir, "*", "*", "manifest.json"))
+	return paths
+}
+
+// PruneGameManifests 删除开始时间早于 cutoff 的对局清单，返回删除数量
+func PruneGameManifests(dir string, cutoff time.Time) int {
+	n := 0
+	for _, path := range gameManifests(dir) {
+		gameDir := filepath.Dir(path)
+		startedAt, err := strconv.ParseInt(filepath.Base(gameDir), 10, 64)
+		if err != nil || !time.UnixMilli(startedAt).Before(cutoff) {
+			continue
+		}
+		if os.RemoveAll(gameDir) == nil {
+			n++
+			// 房间目录为空时一并删除（不为空时删除失败，忽略）
+			os.Remove(filepath.Dir(gameDir))
+		}
+	}
+	return n
+}
+
+// PurgeGameManifestUser 从对局清单中移除用户（其他玩家不变），移除后没有玩家的清单整个删除
+// 返回涉及的清单数量
+func PurgeGameManifestUser(dir string, userID int32) int {
+	n := 0
+	for _, path := range gameManifests(dir) {
+		m, err := LoadGameManifest(path)
+		if err != nil {
+			continue
+		}
+		kept := m.Participants[:0]
+		for _, p := range m.Participants {
+			if p.UserID != userID {
+				kept = append(kept, p)
+			}
+		}
+		if len(kept) == len(m.Participants) {
+			continue
+		}
+		m.Participants = kept
+		if len(kept) == 0 {
+			os.RemoveAll(filepath.Dir(path))
+		} else if err := m.Save(dir); err != nil {
+			continue
+		}
+		n++
+	}
+	return n
+}
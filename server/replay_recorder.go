@@ -4,6 +4,7 @@ import (
 	"encoding/binary"
 	"fmt"
 	"log"
+	"math"
 	"os"
 	"path/filepath"
 	"strconv"
@@ -20,24 +21,82 @@ type ReplayRecorder struct {
 
 	// 房间录制记录 roomId -> *RoomRecorder
 	roomRecorders map[string]*RoomRecorder
+	// 录制中对局的清单 roomId -> *GameManifest
+	manifests map[string]*GameManifest
 
 	httpServer *HTTPServer
+
+	// 回放索引与对象存储（未配置对象存储时storage为nil）
+	index   *ReplayIndex
+	storage *ObjectStorage
+
+	// 回放加密（未配置时cipher为nil；配置了但密钥不可用时cipherErr非nil，此时不录制回放）
+	cipher    *ReplayCipher
+	cipherErr error
 }
 
 // RoomRecorder 房间录制器
 type RoomRecorder struct {
-	RoomID   string
-	ChartID  int32
-	File     *os.File
-	FilePath string
-	mu       sync.Mutex
+	RoomID    string
+	ChartID   int64
+	UserID    int32
+	Name      string
+	RecordID  int64
+	Timestamp int64
+	File      *os.File
+	FilePath  string
+	mu        sync.Mutex
 }
 
 // NewReplayRecorder 创建回放录制器
 func NewReplayRecorder(httpServer *HTTPServer) *ReplayRecorder {
 	r := &ReplayRecorder{
 		roomRecorders: make(map[string]*RoomRecorder),
+		manifests:     make(map[string]*GameManifest),
 		httpServer:    httpServer,
+		index:         NewReplayIndex(ReplayIndexPath),
+	}
+
+	if err := r.index.Load(); err != nil {
+		log.Printf("加载回放索引失败: %v", err)
+	}
+
+	// 配置了回放加密时，录制完成的回放加密后再登记与上传
+	if httpServer != nil && httpServer.server.config.ReplayEncryption.Enabled() {
+		r.cipher, r.cipherErr = LoadReplayCipher(httpServer.server.config.ReplayEncryption)
+		if r.cipherErr != nil {
+			log.Printf("回放加密密钥不可用，回放录制已停用: %v", r.cipherErr)
+		}
+	}
+
+	// 登记旧版本或异常退出遗留的回放文件
+	changed := false
+	if added := r.index.Migrate(ReplayDir); added > 0 {
+		log.Printf("回放索引已登记 %d 个旧回放文件", added)
+		changed = true
+	}
+	// 录制中的回放是明文，异常退出后遗留的明文回放在启动时加密
+	// （平滑升级启动时旧进程可能仍在录制，留到下次正常启动时处理）
+	if r.cipher != nil && !startedByHandover() {
+		if encrypted := r.index.EncryptPlaintext(r.cipher); encrypted > 0 {
+			log.Printf("已加密 %d 个明文回放文件", encrypted)
+			changed = true
+		}
+	}
+	if changed {
+		if err := r.index.Save(); err != nil {
+			log.Printf("保存回放索引失败: %v", err)
+		}
+	}
+
+	// 配置了对象存储时，录制完成的回放上传至对象存储
+	if httpServer != nil && httpServer.server.config.ReplayStorage.Enabled() {
+		storage, err := NewObjectStorage(httpServer.server.config.ReplayStorage)
+		if err != nil {
+			log.Printf("回放对象存储配置无效: %v", err)
+		} else {
+			r.storage = storage
+		}
 	}
 
 	// 启动清理协程
@@ -51,6 +110,9 @@ func (r *ReplayRecorder) StartRecording(room *Room) error {
 	if r.httpServer == nil || !r.httpServer.IsReplayEnabled() {
 		return nil
 	}
+	if r.cipherErr != nil {
+		return fmt.Errorf("replay encryption unavailable: %w", r.cipherErr)
+	}
 
 	chart := room.GetChart()
 	if chart == nil {
@@ -65,32 +127,49 @@ func (r *ReplayRecorder) StartRecording(room *Room) error {
 		return nil
 	}
 
-	// 为每个用户创建录制文件
+	manifest := &GameManifest{
+		Version:   GameManifestVersion,
+		RoomID:    room.ID.Value,
+		ChartID:   chart.ID,
+		ChartName: chart.Name,
+		StartedAt: time.Now().UnixMilli(),
+	}
+
+	// 为每个用户创建录制文件（模拟玩家不录制）
 	for _, user := range room.GetUsers() {
+		if user.IsBot() {
+			continue
+		}
+		manifest.participant(user.ID, user.Name)
 		recorder, err := r.createRecorder(room.ID.Value, chart.ID, user.ID)
 		if err != nil {
 			log.Printf("创建回放录制文件失败: %v", err)
 			continue
 		}
+		recorder.Name = user.Name
 		r.roomRecorders[fmt.Sprintf("%s_%d", room.ID.Value, user.ID)] = recorder
 	}
+	r.manifests[room.ID.Value] = manifest
 
 	log.Printf("开始录制房间 %s 的回放", room.ID.Value)
 	return nil
 }
 
 // createRecorder 创建录制器
-func (r *ReplayRecorder) createRecorder(roomID string, chartID, userID int32) (*RoomRecorder, error) {
+func (r *ReplayRecorder) createRecorder(roomID string, chartID int64, userID int32) (*RoomRecorder, error) {
+	// 文件头中的谱面ID为4字节，超出范围的谱面不录制
+	if chartID < math.MinInt32 || chartID > math.MaxInt32 {
+		return nil, fmt.Errorf("谱面ID %d 超出回放文件格式的范围", chartID)
+	}
 	timestamp := time.Now().UnixMilli()
 
 	// 创建目录
-	dir := filepath.Join("record", fmt.Sprintf("%d", userID), fmt.Sprintf("%d", chartID))
-	if err := os.MkdirAll(dir, 0755); err != nil {
+	filePath := replayPath(ReplayDir, userID, chartID, timestamp)
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
 		return nil, err
 	}
 
 	// 创建文件
-	filePath := filepath.Join(dir, fmt.Sprintf("%d.phirarec", timestamp))
 	file, err := os.Create(filePath)
 	if err != nil {
 		return nil, err
@@ -102,7 +181,7 @@ func (r *ReplayRecorder) createRecorder(roomID string, chartID, userID int32) (*
 	// 4字节: 用户ID
 	// 4字节: 成绩ID (初始为0)
 	header := make([]byte, 14)
-	binary.LittleEndian.PutUint16(header[0:2], 0x504D)
+	binary.LittleEndian.PutUint16(header[0:2], replayMagic)
 	binary.LittleEndian.PutUint32(header[2:6], uint32(chartID))
 	binary.LittleEndian.PutUint32(header[6:10], uint32(userID))
 	binary.LittleEndian.PutUint32(header[10:14], 0) // 成绩ID，游戏结束后再更新
@@ -114,10 +193,12 @@ func (r *ReplayRecorder) createRecorder(roomID string, chartID, userID int32) (*
 	}
 
 	return &RoomRecorder{
-		RoomID:   roomID,
-		ChartID:  chartID,
-		File:     file,
-		FilePath: filePath,
+		RoomID:    roomID,
+		ChartID:   chartID,
+		UserID:    userID,
+		Timestamp: timestamp,
+		File:      file,
+		FilePath:  filePath,
 	}, nil
 }
 
@@ -139,7 +220,7 @@ func (r *ReplayRecorder) RecordTouch(roomID string, userID int32, frames []commo
 	// 命令类型: 0x01 = TouchFrame
 	for _, frame := range frames {
 		// 写入命令类型
-		if _, err := recorder.File.Write([]byte{0x01}); err != nil {
+		if _, err := recorder.File.Write([]byte{replayRecordTouch}); err != nil {
 			log.Printf("写入回放数据失败: %v", err)
 			return
 		}
@@ -180,7 +261,7 @@ func (r *ReplayRecorder) RecordJudge(roomID string, userID int32, judges []commo
 	// 命令类型: 0x02 = JudgeEvent
 	for _, judge := range judges {
 		// 写入命令类型
-		if _, err := recorder.File.Write([]byte{0x02}); err != nil {
+		if _, err := recorder.File.Write([]byte{replayRecordJudge}); err != nil {
 			log.Printf("写入回放数据失败: %v", err)
 			return
 		}
@@ -204,26 +285,69 @@ func (r *ReplayRecorder) RecordJudge(roomID string, userID int32, judges []commo
 	}
 }
 
-// StopRecording 停止录制房间
+// StopRecording 停止录制房间（对局中断，对局清单中没有成绩）
 func (r *ReplayRecorder) StopRecording(roomID string) {
+	r.stopRoom(roomID, nil)
+}
+
+// FinishGame 对局正常结束时停止录制房间，对局清单中写入本局结算
+func (r *ReplayRecorder) FinishGame(roomID string, summary *GameSummary) {
+	r.stopRoom(roomID, summary)
+}
+
+// stopRoom 关闭房间的所有录制器，之后在后台完成回放登记并写入对局清单
+func (r *ReplayRecorder) stopRoom(roomID string, summary *GameSummary) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
 	// 找到并关闭该房间的所有录制器
+	var finished []*RoomRecorder
 	for key, recorder := range r.roomRecorders {
 		if recorder.RoomID == roomID {
 			recorder.mu.Lock()
 			recorder.File.Close()
 			recorder.mu.Unlock()
 			delete(r.roomRecorders, key)
+			finished = append(finished, recorder)
 		}
 	}
+	manifest := r.manifests[roomID]
+	delete(r.manifests, roomID)
+	go r.finishGame(manifest, finished, summary)
 
 	log.Printf("停止录制房间 %s 的回放", roomID)
 }
 
+// finishGame 登记各玩家的回放并写入对局清单（未开始录制时 manifest 为nil，只登记回放）
+func (r *ReplayRecorder) finishGame(manifest *GameManifest, recorders []*RoomRecorder, summary *GameSummary) {
+	if manifest != nil {
+		manifest.EndedAt = time.Now().UnixMilli()
+		if summary != nil {
+			manifest.SetResults(summary)
+		}
+	}
+
+	for _, recorder := range recorders {
+		entry := r.finishReplay(recorder)
+		if entry == nil || manifest == nil {
+			continue
+		}
+		if err := manifest.AddReplay(ReplayDir, recorder.Name, entry); err != nil {
+			log.Printf("计算回放 %s 校验和失败: %v", entry.Path, err)
+		}
+	}
+
+	if manifest == nil {
+		return
+	}
+	if err := manifest.Save(ReplayDir); err != nil {
+		log.Printf("保存房间 %s 的对局清单失败: %v", manifest.RoomID, err)
+	}
+}
+
 // UpdateRecordID 更新录制文件的成绩ID
-func (r *ReplayRecorder) UpdateRecordID(roomID string, userID, recordID int32) {
+// 文件头中的成绩ID为4字节，超出范围时文件头保持为0，完整的成绩ID仅记录在回放索引中
+func (r *ReplayRecorder) UpdateRecordID(roomID string, userID int32, recordID int64) {
 	r.mu.RLock()
 	recorder, ok := r.roomRecorders[fmt.Sprintf("%s_%d", roomID, userID)]
 	r.mu.RUnlock()
@@ -237,11 +361,102 @@ func (r *ReplayRecorder) UpdateRecordID(roomID string, userID, recordID int32) {
 
 	// 更新文件头中的成绩ID (偏移10字节)
 	recordIDBytes := make([]byte, 4)
-	binary.LittleEndian.PutUint32(recordIDBytes, uint32(recordID))
+	binary.LittleEndian.PutUint32(recordIDBytes, uint32(common.LegacyID(recordID)))
 
 	recorder.File.Seek(10, 0)
 	recorder.File.Write(recordIDBytes)
 	recorder.File.Seek(0, 2) // 回到文件末尾
+	recorder.RecordID = recordID
+}
+
+// finishReplay 将录制完成的回放写入索引，配置了对象存储时上传，返回索引条目（加密失败时为nil）
+func (r *ReplayRecorder) finishReplay(recorder *RoomRecorder) *ReplayEntry {
+	entry := &ReplayEntry{
+		UserID:    recorder.UserID,
+		ChartID:   recorder.ChartID,
+		Timestamp: recorder.Timestamp,
+		RecordID:  recorder.RecordID,
+		Path:      recorder.FilePath,
+	}
+
+	if r.cipher != nil {
+		if err := r.cipher.EncryptFile(entry.Path); err != nil {
+			// 不保留未加密的回放
+			log.Printf("加密回放 %s 失败，已删除: %v", entry.Path, err)
+			os.Remove(entry.Path)
+			return nil
+		}
+		entry.Encrypted = true
+	}
+
+	if r.storage != nil {
+		if err := r.uploadReplay(entry); err != nil {
+			log.Printf("上传回放 %s 失败: %v", entry.Path, err)
+		}
+	}
+
+	entry.ID = r.index.Put(entry)
+	if err := r.index.Save(); err != nil {
+		log.Printf("保存回放索引失败: %v", err)
+	}
+	return entry
+}
+
+// uploadReplay 上传回放文件到对象存储
+func (r *ReplayRecorder) uploadReplay(entry *ReplayEntry) error {
+	data, err := os.ReadFile(entry.Path)
+	if err != nil {
+		return err
+	}
+
+	rel, err := filepath.Rel(ReplayDir, entry.Path)
+	if err != nil {
+		rel = filepath.Base(entry.Path)
+	}
+	key := r.storage.ObjectKey(filepath.ToSlash(rel))
+	if err := r.storage.PutObject(key, data, "application/octet-stream"); err != nil {
+		return err
+	}
+
+	entry.ObjectKey = key
+	entry.ObjectURL = r.storage.ObjectURL(key)
+	entry.UploadedAt = time.Now().UnixMilli()
+	log.Printf("回放已上传: %s -> %s", entry.Path, entry.ObjectURL)
+	return nil
+}
+
+// GetReplayIndex 获取回放索引
+func (r *ReplayRecorder) GetReplayIndex() *ReplayIndex {
+	return r.index
+}
+
+// GetStorage 获取对象存储（未配置时返回nil）
+func (r *ReplayRecorder) GetStorage() *ObjectStorage {
+	return r.storage
+}
+
+// DeleteReplay 删除回放文件、索引条目及对象存储中的副本
+// 对象存储中的副本删除失败时保留索引条目并返回错误，以便重试
+func (r *ReplayRecorder) DeleteReplay(id string) error {
+	entry := r.index.Remove(id)
+	if entry == nil {
+		return nil
+	}
+	if err := os.Remove(entry.Path); err != nil && !os.IsNotExist(err) {
+		r.index.Put(entry)
+		return err
+	}
+	if entry.ObjectKey != "" && r.storage != nil {
+		if err := r.storage.DeleteObject(entry.ObjectKey); err != nil {
+			log.Printf("删除对象存储中的回放 %s 失败: %v", entry.ObjectKey, err)
+			r.index.Put(entry)
+			return err
+		}
+	}
+	if err := r.index.Save(); err != nil {
+		log.Printf("保存回放索引失败: %v", err)
+	}
+	return nil
 }
 
 // serializeTouchFrame 序列化触摸帧
@@ -295,7 +510,7 @@ func (r *ReplayRecorder) serializeJudgeEvent(judge common.JudgeEvent) []byte {
 	return data
 }
 
-// cleanupLoop 清理循环 - 删除超过4天的回放文件
+// cleanupLoop 清理循环 - 删除超过保留期限（默认4天）的回放文件
 func (r *ReplayRecorder) cleanupLoop() {
 	ticker := time.NewTicker(1 * time.Hour)
 	defer ticker.Stop()
@@ -308,10 +523,37 @@ func (r *ReplayRecorder) cleanupLoop() {
 	}
 }
 
+// retention 回放保留时长（retention.replay_days）
+func (r *ReplayRecorder) retention() time.Duration {
+	if r.httpServer == nil {
+		return RetentionConfig{}.replayRetention()
+	}
+	return r.httpServer.server.config.Retention.replayRetention()
+}
+
 // cleanupOldReplays 清理旧回放文件
 func (r *ReplayRecorder) cleanupOldReplays() {
-	recordDir := "record"
-	
+	cutoffTime := time.Now().Add(-r.retention())
+
+	// 清理索引中的过期回放（包括本地文件已不存在、仅保留在对象存储中的回放）
+	for _, entry := range r.index.List() {
+		if time.UnixMilli(entry.Timestamp).Before(cutoffTime) {
+			r.DeleteReplay(entry.ID)
+		}
+	}
+
+	if n := PruneGameManifests(ReplayDir, cutoffTime); n > 0 {
+		log.Printf("删除过期对局清单 %d 个", n)
+	}
+
+	if r.index.PruneShares() > 0 {
+		if err := r.index.Save(); err != nil {
+			log.Printf("保存回放索引失败: %v", err)
+		}
+	}
+
+	recordDir := ReplayDir
+
 	// 检查目录是否存在
 	if _, err := os.Stat(recordDir); os.IsNotExist(err) {
 		return
@@ -324,8 +566,6 @@ func (r *ReplayRecorder) cleanupOldReplays() {
 		return
 	}
 
-	cutoffTime := time.Now().Add(-4 * 24 * time.Hour) // 4天前
-
 	for _, userDir := range userDirs {
 		if !userDir.IsDir() {
 			continue
@@ -382,14 +622,27 @@ func (r *ReplayRecorder) cleanupOldReplays() {
 
 // StopAllRecordings 停止所有录制
 func (r *ReplayRecorder) StopAllRecordings() {
+	// 在锁内取出所有录制器与对局清单，加密与上传在锁外进行，避免阻塞其他录制操作
 	r.mu.Lock()
-	defer r.mu.Unlock()
-
+	finished := make(map[string][]*RoomRecorder)
 	for key, recorder := range r.roomRecorders {
 		recorder.mu.Lock()
 		recorder.File.Close()
 		recorder.mu.Unlock()
 		delete(r.roomRecorders, key)
+		finished[recorder.RoomID] = append(finished[recorder.RoomID], recorder)
+	}
+	manifests := r.manifests
+	r.manifests = make(map[string]*GameManifest)
+	r.mu.Unlock()
+
+	// 服务器关闭前同步完成索引、上传与对局清单
+	for roomID, manifest := range manifests {
+		r.finishGame(manifest, finished[roomID], nil)
+		delete(finished, roomID)
+	}
+	for _, recorders := range finished {
+		r.finishGame(nil, recorders, nil)
 	}
 
 	log.Printf("停止所有回放录制")
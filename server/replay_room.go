@@ -0,0 +1,546 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"phira-mp/common"
+)
+
+// ErrReplayRoomMonitorOnly 以玩家身份加入回放房间时返回给客户端的错误
+const ErrReplayRoomMonitorOnly = "回放房间只能以观察者身份加入"
+
+// replayPlaybackInterval 回放房间推送触摸帧与判定的间隔
+const replayPlaybackInterval = 100 * time.Millisecond
+
+// errReplayRoomExists 回放房间ID已被占用
+var errReplayRoomExists = errors.New("房间ID已被占用")
+
+// replayEvent 回放时间轴上的一条记录（触摸帧或判定之一）
+type replayEvent struct {
+	time   float32
+	player int32 // 房间内回放玩家的ID
+	frame  *common.TouchFrame
+	judge  *common.JudgeEvent
+}
+
+// ReplayPlayer 回放房间中的玩家，房间内使用模拟玩家范围的ID，UserID 为原对局中的玩家ID
+type ReplayPlayer struct {
+	ID     int32  `json:"id"`
+	UserID int32  `json:"userId"`
+	Name   string `json:"name"`
+	Replay bool   `json:"replay"` // 有回放数据（未录制的玩家只在结束时显示成绩）
+}
+
+// ReplayPlaybackStatus 回放房间的播放状态
+type ReplayPlaybackStatus struct {
+	RoomID     string         `json:"roomid"`
+	SourceRoom string         `json:"sourceRoom"` // 原对局的房间ID
+	StartedAt  int64          `json:"startedAt"`  // 原对局的开始时间
+	ChartID    int64          `json:"chartId"`
+	State      string         `json:"state"`    // idle（未开始）、playing、paused
+	Position   float64        `json:"position"` // 当前位置（秒）
+	Duration   float64        `json:"duration"` // 最后一条记录的时间（秒）
+	Players    []ReplayPlayer `json:"players"`
+}
+
+// ReplayPlayback 回放房间的播放器：合并同一局各玩家的回放，按时间同步推送给观察者
+// 回放文件中的时间精确到秒，同一秒内的记录在同一次推送中发送
+type ReplayPlayback struct {
+	manifest *GameManifest
+	players  []ReplayPlayer // 与 manifest.Participants 一一对应
+	events   []replayEvent  // 按时间排序
+	duration float64
+
+	mu      sync.Mutex
+	playing bool // 对局进行中（包括暂停）
+	paused  bool
+	offset  float64   // 暂停时的位置，或最近一次开始、继续播放时的位置（秒）
+	resumed time.Time // 最近一次开始或继续播放的时间
+	next    int       // 下一条待推送的记录
+}
+
+// IsReplay 是否为回放房间
+func (r *Room) IsReplay() bool {
+	return r.replay != nil
+}
+
+// GetReplayPlayback 回放房间的播放器（普通房间返回nil）
+func (r *Room) GetReplayPlayback() *ReplayPlayback {
+	return r.replay
+}
+
+// readGameReplay 读取对局清单引用的回放文件，校验SHA-256后解密
+func (r *ReplayRecorder) readGameReplay(replay *ManifestReplay) ([]byte, error) {
+	data, err := os.ReadFile(filepath.Join(ReplayDir, filepath.FromSlash(replay.File)))
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	if hex.EncodeToString(sum[:]) != replay.SHA256 {
+		return nil, fmt.Errorf("回放 %s 校验和不匹配", replay.File)
+	}
+	return decodeReplayData(data, r.cipher)
+}
+
+// parseReplayEvents 解析回放数据中的触摸帧与判定事件，player 为房间内回放玩家的ID
+func parseReplayEvents(data []byte, player int32) ([]replayEvent, error) {
+	if len(data) < replayHeaderSize || binary.LittleEndian.Uint16(data[0:2]) != replayMagic {
+		return nil, fmt.Errorf("无效的回放文件")
+	}
+	reader := bytes.NewReader(data[replayHeaderSize:])
+
+	var events []replayEvent
+	for {
+		kind, err := reader.ReadByte()
+		if err == io.EOF {
+			return events, nil
+		}
+		length, err := binary.ReadUvarint(reader)
+		if err != nil {
+			return nil, fmt.Errorf("回放数据损坏: %w", err)
+		}
+		if length > uint64(reader.Len()) {
+			return nil, fmt.Errorf("回放数据损坏: 记录长度 %d 超出文件", length)
+		}
+		record := make([]byte, length)
+		io.ReadFull(reader, record)
+
+		switch {
+		case kind == replayRecordTouch && len(record) >= 5:
+			frame := &common.TouchFrame{Time: float32(binary.LittleEndian.Uint32(record[0:4]))}
+			points := record[5:]
+			for i := 0; i < int(record[4]) && len(points) >= 5; i++ {
+				frame.Points = append(frame.Points, common.TouchPoint{
+					ID:  int8(points[0]),
+					Pos: common.CompactPos{X: binary.LittleEndian.Uint16(points[1:3]), Y: binary.LittleEndian.Uint16(points[3:5])},
+				})
+				points = points[5:]
+			}
+			events = append(events, replayEvent{time: frame.Time, player: player, frame: frame})
+		case kind == replayRecordJudge && len(record) >= 13:
+			judge := &common.JudgeEvent{
+				Time:      float32(binary.LittleEndian.Uint32(record[0:4])),
+				LineID:    binary.LittleEndian.Uint32(record[4:8]),
+				NoteID:    binary.LittleEndian.Uint32(record[8:12]),
+				Judgement: common.Judgement(record[12]),
+			}
+			events = append(events, replayEvent{time: judge.Time, player: player, judge: judge})
+		}
+	}
+}
+
+// NewReplayRoom 按对局清单创建回放房间
+// 房间内的玩家由各玩家的回放驱动（没有会话，不写入回放与对局历史），其他用户只能以观察者身份加入，
+// 由管理员通过 Play、Pause、Seek 控制播放，解散前一直保留
+func (s *Server) NewReplayRoom(id common.RoomId, manifest *GameManifest) (*Room, error) {
+	recorder := s.GetReplayRecorder()
+	if recorder == nil {
+		return nil, fmt.Errorf("回放功能不可用")
+	}
+	if len(manifest.Participants) == 0 {
+		return nil, fmt.Errorf("对局没有玩家")
+	}
+
+	p := &ReplayPlayback{manifest: manifest}
+	users := make([]*User, 0, len(manifest.Participants))
+	for _, part := range manifest.Participants {
+		name := part.Name
+		if name == "" {
+			name = fmt.Sprintf("玩家%d", part.UserID)
+		}
+		user := NewUser(BotIDBase-s.botSeq.Add(1), name, "zh-CN", s)
+		player := ReplayPlayer{ID: user.ID, UserID: part.UserID, Name: name}
+		if part.Replay != nil {
+			data, err := recorder.readGameReplay(part.Replay)
+			if err != nil {
+				return nil, fmt.Errorf("读取玩家 %s(%d) 的回放失败: %w", name, part.UserID, err)
+			}
+			events, err := parseReplayEvents(data, user.ID)
+			if err != nil {
+				return nil, fmt.Errorf("解析玩家 %s(%d) 的回放失败: %w", name, part.UserID, err)
+			}
+			p.events = append(p.events, events...)
+			player.Replay = true
+		}
+		p.players = append(p.players, player)
+		users = append(users, user)
+	}
+	sort.SliceStable(p.events, func(i, j int) bool { return p.events[i].time < p.events[j].time })
+	if n := len(p.events); n > 0 {
+		p.duration = float64(p.events[n-1].time)
+	}
+
+	room := NewRoom(id, users[0], s)
+	room.replay = p
+	if len(users) > RoomMaxUsers {
+		room.SetMaxUsers(len(users))
+	}
+	for _, user := range users[1:] {
+		room.AddUser(user, false)
+	}
+	for _, user := range users {
+		user.SetRoom(room)
+	}
+	if manifest.ChartName != "" {
+		room.SetChart(&Chart{ID: manifest.ChartID, Name: manifest.ChartName})
+	} else {
+		room.SetChart(&Chart{ID: manifest.ChartID, Name: fmt.Sprintf("ID(%d)", manifest.ChartID)})
+		// 异步获取谱面名称
+		go func() {
+			if chart, err := FetchChart(manifest.ChartID); err == nil {
+				room.SetChart(chart)
+			}
+		}()
+	}
+
+	if _, loaded := s.rooms.LoadOrStore(id, room); loaded {
+		return nil, errReplayRoomExists
+	}
+	s.touchRoomList()
+	log.Printf("回放房间 `%s` 已创建 (对局: %s/%d, 玩家数: %d, 时长: %.0f秒)",
+		id.Value, manifest.RoomID, manifest.StartedAt, len(users), p.duration)
+	BroadcastAdminUpdate(s)
+
+	go p.run(room)
+	return room, nil
+}
+
+// position 当前播放位置（调用方持有 p.mu）
+func (p *ReplayPlayback) position() float64 {
+	if !p.playing || p.paused {
+		return p.offset
+	}
+	return p.offset + time.Since(p.resumed).Seconds()
+}
+
+// Status 播放状态
+func (p *ReplayPlayback) Status(room *Room) ReplayPlaybackStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	state := "idle"
+	if p.playing && p.paused {
+		state = "paused"
+	} else if p.playing {
+		state = "playing"
+	}
+	return ReplayPlaybackStatus{
+		RoomID:     room.ID.Value,
+		SourceRoom: p.manifest.RoomID,
+		StartedAt:  p.manifest.StartedAt,
+		ChartID:    p.manifest.ChartID,
+		State:      state,
+		Position:   p.position(),
+		Duration:   p.duration,
+		Players:    p.players,
+	}
+}
+
+// Play 开始播放（选谱阶段开始一局，从当前位置播放）或继续已暂停的播放
+func (p *ReplayPlayback) Play(room *Room) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.playing {
+		if !p.paused {
+			return fmt.Errorf("回放已在播放中")
+		}
+		p.paused = false
+		p.resumed = time.Now()
+		room.SendMessage(common.Message{Type: common.MsgChat, User: 0, Content: "回放继续"})
+		return nil
+	}
+	if room.GetState() != InternalStateSelectChart {
+		return fmt.Errorf("无效状态")
+	}
+
+	clearSyncMap(&room.started)
+	clearSyncMap(&room.results)
+	clearSyncMap(&room.aborted)
+	clearSyncMap(&room.judgeStats)
+	room.SendMessage(common.Message{Type: common.MsgStartPlaying})
+	room.ResetGameTime()
+	room.SetState(InternalStatePlaying)
+	room.Broadcast(common.ServerCommand{
+		Type:        common.ServerCmdChangeState,
+		ChangeState: &common.RoomState{Type: common.RoomStatePlaying},
+	})
+	BroadcastRoomUpdate(room)
+
+	p.playing = true
+	p.paused = false
+	p.resumed = time.Now()
+	log.Printf("回放房间 `%s` 开始播放 (位置: %.0f秒)", room.ID.Value, p.offset)
+	BroadcastRoomLog(room.ID.Value, fmt.Sprintf("回放开始 - 对局: %s, 玩家数: %d", p.manifest.RoomID, len(p.players)))
+	return nil
+}
+
+// Pause 暂停播放
+func (p *ReplayPlayback) Pause(room *Room) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.playing || p.paused {
+		return fmt.Errorf("回放未在播放")
+	}
+	p.offset = p.position()
+	p.paused = true
+	room.SendMessage(common.Message{Type: common.MsgChat, User: 0, Content: "回放已暂停"})
+	return nil
+}
+
+// Seek 跳转到 position 秒；未开始时设置开始播放的位置
+func (p *ReplayPlayback) Seek(room *Room, position float64) error {
+	if position < 0 || position > p.duration {
+		return fmt.Errorf("位置超出回放范围")
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.offset = position
+	p.resumed = time.Now()
+	p.next = sort.Search(len(p.events), func(i int) bool { return float64(p.events[i].time) >= position })
+	if p.playing {
+		room.SendMessage(common.Message{
+			Type:    common.MsgChat,
+			User:    0,
+			Content: fmt.Sprintf("回放跳转到 %d:%02d", int(position)/60, int(position)%60),
+		})
+	}
+	return nil
+}
+
+// run 按固定间隔推送到期的记录，房间解散后退出
+func (p *ReplayPlayback) run(room *Room) {
+	ticker := time.NewTicker(replayPlaybackInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if room.server.GetRoom(room.ID) != room {
+			return
+		}
+		p.tick(room)
+	}
+}
+
+// tick 推送当前位置之前尚未推送的记录，全部播放完毕后结束本局
+func (p *ReplayPlayback) tick(room *Room) {
+	p.mu.Lock()
+	if !p.playing || p.paused {
+		p.mu.Unlock()
+		return
+	}
+	position := p.position()
+	start := p.next
+	for p.next < len(p.events) && float64(p.events[p.next].time) <= position {
+		p.next++
+	}
+	due := p.events[start:p.next]
+	ended := p.next >= len(p.events) && position >= p.duration
+	if ended {
+		p.playing = false
+		p.offset = 0
+		p.next = 0
+	}
+	p.mu.Unlock()
+
+	p.stream(room, due)
+	if ended {
+		p.finish(room)
+	}
+}
+
+// stream 按玩家合并触摸帧与判定后转发给观察者
+func (p *ReplayPlayback) stream(room *Room, events []replayEvent) {
+	if len(events) == 0 {
+		return
+	}
+	frames := make(map[int32][]common.TouchFrame)
+	judges := make(map[int32][]common.JudgeEvent)
+	for _, e := range events {
+		if e.frame != nil {
+			frames[e.player] = append(frames[e.player], *e.frame)
+		} else {
+			judges[e.player] = append(judges[e.player], *e.judge)
+		}
+	}
+	for _, player := range p.players {
+		if f := frames[player.ID]; len(f) > 0 {
+			room.forwardTouches(player.ID, f)
+		}
+		if j := judges[player.ID]; len(j) > 0 {
+			room.forwardJudges(player.ID, j)
+		}
+	}
+}
+
+// finish 按对局清单中的成绩提交各玩家的结果（判定统计取自完整的回放），随后与普通对局一样结束本局
+func (p *ReplayPlayback) finish(room *Room) {
+	stats := make(map[int32]*JudgeStats)
+	for _, e := range p.events {
+		if e.judge == nil {
+			continue
+		}
+		if stats[e.player] == nil {
+			stats[e.player] = &JudgeStats{}
+		}
+		stats[e.player].Add([]common.JudgeEvent{*e.judge})
+	}
+
+	for i, part := range p.manifest.Participants {
+		id := p.players[i].ID
+		if part.Result == nil {
+			room.aborted.Store(id, true)
+			room.SendMessage(common.Message{Type: common.MsgAbort, User: id})
+			continue
+		}
+		record := &Record{
+			Player:   id,
+			Score:    part.Result.Score,
+			Accuracy: part.Result.Accuracy,
+			MaxCombo: part.Result.MaxCombo,
+		}
+		if s := stats[id]; s != nil {
+			record.Perfect, record.Good, record.Bad, record.Miss, _ = s.Snapshot()
+			record.FullCombo = record.Bad+record.Miss == 0
+		}
+		room.results.Store(id, record)
+		room.SendMessage(room.playedMessage(id, record))
+	}
+	log.Printf("回放房间 `%s` 播放结束", room.ID.Value)
+	room.CheckAllReady()
+}
+
+// ReplayRoomRequest 创建回放房间请求
+type ReplayRoomRequest struct {
+	RoomID     string `json:"roomId"`     // 回放房间ID
+	SourceRoom string `json:"sourceRoom"` // 原对局的房间ID
+	StartedAt  int64  `json:"startedAt"`  // 原对局的开始时间（对局清单的 started_at）
+}
+
+// handleAdminReplayRooms 处理 POST /admin/replay-rooms（按对局清单创建回放房间）
+func (h *HTTPServer) handleAdminReplayRooms(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method-not-allowed")
+		return
+	}
+	if h.server.GetReplayRecorder() == nil {
+		writeError(w, http.StatusServiceUnavailable, "replay-disabled")
+		return
+	}
+
+	var req ReplayRoomRequest
+	if err := parseBody(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad-request")
+		return
+	}
+	if !isValidRoomID(req.RoomID) {
+		writeError(w, http.StatusBadRequest, "bad-room-id")
+		return
+	}
+	roomId, err := common.NewRoomId(req.RoomID)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "bad-room-id")
+		return
+	}
+	if !isValidRoomID(req.SourceRoom) || req.StartedAt <= 0 {
+		writeError(w, http.StatusBadRequest, "bad-request")
+		return
+	}
+
+	manifest, err := LoadGameManifest((&GameManifest{RoomID: req.SourceRoom, StartedAt: req.StartedAt}).Path(ReplayDir))
+	if os.IsNotExist(err) {
+		writeError(w, http.StatusNotFound, "game-not-found")
+		return
+	}
+	if err != nil {
+		log.Printf("[管理员] 读取对局清单 %s/%d 失败: %v", req.SourceRoom, req.StartedAt, err)
+		writeError(w, http.StatusInternalServerError, "internal-error")
+		return
+	}
+	if h.server.GetRoom(roomId) != nil {
+		writeError(w, http.StatusConflict, "room-exists")
+		return
+	}
+
+	room, err := h.server.NewReplayRoom(roomId, manifest)
+	if errors.Is(err, errReplayRoomExists) {
+		writeError(w, http.StatusConflict, "room-exists")
+		return
+	}
+	if err != nil {
+		log.Printf("[管理员] 创建回放房间 `%s` 失败: %v", req.RoomID, err)
+		writeError(w, http.StatusUnprocessableEntity, "replay-unavailable")
+		return
+	}
+	log.Printf("[管理员] 创建回放房间 `%s`", room.ID.Value)
+	writeOK(w, room.replay.Status(room))
+}
+
+// ReplayPlaybackRequest 回放房间播放控制请求
+type ReplayPlaybackRequest struct {
+	Action   string  `json:"action"`   // play、pause 或 seek
+	Position float64 `json:"position"` // seek 的目标位置（秒）
+}
+
+// handleAdminRoomPlayback 处理 GET /admin/rooms/{id}/playback（播放状态）与 POST（播放控制）
+func (h *HTTPServer) handleAdminRoomPlayback(w http.ResponseWriter, r *http.Request, room *Room) {
+	playback := room.GetReplayPlayback()
+	if playback == nil {
+		writeError(w, http.StatusBadRequest, "not-replay-room")
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		writeOK(w, playback.Status(room))
+		return
+	case http.MethodPost:
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method-not-allowed")
+		return
+	}
+
+	var req ReplayPlaybackRequest
+	if err := parseBody(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, "bad-request")
+		return
+	}
+
+	var err error
+	switch req.Action {
+	case "play":
+		err = playback.Play(room)
+	case "pause":
+		err = playback.Pause(room)
+	case "seek":
+		if req.Position < 0 || req.Position > playback.duration {
+			writeError(w, http.StatusBadRequest, "bad-position")
+			return
+		}
+		err = playback.Seek(room, req.Position)
+	default:
+		writeError(w, http.StatusBadRequest, "bad-action")
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid-state")
+		return
+	}
+	log.Printf("[管理员] 回放房间 `%s` %s", room.ID.Value, req.Action)
+	writeOK(w, playback.Status(room))
+}
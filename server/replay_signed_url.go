@@ -0,0 +1,70 @@
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"log"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ReplayURLSigner 生成与校验带过期时间的回放下载链接（HMAC-SHA256，无需服务端状态，可放在CDN之后）
+type ReplayURLSigner struct {
+	key    []byte
+	base   string        // 链接前缀（如CDN地址），为空时生成相对路径
+	expire time.Duration // 链接有效期
+}
+
+// NewReplayURLSigner 创建签名器，key为空时使用随机密钥（重启后旧链接失效）
+func NewReplayURLSigner(key, base string, expire time.Duration) *ReplayURLSigner {
+	secret := []byte(key)
+	if len(secret) == 0 {
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			log.Printf("生成回放链接签名密钥失败: %v", err)
+		}
+	}
+	if expire <= 0 {
+		expire = DefaultReplayURLExpire
+	}
+	return &ReplayURLSigner{key: secret, base: strings.TrimRight(base, "/"), expire: expire}
+}
+
+// signature 计算回放ID与过期时间的签名
+func (s *ReplayURLSigner) signature(id string, expires int64) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(id + "|" + strconv.FormatInt(expires, 10)))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Sign 生成回放下载链接
+func (s *ReplayURLSigner) Sign(id string, now time.Time) (string, time.Time) {
+	expiresAt := now.Add(s.expire)
+	expires := expiresAt.Unix()
+	query := url.Values{}
+	query.Set("id", id)
+	query.Set("expires", strconv.FormatInt(expires, 10))
+	query.Set("sig", s.signature(id, expires))
+	return s.base + "/replay/download?" + query.Encode(), expiresAt
+}
+
+// Verify 校验签名与有效期
+// 返回值：链接剩余有效时间，是否通过
+func (s *ReplayURLSigner) Verify(id, expiresStr, sig string, now time.Time) (time.Duration, bool) {
+	expires, err := strconv.ParseInt(expiresStr, 10, 64)
+	if err != nil || id == "" {
+		return 0, false
+	}
+	if !hmac.Equal([]byte(sig), []byte(s.signature(id, expires))) {
+		return 0, false
+	}
+	remaining := time.Unix(expires, 0).Sub(now)
+	if remaining <= 0 {
+		return 0, false
+	}
+	return remaining, true
+}
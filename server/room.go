@@ -7,6 +7,7 @@ import (
 	"math/rand"
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"phira-mp/common"
 )
@@ -22,17 +23,18 @@ const (
 	InternalStateSelectChart InternalRoomState = iota
 	InternalStateWaitForReady
 	InternalStatePlaying
+	InternalStateLoading // 全员准备后等待谱面加载，客户端视为 WaitingForReady
 )
 
 // ToClientState 转换为客户端状态
-func (s InternalRoomState) ToClientState(chartID *int32) common.RoomState {
+func (s InternalRoomState) ToClientState(chartID *int64) common.RoomState {
 	switch s {
 	case InternalStateSelectChart:
 		return common.RoomState{
 			Type:    common.RoomStateSelectChart,
 			ChartID: chartID,
 		}
-	case InternalStateWaitForReady:
+	case InternalStateWaitForReady, InternalStateLoading:
 		return common.RoomState{Type: common.RoomStateWaitingForReady}
 	case InternalStatePlaying:
 		return common.RoomState{Type: common.RoomStatePlaying}
@@ -46,22 +48,93 @@ type Room struct {
 	host  atomic.Value // *User
 	state atomic.Int32 // InternalRoomState
 
-	live   atomic.Bool
-	locked atomic.Bool
-	cycle  atomic.Bool
+	live     atomic.Bool
+	locked   atomic.Bool
+	cycle    atomic.Bool
+	overflow atomic.Bool // 满员时新玩家自动转为观察者
 
 	users       sync.RWMutex
 	userList    []*User
 	monitors    sync.RWMutex
 	monitorList []*User
+	queue       sync.Mutex
+	queueList   []*User // 等待队列
+	tapsMu      sync.RWMutex
+	taps        []RoomTap // 内部数据订阅者（如回放录制器），见 AddTap
+	botsMu      sync.Mutex
+	bots        []*Bot // 管理员加入的模拟玩家，见 SpawnBots
+
+	// 排队放行时持有，检查空位、取出队首与加入房间作为整体执行，并发放行不会越过空位取出多余的用户
+	admitMu sync.Mutex
+
+	// 广播在持有 outbound 时依次写入各成员的发送队列，来自不同协程（会话、管理接口、定时器）的广播
+	// 因此以相同的顺序到达每个成员，不会出现部分成员先收到 ChangeState、后收到对应房间消息的情况
+	// 写入发送队列不会等待，队列已满的成员被断开（见 User.sendRoom），读取过慢的成员不会阻塞整个房间
+	outbound sync.Mutex
+
+	// 回放房间的播放器（普通房间为nil，见 replay_room.go）
+	replay *ReplayPlayback
+
+	chart       atomic.Value // *Chart
+	maxUsers    atomic.Int32
+	maxMonitors atomic.Int32 // 房主设置的观察者上限（0表示使用服务器默认值）
+
+	// 房间密码与私密房间（见 room_password.go，nil表示没有密码）
+	password atomic.Pointer[roomPassword]
+	private  atomic.Bool
+
+	// 各用户输错房间密码的次数与退避（见 room_password.go）
+	passwordMu       sync.Mutex
+	passwordAttempts map[int32]*passwordAttempt
+
+	// 最近的房间消息（见 chat_history.go）
+	history chatHistory
+
+	// 过载降级时合并转发给观察者的触摸帧（见 load_shed.go）
+	touchBatch touchCoalescer
 
-	chart atomic.Value // *Chart
+	// 同IP限制（防止多开）
+	uniqueIP atomic.Bool
+	ipExempt atomic.Value // map[int32]bool - 允许与他人共用IP的用户
+
+	// 房主闲置检测
+	idleSince   atomic.Int64 // 闲置计时起点（UnixNano）
+	idleWarned  atomic.Bool
+	idleHandled atomic.Bool
+
+	// 官方房间模板（非官方房间为nil）
+	template *RoomTemplate
+
+	// 开放与关闭时间（见 room_schedule.go）
+	schedule     atomic.Value // *common.RoomSchedule
+	closeWarned  atomic.Bool  // 已发送关闭提醒
+	closePending atomic.Bool  // 已到关闭时间，等待本局结束
 
 	// 游戏状态
 	started sync.Map // map[int32]bool - 已准备的玩家
 	results sync.Map // map[int32]*Record - 游戏结果
 	aborted sync.Map // map[int32]bool - 放弃的玩家
 
+	pendingResults sync.Map // map[int32]int64 - 成绩确认中的玩家（成绩ID），见 startPlayedRetry
+
+	judgeStats sync.Map // map[int32]*JudgeStats - 本局判定统计
+
+	// 排名（见 ranking.go）
+	ranking     atomic.Value // string - 房主选择的排名策略名称
+	lastSummary atomic.Value // *GameSummary - 最近一局的结算
+
+	// 队伍（见 team.go）
+	teams sync.Map // map[int32]uint8 - 已分队玩家所在的队伍
+
+	// 比赛模式（见 contest.go，nil表示普通房间）
+	contest atomic.Pointer[contestState]
+
+	// 谱面加载阶段
+	loadProgress sync.Map // map[int32]uint8 - 玩家加载进度（0-100）
+	loadMu       sync.Mutex
+	loadTimer    *time.Timer
+	loadDeadline time.Time
+
 	server *Server
 }
 
@@ -73,18 +146,37 @@ func NewRoom(id common.RoomId, host *User, server *Server) *Room {
 	}
 	r.host.Store(host)
 	r.state.Store(int32(InternalStateSelectChart))
+	r.maxUsers.Store(RoomMaxUsers)
 	r.userList = []*User{host}
+	r.resetHostIdle()
 	return r
 }
 
+// GetMaxUsers 获取最大玩家数
+func (r *Room) GetMaxUsers() int {
+	return int(r.maxUsers.Load())
+}
+
+// SetMaxUsers 设置最大玩家数
+func (r *Room) SetMaxUsers(maxUsers int) {
+	r.maxUsers.Store(int32(maxUsers))
+}
+
 // GetHost 获取房主
 func (r *Room) GetHost() *User {
 	return r.host.Load().(*User)
 }
 
+// setHostExtension 在加入房间与重连的回复上附带当前房主（扩展字段 ExtRoomHost），观察者据此得知房主而无需等待 MsgNewHost
+func (r *Room) setHostExtension(cmd *common.ServerCommand) {
+	host := r.GetHost().ToInfo()
+	cmd.Extensions.SetValue(common.ExtRoomHost, &host)
+}
+
 // SetHost 设置房主
 func (r *Room) SetHost(user *User) {
 	r.host.Store(user)
+	r.resetHostIdle()
 }
 
 // GetState 获取房间状态
@@ -95,6 +187,9 @@ func (r *Room) GetState() InternalRoomState {
 // SetState 设置房间状态
 func (r *Room) SetState(state InternalRoomState) {
 	r.state.Store(int32(state))
+	if state == InternalStateSelectChart {
+		r.resetHostIdle()
+	}
 }
 
 // IsLive 是否直播中
@@ -127,6 +222,23 @@ func (r *Room) SetCycle(cycle bool) {
 	r.cycle.Store(cycle)
 }
 
+// IsOverflow 满员时是否转为观察者加入
+func (r *Room) IsOverflow() bool {
+	return r.overflow.Load()
+}
+
+// SetOverflow 设置满员转观察者
+func (r *Room) SetOverflow(overflow bool) {
+	r.overflow.Store(overflow)
+}
+
+// IsFull 玩家位置是否已满
+func (r *Room) IsFull() bool {
+	r.users.RLock()
+	defer r.users.RUnlock()
+	return len(r.userList) >= r.GetMaxUsers()
+}
+
 // GetChart 获取当前谱面
 func (r *Room) GetChart() *Chart {
 	chart := r.chart.Load()
@@ -144,7 +256,7 @@ func (r *Room) SetChart(chart *Chart) {
 // GetClientRoomState 获取客户端房间状态
 func (r *Room) GetClientRoomState(user *User) common.ClientRoomState {
 	chart := r.GetChart()
-	var chartID *int32
+	var chartID *int64
 	if chart != nil {
 		chartID = &chart.ID
 	}
@@ -157,19 +269,45 @@ func (r *Room) GetClientRoomState(user *User) common.ClientRoomState {
 
 	// 检查是否已准备
 	isReady := false
-	if r.GetState() == InternalStateWaitForReady {
+	if state := r.GetState(); state == InternalStateWaitForReady || state == InternalStateLoading {
 		_, isReady = r.started.Load(user.ID)
 	}
 
 	return common.ClientRoomState{
-		ID:      r.ID,
-		State:   r.GetState().ToClientState(chartID),
-		Live:    r.IsLive(),
-		Locked:  r.IsLocked(),
-		Cycle:   r.IsCycle(),
-		IsHost:  r.GetHost().ID == user.ID,
-		IsReady: isReady,
-		Users:   userMap,
+		ID:         r.ID,
+		State:      r.GetState().ToClientState(chartID),
+		Live:       r.IsLive(),
+		Locked:     r.IsLocked(),
+		Cycle:      r.IsCycle(),
+		IsHost:     r.GetHost().ID == user.ID,
+		IsReady:    isReady,
+		Users:      userMap,
+		ReadyUsers: r.GetReadyUsers(),
+		Teams:      r.GetTeams(),
+	}
+}
+
+// GetReadyUsers 获取已准备的玩家ID（仅在等待准备阶段有效）
+func (r *Room) GetReadyUsers() []int32 {
+	state := r.GetState()
+	if state != InternalStateWaitForReady && state != InternalStateLoading {
+		return nil
+	}
+	var ready []int32
+	for _, u := range r.GetUsers() {
+		if _, ok := r.started.Load(u.ID); ok {
+			ready = append(ready, u.ID)
+		}
+	}
+	return ready
+}
+
+// SetReady 设置玩家准备状态（不检查是否全员准备）
+func (r *Room) SetReady(userID int32, ready bool) {
+	if ready {
+		r.started.Store(userID, true)
+	} else {
+		r.started.Delete(userID)
 	}
 }
 
@@ -177,6 +315,10 @@ func (r *Room) GetClientRoomState(user *User) common.ClientRoomState {
 func (r *Room) AddUser(user *User, monitor bool) bool {
 	if monitor {
 		r.monitors.Lock()
+		if r.monitorsFull() {
+			r.monitors.Unlock()
+			return false
+		}
 		r.monitorList = append(r.monitorList, user)
 		r.monitors.Unlock()
 
@@ -190,7 +332,7 @@ func (r *Room) AddUser(user *User, monitor bool) bool {
 	}
 
 	r.users.Lock()
-	if len(r.userList) >= RoomMaxUsers {
+	if len(r.userList) >= r.GetMaxUsers() {
 		r.users.Unlock()
 		return false
 	}
@@ -268,24 +410,39 @@ func (r *Room) CheckHost(user *User) error {
 
 // Broadcast 广播消息给所有用户
 func (r *Room) Broadcast(cmd common.ServerCommand) {
+	r.outbound.Lock()
+	defer r.outbound.Unlock()
+	r.broadcastLocked(cmd)
+}
+
+// broadcastLocked 依次写入所有成员的发送队列（调用方持有 outbound）
+func (r *Room) broadcastLocked(cmd common.ServerCommand) {
 	for _, user := range r.GetAllUsers() {
-		user.Send(cmd)
+		user.sendRoom(cmd)
 	}
 }
 
 // BroadcastMonitors 广播给观察者
 func (r *Room) BroadcastMonitors(cmd common.ServerCommand) {
+	r.outbound.Lock()
+	defer r.outbound.Unlock()
 	for _, user := range r.GetMonitors() {
-		user.Send(cmd)
+		user.sendRoom(cmd)
 	}
 }
 
-// SendMessage 发送房间消息
+// SendMessage 发送房间消息，并以相同的时间戳推送给WebSocket订阅者，同时保存到房间消息历史
+// 时间戳在持有 outbound 时生成，成员收到的消息顺序与时间顺序一致
 func (r *Room) SendMessage(msg common.Message) {
-	r.Broadcast(common.ServerCommand{
+	r.outbound.Lock()
+	defer r.outbound.Unlock()
+	msg.Time = time.Now().UnixMilli()
+	r.history.add(msg, r.server.config.ChatHistorySize)
+	r.broadcastLocked(common.ServerCommand{
 		Type:    common.ServerCmdMessage,
 		Message: &msg,
 	})
+	BroadcastRoomMessage(r.ID.Value, msg)
 }
 
 // OnUserLeave 用户离开房间
@@ -294,43 +451,43 @@ func (r *Room) OnUserLeave(user *User) bool {
 	r.SendMessage(common.Message{
 		Type: common.MsgLeaveRoom,
 		User: user.ID,
-		Name: user.Name,
+		Name: user.DisplayName(),
 	})
 
 	// 广播房间日志
 	BroadcastRoomLog(r.ID.Value, fmt.Sprintf("玩家 %s(%d) 离开了房间", user.Name, user.ID))
 
 	r.RemoveUser(user.ID)
+	r.leaveTeam(user.ID)
 	user.SetRoom(nil)
+	r.refreshLive()
 
 	// 如果是房主离开
 	if r.GetHost().ID == user.ID {
 		users := r.GetUsers()
 		if len(users) == 0 {
+			// 官方房间仍有观察者时保留房间，由占位房主接管
+			if r.IsOfficial() && len(r.GetMonitors()) > 0 {
+				r.SetHost(newOfficialHost(r.server))
+				BroadcastRoomUpdate(r)
+				r.AdmitQueued()
+				return false
+			}
 			return true // 房间空了，删除房间
 		}
-		// 随机选择新房主
-		newHost := users[rand.Intn(len(users))]
-		oldHost := r.GetHost()
-		r.SetHost(newHost)
-
-		log.Printf("房间 `%s` 房主变更: %s(%d) -> %s(%d) (原房主离开)",
-			r.ID.Value, oldHost.Name, oldHost.ID, newHost.Name, newHost.ID)
-
-		// 广播房间日志
-		BroadcastRoomLog(r.ID.Value, fmt.Sprintf("房主变更: %s(%d) -> %s(%d)", oldHost.Name, oldHost.ID, newHost.Name, newHost.ID))
-
-		r.SendMessage(common.Message{
-			Type: common.MsgNewHost,
-			User: newHost.ID,
-		})
-		newHost.Send(common.ServerCommand{
-			Type:       common.ServerCmdChangeHost,
-			ChangeHost: true,
-		})
+		// 等待准备阶段按 host_leave_policy 处理；取消开始时仍需另选房主
+		if !r.onHostLeaveWaitForReady(user) || r.GetHost().ID == user.ID {
+			// 随机选择新房主（优先真实玩家）
+			candidates := humanUsers(users)
+			if len(candidates) == 0 {
+				candidates = users
+			}
+			r.transferHost(user, candidates[rand.Intn(len(candidates))])
+		}
 	}
 
 	r.CheckAllReady()
+	r.AdmitQueued()
 
 	// 广播房间状态更新
 	BroadcastRoomUpdate(r)
@@ -338,6 +495,28 @@ func (r *Room) OnUserLeave(user *User) bool {
 	return false
 }
 
+// notifyClosed 房间被移除时，通知仍在房间内的成员（如只剩观察者）并将其移出房间
+// V23起的客户端收到 Kicked（KickReasonDisbanded），V5起收到 RoomClosed，原版协议客户端收不到该通知，仍需依靠后续命令失败发现
+func (r *Room) notifyClosed(reason string) {
+	closed := common.ServerCommand{Type: common.ServerCmdRoomClosed, RoomClosed: &common.RoomClosed{RoomId: r.ID, Reason: reason}}
+	kicked := kickedCommand(r, common.KickReasonDisbanded, reason)
+	for _, user := range r.GetAllUsers() {
+		r.RemoveUser(user.ID)
+		if user.GetRoom() == r {
+			user.SetRoom(nil)
+		}
+		session := user.GetSession()
+		if session == nil {
+			continue
+		}
+		if common.ServerCommandSupported(session.Stream.Protocol(), &kicked) {
+			session.Send(kicked)
+		} else {
+			session.Send(closed)
+		}
+	}
+}
+
 // ResetGameTime 重置游戏时间
 func (r *Room) ResetGameTime() {
 	for _, user := range r.GetUsers() {
@@ -363,41 +542,14 @@ func (r *Room) CheckAllReady() {
 				break
 			}
 		}
-		if allReady {
-			// 清空之前的游戏状态
-			r.results = sync.Map{}
-			r.aborted = sync.Map{}
-
-			// 记录游戏开始日志
-			host := r.GetHost()
-			chart := r.GetChart()
-			chartName := "未知谱面"
-			if chart != nil {
-				chartName = chart.Name
-			}
-			log.Printf("房间 `%s` 游戏开始 - 房主: %s(%d), 谱面: %s, 玩家数: %d",
-				r.ID.Value, host.Name, host.ID, chartName, len(users))
-
-			// 广播房间日志
-			BroadcastRoomLog(r.ID.Value, fmt.Sprintf("游戏开始 - 谱面: %s, 玩家数: %d", chartName, len(users)))
-
-			r.SendMessage(common.Message{Type: common.MsgStartPlaying})
-			r.ResetGameTime()
-			r.SetState(InternalStatePlaying)
-			r.Broadcast(common.ServerCommand{
-				Type:        common.ServerCmdChangeState,
-				ChangeState: &common.RoomState{Type: common.RoomStatePlaying},
-			})
-
-			// 广播房间状态更新
-			BroadcastRoomUpdate(r)
-
-			// 开始回放录制
-			if recorder := r.server.GetReplayRecorder(); recorder != nil {
-				recorder.StartRecording(r)
-			}
+		// 比赛房间由管理员手动开始（见 handleAdminContestStart）
+		if allReady && !r.IsContest() {
+			r.beginGame()
 		}
 
+	case InternalStateLoading:
+		r.checkAllLoaded()
+
 	case InternalStatePlaying:
 		users := r.GetUsers()
 		if len(users) == 0 {
@@ -414,22 +566,36 @@ func (r *Room) CheckAllReady() {
 			}
 		}
 		if allDone {
-			// 输出游玩结束信息
-			r.logGameEnd()
+			// 生成结算并输出游玩结束信息
+			summary := r.buildSummary()
+			r.lastSummary.Store(summary)
+			r.logGameEnd(summary)
+			hookGameEnd(r, summary)
+			// 有模拟玩家参与的对局与回放房间不计入对局历史
+			if history := r.server.GetGameHistory(); history != nil && len(summary.Ranking) > 0 && !r.HasBots() && !r.IsReplay() {
+				history.Add(r.ID.Value, summary)
+			}
 
-			// 停止回放录制
+			// 停止回放录制并写入对局清单
 			if recorder := r.server.GetReplayRecorder(); recorder != nil {
-				recorder.StopRecording(r.ID.Value)
+				recorder.FinishGame(r.ID.Value, summary)
 			}
 
 			r.SendMessage(common.Message{Type: common.MsgGameEnd})
 
 			// 清空游戏状态
-			r.started = sync.Map{}
-			r.results = sync.Map{}
-			r.aborted = sync.Map{}
+			clearSyncMap(&r.started)
+			clearSyncMap(&r.results)
+			clearSyncMap(&r.aborted)
 
 			r.SetState(InternalStateSelectChart)
+			r.pruneTeams()
+
+			// 比赛房间结算后导出结果并解散
+			if r.IsContest() {
+				r.finishContest(summary)
+				return
+			}
 
 			// 循环模式：切换房主
 			if r.IsCycle() {
@@ -437,7 +603,7 @@ func (r *Room) CheckAllReady() {
 			}
 
 			chart := r.GetChart()
-			var chartID *int32
+			var chartID *int64
 			if chart != nil {
 				chartID = &chart.ID
 			}
@@ -448,10 +614,63 @@ func (r *Room) CheckAllReady() {
 
 			// 广播房间状态更新
 			BroadcastRoomUpdate(r)
+
+			// 对局结束后放行排队用户
+			r.AdmitQueued()
 		}
 	}
 }
 
+// beginGame 清空上一局的状态后开始对局，启用谱面加载阶段时等待全员加载完成后再开始
+func (r *Room) beginGame() {
+	clearSyncMap(&r.results)
+	clearSyncMap(&r.aborted)
+
+	if r.server.config.ChartLoadTimeout > 0 {
+		r.startLoading()
+		return
+	}
+	r.startPlaying()
+}
+
+// startPlaying 开始游戏
+func (r *Room) startPlaying() {
+	users := r.GetUsers()
+
+	// 记录游戏开始日志
+	host := r.GetHost()
+	chart := r.GetChart()
+	chartName := "未知谱面"
+	if chart != nil {
+		chartName = chart.Name
+	}
+	log.Printf("房间 `%s` 游戏开始 - 房主: %s(%d), 谱面: %s, 玩家数: %d",
+		r.ID.Value, host.Name, host.ID, chartName, len(users))
+
+	// 广播房间日志
+	BroadcastRoomLog(r.ID.Value, fmt.Sprintf("游戏开始 - 谱面: %s, 玩家数: %d", chartName, len(users)))
+
+	clearSyncMap(&r.judgeStats)
+	r.SendMessage(common.Message{Type: common.MsgStartPlaying})
+	r.ResetGameTime()
+	r.SetState(InternalStatePlaying)
+	r.Broadcast(common.ServerCommand{
+		Type:        common.ServerCmdChangeState,
+		ChangeState: &common.RoomState{Type: common.RoomStatePlaying},
+	})
+
+	// 广播房间状态更新
+	BroadcastRoomUpdate(r)
+
+	// 记录开局活动
+	r.server.recordMatchActivity(r)
+
+	// 开始回放录制
+	if recorder := r.server.GetReplayRecorder(); recorder != nil {
+		recorder.StartRecording(r)
+	}
+}
+
 // CycleHost 循环切换房主
 func (r *Room) CycleHost() {
 	users := r.GetUsers()
@@ -491,7 +710,7 @@ func (r *Room) CycleHost() {
 // OnStateChange 状态变化时广播
 func (r *Room) OnStateChange() {
 	chart := r.GetChart()
-	var chartID *int32
+	var chartID *int64
 	if chart != nil {
 		chartID = &chart.ID
 	}
@@ -502,56 +721,49 @@ func (r *Room) OnStateChange() {
 
 	// 广播房间状态更新
 	BroadcastRoomUpdate(r)
+
+	r.AdmitQueued()
 }
 
-// logGameEnd 输出游戏结束信息
-func (r *Room) logGameEnd() {
+// logGameEnd 输出游戏结束信息（成绩按结算排名顺序输出）
+func (r *Room) logGameEnd(summary *GameSummary) {
 	host := r.GetHost()
-	chart := r.GetChart()
 	chartName := "未知谱面"
-	if chart != nil {
-		chartName = chart.Name
+	if summary.ChartName != "" {
+		chartName = summary.ChartName
 	}
 
-	// 收集所有玩家结果
 	var results []string
-	r.results.Range(func(key, value interface{}) bool {
-		userID := key.(int32)
-		record := value.(*Record)
-		// 查找用户名
-		userName := fmt.Sprintf("玩家%d", userID)
-		for _, u := range r.GetAllUsers() {
-			if u.ID == userID {
-				userName = u.Name
-				break
-			}
-		}
-		results = append(results, fmt.Sprintf("%s(%d): 分数=%d, 准度=%.2f%%", userName, userID, record.Score, record.Accuracy))
-		return true
-	})
+	for _, e := range summary.Ranking {
+		results = append(results, fmt.Sprintf("#%d %s(%d): 分数=%d, 准度=%.2f%%", e.Rank, e.Name, e.UserID, e.Score, e.Accuracy))
+	}
 
-	// 收集放弃的玩家
 	var aborted []string
-	r.aborted.Range(func(key, value interface{}) bool {
-		userID := key.(int32)
-		// 查找用户名
-		userName := fmt.Sprintf("玩家%d", userID)
-		for _, u := range r.GetAllUsers() {
-			if u.ID == userID {
-				userName = u.Name
-				break
-			}
-		}
-		aborted = append(aborted, fmt.Sprintf("%s(%d)", userName, userID))
-		return true
-	})
+	for _, userID := range summary.Aborted {
+		aborted = append(aborted, fmt.Sprintf("%s(%d)", r.userName(userID), userID))
+	}
 
 	logMsg := fmt.Sprintf("房间 `%s` 游玩结束 - 房主: %s(%d), 谱面: %s", r.ID.Value, host.Name, host.ID, chartName)
 	if len(results) > 0 {
-		logMsg += fmt.Sprintf(" | 成绩: %v", results)
+		logMsg += fmt.Sprintf(" | 成绩(%s): %v", summary.Aggregator, results)
+	}
+	if len(summary.Teams) > 0 {
+		var teams []string
+		for _, t := range summary.Teams {
+			teams = append(teams, fmt.Sprintf("#%d 队伍%d: 总分=%d, 平均准度=%.2f%%", t.Rank, t.Team, t.Score, t.Accuracy))
+		}
+		logMsg += fmt.Sprintf(" | 队伍: %v", teams)
 	}
 	if len(aborted) > 0 {
 		logMsg += fmt.Sprintf(" | 放弃: %v", aborted)
 	}
-	log.Printf(logMsg)
+	log.Print(logMsg)
+}
+
+// clearSyncMap 原地清空 sync.Map（直接赋值新的 sync.Map 会与其他协程的读写竞争）
+func clearSyncMap(m *sync.Map) {
+	m.Range(func(key, _ interface{}) bool {
+		m.Delete(key)
+		return true
+	})
 }
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"sort"
+)
+
+// ErrSameIP 同IP用户已在房间中时返回给客户端的错误
+const ErrSameIP = "同一IP的用户已在房间中"
+
+// RemoteIP 获取会话的客户端IP
+func (s *Session) RemoteIP() string {
+	if s.Stream == nil || s.Stream.Stream == nil {
+		return ""
+	}
+	addr := s.Stream.RemoteAddr()
+	if addr == nil {
+		return ""
+	}
+	host, _, err := net.SplitHostPort(addr.String())
+	if err != nil {
+		return addr.String()
+	}
+	return host
+}
+
+// GetIP 获取用户的客户端IP（未知时为空）
+func (u *User) GetIP() string {
+	if ip, ok := u.ip.Load().(string); ok {
+		return ip
+	}
+	return ""
+}
+
+// SetIP 设置用户的客户端IP
+func (u *User) SetIP(ip string) {
+	u.ip.Store(ip)
+}
+
+// MaskIP 隐藏IP的后半部分，用于管理员接口展示
+// IPv4保留前两段（如 203.0.*.*），IPv6保留/64前缀（如 2001:db8::/64）
+func MaskIP(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return fmt.Sprintf("%d.%d.*.*", v4[0], v4[1])
+	}
+	return parsed.Mask(net.CIDRMask(64, 128)).String() + "/64"
+}
+
+// IsUniqueIP 是否启用同IP限制
+func (r *Room) IsUniqueIP() bool {
+	return r.uniqueIP.Load()
+}
+
+// SetUniqueIP 设置同IP限制
+func (r *Room) SetUniqueIP(enabled bool) {
+	r.uniqueIP.Store(enabled)
+}
+
+// SetIPExempt 设置允许与他人共用IP的用户
+func (r *Room) SetIPExempt(userIDs []int32) {
+	exempt := make(map[int32]bool, len(userIDs))
+	for _, id := range userIDs {
+		exempt[id] = true
+	}
+	r.ipExempt.Store(exempt)
+}
+
+// GetIPExempt 获取允许与他人共用IP的用户（按ID排序）
+func (r *Room) GetIPExempt() []int32 {
+	exempt, _ := r.ipExempt.Load().(map[int32]bool)
+	result := make([]int32, 0, len(exempt))
+	for id := range exempt {
+		result = append(result, id)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
+
+// IsIPExempt 用户是否允许与他人共用IP
+func (r *Room) IsIPExempt(userID int32) bool {
+	exempt, _ := r.ipExempt.Load().(map[int32]bool)
+	return exempt[userID]
+}
+
+// FindSameIP 查找房间内与用户IP相同的其他用户
+// 未启用同IP限制、IP未知或双方任一在豁免名单中时返回nil
+func (r *Room) FindSameIP(user *User) *User {
+	ip := user.GetIP()
+	if !r.IsUniqueIP() || ip == "" || r.IsIPExempt(user.ID) {
+		return nil
+	}
+	for _, u := range r.GetAllUsers() {
+		if u.ID != user.ID && u.GetIP() == ip && !r.IsIPExempt(u.ID) {
+			return u
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package server
+
+import (
+	"sort"
+	"time"
+
+	"phira-mp/common"
+)
+
+// joinableRooms 可以加入的房间（未锁定、不是私密房间且已到开放时间），按房间ID排序
+func (s *Server) joinableRooms() []*Room {
+	now := time.Now()
+	var rooms []*Room
+	for _, room := range s.GetAllRooms() {
+		if room.IsLocked() || room.IsPrivate() || !room.IsOpen(now) {
+			continue
+		}
+		rooms = append(rooms, room)
+	}
+	sort.Slice(rooms, func(i, j int) bool { return rooms[i].ID.Value < rooms[j].ID.Value })
+	return rooms
+}
+
+// handleListRooms 处理房间列表查询，客户端无需HTTP接口即可显示大厅
+func (s *Session) handleListRooms(page uint32) error {
+	rooms := s.server.joinableRooms()
+	start := min(int(page)*common.RoomListPageSize, len(rooms))
+	end := min(start+common.RoomListPageSize, len(rooms))
+
+	list := common.RoomList{
+		Rooms: make([]common.RoomListEntry, 0, end-start),
+		Page:  page,
+		Total: uint32(len(rooms)),
+	}
+	for _, room := range rooms[start:end] {
+		host := room.GetHost()
+		list.Rooms = append(list.Rooms, common.RoomListEntry{
+			RoomId:     room.ID,
+			Host:       common.UserInfo{ID: host.ID, Name: host.DisplayName()},
+			Players:    uint32(len(room.GetUsers())),
+			MaxPlayers: uint32(room.GetMaxUsers()),
+			State:      uint8(room.GetState().ToClientState(nil).Type),
+		})
+	}
+
+	return s.Send(common.ServerCommand{
+		Type:            common.ServerCmdListRooms,
+		ListRoomsResult: &common.Result[common.RoomList]{Ok: &list},
+	})
+}
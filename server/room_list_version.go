@@ -0,0 +1,118 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RoomListMaxWait 房间列表长轮询的最长等待时间
+const RoomListMaxWait = 30 * time.Second
+
+// roomListVersion 房间列表版本号，任何房间变化时递增，用于 GET /room 的条件请求
+type roomListVersion struct {
+	mu       sync.Mutex
+	epoch    int64 // 进程启动时间，避免重启后版本号重复
+	version  uint64
+	modified time.Time
+	changed  chan struct{} // 版本递增时关闭，供长轮询等待
+}
+
+// init 初始化版本号，调用方需持有锁
+func (v *roomListVersion) init() {
+	if v.changed == nil {
+		now := time.Now()
+		v.epoch = now.UnixNano()
+		v.modified = now
+		v.changed = make(chan struct{})
+	}
+}
+
+// bump 递增版本号并唤醒长轮询
+func (v *roomListVersion) bump() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.init()
+	v.version++
+	v.modified = time.Now()
+	close(v.changed)
+	v.changed = make(chan struct{})
+}
+
+// current 返回当前ETag、最后修改时间与变化通知
+func (v *roomListVersion) current() (string, time.Time, <-chan struct{}) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.init()
+	return fmt.Sprintf(`"%x-%d"`, v.epoch, v.version), v.modified, v.changed
+}
+
+// touchRoomList 标记房间列表已变化
+func (s *Server) touchRoomList() {
+	s.roomList.bump()
+}
+
+// RoomListETag 返回房间列表当前的ETag
+func (s *Server) RoomListETag() string {
+	etag, _, _ := s.roomList.current()
+	return etag
+}
+
+// roomListNotModified 判断条件请求的房间列表是否未变化（If-None-Match 优先于 If-Modified-Since）
+func roomListNotModified(r *http.Request, etag string, modified time.Time) bool {
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, tag := range strings.Split(match, ",") {
+			tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+			if tag == etag || tag == "*" {
+				return true
+			}
+		}
+		return false
+	}
+	if since := r.Header.Get("If-Modified-Since"); since != "" {
+		t, err := http.ParseTime(since)
+		return err == nil && !modified.Truncate(time.Second).After(t)
+	}
+	return false
+}
+
+// parseRoomListWait 解析长轮询等待秒数（wait 参数），超出上限时截断
+func parseRoomListWait(r *http.Request) time.Duration {
+	seconds, err := strconv.Atoi(r.URL.Query().Get("wait"))
+	if err != nil || seconds <= 0 {
+		return 0
+	}
+	wait := time.Duration(seconds) * time.Second
+	if wait > RoomListMaxWait {
+		wait = RoomListMaxWait
+	}
+	return wait
+}
+
+// waitRoomList 条件请求命中时处理长轮询；返回true表示已写入304响应
+func (h *HTTPServer) waitRoomList(w http.ResponseWriter, r *http.Request) bool {
+	etag, modified, changed := h.server.roomList.current()
+	if !roomListNotModified(r, etag, modified) {
+		return false
+	}
+
+	if wait := parseRoomListWait(r); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-changed:
+			return false
+		case <-timer.C:
+		case <-r.Context().Done():
+		case <-h.server.stopChan:
+		}
+	}
+
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", modified.UTC().Format(http.TimeFormat))
+	w.WriteHeader(http.StatusNotModified)
+	return true
+}
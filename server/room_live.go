@@ -0,0 +1,36 @@
+package server
+
+import (
+	"log"
+
+	"phira-mp/common"
+)
+
+// liveHolders 需要直播数据的持有者数量：直播模式下的观察者，以及内部订阅者（回放录制器）
+func (r *Room) liveHolders() int {
+	holders := r.tapCount()
+	if r.server.config.LiveMode {
+		holders += len(r.GetMonitors())
+	}
+	return holders
+}
+
+// refreshLive 根据持有者数量更新直播状态，变化时通知房间内所有人
+// 观察者加入、离开或切换身份后调用；最后一个观察者离开后关闭直播，玩家不再上传触摸数据
+func (r *Room) refreshLive() {
+	live := r.liveHolders() > 0
+	if r.live.Swap(live) == live {
+		return
+	}
+
+	if live {
+		log.Printf("房间 `%s` 开启直播", r.ID.Value)
+	} else {
+		log.Printf("房间 `%s` 已无观察者，关闭直播", r.ID.Value)
+	}
+	r.SendMessage(common.Message{
+		Type: common.MsgLiveRoom,
+		Live: live,
+	})
+	BroadcastRoomUpdate(r)
+}
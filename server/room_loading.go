@@ -0,0 +1,169 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"phira-mp/common"
+)
+
+// startLoading 进入谱面加载阶段，等待全员上报加载完成
+func (r *Room) startLoading() {
+	r.loadProgress.Range(func(key, _ interface{}) bool {
+		r.loadProgress.Delete(key)
+		return true
+	})
+	// 原版客户端无法上报加载进度，视为已加载
+	for _, u := range r.GetUsers() {
+		if !reportsLoadProgress(u) {
+			r.SetLoadProgress(u.ID, 100)
+		}
+	}
+
+	timeout := time.Duration(r.server.config.ChartLoadTimeout) * time.Second
+	r.loadMu.Lock()
+	if r.loadTimer != nil {
+		r.loadTimer.Stop()
+	}
+	r.loadDeadline = time.Now().Add(timeout)
+	r.loadTimer = time.AfterFunc(timeout, r.handleLoadTimeout)
+	r.loadMu.Unlock()
+
+	r.SetState(InternalStateLoading)
+	log.Printf("房间 `%s` 进入谱面加载阶段 (超时: %v)", r.ID.Value, timeout)
+	BroadcastRoomLog(r.ID.Value, "全员已准备，等待谱面加载")
+
+	r.BroadcastLoadProgress()
+	BroadcastRoomUpdate(r)
+	// 全员都是原版客户端时直接开始
+	r.checkAllLoaded()
+}
+
+// reportsLoadProgress 玩家的客户端能否上报加载进度（原版协议没有 LoadProgress 命令；离线的玩家按能上报处理，超时后视为放弃）
+func reportsLoadProgress(u *User) bool {
+	session := u.GetSession()
+	return session == nil || session.Stream.Protocol() >= common.ProtocolV2
+}
+
+// SetLoadProgress 记录玩家加载进度
+func (r *Room) SetLoadProgress(userID int32, progress uint8) {
+	if progress > 100 {
+		progress = 100
+	}
+	r.loadProgress.Store(userID, progress)
+}
+
+// GetLoadProgress 获取玩家加载进度
+func (r *Room) GetLoadProgress(userID int32) uint8 {
+	if v, ok := r.loadProgress.Load(userID); ok {
+		return v.(uint8)
+	}
+	return 0
+}
+
+// loadStatus 汇总当前加载进度
+func (r *Room) loadStatus() common.LoadStatus {
+	users := r.GetUsers()
+	status := common.LoadStatus{Total: uint32(len(users))}
+	sum := 0
+	for _, u := range users {
+		progress := r.GetLoadProgress(u.ID)
+		if progress >= 100 {
+			status.Loaded++
+		}
+		sum += int(progress)
+	}
+	if len(users) > 0 {
+		status.Progress = uint8(sum / len(users))
+	}
+
+	r.loadMu.Lock()
+	if remaining := time.Until(r.loadDeadline); remaining > 0 {
+		status.Remaining = uint32(remaining.Round(time.Second) / time.Second)
+	}
+	r.loadMu.Unlock()
+	return status
+}
+
+// BroadcastLoadProgress 广播整体加载进度
+func (r *Room) BroadcastLoadProgress() {
+	status := r.loadStatus()
+	r.Broadcast(common.ServerCommand{
+		Type:         common.ServerCmdLoadProgress,
+		LoadProgress: &status,
+	})
+}
+
+// checkAllLoaded 全员加载完成时开始游戏
+func (r *Room) checkAllLoaded() {
+	users := r.GetUsers()
+	if len(users) == 0 {
+		return
+	}
+	for _, u := range users {
+		if reportsLoadProgress(u) && r.GetLoadProgress(u.ID) < 100 {
+			return
+		}
+	}
+	r.finishLoading()
+}
+
+// handleLoadTimeout 加载超时，未完成的玩家视为放弃
+func (r *Room) handleLoadTimeout() {
+	if r.GetState() != InternalStateLoading || r.server.GetRoom(r.ID) != r {
+		return
+	}
+
+	for _, u := range r.GetUsers() {
+		if !reportsLoadProgress(u) || r.GetLoadProgress(u.ID) >= 100 {
+			continue
+		}
+		log.Printf("玩家 `%s(%d)` 在房间 `%s` 谱面加载超时，标记为放弃", u.Name, u.ID, r.ID.Value)
+		BroadcastRoomLog(r.ID.Value, fmt.Sprintf("玩家 %s(%d) 谱面加载超时", u.Name, u.ID))
+		r.aborted.Store(u.ID, true)
+		r.SendMessage(common.Message{
+			Type: common.MsgAbort,
+			User: u.ID,
+		})
+	}
+	r.finishLoading()
+}
+
+// stopLoadTimer 停止加载超时计时（服务器关闭时调用）
+func (r *Room) stopLoadTimer() {
+	r.loadMu.Lock()
+	defer r.loadMu.Unlock()
+	if r.loadTimer != nil {
+		r.loadTimer.Stop()
+		r.loadTimer = nil
+	}
+}
+
+// finishLoading 结束加载阶段并开始游戏（仅执行一次）
+func (r *Room) finishLoading() {
+	if !r.state.CompareAndSwap(int32(InternalStateLoading), int32(InternalStatePlaying)) {
+		return
+	}
+
+	r.stopLoadTimer()
+	r.startPlaying()
+	// 全员加载超时时对局直接结束
+	r.CheckAllReady()
+}
+
+// handleLoadProgress 处理谱面加载进度上报
+func (s *Session) handleLoadProgress(progress uint8) error {
+	room := s.User.GetRoom()
+	if room == nil || s.User.IsMonitor() || room.GetState() != InternalStateLoading {
+		return nil
+	}
+
+	if progress <= room.GetLoadProgress(s.User.ID) {
+		return nil
+	}
+	room.SetLoadProgress(s.User.ID, progress)
+	room.BroadcastLoadProgress()
+	room.checkAllLoaded()
+	return nil
+}
@@ -0,0 +1,203 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"phira-mp/common"
+)
+
+// ErrWrongPassword 加入有密码的房间时密码错误或未提供密码
+const ErrWrongPassword = "房间密码错误"
+
+// ErrPasswordQueue 有密码的房间不能排队加入（排队放行时无法校验密码）
+const ErrPasswordQueue = "该房间需要密码，请直接加入"
+
+// ErrPasswordBackoff 连续输错房间密码后须等待的提示
+const ErrPasswordBackoff = "密码错误次数过多，请 %d 秒后再试"
+
+// 输错房间密码的退避：前 passwordFreeAttempts 次不限制，之后每次错误的等待时间翻倍，最长 passwordBackoffMax
+// 按房间与用户记录（重连不会清零），防止在线穷举短密码
+const (
+	passwordFreeAttempts = 3
+	passwordBackoffBase  = 2 * time.Second
+	passwordBackoffMax   = 5 * time.Minute
+)
+
+// passwordAttempt 用户在房间输错密码的次数与下次允许尝试的时间
+type passwordAttempt struct {
+	fails   int
+	retryAt time.Time
+}
+
+// roomPassword 房间密码的加盐哈希，服务器不保存明文
+type roomPassword struct {
+	salt [16]byte
+	hash [sha256.Size]byte
+}
+
+// newRoomPassword 生成密码的加盐哈希
+func newRoomPassword(password string) *roomPassword {
+	p := &roomPassword{}
+	rand.Read(p.salt[:])
+	p.hash = p.sum(password)
+	return p
+}
+
+func (p *roomPassword) sum(password string) [sha256.Size]byte {
+	return sha256.Sum256(append(p.salt[:], password...))
+}
+
+// matches 密码是否正确（比较耗时与内容无关）
+func (p *roomPassword) matches(password string) bool {
+	sum := p.sum(password)
+	return subtle.ConstantTimeCompare(sum[:], p.hash[:]) == 1
+}
+
+// SetPassword 设置房间密码（空字符串表示取消），与锁定互相独立
+// 设置密码时清空等待队列：排队放行时无法校验密码，排队用户须输入密码直接加入
+func (r *Room) SetPassword(password string) {
+	r.passwordMu.Lock()
+	r.passwordAttempts = nil
+	r.passwordMu.Unlock()
+
+	if password == "" {
+		r.password.Store(nil)
+		return
+	}
+	r.password.Store(newRoomPassword(password))
+	if n := r.rejectQueue(ErrPasswordQueue); n > 0 {
+		log.Printf("房间 `%s` 设置了密码，移出等待队列中的 %d 名玩家", r.ID.Value, n)
+		r.BroadcastQueue()
+	}
+}
+
+// HasPassword 房间是否设置了密码
+func (r *Room) HasPassword() bool {
+	return r.password.Load() != nil
+}
+
+// CheckPassword 房间没有密码或密码正确时返回true
+func (r *Room) CheckPassword(password string) bool {
+	p := r.password.Load()
+	return p == nil || p.matches(password)
+}
+
+// passwordRetryAfter 用户还需等待多久才能再次尝试房间密码（0表示可以尝试）
+func (r *Room) passwordRetryAfter(userID int32, now time.Time) time.Duration {
+	r.passwordMu.Lock()
+	defer r.passwordMu.Unlock()
+	if a := r.passwordAttempts[userID]; a != nil && now.Before(a.retryAt) {
+		return a.retryAt.Sub(now)
+	}
+	return 0
+}
+
+// recordPasswordResult 记录一次密码尝试：输对时清除记录，输错时累计次数并计算退避
+func (r *Room) recordPasswordResult(userID int32, ok bool, now time.Time) {
+	r.passwordMu.Lock()
+	defer r.passwordMu.Unlock()
+	if ok {
+		delete(r.passwordAttempts, userID)
+		return
+	}
+	if r.passwordAttempts == nil {
+		r.passwordAttempts = make(map[int32]*passwordAttempt)
+	}
+	a := r.passwordAttempts[userID]
+	if a == nil {
+		a = &passwordAttempt{}
+		r.passwordAttempts[userID] = a
+	}
+	a.fails++
+	if over := a.fails - passwordFreeAttempts; over >= 0 {
+		wait := passwordBackoffMax
+		if over < 16 && passwordBackoffBase<<over < passwordBackoffMax {
+			wait = passwordBackoffBase << over
+		}
+		a.retryAt = now.Add(wait)
+	}
+}
+
+// SetPrivate 设置是否为私密房间：私密房间不出现在房间列表（GET /room 与 ListRooms）中，只能通过房间ID加入
+func (r *Room) SetPrivate(private bool) {
+	r.private.Store(private)
+}
+
+// IsPrivate 是否为私密房间
+func (r *Room) IsPrivate() bool {
+	return r.private.Load()
+}
+
+// handleSetPassword 处理房主修改房间密码与私密房间（空密码表示取消密码），已在房间内的成员不受影响
+func (s *Session) handleSetPassword(password string, private bool) error {
+	fail := func(msg string) error {
+		return s.Send(common.ServerCommand{
+			Type:              common.ServerCmdSetPassword,
+			SetPasswordResult: &common.Result[struct{}]{Err: strPtr(msg)},
+		})
+	}
+
+	room := s.User.GetRoom()
+	if room == nil {
+		return fail("不在房间中")
+	}
+	if room.GetHost().ID != s.User.ID {
+		return fail("只有房主可以修改房间密码")
+	}
+
+	room.SetPassword(password)
+	room.SetPrivate(private)
+	log.Printf("房间 `%s` 房主 %s(%d) 修改了房间密码（密码: %v，私密: %v）", room.ID.Value, s.User.Name, s.User.ID, password != "", private)
+	BroadcastRoomLog(room.ID.Value, fmt.Sprintf("房间密码: %v，私密房间: %v", password != "", private))
+	BroadcastRoomUpdate(room)
+
+	return s.Send(common.ServerCommand{
+		Type:              common.ServerCmdSetPassword,
+		SetPasswordResult: &common.Result[struct{}]{Ok: &struct{}{}},
+	})
+}
+
+// UpdateRoomPasswordRequest 修改房间密码请求
+type UpdateRoomPasswordRequest struct {
+	Password *string `json:"password"` // 空字符串表示取消密码（为nil时保持不变）
+	Private  *bool   `json:"private"`  // 为nil时保持不变
+}
+
+// handleAdminRoomPassword 处理管理员修改房间密码与私密房间
+func (h *HTTPServer) handleAdminRoomPassword(w http.ResponseWriter, r *http.Request, room *Room) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method-not-allowed")
+		return
+	}
+
+	var req UpdateRoomPasswordRequest
+	if err := parseBody(r, &req); err != nil || (req.Password == nil && req.Private == nil) {
+		writeError(w, http.StatusBadRequest, "bad-request")
+		return
+	}
+	if req.Password != nil && len(*req.Password) > common.RoomPasswordMaxLen {
+		writeError(w, http.StatusBadRequest, "bad-password")
+		return
+	}
+
+	if req.Password != nil {
+		room.SetPassword(*req.Password)
+	}
+	if req.Private != nil {
+		room.SetPrivate(*req.Private)
+	}
+	BroadcastRoomLog(room.ID.Value, fmt.Sprintf("管理员修改房间密码: %v，私密房间: %v", room.HasPassword(), room.IsPrivate()))
+	BroadcastRoomUpdate(room)
+
+	writeOK(w, map[string]interface{}{
+		"roomid":   room.ID.Value,
+		"password": room.HasPassword(),
+		"private":  room.IsPrivate(),
+	})
+}
@@ -0,0 +1,287 @@
+package server
+
+import (
+	"fmt"
+	"log"
+
+	"phira-mp/common"
+)
+
+// IsQueueEnabled 是否启用排队功能
+func (s *Server) IsQueueEnabled() bool {
+	return s.config.RoomQueueSize > 0
+}
+
+// Enqueue 将用户加入等待队列
+// 返回值：排队位置（从1开始），队列已满时返回0
+func (r *Room) Enqueue(user *User) int {
+	r.queue.Lock()
+	defer r.queue.Unlock()
+	for i, u := range r.queueList {
+		if u.ID == user.ID {
+			return i + 1
+		}
+	}
+	if len(r.queueList) >= r.server.config.RoomQueueSize {
+		return 0
+	}
+	r.queueList = append(r.queueList, user)
+	user.queued.Store(r)
+	return len(r.queueList)
+}
+
+// Dequeue 将用户移出等待队列
+func (r *Room) Dequeue(userID int32) bool {
+	r.queue.Lock()
+	defer r.queue.Unlock()
+	for i, u := range r.queueList {
+		if u.ID == userID {
+			r.queueList = append(r.queueList[:i], r.queueList[i+1:]...)
+			u.queued.Store((*Room)(nil))
+			return true
+		}
+	}
+	return false
+}
+
+// GetQueue 获取等待队列
+func (r *Room) GetQueue() []*User {
+	r.queue.Lock()
+	defer r.queue.Unlock()
+	result := make([]*User, len(r.queueList))
+	copy(result, r.queueList)
+	return result
+}
+
+// popQueue 取出队首用户
+func (r *Room) popQueue() *User {
+	r.queue.Lock()
+	defer r.queue.Unlock()
+	if len(r.queueList) == 0 {
+		return nil
+	}
+	user := r.queueList[0]
+	r.queueList = r.queueList[1:]
+	user.queued.Store((*Room)(nil))
+	return user
+}
+
+// hasFreeSlot 是否有空余玩家位置且可以加入
+func (r *Room) hasFreeSlot() bool {
+	return r.GetState() == InternalStateSelectChart && !r.IsFull()
+}
+
+// BroadcastQueue 向排队用户发送各自的位置，并向房主发送完整等待列表
+func (r *Room) BroadcastQueue() {
+	queue := r.GetQueue()
+	waiting := make([]common.UserInfo, 0, len(queue))
+	for i, u := range queue {
+		waiting = append(waiting, u.ToInfo())
+		u.Send(common.ServerCommand{
+			Type: common.ServerCmdQueueUpdate,
+			QueueUpdate: &common.QueueStatus{
+				RoomId:   r.ID,
+				Position: uint32(i + 1),
+			},
+		})
+	}
+
+	r.GetHost().Send(common.ServerCommand{
+		Type: common.ServerCmdQueueUpdate,
+		QueueUpdate: &common.QueueStatus{
+			RoomId:  r.ID,
+			Waiting: waiting,
+		},
+	})
+}
+
+// pushQueueFront 将用户放回队首（放行时位置已被他人占用）
+func (r *Room) pushQueueFront(user *User) {
+	r.queue.Lock()
+	defer r.queue.Unlock()
+	r.queueList = append([]*User{user}, r.queueList...)
+	user.queued.Store(r)
+}
+
+// AdmitQueued 有空位时按顺序放行排队用户
+func (r *Room) AdmitQueued() {
+	r.admitMu.Lock()
+	defer r.admitMu.Unlock()
+
+	admitted := false
+	for r.hasFreeSlot() {
+		user := r.popQueue()
+		if user == nil {
+			break
+		}
+		session := user.GetSession()
+		if session == nil || user.GetRoom() != nil {
+			continue
+		}
+		reason := r.joinError(user, false)
+		if reason == "" && r.HasPassword() {
+			reason = ErrPasswordQueue
+		}
+		if reason != "" {
+			log.Printf("玩家 `%s(%d)` 已不能加入房间 `%s`（%s），跳过排队放行", user.Name, user.ID, r.ID.Value, reason)
+			session.Send(common.ServerCommand{
+				Type:           common.ServerCmdJoinRoom,
+				JoinRoomResult: &common.Result[common.JoinRoomResponse]{Err: strPtr(reason)},
+			})
+			continue
+		}
+		if other := r.FindSameIP(user); other != nil {
+			log.Printf("玩家 `%s(%d)` 与房间 `%s` 内的 `%s(%d)` IP相同，跳过排队放行", user.Name, user.ID, r.ID.Value, other.Name, other.ID)
+			session.Send(common.ServerCommand{
+				Type:           common.ServerCmdJoinRoom,
+				JoinRoomResult: &common.Result[common.JoinRoomResponse]{Err: strPtr(ErrSameIP)},
+			})
+			continue
+		}
+		if err := hookJoin(r, user, false); err != nil {
+			session.Send(common.ServerCommand{
+				Type:           common.ServerCmdJoinRoom,
+				JoinRoomResult: &common.Result[common.JoinRoomResponse]{Err: strPtr(err.Error()), Code: common.ErrCodeRejected},
+			})
+			continue
+		}
+
+		if !r.AddUser(user, false) {
+			// 空位已被直接加入的玩家占用，保留该用户的排队位置
+			r.pushQueueFront(user)
+			break
+		}
+		log.Printf("玩家 `%s(%d)` 排队结束，进入房间 `%s`", user.Name, user.ID, r.ID.Value)
+		BroadcastRoomLog(r.ID.Value, fmt.Sprintf("玩家 %s(%d) 从等待队列进入房间", user.Name, user.ID))
+		session.joinedRoom(r, false)
+		admitted = true
+	}
+	if admitted {
+		r.BroadcastQueue()
+	}
+}
+
+// clearQueue 清空等待队列并通知排队用户（房间被移除时调用）
+func (r *Room) clearQueue() {
+	r.rejectQueue("")
+}
+
+// rejectQueue 清空等待队列并通知排队用户，reason 非空时随后以加入失败的结果告知原因
+// 返回值：被移出的用户数量
+func (r *Room) rejectQueue(reason string) int {
+	r.queue.Lock()
+	queue := r.queueList
+	r.queueList = nil
+	r.queue.Unlock()
+
+	for _, u := range queue {
+		u.queued.Store((*Room)(nil))
+		u.Send(common.ServerCommand{
+			Type:        common.ServerCmdQueueUpdate,
+			QueueUpdate: &common.QueueStatus{RoomId: r.ID},
+		})
+		if reason != "" {
+			u.Send(common.ServerCommand{
+				Type:           common.ServerCmdJoinRoom,
+				JoinRoomResult: &common.Result[common.JoinRoomResponse]{Err: strPtr(reason)},
+			})
+		}
+	}
+	return len(queue)
+}
+
+// GetQueuedRoom 获取用户正在排队的房间
+func (u *User) GetQueuedRoom() *Room {
+	r := u.queued.Load()
+	if r == nil {
+		return nil
+	}
+	return r.(*Room)
+}
+
+// LeaveQueue 退出等待队列
+// 返回值：用户此前是否在排队
+func (u *User) LeaveQueue() bool {
+	room := u.GetQueuedRoom()
+	if room == nil || !room.Dequeue(u.ID) {
+		return false
+	}
+	log.Printf("玩家 `%s(%d)` 退出房间 `%s` 的等待队列", u.Name, u.ID, room.ID.Value)
+	room.BroadcastQueue()
+	return true
+}
+
+// handleQueueJoin 处理排队加入房间
+func (s *Session) handleQueueJoin(roomId common.RoomId) error {
+	if !s.server.IsQueueEnabled() {
+		return s.Send(common.ServerCommand{
+			Type:            common.ServerCmdQueueJoin,
+			QueueJoinResult: &common.Result[struct{}]{Err: strPtr("排队功能未启用")},
+		})
+	}
+
+	if s.User.GetRoom() != nil {
+		return s.Send(common.ServerCommand{
+			Type:            common.ServerCmdQueueJoin,
+			QueueJoinResult: &common.Result[struct{}]{Err: strPtr("已在房间中")},
+		})
+	}
+
+	if s.User.GetQueuedRoom() != nil {
+		return s.Send(common.ServerCommand{
+			Type:            common.ServerCmdQueueJoin,
+			QueueJoinResult: &common.Result[struct{}]{Err: strPtr("已在排队中")},
+		})
+	}
+
+	room := s.server.GetRoom(roomId)
+	if room == nil {
+		return s.Send(common.ServerCommand{
+			Type:            common.ServerCmdQueueJoin,
+			QueueJoinResult: &common.Result[struct{}]{Err: strPtr("房间不存在")},
+		})
+	}
+
+	if reason := room.joinError(s.User, false); reason != "" {
+		return s.Send(common.ServerCommand{
+			Type:            common.ServerCmdQueueJoin,
+			QueueJoinResult: &common.Result[struct{}]{Err: strPtr(reason)},
+		})
+	}
+
+	if room.HasPassword() {
+		return s.Send(common.ServerCommand{
+			Type:            common.ServerCmdQueueJoin,
+			QueueJoinResult: &common.Result[struct{}]{Err: strPtr(ErrPasswordQueue)},
+		})
+	}
+
+	if room.FindSameIP(s.User) != nil {
+		return s.Send(common.ServerCommand{
+			Type:            common.ServerCmdQueueJoin,
+			QueueJoinResult: &common.Result[struct{}]{Err: strPtr(ErrSameIP)},
+		})
+	}
+
+	position := room.Enqueue(s.User)
+	if position == 0 {
+		return s.Send(common.ServerCommand{
+			Type:            common.ServerCmdQueueJoin,
+			QueueJoinResult: &common.Result[struct{}]{Err: strPtr("等待队列已满")},
+		})
+	}
+
+	log.Printf("玩家 `%s(%d)` 加入房间 `%s` 的等待队列 (位置: %d)", s.User.Name, s.User.ID, room.ID.Value, position)
+
+	if err := s.Send(common.ServerCommand{
+		Type:            common.ServerCmdQueueJoin,
+		QueueJoinResult: &common.Result[struct{}]{Ok: &struct{}{}},
+	}); err != nil {
+		return err
+	}
+
+	room.BroadcastQueue()
+	// 房间有空位时立即放行
+	room.AdmitQueued()
+	return nil
+}
@@ -0,0 +1,167 @@
+package server
+
+import (
+	"fmt"
+	"log"
+
+	"phira-mp/common"
+)
+
+// SwitchRole 在玩家列表与观察者列表之间移动用户
+// 返回值：是否切换成功（转为玩家时房间已满、转为观察者时观察者已满或用户不在对应列表中返回false）
+func (r *Room) SwitchRole(user *User, monitor bool) bool {
+	r.users.Lock()
+	defer r.users.Unlock()
+	r.monitors.Lock()
+	defer r.monitors.Unlock()
+
+	from, to := &r.userList, &r.monitorList
+	if monitor {
+		if r.monitorsFull() {
+			return false
+		}
+	} else {
+		if len(r.userList) >= r.GetMaxUsers() {
+			return false
+		}
+		from, to = &r.monitorList, &r.userList
+	}
+
+	for i, u := range *from {
+		if u.ID == user.ID {
+			*from = append((*from)[:i], (*from)[i+1:]...)
+			*to = append(*to, user)
+			user.SetMonitor(monitor)
+			if monitor {
+				r.leaveTeam(user.ID)
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// handleSwitchRole 处理玩家与观察者身份切换
+func (s *Session) handleSwitchRole(monitor bool) error {
+	room := s.User.GetRoom()
+	if room == nil {
+		return s.Send(common.ServerCommand{
+			Type:             common.ServerCmdSwitchRole,
+			SwitchRoleResult: &common.Result[struct{}]{Err: strPtr("不在房间中")},
+		})
+	}
+
+	if room.GetState() != InternalStateSelectChart {
+		return s.Send(common.ServerCommand{
+			Type:             common.ServerCmdSwitchRole,
+			SwitchRoleResult: &common.Result[struct{}]{Err: strPtr("游戏进行中")},
+		})
+	}
+
+	if s.User.IsMonitor() == monitor {
+		return s.Send(common.ServerCommand{
+			Type:             common.ServerCmdSwitchRole,
+			SwitchRoleResult: &common.Result[struct{}]{Err: strPtr("身份未改变")},
+		})
+	}
+
+	if !monitor && s.User.IsGuest() {
+		return s.Send(common.ServerCommand{
+			Type:             common.ServerCmdSwitchRole,
+			SwitchRoleResult: &common.Result[struct{}]{Err: strPtr(ErrGuestMonitorOnly)},
+		})
+	}
+
+	if !monitor && room.IsReplay() {
+		return s.Send(common.ServerCommand{
+			Type:             common.ServerCmdSwitchRole,
+			SwitchRoleResult: &common.Result[struct{}]{Err: strPtr(ErrReplayRoomMonitorOnly)},
+		})
+	}
+
+	if !monitor && !room.CanCompete(s.User.ID) {
+		return s.Send(common.ServerCommand{
+			Type:             common.ServerCmdSwitchRole,
+			SwitchRoleResult: &common.Result[struct{}]{Err: strPtr(ErrContestMonitorOnly)},
+		})
+	}
+
+	if !monitor {
+		// 观察者转为玩家视同以玩家身份加入，同样由插件决定是否放行
+		if err := hookJoin(room, s.User, false); err != nil {
+			return s.Send(common.ServerCommand{
+				Type:             common.ServerCmdSwitchRole,
+				SwitchRoleResult: &common.Result[struct{}]{Err: strPtr(err.Error()), Code: common.ErrCodeRejected},
+			})
+		}
+	}
+
+	if monitor {
+		if room.GetHost().ID == s.User.ID {
+			return s.Send(common.ServerCommand{
+				Type:             common.ServerCmdSwitchRole,
+				SwitchRoleResult: &common.Result[struct{}]{Err: strPtr("房主不能转为观察者")},
+			})
+		}
+		if !room.CanMonitor(s.User) {
+			return s.Send(common.ServerCommand{
+				Type:             common.ServerCmdSwitchRole,
+				SwitchRoleResult: &common.Result[struct{}]{Err: strPtr("无法观察")},
+			})
+		}
+		if s.server.shedding(LoadShedMonitors) {
+			return s.Send(common.ServerCommand{
+				Type:             common.ServerCmdSwitchRole,
+				SwitchRoleResult: &common.Result[struct{}]{Err: strPtr(ErrLoadShedMonitor)},
+			})
+		}
+	}
+
+	if !room.SwitchRole(s.User, monitor) {
+		reason := "房间已满"
+		if monitor {
+			reason = ErrMonitorLimit
+		}
+		return s.Send(common.ServerCommand{
+			Type:             common.ServerCmdSwitchRole,
+			SwitchRoleResult: &common.Result[struct{}]{Err: strPtr(reason)},
+		})
+	}
+
+	role := "玩家"
+	if monitor {
+		role = "观察者"
+	}
+	room.refreshLive()
+	log.Printf("玩家 `%s(%d)` 在房间 `%s` 切换为%s", s.User.Name, s.User.ID, room.ID.Value, role)
+	BroadcastRoomLog(room.ID.Value, fmt.Sprintf("%s(%d) 切换为%s", s.User.Name, s.User.ID, role))
+
+	if err := s.Send(common.ServerCommand{
+		Type:             common.ServerCmdSwitchRole,
+		SwitchRoleResult: &common.Result[struct{}]{Ok: &struct{}{}},
+	}); err != nil {
+		return err
+	}
+
+	// 以更新后的用户信息重新广播，客户端据此刷新成员身份
+	info := s.User.ToInfo()
+	room.Broadcast(common.ServerCommand{
+		Type:           common.ServerCmdOnJoinRoom,
+		OnJoinRoomUser: &info,
+	})
+	room.SendMessage(common.Message{
+		Type:    common.MsgChat,
+		User:    0,
+		Content: fmt.Sprintf("%s 切换为%s", s.User.Name, role),
+	})
+	BroadcastRoomUpdate(room)
+
+	if monitor {
+		// 空出的玩家位置放行排队用户
+		room.AdmitQueued()
+	} else {
+		// 官方房间无真实房主时，由转为玩家的用户接任
+		room.claimHost(s.User)
+	}
+	return nil
+}
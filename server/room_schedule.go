@@ -0,0 +1,182 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"time"
+
+	"phira-mp/common"
+)
+
+// RoomScheduleCheckInterval 房间关闭时间检查间隔
+const RoomScheduleCheckInterval = time.Second
+
+// DefaultRoomCloseWarning 到达关闭时间前提醒房间成员的秒数
+const DefaultRoomCloseWarning = 300
+
+// GetSchedule 房间的开放与关闭时间，未设置时为nil
+func (r *Room) GetSchedule() *common.RoomSchedule {
+	s, _ := r.schedule.Load().(*common.RoomSchedule)
+	return s
+}
+
+// SetSchedule 设置房间的开放与关闭时间（均为0或nil表示取消）
+func (r *Room) SetSchedule(s *common.RoomSchedule) {
+	if s != nil && s.OpenAt == 0 && s.CloseAt == 0 {
+		s = nil
+	}
+	r.schedule.Store(s)
+	r.closeWarned.Store(false)
+	r.closePending.Store(false)
+}
+
+// IsOpen 房间是否已到开放时间（未设置开放时间时始终开放）
+func (r *Room) IsOpen(now time.Time) bool {
+	s := r.GetSchedule()
+	return s == nil || s.OpenAt == 0 || now.UnixMilli() >= s.OpenAt
+}
+
+// validSchedule 检查开放与关闭时间：关闭时间须晚于当前时间与开放时间
+func validSchedule(s common.RoomSchedule, now time.Time) bool {
+	if s.OpenAt < 0 || s.CloseAt < 0 {
+		return false
+	}
+	if s.CloseAt == 0 {
+		return true
+	}
+	return s.CloseAt > now.UnixMilli() && s.CloseAt > s.OpenAt
+}
+
+// formatRemaining 剩余时长的提示文本（不足一分钟时按秒）
+func formatRemaining(d time.Duration) string {
+	if d < time.Minute {
+		return fmt.Sprintf("%d 秒", int(math.Ceil(d.Seconds())))
+	}
+	return fmt.Sprintf("%d 分钟", int(math.Ceil(d.Minutes())))
+}
+
+// checkSchedule 关闭前提醒房间成员，到达关闭时间后解散房间（或锁定）
+// 对局进行中到达关闭时间时等本局结束后再解散；官方房间解散后会立即重建，因此只锁定
+func (r *Room) checkSchedule(now time.Time, warn time.Duration) {
+	s := r.GetSchedule()
+	if s == nil || s.CloseAt == 0 {
+		return
+	}
+
+	left := time.UnixMilli(s.CloseAt).Sub(now)
+	if left > 0 {
+		if warn > 0 && left <= warn && !r.closeWarned.Swap(true) {
+			action := "解散"
+			if s.Lock || r.IsOfficial() {
+				action = "锁定"
+			}
+			r.SendMessage(common.Message{
+				Type:    common.MsgChat,
+				User:    0,
+				Content: fmt.Sprintf("房间将在 %s 后%s", formatRemaining(left), action),
+			})
+		}
+		return
+	}
+
+	if s.Lock || r.IsOfficial() {
+		r.SetSchedule(&common.RoomSchedule{OpenAt: s.OpenAt})
+		r.SetLocked(true)
+		log.Printf("房间 `%s` 到达关闭时间，已锁定", r.ID.Value)
+		r.SendMessage(common.Message{
+			Type:    common.MsgChat,
+			User:    0,
+			Content: "房间已到关闭时间，已锁定",
+		})
+		BroadcastRoomUpdate(r)
+		return
+	}
+
+	if r.GetState() != InternalStateSelectChart {
+		if !r.closePending.Swap(true) {
+			r.SendMessage(common.Message{
+				Type:    common.MsgChat,
+				User:    0,
+				Content: "房间已到关闭时间，将在本局结束后解散",
+			})
+		}
+		return
+	}
+
+	r.server.DisbandRoom(r, "房间已到关闭时间，已解散", "到达关闭时间")
+}
+
+// roomScheduleLoop 定期检查所有房间的关闭时间
+func (s *Server) roomScheduleLoop() {
+	warn := time.Duration(s.config.RoomCloseWarning) * time.Second
+
+	ticker := time.NewTicker(RoomScheduleCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.stopChan:
+			return
+		case now := <-ticker.C:
+			for _, room := range s.GetAllRooms() {
+				room.checkSchedule(now, warn)
+			}
+		}
+	}
+}
+
+// handleSetSchedule 处理房主设置房间的开放与关闭时间
+func (s *Session) handleSetSchedule(schedule common.RoomSchedule) error {
+	fail := func(msg string) error {
+		return s.Send(common.ServerCommand{
+			Type:              common.ServerCmdSetSchedule,
+			SetScheduleResult: &common.Result[struct{}]{Err: strPtr(msg)},
+		})
+	}
+
+	room := s.User.GetRoom()
+	if room == nil {
+		return fail("不在房间中")
+	}
+	if room.IsOfficial() {
+		return fail("官方房间的开放时间只能由管理员设置")
+	}
+	if room.GetHost().ID != s.User.ID {
+		return fail("只有房主可以设置开放时间")
+	}
+	if !validSchedule(schedule, time.Now()) {
+		return fail("无效的开放时间")
+	}
+
+	room.SetSchedule(&schedule)
+	log.Printf("房间 `%s` 房主 %s(%d) 设置开放时间: %d, 关闭时间: %d", room.ID.Value, s.User.Name, s.User.ID, schedule.OpenAt, schedule.CloseAt)
+	BroadcastRoomUpdate(room)
+
+	return s.Send(common.ServerCommand{
+		Type:              common.ServerCmdSetSchedule,
+		SetScheduleResult: &common.Result[struct{}]{Ok: &struct{}{}},
+	})
+}
+
+// DisbandRoom 解散房间：通知房间成员、停止回放录制并移除房间
+// 收不到房间关闭通知的旧版客户端直接断开连接，其余成员由 RemoveRoom 通知并移出房间
+func (s *Server) DisbandRoom(room *Room, notice, reason string) {
+	room.SendMessage(common.Message{
+		Type:    common.MsgChat,
+		User:    0,
+		Content: notice,
+	})
+
+	if recorder := s.GetReplayRecorder(); recorder != nil {
+		recorder.StopRecording(room.ID.Value)
+	}
+
+	closed := common.ServerCommand{Type: common.ServerCmdRoomClosed}
+	for _, user := range room.GetAllUsers() {
+		if session := user.GetSession(); session != nil && !common.ServerCommandSupported(session.Stream.Protocol(), &closed) {
+			go session.Disconnect(common.DisconnectReasonKicked, notice)
+		}
+	}
+
+	s.RemoveRoom(room.ID, reason)
+}
@@ -0,0 +1,100 @@
+package server
+
+import (
+	"phira-mp/common"
+)
+
+// RoomTap 房间内部数据订阅者（如回放录制器）
+// 与观察者一样接收玩家的触摸与判定数据，但不以用户身份出现在房间中
+type RoomTap interface {
+	OnTouches(room *Room, player int32, frames []common.TouchFrame)
+	OnJudges(room *Room, player int32, judges []common.JudgeEvent)
+}
+
+// AddTap 注册内部订阅者（房间因此保持直播，调用方负责 refreshLive）
+func (r *Room) AddTap(tap RoomTap) {
+	r.tapsMu.Lock()
+	defer r.tapsMu.Unlock()
+	for _, t := range r.taps {
+		if t == tap {
+			return
+		}
+	}
+	r.taps = append(r.taps, tap)
+}
+
+// RemoveTap 移除内部订阅者
+func (r *Room) RemoveTap(tap RoomTap) {
+	r.tapsMu.Lock()
+	defer r.tapsMu.Unlock()
+	for i, t := range r.taps {
+		if t == tap {
+			r.taps = append(r.taps[:i], r.taps[i+1:]...)
+			return
+		}
+	}
+}
+
+// tapCount 内部订阅者数量
+func (r *Room) tapCount() int {
+	r.tapsMu.RLock()
+	defer r.tapsMu.RUnlock()
+	return len(r.taps)
+}
+
+// getTaps 内部订阅者快照
+func (r *Room) getTaps() []RoomTap {
+	r.tapsMu.RLock()
+	defer r.tapsMu.RUnlock()
+	return append([]RoomTap(nil), r.taps...)
+}
+
+// forwardTouches 将玩家触摸数据转发给观察者与内部订阅者
+func (r *Room) forwardTouches(player int32, frames []common.TouchFrame) {
+	if r.server.shedding(LoadShedCoalesce) {
+		r.touchBatch.add(r, player, frames)
+	} else {
+		r.BroadcastMonitors(common.ServerCommand{
+			Type:          common.ServerCmdTouches,
+			TouchesPlayer: player,
+			TouchesFrames: frames,
+		})
+	}
+	for _, tap := range r.getTaps() {
+		tap.OnTouches(r, player, frames)
+	}
+}
+
+// forwardJudges 将玩家判定数据转发给观察者与内部订阅者
+func (r *Room) forwardJudges(player int32, judges []common.JudgeEvent) {
+	r.BroadcastMonitors(common.ServerCommand{
+		Type:         common.ServerCmdJudges,
+		JudgesPlayer: player,
+		JudgesEvents: judges,
+	})
+	for _, tap := range r.getTaps() {
+		tap.OnJudges(r, player, judges)
+	}
+}
+
+// forwardScore 将玩家实时成绩转发给观察者与WebSocket订阅者（不写入回放）
+func (r *Room) forwardScore(player int32, score common.LiveScore) {
+	if r.IsLive() {
+		r.BroadcastMonitors(common.ServerCommand{
+			Type:              common.ServerCmdScoreUpdate,
+			ScoreUpdatePlayer: player,
+			ScoreUpdate:       &score,
+		})
+	}
+	BroadcastRoomScore(r.ID.Value, player, score)
+}
+
+// OnTouches 录制触摸数据（实现 RoomTap）
+func (r *ReplayRecorder) OnTouches(room *Room, player int32, frames []common.TouchFrame) {
+	r.RecordTouch(room.ID.Value, player, frames)
+}
+
+// OnJudges 录制判定数据（实现 RoomTap）
+func (r *ReplayRecorder) OnJudges(room *Room, player int32, judges []common.JudgeEvent) {
+	r.RecordJudge(room.ID.Value, player, judges)
+}
@@ -0,0 +1,148 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"phira-mp/common"
+)
+
+// DefaultScoreSubmitMaxPayload 成绩数据默认最大字节数
+const DefaultScoreSubmitMaxPayload = 64 * 1024
+
+// ScoreSubmitConfig 成绩代提交配置
+type ScoreSubmitConfig struct {
+	URL        string `yaml:"url"`         // 成绩提交地址（POST，请求体为客户端原样上传的数据，返回与 /record/{id} 相同格式的JSON）
+	Timeout    int    `yaml:"timeout"`     // 请求超时秒数（默认10）
+	MaxPayload int    `yaml:"max_payload"` // 成绩数据最大字节数（默认65536）
+}
+
+// Enabled 是否启用成绩代提交
+func (c ScoreSubmitConfig) Enabled() bool {
+	return c.URL != ""
+}
+
+// ScoreSubmitter 将客户端成绩数据转发至成绩服务
+type ScoreSubmitter struct {
+	config ScoreSubmitConfig
+	client *http.Client
+}
+
+// NewScoreSubmitter 创建成绩代提交客户端
+func NewScoreSubmitter(config ScoreSubmitConfig) *ScoreSubmitter {
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultPhiraAPITimeout
+	}
+	if config.MaxPayload <= 0 {
+		config.MaxPayload = DefaultScoreSubmitMaxPayload
+	}
+	return &ScoreSubmitter{
+		config: config,
+		client: &http.Client{Timeout: time.Duration(config.Timeout) * time.Second},
+	}
+}
+
+// Submit 以玩家身份提交成绩，返回成绩服务生成的记录
+// 提交不可安全重放，因此不做重试
+func (s *ScoreSubmitter) Submit(token, payload string) (*Record, error) {
+	req, err := http.NewRequest(http.MethodPost, s.config.URL, bytes.NewBufferString(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	stats := upstream.endpoint("score_submit")
+	start := time.Now()
+	resp, err := s.client.Do(req)
+	if err != nil {
+		stats.observe(time.Since(start), true)
+		return nil, err
+	}
+	defer resp.Body.Close()
+	stats.observe(time.Since(start), resp.StatusCode >= 500)
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 256))
+		return nil, fmt.Errorf("submit failed: %d %s", resp.StatusCode, bytes.TrimSpace(body))
+	}
+
+	var record Record
+	if err := json.NewDecoder(resp.Body).Decode(&record); err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+// handleSubmitResult 处理成绩代提交：转发成绩数据后直接以返回的记录完成 Played，
+// 避免客户端自行上传后成绩尚未可查询导致 Played 失败
+func (s *Session) handleSubmitResult(payload string) error {
+	fail := func(msg string) error {
+		return s.Send(common.ServerCommand{
+			Type:               common.ServerCmdSubmitResult,
+			SubmitResultResult: &common.Result[int64]{Err: strPtr(msg)},
+		})
+	}
+
+	submitter := s.server.scoreSubmitter
+	if submitter == nil {
+		return fail("服务器未启用成绩代提交")
+	}
+	if s.User.IsGuest() {
+		return fail("游客不能提交成绩")
+	}
+
+	room := s.User.GetRoom()
+	if room == nil {
+		return fail("不在房间中")
+	}
+	if room.GetState() != InternalStatePlaying {
+		return fail("未在游戏中")
+	}
+	if _, aborted := room.aborted.Load(s.User.ID); aborted {
+		return fail("已放弃")
+	}
+	if _, hasResult := room.results.Load(s.User.ID); hasResult {
+		return fail("已上传")
+	}
+	if payload == "" || len(payload) > submitter.config.MaxPayload {
+		return fail("成绩数据无效")
+	}
+
+	record, err := submitter.Submit(s.token, payload)
+	if err != nil {
+		log.Printf("用户 `%s(%d)` 成绩代提交失败: %v", s.User.Name, s.User.ID, err)
+		return fail("提交失败")
+	}
+	if record.Player == 0 {
+		record.Player = s.User.ID
+	}
+	if record.Player != s.User.ID {
+		return fail("无效记录")
+	}
+
+	// 转发期间房间状态可能已变化，重新检查
+	if room.GetState() != InternalStatePlaying {
+		return fail("未在游戏中")
+	}
+	if _, hasResult := room.results.Load(s.User.ID); hasResult {
+		return fail("已上传")
+	}
+	if !s.server.consumeRecord(room, s.User, record) {
+		return fail("成绩已被使用")
+	}
+
+	s.recordPlayed(room, record)
+
+	return s.Send(common.ServerCommand{
+		Type:               common.ServerCmdSubmitResult,
+		SubmitResultResult: &common.Result[int64]{Ok: &record.ID},
+	})
+}
@@ -1,10 +1,11 @@
 package server
 
 import (
-	"fmt"
 	"log"
 	"net"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"phira-mp/common"
 
@@ -19,16 +20,74 @@ type Server struct {
 	users    sync.Map // map[int32]*User
 	rooms    sync.Map // map[common.RoomId]*Room
 
-	listener net.Listener
+	listener  net.Listener
+	address   string     // 游戏端口监听地址（Start 时设置）
+	lifecycle *Lifecycle // 组件启动与停止顺序
 
 	httpServer     *HTTPServer
 	replayRecorder *ReplayRecorder
+	globalChat     *GlobalChat
+	monitorChat    *GlobalChat // 观察者聊天发言限流
+	emotes         *GlobalChat // 表情发送限流
+	activityStats  *ActivityStats
+	recordProvider RecordProvider
+	scoreSubmitter *ScoreSubmitter // 未配置成绩代提交时为nil
+	recordLedger   *RecordLedger   // 已使用成绩（record_ledger_size 为0时为nil）
+	gameHistory    *GameHistory    // 对局历史（game_history_size 为0时为nil）
+	recentUsers    *RecentUsers    // 最近离线用户（recent_users_size 为0时为nil）
+	commandLatency *CommandLatency // 各命令类型的处理耗时
+	desyncs        atomic.Int64    // 关键命令超时未确认、重新同步的次数
+	fetchPool      *fetchPool      // 谱面与成绩查询（fetch_workers 为0时同步执行）
+	loadShed       *LoadShedder    // 过载降级（见 load_shed.go）
+	bytesSent      atomic.Int64    // 游戏连接写出的字节数
+	adminDeferred  atomic.Bool     // 降级时已安排推迟的管理员全量快照
+
+	// 未在 auth_timeout 内完成认证而被断开的连接数（见 auth_timeout.go）
+	reapedHandshake atomic.Int64 // 未完成握手
+	reapedAuth      atomic.Int64 // 已握手但未完成认证
+
+	// 按原因统计的断开连接次数（见 disconnect.go）
+	disconnects [common.DisconnectReasonCount]atomic.Int64
+
+	guestSeq atomic.Int32 // 游客编号（递增）
+	botSeq   atomic.Int32 // 模拟玩家编号（递增）
+
+	roomList roomListVersion // 房间列表版本号（GET /room 条件请求）
+
+	ready    chan struct{} // 开始接受连接后关闭
+	stopChan chan struct{}
 }
 
 // NewServer 创建新服务器
 func NewServer(config ServerConfig) *Server {
 	server := &Server{
-		config: config,
+		config:         config,
+		ready:          make(chan struct{}),
+		stopChan:       make(chan struct{}),
+		globalChat:     NewGlobalChat(),
+		monitorChat:    NewGlobalChat(),
+		emotes:         NewGlobalChat(),
+		commandLatency: NewCommandLatency(),
+		loadShed:       NewLoadShedder(config.LoadShedCPU, config.LoadShedBandwidth),
+	}
+	server.fetchPool = newFetchPool(config.FetchWorkers, server.stopChan)
+	server.lifecycle = server.newLifecycle()
+	common.SetDecodeLimits(common.DecodeLimits{
+		TouchFrames: config.MaxTouchFrames,
+		TouchPoints: config.MaxTouchPoints,
+		JudgeEvents: config.MaxJudgeEvents,
+	})
+	common.SetFrameLimits(common.FrameLimits{
+		Default:  config.MaxFrameSize,
+		Commands: config.MaxFrameSizes,
+	})
+
+	// 应用Phira主站API配置
+	ConfigurePhiraAPI(config.PhiraAPI)
+	server.recordProvider = newRecordProvider(config.RecordProvider)
+	if config.ScoreSubmit.Enabled() {
+		log.Printf("已启用成绩代提交: %s", config.ScoreSubmit.URL)
+		server.scoreSubmitter = NewScoreSubmitter(config.ScoreSubmit)
 	}
 
 	// 创建HTTP配置
@@ -45,21 +104,45 @@ func NewServer(config ServerConfig) *Server {
 	// 创建回放录制器
 	server.replayRecorder = NewReplayRecorder(server.httpServer)
 
+	// 加载活动统计
+	server.activityStats = NewActivityStats(server.getActivityStatsPath())
+	if err := server.activityStats.Load(); err != nil {
+		log.Printf("加载活动统计失败: %v", err)
+	}
+
+	if config.RecentUsersSize > 0 {
+		server.recentUsers = NewRecentUsers(config.RecentUsersSize)
+	}
+
+	// 加载已使用成绩
+	if config.RecordLedgerSize > 0 {
+		server.recordLedger = NewRecordLedger(server.getRecordLedgerPath(), config.RecordLedgerSize)
+		if err := server.recordLedger.Load(); err != nil {
+			log.Printf("加载已使用成绩失败: %v", err)
+		}
+	}
+
+	// 加载对局历史
+	if config.GameHistorySize > 0 {
+		server.gameHistory = NewGameHistory(server.getGameHistoryPath(), config.GameHistorySize)
+		if err := server.gameHistory.Load(); err != nil {
+			log.Printf("加载对局历史失败: %v", err)
+		}
+	}
+
 	return server
 }
 
 // Start 启动服务器
 func (s *Server) Start(address string) error {
-	// 启动HTTP服务
-	if err := s.httpServer.Start(); err != nil {
-		return fmt.Errorf("启动HTTP服务失败: %w", err)
-	}
-
-	listener, err := net.Listen("tcp", address)
-	if err != nil {
+	// 按依赖顺序启动各组件，最后监听游戏端口（见 newLifecycle）
+	s.address = address
+	if err := s.lifecycle.Start(); err != nil {
+		s.Stop()
 		return err
 	}
-	s.listener = listener
+	listener := s.listener
+	close(s.ready)
 
 	log.Printf("服务器正在偷听 %s", address)
 
@@ -79,59 +162,88 @@ func (s *Server) Start(address string) error {
 	}
 }
 
-// Stop 停止服务器
-func (s *Server) Stop() {
-	// 停止HTTP服务
-	if s.httpServer != nil {
-		s.httpServer.Stop()
-	}
-
-	// 停止所有回放录制
-	if s.replayRecorder != nil {
-		s.replayRecorder.StopAllRecordings()
-	}
+// Ready 服务器开始接受连接（HTTP服务已启动、游戏端口已监听）后关闭的通道
+func (s *Server) Ready() <-chan struct{} {
+	return s.ready
+}
 
-	if s.listener != nil {
-		s.listener.Close()
+// Stop 停止服务器（可重复调用），每个组件最多等待 shutdown_timeout 秒
+func (s *Server) Stop() {
+	select {
+	case <-s.stopChan:
+	default:
+		close(s.stopChan)
 	}
 
-	// 关闭所有会话
-	s.sessions.Range(func(key, value interface{}) bool {
-		if session, ok := value.(*Session); ok {
-			session.Stop()
-		}
-		return true
-	})
+	// 依次停止监听、会话、房间、回放录制、HTTP/WebSocket 与存储
+	s.lifecycle.Stop()
 }
 
 // handleConnection 处理新连接
 func (s *Server) handleConnection(conn net.Conn) {
+	// 握手（含PROXY Protocol头）须在认证超时内完成
+	deadline := s.setAuthDeadline(conn)
+
 	// 如果启用了PROXY Protocol，尝试解析真实IP
+	transport := TransportTCP
 	if s.config.TCPProxyProtocol {
 		info, _, err := ParseProxyProtocol(conn, nil)
 		if err == nil && info != nil && info.SourceIP != nil {
 			// 包装连接以使用真实IP
 			conn = NewProxyConn(conn, info)
+			transport = TransportTCPProxy
 			log.Printf("[PROXY] 解析到真实IP: %s", info.SourceIP.String())
 		}
 	}
 
+	// 统计出站字节数（过载降级按出站带宽判断）
+	conn = &countingConn{Conn: conn, sent: &s.bytesSent}
+
 	// 创建Stream
-	stream, err := common.NewServerStream(conn)
+	var features common.StreamFeatures
+	if s.config.StreamCompression {
+		features |= common.FeatureDeflate
+	}
+	if s.config.StreamChecksum {
+		features |= common.FeatureChecksum
+	}
+	stream, err := common.NewServerStream(conn, features)
 	if err != nil {
-		log.Printf("创建流失败: %v", err)
+		if isTimeout(err) && deadline {
+			s.reapedHandshake.Add(1)
+			log.Printf("连接 %s 未在 %v 内完成握手，断开连接", conn.RemoteAddr(), s.authTimeout())
+		} else {
+			log.Printf("创建流失败: %v", err)
+		}
 		conn.Close()
 		return
 	}
+	if deadline {
+		conn.SetDeadline(time.Time{})
+	}
+
+	// 服务器停止过程中完成握手的连接不再创建会话
+	select {
+	case <-s.stopChan:
+		stream.Close()
+		return
+	default:
+	}
 
 	// 生成UUID
 	id := uuid.New()
 
 	// 创建Session
 	session := NewSession(id, stream, s)
+	session.Transport = transport
 	s.sessions.Store(id, session)
+	s.observePeaks()
 
-	log.Printf("新连接来自 %s (ID: %s, 版本: %d)", conn.RemoteAddr(), id, stream.Version())
+	if f := stream.Features(); f != 0 {
+		log.Printf("新连接来自 %s (ID: %s, 版本: %d, 连接特性: %s)", conn.RemoteAddr(), id, stream.Version(), f)
+	} else {
+		log.Printf("新连接来自 %s (ID: %s, 版本: %d)", conn.RemoteAddr(), id, stream.Version())
+	}
 
 	// 启动会话
 	session.Start()
@@ -154,12 +266,18 @@ func (s *Server) GetSession(id uuid.UUID) *Session {
 // AddUser 添加用户
 func (s *Server) AddUser(user *User) {
 	s.users.Store(user.ID, user)
+	if s.recentUsers != nil {
+		s.recentUsers.Remove(user.ID)
+	}
+	s.observePeaks()
 	log.Printf("用户已添加: %d (%s)", user.ID, user.Name)
 }
 
 // RemoveUser 移除用户
 func (s *Server) RemoveUser(id int32) {
-	s.users.Delete(id)
+	if val, ok := s.users.LoadAndDelete(id); ok && s.recentUsers != nil {
+		s.recentUsers.Add(val.(*User), time.Now())
+	}
 	log.Printf("用户已移除: %d", id)
 }
 
@@ -174,18 +292,31 @@ func (s *Server) GetUser(id int32) *User {
 // AddRoom 添加房间
 func (s *Server) AddRoom(room *Room) {
 	s.rooms.Store(room.ID, room)
+	s.touchRoomList()
+	s.observePeaks()
 	host := room.GetHost()
 	log.Printf("玩家 %s(%d) 创建了房间 %s", host.Name, host.ID, room.ID.Value)
 }
 
 // RemoveRoom 移除房间
 func (s *Server) RemoveRoom(id common.RoomId, reason string) {
-	s.rooms.Delete(id)
+	if val, ok := s.rooms.LoadAndDelete(id); ok {
+		room := val.(*Room)
+		// 通知排队用户与仍在房间内的成员房间已移除
+		room.clearQueue()
+		room.notifyClosed(reason)
+		s.touchRoomList()
+	}
 	if reason != "" {
 		log.Printf("房间已移除: %s (原因: %s)", id.Value, reason)
 	} else {
 		log.Printf("房间已移除: %s", id.Value)
 	}
+
+	// 官方房间移除后立即重建
+	if tpl := s.findRoomTemplate(id); tpl != nil {
+		s.ensureOfficialRoom(tpl)
+	}
 }
 
 // GetRoom 获取房间
@@ -229,9 +360,10 @@ func (s *Server) GetStats() map[string]interface{} {
 	})
 
 	return map[string]interface{}{
-		"sessions": sessionCount,
-		"users":    userCount,
-		"rooms":    roomCount,
+		"sessions":    sessionCount,
+		"users":       userCount,
+		"rooms":       roomCount,
+		"disconnects": s.DisconnectCounts(),
 	}
 }
 
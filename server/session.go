@@ -1,9 +1,11 @@
 package server
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"phira-mp/common"
@@ -22,18 +24,48 @@ type Session struct {
 	stopped       bool
 	disconnecting bool // 是否正在断开连接，避免重复处理
 	lastPing      time.Time
-	authenticated bool
+	authenticated atomic.Bool
+	guestLimiter  *commandLimiter // 游客命令限流（仅游客会话）
+	fetchingChart atomic.Bool     // SelectChart 的谱面查询是否在工作池中进行
+	token         string          // Phira token（成绩代提交时转发给成绩服务）
+
+	validatingChart atomic.Bool // ValidateChart 的谱面查询是否在工作池中进行
+
+	// 会话有效期（见 session_lifetime.go）
+	lifeMu       sync.Mutex
+	authAt       time.Time   // 最近一次认证或校验通过的时间
+	reauthBy     time.Time   // 要求重新认证的截止时间（零值表示无需重新认证）
+	revalidating atomic.Bool // 是否正在后台校验token
+
+	acks *ackTracker // 关键命令确认（见 desync.go，未开启或客户端不支持时为nil）
+
+	// 断开连接的原因（见 disconnect.go，nil表示尚未断开或客户端关闭了连接）
+	cause atomic.Pointer[disconnectCause]
+
+	// 是否因发送队列已满而断开（见 User.sendRoom，只断开一次）
+	overflowed atomic.Bool
+
+	// 连接信息
+	ConnectedAt time.Time
+	Transport   string // tcp, tcp+proxy
 }
 
 // NewSession 创建新会话
 func NewSession(id uuid.UUID, stream *common.ServerStream, server *Server) *Session {
-	return &Session{
+	s := &Session{
 		ID:       id,
 		Stream:   stream,
 		server:   server,
 		stopChan: make(chan struct{}),
 		lastPing: time.Now(),
+
+		ConnectedAt: time.Now(),
+		Transport:   TransportTCP,
+	}
+	if server != nil && server.config.AckDelivery && stream.Protocol() >= common.ProtocolV14 {
+		s.acks = &ackTracker{}
 	}
+	return s
 }
 
 // Start 启动会话处理
@@ -53,48 +85,72 @@ func (s *Session) Stop() {
 
 // Send 发送命令
 func (s *Session) Send(cmd common.ServerCommand) error {
+	s.prepare(&cmd)
 	return s.Stream.Send(cmd)
 }
 
+// TrySend 发送命令但不等待发送队列，队列已满时返回 common.ErrSendQueueFull
+func (s *Session) TrySend(cmd common.ServerCommand) error {
+	s.prepare(&cmd)
+	return s.Stream.TrySend(cmd)
+}
+
+// prepare 发送前补全命令
+func (s *Session) prepare(cmd *common.ServerCommand) {
+	// 房间消息在首次发送时记录服务器时间（广播的消息共用同一时间）
+	if cmd.Message != nil && cmd.Message.Time == 0 {
+		cmd.Message.Time = time.Now().UnixMilli()
+	}
+	if s.acks != nil && isCriticalCommand(cmd.Type) {
+		s.acks.stamp(cmd)
+	}
+}
+
 // recvLoop 接收循环
 func (s *Session) recvLoop() {
 	for {
 		select {
 		case <-s.stopChan:
+			s.handleDisconnect()
 			return
 		default:
 		}
 
 		cmd, err := s.Stream.Recv()
 		if err != nil {
-			RateLimitedLog("会话 %s 接收错误: %v", s.ID, err)
+			if errors.Is(err, common.ErrFrameTooLarge) {
+				var userID int32
+				if s.User != nil {
+					userID = s.User.ID
+				}
+				RateLimitedLog("[安全] 会话 %s (用户 %d) 发送超长命令，已断开: %v", s.ID, userID, err)
+			} else {
+				RateLimitedLog("会话 %s 接收错误: %v", s.ID, err)
+			}
+			// 连接未被服务器关闭时，接收错误来自帧校验失败或命令无法解码
+			if !s.stopped {
+				s.notifyDisconnect(common.DisconnectReasonProtocolError, err.Error())
+			}
 			s.handleDisconnect()
 			return
 		}
 
 		s.lastPing = time.Now()
-
-		// 选择谱面命令输出详细日志（常规输出）
-		if cmd.Type == common.ClientCmdSelectChart && s.User != nil {
-			room := s.User.GetRoom()
-			if room != nil {
-				chart, _ := FetchChart(cmd.ChartID)
-				if chart != nil {
-					log.Printf("玩家 `%s(%d)` 在房间 `%s` 选择了谱面 `%s(%d)`", s.User.Name, s.User.ID, room.ID, chart.Name, chart.ID)
-				} else {
-					log.Printf("玩家 `%s(%d)` 在房间 `%s` 选择了谱面 `ID(%d)`", s.User.Name, s.User.ID, room.ID, cmd.ChartID)
-				}
-			} else {
-				log.Printf("玩家 `%s(%d)` 选择了谱面 `ID(%d)`", s.User.Name, s.User.ID, cmd.ChartID)
-			}
+		if s.User != nil && cmd.Type != common.ClientCmdPing && cmd.Type != common.ClientCmdAck {
+			s.User.MarkActive()
 		}
 
-		// 记录接收到的命令类型（只在 DEBUG 模式下输出）
-		if s.server.IsDebugEnabled() {
-			log.Printf("[DEBUG] 会话 %s 收到命令: 类型=%d", s.ID, cmd.Type)
+		// 记录接收到的命令（debug_commands 输出完整JSON，否则 DEBUG 模式下只输出类型）
+		if s.server.config.DebugCommands {
+			log.Printf("[DEBUG] 会话 %s 收到命令: %s", s.ID, DebugCommandJSON(cmd))
+		} else if s.server.IsDebugEnabled() {
+			log.Printf("[DEBUG] 会话 %s 收到命令: 类型=%s", s.ID, cmd.Type)
 		}
 
-		if err := s.handleCommand(cmd); err != nil {
+		start := time.Now()
+		err = s.handleCommand(cmd)
+		s.server.commandLatency.Observe(cmd.Type, time.Since(start), err != nil)
+		if err != nil {
 			RateLimitedLog("会话 %s 处理命令错误: %v", s.ID, err)
 		}
 	}
@@ -111,7 +167,24 @@ func (s *Session) heartbeatCheck() {
 			return
 		case <-ticker.C:
 			if time.Since(s.lastPing) > common.HeartbeatDisconnectTimeout {
-				log.Printf("会话 %s 心跳超时", s.ID)
+				// 接收循环已因读取出错退出时，是客户端关闭了连接而不是没有发送心跳
+				if err := s.Stream.ReadError(); err != nil {
+					log.Printf("会话 %s 连接已关闭: %v", s.ID, err)
+					s.notifyDisconnect(common.DisconnectReasonClosed, "")
+				} else {
+					log.Printf("会话 %s 心跳超时", s.ID)
+					s.notifyDisconnect(common.DisconnectReasonHeartbeatTimeout, "心跳超时")
+				}
+				s.handleDisconnect()
+				return
+			}
+			if s.authExpired(time.Now()) {
+				s.notifyDisconnect(common.DisconnectReasonAuthTimeout, "未在规定时间内完成认证")
+				s.handleDisconnect()
+				return
+			}
+			if s.checkLifetime(time.Now()) {
+				s.notifyDisconnect(common.DisconnectReasonSessionExpired, "会话已过期，请重新登录")
 				s.handleDisconnect()
 				return
 			}
@@ -127,8 +200,12 @@ func (s *Session) handleDisconnect() {
 	}
 	s.disconnecting = true
 
+	s.recordDisconnect()
 	s.server.RemoveSession(s.ID)
 	if s.User != nil {
+		// 断线后不再保留排队位置
+		s.User.LeaveQueue()
+
 		// 只有当前会话还是用户的活跃会话时，才调用 Dangle
 		// 这避免了旧会话在用户重连后错误地触发 Dangle
 		if s.User.GetSession() == s {
@@ -142,17 +219,25 @@ func (s *Session) handleDisconnect() {
 func (s *Session) handleCommand(cmd common.ClientCommand) error {
 	// Ping
 	if cmd.Type == common.ClientCmdPing {
-		return s.Send(common.ServerCommand{Type: common.ServerCmdPong})
+		return s.handlePing(cmd.Ping)
 	}
 
 	// 未认证时只允许Authenticate
-	if !s.authenticated {
+	if !s.authenticated.Load() {
 		if cmd.Type != common.ClientCmdAuthenticate {
 			return fmt.Errorf("未认证")
 		}
+		if cmd.Token == "" && s.server.IsGuestModeEnabled() {
+			return s.handleGuestAuthenticate()
+		}
 		return s.handleAuthenticate(cmd.Token)
 	}
 
+	// 游客命令限流，超出频率的命令直接丢弃
+	if s.guestLimiter != nil && !s.guestLimiter.Allow(time.Now()) {
+		return fmt.Errorf("游客 `%s(%d)` 命令过于频繁", s.User.Name, s.User.ID)
+	}
+
 	// 已认证，处理其他命令
 	switch cmd.Type {
 	case common.ClientCmdChat:
@@ -161,10 +246,12 @@ func (s *Session) handleCommand(cmd common.ClientCommand) error {
 		return s.handleTouches(cmd.Frames)
 	case common.ClientCmdJudges:
 		return s.handleJudges(cmd.Judges)
+	case common.ClientCmdFrameBatch:
+		return s.handleFrameBatch(cmd.Frames, cmd.Judges)
 	case common.ClientCmdCreateRoom:
-		return s.handleCreateRoom(cmd.RoomId)
+		return s.handleCreateRoom(cmd.RoomId, cmd.Password)
 	case common.ClientCmdJoinRoom:
-		return s.handleJoinRoom(cmd.RoomId, cmd.Monitor)
+		return s.handleJoinRoom(cmd.RoomId, cmd.Monitor, cmd.Password)
 	case common.ClientCmdLeaveRoom:
 		return s.handleLeaveRoom()
 	case common.ClientCmdLockRoom:
@@ -173,6 +260,10 @@ func (s *Session) handleCommand(cmd common.ClientCommand) error {
 		return s.handleCycleRoom(cmd.Cycle)
 	case common.ClientCmdSelectChart:
 		return s.handleSelectChart(cmd.ChartID)
+	case common.ClientCmdValidateChart:
+		return s.handleValidateChart(cmd.ChartID)
+	case common.ClientCmdSetRanking:
+		return s.handleSetRanking(cmd.Name)
 	case common.ClientCmdRequestStart:
 		return s.handleRequestStart()
 	case common.ClientCmdReady:
@@ -183,8 +274,52 @@ func (s *Session) handleCommand(cmd common.ClientCommand) error {
 		return s.handlePlayed(cmd.RecordID)
 	case common.ClientCmdAbort:
 		return s.handleAbort()
+	case common.ClientCmdQueueJoin:
+		return s.handleQueueJoin(cmd.RoomId)
+	case common.ClientCmdOverflowRoom:
+		return s.handleOverflowRoom(cmd.Overflow)
+	case common.ClientCmdLoadProgress:
+		return s.handleLoadProgress(cmd.Progress)
+	case common.ClientCmdSwitchRole:
+		return s.handleSwitchRole(cmd.Monitor)
+	case common.ClientCmdGlobalChat:
+		return s.handleGlobalChat(cmd.Message)
+	case common.ClientCmdGlobalSubscribe:
+		return s.handleGlobalSubscribe(cmd.Subscribe)
+	case common.ClientCmdSetMaxMonitors:
+		return s.handleSetMaxMonitors(int(cmd.MaxMonitors))
+	case common.ClientCmdSubmitResult:
+		return s.handleSubmitResult(cmd.Payload)
+	case common.ClientCmdReauthenticate:
+		return s.handleReauthenticate(cmd.Token)
+	case common.ClientCmdUpdateProfile:
+		return s.handleUpdateProfile(cmd.Name, cmd.Avatar)
+	case common.ClientCmdMonitorChat:
+		return s.handleMonitorChat(cmd.Message)
+	case common.ClientCmdScoreUpdate:
+		return s.handleScoreUpdate(cmd.LiveScore)
+	case common.ClientCmdAck:
+		return s.handleAck(cmd.Seq)
+	case common.ClientCmdEmote:
+		return s.handleEmote(cmd.Name)
+	case common.ClientCmdSetSchedule:
+		return s.handleSetSchedule(cmd.Schedule)
+	case common.ClientCmdListRooms:
+		return s.handleListRooms(cmd.Page)
+	case common.ClientCmdShareStats:
+		return s.handleShareStats(cmd.Share)
+	case common.ClientCmdTransferHost:
+		return s.handleTransferHost(cmd.UserID)
+	case common.ClientCmdChatHistory:
+		return s.handleChatHistory(cmd.Count)
+	case common.ClientCmdKick:
+		return s.handleKick(cmd.UserID)
+	case common.ClientCmdSetPassword:
+		return s.handleSetPassword(cmd.Password, cmd.Private)
+	case common.ClientCmdSetTeam:
+		return s.handleSetTeam(cmd.UserID, cmd.Team)
 	default:
-		log.Printf("会话 %s 未知命令类型: %d (最大有效值: %d), 断开连接", s.ID, cmd.Type, common.ClientCmdAbort)
+		log.Printf("会话 %s 未知命令类型: %d (最大有效值: %d), 断开连接", s.ID, cmd.Type, common.ClientCmdSetTeam)
 		// 发送错误响应
 		s.Send(common.ServerCommand{
 			Type: common.ServerCmdMessage,
@@ -245,32 +380,33 @@ func (s *Session) handleAuthenticate(token string) error {
 		}
 	}
 
-	s.authenticated = true
+	s.authenticated.Store(true)
+	s.markAuthenticated(token)
+	s.User.MarkActive()
+	s.User.SetIP(s.RemoteIP())
 
 	// 获取房间状态
-	var clientRoomState *common.ClientRoomState
+	reply := common.ServerCommand{
+		Type: common.ServerCmdAuthenticate,
+		AuthenticateResult: &common.Result[common.AuthResult]{
+			Ok: &common.AuthResult{User: s.User.ToInfo()},
+		},
+	}
 	if room := s.User.GetRoom(); room != nil {
 		state := room.GetClientRoomState(s.User)
-		clientRoomState = &state
+		reply.AuthenticateResult.Ok.Room = &state
+		room.setHostExtension(&reply)
 	}
 
 	// 发送认证成功响应
-	if err := s.Send(common.ServerCommand{
-		Type: common.ServerCmdAuthenticate,
-		AuthenticateResult: &common.Result[common.AuthResult]{
-			Ok: &common.AuthResult{
-				User: s.User.ToInfo(),
-				Room: clientRoomState,
-			},
-		},
-	}); err != nil {
+	if err := s.Send(reply); err != nil {
 		return err
 	}
 
 	// 断开旧会话（在发送响应后）
 	if staleSession != nil {
 		log.Printf("断开用户 `%s(%d)` 的旧会话 %s", s.User.Name, s.User.ID, staleSession.ID)
-		go staleSession.Stop()
+		go staleSession.Disconnect(common.DisconnectReasonReplaced, "账号已在其他连接上登录")
 	}
 
 	monitorSuffix := ""
@@ -326,17 +462,8 @@ func (s *Session) handleTouches(frames []common.TouchFrame) error {
 		s.User.gameTime.Store(uint32(frames[len(frames)-1].Time))
 	}
 
-	// 广播给观察者
-	room.BroadcastMonitors(common.ServerCommand{
-		Type:          common.ServerCmdTouches,
-		TouchesPlayer: s.User.ID,
-		TouchesFrames: frames,
-	})
-
-	// 录制回放
-	if recorder := s.server.GetReplayRecorder(); recorder != nil {
-		recorder.RecordTouch(room.ID.Value, s.User.ID, frames)
-	}
+	// 转发给观察者与回放录制器
+	room.forwardTouches(s.User.ID, frames)
 
 	return nil
 }
@@ -344,27 +471,37 @@ func (s *Session) handleTouches(frames []common.TouchFrame) error {
 // handleJudges 处理判定数据
 func (s *Session) handleJudges(judges []common.JudgeEvent) error {
 	room := s.User.GetRoom()
-	if room == nil || !room.IsLive() {
+	if room == nil {
 		return nil
 	}
 
-	// 广播给观察者
-	room.BroadcastMonitors(common.ServerCommand{
-		Type:         common.ServerCmdJudges,
-		JudgesPlayer: s.User.ID,
-		JudgesEvents: judges,
-	})
-
-	// 录制回放
-	if recorder := s.server.GetReplayRecorder(); recorder != nil {
-		recorder.RecordJudge(room.ID.Value, s.User.ID, judges)
+	// 统计判定，用于成绩播报
+	room.RecordJudges(s.User.ID, judges)
+	if !room.IsLive() {
+		return nil
 	}
 
+	// 转发给观察者与回放录制器
+	room.forwardJudges(s.User.ID, judges)
+
+	return nil
+}
+
+// handleFrameBatch 处理合并发送的触摸帧与判定，拆分后与单独的 Touches、Judges 处理相同
+func (s *Session) handleFrameBatch(frames []common.TouchFrame, judges []common.JudgeEvent) error {
+	if len(frames) > 0 {
+		if err := s.handleTouches(frames); err != nil {
+			return err
+		}
+	}
+	if len(judges) > 0 {
+		return s.handleJudges(judges)
+	}
 	return nil
 }
 
-// handleCreateRoom 处理创建房间
-func (s *Session) handleCreateRoom(roomId common.RoomId) error {
+// handleCreateRoom 处理创建房间，password 非空时为房间设置密码
+func (s *Session) handleCreateRoom(roomId common.RoomId, password string) error {
 	// 检查用户是否被封禁
 	if s.server.IsUserBanned(s.User.ID) {
 		return s.Send(common.ServerCommand{
@@ -373,6 +510,13 @@ func (s *Session) handleCreateRoom(roomId common.RoomId) error {
 		})
 	}
 
+	if s.User.IsGuest() {
+		return s.Send(common.ServerCommand{
+			Type:             common.ServerCmdCreateRoom,
+			CreateRoomResult: &common.Result[struct{}]{Err: strPtr(ErrGuestCreateRoom)},
+		})
+	}
+
 	if s.User.GetRoom() != nil {
 		return s.Send(common.ServerCommand{
 			Type:             common.ServerCmdCreateRoom,
@@ -387,6 +531,12 @@ func (s *Session) handleCreateRoom(roomId common.RoomId) error {
 			CreateRoomResult: &common.Result[struct{}]{Err: strPtr("房间创建已被禁用")},
 		})
 	}
+	if s.server.shedding(LoadShedRooms) {
+		return s.Send(common.ServerCommand{
+			Type:             common.ServerCmdCreateRoom,
+			CreateRoomResult: &common.Result[struct{}]{Err: strPtr(ErrLoadShedCreateRoom)},
+		})
+	}
 
 	if s.server.GetRoom(roomId) != nil {
 		return s.Send(common.ServerCommand{
@@ -395,8 +545,13 @@ func (s *Session) handleCreateRoom(roomId common.RoomId) error {
 		})
 	}
 
+	s.User.refreshProfileCard()
 	room := NewRoom(roomId, s.User, s.server)
+	room.SetPassword(password)
 	s.server.AddRoom(room)
+	if password != "" {
+		log.Printf("房间 %s 已设置密码", room.ID.Value)
+	}
 	s.User.SetRoom(room)
 
 	room.SendMessage(common.Message{
@@ -404,151 +559,180 @@ func (s *Session) handleCreateRoom(roomId common.RoomId) error {
 		User: s.User.ID,
 	})
 
-	// 如果启用了回放录制，插入虚拟monitor并设置live模式
-	if s.server.GetReplayRecorder() != nil && s.server.GetHTTPServer() != nil && s.server.GetHTTPServer().IsReplayEnabled() {
-		s.setupVirtualMonitorForReplay(room)
+	// 如果启用了回放录制，由录制器订阅房间数据，房间因此进入直播
+	recording := s.server.GetReplayRecorder() != nil && s.server.GetHTTPServer() != nil && s.server.GetHTTPServer().IsReplayEnabled()
+	if recording {
+		room.AddTap(s.server.GetReplayRecorder())
+		log.Printf("房间 %s 已启用回放录制", room.ID.Value)
 	}
 
-	return s.Send(common.ServerCommand{
+	if err := s.Send(common.ServerCommand{
 		Type:             common.ServerCmdCreateRoom,
 		CreateRoomResult: &common.Result[struct{}]{Ok: &struct{}{}},
-	})
-}
-
-// setupVirtualMonitorForReplay 为回放录制设置虚拟monitor
-func (s *Session) setupVirtualMonitorForReplay(room *Room) {
-	// 设置房间为live模式
-	room.SetLive(true)
-
-	// 创建虚拟monitor用户信息
-	virtualUser := &common.UserInfo{
-		ID:      2_000_000_000, // 虚拟monitor的固定ID
-		Name:    "回放录制器",
-		Monitor: true,
+	}); err != nil {
+		return err
 	}
 
-	log.Printf("房间 %s 已启用回放录制模式（虚拟monitor加入）", room.ID.Value)
-
-	// 使用goroutine异步发送虚拟monitor消息，避免阻塞房间创建响应
-	// 这模拟了TypeScript中的setImmediate行为
-	go func() {
-		// 短暂延迟，确保房间创建响应已发送
-		time.Sleep(50 * time.Millisecond)
+	// 创建结果发出后再通知直播状态，客户端据此开始上传触摸数据
+	if recording {
+		room.refreshLive()
+	}
+	return nil
+}
 
-		// 检查房间是否还存在且用户还在房间中
-		currentRoom := s.server.GetRoom(room.ID)
-		if currentRoom == nil {
-			return
-		}
-		if s.User == nil || s.User.GetRoom() == nil || s.User.GetRoom().ID != room.ID {
-			return
-		}
+// joinError 用户能否加入房间（加入、排队与排队放行共用），不能加入时返回原因
+// 加入之后才可能变化的条件（封禁、锁定、开放时间、比赛白名单）须在排队放行时重新检查
+func (r *Room) joinError(user *User, monitor bool) string {
+	switch {
+	case r.server.IsUserBanned(user.ID):
+		return "用户已被封禁"
+	case r.server.IsUserBannedFromRoom(user.ID, r.ID.Value):
+		return "已被禁止进入该房间"
+	case r.IsLocked():
+		return "房间已锁定"
+	case !r.IsOpen(time.Now()):
+		return "房间尚未开放"
+	case monitor:
+		return ""
+	case user.IsGuest():
+		return ErrGuestMonitorOnly
+	case r.IsReplay():
+		return ErrReplayRoomMonitorOnly
+	case !r.CanCompete(user.ID):
+		return ErrContestMonitorOnly
+	}
+	return ""
+}
 
-		// 发送虚拟monitor加入消息给房主
-		s.User.Send(common.ServerCommand{
-			Type:           common.ServerCmdOnJoinRoom,
-			OnJoinRoomUser: virtualUser,
-		})
-		s.User.Send(common.ServerCommand{
-			Type: common.ServerCmdMessage,
-			Message: &common.Message{
-				Type: common.MsgJoinRoom,
-				User: virtualUser.ID,
-				Name: virtualUser.Name,
-			},
+// handleJoinRoom 处理加入房间，房间有密码时须提供正确的密码
+func (s *Session) handleJoinRoom(roomId common.RoomId, monitor bool, password string) error {
+	if s.User.GetRoom() != nil {
+		return s.Send(common.ServerCommand{
+			Type:           common.ServerCmdJoinRoom,
+			JoinRoomResult: &common.Result[common.JoinRoomResponse]{Err: strPtr("已在房间中")},
 		})
+	}
 
-		// 再延迟2秒后播报虚拟monitor退出
-		time.Sleep(2 * time.Second)
-
-		// 再次检查房间和用户状态
-		if s.server.GetRoom(room.ID) == nil {
-			return
-		}
-		if s.User == nil || s.User.GetRoom() == nil || s.User.GetRoom().ID != room.ID {
-			return
-		}
-
-		// 广播虚拟monitor离开
-		room.Broadcast(common.ServerCommand{
-			Type: common.ServerCmdMessage,
-			Message: &common.Message{
-				Type: common.MsgLeaveRoom,
-				User: virtualUser.ID,
-				Name: virtualUser.Name,
-			},
+	room := s.server.GetRoom(roomId)
+	if room == nil {
+		return s.Send(common.ServerCommand{
+			Type:           common.ServerCmdJoinRoom,
+			JoinRoomResult: &common.Result[common.JoinRoomResponse]{Err: strPtr("房间不存在")},
 		})
+	}
 
-		log.Printf("房间 %s 虚拟monitor已退出，房间保持live模式", room.ID.Value)
-	}()
-}
-
-// handleJoinRoom 处理加入房间
-func (s *Session) handleJoinRoom(roomId common.RoomId, monitor bool) error {
-	// 检查用户是否被封禁
-	if s.server.IsUserBanned(s.User.ID) {
+	if reason := room.joinError(s.User, monitor); reason != "" {
 		return s.Send(common.ServerCommand{
 			Type:           common.ServerCmdJoinRoom,
-			JoinRoomResult: &common.Result[common.JoinRoomResponse]{Err: strPtr("用户已被封禁")},
+			JoinRoomResult: &common.Result[common.JoinRoomResponse]{Err: strPtr(reason)},
 		})
 	}
 
-	if s.User.GetRoom() != nil {
+	if room.HasPassword() {
+		now := time.Now()
+		if wait := room.passwordRetryAfter(s.User.ID, now); wait > 0 {
+			return s.Send(common.ServerCommand{
+				Type:           common.ServerCmdJoinRoom,
+				JoinRoomResult: &common.Result[common.JoinRoomResponse]{Err: strPtr(fmt.Sprintf(ErrPasswordBackoff, int((wait+time.Second-1)/time.Second)))},
+			})
+		}
+		ok := room.CheckPassword(password)
+		room.recordPasswordResult(s.User.ID, ok, now)
+		if !ok {
+			log.Printf("玩家 `%s(%d)` 加入房间 `%s` 密码错误", s.User.Name, s.User.ID, room.ID.Value)
+			return s.Send(common.ServerCommand{
+				Type:           common.ServerCmdJoinRoom,
+				JoinRoomResult: &common.Result[common.JoinRoomResponse]{Err: strPtr(ErrWrongPassword)},
+			})
+		}
+	}
+
+	if room.GetState() != InternalStateSelectChart {
 		return s.Send(common.ServerCommand{
 			Type:           common.ServerCmdJoinRoom,
-			JoinRoomResult: &common.Result[common.JoinRoomResponse]{Err: strPtr("已在房间中")},
+			JoinRoomResult: &common.Result[common.JoinRoomResponse]{Err: strPtr("游戏进行中")},
 		})
 	}
 
-	room := s.server.GetRoom(roomId)
-	if room == nil {
+	if monitor && !room.CanMonitor(s.User) {
 		return s.Send(common.ServerCommand{
 			Type:           common.ServerCmdJoinRoom,
-			JoinRoomResult: &common.Result[common.JoinRoomResponse]{Err: strPtr("房间不存在")},
+			JoinRoomResult: &common.Result[common.JoinRoomResponse]{Err: strPtr("无法观察")},
 		})
 	}
 
-	// 检查用户是否被禁止进入该房间
-	if s.server.IsUserBannedFromRoom(s.User.ID, roomId.Value) {
+	// 房主开启满员转观察者时，满员后新玩家以观察者身份加入
+	overflow := !monitor && room.IsOverflow() && room.IsFull()
+	if overflow {
+		monitor = true
+	}
+
+	if other := room.FindSameIP(s.User); other != nil {
+		log.Printf("玩家 `%s(%d)` 与房间 `%s` 内的 `%s(%d)` IP相同，拒绝加入", s.User.Name, s.User.ID, room.ID.Value, other.Name, other.ID)
 		return s.Send(common.ServerCommand{
 			Type:           common.ServerCmdJoinRoom,
-			JoinRoomResult: &common.Result[common.JoinRoomResponse]{Err: strPtr("已被禁止进入该房间")},
+			JoinRoomResult: &common.Result[common.JoinRoomResponse]{Err: strPtr(ErrSameIP)},
 		})
 	}
 
-	if room.IsLocked() {
+	if monitor && room.IsMonitorsFull() {
 		return s.Send(common.ServerCommand{
 			Type:           common.ServerCmdJoinRoom,
-			JoinRoomResult: &common.Result[common.JoinRoomResponse]{Err: strPtr("房间已锁定")},
+			JoinRoomResult: &common.Result[common.JoinRoomResponse]{Err: strPtr(ErrMonitorLimit)},
 		})
 	}
-
-	if room.GetState() != InternalStateSelectChart {
+	if monitor && s.server.shedding(LoadShedMonitors) {
 		return s.Send(common.ServerCommand{
 			Type:           common.ServerCmdJoinRoom,
-			JoinRoomResult: &common.Result[common.JoinRoomResponse]{Err: strPtr("游戏进行中")},
+			JoinRoomResult: &common.Result[common.JoinRoomResponse]{Err: strPtr(ErrLoadShedMonitor)},
 		})
 	}
 
-	if monitor && !s.User.CanMonitor() {
+	if err := hookJoin(room, s.User, monitor); err != nil {
 		return s.Send(common.ServerCommand{
 			Type:           common.ServerCmdJoinRoom,
-			JoinRoomResult: &common.Result[common.JoinRoomResponse]{Err: strPtr("无法观察")},
+			JoinRoomResult: &common.Result[common.JoinRoomResponse]{Err: strPtr(err.Error()), Code: common.ErrCodeRejected},
 		})
 	}
 
+	if err := s.enterRoom(room, monitor); err != nil || !overflow {
+		return err
+	}
+
+	log.Printf("房间 `%s` 已满员，玩家 `%s(%d)` 转为观察者加入", room.ID.Value, s.User.Name, s.User.ID)
+	return s.Send(common.ServerCommand{
+		Type: common.ServerCmdMessage,
+		Message: &common.Message{
+			Type:    common.MsgChat,
+			User:    0,
+			Content: "房间已满员，你已作为观察者加入",
+		},
+	})
+}
+
+// enterRoom 将用户加入房间并发送加入结果（调用方需已完成权限检查）
+func (s *Session) enterRoom(room *Room, monitor bool) error {
 	if !room.AddUser(s.User, monitor) {
+		reason := "房间已满"
+		if monitor {
+			reason = ErrMonitorLimit
+		}
 		return s.Send(common.ServerCommand{
 			Type:           common.ServerCmdJoinRoom,
-			JoinRoomResult: &common.Result[common.JoinRoomResponse]{Err: strPtr("房间已满")},
+			JoinRoomResult: &common.Result[common.JoinRoomResponse]{Err: strPtr(reason)},
 		})
 	}
+	return s.joinedRoom(room, monitor)
+}
 
+// joinedRoom 完成加入房间的后续步骤（调用方需已通过 AddUser 占到位置）
+func (s *Session) joinedRoom(room *Room, monitor bool) error {
 	s.User.SetMonitor(monitor)
+	s.User.refreshProfileCard()
 	s.User.SetRoom(room)
 
-	if monitor && !room.IsLive() {
-		room.SetLive(true)
+	if monitor {
+		room.refreshLive()
 	}
 
 	monitorSuffix := ""
@@ -561,15 +745,16 @@ func (s *Session) handleJoinRoom(roomId common.RoomId, monitor bool) error {
 		Type: common.ServerCmdOnJoinRoom,
 		OnJoinRoomUser: &common.UserInfo{
 			ID:      s.User.ID,
-			Name:    s.User.Name,
+			Name:    s.User.DisplayName(),
 			Monitor: monitor,
+			Card:    s.User.ProfileCard(),
 		},
 	})
 
 	room.SendMessage(common.Message{
 		Type: common.MsgJoinRoom,
 		User: s.User.ID,
-		Name: s.User.Name,
+		Name: s.User.DisplayName(),
 	})
 
 	// 获取所有用户信息
@@ -580,21 +765,32 @@ func (s *Session) handleJoinRoom(roomId common.RoomId, monitor bool) error {
 	}
 
 	chart := room.GetChart()
-	var chartID *int32
+	var chartID *int64
 	if chart != nil {
 		chartID = &chart.ID
 	}
 
-	return s.Send(common.ServerCommand{
+	reply := common.ServerCommand{
 		Type: common.ServerCmdJoinRoom,
 		JoinRoomResult: &common.Result[common.JoinRoomResponse]{
 			Ok: &common.JoinRoomResponse{
-				State: room.GetState().ToClientState(chartID),
-				Users: userInfos,
-				Live:  room.IsLive(),
+				State:      room.GetState().ToClientState(chartID),
+				Users:      userInfos,
+				Live:       room.IsLive(),
+				ReadyUsers: room.GetReadyUsers(),
+				Teams:      room.GetTeams(),
 			},
 		},
-	})
+	}
+	room.setHostExtension(&reply)
+	err := s.Send(reply)
+
+	// 官方房间无真实房主时，由首个加入的玩家接任
+	if !monitor {
+		room.claimHost(s.User)
+	}
+
+	return err
 }
 
 // handleRequestStart 处理请求开始
@@ -647,6 +843,13 @@ func (s *Session) handleRequestStart() error {
 }
 func (s *Session) handleLeaveRoom() error {
 	room := s.User.GetRoom()
+	if room == nil && s.User.LeaveQueue() {
+		// 排队中离开视为退出队列
+		return s.Send(common.ServerCommand{
+			Type:            common.ServerCmdLeaveRoom,
+			LeaveRoomResult: &common.Result[struct{}]{Ok: &struct{}{}},
+		})
+	}
 	if room == nil {
 		return s.Send(common.ServerCommand{
 			Type:            common.ServerCmdLeaveRoom,
@@ -739,8 +942,45 @@ func (s *Session) handleCycleRoom(cycle bool) error {
 	})
 }
 
+// handleOverflowRoom 处理满员转观察者设置
+func (s *Session) handleOverflowRoom(overflow bool) error {
+	room := s.User.GetRoom()
+	if room == nil {
+		return s.Send(common.ServerCommand{
+			Type:               common.ServerCmdOverflowRoom,
+			OverflowRoomResult: &common.Result[struct{}]{Err: strPtr("不在房间中")},
+		})
+	}
+
+	if err := room.CheckHost(s.User); err != nil {
+		return s.Send(common.ServerCommand{
+			Type:               common.ServerCmdOverflowRoom,
+			OverflowRoomResult: &common.Result[struct{}]{Err: strPtr("只有房主可以设置满员转观察者")},
+		})
+	}
+
+	room.SetOverflow(overflow)
+	content := "房主已关闭满员转观察者"
+	if overflow {
+		content = "房主已开启满员转观察者，满员后加入的玩家将作为观察者"
+	}
+	room.SendMessage(common.Message{
+		Type:    common.MsgChat,
+		User:    0,
+		Content: content,
+	})
+
+	// 广播房间状态更新
+	BroadcastRoomUpdate(room)
+
+	return s.Send(common.ServerCommand{
+		Type:               common.ServerCmdOverflowRoom,
+		OverflowRoomResult: &common.Result[struct{}]{Ok: &struct{}{}},
+	})
+}
+
 // handleSelectChart 处理选择谱面
-func (s *Session) handleSelectChart(chartID int32) error {
+func (s *Session) handleSelectChart(chartID int64) error {
 	room := s.User.GetRoom()
 	if room == nil {
 		return s.Send(common.ServerCommand{
@@ -763,21 +1003,76 @@ func (s *Session) handleSelectChart(chartID int32) error {
 		})
 	}
 
+	if room.IsChartFixed() {
+		return s.Send(common.ServerCommand{
+			Type:              common.ServerCmdSelectChart,
+			SelectChartResult: &common.Result[struct{}]{Err: strPtr("该房间谱面已固定")},
+		})
+	}
+
+	// 谱面查询在工作池中进行，完成后再回复 SelectChartResult
+	if !s.fetchingChart.CompareAndSwap(false, true) {
+		return s.Send(common.ServerCommand{
+			Type:              common.ServerCmdSelectChart,
+			SelectChartResult: &common.Result[struct{}]{Err: strPtr("谱面查询中")},
+		})
+	}
+	if !s.server.fetchPool.Submit(func() {
+		defer s.fetchingChart.Store(false)
+		s.reportAsync(s.selectChart(room, chartID))
+	}) {
+		s.fetchingChart.Store(false)
+		return s.Send(common.ServerCommand{
+			Type:              common.ServerCmdSelectChart,
+			SelectChartResult: &common.Result[struct{}]{Err: strPtr("服务器繁忙，请稍后重试")},
+		})
+	}
+	return nil
+}
+
+// selectChart 查询谱面并设置为房间谱面（在工作池中执行）
+func (s *Session) selectChart(room *Room, chartID int64) error {
 	chart, err := FetchChart(chartID)
 	if err != nil {
+		log.Printf("玩家 `%s(%d)` 在房间 `%s` 选择的谱面 `ID(%d)` 查询失败: %v", s.User.Name, s.User.ID, room.ID, chartID, err)
 		return s.Send(common.ServerCommand{
 			Type:              common.ServerCmdSelectChart,
 			SelectChartResult: &common.Result[struct{}]{Err: strPtr("谱面不存在")},
 		})
 	}
 
+	// 查询期间房间状态可能已变化；谱面查询完成后才能检查定数限制
+	if reason := s.chartSelectable(room, chart); reason != "" {
+		return s.Send(common.ServerCommand{
+			Type:              common.ServerCmdSelectChart,
+			SelectChartResult: &common.Result[struct{}]{Err: strPtr(reason)},
+		})
+	}
+	if err := hookSelectChart(room, s.User, chart); err != nil {
+		return s.Send(common.ServerCommand{
+			Type:              common.ServerCmdSelectChart,
+			SelectChartResult: &common.Result[struct{}]{Err: strPtr(err.Error()), Code: common.ErrCodeRejected},
+		})
+	}
+
+	log.Printf("玩家 `%s(%d)` 在房间 `%s` 选择了谱面 `%s(%d)`", s.User.Name, s.User.ID, room.ID, chart.Name, chart.ID)
 	room.SetChart(chart)
-	room.SendMessage(common.Message{
-		Type:    common.MsgSelectChart,
-		User:    s.User.ID,
-		Name:    chart.Name,
-		ChartID: chart.ID,
-	})
+
+	// 谱面预览与难度作为扩展字段随 MsgSelectChart 下发（V6 以下的客户端只收到原有字段）
+	notify := common.ServerCommand{
+		Type: common.ServerCmdMessage,
+		Message: &common.Message{
+			Type:    common.MsgSelectChart,
+			User:    s.User.ID,
+			Name:    chart.Name,
+			ChartID: chart.ID,
+		},
+	}
+	preview := chart.Preview()
+	notify.Extensions.SetValue(common.ExtChartPreview, &preview)
+	level := chart.ChartLevel()
+	notify.Extensions.SetValue(common.ExtChartLevel, &level)
+	room.Broadcast(notify)
 	room.OnStateChange()
 
 	return s.Send(common.ServerCommand{
@@ -854,17 +1149,7 @@ func (s *Session) handleCancelReady() error {
 
 	// 房主取消则取消游戏
 	if room.GetHost().ID == s.User.ID {
-		// 清空游戏状态
-		room.started = sync.Map{}
-		room.results = sync.Map{}
-		room.aborted = sync.Map{}
-
-		room.SendMessage(common.Message{
-			Type: common.MsgCancelGame,
-			User: s.User.ID,
-		})
-		room.SetState(InternalStateSelectChart)
-		room.OnStateChange()
+		room.cancelGame(s.User)
 	} else {
 		room.SendMessage(common.Message{
 			Type: common.MsgCancelReady,
@@ -882,7 +1167,7 @@ func (s *Session) handleCancelReady() error {
 }
 
 // handlePlayed 处理游戏完成
-func (s *Session) handlePlayed(recordID int32) error {
+func (s *Session) handlePlayed(recordID int64) error {
 	room := s.User.GetRoom()
 	if room == nil {
 		return s.Send(common.ServerCommand{
@@ -898,14 +1183,43 @@ func (s *Session) handlePlayed(recordID int32) error {
 		})
 	}
 
-	record, err := FetchRecord(recordID)
-	if err != nil {
+	// 成绩查询在工作池中进行，期间玩家处于"成绩确认中"，完成后再回复 PlayedResult
+	if _, pending := room.pendingResults.LoadOrStore(s.User.ID, recordID); pending {
+		return s.Send(common.ServerCommand{
+			Type:         common.ServerCmdPlayed,
+			PlayedResult: &common.Result[struct{}]{Err: strPtr("成绩确认中")},
+		})
+	}
+	if !s.server.fetchPool.Submit(func() { s.reportAsync(s.fetchPlayed(room, recordID)) }) {
+		room.pendingResults.Delete(s.User.ID)
 		return s.Send(common.ServerCommand{
 			Type:         common.ServerCmdPlayed,
-			PlayedResult: &common.Result[struct{}]{Err: strPtr("记录不存在")},
+			PlayedResult: &common.Result[struct{}]{Err: strPtr("服务器繁忙，请稍后重试")},
 		})
 	}
+	return nil
+}
 
+// fetchPlayed 查询成绩并完成 Played（在工作池中执行）
+func (s *Session) fetchPlayed(room *Room, recordID int64) error {
+	record, err := s.server.GetRecordProvider().FetchRecord(recordID)
+	if err != nil && s.server.config.PlayedRetries > 0 {
+		// 刚上传的成绩可能暂时查询不到，转入后台重试
+		s.startPlayedRetry(room, recordID, err)
+		return nil
+	}
+	return s.finishPlayed(room, recordID, record, err)
+}
+
+// reportAsync 记录工作池中命令处理的错误（与接收循环中的处理错误相同）
+func (s *Session) reportAsync(err error) {
+	if err != nil {
+		RateLimitedLog("会话 %s 处理命令错误: %v", s.ID, err)
+	}
+}
+
+// completePlayed 校验查询到的成绩并完成 Played
+func (s *Session) completePlayed(room *Room, record *Record) error {
 	if record.Player != s.User.ID {
 		return s.Send(common.ServerCommand{
 			Type:         common.ServerCmdPlayed,
@@ -929,21 +1243,15 @@ func (s *Session) handlePlayed(recordID int32) error {
 		})
 	}
 
-	room.results.Store(s.User.ID, record)
-	room.SendMessage(common.Message{
-		Type:      common.MsgPlayed,
-		User:      s.User.ID,
-		Score:     record.Score,
-		Accuracy:  record.Accuracy,
-		FullCombo: record.FullCombo,
-	})
-
-	// 更新回放文件的成绩ID
-	if recorder := s.server.GetReplayRecorder(); recorder != nil {
-		recorder.UpdateRecordID(room.ID.Value, s.User.ID, recordID)
+	// 同一成绩不能在多个房间或多局中重复使用
+	if !s.server.consumeRecord(room, s.User, record) {
+		return s.Send(common.ServerCommand{
+			Type:         common.ServerCmdPlayed,
+			PlayedResult: &common.Result[struct{}]{Err: strPtr("成绩已被使用")},
+		})
 	}
 
-	room.CheckAllReady()
+	s.recordPlayed(room, record)
 
 	return s.Send(common.ServerCommand{
 		Type:         common.ServerCmdPlayed,
@@ -951,6 +1259,19 @@ func (s *Session) handlePlayed(recordID int32) error {
 	})
 }
 
+// recordPlayed 记录玩家成绩并广播（Played 与 SubmitResult 共用）
+func (s *Session) recordPlayed(room *Room, record *Record) {
+	room.results.Store(s.User.ID, record)
+	room.SendMessage(room.playedMessage(s.User.ID, record))
+
+	// 更新回放文件的成绩ID
+	if recorder := s.server.GetReplayRecorder(); recorder != nil {
+		recorder.UpdateRecordID(room.ID.Value, s.User.ID, record.ID)
+	}
+
+	room.CheckAllReady()
+}
+
 // handleAbort 处理放弃
 func (s *Session) handleAbort() error {
 	room := s.User.GetRoom()
@@ -975,6 +1296,12 @@ func (s *Session) handleAbort() error {
 			AbortResult: &common.Result[struct{}]{Err: strPtr("已上传")},
 		})
 	}
+	if _, pending := room.pendingResults.Load(s.User.ID); pending {
+		return s.Send(common.ServerCommand{
+			Type:        common.ServerCmdAbort,
+			AbortResult: &common.Result[struct{}]{Err: strPtr("成绩确认中")},
+		})
+	}
 
 	// 检查是否已放弃
 	if _, aborted := room.aborted.Load(s.User.ID); aborted {
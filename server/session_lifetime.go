@@ -0,0 +1,133 @@
+package server
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"phira-mp/common"
+)
+
+// 会话有效期参数
+const (
+	DefaultSessionReauthGrace = 60              // 要求重新认证后的默认宽限秒数
+	sessionRevalidateRetry    = 1 * time.Minute // 认证服务不可用时的重试间隔
+)
+
+// markAuthenticated 记录认证通过的token与时间，并清除重新认证要求
+func (s *Session) markAuthenticated(token string) {
+	s.lifeMu.Lock()
+	defer s.lifeMu.Unlock()
+	s.token = token
+	s.authAt = time.Now()
+	s.reauthBy = time.Time{}
+}
+
+// checkLifetime 检查会话有效期（由心跳检测每秒调用）
+// 到期后在后台向认证服务校验token；token被拒绝且宽限期内未重新认证时返回true（断开连接）
+func (s *Session) checkLifetime(now time.Time) bool {
+	maxAge := time.Duration(s.server.config.SessionMaxAge) * time.Second
+	if maxAge <= 0 {
+		return false
+	}
+
+	s.lifeMu.Lock()
+	authAt, reauthBy := s.authAt, s.reauthBy
+	s.lifeMu.Unlock()
+
+	// 未认证或游客会话
+	if authAt.IsZero() {
+		return false
+	}
+
+	if !reauthBy.IsZero() {
+		if now.Before(reauthBy) {
+			return false
+		}
+		// 对局中不打断，等对局结束后再断开
+		if room := s.User.GetRoom(); room != nil && room.GetState() == InternalStatePlaying {
+			return false
+		}
+		log.Printf("用户 `%s(%d)` 未在宽限期内重新认证，断开连接", s.User.Name, s.User.ID)
+		return true
+	}
+
+	if now.Sub(authAt) >= maxAge && s.revalidating.CompareAndSwap(false, true) {
+		go s.revalidate(maxAge)
+	}
+	return false
+}
+
+// revalidate 向认证服务重新校验token
+func (s *Session) revalidate(maxAge time.Duration) {
+	defer s.revalidating.Store(false)
+
+	s.lifeMu.Lock()
+	token := s.token
+	s.lifeMu.Unlock()
+
+	globalAuthCache.delete(token)
+	user, _, err := UserInfoFromAPI(token)
+	now := time.Now()
+
+	if err == nil && user.ID == s.User.ID {
+		s.lifeMu.Lock()
+		s.authAt = now
+		s.lifeMu.Unlock()
+		return
+	}
+	if err != nil && !errors.Is(err, ErrTokenRejected) {
+		// 认证服务不可用时不惩罚用户，稍后再试
+		log.Printf("用户 `%s(%d)` token校验失败，%v 后重试: %v", s.User.Name, s.User.ID, sessionRevalidateRetry, err)
+		s.lifeMu.Lock()
+		s.authAt = now.Add(sessionRevalidateRetry - maxAge)
+		s.lifeMu.Unlock()
+		return
+	}
+
+	grace := s.server.config.SessionReauthGrace
+	if grace <= 0 {
+		grace = DefaultSessionReauthGrace
+	}
+	s.lifeMu.Lock()
+	s.reauthBy = now.Add(time.Duration(grace) * time.Second)
+	s.lifeMu.Unlock()
+
+	log.Printf("用户 `%s(%d)` token已失效，要求在 %d 秒内重新认证", s.User.Name, s.User.ID, grace)
+	s.Send(common.ServerCommand{
+		Type:        common.ServerCmdReauthRequired,
+		ReauthGrace: uint32(grace),
+	})
+}
+
+// handleReauthenticate 处理重新认证：更换token而不断开连接，用户必须与当前用户一致
+func (s *Session) handleReauthenticate(token string) error {
+	fail := func(msg string) error {
+		return s.Send(common.ServerCommand{
+			Type:                 common.ServerCmdReauthenticate,
+			ReauthenticateResult: &common.Result[struct{}]{Err: strPtr(msg)},
+		})
+	}
+
+	if s.User.IsGuest() {
+		return fail("游客无需认证")
+	}
+
+	user, _, err := UserInfoFromAPI(token)
+	if err != nil {
+		log.Printf("用户 `%s(%d)` 重新认证失败: %v", s.User.Name, s.User.ID, err)
+		return fail("认证失败")
+	}
+	if user.ID != s.User.ID {
+		log.Printf("[安全] 用户 `%s(%d)` 尝试以其他用户 (%d) 的token重新认证", s.User.Name, s.User.ID, user.ID)
+		return fail("用户不匹配")
+	}
+
+	s.markAuthenticated(token)
+	log.Printf("用户 `%s(%d)` 重新认证成功", s.User.Name, s.User.ID)
+
+	return s.Send(common.ServerCommand{
+		Type:                 common.ServerCmdReauthenticate,
+		ReauthenticateResult: &common.Result[struct{}]{Ok: &struct{}{}},
+	})
+}
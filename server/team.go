@@ -0,0 +1,183 @@
+package server
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"phira-mp/common"
+)
+
+// TeamEntry 队伍结算：按队员排名分数之和排序
+type TeamEntry struct {
+	Rank     int     `json:"rank"` // 从1开始，分数相同的队伍名次相同
+	Team     uint8   `json:"team"`
+	Players  []int32 `json:"players"`  // 有成绩的队员（按个人排名顺序）
+	Points   float64 `json:"points"`   // 队员排名分数之和
+	Score    int64   `json:"score"`    // 队员分数之和
+	Accuracy float32 `json:"accuracy"` // 队员的平均准度
+}
+
+// GetTeam 玩家所在的队伍（0表示未分队）
+func (r *Room) GetTeam(userID int32) uint8 {
+	if v, ok := r.teams.Load(userID); ok {
+		return v.(uint8)
+	}
+	return 0
+}
+
+// SetTeam 设置玩家所在的队伍，team 为0时退出队伍；返回队伍是否变化
+func (r *Room) SetTeam(userID int32, team uint8) bool {
+	if r.GetTeam(userID) == team {
+		return false
+	}
+	if team == 0 {
+		r.teams.Delete(userID)
+	} else {
+		r.teams.Store(userID, team)
+	}
+	return true
+}
+
+// GetTeams 房间内已分队玩家所在的队伍（没有玩家分队时为nil）
+func (r *Room) GetTeams() map[int32]uint8 {
+	var teams map[int32]uint8
+	for _, u := range r.players() {
+		if team := r.GetTeam(u.ID); team != 0 {
+			if teams == nil {
+				teams = make(map[int32]uint8)
+			}
+			teams[u.ID] = team
+		}
+	}
+	return teams
+}
+
+// players 房间内的全部玩家（包括断线等待重连的玩家，不包括观察者）
+func (r *Room) players() []*User {
+	r.users.RLock()
+	defer r.users.RUnlock()
+	return append([]*User(nil), r.userList...)
+}
+
+// leaveTeam 玩家离开房间或转为观察者时退出队伍；对局中离开的玩家保留到结算之后（见 pruneTeams）
+func (r *Room) leaveTeam(userID int32) {
+	if state := r.GetState(); state == InternalStatePlaying || state == InternalStateLoading {
+		return
+	}
+	r.teams.Delete(userID)
+}
+
+// pruneTeams 对局结束后移除已不在房间中的玩家的队伍
+func (r *Room) pruneTeams() {
+	present := make(map[int32]bool)
+	for _, u := range r.players() {
+		present[u.ID] = true
+	}
+	r.teams.Range(func(key, _ interface{}) bool {
+		if !present[key.(int32)] {
+			r.teams.Delete(key)
+		}
+		return true
+	})
+}
+
+// rankTeams 按个人排名汇总各队伍的成绩（没有已分队的玩家时为nil），同时填写个人排名中的队伍
+func (r *Room) rankTeams(ranking []RankEntry) []TeamEntry {
+	byTeam := make(map[uint8]*TeamEntry)
+	for i := range ranking {
+		team := r.GetTeam(ranking[i].UserID)
+		if team == 0 {
+			continue
+		}
+		ranking[i].Team = team
+		entry := byTeam[team]
+		if entry == nil {
+			entry = &TeamEntry{Team: team}
+			byTeam[team] = entry
+		}
+		entry.Players = append(entry.Players, ranking[i].UserID)
+		entry.Points += ranking[i].Points
+		entry.Score += int64(ranking[i].Score)
+		entry.Accuracy += ranking[i].Accuracy
+	}
+	if len(byTeam) == 0 {
+		return nil
+	}
+
+	teams := make([]TeamEntry, 0, len(byTeam))
+	for _, entry := range byTeam {
+		entry.Accuracy /= float32(len(entry.Players))
+		teams = append(teams, *entry)
+	}
+	sort.Slice(teams, func(i, j int) bool {
+		if teams[i].Points != teams[j].Points {
+			return teams[i].Points > teams[j].Points
+		}
+		return teams[i].Team < teams[j].Team
+	})
+	for i := range teams {
+		if i > 0 && teams[i].Points == teams[i-1].Points {
+			teams[i].Rank = teams[i-1].Rank
+		} else {
+			teams[i].Rank = i + 1
+		}
+	}
+	return teams
+}
+
+// handleSetTeam 处理房主为玩家分配队伍（游戏中不能调整）
+func (s *Session) handleSetTeam(userID int32, team uint8) error {
+	fail := func(msg string) error {
+		return s.Send(common.ServerCommand{
+			Type:          common.ServerCmdSetTeam,
+			SetTeamResult: &common.Result[struct{}]{Err: strPtr(msg)},
+		})
+	}
+
+	room := s.User.GetRoom()
+	if room == nil {
+		return fail("不在房间中")
+	}
+	if err := room.CheckHost(s.User); err != nil {
+		return fail("只有房主可以分配队伍")
+	}
+	if state := room.GetState(); state == InternalStatePlaying || state == InternalStateLoading {
+		return fail("游戏中不能调整队伍")
+	}
+	if team > common.RoomMaxTeams {
+		return fail("无效的队伍编号")
+	}
+
+	var target *User
+	for _, u := range room.players() {
+		if u.ID == userID {
+			target = u
+			break
+		}
+	}
+	if target == nil {
+		for _, u := range room.GetMonitors() {
+			if u.ID == userID {
+				return fail("观察者不能加入队伍")
+			}
+		}
+		return fail("目标玩家不在房间中")
+	}
+
+	if room.SetTeam(userID, team) {
+		room.SendMessage(common.Message{
+			Type: common.MsgTeamChange,
+			User: userID,
+			Team: team,
+		})
+		log.Printf("房间 `%s` 玩家 %s(%d) 的队伍调整为 %d", room.ID.Value, target.Name, target.ID, team)
+		BroadcastRoomLog(room.ID.Value, fmt.Sprintf("玩家 %s(%d) 的队伍调整为 %d", target.Name, target.ID, team))
+		BroadcastRoomUpdate(room)
+	}
+
+	return s.Send(common.ServerCommand{
+		Type:          common.ServerCmdSetTeam,
+		SetTeamResult: &common.Result[struct{}]{Ok: &struct{}{}},
+	})
+}
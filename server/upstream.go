@@ -0,0 +1,227 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PhiraAPIConfig Phira主站API配置
+type PhiraAPIConfig struct {
+	BaseURL string `yaml:"base_url"` // API地址（默认 https://phira.5wyxi.com）
+	Timeout int    `yaml:"timeout"`  // 单次请求超时秒数
+	Retries int    `yaml:"retries"`  // 网络错误或5xx时的重试次数（0表示不重试）
+}
+
+// Phira主站API默认参数
+const (
+	DefaultPhiraAPITimeout = 10 // 秒
+	DefaultPhiraAPIRetries = 3
+)
+
+// DefaultPhiraAPIConfig 默认Phira主站API配置
+func DefaultPhiraAPIConfig() PhiraAPIConfig {
+	return PhiraAPIConfig{
+		BaseURL: Host,
+		Timeout: DefaultPhiraAPITimeout,
+		Retries: DefaultPhiraAPIRetries,
+	}
+}
+
+// upstreamBackoff 首次重试前的等待时间（之后每次翻倍）
+const upstreamBackoff = 500 * time.Millisecond
+
+// upstreamLatencyBuckets 请求耗时直方图的桶上界（秒）
+var upstreamLatencyBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// upstreamEndpoint 单个上游接口的统计
+type upstreamEndpoint struct {
+	mu       sync.Mutex
+	requests uint64
+	errors   uint64   // 网络错误、超时与5xx
+	sum      float64  // 耗时总和（秒）
+	buckets  []uint64 // 与 upstreamLatencyBuckets 对应的累计计数
+}
+
+// UpstreamStats 上游接口统计快照
+type UpstreamStats struct {
+	Requests   uint64  `json:"requests"`
+	Errors     uint64  `json:"errors"`
+	AvgLatency float64 `json:"avg_latency"` // 平均耗时（秒）
+}
+
+// upstreamClient Phira主站API客户端
+type upstreamClient struct {
+	config atomic.Value // PhiraAPIConfig
+	client atomic.Value // *http.Client
+
+	mu        sync.Mutex
+	endpoints map[string]*upstreamEndpoint
+}
+
+var upstream = newUpstreamClient()
+
+func newUpstreamClient() *upstreamClient {
+	c := &upstreamClient{endpoints: make(map[string]*upstreamEndpoint)}
+	c.configure(DefaultPhiraAPIConfig())
+	return c
+}
+
+// ConfigurePhiraAPI 应用Phira主站API配置（未填写的字段使用默认值）
+func ConfigurePhiraAPI(config PhiraAPIConfig) {
+	upstream.configure(config)
+}
+
+func (c *upstreamClient) configure(config PhiraAPIConfig) {
+	if config.BaseURL == "" {
+		config.BaseURL = Host
+	}
+	config.BaseURL = strings.TrimRight(config.BaseURL, "/")
+	if config.Timeout <= 0 {
+		config.Timeout = DefaultPhiraAPITimeout
+	}
+	if config.Retries < 0 {
+		config.Retries = 0
+	}
+	c.config.Store(config)
+	c.client.Store(&http.Client{Timeout: time.Duration(config.Timeout) * time.Second})
+}
+
+// endpoint 获取（必要时创建）接口统计
+func (c *upstreamClient) endpoint(name string) *upstreamEndpoint {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.endpoints[name]
+	if !ok {
+		e = &upstreamEndpoint{buckets: make([]uint64, len(upstreamLatencyBuckets))}
+		c.endpoints[name] = e
+	}
+	return e
+}
+
+// observe 记录一次请求
+func (e *upstreamEndpoint) observe(latency time.Duration, failed bool) {
+	seconds := latency.Seconds()
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.requests++
+	if failed {
+		e.errors++
+	}
+	e.sum += seconds
+	for i, bound := range upstreamLatencyBuckets {
+		if seconds <= bound {
+			e.buckets[i]++
+		}
+	}
+}
+
+// get 请求上游接口，网络错误与5xx按配置重试，4xx直接返回
+// name 为统计使用的接口名；调用方负责关闭返回的 Body
+func (c *upstreamClient) get(name, path, token string) (*http.Response, error) {
+	config := c.config.Load().(PhiraAPIConfig)
+	client := c.client.Load().(*http.Client)
+	stats := c.endpoint(name)
+
+	backoff := upstreamBackoff
+	var lastErr error
+	for attempt := 0; attempt <= config.Retries; attempt++ {
+		if attempt > 0 {
+			log.Printf("请求 %s 第 %d 次重试（回退 %v）: %v", path, attempt, backoff, lastErr)
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		req, err := http.NewRequest(http.MethodGet, config.BaseURL+path, nil)
+		if err != nil {
+			return nil, err
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+
+		start := time.Now()
+		resp, err := client.Do(req)
+		if err != nil {
+			stats.observe(time.Since(start), true)
+			lastErr = err
+			continue
+		}
+		if resp.StatusCode >= 500 {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			stats.observe(time.Since(start), true)
+			lastErr = fmt.Errorf("server error: %d", resp.StatusCode)
+			continue
+		}
+		stats.observe(time.Since(start), false)
+		return resp, nil
+	}
+	return nil, fmt.Errorf("request %s failed after %d retries: %w", path, config.Retries, lastErr)
+}
+
+// UpstreamMetrics 各上游接口的统计快照
+func UpstreamMetrics() map[string]UpstreamStats {
+	upstream.mu.Lock()
+	defer upstream.mu.Unlock()
+	result := make(map[string]UpstreamStats, len(upstream.endpoints))
+	for name, e := range upstream.endpoints {
+		e.mu.Lock()
+		stats := UpstreamStats{Requests: e.requests, Errors: e.errors}
+		if e.requests > 0 {
+			stats.AvgLatency = e.sum / float64(e.requests)
+		}
+		e.mu.Unlock()
+		result[name] = stats
+	}
+	return result
+}
+
+// WriteUpstreamMetrics 以Prometheus文本格式输出上游接口统计
+func WriteUpstreamMetrics(w io.Writer) {
+	upstream.mu.Lock()
+	names := make([]string, 0, len(upstream.endpoints))
+	for name := range upstream.endpoints {
+		names = append(names, name)
+	}
+	upstream.mu.Unlock()
+	sort.Strings(names)
+
+	fmt.Fprintln(w, "# HELP phira_upstream_requests_total Phira主站API请求次数（含重试）")
+	fmt.Fprintln(w, "# TYPE phira_upstream_requests_total counter")
+	for _, name := range names {
+		e := upstream.endpoint(name)
+		e.mu.Lock()
+		fmt.Fprintf(w, "phira_upstream_requests_total{endpoint=%q} %d\n", name, e.requests)
+		e.mu.Unlock()
+	}
+
+	fmt.Fprintln(w, "# HELP phira_upstream_errors_total Phira主站API失败次数（网络错误、超时与5xx）")
+	fmt.Fprintln(w, "# TYPE phira_upstream_errors_total counter")
+	for _, name := range names {
+		e := upstream.endpoint(name)
+		e.mu.Lock()
+		fmt.Fprintf(w, "phira_upstream_errors_total{endpoint=%q} %d\n", name, e.errors)
+		e.mu.Unlock()
+	}
+
+	fmt.Fprintln(w, "# HELP phira_upstream_request_duration_seconds Phira主站API请求耗时")
+	fmt.Fprintln(w, "# TYPE phira_upstream_request_duration_seconds histogram")
+	for _, name := range names {
+		e := upstream.endpoint(name)
+		e.mu.Lock()
+		for i, bound := range upstreamLatencyBuckets {
+			fmt.Fprintf(w, "phira_upstream_request_duration_seconds_bucket{endpoint=%q,le=\"%g\"} %d\n", name, bound, e.buckets[i])
+		}
+		fmt.Fprintf(w, "phira_upstream_request_duration_seconds_bucket{endpoint=%q,le=\"+Inf\"} %d\n", name, e.requests)
+		fmt.Fprintf(w, "phira_upstream_request_duration_seconds_sum{endpoint=%q} %g\n", name, e.sum)
+		fmt.Fprintf(w, "phira_upstream_request_duration_seconds_count{endpoint=%q} %d\n", name, e.requests)
+		e.mu.Unlock()
+	}
+}
@@ -2,6 +2,7 @@ package server
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -13,7 +14,7 @@ import (
 )
 
 const (
-	Host = "https://phira.5wyxi.com"
+	Host = "https://phira.5wyxi.com" // Phira主站API默认地址（可通过配置 phira_api.base_url 修改）
 )
 
 // User 用户
@@ -21,13 +22,28 @@ type User struct {
 	ID   int32
 	Name string
 	Lang string
+	Rks  float32 // 主站rks（认证时获取，用于资料卡）
 
 	server  *Server
 	session atomic.Value // *Session
 	room    atomic.Value // *Room
+	queued  atomic.Value // *Room - 正在排队的房间
 
-	monitor  atomic.Bool
-	gameTime atomic.Uint32
+	monitor    atomic.Bool
+	globalChat atomic.Bool // 是否订阅全服频道
+	gameTime   atomic.Uint32
+	lastActive atomic.Int64 // 最后一次操作时间（UnixNano）
+	ip         atomic.Value // string - 客户端IP（认证时记录）
+	lastRoom   atomic.Value // string - 最近所在房间的ID
+	profile    atomic.Value // userProfile - 玩家自定义的显示资料
+	shareStats atomic.Bool  // 是否向房间成员展示资料卡（默认不展示）
+	card       atomic.Pointer[common.ProfileCard]
+
+	lastScoreUpdate atomic.Int64 // 最后一次转发实时成绩的时间（UnixNano）
+	srtt            atomic.Int64 // 平滑往返延迟（纳秒），见 Latency
+
+	// 最近一次断开连接的原因（见 disconnect.go）
+	lastDisconnect atomic.Pointer[DisconnectRecord]
 
 	mu           sync.RWMutex
 	disconnected bool
@@ -36,20 +52,23 @@ type User struct {
 
 // NewUser 创建新用户
 func NewUser(id int32, name, lang string, server *Server) *User {
-	return &User{
+	u := &User{
 		ID:     id,
 		Name:   name,
 		Lang:   lang,
 		server: server,
 	}
+	u.MarkActive()
+	return u
 }
 
 // ToInfo 转换为用户信息
 func (u *User) ToInfo() common.UserInfo {
 	return common.UserInfo{
 		ID:      u.ID,
-		Name:    u.Name,
+		Name:    u.DisplayName(),
 		Monitor: u.monitor.Load(),
+		Card:    u.ProfileCard(),
 	}
 }
 
@@ -90,6 +109,17 @@ func (u *User) GetSession() *Session {
 // SetRoom 设置房间
 func (u *User) SetRoom(room *Room) {
 	u.room.Store(room)
+	if room != nil {
+		u.lastRoom.Store(room.ID.Value)
+	}
+}
+
+// LastRoom 当前或最近所在房间的ID（从未进入房间时为空）
+func (u *User) LastRoom() string {
+	if id, ok := u.lastRoom.Load().(string); ok {
+		return id
+	}
+	return ""
 }
 
 // GetRoom 获取房间
@@ -133,6 +163,19 @@ func (u *User) Send(cmd common.ServerCommand) {
 	}
 }
 
+// sendRoom 发送房间广播（调用方持有房间的 outbound），不等待发送队列
+// 发送队列已满的连接已经错过了广播，在新协程中断开，不阻塞其他成员
+func (u *User) sendRoom(cmd common.ServerCommand) {
+	session := u.GetSession()
+	if session == nil {
+		return
+	}
+	if err := session.TrySend(cmd); errors.Is(err, common.ErrSendQueueFull) && session.overflowed.CompareAndSwap(false, true) {
+		log.Printf("用户 `%s(%d)` 的发送队列已满，断开连接", u.Name, u.ID)
+		go session.Disconnect(common.DisconnectReasonSlowConsumer, "发送队列已满")
+	}
+}
+
 // Dangle 处理用户连接断开
 func (u *User) Dangle() {
 	room := u.GetRoom()
@@ -164,6 +207,11 @@ func (u *User) Dangle() {
 		return
 	}
 
+	// 等待准备阶段房主断线时按策略处理，避免其余玩家无法取消准备
+	if room != nil {
+		room.onHostLeaveWaitForReady(u)
+	}
+
 	// 正常悬挂，设置10秒超时
 	log.Printf("用户 `%s(%d)` 连接断开，进入挂起状态", u.Name, u.ID)
 	u.mu.Lock()
@@ -201,83 +249,58 @@ func (u *User) HandleDangleTimeout() {
 	}
 }
 
+// ErrTokenRejected 认证服务拒绝了token（无效、过期或用户被封禁），区别于网络错误
+var ErrTokenRejected = errors.New("token rejected")
+
 // UserInfoFromAPI 从API获取用户信息（带缓存和指数回退重试）
 func UserInfoFromAPI(token string) (*User, *common.ClientRoomState, error) {
 	// 命中缓存时直接复用，避免重复请求
-	if id, name, lang, ok := globalAuthCache.get(token); ok {
+	if id, name, lang, rks, ok := globalAuthCache.get(token); ok {
 		log.Printf("Token缓存命中，复用用户信息 (id=%d, name=%s)", id, name)
 		return &User{
 			ID:   id,
 			Name: name,
 			Lang: lang,
+			Rks:  rks,
 		}, nil, nil
 	}
 
-	// 未命中缓存，带指数回退地请求API
-	const maxRetries = 3
-	backoff := 500 * time.Millisecond
-
-	httpClient := &http.Client{Timeout: 10 * time.Second}
-
-	var lastErr error
-	for attempt := 0; attempt <= maxRetries; attempt++ {
-		if attempt > 0 {
-			log.Printf("Token认证第 %d 次重试（回退 %v）: %v", attempt, backoff, lastErr)
-			time.Sleep(backoff)
-			backoff *= 2
-		}
-
-		req, err := http.NewRequest("GET", Host+"/me", nil)
-		if err != nil {
-			return nil, nil, err
-		}
-		req.Header.Set("Authorization", "Bearer "+token)
-
-		resp, err := httpClient.Do(req)
-		if err != nil {
-			lastErr = err
-			continue
-		}
-
-		if resp.StatusCode != http.StatusOK {
-			resp.Body.Close()
-			// 认证失败（4xx）不重试，直接返回错误；服务端错误（5xx）继续重试
-			if resp.StatusCode >= 400 && resp.StatusCode < 500 {
-				return nil, nil, fmt.Errorf("authentication failed")
-			}
-			lastErr = fmt.Errorf("server error: %d", resp.StatusCode)
-			continue
-		}
-
-		var userInfo struct {
-			ID       int32  `json:"id"`
-			Name     string `json:"name"`
-			Language string `json:"language"`
-		}
-		err = json.NewDecoder(resp.Body).Decode(&userInfo)
-		resp.Body.Close()
-		if err != nil {
-			lastErr = err
-			continue
-		}
+	// 未命中缓存，请求API（网络错误与5xx按配置重试）
+	resp, err := upstream.get("me", "/me", token)
+	if err != nil {
+		return nil, nil, fmt.Errorf("authentication failed: %w", err)
+	}
+	defer resp.Body.Close()
 
-		// 写入缓存
-		globalAuthCache.set(token, userInfo.ID, userInfo.Name, userInfo.Language)
+	// 认证失败（4xx）不重试，直接返回错误
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("authentication failed: %w", ErrTokenRejected)
+	}
 
-		return &User{
-			ID:   userInfo.ID,
-			Name: userInfo.Name,
-			Lang: userInfo.Language,
-		}, nil, nil
+	var userInfo struct {
+		ID       int32   `json:"id"`
+		Name     string  `json:"name"`
+		Language string  `json:"language"`
+		Rks      float32 `json:"rks"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&userInfo); err != nil {
+		return nil, nil, err
 	}
 
-	return nil, nil, fmt.Errorf("authentication failed after %d retries: %w", maxRetries, lastErr)
+	// 写入缓存
+	globalAuthCache.set(token, userInfo.ID, userInfo.Name, userInfo.Language, userInfo.Rks)
+
+	return &User{
+		ID:   userInfo.ID,
+		Name: userInfo.Name,
+		Lang: userInfo.Language,
+		Rks:  userInfo.Rks,
+	}, nil, nil
 }
 
 // FetchChart 从API获取谱面信息
-func FetchChart(chartID int32) (*Chart, error) {
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(fmt.Sprintf("%s/chart/%d", Host, chartID))
+func FetchChart(chartID int64) (*Chart, error) {
+	resp, err := upstream.get("chart", fmt.Sprintf("/chart/%d", chartID), "")
 	if err != nil {
 		return nil, err
 	}
@@ -291,13 +314,14 @@ func FetchChart(chartID int32) (*Chart, error) {
 	if err := json.NewDecoder(resp.Body).Decode(&chart); err != nil {
 		return nil, err
 	}
+	level := common.ParseChartLevel(chart.Level)
+	chart.DifficultyName, chart.LevelNumber = level.Name, level.Level
 	return &chart, nil
 }
 
 // FetchRecord 从API获取记录信息
-func FetchRecord(recordID int32) (*Record, error) {
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Get(fmt.Sprintf("%s/record/%d", Host, recordID))
+func FetchRecord(recordID int64) (*Record, error) {
+	resp, err := upstream.get("record", fmt.Sprintf("/record/%d", recordID), "")
 	if err != nil {
 		return nil, err
 	}
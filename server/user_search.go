@@ -0,0 +1,100 @@
+package server
+
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	userSearchDefaultLimit = 20
+	userSearchMaxLimit     = 100
+)
+
+// UserSearchResult 按名称搜索用户的结果
+type UserSearchResult struct {
+	ID          int32  `json:"id"`
+	Name        string `json:"name"`         // 账号名称
+	DisplayName string `json:"display_name"` // 显示名称（未修改时与账号名称相同）
+	Monitor     bool   `json:"monitor"`
+	Connected   bool   `json:"connected"`
+	Room        string `json:"room,omitempty"`
+	LastActive  int64  `json:"last_active"`      // 最后一次操作时间（毫秒时间戳）
+	Recent      bool   `json:"recent,omitempty"` // 已离开服务器，来自最近离线用户记录
+
+	prefix bool // 名称以关键词开头（排序时优先）
+}
+
+// SearchUsers 按账号名称或显示名称搜索服务器上的用户（含断线后尚未移除的用户与最近离线用户），不区分大小写
+// 前缀匹配排在子串匹配之前，其次在线用户优先，最后按用户ID排序
+func (s *Server) SearchUsers(query string, limit int) []UserSearchResult {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return nil
+	}
+	if limit <= 0 {
+		limit = userSearchDefaultLimit
+	}
+	if limit > userSearchMaxLimit {
+		limit = userSearchMaxLimit
+	}
+
+	var results []UserSearchResult
+	online := make(map[int32]bool)
+	s.users.Range(func(_, value interface{}) bool {
+		user := value.(*User)
+		online[user.ID] = true
+		name, display := strings.ToLower(user.Name), strings.ToLower(user.DisplayName())
+		if !strings.Contains(name, query) && !strings.Contains(display, query) {
+			return true
+		}
+
+		result := UserSearchResult{
+			ID:          user.ID,
+			Name:        user.Name,
+			DisplayName: user.DisplayName(),
+			Monitor:     user.IsMonitor(),
+			Connected:   !user.IsDisconnected(),
+			prefix:      strings.HasPrefix(name, query) || strings.HasPrefix(display, query),
+		}
+		if room := user.GetRoom(); room != nil {
+			result.Room = room.ID.Value
+		}
+		if last := user.lastActive.Load(); last != 0 {
+			result.LastActive = time.Unix(0, last).UnixMilli()
+		}
+		results = append(results, result)
+		return true
+	})
+	if s.recentUsers != nil {
+		for _, recent := range s.recentUsers.List(0) {
+			name, display := strings.ToLower(recent.Name), strings.ToLower(recent.DisplayName)
+			if online[recent.ID] || (!strings.Contains(name, query) && !strings.Contains(display, query)) {
+				continue
+			}
+			results = append(results, UserSearchResult{
+				ID:          recent.ID,
+				Name:        recent.Name,
+				DisplayName: recent.DisplayName,
+				LastActive:  recent.LastSeen,
+				Recent:      true,
+				prefix:      strings.HasPrefix(name, query) || strings.HasPrefix(display, query),
+			})
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.prefix != b.prefix {
+			return a.prefix
+		}
+		if a.Connected != b.Connected {
+			return a.Connected
+		}
+		return a.ID < b.ID
+	})
+	if len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
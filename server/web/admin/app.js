@@ -0,0 +1,190 @@
+// Phira MP 管理面板：通过Cookie会话访问管理员接口，通过管理员WebSocket接收房间实时状态
+(function () {
+  'use strict';
+
+  const $ = (id) => document.getElementById(id);
+  let csrfToken = '';
+  let ws = null;
+
+  function toast(text) {
+    const el = $('toast');
+    el.textContent = text;
+    el.hidden = false;
+    clearTimeout(toast.timer);
+    toast.timer = setTimeout(() => { el.hidden = true; }, 3000);
+  }
+
+  async function api(method, path, body) {
+    const headers = { 'Content-Type': 'application/json' };
+    if (method !== 'GET') headers['X-CSRF-Token'] = csrfToken;
+    const resp = await fetch(path, {
+      method,
+      headers,
+      credentials: 'same-origin',
+      body: body === undefined ? undefined : JSON.stringify(body),
+    });
+    const data = await resp.json().catch(() => ({ ok: false, error: 'bad-response' }));
+    if (!data.ok) throw new Error(data.error || resp.status);
+    return data;
+  }
+
+  function showDashboard(loggedIn) {
+    $('login-panel').hidden = loggedIn;
+    $('dashboard').hidden = !loggedIn;
+    $('logout').hidden = !loggedIn;
+  }
+
+  async function restoreSession() {
+    try {
+      const data = await api('GET', '/admin/session');
+      csrfToken = data.csrfToken;
+      showDashboard(true);
+      connect();
+    } catch (e) {
+      showDashboard(false);
+    }
+  }
+
+  function connect() {
+    const scheme = location.protocol === 'https:' ? 'wss:' : 'ws:';
+    ws = new WebSocket(scheme + '//' + location.host + '/ws');
+    ws.onopen = () => ws.send(JSON.stringify({ type: 'admin_subscribe' }));
+    ws.onmessage = (event) => {
+      const msg = JSON.parse(event.data);
+      switch (msg.type) {
+        case 'admin_subscribed':
+          setStatus(true);
+          break;
+        case 'admin_update':
+          renderRooms(msg.data.changes.rooms || []);
+          break;
+        case 'error':
+          toast('订阅失败：' + ((msg.data && msg.data.message) || 'unknown'));
+          break;
+      }
+    };
+    ws.onclose = () => {
+      setStatus(false);
+      if (!$('dashboard').hidden) setTimeout(connect, 3000);
+    };
+  }
+
+  function setStatus(online) {
+    const el = $('status');
+    el.textContent = online ? '实时' : '未连接';
+    el.classList.toggle('online', online);
+  }
+
+  function el(tag, attrs, children) {
+    const node = document.createElement(tag);
+    Object.assign(node, attrs || {});
+    (children || []).forEach((child) => node.append(child));
+    return node;
+  }
+
+  function button(text, onClick, danger) {
+    return el('button', { textContent: text, className: 'small' + (danger ? ' danger' : ''), onclick: onClick });
+  }
+
+  async function action(confirmText, method, path, body, doneText) {
+    if (confirmText && !confirm(confirmText)) return;
+    try {
+      await api(method, path, body);
+      toast(doneText);
+    } catch (e) {
+      toast('操作失败：' + e.message);
+    }
+  }
+
+  function userRow(user, monitor) {
+    const id = user.id;
+    const role = monitor ? '观察者' : (user.is_host ? '房主' : '玩家');
+    return el('tr', {}, [
+      el('td', { textContent: id }),
+      el('td', { textContent: user.name }),
+      el('td', { textContent: role }),
+      el('td', { textContent: user.connected ? '在线' : '断线' }),
+      el('td', { textContent: (user.connection && user.connection.ip) || '' }),
+      el('td', {}, [
+        button('踢出', () => action(`断开 ${user.name}(${id}) 的连接？`, 'POST', `/admin/users/${id}/disconnect`, undefined, '已断开')),
+        ' ',
+        button('封禁', () => action(`封禁 ${user.name}(${id}) 并断开连接？`, 'POST', '/admin/ban/user',
+          { userId: id, banned: true, disconnect: true }, '已封禁'), true),
+      ]),
+    ]);
+  }
+
+  function chartLabel(chart) {
+    const level = [chart.difficulty_name, chart.level_number].filter(Boolean).join(' ');
+    return level ? `${chart.name} [${level}]` : chart.name;
+  }
+
+  function renderRooms(rooms) {
+    $('room-count').textContent = `(${rooms.length})`;
+    const container = $('rooms');
+    container.replaceChildren();
+    if (rooms.length === 0) {
+      container.append(el('p', { className: 'muted', textContent: '暂无房间' }));
+      return;
+    }
+    rooms.forEach((room) => {
+      const id = room.roomid;
+      const tags = [room.state && room.state.type, room.locked && '已锁定', room.cycle && '循环', room.live && '直播']
+        .filter(Boolean)
+        .map((text) => el('span', { className: 'tag', textContent: text }));
+      const chart = room.chart ? `${chartLabel(room.chart)}(${room.chart.id})` : '未选谱';
+      const head = el('div', { className: 'room-head' }, [
+        el('strong', { textContent: `${id}  ${room.current_users}/${room.max_users}` }),
+        ...tags,
+        el('span', { className: 'muted', textContent: chart }),
+        button('发消息', () => {
+          const message = prompt(`向房间 ${id} 发送消息`);
+          if (message) action('', 'POST', `/admin/rooms/${encodeURIComponent(id)}/chat`, { message }, '已发送');
+        }),
+        button('解散', () => action(`解散房间 ${id}？`, 'POST', `/admin/rooms/${encodeURIComponent(id)}/disband`, undefined, '已解散'), true),
+      ]);
+      const rows = (room.users || []).map((u) => userRow(u, false))
+        .concat((room.monitors || []).map((u) => userRow(u, true)));
+      const table = el('table', {}, [
+        el('tr', {}, ['ID', '名称', '身份', '状态', 'IP', ''].map((text) => el('th', { textContent: text }))),
+        ...rows,
+      ]);
+      container.append(el('div', { className: 'room' }, [head, table]));
+    });
+  }
+
+  $('login-form').addEventListener('submit', async (event) => {
+    event.preventDefault();
+    $('login-error').textContent = '';
+    try {
+      const data = await api('POST', '/admin/login', { token: $('login-token').value });
+      csrfToken = data.csrfToken;
+      $('login-token').value = '';
+      showDashboard(true);
+      connect();
+    } catch (e) {
+      $('login-error').textContent = '登录失败：' + e.message;
+    }
+  });
+
+  $('broadcast-form').addEventListener('submit', async (event) => {
+    event.preventDefault();
+    const message = $('broadcast-message').value.trim();
+    if (!message) return;
+    await action('', 'POST', '/admin/broadcast', { message }, '广播已发送');
+    $('broadcast-message').value = '';
+  });
+
+  $('logout').addEventListener('click', async () => {
+    try {
+      await api('POST', '/admin/logout');
+    } catch (e) {
+      // 会话已失效时直接返回登录页
+    }
+    csrfToken = '';
+    showDashboard(false);
+    if (ws) ws.close();
+  });
+
+  restoreSession();
+})();
@@ -0,0 +1,70 @@
+// Phira MP 公开房间列表：定期拉取 /room 并渲染（只读）
+(function () {
+  'use strict';
+
+  const REFRESH_INTERVAL = 5000;
+  const STATE_NAMES = {
+    select_chart: '选择谱面',
+    waiting_for_ready: '等待准备',
+    loading: '加载中',
+    playing: '游戏中',
+  };
+  const $ = (id) => document.getElementById(id);
+
+  function el(tag, attrs, children) {
+    const node = document.createElement(tag);
+    Object.assign(node, attrs || {});
+    (children || []).forEach((child) => node.append(child));
+    return node;
+  }
+
+  function renderAddresses(addresses) {
+    $('addresses').hidden = addresses.length === 0;
+    $('address-list').replaceChildren(...addresses.map((addr) =>
+      el('li', {}, [el('code', { textContent: addr.address }), addr.region ? ` (${addr.region})` : ''])));
+  }
+
+  function chartLabel(chart) {
+    const level = [chart.difficulty_name, chart.level_number].filter(Boolean).join(' ');
+    return level ? `${chart.name} [${level}]` : chart.name;
+  }
+
+  function renderRooms(rooms) {
+    $('room-count').textContent = `(${rooms.length})`;
+    const container = $('rooms');
+    container.replaceChildren();
+    if (rooms.length === 0) {
+      container.append(el('p', { className: 'muted', textContent: '暂无房间' }));
+      return;
+    }
+    rooms.forEach((room) => {
+      const tags = [el('span', { className: 'tag ' + room.state, textContent: STATE_NAMES[room.state] || room.state })];
+      if (room.lock) tags.push(el('span', { className: 'tag', textContent: '已锁定' }));
+      if (room.cycle) tags.push(el('span', { className: 'tag', textContent: '循环' }));
+      const chart = room.chart ? `${chartLabel(room.chart)} (${room.chart.id})` : '未选择谱面';
+      const players = (room.players || []).map((p) => p.name).join('、');
+      container.append(el('div', { className: 'room' }, [
+        el('strong', { textContent: room.roomid }),
+        ...tags,
+        el('span', { className: 'muted', textContent: `房主 ${room.host.name} · ${chart}` }),
+        el('div', { className: 'players', textContent: `${(room.players || []).length} 名玩家：${players || '无'}` }),
+      ]));
+    });
+  }
+
+  async function refresh() {
+    try {
+      const resp = await fetch('/room');
+      const body = await resp.json();
+      const list = body.data || body;
+      renderRooms(list.rooms || []);
+      renderAddresses(list.addresses || []);
+      $('updated').textContent = '更新于 ' + new Date().toLocaleTimeString();
+    } catch (e) {
+      $('updated').textContent = '获取房间列表失败';
+    }
+  }
+
+  refresh();
+  setInterval(refresh, REFRESH_INTERVAL);
+})();
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+)
+
+// webFiles 内置网页静态资源（管理面板与公开房间列表）
+//
+//go:embed web/admin web/public
+var webFiles embed.FS
+
+// 内置网页路径
+const (
+	AdminUIPath    = "/admin/ui/" // 管理面板
+	PublicPagePath = "/"          // 公开房间列表
+)
+
+// staticHandler 提供 web/<dir> 下的静态资源（仅GET/HEAD）
+func staticHandler(dir, prefix string) http.Handler {
+	static, err := fs.Sub(webFiles, "web/"+dir)
+	if err != nil {
+		panic(err)
+	}
+	files := http.StripPrefix(prefix, http.FileServer(http.FS(static)))
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			writeError(w, http.StatusMethodNotAllowed, "method-not-allowed")
+			return
+		}
+		// 禁止被其他站点嵌入，脚本与连接仅限本站
+		w.Header().Set("X-Frame-Options", "DENY")
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.Header().Set("Content-Security-Policy", "default-src 'self'; connect-src 'self' ws: wss:; frame-ancestors 'none'")
+		w.Header().Set("Cache-Control", "no-cache")
+		files.ServeHTTP(w, r)
+	})
+}
+
+// AdminUIHandler 内置管理面板（登录使用 /admin/login Cookie会话，实时数据来自管理员WebSocket）
+func AdminUIHandler() http.Handler {
+	return staticHandler("admin", AdminUIPath)
+}
+
+// PublicPageHandler 公开只读房间列表页（定期拉取 /room）
+func PublicPageHandler() http.Handler {
+	return staticHandler("public", PublicPagePath)
+}
@@ -34,6 +34,11 @@ type WebSocketClient struct {
 	server         *HTTPServer
 	subscribedRoom string
 	isAdmin        bool
+	adminSession   string        // 同源连接携带的管理员Cookie会话ID
+	latency        bool          // 是否开启延迟回报（定期推送meta消息）
+	rtt            time.Duration // 最近一次测得的往返延迟
+	srtt           time.Duration // 平滑往返延迟
+	pingNow        chan struct{} // 开启延迟回报时立即发送一次ping
 	mu             sync.RWMutex
 }
 
@@ -114,6 +119,17 @@ func (h *WebSocketHub) run() {
 	}
 }
 
+// closeServer 关闭属于指定HTTP服务的所有WebSocket连接（读取协程随后注销客户端）
+func (h *WebSocketHub) closeServer(server *HTTPServer) {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for client := range h.clients {
+		if client.server == server {
+			client.conn.Close()
+		}
+	}
+}
+
 // HandleWebSocket 处理WebSocket连接
 func (h *HTTPServer) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
@@ -123,9 +139,13 @@ func (h *HTTPServer) HandleWebSocket(w http.ResponseWriter, r *http.Request) {
 	}
 
 	client := &WebSocketClient{
-		conn:   conn,
-		send:   make(chan []byte, 256),
-		server: h,
+		conn:    conn,
+		send:    make(chan []byte, 256),
+		server:  h,
+		pingNow: make(chan struct{}, 1),
+	}
+	if session := h.cookieSession(r); session != nil && isSameOrigin(r) {
+		client.adminSession = session.ID
 	}
 
 	hub.register <- client
@@ -141,8 +161,9 @@ func (c *WebSocketClient) readPump() {
 	}()
 
 	c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
-	c.conn.SetPongHandler(func(string) error {
+	c.conn.SetPongHandler(func(payload string) error {
 		c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		c.recordPong(payload, time.Now())
 		return nil
 	})
 
@@ -166,7 +187,8 @@ func (c *WebSocketClient) readPump() {
 }
 
 func (c *WebSocketClient) writePump() {
-	ticker := time.NewTicker(30 * time.Second)
+	ticker := time.NewTicker(wsLatencyInterval)
+	lastPing := time.Now()
 	defer func() {
 		ticker.Stop()
 		c.conn.Close()
@@ -185,9 +207,19 @@ func (c *WebSocketClient) writePump() {
 				return
 			}
 
-		case <-ticker.C:
-			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
-			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+		case now := <-ticker.C:
+			// 开启延迟回报时每5秒ping一次，否则保持30秒心跳
+			if !c.latencyEnabled() && now.Sub(lastPing) < wsKeepaliveInterval {
+				continue
+			}
+			lastPing = now
+			if err := c.writePing(); err != nil {
+				return
+			}
+
+		case <-c.pingNow:
+			lastPing = time.Now()
+			if err := c.writePing(); err != nil {
 				return
 			}
 		}
@@ -211,6 +243,14 @@ func (c *WebSocketClient) handleMessage(msg *WebSocketMessage) {
 	case "admin_unsubscribe":
 		c.handleAdminUnsubscribe()
 
+	case "latency_subscribe":
+		c.setLatency(true)
+		c.sendMessage(WebSocketMessage{Type: "latency_subscribed"})
+
+	case "latency_unsubscribe":
+		c.setLatency(false)
+		c.sendMessage(WebSocketMessage{Type: "latency_unsubscribed"})
+
 	default:
 		c.sendError("invalid-message")
 	}
@@ -336,21 +376,36 @@ func (c *WebSocketClient) buildRoomData(room *Room) map[string]interface{} {
 	chart := room.GetChart()
 
 	data := map[string]interface{}{
-		"roomid": room.ID.Value,
-		"state":  c.getRoomStateString(room),
-		"locked": room.IsLocked(),
-		"cycle":  room.IsCycle(),
-		"live":   room.IsLive(),
+		"roomid":   room.ID.Value,
+		"state":    c.getRoomStateString(room),
+		"locked":   room.IsLocked(),
+		"cycle":    room.IsCycle(),
+		"live":     room.IsLive(),
+		"overflow": room.IsOverflow(),
+		"ranking":  room.GetAggregator().Name(),
 		"host": map[string]interface{}{
 			"id":   host.ID,
-			"name": host.Name,
+			"name": host.DisplayName(),
 		},
 	}
+	if summary := room.GetLastSummary(); summary != nil {
+		data["last_game"] = summary
+	}
+	if schedule := room.GetSchedule(); schedule != nil {
+		data["schedule"] = schedule
+	}
 
 	if chart != nil {
 		data["chart"] = map[string]interface{}{
-			"name": chart.Name,
-			"id":   chart.ID,
+			"name":            chart.Name,
+			"id":              chart.ID,
+			"level":           chart.Level,
+			"difficulty_name": chart.DifficultyName,
+			"level_number":    chart.LevelNumber,
+			"difficulty":      chart.Difficulty,
+			"charter":         chart.Charter,
+			"composer":        chart.Composer,
+			"illustration":    chart.Illustration,
 		}
 	}
 
@@ -358,11 +413,16 @@ func (c *WebSocketClient) buildRoomData(room *Room) map[string]interface{} {
 	usersData := make([]map[string]interface{}, 0, len(users))
 	for _, u := range users {
 		_, isReady := room.started.Load(u.ID)
-		usersData = append(usersData, map[string]interface{}{
+		entry := map[string]interface{}{
 			"id":       u.ID,
-			"name":     u.Name,
+			"name":     u.DisplayName(),
+			"avatar":   u.Avatar(),
 			"is_ready": isReady,
-		})
+		}
+		if latency := u.latencyMs(); latency > 0 {
+			entry["latency_ms"] = latency
+		}
+		usersData = append(usersData, entry)
 	}
 	data["users"] = usersData
 
@@ -370,8 +430,9 @@ func (c *WebSocketClient) buildRoomData(room *Room) map[string]interface{} {
 	monitorsData := make([]map[string]interface{}, 0, len(monitors))
 	for _, m := range monitors {
 		monitorsData = append(monitorsData, map[string]interface{}{
-			"id":   m.ID,
-			"name": m.Name,
+			"id":     m.ID,
+			"name":   m.DisplayName(),
+			"avatar": m.Avatar(),
 		})
 	}
 	data["monitors"] = monitorsData
@@ -385,7 +446,9 @@ func (c *WebSocketClient) buildAdminRoomData(room *Room) map[string]interface{}
 
 	// 添加管理员专属信息
 	users := room.GetUsers()
-	data["max_users"] = RoomMaxUsers
+	data["max_users"] = room.GetMaxUsers()
+	data["max_monitors"] = room.GetMaxMonitors()
+	data["official"] = room.IsOfficial()
 	data["current_users"] = len(users)
 	data["current_monitors"] = len(room.GetMonitors())
 
@@ -395,15 +458,21 @@ func (c *WebSocketClient) buildAdminRoomData(room *Room) map[string]interface{}
 		_, isReady := room.started.Load(u.ID)
 		_, finished := room.results.Load(u.ID)
 		_, aborted := room.aborted.Load(u.ID)
+		_, pending := room.pendingResults.Load(u.ID)
 
 		usersData = append(usersData, map[string]interface{}{
-			"id":        u.ID,
-			"name":      u.Name,
-			"connected": !u.IsDisconnected(),
-			"is_host":   room.GetHost().ID == u.ID,
-			"is_ready":  isReady,
-			"finished":  finished,
-			"aborted":   aborted,
+			"id":           u.ID,
+			"name":         u.Name,
+			"display_name": u.DisplayName(),
+			"avatar":       u.Avatar(),
+			"connected":    !u.IsDisconnected(),
+			"is_host":      room.GetHost().ID == u.ID,
+			"is_ready":     isReady,
+			"finished":     finished,
+			"aborted":      aborted,
+			"pending":      pending,
+			"afk":          u.IsAFK(),
+			"connection":   u.ConnectionInfo(),
 		})
 	}
 	data["users"] = usersData
@@ -418,6 +487,8 @@ func (c *WebSocketClient) getRoomStateString(room *Room) string {
 		return "select_chart"
 	case InternalStateWaitForReady:
 		return "waiting_for_ready"
+	case InternalStateLoading:
+		return "loading"
 	case InternalStatePlaying:
 		return "playing"
 	default:
@@ -426,17 +497,35 @@ func (c *WebSocketClient) getRoomStateString(room *Room) string {
 }
 
 func (c *WebSocketClient) validateAdminToken(token string) bool {
+	// 未携带token时使用管理员Cookie会话
+	if token == "" {
+		return c.adminSession != "" && c.server.adminSessions.Get(c.adminSession) != nil
+	}
+
 	// 检查永久token
 	if c.server.config.AdminToken != "" && token == c.server.config.AdminToken {
 		return true
 	}
 
+	// 检查OIDC令牌
+	if c.server.oidc != nil && LooksLikeJWT(token) {
+		claims, err := c.server.oidc.Verify(token)
+		if err != nil {
+			log.Printf("[安全] WebSocket OIDC令牌验证失败: %v", err)
+			return false
+		}
+		log.Printf("管理员 %s 通过OIDC订阅WebSocket管理数据", claims.Display())
+		return true
+	}
+
 	// 检查临时token（不验证IP，因为WebSocket可能来自不同IP）
 	return c.server.otpManager.ValidateTempTokenNoIP(token)
 }
 
 // BroadcastRoomUpdate 广播房间更新
 func BroadcastRoomUpdate(room *Room) {
+	room.server.touchRoomList()
+
 	client := &WebSocketClient{server: room.server.GetHTTPServer()}
 	data := client.buildRoomData(room)
 
@@ -484,12 +573,46 @@ func BroadcastRoomLog(roomID string, message string) {
 	}
 }
 
+// BroadcastRoomMessage 向房间的WebSocket订阅者推送房间消息（聊天、加入、离开等），time 与玩家客户端收到的一致
+func BroadcastRoomMessage(roomID string, message common.Message) {
+	msg := WebSocketMessage{
+		Type: "room_message",
+		Data: message,
+	}
+
+	msgBytes, err := json.Marshal(msg)
+	if err != nil {
+		log.Printf("序列化房间消息失败: %v", err)
+		return
+	}
+
+	hub.broadcast <- &BroadcastMessage{
+		roomID:  roomID,
+		message: msgBytes,
+		isAdmin: false,
+	}
+}
+
 // BroadcastAdminUpdate 广播管理员更新
+// 过载降级推迟快照时，期间的多次更新合并为 loadShedSnapshotDelay 后的一次推送
 func BroadcastAdminUpdate(server *Server) {
 	if server.GetHTTPServer() == nil {
 		return
 	}
+	if server.shedding(LoadShedSnapshots) {
+		if !server.adminDeferred.Swap(true) {
+			time.AfterFunc(loadShedSnapshotDelay, func() {
+				server.adminDeferred.Store(false)
+				broadcastAdminSnapshot(server)
+			})
+		}
+		return
+	}
+	broadcastAdminSnapshot(server)
+}
 
+// broadcastAdminSnapshot 向管理员推送所有房间的全量快照
+func broadcastAdminSnapshot(server *Server) {
 	client := &WebSocketClient{server: server.GetHTTPServer()}
 	rooms := server.GetAllRooms()
 	roomsData := make([]interface{}, 0, len(rooms))
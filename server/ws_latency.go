@@ -0,0 +1,83 @@
+package server
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// wsKeepaliveInterval 未开启延迟回报时的心跳间隔
+	wsKeepaliveInterval = 30 * time.Second
+	// wsLatencyInterval 开启延迟回报后的ping间隔
+	wsLatencyInterval = 5 * time.Second
+)
+
+// wsPingPayload ping帧携带发送时间（纳秒），客户端回复的pong帧原样带回
+func wsPingPayload(now time.Time) []byte {
+	return []byte(strconv.FormatInt(now.UnixNano(), 10))
+}
+
+// latencyEnabled 是否开启了延迟回报
+func (c *WebSocketClient) latencyEnabled() bool {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.latency
+}
+
+// setLatency 开启或关闭延迟回报，开启时立即测量一次
+func (c *WebSocketClient) setLatency(enabled bool) {
+	c.mu.Lock()
+	c.latency = enabled
+	c.mu.Unlock()
+
+	if enabled && c.pingNow != nil {
+		select {
+		case c.pingNow <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// writePing 发送携带时间戳的ping帧（仅在writePump中调用）
+func (c *WebSocketClient) writePing() error {
+	now := time.Now()
+	c.conn.SetWriteDeadline(now.Add(10 * time.Second))
+	return c.conn.WriteMessage(websocket.PingMessage, wsPingPayload(now))
+}
+
+// recordPong 根据pong帧中的时间戳计算往返延迟，开启延迟回报时推送meta消息
+func (c *WebSocketClient) recordPong(payload string, now time.Time) {
+	sent, err := strconv.ParseInt(payload, 10, 64)
+	if err != nil {
+		return
+	}
+	rtt := now.Sub(time.Unix(0, sent))
+	if rtt < 0 {
+		return
+	}
+
+	c.mu.Lock()
+	c.rtt = rtt
+	if c.srtt == 0 {
+		c.srtt = rtt
+	} else {
+		// 平滑往返延迟（同TCP的SRTT，权重1/8）
+		c.srtt += (rtt - c.srtt) / 8
+	}
+	srtt := c.srtt
+	enabled := c.latency
+	c.mu.Unlock()
+
+	if enabled {
+		c.sendMessage(WebSocketMessage{
+			Type: "meta",
+			Data: map[string]interface{}{
+				"rtt_ms":      float64(rtt.Microseconds()) / 1000,
+				"srtt_ms":     float64(srtt.Microseconds()) / 1000,
+				"server_time": now.UnixMilli(),
+			},
+		})
+	}
+}
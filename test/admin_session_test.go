@@ -0,0 +1,36 @@
+package test
+
+import (
+	"testing"
+
+	"phira-mp/server"
+)
+
+// TestAdminSessionManager 测试管理员Cookie会话
+func TestAdminSessionManager(t *testing.T) {
+	manager := server.NewAdminSessionManager()
+
+	session := manager.Create("admin_token", "127.0.0.1")
+	if session.ID == "" || session.CSRFToken == "" || session.ID == session.CSRFToken {
+		t.Fatal("会话ID与CSRF token应该是不同的随机值")
+	}
+	if got := manager.Get(session.ID); got != session {
+		t.Error("应该能获取刚创建的会话")
+	}
+	if manager.Get(session.CSRFToken) != nil {
+		t.Error("CSRF token不能作为会话ID使用")
+	}
+
+	other := manager.Create("otp", "127.0.0.1")
+	if other.ID == session.ID {
+		t.Error("不同会话的ID不应该相同")
+	}
+
+	manager.Revoke(session.ID)
+	if manager.Get(session.ID) != nil {
+		t.Error("注销后会话应该失效")
+	}
+	if manager.Get(other.ID) == nil {
+		t.Error("注销一个会话不应该影响其他会话")
+	}
+}
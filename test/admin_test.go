@@ -4,6 +4,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"phira-mp/server"
 )
@@ -332,6 +333,65 @@ func TestAdminDataPersistence(t *testing.T) {
 	}
 }
 
+// TestAdminDataBackup 测试管理员数据原子保存、备份与损坏恢复
+func TestAdminDataBackup(t *testing.T) {
+	tempDir := t.TempDir()
+	dataPath := filepath.Join(tempDir, "admin_data.json")
+
+	adminData := server.NewAdminData()
+	if adminData.Dirty() {
+		t.Error("新建的数据不应标记为已修改")
+	}
+	adminData.BanUser(1, true)
+	if !adminData.Dirty() {
+		t.Error("封禁后应标记为已修改")
+	}
+	if err := adminData.Save(dataPath); err != nil {
+		t.Fatalf("保存失败: %v", err)
+	}
+	if adminData.Dirty() {
+		t.Error("保存后不应标记为已修改")
+	}
+
+	// 第二次保存时上一版本成为备份
+	adminData.BanUser(2, true)
+	if err := adminData.Save(dataPath); err != nil {
+		t.Fatalf("保存失败: %v", err)
+	}
+	backup := server.NewAdminData()
+	if err := backup.Load(dataPath + ".bak"); err != nil {
+		t.Fatalf("加载备份失败: %v", err)
+	}
+	if !backup.IsUserBanned(1) || backup.IsUserBanned(2) {
+		t.Error("备份应为上一版本的数据")
+	}
+
+	entries, _ := os.ReadDir(tempDir)
+	if len(entries) != 2 {
+		t.Errorf("目录中应只有数据文件与备份，实际: %d 个文件", len(entries))
+	}
+
+	// 数据文件损坏时从备份恢复
+	if err := os.WriteFile(dataPath, []byte(`{"banned_users": {"1": tr`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	recovered := server.NewAdminData()
+	if err := recovered.Load(dataPath); err != nil {
+		t.Fatalf("应从备份恢复: %v", err)
+	}
+	if !recovered.IsUserBanned(1) || !recovered.Dirty() {
+		t.Error("恢复后的数据应来自备份并等待重新保存")
+	}
+
+	// 损坏的文件不会覆盖已有备份
+	if err := recovered.Save(dataPath); err != nil {
+		t.Fatalf("保存失败: %v", err)
+	}
+	if err := backup.Load(dataPath + ".bak"); err != nil {
+		t.Errorf("备份不应被损坏的文件覆盖: %v", err)
+	}
+}
+
 // TestBanUserTwice 测试重复封禁
 func TestBanUserTwice(t *testing.T) {
 	adminData := server.NewAdminData()
@@ -386,6 +446,70 @@ func TestRoomBanCleanup(t *testing.T) {
 	}
 }
 
+// TestBanListWithReason 测试带原因与期限的封禁列表
+func TestBanListWithReason(t *testing.T) {
+	adminData := server.NewAdminData()
+
+	adminData.BanUserWithReason(2, "刷屏", time.Hour)
+	adminData.BanUserWithReason(1, "作弊", 0)
+	adminData.BanUserWithReason(3, "已过期", time.Millisecond)
+	adminData.BanUserFromRoomWithReason(5, "room1", "辱骂", 0)
+	time.Sleep(5 * time.Millisecond)
+
+	if adminData.IsUserBanned(3) {
+		t.Error("过期的封禁不应生效")
+	}
+
+	bans := adminData.UserBanList()
+	if len(bans) != 2 || bans[0].UserID != 1 || bans[1].UserID != 2 {
+		t.Fatalf("封禁列表应按用户ID排序且不含过期项，实际: %+v", bans)
+	}
+	if bans[0].Reason != "作弊" || bans[0].ExpiresAt != 0 || bans[0].CreatedAt == 0 {
+		t.Errorf("永久封禁信息错误: %+v", bans[0])
+	}
+	if bans[1].ExpiresAt <= time.Now().UnixMilli() {
+		t.Errorf("限时封禁的到期时间错误: %+v", bans[1])
+	}
+
+	if n := adminData.PruneExpiredBans(); n != 1 {
+		t.Errorf("应清理1条过期封禁，实际: %d", n)
+	}
+
+	roomBans := adminData.RoomBanList("room1")
+	if len(roomBans) != 1 || roomBans[0].UserID != 5 || roomBans[0].Reason != "辱骂" {
+		t.Errorf("房间封禁列表错误: %+v", roomBans)
+	}
+	if !adminData.UnbanUserFromRoom(5, "room1") {
+		t.Error("解除存在的房间封禁应返回true")
+	}
+	if adminData.UnbanUserFromRoom(5, "room1") || adminData.UnbanUser(99) {
+		t.Error("解除不存在的封禁应返回false")
+	}
+}
+
+// TestAdminDataLoadLegacy 测试加载旧版封禁数据（userId -> true）
+func TestAdminDataLoadLegacy(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "admin_data.json")
+	legacy := `{"banned_users":{"1":true,"2":false},"room_bans":{"room1":{"3":true}}}`
+	if err := os.WriteFile(path, []byte(legacy), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	adminData := server.NewAdminData()
+	if err := adminData.Load(path); err != nil {
+		t.Fatalf("加载旧版数据失败: %v", err)
+	}
+	if !adminData.IsUserBanned(1) || adminData.IsUserBanned(2) {
+		t.Error("旧版服务器封禁数据加载错误")
+	}
+	if !adminData.IsUserBannedFromRoom(3, "room1") {
+		t.Error("旧版房间封禁数据加载错误")
+	}
+	if len(adminData.UserBanList()) != 1 {
+		t.Errorf("旧版中的false不应出现在封禁列表中: %+v", adminData.UserBanList())
+	}
+}
+
 // TestLargeUserID 测试大用户ID
 func TestLargeUserID(t *testing.T) {
 	adminData := server.NewAdminData()
@@ -0,0 +1,63 @@
+package test
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"phira-mp/common"
+	"phira-mp/server"
+)
+
+// TestAuthTimeout 测试未完成握手或认证的连接在认证超时后被断开，已认证的连接不受影响
+func TestAuthTimeout(t *testing.T) {
+	config := server.DefaultConfig()
+	config.AuthTimeout = 1
+	ts := startTestServer(t, config)
+
+	authed := ts.connect(t, 1)
+
+	// 只建立TCP连接，不发送握手
+	idle, err := net.Dial("tcp", ts.addr)
+	if err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	defer idle.Close()
+
+	// 完成握手后只发送心跳，不认证
+	conn, err := net.Dial("tcp", ts.addr)
+	if err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	stream, err := common.NewNegotiatedClientStream(conn, common.SupportedProtocols, 0)
+	if err != nil {
+		t.Fatalf("握手失败: %v", err)
+	}
+	defer stream.Close()
+	go func() {
+		for stream.Send(common.ClientCommand{Type: common.ClientCmdPing}) == nil {
+			time.Sleep(200 * time.Millisecond)
+		}
+	}()
+
+	idle.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := idle.Read(make([]byte, 1)); err == nil || isTimeoutErr(err) {
+		t.Errorf("未握手的连接应被服务器断开: %v", err)
+	}
+	waitFor(t, "未认证的会话被断开", func() bool {
+		_, auth := ts.ReapedConnections()
+		return auth == 1
+	})
+	if handshake, _ := ts.ReapedConnections(); handshake != 1 {
+		t.Errorf("未完成握手而被断开的连接数不正确: %d", handshake)
+	}
+
+	if u := ts.GetUser(1); u == nil || u.IsDisconnected() || authed.Me() == nil {
+		t.Error("已认证的连接不应被断开")
+	}
+}
+
+func isTimeoutErr(err error) bool {
+	netErr, ok := err.(net.Error)
+	return ok && netErr.Timeout()
+}
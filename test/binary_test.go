@@ -3,6 +3,7 @@ package test
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"math"
 	"testing"
 
@@ -619,3 +620,62 @@ func TestBinaryDataRoundTrip(t *testing.T) {
 		})
 	}
 }
+
+// TestListAllocationGuard 测试列表长度前缀过大时直接返回错误，而不是按长度分配内存
+func TestListAllocationGuard(t *testing.T) {
+	t.Cleanup(func() { common.SetDecodeLimits(common.DefaultDecodeLimits) })
+
+	encode := func(cmdType common.ClientCommandType, length uint64, body []byte) []byte {
+		w := common.NewBinaryWriter()
+		common.WriteUint8(w, uint8(cmdType))
+		w.Uleb(length)
+		w.WriteBytes(body)
+		return w.Data()
+	}
+
+	// 长度远超数据包大小：视为截断
+	var cmd common.ClientCommand
+	if err := common.DecodeStrict(encode(common.ClientCmdTouches, 1<<62, nil), &cmd); err == nil {
+		t.Error("超大长度前缀应返回错误")
+	}
+
+	// 超过元素数量上限
+	judge := common.NewBinaryWriter()
+	(&common.JudgeEvent{Time: 1, LineID: 1, NoteID: 1, Judgement: common.JudgementPerfect}).WriteBinary(judge)
+	body := bytes.Repeat(judge.Data(), 10)
+	common.SetDecodeLimits(common.DecodeLimits{JudgeEvents: 5})
+	err := common.DecodeStrict(encode(common.ClientCmdJudges, 10, body), &cmd)
+	if !errors.Is(err, common.ErrListTooLong) {
+		t.Errorf("超过上限应返回 ErrListTooLong，实际 %v", err)
+	}
+	if limits := common.GetDecodeLimits(); limits.JudgeEvents != 5 || limits.TouchFrames != common.DefaultDecodeLimits.TouchFrames {
+		t.Errorf("未设置的上限应使用默认值: %+v", limits)
+	}
+
+	common.SetDecodeLimits(common.DecodeLimits{JudgeEvents: 10})
+	if err := common.DecodeStrict(encode(common.ClientCmdJudges, 10, body), &cmd); err != nil || len(cmd.Judges) != 10 {
+		t.Errorf("未超过上限应正常解码: %d %v", len(cmd.Judges), err)
+	}
+
+	// 触摸帧内的触摸点同样受限
+	common.SetDecodeLimits(common.DecodeLimits{TouchPoints: 2})
+	frame := common.TouchFrame{Time: 1, Points: make([]common.TouchPoint, 3)}
+	w := common.NewBinaryWriter()
+	frame.WriteBinary(w)
+	if err := common.DecodeStrict(encode(common.ClientCmdTouches, 1, w.Data()), &cmd); !errors.Is(err, common.ErrListTooLong) {
+		t.Errorf("触摸点超过上限应返回 ErrListTooLong，实际 %v", err)
+	}
+
+	// 字符串长度前缀溢出不应引发panic
+	w = common.NewBinaryWriter()
+	w.Uleb(math.MaxUint64)
+	if _, err := common.ReadString(common.NewBinaryReader(w.Data())); err == nil {
+		t.Error("超大字符串长度应返回错误")
+	}
+	w = common.NewBinaryWriter()
+	common.WriteUint8(w, uint8(common.ClientCmdChat))
+	w.Uleb(math.MaxUint64)
+	if err := common.DecodeStrict(w.Data(), &cmd); err == nil {
+		t.Error("超大Varchar长度应返回错误")
+	}
+}
@@ -0,0 +1,87 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"phira-mp/common"
+	"phira-mp/server"
+)
+
+// TestBotsPlayGame 测试模拟玩家自动准备、向观察者发送数据并提交模拟成绩，真实玩家离开后自动离开
+func TestBotsPlayGame(t *testing.T) {
+	config := server.DefaultConfig()
+	config.LiveMode = true
+	config.Monitors = []int32{2}
+	config.Bots = true
+	ts := startTestServer(t, config)
+	useFakeChartAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": 1, "name": "Bots"})
+	})
+
+	host := ts.connect(t, 1)
+	monitor := ts.connect(t, 2)
+	roomID, _ := common.NewRoomId("bots")
+	host.CreateRoom(roomID)
+	waitFor(t, "房间创建", func() bool { return ts.GetRoom(roomID) != nil })
+	room := ts.GetRoom(roomID)
+	monitor.JoinRoom(roomID, true)
+	waitFor(t, "观察者加入", func() bool { return room.IsLive() })
+	host.SelectChart(1)
+	waitFor(t, "选择谱面", func() bool { return room.GetChart() != nil })
+
+	bots, err := ts.SpawnBots(room, 2, 500*time.Millisecond)
+	if err != nil || len(bots) != 2 {
+		t.Fatalf("加入模拟玩家失败: %v", err)
+	}
+	if !bots[0].User.IsBot() || bots[0].User.IsGuest() {
+		t.Errorf("模拟玩家ID不正确: %d", bots[0].User.ID)
+	}
+
+	host.RequestStart()
+	waitFor(t, "开始游戏", func() bool { return room.GetState() == server.InternalStatePlaying })
+	host.Abort()
+
+	bot := bots[0].User.ID
+	waitFor(t, "观察者收到模拟玩家的实时数据", func() bool {
+		frames, judges := monitor.LivePlayer(bot).Snapshot()
+		return monitor.LivePlayer(bot).LiveScore().Score > 0 && len(frames) > 0 && len(judges) > 0
+	})
+	waitFor(t, "对局结束", func() bool { return room.GetState() == server.InternalStateSelectChart })
+
+	played := 0
+	waitFor(t, "收到2个模拟成绩", func() bool {
+		for _, msg := range host.TakeMessages() {
+			if msg.Type == common.MsgPlayed && (msg.User == bots[0].User.ID || msg.User == bots[1].User.ID) {
+				played++
+				if msg.Score <= 0 || msg.Perfect+msg.Good+msg.Bad+msg.Miss == 0 {
+					t.Errorf("模拟成绩不正确: %+v", msg)
+				}
+			}
+		}
+		return played == 2
+	})
+	if ts.GetGameHistory().Len() != 0 {
+		t.Error("有模拟玩家参与的对局不应计入对局历史")
+	}
+
+	// 真实玩家与观察者离开后模拟玩家随之离开，房间被移除
+	monitor.LeaveRoom()
+	host.LeaveRoom()
+	waitFor(t, "房间移除", func() bool { return ts.GetRoom(roomID) == nil })
+}
+
+// TestBotsDisabled 测试未开启模拟玩家时不能加入
+func TestBotsDisabled(t *testing.T) {
+	ts := startTestServer(t, server.DefaultConfig())
+	host := ts.connect(t, 1)
+	roomID, _ := common.NewRoomId("no-bots")
+	host.CreateRoom(roomID)
+	waitFor(t, "房间创建", func() bool { return ts.GetRoom(roomID) != nil })
+
+	if _, err := ts.SpawnBots(ts.GetRoom(roomID), 1, 0); err == nil {
+		t.Error("未开启模拟玩家时应拒绝加入")
+	}
+}
@@ -0,0 +1,48 @@
+package test
+
+import (
+	"testing"
+
+	"phira-mp/common"
+	"phira-mp/server"
+)
+
+// TestChatHistory 测试后加入的观察者查询房间消息历史，超过保存条数时只保留最近的消息
+func TestChatHistory(t *testing.T) {
+	config := server.DefaultConfig()
+	config.ChatHistorySize = 3
+	config.LiveMode = true
+	config.Monitors = []int32{3}
+	ts := startTestServer(t, config)
+
+	host := ts.connect(t, 1)
+	player := ts.connect(t, 2)
+	monitor := ts.connect(t, 3)
+	roomID, _ := common.NewRoomId("history")
+
+	host.CreateRoom(roomID)
+	waitFor(t, "创建房间", func() bool { return ts.GetRoom(roomID) != nil })
+	player.JoinRoom(roomID, false)
+	waitFor(t, "加入房间", func() bool { return ts.GetUser(2).GetRoom() != nil })
+	monitor.JoinRoom(roomID, true)
+	waitFor(t, "观察者加入房间", func() bool { return ts.GetUser(3).GetRoom() != nil })
+
+	// 创建房间的消息已被覆盖，只剩玩家加入、房间进入直播与观察者加入
+	if err := monitor.RequestChatHistory(0); err != nil {
+		t.Fatalf("查询失败: %v", err)
+	}
+	waitFor(t, "收到房间消息历史", func() bool { return len(monitor.ChatHistory()) == 3 })
+	history := monitor.ChatHistory()
+	if history[0].Type != common.MsgJoinRoom || history[0].User != 2 || history[1].Type != common.MsgLiveRoom || history[2].User != 3 {
+		t.Fatalf("房间消息历史不正确: %+v", history)
+	}
+	if history[0].Time == 0 || history[0].Time > history[2].Time {
+		t.Errorf("房间消息应带有时间并按时间排列: %+v", history)
+	}
+
+	monitor.RequestChatHistory(1)
+	waitFor(t, "只返回最近一条", func() bool {
+		h := monitor.ChatHistory()
+		return len(h) == 1 && h[0].User == 3
+	})
+}
@@ -2,15 +2,21 @@ package test
 
 import (
 	"bytes"
+	"encoding/json"
+	"net"
+	"reflect"
+	"strings"
 	"testing"
 
 	"phira-mp/common"
+	"phira-mp/server"
 )
 
-// TestClientCommandPing 测试Ping命令序列化
+// TestClientCommandPing 测试Ping命令序列化（V18起携带时间戳）
 func TestClientCommandPing(t *testing.T) {
 	cmd := common.ClientCommand{
 		Type: common.ClientCmdPing,
+		Ping: common.PingTimes{Time: 1730000000123, Echo: 1730000000001, Hold: 42},
 	}
 
 	w := common.NewBinaryWriter()
@@ -20,8 +26,8 @@ func TestClientCommandPing(t *testing.T) {
 	}
 
 	data := w.Data()
-	if len(data) != 1 {
-		t.Errorf("Ping命令数据长度应该是1，实际: %d", len(data))
+	if data[0] != byte(common.ClientCmdPing) {
+		t.Errorf("Ping命令类型字节不匹配: %d", data[0])
 	}
 
 	// 读取验证
@@ -35,6 +41,40 @@ func TestClientCommandPing(t *testing.T) {
 	if readCmd.Type != common.ClientCmdPing {
 		t.Errorf("命令类型不匹配，期望: %d, 实际: %d", common.ClientCmdPing, readCmd.Type)
 	}
+	if readCmd.Ping != cmd.Ping {
+		t.Errorf("Ping时间戳不匹配，期望: %+v, 实际: %+v", cmd.Ping, readCmd.Ping)
+	}
+}
+
+// TestPingLegacyPayload 测试原版客户端的 Ping 与 Pong 不携带时间戳（只有1字节的命令类型）
+func TestPingLegacyPayload(t *testing.T) {
+	legacy := common.WireFormat{Version: 1}
+	data, err := legacy.EncodeClient(common.ClientCommand{
+		Type: common.ClientCmdPing,
+		Ping: common.PingTimes{Time: 1730000000123, Echo: 1730000000001, Hold: 42},
+	})
+	if err != nil {
+		t.Fatalf("编码失败: %v", err)
+	}
+	if len(data) != 1 || data[0] != byte(common.ClientCmdPing) {
+		t.Errorf("原版Ping命令应该只有1字节，实际: %v", data)
+	}
+	cmd, err := legacy.DecodeClient([]byte{byte(common.ClientCmdPing)})
+	if err != nil || cmd.Type != common.ClientCmdPing || cmd.Ping != (common.PingTimes{}) {
+		t.Errorf("原版Ping命令解码不正确: %+v %v", cmd, err)
+	}
+
+	server, client := streamPair(t, 0, func(conn net.Conn) (*common.ClientStream, error) {
+		return common.NewClientStream(conn, 1)
+	})
+	server.Send(common.ServerCommand{Type: common.ServerCmdPong, Pong: common.PingTimes{Time: 1730000000123, Echo: 1730000000001}})
+	data, err = client.RecvRaw()
+	if err != nil {
+		t.Fatalf("接收失败: %v", err)
+	}
+	if len(data) != 1 || data[0] != byte(common.ServerCmdPong) {
+		t.Errorf("原版Pong命令应该只有1字节，实际: %v", data)
+	}
 }
 
 // TestClientCommandAuthenticate 测试认证命令
@@ -388,6 +428,38 @@ func TestClientCommandPlayed(t *testing.T) {
 	}
 }
 
+// TestClientCommandFrameBatch 测试触摸帧与判定合并命令
+func TestClientCommandFrameBatch(t *testing.T) {
+	cmd := common.ClientCommand{
+		Type:   common.ClientCmdFrameBatch,
+		Frames: []common.TouchFrame{{Time: 0.5, Points: []common.TouchPoint{{ID: 1, Pos: common.NewCompactPos(0.25, 0.75)}}}},
+		Judges: []common.JudgeEvent{{Time: 0.5, LineID: 3, NoteID: 4, Judgement: common.JudgementGood}},
+	}
+
+	w := common.NewBinaryWriter()
+	if err := cmd.WriteBinary(w); err != nil {
+		t.Fatalf("写入命令失败: %v", err)
+	}
+	var readCmd common.ClientCommand
+	if err := common.DecodeStrict(w.Data(), &readCmd); err != nil {
+		t.Fatalf("读取命令失败: %v", err)
+	}
+	if len(readCmd.Frames) != 1 || readCmd.Frames[0].Points[0].ID != 1 || len(readCmd.Judges) != 1 || readCmd.Judges[0] != cmd.Judges[0] {
+		t.Errorf("合并命令不匹配: %+v", readCmd)
+	}
+
+	// 只有触摸帧时判定列表为空
+	w = common.NewBinaryWriter()
+	(&common.ClientCommand{Type: common.ClientCmdFrameBatch, Frames: cmd.Frames}).WriteBinary(w)
+	if err := common.DecodeStrict(w.Data(), &readCmd); err != nil || len(readCmd.Judges) != 0 {
+		t.Errorf("只有触摸帧的合并命令解析不正确: %+v %v", readCmd, err)
+	}
+
+	if common.ClientCommandSupported(common.ProtocolV6, &cmd) || !common.ClientCommandSupported(common.ProtocolV7, &cmd) {
+		t.Error("FrameBatch 应从协议V7起可用")
+	}
+}
+
 // TestServerCommandPong 测试Pong响应
 func TestServerCommandPong(t *testing.T) {
 	cmd := common.ServerCommand{
@@ -527,9 +599,88 @@ func TestServerCommandMessage(t *testing.T) {
 	}
 }
 
+// TestReauthRequired 测试重新认证要求命令
+func TestReauthRequired(t *testing.T) {
+	cmd := common.ServerCommand{Type: common.ServerCmdReauthRequired, ReauthGrace: 60}
+
+	w := common.NewBinaryWriter()
+	if err := cmd.WriteBinary(w); err != nil {
+		t.Fatalf("写入失败: %v", err)
+	}
+
+	var readCmd common.ServerCommand
+	if err := readCmd.ReadBinary(common.NewBinaryReader(w.Data())); err != nil {
+		t.Fatalf("读取失败: %v", err)
+	}
+	if readCmd.Type != common.ServerCmdReauthRequired || readCmd.ReauthGrace != 60 {
+		t.Errorf("命令不匹配: 类型 %d, 宽限 %d", readCmd.Type, readCmd.ReauthGrace)
+	}
+}
+
+// TestMessageLiveRoom 测试直播状态消息
+func TestMessageLiveRoom(t *testing.T) {
+	for _, live := range []bool{true, false} {
+		msg := common.Message{Type: common.MsgLiveRoom, Live: live}
+
+		w := common.NewBinaryWriter()
+		msg.WriteBinary(w)
+
+		var readMsg common.Message
+		if err := readMsg.ReadBinary(common.NewBinaryReader(w.Data())); err != nil {
+			t.Fatalf("读取消息失败: %v", err)
+		}
+		if readMsg != msg {
+			t.Errorf("直播状态消息不匹配: %+v", readMsg)
+		}
+	}
+}
+
+// TestMessagePlayedJudgeStats 测试成绩消息中的判定统计
+func TestMessagePlayedJudgeStats(t *testing.T) {
+	msg := common.Message{
+		Type:      common.MsgPlayed,
+		User:      1,
+		Score:     980000,
+		Accuracy:  0.98,
+		FullCombo: false,
+		Perfect:   500,
+		Good:      20,
+		Bad:       1,
+		Miss:      2,
+		MaxCombo:  300,
+	}
+
+	w := common.NewBinaryWriter()
+	msg.WriteBinary(w)
+
+	var readMsg common.Message
+	if err := readMsg.ReadBinary(common.NewBinaryReader(w.Data())); err != nil {
+		t.Fatalf("读取消息失败: %v", err)
+	}
+	if readMsg != msg {
+		t.Errorf("成绩消息不匹配: %+v", readMsg)
+	}
+
+	// 旧版数据不包含判定统计
+	legacy := common.NewBinaryWriter()
+	common.WriteUint8(legacy, uint8(common.MsgPlayed))
+	common.WriteInt32(legacy, 1)
+	common.WriteInt32(legacy, 980000)
+	common.WriteFloat32(legacy, 0.98)
+	common.WriteBool(legacy, true)
+
+	var legacyMsg common.Message
+	if err := legacyMsg.ReadBinary(common.NewBinaryReader(legacy.Data())); err != nil {
+		t.Fatalf("读取旧版消息失败: %v", err)
+	}
+	if legacyMsg.Score != 980000 || !legacyMsg.FullCombo || legacyMsg.Perfect != 0 {
+		t.Errorf("旧版成绩消息解析不正确: %+v", legacyMsg)
+	}
+}
+
 // TestServerCommandChangeState 测试状态变更通知
 func TestServerCommandChangeState(t *testing.T) {
-	chartID := int32(123)
+	chartID := int64(123)
 	cmd := common.ServerCommand{
 		Type: common.ServerCmdChangeState,
 		ChangeState: &common.RoomState{
@@ -646,6 +797,108 @@ func TestServerCommandJudges(t *testing.T) {
 	}
 }
 
+// TestServerCommandQueueUpdate 测试排队状态命令
+func TestServerCommandQueueUpdate(t *testing.T) {
+	roomID, _ := common.NewRoomId("queue-room")
+	cmd := common.ServerCommand{
+		Type: common.ServerCmdQueueUpdate,
+		QueueUpdate: &common.QueueStatus{
+			RoomId:   roomID,
+			Position: 2,
+			Waiting: []common.UserInfo{
+				{ID: 1, Name: "Alice"},
+				{ID: 2, Name: "Bob"},
+			},
+		},
+	}
+
+	w := common.NewBinaryWriter()
+	if err := cmd.WriteBinary(w); err != nil {
+		t.Fatalf("写入命令失败: %v", err)
+	}
+
+	r := common.NewBinaryReader(w.Data())
+	var readCmd common.ServerCommand
+	if err := readCmd.ReadBinary(r); err != nil {
+		t.Fatalf("读取命令失败: %v", err)
+	}
+
+	if readCmd.QueueUpdate == nil {
+		t.Fatal("排队状态为空")
+	}
+	if readCmd.QueueUpdate.RoomId.Value != "queue-room" {
+		t.Errorf("房间ID不匹配，实际: %s", readCmd.QueueUpdate.RoomId.Value)
+	}
+	if readCmd.QueueUpdate.Position != 2 {
+		t.Errorf("排队位置不匹配，期望: 2, 实际: %d", readCmd.QueueUpdate.Position)
+	}
+	if len(readCmd.QueueUpdate.Waiting) != 2 || readCmd.QueueUpdate.Waiting[1].Name != "Bob" {
+		t.Errorf("等待列表不匹配: %v", readCmd.QueueUpdate.Waiting)
+	}
+}
+
+// TestServerCommandLoadProgress 测试谱面加载进度命令
+func TestServerCommandLoadProgress(t *testing.T) {
+	cmd := common.ServerCommand{
+		Type: common.ServerCmdLoadProgress,
+		LoadProgress: &common.LoadStatus{
+			Loaded:    1,
+			Total:     3,
+			Progress:  67,
+			Remaining: 12,
+		},
+	}
+
+	w := common.NewBinaryWriter()
+	if err := cmd.WriteBinary(w); err != nil {
+		t.Fatalf("写入命令失败: %v", err)
+	}
+
+	r := common.NewBinaryReader(w.Data())
+	var readCmd common.ServerCommand
+	if err := readCmd.ReadBinary(r); err != nil {
+		t.Fatalf("读取命令失败: %v", err)
+	}
+
+	if readCmd.LoadProgress == nil || *readCmd.LoadProgress != *cmd.LoadProgress {
+		t.Errorf("加载进度不匹配: %+v", readCmd.LoadProgress)
+	}
+}
+
+// TestJoinRoomResponseReadyUsers 测试加入房间响应中的已准备玩家列表
+func TestJoinRoomResponseReadyUsers(t *testing.T) {
+	resp := common.JoinRoomResponse{
+		State:      common.RoomState{Type: common.RoomStateWaitingForReady},
+		Users:      []common.UserInfo{{ID: 1, Name: "Alice"}, {ID: 2, Name: "Bob"}},
+		ReadyUsers: []int32{1},
+	}
+
+	w := common.NewBinaryWriter()
+	resp.WriteBinary(w)
+
+	var readResp common.JoinRoomResponse
+	if err := readResp.ReadBinary(common.NewBinaryReader(w.Data())); err != nil {
+		t.Fatalf("读取响应失败: %v", err)
+	}
+	if len(readResp.ReadyUsers) != 1 || readResp.ReadyUsers[0] != 1 {
+		t.Errorf("已准备玩家不匹配: %v", readResp.ReadyUsers)
+	}
+
+	// 旧版数据不包含已准备玩家列表
+	legacy := common.NewBinaryWriter()
+	resp.State.WriteBinary(legacy)
+	legacy.Uleb(0)
+	common.WriteBool(legacy, true)
+
+	var legacyResp common.JoinRoomResponse
+	if err := legacyResp.ReadBinary(common.NewBinaryReader(legacy.Data())); err != nil {
+		t.Fatalf("读取旧版响应失败: %v", err)
+	}
+	if !legacyResp.Live || len(legacyResp.ReadyUsers) != 0 {
+		t.Errorf("旧版响应解析不正确: %+v", legacyResp)
+	}
+}
+
 // TestAllClientCommands 测试所有客户端命令类型
 func TestAllClientCommands(t *testing.T) {
 	// 测试简单命令（不需要额外数据）
@@ -696,6 +949,15 @@ func TestAllServerCommands(t *testing.T) {
 		common.ServerCmdCancelReady,
 		common.ServerCmdPlayed,
 		common.ServerCmdAbort,
+		common.ServerCmdQueueJoin,
+		common.ServerCmdOverflowRoom,
+		common.ServerCmdSwitchRole,
+		common.ServerCmdGlobalChat,
+		common.ServerCmdGlobalSubscribe,
+		common.ServerCmdSetMaxMonitors,
+		common.ServerCmdSubmitResult,
+		common.ServerCmdReauthenticate,
+		common.ServerCmdUpdateProfile,
 	}
 
 	for _, cmdType := range simpleCommands {
@@ -753,6 +1015,56 @@ func TestCommandRoundTrip(t *testing.T) {
 				ChartID: 987654321,
 			},
 		},
+		{
+			name: "SwitchRole",
+			cmd: common.ClientCommand{
+				Type:    common.ClientCmdSwitchRole,
+				Monitor: true,
+			},
+		},
+		{
+			name: "GlobalChat",
+			cmd: common.ClientCommand{
+				Type:    common.ClientCmdGlobalChat,
+				Message: "半决赛即将开始",
+			},
+		},
+		{
+			name: "GlobalSubscribe",
+			cmd: common.ClientCommand{
+				Type:      common.ClientCmdGlobalSubscribe,
+				Subscribe: true,
+			},
+		},
+		{
+			name: "SetMaxMonitors",
+			cmd: common.ClientCommand{
+				Type:        common.ClientCmdSetMaxMonitors,
+				MaxMonitors: 12,
+			},
+		},
+		{
+			name: "SubmitResult",
+			cmd: common.ClientCommand{
+				Type:    common.ClientCmdSubmitResult,
+				Payload: `{"chart":1,"score":1000000}`,
+			},
+		},
+		{
+			name: "Reauthenticate",
+			cmd: common.ClientCommand{
+				Type:  common.ClientCmdReauthenticate,
+				Token: "new-token",
+			},
+		},
+		{
+			name: "UpdateProfile",
+			cmd: common.ClientCommand{
+				Type:   common.ClientCmdUpdateProfile,
+				Name:   "新名字",
+				Avatar: "preset:3",
+			},
+		},
 	}
 
 	for _, tc := range testCases {
@@ -777,6 +1089,141 @@ func TestCommandRoundTrip(t *testing.T) {
 	}
 }
 
+// TestProfileCommands 测试玩家资料命令的编解码
+func TestProfileCommands(t *testing.T) {
+	w := common.NewBinaryWriter()
+	cmd := common.ClientCommand{Type: common.ClientCmdUpdateProfile, Name: "新名字", Avatar: "https://example.com/a.png"}
+	cmd.WriteBinary(w)
+	var readCmd common.ClientCommand
+	if err := readCmd.ReadBinary(common.NewBinaryReader(w.Data())); err != nil {
+		t.Fatalf("读取失败: %v", err)
+	}
+	if readCmd.Name != cmd.Name || readCmd.Avatar != cmd.Avatar {
+		t.Errorf("资料不匹配: %+v", readCmd)
+	}
+
+	// 超长名称应被拒绝
+	w = common.NewBinaryWriter()
+	common.WriteUint8(w, uint8(common.ClientCmdUpdateProfile))
+	common.WriteString(w, strings.Repeat("a", common.ProfileNameMaxLen+1))
+	common.WriteString(w, "")
+	if err := readCmd.ReadBinary(common.NewBinaryReader(w.Data())); err == nil {
+		t.Error("超长名称应该返回错误")
+	}
+
+	w = common.NewBinaryWriter()
+	update := common.ServerCommand{
+		Type:           common.ServerCmdProfileUpdated,
+		ProfileUpdated: &common.ProfileInfo{ID: 7, Name: "新名字", Avatar: "preset:3"},
+	}
+	update.WriteBinary(w)
+	var readUpdate common.ServerCommand
+	if err := readUpdate.ReadBinary(common.NewBinaryReader(w.Data())); err != nil {
+		t.Fatalf("读取失败: %v", err)
+	}
+	if readUpdate.ProfileUpdated == nil || *readUpdate.ProfileUpdated != *update.ProfileUpdated {
+		t.Errorf("资料广播不匹配: %+v", readUpdate.ProfileUpdated)
+	}
+
+	// 原版与V2协议不支持资料命令
+	for _, v := range []uint8{common.ProtocolV1, common.ProtocolV2} {
+		if common.ServerCommandSupported(v, &update) {
+			t.Errorf("协议 %d 不应支持ProfileUpdated", v)
+		}
+	}
+	if !common.ServerCommandSupported(common.ProtocolV3, &update) {
+		t.Error("协议V3应支持ProfileUpdated")
+	}
+}
+
+// TestRoomClosedCommand 测试房间关闭通知的编解码
+func TestRoomClosedCommand(t *testing.T) {
+	roomID, _ := common.NewRoomId("closed")
+	cmd := common.ServerCommand{
+		Type:       common.ServerCmdRoomClosed,
+		RoomClosed: &common.RoomClosed{RoomId: roomID, Reason: "管理员解散"},
+	}
+	w := common.NewBinaryWriter()
+	cmd.WriteBinary(w)
+	var read common.ServerCommand
+	if err := read.ReadBinary(common.NewBinaryReader(w.Data())); err != nil {
+		t.Fatalf("读取失败: %v", err)
+	}
+	if read.RoomClosed == nil || *read.RoomClosed != *cmd.RoomClosed {
+		t.Errorf("房间关闭通知不匹配: %+v", read.RoomClosed)
+	}
+
+	if common.ServerCommandSupported(common.ProtocolV4, &cmd) {
+		t.Error("协议V4不应支持RoomClosed")
+	}
+	if !common.ServerCommandSupported(common.ProtocolV5, &cmd) {
+		t.Error("协议V5应支持RoomClosed")
+	}
+}
+
+// TestKickedCommand 测试移出通知的编解码，以及不在房间中时省略房间ID
+func TestKickedCommand(t *testing.T) {
+	roomID, _ := common.NewRoomId("kicked")
+	for _, kicked := range []common.Kicked{
+		{RoomId: &roomID, Reason: common.KickReasonHost},
+		{Reason: common.KickReasonBanned, Message: "违规"},
+	} {
+		cmd := common.ServerCommand{Type: common.ServerCmdKicked, Kicked: &kicked}
+		w := common.NewBinaryWriter()
+		cmd.WriteBinary(w)
+		var read common.ServerCommand
+		if err := read.ReadBinary(common.NewBinaryReader(w.Data())); err != nil {
+			t.Fatalf("读取失败: %v", err)
+		}
+		if !reflect.DeepEqual(read.Kicked, cmd.Kicked) {
+			t.Errorf("移出通知不匹配: %+v", read.Kicked)
+		}
+	}
+
+	cmd := common.ServerCommand{Type: common.ServerCmdKicked, Kicked: &common.Kicked{Reason: common.KickReasonAdmin}}
+	if common.ServerCommandSupported(common.ProtocolV22, &cmd) {
+		t.Error("协议V22不应支持Kicked")
+	}
+	if !common.ServerCommandSupported(common.ProtocolV23, &cmd) {
+		t.Error("协议V23应支持Kicked")
+	}
+}
+
+// TestValidateChartCommand 测试谱面预检命令与结果的编解码
+func TestValidateChartCommand(t *testing.T) {
+	cmd := common.ClientCommand{Type: common.ClientCmdValidateChart, ChartID: 42}
+	w := common.NewBinaryWriter()
+	cmd.WriteBinary(w)
+	var readCmd common.ClientCommand
+	if err := common.DecodeStrict(w.Data(), &readCmd); err != nil || readCmd.ChartID != 42 {
+		t.Fatalf("预检命令解析不正确: %+v %v", readCmd, err)
+	}
+
+	result := common.ServerCommand{
+		Type: common.ServerCmdValidateChart,
+		ValidateChartResult: &common.Result[common.ChartValidation]{Ok: &common.ChartValidation{
+			Chart:  common.ChartPreview{ID: 42, Name: "Test", Difficulty: 14.5},
+			Reason: "只有房主可以选择谱面",
+		}},
+	}
+	w = common.NewBinaryWriter()
+	result.WriteBinary(w)
+	var read common.ServerCommand
+	if err := common.DecodeStrict(w.Data(), &read); err != nil {
+		t.Fatalf("读取失败: %v", err)
+	}
+	if read.ValidateChartResult == nil || read.ValidateChartResult.Ok == nil || *read.ValidateChartResult.Ok != *result.ValidateChartResult.Ok {
+		t.Errorf("预检结果不匹配: %+v", read.ValidateChartResult)
+	}
+
+	if common.ClientCommandSupported(common.ProtocolV7, &cmd) || !common.ClientCommandSupported(common.ProtocolV8, &cmd) {
+		t.Error("ValidateChart 应从协议V8起可用")
+	}
+	if common.ServerCommandSupported(common.ProtocolV7, &result) || !common.ServerCommandSupported(common.ProtocolV8, &result) {
+		t.Error("ValidateChart 结果应从协议V8起可用")
+	}
+}
+
 // TestEmptyData 测试空数据处理
 func TestEmptyData(t *testing.T) {
 	// 测试读取空数据
@@ -803,6 +1250,69 @@ func TestInvalidCommandType(t *testing.T) {
 	}
 }
 
+// TestStrictDecoding 测试严格解码拒绝截断、多余字节与未知类型
+func TestStrictDecoding(t *testing.T) {
+	// 截断：Chat 结果缺少成功标记
+	truncated := common.NewBinaryWriter()
+	common.WriteUint8(truncated, uint8(common.ServerCmdChat))
+	var cmd common.ServerCommand
+	if err := common.DecodeStrict(truncated.Data(), &cmd); err == nil {
+		t.Error("严格解码应拒绝截断的命令")
+	}
+	// 宽松模式保持原有行为
+	if err := cmd.ReadBinary(common.NewBinaryReader(truncated.Data())); err != nil {
+		t.Errorf("宽松解码不应拒绝截断的命令: %v", err)
+	}
+
+	// 截断：消息内容缺失
+	msg := common.NewBinaryWriter()
+	common.WriteUint8(msg, uint8(common.ServerCmdMessage))
+	common.WriteUint8(msg, uint8(common.MsgChat))
+	common.WriteInt32(msg, 1)
+	if err := common.DecodeStrict(msg.Data(), &common.ServerCommand{}); err == nil {
+		t.Error("严格解码应拒绝缺少字段的消息")
+	}
+
+	// 多余字节
+	pong := common.NewBinaryWriter()
+	common.WriteUint8(pong, uint8(common.ServerCmdPong))
+	common.WriteUint8(pong, 0)
+	if err := common.DecodeStrict(pong.Data(), &common.ServerCommand{}); err == nil {
+		t.Error("严格解码应拒绝多余字节")
+	}
+
+	// 未知命令类型与消息类型
+	if err := common.DecodeStrict([]byte{255}, &common.ServerCommand{}); err == nil {
+		t.Error("严格解码应拒绝未知命令类型")
+	}
+	if err := common.DecodeStrict([]byte{uint8(common.ServerCmdMessage), 255}, &common.ServerCommand{}); err == nil {
+		t.Error("严格解码应拒绝未知消息类型")
+	}
+
+	// 旧版成绩消息不含判定统计，仍应通过严格解码
+	legacy := common.NewBinaryWriter()
+	common.WriteUint8(legacy, uint8(common.ServerCmdMessage))
+	common.WriteUint8(legacy, uint8(common.MsgPlayed))
+	common.WriteInt32(legacy, 1)
+	common.WriteInt32(legacy, 980000)
+	common.WriteFloat32(legacy, 0.98)
+	common.WriteBool(legacy, true)
+	var played common.ServerCommand
+	if err := common.DecodeStrict(legacy.Data(), &played); err != nil {
+		t.Fatalf("严格解码旧版成绩消息失败: %v", err)
+	}
+	if played.Message == nil || played.Message.Score != 980000 {
+		t.Errorf("旧版成绩消息解析不正确: %+v", played.Message)
+	}
+
+	// 完整命令正常解码
+	ok := common.NewBinaryWriter()
+	(&common.ServerCommand{Type: common.ServerCmdPong}).WriteBinary(ok)
+	if err := common.DecodeStrict(ok.Data(), &common.ServerCommand{}); err != nil {
+		t.Errorf("严格解码完整命令失败: %v", err)
+	}
+}
+
 // TestLargeMessage 测试大消息处理
 func TestLargeMessage(t *testing.T) {
 	// 创建一个较大的聊天消息（在限制范围内，最大200字符）
@@ -860,3 +1370,95 @@ func absFloat32(f float32) float32 {
 	}
 	return f
 }
+
+// TestCommandJSON 测试命令的JSON调试编码
+func TestCommandJSON(t *testing.T) {
+	roomID, _ := common.NewRoomId("room-1")
+	clientCmds := []common.ClientCommand{
+		{Type: common.ClientCmdChat, Message: "你好"},
+		{Type: common.ClientCmdJoinRoom, RoomId: roomID, Monitor: true},
+		{Type: common.ClientCmdCreateRoom, RoomId: roomID, Password: "secret"},
+		{Type: common.ClientCmdLockRoom, Lock: false},
+		{Type: common.ClientCmdSetPassword, Password: "secret", Private: true},
+		{Type: common.ClientCmdSetTeam, UserID: 2, Team: 1},
+		{Type: common.ClientCmdJudges, Judges: []common.JudgeEvent{{Time: 1.5, LineID: 2, NoteID: 3, Judgement: common.JudgementGood}}},
+		{Type: common.ClientCmdJudges, Judges: []common.JudgeEvent{{NoteID: 4, Judgement: common.JudgementFlickPerfect | common.JudgementEarly}, {Judgement: 0x3f | common.JudgementLate}}},
+	}
+	for _, cmd := range clientCmds {
+		data, err := json.Marshal(cmd)
+		if err != nil {
+			t.Fatalf("编码失败: %v", err)
+		}
+		var decoded common.ClientCommand
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("解码 %s 失败: %v", data, err)
+		}
+		if !reflect.DeepEqual(cmd, decoded) {
+			t.Errorf("往返结果不一致: %s\n期望 %+v\n实际 %+v", data, cmd, decoded)
+		}
+	}
+
+	data, _ := json.Marshal(clientCmds[0])
+	if string(data) != `{"type":"Chat","message":"你好"}` {
+		t.Errorf("只应输出命令相关字段: %s", data)
+	}
+
+	errMsg := "房间不存在"
+	serverCmds := []common.ServerCommand{
+		{Type: common.ServerCmdPong},
+		{Type: common.ServerCmdJoinRoom, JoinRoomResult: &common.Result[common.JoinRoomResponse]{Ok: &common.JoinRoomResponse{
+			State: common.RoomState{Type: common.RoomStateSelectChart},
+			Users: []common.UserInfo{{ID: 1, Name: "A"}},
+		}}},
+		{Type: common.ServerCmdLockRoom, LockRoomResult: &common.Result[struct{}]{Err: &errMsg}},
+		{Type: common.ServerCmdReady, ReadyResult: &common.Result[struct{}]{Ok: &struct{}{}}},
+		{Type: common.ServerCmdMessage, Message: &common.Message{Type: common.MsgPlayed, User: 1, Score: 990000, Perfect: 100}},
+		{Type: common.ServerCmdChatHistory, ChatHistoryResult: &common.Result[common.ChatHistory]{Ok: &common.ChatHistory{
+			Messages: []common.Message{{Type: common.MsgJoinRoom, User: 2, Name: "B", Time: 1000}},
+		}}},
+		{Type: common.ServerCmdKicked, Kicked: &common.Kicked{RoomId: &roomID, Reason: common.KickReasonRoomBanned, Message: "违规"}},
+		{Type: common.ServerCmdDisconnect, Disconnect: &common.Disconnect{Reason: common.DisconnectReasonServerShutdown, Message: "服务器维护"}},
+	}
+	for _, cmd := range serverCmds {
+		data, err := json.Marshal(cmd)
+		if err != nil {
+			t.Fatalf("编码失败: %v", err)
+		}
+		var decoded common.ServerCommand
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatalf("解码 %s 失败: %v", data, err)
+		}
+		if !reflect.DeepEqual(cmd, decoded) {
+			t.Errorf("往返结果不一致: %s\n期望 %+v\n实际 %+v", data, cmd, decoded)
+		}
+	}
+}
+
+// TestParseChartLevel 测试拆分难度标签
+func TestParseChartLevel(t *testing.T) {
+	cases := []struct {
+		label string
+		want  common.ChartLevel
+		text  string
+	}{
+		{"IN Lv.15", common.ChartLevel{Name: "IN", Level: "15"}, "IN 15"},
+		{"SP Lv.?", common.ChartLevel{Name: "SP", Level: "?"}, "SP ?"},
+		{"Lv.13", common.ChartLevel{Level: "13"}, "13"},
+		{"AT", common.ChartLevel{Name: "AT"}, "AT"},
+		{"", common.ChartLevel{}, ""},
+	}
+	for _, c := range cases {
+		got := common.ParseChartLevel(c.label)
+		if got != c.want || got.String() != c.text {
+			t.Errorf("ParseChartLevel(%q) = %+v (%q)，期望 %+v (%q)", c.label, got, got.String(), c.want, c.text)
+		}
+	}
+}
+
+// TestDebugCommandJSON 测试调试日志中的token打码
+func TestDebugCommandJSON(t *testing.T) {
+	out := server.DebugCommandJSON(common.ClientCommand{Type: common.ClientCmdAuthenticate, Token: "abcdefghijklmnop"})
+	if strings.Contains(out, "efgh") || !strings.Contains(out, `"type":"Authenticate"`) {
+		t.Errorf("token应该打码: %s", out)
+	}
+}
@@ -0,0 +1,145 @@
+package test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"phira-mp/common"
+	"phira-mp/server"
+)
+
+// TestContestRoom 测试比赛房间：白名单外的用户只能观战，对局由管理员手动开始，结算后导出结果并解散房间
+func TestContestRoom(t *testing.T) {
+	config := server.DefaultConfig()
+	config.LiveMode = true
+	config.ContestResultDir = t.TempDir()
+	ts := startTestServer(t, config)
+
+	records := map[string]string{
+		"/record/101": `{"id": 101, "player": 1, "score": 990000, "accuracy": 0.95, "perfect": 10}`,
+		"/record/102": `{"id": 102, "player": 2, "score": 980000, "accuracy": 0.99, "perfect": 10}`,
+	}
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/me":
+			serveFakeMe(w, r)
+		case strings.HasPrefix(r.URL.Path, "/chart/"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": 5, "name": "Final"})
+		case records[r.URL.Path] != "":
+			w.Write([]byte(records[r.URL.Path]))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(api.Close)
+	server.ConfigurePhiraAPI(server.PhiraAPIConfig{BaseURL: api.URL, Timeout: 5})
+
+	host := ts.connect(t, 1)
+	player := ts.connect(t, 2)
+	viewer := ts.connect(t, 3)
+	roomID, _ := common.NewRoomId("contest")
+	host.CreateRoom(roomID)
+	waitFor(t, "创建房间", func() bool { return ts.GetRoom(roomID) != nil })
+	room := ts.GetRoom(roomID)
+
+	room.SetContest([]int32{2})
+	if !room.CanCompete(1) || !room.CanCompete(2) || room.CanCompete(3) {
+		t.Fatalf("白名单应包含指定用户与房间内的房主: %+v", room.GetContest())
+	}
+
+	viewer.JoinRoom(roomID, false)
+	time.Sleep(200 * time.Millisecond)
+	if ts.GetUser(3).GetRoom() != nil {
+		t.Fatal("不在白名单中的用户不应以玩家身份加入")
+	}
+	if code := common.LookupErrorCode(server.ErrContestMonitorOnly); code != common.ErrCodeRoomLocked {
+		t.Errorf("比赛房间拒绝参赛应对应错误码 %d，实际 %d", common.ErrCodeRoomLocked, code)
+	}
+	viewer.JoinRoom(roomID, true)
+	waitFor(t, "以观察者身份加入", func() bool { return len(room.GetMonitors()) == 1 })
+	viewer.SwitchRole(false)
+	time.Sleep(200 * time.Millisecond)
+	if !ts.GetUser(3).IsMonitor() {
+		t.Fatal("不在白名单中的观察者不应转为玩家")
+	}
+
+	player.JoinRoom(roomID, false)
+	waitFor(t, "白名单玩家加入", func() bool { return len(room.GetUsers()) == 2 })
+
+	host.SelectChart(5)
+	waitFor(t, "选择谱面", func() bool { return room.GetChart() != nil })
+	host.RequestStart()
+	waitFor(t, "等待准备", func() bool { return room.GetState() == server.InternalStateWaitForReady })
+	if err := room.StartContest(false); !errors.Is(err, server.ErrNotAllReady) {
+		t.Fatalf("未全员准备时应拒绝开始: %v", err)
+	}
+	player.Ready()
+	time.Sleep(200 * time.Millisecond)
+	if room.GetState() != server.InternalStateWaitForReady {
+		t.Fatal("比赛房间全员准备后不应自动开始")
+	}
+	if err := room.StartContest(false); err != nil {
+		t.Fatalf("开始比赛失败: %v", err)
+	}
+	waitFor(t, "开始比赛", func() bool { return room.GetState() == server.InternalStatePlaying })
+
+	host.Played(101)
+	player.Played(102)
+	waitFor(t, "结算后解散房间", func() bool { return ts.GetRoom(roomID) == nil })
+	if ts.GetUser(2).GetRoom() != nil || ts.GetUser(3).GetRoom() != nil {
+		t.Error("解散后成员应离开房间")
+	}
+
+	files, _ := filepath.Glob(filepath.Join(config.ContestResultDir, "contest-contest-*.json"))
+	if len(files) != 1 {
+		t.Fatalf("应导出一份比赛结果，实际 %v", files)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result server.ContestResult
+	if err := json.Unmarshal(data, &result); err != nil {
+		t.Fatalf("解析比赛结果失败: %v", err)
+	}
+	if result.RoomID != "contest" || len(result.Whitelist) != 2 || result.Summary == nil || len(result.Summary.Ranking) != 2 {
+		t.Errorf("比赛结果不正确: %s", data)
+	}
+}
+
+// TestContestQueueWhitelist 测试排队放行时重新检查白名单：排队后被移出白名单的用户不会以玩家身份进入
+func TestContestQueueWhitelist(t *testing.T) {
+	config := server.DefaultConfig()
+	config.RoomQueueSize = 2
+	ts := startTestServer(t, config)
+
+	host := ts.connect(t, 1)
+	player := ts.connect(t, 2)
+	waiting := ts.connect(t, 3)
+	roomID, _ := common.NewRoomId("contest-queue")
+	host.CreateRoom(roomID)
+	waitFor(t, "创建房间", func() bool { return ts.GetRoom(roomID) != nil })
+	room := ts.GetRoom(roomID)
+	room.SetMaxUsers(2)
+	room.SetContest([]int32{2, 3})
+
+	player.JoinRoom(roomID, false)
+	waitFor(t, "白名单玩家加入", func() bool { return len(room.GetUsers()) == 2 })
+	waiting.QueueJoin(roomID)
+	waitFor(t, "加入等待队列", func() bool { return len(room.GetQueue()) == 1 })
+
+	room.SetContest(nil)
+	player.LeaveRoom()
+	waitFor(t, "排队放行", func() bool { return len(room.GetQueue()) == 0 })
+	time.Sleep(200 * time.Millisecond)
+	if ts.GetUser(3).GetRoom() != nil {
+		t.Error("已被移出白名单的用户不应从等待队列进入比赛房间")
+	}
+}
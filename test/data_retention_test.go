@@ -0,0 +1,216 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"phira-mp/server"
+)
+
+// TestPurgeUser 测试清除用户在对局历史与已使用成绩中的数据
+func TestPurgeUser(t *testing.T) {
+	ts := startTestServer(t, server.ServerConfig{GameHistorySize: 16, RecordLedgerSize: 16})
+	now := time.Now()
+
+	history := ts.GetGameHistory()
+	history.Add("cup", &server.GameSummary{Ranking: []server.RankEntry{
+		{Rank: 1, UserID: 1, Points: 900},
+		{Rank: 2, UserID: 2, Points: 800},
+	}, Aborted: []int32{3}, EndedAt: now.UnixMilli()})
+	history.Add("cup", &server.GameSummary{Ranking: []server.RankEntry{
+		{Rank: 1, UserID: 1, Points: 700},
+	}, EndedAt: now.UnixMilli()})
+	history.Add("cup", &server.GameSummary{Ranking: []server.RankEntry{
+		{Rank: 1, UserID: 2, Points: 600},
+	}, EndedAt: now.UnixMilli()})
+
+	ledger := ts.GetRecordLedger()
+	ledger.Consume(101, 1, "cup", now)
+	ledger.Consume(102, 1, "cup", now)
+	ledger.Consume(201, 2, "cup", now)
+
+	result, err := ts.PurgeUser(1)
+	if err != nil {
+		t.Fatalf("清除失败: %v", err)
+	}
+	if result.Games != 2 || result.Records != 2 || result.Replays != 0 {
+		t.Errorf("清除结果不正确: %+v", result)
+	}
+
+	// 仅有用户1成绩的对局整局删除，其他玩家名次不变
+	if history.Len() != 2 {
+		t.Errorf("应剩余2局，实际 %d", history.Len())
+	}
+	board := history.Leaderboard("cup", 0, now.UnixMilli())
+	if len(board.Players) != 1 || board.Players[0].UserID != 2 || board.Players[0].Games != 2 {
+		t.Errorf("清除后累计排行不正确: %+v", board)
+	}
+	if games := history.Games("cup", 0, now.UnixMilli()); games[0].Ranking[0].Rank != 2 || len(games[0].Aborted) != 1 {
+		t.Errorf("其他玩家的结算不应改变: %+v", games[0])
+	}
+
+	if ledger.Len() != 1 || ledger.Consume(101, 3, "other", now) != nil {
+		t.Error("用户的成绩登记应已删除")
+	}
+	if ledger.Consume(201, 3, "other", now) == nil {
+		t.Error("其他用户的成绩登记应保留")
+	}
+}
+
+// TestRetentionPrune 测试按保留期限清理对局历史、已使用成绩与封禁备注
+func TestRetentionPrune(t *testing.T) {
+	now := time.Now()
+	old := now.Add(-48 * time.Hour)
+
+	history := server.NewGameHistory(filepath.Join(t.TempDir(), "game_history.json"), 8)
+	history.Add("cup", &server.GameSummary{Ranking: []server.RankEntry{{Rank: 1, UserID: 1}}, EndedAt: old.UnixMilli()})
+	history.Add("cup", &server.GameSummary{Ranking: []server.RankEntry{{Rank: 1, UserID: 1}}, EndedAt: now.UnixMilli()})
+	if n := history.Prune(now.Add(-24 * time.Hour).UnixMilli()); n != 1 || history.Len() != 1 {
+		t.Errorf("应清理1局过期对局，实际清理 %d 剩余 %d", n, history.Len())
+	}
+
+	ledger := server.NewRecordLedger(filepath.Join(t.TempDir(), "used_records.json"), 8)
+	ledger.Consume(1, 1, "cup", old)
+	ledger.Consume(2, 1, "cup", now)
+	if n := ledger.Prune(now.Add(-24 * time.Hour)); n != 1 || ledger.Len() != 1 {
+		t.Errorf("应清理1条过期成绩登记，实际清理 %d 剩余 %d", n, ledger.Len())
+	}
+
+	adminData := server.NewAdminData()
+	adminData.BanUserWithReason(1, "作弊", 0)
+	adminData.BanUserFromRoomWithReason(1, "cup", "刷屏", 0)
+	adminData.BanUserWithReason(2, "辱骂", 0)
+	if n := adminData.PruneNotes(now.Add(-time.Hour)); n != 0 {
+		t.Errorf("未到期的封禁备注不应清理，实际清理 %d", n)
+	}
+	if n := adminData.ClearNotes(1); n != 2 {
+		t.Errorf("应清空用户1的2条封禁备注，实际 %d", n)
+	}
+	if !adminData.IsUserBanned(1) || !adminData.IsUserBannedFromRoom(1, "cup") {
+		t.Error("清空备注后封禁应保留")
+	}
+	if n := adminData.PruneNotes(now.Add(time.Hour)); n != 1 {
+		t.Errorf("应清理1条到期的封禁备注，实际 %d", n)
+	}
+	for _, ban := range adminData.UserBanList() {
+		if ban.Reason != "" {
+			t.Errorf("封禁备注应已清空: %+v", ban)
+		}
+	}
+}
+
+// TestContestResultPurge 测试清除用户与按保留期限清理导出的比赛结果
+func TestContestResultPurge(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	old := now.Add(-48 * time.Hour)
+	write := func(name string, result server.ContestResult) string {
+		data, _ := json.Marshal(result)
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	shared := write("contest-cup-1.json", server.ContestResult{RoomID: "cup", Whitelist: []int32{1, 2}, Summary: &server.GameSummary{
+		Ranking: []server.RankEntry{{Rank: 1, UserID: 1}, {Rank: 2, UserID: 2}},
+		Teams:   []server.TeamEntry{{Rank: 1, Team: 1, Players: []int32{1, 2}}},
+		EndedAt: now.UnixMilli(),
+	}})
+	solo := write("contest-cup-2.json", server.ContestResult{RoomID: "cup", Whitelist: []int32{1}, Summary: &server.GameSummary{
+		Ranking: []server.RankEntry{{Rank: 1, UserID: 1}}, EndedAt: now.UnixMilli(),
+	}})
+	expired := write("contest-cup-3.json", server.ContestResult{RoomID: "cup", Whitelist: []int32{2}, Summary: &server.GameSummary{
+		Ranking: []server.RankEntry{{Rank: 1, UserID: 2}}, EndedAt: old.UnixMilli(),
+	}})
+
+	ts := startTestServer(t, server.ServerConfig{ContestResultDir: dir})
+	result, err := ts.PurgeUser(1)
+	if err != nil {
+		t.Fatalf("清除失败: %v", err)
+	}
+	if result.ContestResults != 2 {
+		t.Errorf("应涉及2个比赛结果，实际 %d", result.ContestResults)
+	}
+	if _, err := os.Stat(solo); !os.IsNotExist(err) {
+		t.Error("只有该用户成绩的比赛结果应整个删除")
+	}
+	data, _ := os.ReadFile(shared)
+	var kept server.ContestResult
+	if err := json.Unmarshal(data, &kept); err != nil {
+		t.Fatalf("读取比赛结果失败: %v", err)
+	}
+	if len(kept.Whitelist) != 1 || len(kept.Summary.Ranking) != 1 || kept.Summary.Ranking[0].Rank != 2 || len(kept.Summary.Teams[0].Players) != 1 {
+		t.Errorf("应只移除该用户，其他玩家名次不变: %s", data)
+	}
+
+	if n := server.PruneContestResults(dir, now.Add(-24*time.Hour)); n != 1 {
+		t.Errorf("应清理1个过期比赛结果，实际 %d", n)
+	}
+	if _, err := os.Stat(expired); !os.IsNotExist(err) {
+		t.Error("过期的比赛结果应被删除")
+	}
+	if _, err := os.Stat(shared); err != nil {
+		t.Error("未过期的比赛结果应保留")
+	}
+}
+
+// TestPurgeUserObjectStorageFailure 测试对象存储中的回放副本删除失败时清除返回错误并保留索引条目，重试后删除
+func TestPurgeUserObjectStorageFailure(t *testing.T) {
+	var failing atomic.Bool
+	failing.Store(true)
+	var deleted atomic.Int32
+	s3 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			if failing.Load() {
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			deleted.Add(1)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer s3.Close()
+
+	dir := t.TempDir()
+	indexPath := server.ReplayIndexPath
+	server.ReplayIndexPath = filepath.Join(dir, "index.json")
+	t.Cleanup(func() { server.ReplayIndexPath = indexPath })
+
+	config := server.DefaultConfig()
+	config.HTTPService = true
+	config.AdminDataPath = filepath.Join(dir, "admin_data.json")
+	config.ActivityStatsPath = filepath.Join(dir, "activity_stats.json")
+	config.RecordLedgerPath = filepath.Join(dir, "used_records.json")
+	config.GameHistoryPath = filepath.Join(dir, "game_history.json")
+	config.ReplayStorage = server.ReplayStorageConfig{Endpoint: s3.URL, Bucket: "replays", AccessKey: "AK", SecretKey: "SK", PathStyle: true}
+	srv := server.NewServer(config)
+
+	local := filepath.Join(dir, "1730000000000.phirarec")
+	writeReplayFile(t, local, 1, 1, 0)
+	index := srv.GetReplayRecorder().GetReplayIndex()
+	index.Put(&server.ReplayEntry{UserID: 1, ChartID: 1, Timestamp: time.Now().UnixMilli(), Path: local, ObjectKey: "1/1/1730000000000.phirarec"})
+
+	result, err := srv.PurgeUser(1)
+	if err == nil {
+		t.Fatal("对象存储删除失败时清除应返回错误")
+	}
+	if result.Replays != 0 || len(index.ListUser(1)) != 1 {
+		t.Errorf("删除失败的回放不应计为已清除，且应保留索引条目以便重试: %+v", result)
+	}
+
+	failing.Store(false)
+	result, err = srv.PurgeUser(1)
+	if err != nil {
+		t.Fatalf("重试清除失败: %v", err)
+	}
+	if result.Replays != 1 || len(index.ListUser(1)) != 0 || deleted.Load() != 1 {
+		t.Errorf("重试后应删除对象存储中的副本: %+v", result)
+	}
+}
@@ -0,0 +1,88 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"phira-mp/common"
+	"phira-mp/server"
+)
+
+// TestDesyncResync 测试开启命令确认后，不回复 Ack 的客户端被重新下发房间状态，自动确认的客户端不受影响
+func TestDesyncResync(t *testing.T) {
+	config := server.DefaultConfig()
+	config.AckDelivery = true
+	config.DesyncTimeout = 1
+	ts := startTestServer(t, config)
+	useFakeChartAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": 1, "name": "Sync"})
+	})
+
+	host := ts.connect(t, 1)
+	roomID, _ := common.NewRoomId("desync")
+	host.CreateRoom(roomID)
+	waitFor(t, "创建房间", func() bool { return ts.GetRoom(roomID) != nil })
+
+	// 不回复 Ack 的客户端
+	conn, err := net.Dial("tcp", ts.addr)
+	if err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	stuck, err := common.NewNegotiatedClientStream(conn, common.SupportedProtocols, 0)
+	if err != nil {
+		t.Fatalf("握手失败: %v", err)
+	}
+	t.Cleanup(stuck.Close)
+	var (
+		mu      sync.Mutex
+		states  int    // 收到的带确认序号的 ChangeState 数量
+		lastSeq uint64 // 收到的最大确认序号
+	)
+	go func() {
+		for {
+			cmd, err := stuck.Recv()
+			if err != nil {
+				return
+			}
+			if seq, ok := cmd.Extensions.Uleb(common.ExtAckSeq); ok {
+				mu.Lock()
+				lastSeq = seq
+				if cmd.Type == common.ServerCmdChangeState {
+					states++
+				}
+				mu.Unlock()
+			}
+		}
+	}()
+	stuck.Send(common.ClientCommand{Type: common.ClientCmdAuthenticate, Token: fmt.Sprintf("%d-2", tokenSeq.Add(1))})
+	stuck.Send(common.ClientCommand{Type: common.ClientCmdJoinRoom, RoomId: roomID})
+	waitFor(t, "加入房间", func() bool { return ts.GetUser(2) != nil && ts.GetUser(2).GetRoom() != nil })
+
+	host.SelectChart(1)
+	received := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return states
+	}
+	// 重新同步依次下发 ChangeState 与 ChangeHost
+	waitFor(t, "重新同步", func() bool { return received() >= 2 && ts.DesyncCount() > 0 })
+	time.Sleep(100 * time.Millisecond)
+	if n := ts.DesyncCount(); n != 1 {
+		t.Errorf("只有未确认的客户端应被重新同步，实际 %d 次", n)
+	}
+
+	// 确认重新下发的状态后不再重新同步
+	mu.Lock()
+	latest := lastSeq
+	mu.Unlock()
+	stuck.Send(common.ClientCommand{Type: common.ClientCmdAck, Seq: latest})
+	time.Sleep(1500 * time.Millisecond)
+	if n := ts.DesyncCount(); n != 1 {
+		t.Errorf("确认后不应再重新同步，实际 %d 次", n)
+	}
+}
@@ -0,0 +1,122 @@
+package test
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"phira-mp/common"
+	"phira-mp/server"
+)
+
+// TestDisconnectReason 测试断开连接前客户端收到带原因的 Disconnect 通知，服务器按原因计数并记录到用户
+func TestDisconnectReason(t *testing.T) {
+	config := server.DefaultConfig()
+	config.AuthTimeout = 1
+	ts := startTestServer(t, config)
+
+	c := ts.connect(t, 1)
+	ts.GetUser(1).GetSession().Disconnect(common.DisconnectReasonKicked, "违反规则")
+	waitFor(t, "收到断开连接通知", func() bool { return c.Disconnected() != nil })
+	if notice := c.Disconnected(); notice.Reason != common.DisconnectReasonKicked || notice.Message != "违反规则" {
+		t.Errorf("断开连接通知不正确: %+v", notice)
+	}
+	waitFor(t, "记录断开原因", func() bool { return ts.GetUser(1).LastDisconnect() != nil })
+	if record := ts.GetUser(1).LastDisconnect(); record.Reason != "kicked" || record.Message != "违反规则" {
+		t.Errorf("用户最近一次断开原因不正确: %+v", record)
+	}
+
+	// 认证超时：未认证的会话同样收到通知并计数
+	conn, err := net.Dial("tcp", ts.addr)
+	if err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	stream, err := common.NewNegotiatedClientStream(conn, common.SupportedProtocols, 0)
+	if err != nil {
+		t.Fatalf("握手失败: %v", err)
+	}
+	defer stream.Close()
+	notice := make(chan *common.Disconnect, 1)
+	go func() {
+		for {
+			cmd, err := stream.Recv()
+			if err != nil {
+				return
+			}
+			if cmd.Type == common.ServerCmdDisconnect {
+				notice <- cmd.Disconnect
+				return
+			}
+		}
+	}()
+	select {
+	case d := <-notice:
+		if d.Reason != common.DisconnectReasonAuthTimeout {
+			t.Errorf("认证超时的断开原因不正确: %v", d.Reason)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("等待认证超时通知超时")
+	}
+
+	waitFor(t, "按原因计数", func() bool {
+		counts := ts.DisconnectCounts()
+		return counts["kicked"] == 1 && counts["auth_timeout"] == 1
+	})
+	if n := ts.GetStats()["disconnects"].(map[string]int64)["kicked"]; n != 1 {
+		t.Errorf("统计信息中的断开次数不正确: %d", n)
+	}
+}
+
+// TestSlowConsumerDisconnect 测试房间广播不等待读取过慢的成员，发送队列已满的成员被断开
+func TestSlowConsumerDisconnect(t *testing.T) {
+	ts := startTestServer(t, server.DefaultConfig())
+	host := ts.connect(t, 1)
+	roomID, _ := common.NewRoomId("slow")
+	host.CreateRoom(roomID)
+	waitFor(t, "创建房间", func() bool { return ts.GetRoom(roomID) != nil })
+
+	// 加入房间后不再读取的客户端
+	conn, err := net.Dial("tcp", ts.addr)
+	if err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	stuck, err := common.NewNegotiatedClientStream(conn, common.SupportedProtocols, 0)
+	if err != nil {
+		t.Fatalf("握手失败: %v", err)
+	}
+	t.Cleanup(stuck.Close)
+	stuck.Send(common.ClientCommand{Type: common.ClientCmdAuthenticate, Token: fmt.Sprintf("%d-2", tokenSeq.Add(1))})
+	stuck.Send(common.ClientCommand{Type: common.ClientCmdJoinRoom, RoomId: roomID})
+	waitFor(t, "加入房间", func() bool { return ts.GetUser(2) != nil && ts.GetUser(2).GetRoom() != nil })
+
+	room := ts.GetRoom(roomID)
+	content := strings.Repeat("x", 4096)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		deadline := time.Now().Add(5 * time.Second)
+		for ts.DisconnectCounts()["slow_consumer"] == 0 && time.Now().Before(deadline) {
+			room.Broadcast(common.ServerCommand{
+				Type:    common.ServerCmdMessage,
+				Message: &common.Message{Type: common.MsgChat, User: 1, Content: content},
+			})
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("广播被读取过慢的成员阻塞")
+	}
+	if ts.DisconnectCounts()["slow_consumer"] == 0 {
+		t.Fatal("发送队列已满的成员应被断开")
+	}
+	waitFor(t, "记录断开原因", func() bool { return ts.GetUser(2).LastDisconnect() != nil })
+	if record := ts.GetUser(2).LastDisconnect(); record.Reason != "slow_consumer" {
+		t.Errorf("断开原因不正确: %+v", record)
+	}
+	if ts.GetUser(1).GetSession() == nil || room.GetHost().ID != 1 {
+		t.Error("正常读取的成员不应受影响")
+	}
+}
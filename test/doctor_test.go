@@ -0,0 +1,103 @@
+package test
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"phira-mp/server"
+)
+
+// TestValidateConfig 测试配置项检查
+func TestValidateConfig(t *testing.T) {
+	config := server.DefaultConfig()
+	if problems := server.ValidateConfig(config); len(problems) != 0 {
+		t.Fatalf("默认配置不应有问题: %v", problems)
+	}
+
+	config.Port = 70000
+	config.LogLevel = "verbose"
+	config.HostLeavePolicy = "wait"
+	config.RoomTemplates = []server.RoomTemplate{
+		{ID: "official", ChartPolicy: server.ChartPolicyFixed},
+		{ID: "official"},
+		{ID: "ranked", MinDifficulty: 14, MaxDifficulty: 12},
+	}
+	config.ReplayEncryption.Key = "c2hvcnQ="
+	config.AdminOIDC = server.OIDCConfig{Issuer: "https://sso.example.com", AllowedGroups: []string{"admins"}}
+	problems := server.ValidateConfig(config)
+	for _, want := range []string{"port", "log_level", "host_leave_policy", "chart_id", "房间ID重复", "定数范围", "replay_encryption", "admin_oidc"} {
+		found := false
+		for _, p := range problems {
+			if strings.Contains(p, want) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("应报告 %s 问题，实际: %v", want, problems)
+		}
+	}
+}
+
+// TestRunDoctor 测试启动自检报告
+func TestRunDoctor(t *testing.T) {
+	// 自检会创建回放目录，切换到临时目录执行
+	wd, _ := os.Getwd()
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", time.Now().Add(-10*time.Minute).UTC().Format(http.TimeFormat))
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	// 占用游戏端口
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	config := server.DefaultConfig()
+	config.Host = "127.0.0.1"
+	config.Port = ln.Addr().(*net.TCPAddr).Port
+	config.PhiraAPI = server.PhiraAPIConfig{BaseURL: ts.URL, Timeout: 5}
+
+	report := server.RunDoctor(config, "missing.yml", nil)
+	status := make(map[string]server.DoctorStatus)
+	for _, c := range report.Checks {
+		status[c.Name] = c.Status
+	}
+
+	expect := map[string]server.DoctorStatus{
+		"配置文件":    server.DoctorWarn,
+		"配置项":     server.DoctorOK,
+		"游戏端口":    server.DoctorFail,
+		"回放目录":    server.DoctorOK,
+		"管理员数据":   server.DoctorOK,
+		"Phira主站": server.DoctorOK,
+		"系统时钟":    server.DoctorWarn,
+	}
+	for name, want := range expect {
+		if got, ok := status[name]; !ok || got != want {
+			t.Errorf("%s: 期望 %s, 实际 %s (存在: %v)", name, want, got, ok)
+		}
+	}
+	if !report.Failed() {
+		t.Error("端口被占用时自检应失败")
+	}
+
+	var out bytes.Buffer
+	report.Print(&out)
+	if !strings.Contains(out.String(), "[FAIL] 游戏端口") {
+		t.Errorf("报告缺少失败项:\n%s", out.String())
+	}
+}
@@ -0,0 +1,81 @@
+package test
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"phira-mp/common"
+	"phira-mp/server"
+)
+
+// TestEmote 测试表情广播给房间成员，并校验允许列表与发送间隔
+func TestEmote(t *testing.T) {
+	ts := startTestServer(t, server.DefaultConfig())
+
+	host := ts.connect(t, 1)
+	roomID, _ := common.NewRoomId("emote")
+	host.CreateRoom(roomID)
+	waitFor(t, "创建房间", func() bool { return ts.GetRoom(roomID) != nil })
+
+	conn, err := net.Dial("tcp", ts.addr)
+	if err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	player, err := common.NewNegotiatedClientStream(conn, common.SupportedProtocols, 0)
+	if err != nil {
+		t.Fatalf("握手失败: %v", err)
+	}
+	t.Cleanup(player.Close)
+	results := make(chan *common.Result[struct{}], 8)
+	go func() {
+		for {
+			cmd, err := player.Recv()
+			if err != nil {
+				return
+			}
+			if cmd.Type == common.ServerCmdEmote {
+				results <- cmd.EmoteResult
+			}
+		}
+	}()
+	player.Send(common.ClientCommand{Type: common.ClientCmdAuthenticate, Token: fmt.Sprintf("%d-2", tokenSeq.Add(1))})
+	player.Send(common.ClientCommand{Type: common.ClientCmdJoinRoom, RoomId: roomID})
+	waitFor(t, "加入房间", func() bool { return ts.GetUser(2) != nil && ts.GetUser(2).GetRoom() != nil })
+
+	emote := func(name string) *common.Result[struct{}] {
+		t.Helper()
+		player.Send(common.ClientCommand{Type: common.ClientCmdEmote, Name: name})
+		select {
+		case result := <-results:
+			return result
+		case <-time.After(3 * time.Second):
+			t.Fatalf("等待表情 %q 的结果超时", name)
+			return nil
+		}
+	}
+
+	if result := emote("gg"); result.Ok == nil {
+		t.Fatalf("发送表情失败: %s", *result.Err)
+	}
+	var got []common.Message
+	waitFor(t, "收到表情", func() bool {
+		for _, msg := range host.TakeMessages() {
+			if msg.Type == common.MsgEmote {
+				got = append(got, msg)
+			}
+		}
+		return len(got) > 0
+	})
+	if got[0].User != 2 || got[0].Content != "gg" {
+		t.Errorf("表情内容不匹配: %+v", got[0])
+	}
+
+	if result := emote("not_an_emote"); result.Err == nil || result.ErrorCode() != common.ErrCodeEmoteNotAllowed {
+		t.Errorf("不在允许列表中的表情应被拒绝: %+v", result)
+	}
+	if result := emote("clap"); result.Err == nil || result.ErrorCode() != common.ErrCodeRateLimited {
+		t.Errorf("间隔内再次发送表情应被限流: %+v", result)
+	}
+}
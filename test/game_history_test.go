@@ -0,0 +1,90 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"phira-mp/server"
+)
+
+// TestGameHistoryLeaderboard 测试按房间ID与时间范围汇总累计排行
+func TestGameHistoryLeaderboard(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "game_history.json")
+	history := server.NewGameHistory(path, 4)
+
+	game := func(endedAt int64, ranking ...server.RankEntry) *server.GameSummary {
+		return &server.GameSummary{Aggregator: server.AggregatorScore, Ranking: ranking, EndedAt: endedAt}
+	}
+	history.Add("cup", game(1000,
+		server.RankEntry{Rank: 1, UserID: 1, Name: "A", Points: 900, Score: 900},
+		server.RankEntry{Rank: 2, UserID: 2, Name: "B", Points: 800, Score: 800}))
+	history.Add("other", game(1500,
+		server.RankEntry{Rank: 1, UserID: 2, Name: "B", Points: 5000, Score: 5000}))
+	history.Add("cup", game(2000,
+		server.RankEntry{Rank: 1, UserID: 2, Name: "B2", Points: 1000, Score: 1000},
+		server.RankEntry{Rank: 2, UserID: 1, Name: "A", Points: 900, Score: 900}))
+	history.Add("cup", game(3000,
+		server.RankEntry{Rank: 1, UserID: 3, Name: "C", Points: 700, Score: 700}))
+
+	board := history.Leaderboard("cup", 0, 2500)
+	if board.Games != 2 || len(board.Players) != 2 {
+		t.Fatalf("应只统计时间范围内该房间的2局: %+v", board)
+	}
+	// 两名玩家累计分数相同，并列第一并按用户ID排序
+	first, second := board.Players[0], board.Players[1]
+	if first.UserID != 1 || first.Points != 1800 || first.Games != 2 || first.Wins != 1 || first.BestScore != 900 {
+		t.Errorf("第一名统计不正确: %+v", first)
+	}
+	if second.UserID != 2 || second.Points != 1800 || second.Games != 2 || second.Wins != 1 || second.TotalScore != 1800 {
+		t.Errorf("第二名统计不正确: %+v", second)
+	}
+	if first.Rank != 1 || second.Rank != 1 || second.Name != "B2" || second.LastPlayedAt != 2000 {
+		t.Errorf("累计分数相同应并列，名称取最近一局: %+v", second)
+	}
+
+	if board := history.Leaderboard("cup", 2500, 5000); board.Games != 1 || board.Players[0].UserID != 3 {
+		t.Errorf("时间范围过滤不正确: %+v", board)
+	}
+
+	// 超出容量时淘汰最早的对局
+	history.Add("cup", game(4000, server.RankEntry{Rank: 1, UserID: 1, Name: "A", Points: 1, Score: 1}))
+	if history.Len() != 4 || history.Leaderboard("cup", 0, 1000).Games != 0 {
+		t.Errorf("超出容量时应淘汰最早的对局，当前 %d 局", history.Len())
+	}
+
+	// 保存后重新加载
+	if err := history.Save(); err != nil {
+		t.Fatalf("保存失败: %v", err)
+	}
+	loaded := server.NewGameHistory(path, 4)
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("加载失败: %v", err)
+	}
+	if loaded.Len() != 4 || loaded.Leaderboard("cup", 0, 5000).Games != 3 {
+		t.Errorf("加载后的对局历史不正确: %d 局", loaded.Len())
+	}
+}
+
+// TestGameHistorySaveRetry 测试保存失败后保留未保存的修改，下次保存时重试
+func TestGameHistorySaveRetry(t *testing.T) {
+	blocker := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(blocker, "game_history.json")
+	history := server.NewGameHistory(path, 4)
+	history.Add("cup", &server.GameSummary{Ranking: []server.RankEntry{{Rank: 1, UserID: 1}}, EndedAt: 1000})
+	if err := history.Save(); err == nil {
+		t.Fatal("目录无法创建时保存应失败")
+	}
+
+	os.Remove(blocker)
+	if err := history.Save(); err != nil {
+		t.Fatalf("重试保存失败: %v", err)
+	}
+	loaded := server.NewGameHistory(path, 4)
+	if err := loaded.Load(); err != nil || loaded.Len() != 1 {
+		t.Errorf("保存失败的修改应在重试时写入: %d 局 %v", loaded.Len(), err)
+	}
+}
@@ -1,8 +1,13 @@
 package test
 
 import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
 	"sync"
 	"testing"
+	"time"
 
 	"phira-mp/common"
 	"phira-mp/server"
@@ -625,8 +630,8 @@ func TestRoomBroadcastDuringGame(t *testing.T) {
 	room.Broadcast(common.ServerCommand{
 		Type: common.ServerCmdMessage,
 		Message: &common.Message{
-			Type:    common.MsgGameStart,
-			User:    host.ID,
+			Type: common.MsgGameStart,
+			User: host.ID,
 		},
 	})
 }
@@ -679,4 +684,141 @@ func TestConcurrentRoomOperations(t *testing.T) {
 	if len(users) < 2 {
 		t.Error("并发添加用户失败")
 	}
-}
\ No newline at end of file
+}
+
+// TestChartLoadingProgress 测试谱面加载进度记录
+func TestChartLoadingProgress(t *testing.T) {
+	config := server.DefaultConfig()
+	config.ChartLoadTimeout = 10
+	srv := server.NewServer(config)
+
+	host := server.NewUser(1, "Host", "zh-CN", srv)
+	roomID, _ := common.NewRoomId("loading-room")
+	room := server.NewRoom(roomID, host, srv)
+
+	if room.GetLoadProgress(host.ID) != 0 {
+		t.Error("未上报时加载进度应为0")
+	}
+	room.SetLoadProgress(host.ID, 150)
+	if room.GetLoadProgress(host.ID) != 100 {
+		t.Errorf("加载进度应被限制为100，实际: %d", room.GetLoadProgress(host.ID))
+	}
+
+	// 加载阶段对客户端表现为等待准备
+	state := server.InternalStateLoading.ToClientState(nil)
+	if state.Type != common.RoomStateWaitingForReady {
+		t.Errorf("加载阶段的客户端状态应为等待准备，实际: %d", state.Type)
+	}
+}
+
+// TestChartLoadingLegacyClient 测试谱面加载阶段原版客户端视为已加载，超时只将未加载完成的扩展协议玩家标记为放弃
+func TestChartLoadingLegacyClient(t *testing.T) {
+	config := server.DefaultConfig()
+	config.ChartLoadTimeout = 1
+	ts := startTestServer(t, config)
+	useFakeChartAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": 1, "name": "Loading"})
+	})
+
+	host := ts.connect(t, 1)
+	roomID, _ := common.NewRoomId("loading-legacy")
+	host.CreateRoom(roomID)
+	waitFor(t, "创建房间", func() bool { return ts.GetRoom(roomID) != nil })
+	room := ts.GetRoom(roomID)
+
+	// 原版客户端（V1）
+	conn, err := net.Dial("tcp", ts.addr)
+	if err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	legacy, err := common.NewClientStream(conn, 1)
+	if err != nil {
+		t.Fatalf("握手失败: %v", err)
+	}
+	t.Cleanup(legacy.Close)
+	var (
+		mu      sync.Mutex
+		aborted []int32
+	)
+	go func() {
+		for {
+			cmd, err := legacy.Recv()
+			if err != nil {
+				return
+			}
+			if cmd.Type == common.ServerCmdMessage && cmd.Message.Type == common.MsgAbort {
+				mu.Lock()
+				aborted = append(aborted, cmd.Message.User)
+				mu.Unlock()
+			}
+		}
+	}()
+	legacy.Send(common.ClientCommand{Type: common.ClientCmdAuthenticate, Token: fmt.Sprintf("%d-2", tokenSeq.Add(1))})
+	legacy.Send(common.ClientCommand{Type: common.ClientCmdJoinRoom, RoomId: roomID})
+	waitFor(t, "原版客户端加入房间", func() bool { return len(room.GetUsers()) == 2 })
+
+	host.SelectChart(1)
+	waitFor(t, "选择谱面", func() bool { return room.GetChart() != nil })
+	host.RequestStart()
+	waitFor(t, "等待准备", func() bool { return room.GetState() == server.InternalStateWaitForReady })
+	legacy.Send(common.ClientCommand{Type: common.ClientCmdReady})
+	waitFor(t, "进入加载阶段", func() bool { return room.GetState() == server.InternalStateLoading })
+	if room.GetLoadProgress(2) != 100 || room.GetLoadProgress(1) != 0 {
+		t.Errorf("原版客户端应视为已加载: host=%d legacy=%d", room.GetLoadProgress(1), room.GetLoadProgress(2))
+	}
+
+	// 房主不上报进度，超时后只有房主被标记为放弃
+	waitFor(t, "加载超时后开始游戏", func() bool { return room.GetState() != server.InternalStateLoading })
+	waitFor(t, "收到放弃消息", func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(aborted) > 0
+	})
+	time.Sleep(200 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if len(aborted) != 1 || aborted[0] != 1 {
+		t.Errorf("只有未上报加载进度的扩展协议玩家应被标记为放弃: %v", aborted)
+	}
+}
+
+// TestJudgeStats 测试判定流统计
+func TestJudgeStats(t *testing.T) {
+	stats := &server.JudgeStats{}
+	stats.Add([]common.JudgeEvent{
+		{Judgement: common.JudgementPerfect},
+		{Judgement: common.JudgementGood},
+		{Judgement: common.JudgementHoldPerfect},
+		{Judgement: common.JudgementMiss},
+		{Judgement: common.JudgementPerfect},
+		{Judgement: common.JudgementBad},
+	})
+
+	perfect, good, bad, miss, maxCombo := stats.Snapshot()
+	if perfect != 3 || good != 1 || bad != 1 || miss != 1 {
+		t.Errorf("判定统计不匹配: perfect=%d good=%d bad=%d miss=%d", perfect, good, bad, miss)
+	}
+	if maxCombo != 3 {
+		t.Errorf("最大连击不匹配，期望: 3, 实际: %d", maxCombo)
+	}
+}
+
+// TestJudgeStatsExtended 测试扩展判定按成绩分类统计，未知判定不计入成绩也不中断连击
+func TestJudgeStatsExtended(t *testing.T) {
+	stats := &server.JudgeStats{}
+	stats.Add([]common.JudgeEvent{
+		{Judgement: common.JudgementFlickPerfect},
+		{Judgement: common.JudgementGood | common.JudgementEarly},
+		{Judgement: 0x3f},
+		{Judgement: common.JudgementPerfect | common.JudgementLate},
+		{Judgement: common.JudgementFlickMiss | common.JudgementLate},
+	})
+
+	perfect, good, bad, miss, maxCombo := stats.Snapshot()
+	if perfect != 2 || good != 1 || bad != 0 || miss != 1 || maxCombo != 3 {
+		t.Errorf("判定统计不匹配: perfect=%d good=%d bad=%d miss=%d maxCombo=%d", perfect, good, bad, miss, maxCombo)
+	}
+	if early, late, unknown := stats.Timing(); early != 1 || late != 2 || unknown != 1 {
+		t.Errorf("早晚与未知判定统计不匹配: early=%d late=%d unknown=%d", early, late, unknown)
+	}
+}
@@ -0,0 +1,118 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"phira-mp/client"
+	"phira-mp/server"
+)
+
+// tokenSeq 保证每个测试使用不同的token，避免命中认证缓存
+var tokenSeq atomic.Int64
+
+// testServer 在本机随机端口上运行的完整服务器，认证请求由模拟的Phira主站处理
+type testServer struct {
+	*server.Server
+	addr string
+}
+
+// startTestServer 启动服务器，模拟主站的 /me 按token中的用户ID返回用户信息（token格式: <序号>-<用户ID>）
+func startTestServer(t *testing.T, config server.ServerConfig) *testServer {
+	t.Helper()
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/me" {
+			http.NotFound(w, r)
+			return
+		}
+		serveFakeMe(w, r)
+	}))
+	t.Cleanup(api.Close)
+
+	dir := t.TempDir()
+	config.PhiraAPI = server.PhiraAPIConfig{BaseURL: api.URL, Timeout: 5}
+	config.ActivityStatsPath = filepath.Join(dir, "activity_stats.json")
+	config.RecordLedgerPath = filepath.Join(dir, "used_records.json")
+	config.GameHistoryPath = filepath.Join(dir, "game_history.json")
+	config.HTTPService = false
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := ln.Addr().String()
+	ln.Close()
+
+	srv := server.NewServer(config)
+	go srv.Start(addr)
+	t.Cleanup(func() {
+		srv.Stop()
+		server.ConfigurePhiraAPI(server.DefaultPhiraAPIConfig())
+	})
+
+	select {
+	case <-srv.Ready():
+	case <-time.After(3 * time.Second):
+		t.Fatal("等待服务器启动超时")
+	}
+	return &testServer{Server: srv, addr: addr}
+}
+
+// serveFakeMe 模拟主站的 /me：按token中的用户ID返回用户信息
+func serveFakeMe(w http.ResponseWriter, r *http.Request) {
+	var seq, id int32
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if _, err := fmt.Sscanf(token, "%d-%d", &seq, &id); err != nil {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"id":       id,
+		"name":     fmt.Sprintf("player%d", id),
+		"language": "zh-CN",
+		"rks":      float64(id) / 10,
+	})
+}
+
+// connect 以指定用户ID连接并完成认证
+func (ts *testServer) connect(t *testing.T, id int32) *client.Client {
+	t.Helper()
+	return ts.connectWith(t, id, client.Options{})
+}
+
+// connectWith 按客户端选项连接并完成认证
+func (ts *testServer) connectWith(t *testing.T, id int32, opts client.Options) *client.Client {
+	t.Helper()
+	c, err := client.NewClientWithOptions(ts.addr, opts)
+	if err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	t.Cleanup(c.Close)
+
+	if err := c.Authenticate(fmt.Sprintf("%d-%d", tokenSeq.Add(1), id)); err != nil {
+		t.Fatalf("认证失败: %v", err)
+	}
+	waitFor(t, "认证完成", func() bool { return c.Me() != nil })
+	return c
+}
+
+// waitFor 轮询等待条件成立
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(3 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("等待%s超时", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
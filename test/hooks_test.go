@@ -0,0 +1,117 @@
+package test
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"phira-mp/common"
+	"phira-mp/server"
+)
+
+// testRules 测试用钩子：拒绝用户3加入房间 hooks、只允许用户5观战，拒绝定数16以上的谱面，并统计对局结束次数
+type testRules struct {
+	gameEnds atomic.Int32
+}
+
+func (*testRules) Name() string { return "test-rules" }
+
+func (*testRules) OnJoin(room *server.Room, user *server.User, monitor bool) error {
+	if user.ID == 3 && room.ID.Value == "hooks" {
+		return errors.New("不允许加入")
+	}
+	if user.ID == 5 && !monitor {
+		return errors.New("只允许观战")
+	}
+	return nil
+}
+
+func (*testRules) OnSelectChart(room *server.Room, user *server.User, chart *server.Chart) error {
+	if chart.Difficulty > 16 {
+		room.SendSystemChat("谱面定数过高")
+		return errors.New("谱面定数过高")
+	}
+	return nil
+}
+
+func (h *testRules) OnGameEnd(room *server.Room, summary *server.GameSummary) {
+	h.gameEnds.Add(1)
+}
+
+// TestHooks 测试事件钩子可以拒绝加入与选谱，并在对局结束时被调用
+func TestHooks(t *testing.T) {
+	rules := &testRules{}
+	if err := server.RegisterHook(rules); err != nil {
+		t.Fatalf("注册钩子失败: %v", err)
+	}
+	t.Cleanup(func() { server.UnregisterHook(rules.Name()) })
+	if err := server.RegisterHook(rules); err == nil {
+		t.Error("重复注册同名钩子应失败")
+	}
+
+	config := server.DefaultConfig()
+	config.Bots = true
+	config.LiveMode = true
+	config.Monitors = []int32{5}
+	ts := startTestServer(t, config)
+	useFakeChartAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		id, _ := strconv.Atoi(path.Base(r.URL.Path))
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": id, "name": "Hook", "difficulty": id})
+	})
+
+	host := ts.connect(t, 1)
+	roomID, _ := common.NewRoomId("hooks")
+	host.CreateRoom(roomID)
+	waitFor(t, "房间创建", func() bool { return ts.GetRoom(roomID) != nil })
+	room := ts.GetRoom(roomID)
+
+	// 被拒绝的玩家随后加入其他房间，确认拒绝已处理
+	other, _ := common.NewRoomId("hooks-other")
+	ts.connect(t, 4).CreateRoom(other)
+	waitFor(t, "房间创建", func() bool { return ts.GetRoom(other) != nil })
+	denied := ts.connect(t, 3)
+	denied.JoinRoom(roomID, false)
+	denied.JoinRoom(other, false)
+	waitFor(t, "加入其他房间", func() bool { return ts.GetUser(3).GetRoom() != nil })
+	if ts.GetUser(3).GetRoom() == room {
+		t.Error("钩子拒绝的玩家不应加入房间")
+	}
+
+	// 观察者转为玩家同样经过钩子
+	viewer := ts.connect(t, 5)
+	viewer.JoinRoom(roomID, true)
+	waitFor(t, "以观察者身份加入", func() bool { return len(room.GetMonitors()) == 1 })
+	viewer.SwitchRole(false)
+	time.Sleep(200 * time.Millisecond)
+	if !ts.GetUser(5).IsMonitor() {
+		t.Error("钩子拒绝的观察者不应转为玩家")
+	}
+
+	host.SelectChart(12)
+	waitFor(t, "选择谱面", func() bool { return room.GetChart() != nil })
+	host.SelectChart(17)
+	waitFor(t, "钩子发送提示", func() bool {
+		for _, msg := range host.TakeMessages() {
+			if msg.Type == common.MsgChat && msg.User == 0 && msg.Content == "谱面定数过高" {
+				return true
+			}
+		}
+		return false
+	})
+	if chart := room.GetChart(); chart == nil || chart.ID != 12 {
+		t.Errorf("钩子拒绝的谱面不应被选择: %+v", chart)
+	}
+
+	if _, err := ts.SpawnBots(room, 1, 300*time.Millisecond); err != nil {
+		t.Fatalf("加入模拟玩家失败: %v", err)
+	}
+	host.RequestStart()
+	waitFor(t, "开始游戏", func() bool { return room.GetState() == server.InternalStatePlaying })
+	host.Abort()
+	waitFor(t, "对局结束钩子", func() bool { return rules.gameEnds.Load() == 1 })
+}
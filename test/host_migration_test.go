@@ -0,0 +1,111 @@
+package test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"phira-mp/common"
+	"phira-mp/server"
+)
+
+// newWaitForReadyRoom 创建处于等待准备阶段的房间（房主与玩家2已准备，玩家3按参数决定）
+func newWaitForReadyRoom(t *testing.T, policy string, player3Ready bool) (*server.Room, []*server.User) {
+	config := server.DefaultConfig()
+	config.HostLeavePolicy = policy
+	config.ActivityStatsPath = filepath.Join(t.TempDir(), "activity_stats.json")
+	srv := server.NewServer(config)
+
+	host := server.NewUser(1, "Host", "zh-CN", srv)
+	player2 := server.NewUser(2, "Player2", "zh-CN", srv)
+	player3 := server.NewUser(3, "Player3", "zh-CN", srv)
+
+	roomID, _ := common.NewRoomId("migrate-" + policy)
+	room := server.NewRoom(roomID, host, srv)
+	host.SetRoom(room)
+	for _, u := range []*server.User{player2, player3} {
+		room.AddUser(u, false)
+		u.SetRoom(room)
+	}
+	room.SetChart(&server.Chart{ID: 1, Name: "Test"})
+
+	room.SetState(server.InternalStateWaitForReady)
+	room.SetReady(host.ID, true)
+	room.SetReady(player2.ID, true)
+	room.SetReady(player3.ID, player3Ready)
+	return room, []*server.User{host, player2, player3}
+}
+
+// TestHostLeaveWaitForReadyCancel 测试 cancel 策略：回到选谱阶段
+func TestHostLeaveWaitForReadyCancel(t *testing.T) {
+	room, users := newWaitForReadyRoom(t, server.HostLeaveCancel, true)
+
+	room.OnUserLeave(users[0])
+
+	if room.GetState() != server.InternalStateSelectChart {
+		t.Errorf("状态应该回到选谱，实际 %v", room.GetState())
+	}
+	if room.GetHost().ID == users[0].ID {
+		t.Error("离开的房主不应该继续担任房主")
+	}
+	if len(room.GetReadyUsers()) != 0 {
+		t.Error("取消后应该清空准备状态")
+	}
+}
+
+// TestHostLeaveWaitForReadyTransfer 测试 transfer 策略：移交房主并保留准备状态
+func TestHostLeaveWaitForReadyTransfer(t *testing.T) {
+	room, users := newWaitForReadyRoom(t, server.HostLeaveTransfer, false)
+
+	room.OnUserLeave(users[0])
+
+	if room.GetState() != server.InternalStateWaitForReady {
+		t.Fatalf("状态应该保持等待准备，实际 %v", room.GetState())
+	}
+	if room.GetHost().ID != users[1].ID {
+		t.Errorf("房主应该移交给已准备的玩家2，实际 %d", room.GetHost().ID)
+	}
+	if room.GetChart() == nil || room.GetChart().ID != 1 {
+		t.Error("应该保留已选谱面")
+	}
+
+	// 剩余玩家全部准备后照常开始
+	room.SetReady(users[2].ID, true)
+	room.CheckAllReady()
+	if room.GetState() != server.InternalStatePlaying {
+		t.Errorf("全员准备后应该开始游戏，实际 %v", room.GetState())
+	}
+}
+
+// TestHostLeaveWaitForReadyStart 测试 start 策略
+func TestHostLeaveWaitForReadyStart(t *testing.T) {
+	// 其余玩家均已准备：立即开始
+	room, users := newWaitForReadyRoom(t, server.HostLeaveStart, true)
+	room.OnUserLeave(users[0])
+	if room.GetState() != server.InternalStatePlaying {
+		t.Errorf("其余玩家均已准备时应该立即开始，实际 %v", room.GetState())
+	}
+	if room.GetHost().ID == users[0].ID {
+		t.Error("离开的房主不应该继续担任房主")
+	}
+
+	// 有玩家未准备：取消开始
+	room, users = newWaitForReadyRoom(t, server.HostLeaveStart, false)
+	room.OnUserLeave(users[0])
+	if room.GetState() != server.InternalStateSelectChart {
+		t.Errorf("有玩家未准备时应该回到选谱，实际 %v", room.GetState())
+	}
+}
+
+// TestHostDisconnectWaitForReady 测试房主在等待准备阶段断线时立即处理
+func TestHostDisconnectWaitForReady(t *testing.T) {
+	room, users := newWaitForReadyRoom(t, server.HostLeaveTransfer, false)
+
+	users[0].Dangle()
+
+	if room.GetHost().ID != users[1].ID {
+		t.Errorf("房主断线后应该立即移交给玩家2，实际 %d", room.GetHost().ID)
+	}
+	if users[0].GetRoom() != room {
+		t.Error("断线的原房主应该保留在房间中等待重连")
+	}
+}
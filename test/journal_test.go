@@ -0,0 +1,88 @@
+package test
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"phira-mp/client"
+	"phira-mp/common"
+	"phira-mp/server"
+)
+
+// TestClientJournal 测试客户端事件日志按环形缓冲保留最近的命令，并轮换写入文件
+func TestClientJournal(t *testing.T) {
+	ts := startTestServer(t, server.DefaultConfig())
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	c := ts.connectWith(t, 1, client.Options{Journal: 4, JournalFile: path})
+
+	entries := c.Journal()
+	if len(entries) == 0 || entries[0].Command.Type != common.ServerCmdAuthenticate {
+		t.Fatalf("事件日志应记录认证结果: %+v", entries)
+	}
+
+	roomID, _ := common.NewRoomId("journal")
+	c.CreateRoom(roomID)
+	waitFor(t, "创建房间", func() bool { return ts.GetRoom(roomID) != nil })
+	c.Chat("hi")
+	waitFor(t, "收到聊天", func() bool {
+		for _, msg := range c.TakeMessages() {
+			if msg.Type == common.MsgChat {
+				return true
+			}
+		}
+		return false
+	})
+	// 认证、创建房间、聊天的结果与消息共 6 条以上，缓冲只保留最近 4 条
+	entries = c.Journal()
+	if len(entries) != 4 || entries[0].Command.Type == common.ServerCmdAuthenticate {
+		t.Fatalf("事件日志应只保留最近 4 条: %+v", entries)
+	}
+	for i := 1; i < len(entries); i++ {
+		if entries[i].Time.Before(entries[i-1].Time) {
+			t.Errorf("事件日志应按接收顺序排列: %v 早于 %v", entries[i].Time, entries[i-1].Time)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := c.ExportJournal(&buf); err != nil {
+		t.Fatalf("导出事件日志失败: %v", err)
+	}
+	if lines := countJournalLines(t, &buf); lines != 4 {
+		t.Errorf("导出应有 4 条记录，实际 %d", lines)
+	}
+
+	if _, err := os.Stat(path + ".1"); err != nil {
+		t.Errorf("日志文件写满后应轮换: %v", err)
+	}
+	data, err := os.ReadFile(path + ".1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := countJournalLines(t, bytes.NewReader(data)); lines != 4 {
+		t.Errorf("轮换的日志文件应有 4 条记录，实际 %d", lines)
+	}
+
+	if plain := ts.connect(t, 2); plain.Journal() != nil {
+		t.Error("未开启事件日志时不应记录")
+	}
+}
+
+// countJournalLines 统计JSON Lines中的记录数，并检查每行都能解析
+func countJournalLines(t *testing.T, r interface{ Read([]byte) (int, error) }) int {
+	t.Helper()
+	n := 0
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var entry client.JournalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			t.Fatalf("无法解析事件日志: %v\n%s", err, scanner.Bytes())
+		}
+		n++
+	}
+	return n
+}
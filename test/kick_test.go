@@ -0,0 +1,69 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"phira-mp/common"
+	"phira-mp/server"
+)
+
+// TestKick 测试房主移出玩家，被移出的玩家收到带原因的移出通知，非房主不能移出
+func TestKick(t *testing.T) {
+	ts := startTestServer(t, server.DefaultConfig())
+
+	host := ts.connect(t, 1)
+	player := ts.connect(t, 2)
+	other := ts.connect(t, 3)
+	roomID, _ := common.NewRoomId("kick")
+	host.CreateRoom(roomID)
+	waitFor(t, "创建房间", func() bool { return ts.GetRoom(roomID) != nil })
+	player.JoinRoom(roomID, false)
+	other.JoinRoom(roomID, false)
+	waitFor(t, "加入房间", func() bool { return len(ts.GetRoom(roomID).GetUsers()) == 3 })
+
+	other.Kick(2)
+	host.Kick(1)
+	time.Sleep(200 * time.Millisecond)
+	if ts.GetUser(2).GetRoom() == nil || player.Kicked() != nil {
+		t.Fatal("移出失败时玩家不应离开房间")
+	}
+
+	if err := host.Kick(2); err != nil {
+		t.Fatalf("移出玩家失败: %v", err)
+	}
+	waitFor(t, "移出通知", func() bool { return player.Kicked() != nil })
+	kicked := player.Kicked()
+	if kicked.Reason != common.KickReasonHost || kicked.RoomId == nil || *kicked.RoomId != roomID {
+		t.Errorf("移出通知不匹配: %+v", kicked)
+	}
+	if player.RoomState() != nil {
+		t.Error("收到移出通知后客户端不应仍在房间内")
+	}
+	if ts.GetUser(2).GetRoom() != nil || len(ts.GetRoom(roomID).GetUsers()) != 2 {
+		t.Error("玩家应已被移出房间")
+	}
+}
+
+// TestKickedOnDisband 测试房间被管理员解散时成员收到移出通知，同时记录为房间关闭通知
+func TestKickedOnDisband(t *testing.T) {
+	ts := startTestServer(t, server.DefaultConfig())
+
+	host := ts.connect(t, 1)
+	player := ts.connect(t, 2)
+	roomID, _ := common.NewRoomId("disband")
+	host.CreateRoom(roomID)
+	waitFor(t, "创建房间", func() bool { return ts.GetRoom(roomID) != nil })
+	player.JoinRoom(roomID, false)
+	waitFor(t, "加入房间", func() bool { return ts.GetUser(2).GetRoom() != nil })
+
+	ts.DisbandRoom(ts.GetRoom(roomID), "房间已被管理员解散", "管理员解散")
+	waitFor(t, "移出通知", func() bool { return player.Kicked() != nil && host.Kicked() != nil })
+	kicked := player.Kicked()
+	if kicked.Reason != common.KickReasonDisbanded || kicked.Message != "管理员解散" {
+		t.Errorf("移出通知不匹配: %+v", kicked)
+	}
+	if closed := player.RoomClosed(); closed == nil || closed.RoomId != roomID {
+		t.Errorf("房间关闭通知不匹配: %+v", closed)
+	}
+}
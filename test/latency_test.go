@@ -0,0 +1,24 @@
+package test
+
+import (
+	"testing"
+
+	"phira-mp/common"
+	"phira-mp/server"
+)
+
+// TestPingLatency 测试 Ping/Pong 携带时间戳后客户端与服务器都能测得往返延迟
+func TestPingLatency(t *testing.T) {
+	ts := startTestServer(t, server.DefaultConfig())
+	c := ts.connect(t, 1)
+
+	// 第一次 Pong 后客户端测得延迟，下一次 Ping 回显该 Pong 的时间后服务器测得延迟
+	c.Ping()
+	waitFor(t, "客户端测得延迟", func() bool { return c.Latency() > 0 })
+	c.Ping()
+	waitFor(t, "服务器测得延迟", func() bool { return ts.GetUser(1).Latency() > 0 })
+
+	if latency := ts.GetUser(1).Latency(); latency > c.Latency()+common.HeartbeatInterval {
+		t.Errorf("服务器测得的延迟不合理: %v", latency)
+	}
+}
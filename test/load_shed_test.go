@@ -0,0 +1,76 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"phira-mp/common"
+	"phira-mp/server"
+)
+
+// TestLoadShedder 测试过载降级逐级升高、在恢复阈值以下才逐级降低
+func TestLoadShedder(t *testing.T) {
+	l := server.NewLoadShedder(0.8, 1<<20)
+	for want := server.LoadShedMonitors; want <= server.LoadShedRooms; want++ {
+		if _, got := l.Observe(server.LoadSample{CPU: 0.9}); got != want {
+			t.Fatalf("持续过载应逐级升高到 %s，实际 %s", want, got)
+		}
+	}
+	if _, got := l.Observe(server.LoadSample{Bandwidth: 2 << 20}); got != server.LoadShedRooms {
+		t.Errorf("最高等级不应继续升高: %s", got)
+	}
+	if _, got := l.Observe(server.LoadSample{CPU: 0.7}); got != server.LoadShedRooms {
+		t.Errorf("负载在阈值与恢复阈值之间时应保持等级: %s", got)
+	}
+	if _, got := l.Observe(server.LoadSample{CPU: 0.1}); got != server.LoadShedSnapshots {
+		t.Errorf("负载降低后应降低一级: %s", got)
+	}
+
+	if _, got := server.NewLoadShedder(0, 0).Observe(server.LoadSample{CPU: 1}); got != server.LoadShedNone {
+		t.Errorf("未配置阈值时不应降级: %s", got)
+	}
+}
+
+// TestLoadShedRejects 测试降级时拒绝新的观察者，最高等级时拒绝创建房间
+func TestLoadShedRejects(t *testing.T) {
+	config := server.DefaultConfig()
+	config.LoadShedCPU = 0.8
+	config.LiveMode = true
+	config.Monitors = []int32{3}
+	ts := startTestServer(t, config)
+
+	host := ts.connect(t, 1)
+	monitor := ts.connect(t, 3)
+	roomID, _ := common.NewRoomId("shed")
+	host.CreateRoom(roomID)
+	waitFor(t, "创建房间", func() bool { return ts.GetRoom(roomID) != nil })
+
+	overload := server.LoadSample{CPU: 1}
+	if level := ts.ObserveLoad(overload); level != server.LoadShedMonitors {
+		t.Fatalf("过载后应暂停接受观察者: %s", level)
+	}
+	monitor.JoinRoom(roomID, true)
+	time.Sleep(200 * time.Millisecond)
+	if ts.GetUser(3).GetRoom() != nil {
+		t.Fatal("降级时不应接受新的观察者")
+	}
+	if code := common.LookupErrorCode(server.ErrLoadShedMonitor); code != common.ErrCodeBusy {
+		t.Errorf("降级拒绝应对应服务器繁忙错误码，实际 %d", code)
+	}
+
+	for ts.LoadShedLevel() < server.LoadShedRooms {
+		ts.ObserveLoad(overload)
+	}
+	other, _ := common.NewRoomId("shed2")
+	monitor.CreateRoom(other)
+	time.Sleep(200 * time.Millisecond)
+	if ts.GetRoom(other) != nil {
+		t.Fatal("最高降级等级时不应创建房间")
+	}
+
+	for ts.LoadShedLevel() > server.LoadShedNone {
+		ts.ObserveLoad(server.LoadSample{})
+	}
+	monitor.JoinRoom(roomID, true)
+	waitFor(t, "降级解除后以观察者身份加入", func() bool { return ts.GetUser(3).GetRoom() != nil })
+}
@@ -0,0 +1,124 @@
+package test
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"phira-mp/server"
+)
+
+// signTestJWT 使用RS256签发测试令牌
+func signTestJWT(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	encode := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	signed := encode(map[string]string{"alg": "RS256", "kid": kid, "typ": "JWT"}) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// TestOIDCVerifier 测试OIDC令牌校验
+func TestOIDCVerifier(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var issuer string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": issuer, "jwks_uri": issuer + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "k1",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+	issuer = ts.URL
+
+	config := server.OIDCConfig{
+		Issuer:        issuer,
+		Audience:      "phira-mp-admin",
+		AllowedGroups: []string{"phira-admins"},
+	}
+	if !config.Enabled() {
+		t.Fatalf("完整的配置应启用OIDC: %s", config.Problem())
+	}
+	for name, incomplete := range map[string]server.OIDCConfig{
+		"缺少audience": {Issuer: issuer, AllowedGroups: []string{"phira-admins"}},
+		"缺少白名单":      {Issuer: issuer, Audience: "phira-mp-admin"},
+	} {
+		if incomplete.Enabled() {
+			t.Errorf("%s 时不应启用OIDC", name)
+		}
+	}
+	verifier := server.NewOIDCVerifier(config)
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss":    issuer,
+			"aud":    []string{"phira-mp-admin"},
+			"sub":    "alice",
+			"exp":    time.Now().Add(time.Hour).Unix(),
+			"groups": []string{"phira-admins"},
+		}
+		for k, v := range overrides {
+			c[k] = v
+		}
+		return c
+	}
+
+	token := signTestJWT(t, key, "k1", claims(nil))
+	if !server.LooksLikeJWT(token) {
+		t.Error("签发的令牌应该被识别为JWT")
+	}
+	result, err := verifier.Verify(token)
+	if err != nil {
+		t.Fatalf("有效令牌应该通过校验: %v", err)
+	}
+	if result.Subject != "alice" {
+		t.Errorf("sub不匹配: %s", result.Subject)
+	}
+
+	cases := map[string]map[string]interface{}{
+		"签发者不匹配": {"iss": "https://evil.example.com"},
+		"受众不匹配":  {"aud": "other"},
+		"令牌已过期":  {"exp": time.Now().Add(-time.Hour).Unix()},
+		"不在用户组中": {"groups": []string{"users"}},
+	}
+	for name, override := range cases {
+		if _, err := verifier.Verify(signTestJWT(t, key, "k1", claims(override))); err == nil {
+			t.Errorf("%s 的令牌不应该通过校验", name)
+		}
+	}
+
+	// 其他密钥签发的令牌
+	other, _ := rsa.GenerateKey(rand.Reader, 2048)
+	if _, err := verifier.Verify(signTestJWT(t, other, "k1", claims(nil))); err == nil {
+		t.Error("签名无效的令牌不应该通过校验")
+	}
+}
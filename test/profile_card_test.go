@@ -0,0 +1,43 @@
+package test
+
+import (
+	"testing"
+
+	"phira-mp/common"
+	"phira-mp/server"
+)
+
+// TestProfileCard 测试开启资料卡展示的玩家加入房间后，其他成员收到的用户信息带有资料卡
+func TestProfileCard(t *testing.T) {
+	ts := startTestServer(t, server.DefaultConfig())
+
+	host := ts.connect(t, 1)
+	if err := host.ShareStats(true); err != nil {
+		t.Fatalf("开启资料卡失败: %v", err)
+	}
+	waitFor(t, "开启资料卡", func() bool { return ts.GetUser(1).ShareStats() })
+
+	roomID, _ := common.NewRoomId("cards")
+	host.CreateRoom(roomID)
+	waitFor(t, "创建房间", func() bool { return ts.GetRoom(roomID) != nil })
+
+	player := ts.connect(t, 2)
+	player.JoinRoom(roomID, false)
+	waitFor(t, "加入房间", func() bool {
+		state := player.RoomState()
+		return state != nil && len(state.Users) == 2
+	})
+
+	users := player.RoomState().Users
+	card := users[1].Card
+	if card == nil {
+		t.Fatal("开启展示的房主应带有资料卡")
+	}
+	if card.Rks != 0.1 || card.PlayCount != 0 {
+		t.Errorf("资料卡不匹配: %+v", card)
+	}
+	if users[2].Card != nil {
+		t.Errorf("未开启展示的玩家不应带有资料卡: %+v", users[2].Card)
+	}
+
+}
@@ -0,0 +1,604 @@
+package test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+
+	"phira-mp/common"
+)
+
+// streamPair 建立一对TCP连接，返回服务器端Stream与客户端连接
+func streamPair(t *testing.T, allowed common.StreamFeatures, dial func(net.Conn) (*common.ClientStream, error)) (*common.ServerStream, *common.ClientStream) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	defer ln.Close()
+
+	accepted := make(chan *common.ServerStream, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			accepted <- nil
+			return
+		}
+		stream, err := common.NewServerStream(conn, allowed)
+		if err != nil {
+			conn.Close()
+			accepted <- nil
+			return
+		}
+		accepted <- stream
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	client, err := dial(conn)
+	if err != nil {
+		t.Fatalf("握手失败: %v", err)
+	}
+	server := <-accepted
+	if server == nil {
+		t.Fatal("服务器端握手失败")
+	}
+	t.Cleanup(func() {
+		client.Close()
+		server.Close()
+	})
+	return server, client
+}
+
+// TestSelectProtocol 测试协议版本选择
+func TestSelectProtocol(t *testing.T) {
+	unsupported := common.ProtocolLatest + 1
+	if v := common.SelectProtocol([]uint8{1, 2, unsupported}); v != common.ProtocolV2 {
+		t.Errorf("应该选择双方都支持的最高版本，实际 %d", v)
+	}
+	if v := common.SelectProtocol([]uint8{unsupported}); v != 0 {
+		t.Errorf("没有共同版本时应该返回0，实际 %d", v)
+	}
+}
+
+// TestProtocolNegotiation 测试协商握手
+func TestProtocolNegotiation(t *testing.T) {
+	server, client := streamPair(t, 0, func(conn net.Conn) (*common.ClientStream, error) {
+		return common.NewNegotiatedClientStream(conn, common.SupportedProtocols, 0)
+	})
+
+	if server.Protocol() != common.ProtocolLatest || client.Protocol() != common.ProtocolLatest {
+		t.Fatalf("应该协商到最新版本，服务器 %d 客户端 %d", server.Protocol(), client.Protocol())
+	}
+	if !server.Negotiated() {
+		t.Error("服务器端应该记录为已协商")
+	}
+
+	server.Send(common.ServerCommand{Type: common.ServerCmdReauthRequired, ReauthGrace: 30})
+	cmd, err := client.Recv()
+	if err != nil || cmd.Type != common.ServerCmdReauthRequired || cmd.ReauthGrace != 30 {
+		t.Errorf("扩展命令应该正常送达: %+v %v", cmd, err)
+	}
+}
+
+// TestProtocolLegacyShim 测试原版客户端的兼容层
+func TestProtocolLegacyShim(t *testing.T) {
+	server, client := streamPair(t, 0, func(conn net.Conn) (*common.ClientStream, error) {
+		return common.NewClientStream(conn, 1)
+	})
+
+	if server.Negotiated() || server.Protocol() != common.ProtocolV1 {
+		t.Fatalf("原版握手应该使用v1协议，实际 %d", server.Protocol())
+	}
+
+	// 扩展命令与新增消息类型被丢弃，追加字段不写入
+	server.Send(common.ServerCommand{Type: common.ServerCmdReauthRequired, ReauthGrace: 30})
+	server.Send(common.ServerCommand{Type: common.ServerCmdMessage, Message: &common.Message{Type: common.MsgLiveRoom, Live: true}})
+	server.Send(common.ServerCommand{Type: common.ServerCmdMessage, Message: &common.Message{
+		Type: common.MsgPlayed, User: 1, Score: 1000000, Accuracy: 1, FullCombo: true, Perfect: 100,
+	}})
+
+	cmd, err := client.Recv()
+	if err != nil {
+		t.Fatalf("接收失败: %v", err)
+	}
+	if cmd.Type != common.ServerCmdMessage || cmd.Message.Type != common.MsgPlayed {
+		t.Fatalf("应该只收到原版支持的消息: %+v", cmd)
+	}
+	if cmd.Message.Score != 1000000 || cmd.Message.Perfect != 0 {
+		t.Errorf("原版协议不应该写入判定统计: %+v", cmd.Message)
+	}
+
+	// 原版连接不能发送扩展命令
+	if err := client.Send(common.ClientCommand{Type: common.ClientCmdLoadProgress, Progress: 50}); err == nil {
+		t.Error("原版协议下发送扩展命令应该失败")
+	}
+}
+
+// TestProtocolWideIDs 测试V10起谱面与成绩ID为64位，更早的版本仍为int32
+func TestProtocolWideIDs(t *testing.T) {
+	const wide = int64(1) << 40
+
+	server, client := streamPair(t, 0, func(conn net.Conn) (*common.ClientStream, error) {
+		return common.NewNegotiatedClientStream(conn, common.SupportedProtocols, 0)
+	})
+	if server.Protocol() != common.ProtocolLatest {
+		t.Fatalf("应该协商到最新版本，实际 %d", server.Protocol())
+	}
+	if err := client.Send(common.ClientCommand{Type: common.ClientCmdPlayed, RecordID: wide}); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	if cmd, err := server.Recv(); err != nil || cmd.RecordID != wide {
+		t.Errorf("成绩ID应完整送达: %+v %v", cmd, err)
+	}
+	server.Send(common.ServerCommand{Type: common.ServerCmdMessage, Message: &common.Message{Type: common.MsgSelectChart, User: 1, Name: "x", ChartID: -wide}})
+	if cmd, err := client.Recv(); err != nil || cmd.Message.ChartID != -wide {
+		t.Errorf("谱面ID应完整送达: %+v %v", cmd, err)
+	}
+
+	legacyServer, legacyClient := streamPair(t, 0, func(conn net.Conn) (*common.ClientStream, error) {
+		return common.NewNegotiatedClientStream(conn, []uint8{common.ProtocolV9}, 0)
+	})
+	if err := legacyClient.Send(common.ClientCommand{Type: common.ClientCmdSelectChart, ChartID: wide}); !errors.Is(err, common.ErrIDOutOfRange) {
+		t.Errorf("v9下发送超出int32范围的ID应返回 ErrIDOutOfRange，实际 %v", err)
+	}
+	if err := legacyServer.Send(common.ServerCommand{Type: common.ServerCmdChangeState, ChangeState: &common.RoomState{ChartID: &[]int64{wide}[0]}}); !errors.Is(err, common.ErrIDOutOfRange) {
+		t.Errorf("向v9客户端发送超出int32范围的ID应返回 ErrIDOutOfRange，实际 %v", err)
+	}
+
+	// int32范围内的ID在旧版本中仍为4字节
+	if err := legacyClient.Send(common.ClientCommand{Type: common.ClientCmdSelectChart, ChartID: 42}); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	data, err := legacyServer.RecvRaw()
+	if err != nil || len(data) != 5 {
+		t.Fatalf("v9的 SelectChart 应为1字节类型加4字节ID: %v %v", data, err)
+	}
+}
+
+// TestResultErrorCode 测试V12起失败结果携带错误码，旧版本只收到错误信息
+func TestResultErrorCode(t *testing.T) {
+	server, client := streamPair(t, 0, func(conn net.Conn) (*common.ClientStream, error) {
+		return common.NewNegotiatedClientStream(conn, common.SupportedProtocols, 0)
+	})
+	results := []*common.Result[struct{}]{
+		{Err: strPtr("房间已锁定")},
+		{Err: strPtr("发言过于频繁，请 3 秒后再试")},
+		{Err: strPtr("自定义规则"), Code: common.ErrCodeRejected},
+		{Err: strPtr("未知错误")},
+	}
+	want := []common.ErrorCode{common.ErrCodeRoomLocked, common.ErrCodeRateLimited, common.ErrCodeRejected, common.ErrCodeOther}
+	for code := common.ErrCodeNotInRoom; code <= common.ErrCodeWrongPassword; code++ {
+		if msg := code.Message(); msg == "" || common.LookupErrorCode(msg) != code {
+			t.Errorf("错误码 %d 的默认信息 %q 不能查回该错误码", code, msg)
+		}
+	}
+	for i, result := range results {
+		server.Send(common.ServerCommand{Type: common.ServerCmdLockRoom, LockRoomResult: result})
+		cmd, err := client.Recv()
+		if err != nil {
+			t.Fatalf("接收失败: %v", err)
+		}
+		got := cmd.LockRoomResult
+		if got.ErrorCode() != want[i] || *got.Err != *result.Err {
+			t.Errorf("错误码或错误信息不正确: %d %q，期望 %d %q", got.ErrorCode(), *got.Err, want[i], *result.Err)
+		}
+	}
+
+	// 与默认信息相同时省略错误信息：1字节类型、1字节失败标记、1字节错误码、1字节空字符串
+	server.Send(common.ServerCommand{Type: common.ServerCmdLockRoom, LockRoomResult: &common.Result[struct{}]{Err: strPtr("房间已锁定")}})
+	if data, err := client.RecvRaw(); err != nil || len(data) != 4 {
+		t.Errorf("默认信息应省略: %v %v", data, err)
+	}
+
+	legacyServer, legacyClient := streamPair(t, 0, func(conn net.Conn) (*common.ClientStream, error) {
+		return common.NewNegotiatedClientStream(conn, []uint8{common.ProtocolV11}, 0)
+	})
+	legacyServer.Send(common.ServerCommand{Type: common.ServerCmdLockRoom, LockRoomResult: &common.Result[struct{}]{Err: strPtr("房间已锁定")}})
+	cmd, err := legacyClient.Recv()
+	if err != nil || *cmd.LockRoomResult.Err != "房间已锁定" || cmd.LockRoomResult.ErrorCode() != common.ErrCodeRoomLocked {
+		t.Errorf("v11只收到错误信息，也应能查到错误码: %+v %v", cmd.LockRoomResult, err)
+	}
+}
+
+// TestMessageTimestamp 测试V13起房间消息携带时间戳，旧版本客户端收到的消息格式不变
+func TestMessageTimestamp(t *testing.T) {
+	msg := common.Message{Type: common.MsgPlayed, User: 1, Score: 980000, Accuracy: 0.98, Perfect: 500, Time: 1700000000123}
+
+	server, client := streamPair(t, 0, func(conn net.Conn) (*common.ClientStream, error) {
+		return common.NewNegotiatedClientStream(conn, common.SupportedProtocols, 0)
+	})
+	server.Send(common.ServerCommand{Type: common.ServerCmdMessage, Message: &msg})
+	cmd, err := client.Recv()
+	if err != nil || cmd.Message == nil || *cmd.Message != msg {
+		t.Fatalf("消息应带时间戳送达: %+v %v", cmd.Message, err)
+	}
+
+	legacyServer, legacyClient := streamPair(t, 0, func(conn net.Conn) (*common.ClientStream, error) {
+		return common.NewNegotiatedClientStream(conn, []uint8{common.ProtocolV12}, 0)
+	})
+	legacyServer.Send(common.ServerCommand{Type: common.ServerCmdMessage, Message: &msg})
+	cmd, err = legacyClient.Recv()
+	if err != nil || cmd.Message == nil || cmd.Message.Time != 0 || cmd.Message.Perfect != 500 {
+		t.Errorf("v12不应收到时间戳: %+v %v", cmd.Message, err)
+	}
+}
+
+// TestCommandExtensions 测试命令末尾的扩展块
+func TestCommandExtensions(t *testing.T) {
+	const tagProfile, tagUnknown common.ExtensionTag = 1, 99
+
+	var exts common.Extensions
+	if err := exts.SetValue(tagProfile, &common.ProfileInfo{ID: 7, Name: "Alice"}); err != nil {
+		t.Fatalf("编码扩展字段失败: %v", err)
+	}
+	exts.Set(tagUnknown, []byte{1, 2, 3})
+
+	server, client := streamPair(t, 0, func(conn net.Conn) (*common.ClientStream, error) {
+		return common.NewNegotiatedClientStream(conn, common.SupportedProtocols, 0)
+	})
+	if server.Protocol() < common.ProtocolV6 {
+		t.Fatalf("应该协商到V6及以上，实际 %d", server.Protocol())
+	}
+
+	if err := client.Send(common.ClientCommand{Type: common.ClientCmdSelectChart, ChartID: 42, Extensions: exts}); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	cmd, err := server.Recv()
+	if err != nil || cmd.ChartID != 42 || len(cmd.Extensions) != 2 {
+		t.Fatalf("扩展字段应该随命令送达: %+v %v", cmd, err)
+	}
+	var profile common.ProfileInfo
+	if ok, err := cmd.Extensions.Value(tagProfile, &profile); !ok || err != nil || profile.Name != "Alice" {
+		t.Errorf("解码扩展字段失败: %+v %v %v", profile, ok, err)
+	}
+	if data, ok := cmd.Extensions.Get(tagUnknown); !ok || !bytes.Equal(data, []byte{1, 2, 3}) {
+		t.Errorf("未知标签应该原样保留: %v", data)
+	}
+
+	// 服务器命令在严格解码下同样可以携带扩展块（Message 的追加字段之后）
+	server.Send(common.ServerCommand{Type: common.ServerCmdMessage, Message: &common.Message{
+		Type: common.MsgPlayed, User: 1, Score: 1000000, Accuracy: 1, Perfect: 100,
+	}, Extensions: exts})
+	reply, err := client.Recv()
+	if err != nil || reply.Message == nil || reply.Message.Perfect != 100 || len(reply.Extensions) != 2 {
+		t.Fatalf("服务器命令的扩展字段应该送达: %+v %v", reply, err)
+	}
+
+	// V5 及更早版本的对端不写入扩展块
+	oldServer, oldClient := streamPair(t, 0, func(conn net.Conn) (*common.ClientStream, error) {
+		return common.NewNegotiatedClientStream(conn, []uint8{common.ProtocolV5}, 0)
+	})
+	oldServer.Send(common.ServerCommand{Type: common.ServerCmdReauthRequired, ReauthGrace: 30, Extensions: exts})
+	reply, err = oldClient.Recv()
+	if err != nil || reply.ReauthGrace != 30 || reply.Extensions != nil {
+		t.Errorf("V5 连接不应写入扩展块: %+v %v", reply, err)
+	}
+
+	// 数量超出上限或空扩展块被拒绝
+	w := common.NewBinaryWriter()
+	common.WriteUint8(w, uint8(common.ServerCmdPong))
+	w.Uleb(common.MaxExtensions + 1)
+	if err := common.DecodeStrict(w.Data(), &common.ServerCommand{}); err == nil {
+		t.Error("扩展字段过多应该返回错误")
+	}
+	if err := common.DecodeStrict([]byte{uint8(common.ServerCmdPong), 0}, &common.ServerCommand{}); err == nil {
+		t.Error("空扩展块应该返回错误")
+	}
+}
+
+// TestStreamCompression 测试协商连接压缩
+func TestStreamCompression(t *testing.T) {
+	server, client := streamPair(t, common.FeatureDeflate, func(conn net.Conn) (*common.ClientStream, error) {
+		return common.NewNegotiatedClientStream(conn, common.SupportedProtocols, common.FeatureDeflate)
+	})
+	if server.Features() != common.FeatureDeflate || client.Features() != common.FeatureDeflate {
+		t.Fatalf("双方都应该启用压缩，服务器 %v 客户端 %v", server.Features(), client.Features())
+	}
+
+	frames := []common.TouchFrame{{Time: 1.5, Points: []common.TouchPoint{{ID: 1}}}}
+	for i := int32(0); i < 50; i++ {
+		if err := server.Send(common.ServerCommand{Type: common.ServerCmdTouches, TouchesPlayer: i, TouchesFrames: frames}); err != nil {
+			t.Fatalf("发送失败: %v", err)
+		}
+	}
+	for i := int32(0); i < 50; i++ {
+		cmd, err := client.Recv()
+		if err != nil {
+			t.Fatalf("接收失败: %v", err)
+		}
+		if cmd.Type != common.ServerCmdTouches || cmd.TouchesPlayer != i || len(cmd.TouchesFrames) != 1 {
+			t.Fatalf("第%d帧解压结果不正确: %+v", i, cmd)
+		}
+	}
+
+	if err := client.Send(common.ClientCommand{Type: common.ClientCmdChat, Message: "hello"}); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	cmd, err := server.Recv()
+	if err != nil || cmd.Message != "hello" {
+		t.Errorf("服务器应该收到解压后的命令: %+v %v", cmd, err)
+	}
+}
+
+// TestStreamCompressionRefused 测试服务器未允许时不启用压缩
+func TestStreamCompressionRefused(t *testing.T) {
+	server, client := streamPair(t, 0, func(conn net.Conn) (*common.ClientStream, error) {
+		return common.NewNegotiatedClientStream(conn, common.SupportedProtocols, common.FeatureDeflate)
+	})
+	if server.Features() != 0 || client.Features() != 0 {
+		t.Fatal("服务器未允许时不应该启用压缩")
+	}
+
+	server.Send(common.ServerCommand{Type: common.ServerCmdPong})
+	if cmd, err := client.Recv(); err != nil || cmd.Type != common.ServerCmdPong {
+		t.Errorf("未压缩连接应该正常收发: %+v %v", cmd, err)
+	}
+}
+
+// TestStreamChecksum 测试帧CRC32校验
+func TestStreamChecksum(t *testing.T) {
+	features := common.FeatureDeflate | common.FeatureChecksum
+	server, client := streamPair(t, features, func(conn net.Conn) (*common.ClientStream, error) {
+		return common.NewNegotiatedClientStream(conn, common.SupportedProtocols, features)
+	})
+	if server.Features() != features || client.Features() != features {
+		t.Fatalf("双方都应该启用压缩与校验，服务器 %v 客户端 %v", server.Features(), client.Features())
+	}
+
+	server.Send(common.ServerCommand{Type: common.ServerCmdPong})
+	if cmd, err := client.Recv(); err != nil || cmd.Type != common.ServerCmdPong {
+		t.Errorf("校验连接应该正常收发: %+v %v", cmd, err)
+	}
+	if err := client.Send(common.ClientCommand{Type: common.ClientCmdChat, Message: "hello"}); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	if cmd, err := server.Recv(); err != nil || cmd.Message != "hello" {
+		t.Errorf("服务器应该收到校验通过的命令: %+v %v", cmd, err)
+	}
+}
+
+// TestStreamChecksumMismatch 测试损坏的帧使接收方报错而不是解出错误命令
+func TestStreamChecksumMismatch(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("监听失败: %v", err)
+	}
+	defer ln.Close()
+
+	accepted := make(chan *common.ServerStream, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			accepted <- nil
+			return
+		}
+		stream, _ := common.NewServerStream(conn, common.FeatureChecksum)
+		accepted <- stream
+	}()
+
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("连接失败: %v", err)
+	}
+	defer conn.Close()
+	conn.Write([]byte{common.ProtocolNegotiate, 1, common.ProtocolLatest, uint8(common.FeatureChecksum)})
+	reply := make([]byte, 2)
+	if _, err := io.ReadFull(conn, reply); err != nil || common.StreamFeatures(reply[1]) != common.FeatureChecksum {
+		t.Fatalf("握手失败: %v %v", reply, err)
+	}
+	server := <-accepted
+	if server == nil {
+		t.Fatal("服务器端握手失败")
+	}
+	defer server.Close()
+
+	// Ping帧（类型0）+ 错误的校验值
+	conn.Write([]byte{5, 0, 0xde, 0xad, 0xbe, 0xef})
+	if _, err := server.Recv(); !errors.Is(err, common.ErrFrameChecksum) {
+		t.Errorf("应该返回帧校验错误，实际: %v", err)
+	}
+}
+
+// TestStreamFrameLimits 测试按命令类型限制客户端命令的长度
+func TestStreamFrameLimits(t *testing.T) {
+	t.Cleanup(func() { common.SetFrameLimits(common.FrameLimits{}) })
+	common.SetFrameLimits(common.FrameLimits{Default: 256})
+	if limits := common.GetFrameLimits(); limits.Limit(common.ClientCmdChat) != 256 ||
+		limits.Limit(common.ClientCmdTouches) != common.DefaultFrameLimits.Commands[common.ClientCmdTouches] {
+		t.Fatalf("未单独设置的命令应使用 Default，已设置的沿用默认值: %+v", limits)
+	}
+
+	server, client := streamPair(t, 0, func(conn net.Conn) (*common.ClientStream, error) {
+		return common.NewNegotiatedClientStream(conn, common.SupportedProtocols, 0)
+	})
+
+	// 触摸数据远超 Default 仍在 Touches 的上限内
+	frame := common.TouchFrame{Time: 1, Points: make([]common.TouchPoint, 64)}
+	if err := client.Send(common.ClientCommand{Type: common.ClientCmdTouches, Frames: []common.TouchFrame{frame}}); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	if cmd, err := server.Recv(); err != nil || len(cmd.Frames) != 1 {
+		t.Fatalf("Touches 应在上限内: %+v %v", cmd, err)
+	}
+
+	if err := client.Send(common.ClientCommand{Type: common.ClientCmdChat, Message: string(bytes.Repeat([]byte("a"), 300))}); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	if _, err := server.Recv(); !errors.Is(err, common.ErrFrameTooLarge) {
+		t.Errorf("超长的 Chat 应返回 ErrFrameTooLarge，实际 %v", err)
+	}
+}
+
+// TestRoomPasswordEncoding 测试V21起 JoinRoom 末尾携带房间密码，更早的版本不写入
+func TestRoomPasswordEncoding(t *testing.T) {
+	roomID, _ := common.NewRoomId("password")
+
+	server, client := streamPair(t, 0, func(conn net.Conn) (*common.ClientStream, error) {
+		return common.NewNegotiatedClientStream(conn, common.SupportedProtocols, 0)
+	})
+	if err := client.Send(common.ClientCommand{Type: common.ClientCmdJoinRoom, RoomId: roomID, Password: "secret"}); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	if cmd, err := server.Recv(); err != nil || cmd.Password != "secret" {
+		t.Errorf("房间密码应完整送达: %+v %v", cmd, err)
+	}
+
+	legacyServer, legacyClient := streamPair(t, 0, func(conn net.Conn) (*common.ClientStream, error) {
+		return common.NewNegotiatedClientStream(conn, []uint8{common.ProtocolV20}, 0)
+	})
+	if err := legacyClient.Send(common.ClientCommand{Type: common.ClientCmdJoinRoom, RoomId: roomID, Password: "secret"}); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	if cmd, err := legacyServer.Recv(); err != nil || cmd.RoomId != roomID || cmd.Password != "" {
+		t.Errorf("v20不应写入房间密码: %+v %v", cmd, err)
+	}
+}
+
+// TestExtendedJudgementEncoding 测试扩展判定在V24起原样转发，更早版本转换为原版判定并跳过未知判定
+func TestExtendedJudgementEncoding(t *testing.T) {
+	judges := []common.JudgeEvent{
+		{NoteID: 1, Judgement: common.JudgementFlickPerfect | common.JudgementEarly},
+		{NoteID: 2, Judgement: common.JudgementGood | common.JudgementLate},
+		{NoteID: 3, Judgement: 0x3f},
+		{NoteID: 4, Judgement: common.JudgementFlickMiss},
+	}
+	cmd := common.ServerCommand{Type: common.ServerCmdJudges, JudgesPlayer: 1, JudgesEvents: judges}
+
+	server, client := streamPair(t, 0, func(conn net.Conn) (*common.ClientStream, error) {
+		return common.NewNegotiatedClientStream(conn, common.SupportedProtocols, 0)
+	})
+	server.Send(cmd)
+	read, err := client.Recv()
+	if err != nil || len(read.JudgesEvents) != len(judges) {
+		t.Fatalf("扩展判定应原样送达: %+v %v", read.JudgesEvents, err)
+	}
+	for i, j := range read.JudgesEvents {
+		if j != judges[i] {
+			t.Errorf("判定不匹配: %+v != %+v", j, judges[i])
+		}
+	}
+
+	legacyServer, legacyClient := streamPair(t, 0, func(conn net.Conn) (*common.ClientStream, error) {
+		return common.NewNegotiatedClientStream(conn, []uint8{common.ProtocolV23}, 0)
+	})
+	legacyServer.Send(cmd)
+	read, err = legacyClient.Recv()
+	if err != nil {
+		t.Fatalf("接收失败: %v", err)
+	}
+	want := []common.Judgement{common.JudgementPerfect, common.JudgementGood, common.JudgementMiss}
+	if len(read.JudgesEvents) != len(want) {
+		t.Fatalf("v23应跳过无法转换的判定: %+v", read.JudgesEvents)
+	}
+	for i, j := range read.JudgesEvents {
+		if j.Judgement != want[i] {
+			t.Errorf("v23判定转换不正确: %+v，期望 %s", j, want[i])
+		}
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
+
+// recordingConn 记录连接两个方向的原始字节
+type recordingConn struct {
+	net.Conn
+	mu       sync.Mutex
+	sent     []byte
+	received []byte
+}
+
+func (c *recordingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	c.mu.Lock()
+	c.received = append(c.received, p[:n]...)
+	c.mu.Unlock()
+	return n, err
+}
+
+func (c *recordingConn) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	c.sent = append(c.sent, p...)
+	c.mu.Unlock()
+	return c.Conn.Write(p)
+}
+
+// TestWireFormatCapture 测试从抓取的原始字节流中解析握手并逐帧解码（压缩与校验同时启用）
+func TestWireFormatCapture(t *testing.T) {
+	features := common.FeatureDeflate | common.FeatureChecksum
+	var rec *recordingConn
+	server, client := streamPair(t, features, func(conn net.Conn) (*common.ClientStream, error) {
+		rec = &recordingConn{Conn: conn}
+		return common.NewNegotiatedClientStream(rec, common.SupportedProtocols, features)
+	})
+
+	client.Send(common.ClientCommand{Type: common.ClientCmdAuthenticate, Token: "token"})
+	client.Send(common.ClientCommand{Type: common.ClientCmdChat, Message: "hello"})
+	for i := 0; i < 2; i++ {
+		if _, err := server.Recv(); err != nil {
+			t.Fatalf("接收失败: %v", err)
+		}
+	}
+	server.Send(common.ServerCommand{Type: common.ServerCmdMessage, Message: &common.Message{Type: common.MsgChat, User: 1, Content: "hi"}})
+	if _, err := client.Recv(); err != nil {
+		t.Fatalf("接收失败: %v", err)
+	}
+
+	rec.mu.Lock()
+	sent, received := rec.sent, rec.received
+	rec.mu.Unlock()
+	format, clientLen, serverLen, err := common.ParseHandshake(sent, received)
+	if err != nil {
+		t.Fatalf("解析握手失败: %v", err)
+	}
+	if format != client.WireFormat() || format.Protocol() != common.ProtocolLatest || serverLen != 2 {
+		t.Fatalf("握手解析结果不正确: %+v serverLen=%d", format, serverLen)
+	}
+
+	frames := format.NewFrameReader(bytes.NewReader(sent[clientLen:]))
+	var chats []string
+	for {
+		data, err := frames.Next()
+		if err != nil {
+			break
+		}
+		cmd, err := format.DecodeClient(data)
+		if err != nil {
+			t.Fatalf("解码客户端命令失败: %v", err)
+		}
+		if cmd.Type == common.ClientCmdChat {
+			chats = append(chats, cmd.Message)
+		}
+	}
+	if len(chats) != 1 || chats[0] != "hello" {
+		t.Errorf("应该解出客户端发送的聊天: %v", chats)
+	}
+
+	data, err := format.NewFrameReader(bytes.NewReader(received[serverLen:])).Next()
+	if err != nil {
+		t.Fatalf("读取服务器帧失败: %v", err)
+	}
+	cmd, err := format.DecodeServer(data)
+	if err != nil || cmd.Message == nil || cmd.Message.Content != "hi" {
+		t.Errorf("应该解出服务器发送的消息: %+v %v", cmd, err)
+	}
+
+	encoded, err := format.EncodeClient(common.ClientCommand{Type: common.ClientCmdChat, Message: "hello"})
+	if err != nil {
+		t.Fatalf("编码失败: %v", err)
+	}
+	if decoded, err := format.DecodeClient(encoded); err != nil || decoded.Message != "hello" {
+		t.Errorf("编码结果应该能解码: %+v %v", decoded, err)
+	}
+
+	if f, n, _, err := common.ParseHandshake([]byte{common.ProtocolV1}, nil); err != nil || f.Negotiated || f.Protocol() != common.ProtocolV1 || n != 1 {
+		t.Errorf("原版握手解析不正确: %+v %v", f, err)
+	}
+}
@@ -0,0 +1,147 @@
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"phira-mp/common"
+	"phira-mp/server"
+)
+
+// TestRankRecords 测试内置排名策略与并列名次
+func TestRankRecords(t *testing.T) {
+	records := map[int32]*server.Record{
+		1: {Score: 900000, Accuracy: 0.95, MaxCombo: 100, Perfect: 200},
+		2: {Score: 950000, Accuracy: 0.93, MaxCombo: 200, Perfect: 200},
+		3: {Score: 900000, Accuracy: 0.99, MaxCombo: 200, Perfect: 200},
+	}
+	name := func(id int32) string { return fmt.Sprintf("P%d", id) }
+
+	score, _ := server.LookupAggregator(server.AggregatorScore)
+	ranking := server.RankRecords(score, records, name)
+	if ranking[0].UserID != 2 || ranking[1].Rank != 2 || ranking[2].Rank != 2 {
+		t.Errorf("按分数排名不正确（分数相同应并列）: %+v", ranking)
+	}
+	if ranking[1].UserID != 1 || ranking[2].UserID != 3 || ranking[0].Name != "P2" {
+		t.Errorf("并列时应按用户ID排序: %+v", ranking)
+	}
+
+	acc, _ := server.LookupAggregator(server.AggregatorAcc)
+	if ranking := server.RankRecords(acc, records, name); ranking[0].UserID != 3 || ranking[2].UserID != 2 {
+		t.Errorf("按准度排名不正确: %+v", ranking)
+	}
+
+	// 连击占比：玩家1只有一半连击，加权后低于玩家3
+	combo, _ := server.LookupAggregator(server.AggregatorCombo)
+	ranking = server.RankRecords(combo, records, name)
+	if ranking[0].UserID != 2 || ranking[1].UserID != 3 || ranking[2].Points != 675000 {
+		t.Errorf("按连击加权排名不正确: %+v", ranking)
+	}
+}
+
+// TestRegisterAggregator 测试注册自定义排名策略
+func TestRegisterAggregator(t *testing.T) {
+	custom := server.NewAggregator("test-miss", func(r *server.Record) float64 { return -float64(r.Miss) })
+	if err := server.RegisterAggregator(custom); err != nil {
+		t.Fatalf("注册失败: %v", err)
+	}
+	if err := server.RegisterAggregator(custom); err == nil {
+		t.Error("重复注册应返回错误")
+	}
+	if err := server.RegisterAggregator(server.NewAggregator("", nil)); err == nil {
+		t.Error("空名称应返回错误")
+	}
+
+	found := false
+	for _, name := range server.AggregatorNames() {
+		found = found || name == "test-miss"
+	}
+	if !found {
+		t.Errorf("已注册的策略应出现在列表中: %v", server.AggregatorNames())
+	}
+
+	config := server.DefaultConfig()
+	srv := server.NewServer(config)
+	roomID, _ := common.NewRoomId("custom-rank")
+	room := server.NewRoom(roomID, server.NewUser(1, "Host", "zh-CN", srv), srv)
+	if room.GetAggregator().Name() != server.DefaultAggregator {
+		t.Errorf("默认排名策略应为 %s", server.DefaultAggregator)
+	}
+	if err := room.SetAggregator("test-miss"); err != nil || room.GetAggregator().Name() != "test-miss" {
+		t.Errorf("设置自定义策略失败: %v", err)
+	}
+	if err := room.SetAggregator("unknown"); err == nil {
+		t.Error("未知策略应返回错误")
+	}
+}
+
+// TestGameSummaryRanking 测试房主设置排名策略后，对局结束时按该策略生成结算
+func TestGameSummaryRanking(t *testing.T) {
+	ts := startTestServer(t, server.ServerConfig{DefaultMaxUsers: 8, GameHistorySize: 16})
+
+	// 玩家1分数高、准度低，玩家2分数低、准度高
+	records := map[string]string{
+		"/record/101": `{"id": 101, "player": 1, "score": 990000, "accuracy": 0.95, "perfect": 10}`,
+		"/record/102": `{"id": 102, "player": 2, "score": 980000, "accuracy": 0.99, "perfect": 10}`,
+	}
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/me":
+			serveFakeMe(w, r)
+		case strings.HasPrefix(r.URL.Path, "/chart/"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": 5, "name": "Ranked"})
+		case records[r.URL.Path] != "":
+			w.Write([]byte(records[r.URL.Path]))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(api.Close)
+	server.ConfigurePhiraAPI(server.PhiraAPIConfig{BaseURL: api.URL, Timeout: 5})
+
+	host := ts.connect(t, 1)
+	player := ts.connect(t, 2)
+	roomID, _ := common.NewRoomId("ranked-game")
+	host.CreateRoom(roomID)
+	waitFor(t, "创建房间", func() bool { return ts.GetRoom(roomID) != nil })
+	room := ts.GetRoom(roomID)
+	player.JoinRoom(roomID, false)
+	waitFor(t, "玩家加入", func() bool { return len(room.GetUsers()) == 2 })
+
+	if err := player.SetRanking(server.AggregatorAcc); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	if err := host.SetRanking(server.AggregatorAcc); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	waitFor(t, "设置排名策略", func() bool { return room.GetAggregator().Name() == server.AggregatorAcc })
+
+	host.SelectChart(5)
+	waitFor(t, "选择谱面", func() bool { return room.GetChart() != nil })
+	host.RequestStart()
+	waitFor(t, "等待准备", func() bool { return room.GetState() == server.InternalStateWaitForReady })
+	player.Ready()
+	waitFor(t, "开始游戏", func() bool { return room.GetState() == server.InternalStatePlaying })
+
+	host.Played(101)
+	player.Played(102)
+	waitFor(t, "生成结算", func() bool { return room.GetLastSummary() != nil })
+
+	summary := room.GetLastSummary()
+	if summary.Aggregator != server.AggregatorAcc || summary.ChartID != 5 || len(summary.Ranking) != 2 {
+		t.Fatalf("结算不正确: %+v", summary)
+	}
+	if summary.Ranking[0].UserID != 2 || summary.Ranking[0].Rank != 1 || summary.Ranking[1].UserID != 1 {
+		t.Errorf("应按准度排名: %+v", summary.Ranking)
+	}
+
+	// 结算计入对局历史
+	board := ts.GetGameHistory().Leaderboard(roomID.Value, 0, summary.EndedAt)
+	if board.Games != 1 || len(board.Players) != 2 || board.Players[0].UserID != 2 || board.Players[0].Wins != 1 {
+		t.Errorf("对局历史累计排行不正确: %+v", board)
+	}
+}
@@ -0,0 +1,93 @@
+package test
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"phira-mp/server"
+)
+
+// TestRateLimiter 测试公开接口按IP限流
+func TestRateLimiter(t *testing.T) {
+	if server.NewRateLimiter("room", server.RateLimitConfig{}) != nil {
+		t.Fatal("rate 为0时不应该启用限流")
+	}
+
+	limiter := server.NewRateLimiter("room", server.RateLimitConfig{Rate: 2, Burst: 3})
+	now := time.Now()
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := limiter.Allow("1.1.1.1", now); !ok {
+			t.Fatalf("突发额度内的第%d次请求不应该被拒绝", i+1)
+		}
+	}
+	ok, wait := limiter.Allow("1.1.1.1", now)
+	if ok {
+		t.Fatal("超出突发额度的请求应该被拒绝")
+	}
+	if wait <= 0 || wait > 500*time.Millisecond {
+		t.Errorf("等待时间应该为0.5秒以内，实际 %v", wait)
+	}
+
+	// 不同IP互不影响
+	if ok, _ := limiter.Allow("2.2.2.2", now); !ok {
+		t.Error("其他IP的请求不应该被拒绝")
+	}
+
+	// 按速率恢复额度
+	if ok, _ := limiter.Allow("1.1.1.1", now.Add(500*time.Millisecond)); !ok {
+		t.Error("恢复额度后的请求不应该被拒绝")
+	}
+	if ok, _ := limiter.Allow("1.1.1.1", now.Add(500*time.Millisecond)); ok {
+		t.Error("额度用尽后的请求应该被拒绝")
+	}
+}
+
+// TestRateLimitIgnoresForwardedFor 测试未配置 real_ip_header 时按连接地址限流，伪造的 X-Forwarded-For 不能绕过
+func TestRateLimitIgnoresForwardedFor(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	httpPort := ln.Addr().(*net.TCPAddr).Port
+	ln.Close()
+
+	config := server.DefaultConfig()
+	config.HTTPService = true
+	config.HTTPPort = httpPort
+	config.PublicRateLimit.Room = server.RateLimitConfig{Rate: 0.1, Burst: 2}
+	dir := t.TempDir()
+	config.AdminDataPath = filepath.Join(dir, "admin_data.json")
+	config.ActivityStatsPath = filepath.Join(dir, "activity_stats.json")
+	config.RecordLedgerPath = filepath.Join(dir, "used_records.json")
+	config.GameHistoryPath = filepath.Join(dir, "game_history.json")
+
+	srv := server.NewServer(config)
+	go srv.Start("127.0.0.1:0")
+	t.Cleanup(srv.Stop)
+	select {
+	case <-srv.Ready():
+	case <-time.After(3 * time.Second):
+		t.Fatal("等待服务器启动超时")
+	}
+
+	url := fmt.Sprintf("http://127.0.0.1:%d/room", httpPort)
+	status := 0
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		req.Header.Set("X-Forwarded-For", fmt.Sprintf("10.0.0.%d", i+1))
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("请求失败: %v", err)
+		}
+		resp.Body.Close()
+		status = resp.StatusCode
+	}
+	if status != http.StatusTooManyRequests {
+		t.Errorf("更换 X-Forwarded-For 不应绕过限流，第3次请求状态码: %d", status)
+	}
+}
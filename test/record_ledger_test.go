@@ -0,0 +1,110 @@
+package test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"phira-mp/server"
+)
+
+// TestRecordLedger 测试成绩重复使用检测
+func TestRecordLedger(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "used_records.json")
+	ledger := server.NewRecordLedger(path, 3)
+	now := time.Now()
+
+	if used := ledger.Consume(1, 100, "room-a", now); used != nil {
+		t.Fatal("首次使用的成绩不应该被拒绝")
+	}
+	used := ledger.Consume(1, 100, "room-b", now)
+	if used == nil {
+		t.Fatal("重复使用的成绩应该被拒绝")
+	}
+	if used.RoomID != "room-a" || used.UserID != 100 {
+		t.Errorf("应该返回首次使用的记录: %+v", used)
+	}
+
+	// 超出容量时淘汰最早的记录
+	ledger.Consume(2, 100, "room-a", now)
+	ledger.Consume(3, 100, "room-a", now)
+	ledger.Consume(4, 100, "room-a", now)
+	if ledger.Len() != 3 {
+		t.Errorf("登记数量应该为3，实际 %d", ledger.Len())
+	}
+	if ledger.Consume(1, 100, "room-c", now) != nil {
+		t.Error("已淘汰的成绩应该可以再次登记")
+	}
+
+	// 保存后重新加载
+	if err := ledger.Save(); err != nil {
+		t.Fatalf("保存失败: %v", err)
+	}
+	loaded := server.NewRecordLedger(path, 3)
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("加载失败: %v", err)
+	}
+	if loaded.Len() != 3 {
+		t.Errorf("加载后数量应该为3，实际 %d", loaded.Len())
+	}
+	if used := loaded.Consume(4, 200, "room-d", now); used == nil || used.RoomID != "room-a" {
+		t.Error("加载后应该保留已使用的成绩")
+	}
+	if loaded.Consume(3, 100, "room-a", now) == nil {
+		t.Error("加载后应该保留未淘汰的成绩")
+	}
+}
+
+// TestRecordLedgerFrozen 测试平滑升级冻结后不再写文件，解除冻结后恢复保存
+func TestRecordLedgerFrozen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "used_records.json")
+	ledger := server.NewRecordLedger(path, 10)
+	ledger.Consume(1, 100, "room-a", time.Now())
+	if err := ledger.Save(); err != nil {
+		t.Fatalf("保存失败: %v", err)
+	}
+
+	// 新进程接管文件后写入的内容不应被旧进程覆盖
+	ledger.Freeze(true)
+	ledger.Consume(2, 100, "room-a", time.Now())
+	if err := ledger.Save(); err != nil {
+		t.Fatalf("冻结后保存不应报错: %v", err)
+	}
+	loaded := server.NewRecordLedger(path, 10)
+	loaded.Load()
+	if loaded.Len() != 1 {
+		t.Fatalf("冻结后不应写文件，文件中的数量: %d", loaded.Len())
+	}
+
+	ledger.Freeze(false)
+	ledger.Save()
+	loaded = server.NewRecordLedger(path, 10)
+	loaded.Load()
+	if loaded.Len() != 2 {
+		t.Errorf("解除冻结后应保存之前的修改，文件中的数量: %d", loaded.Len())
+	}
+}
+
+// TestRecordLedgerSaveRetry 测试保存失败后已使用的成绩仍在下次保存时写入，不会因此可以再次使用
+func TestRecordLedgerSaveRetry(t *testing.T) {
+	blocker := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(blocker, "used_records.json")
+	ledger := server.NewRecordLedger(path, 8)
+	ledger.Consume(101, 1, "cup", time.Now())
+	if err := ledger.Save(); err == nil {
+		t.Fatal("目录无法创建时保存应失败")
+	}
+
+	os.Remove(blocker)
+	if err := ledger.Save(); err != nil {
+		t.Fatalf("重试保存失败: %v", err)
+	}
+	loaded := server.NewRecordLedger(path, 8)
+	if err := loaded.Load(); err != nil || loaded.Consume(101, 2, "other", time.Now()) == nil {
+		t.Errorf("保存失败的成绩登记应在重试时写入: %v", err)
+	}
+}
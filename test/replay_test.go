@@ -0,0 +1,596 @@
+package test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"phira-mp/common"
+	"phira-mp/server"
+)
+
+// TestReplayIndex 测试回放索引的保存与加载
+func TestReplayIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.json")
+	index := server.NewReplayIndex(path)
+
+	id := index.Put(&server.ReplayEntry{
+		UserID:    100,
+		ChartID:   1,
+		Timestamp: 1730000000000,
+		RecordID:  123,
+		Path:      filepath.Join("record", "100", "1", "1730000000000.phirarec"),
+		ObjectKey: "replays/100/1/1730000000000.phirarec",
+	})
+	if id == "" {
+		t.Fatal("应该分配回放ID")
+	}
+	if err := index.Save(); err != nil {
+		t.Fatalf("保存索引失败: %v", err)
+	}
+
+	loaded := server.NewReplayIndex(path)
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("加载索引失败: %v", err)
+	}
+	entry := loaded.Get(id)
+	if entry == nil {
+		t.Fatal("应该能按ID找到索引条目")
+	}
+	if entry.RecordID != 123 || entry.ObjectKey != "replays/100/1/1730000000000.phirarec" {
+		t.Errorf("索引条目不匹配: %+v", entry)
+	}
+	if found := loaded.Find(100, 1, 1730000000000); found == nil || found.ID != id {
+		t.Error("应该能按谱面ID和时间戳找到索引条目")
+	}
+	if found := loaded.FindByPath("record/100/1/1730000000000.phirarec"); found == nil || found.ID != id {
+		t.Error("应该能按路径找到索引条目")
+	}
+
+	if loaded.Remove(id) == nil || loaded.Get(id) != nil {
+		t.Error("删除后不应该再找到索引条目")
+	}
+}
+
+// TestReplayShare 测试回放分享链接
+func TestReplayShare(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "index.json")
+	index := server.NewReplayIndex(path)
+	id := index.Put(&server.ReplayEntry{UserID: 100, ChartID: 1, Timestamp: 1730000000000, Path: "record/100/1/1730000000000.phirarec"})
+
+	permanent := index.AddShare(id, 0)
+	if permanent.ExpiresAt != 0 || index.GetShare(permanent.Token) == nil {
+		t.Error("永久分享链接应该有效")
+	}
+
+	expired := index.AddShare(id, time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+	if index.GetShare(expired.Token) != nil {
+		t.Error("过期的分享链接不应该有效")
+	}
+	if pruned := index.PruneShares(); pruned != 1 {
+		t.Errorf("清理数量不匹配: 期望 1, 实际 %d", pruned)
+	}
+
+	// 分享链接随索引持久化
+	if err := index.Save(); err != nil {
+		t.Fatalf("保存索引失败: %v", err)
+	}
+	loaded := server.NewReplayIndex(path)
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("加载索引失败: %v", err)
+	}
+	if share := loaded.GetShare(permanent.Token); share == nil || share.ReplayID != id {
+		t.Error("加载后分享链接应该仍然有效")
+	}
+
+	// 删除回放后分享链接失效
+	loaded.Remove(id)
+	if loaded.GetShare(permanent.Token) != nil {
+		t.Error("回放删除后分享链接应该失效")
+	}
+}
+
+// writeReplayFile 写入只有文件头的回放文件
+func writeReplayFile(t *testing.T, path string, chartID, userID, recordID int32) {
+	header := make([]byte, 14)
+	binary.LittleEndian.PutUint16(header[0:2], 0x504D)
+	binary.LittleEndian.PutUint32(header[2:6], uint32(chartID))
+	binary.LittleEndian.PutUint32(header[6:10], uint32(userID))
+	binary.LittleEndian.PutUint32(header[10:14], uint32(recordID))
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, header, 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestClipReplayFile 测试截取回放片段
+func TestClipReplayFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "100", "1", "1730000000000.phirarec")
+	writeReplayFile(t, src, 1, 100, 123)
+
+	// 每秒一条触摸帧与一条判定事件
+	file, err := os.OpenFile(src, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for second := uint32(0); second < 10; second++ {
+		touch := make([]byte, 5)
+		binary.LittleEndian.PutUint32(touch, second)
+		judge := make([]byte, 13)
+		binary.LittleEndian.PutUint32(judge, second)
+		file.Write(append([]byte{0x01, byte(len(touch))}, touch...))
+		file.Write(append([]byte{0x02, byte(len(judge))}, judge...))
+	}
+	file.Close()
+
+	dst := filepath.Join(dir, "100", "1", "1730000001000.phirarec")
+	records, err := server.ClipReplayFile(src, dst, 3, 5)
+	if err != nil {
+		t.Fatalf("截取回放失败: %v", err)
+	}
+	if records != 6 {
+		t.Errorf("记录数不匹配: 期望 6, 实际 %d", records)
+	}
+
+	chartID, userID, recordID := readHeader(t, dst)
+	if chartID != 1 || userID != 100 || recordID != 123 {
+		t.Errorf("片段文件头不匹配: chart=%d user=%d record=%d", chartID, userID, recordID)
+	}
+	info, err := os.Stat(dst)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := int64(14 + 3*(2+5) + 3*(2+13)); info.Size() != want {
+		t.Errorf("片段文件大小不匹配: 期望 %d, 实际 %d", want, info.Size())
+	}
+
+	if _, err := server.ClipReplayFile(filepath.Join(dir, "missing.phirarec"), dst, 0, 1); err == nil {
+		t.Error("源文件不存在时应该返回错误")
+	}
+}
+
+// readHeader 读取回放文件头
+func readHeader(t *testing.T, path string) (chartID, userID, recordID int32) {
+	data, err := os.ReadFile(path)
+	if err != nil || len(data) < 14 {
+		t.Fatalf("读取回放文件失败: %v", err)
+	}
+	return int32(binary.LittleEndian.Uint32(data[2:6])), int32(binary.LittleEndian.Uint32(data[6:10])), int32(binary.LittleEndian.Uint32(data[10:14]))
+}
+
+// TestReplayIndexMigrate 测试旧版回放文件的迁移
+func TestReplayIndexMigrate(t *testing.T) {
+	dir := t.TempDir()
+	legacy := filepath.Join(dir, "100", "1730000000000.phirarec")
+	current := filepath.Join(dir, "100", "2", "1730000001000.phirarec")
+	writeReplayFile(t, legacy, 1, 100, 123)
+	writeReplayFile(t, current, 2, 100, 0)
+
+	index := server.NewReplayIndex(filepath.Join(dir, "index.json"))
+	if added := index.Migrate(dir); added != 2 {
+		t.Fatalf("登记数量不匹配: 期望 2, 实际 %d", added)
+	}
+
+	moved := filepath.Join(dir, "100", "1", "1730000000000.phirarec")
+	if _, err := os.Stat(moved); err != nil {
+		t.Errorf("旧版文件应该被移动到标准路径: %v", err)
+	}
+	entry := index.Find(100, 1, 1730000000000)
+	if entry == nil || entry.Path != moved || entry.RecordID != 123 {
+		t.Errorf("迁移后的索引条目不匹配: %+v", entry)
+	}
+	if len(index.ListUser(100)) != 2 {
+		t.Errorf("用户回放数量不匹配: %d", len(index.ListUser(100)))
+	}
+
+	// 再次迁移不应重复登记
+	if added := index.Migrate(dir); added != 0 {
+		t.Errorf("重复迁移不应登记新回放，实际 %d", added)
+	}
+}
+
+// TestObjectStorage 测试对象存储的上传、删除与签名链接
+func TestObjectStorage(t *testing.T) {
+	var mu sync.Mutex
+	objects := make(map[string]string)
+	s3 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AK/") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.Method {
+		case http.MethodPut:
+			body, _ := io.ReadAll(r.Body)
+			objects[r.URL.Path] = string(body)
+		case http.MethodDelete:
+			delete(objects, r.URL.Path)
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer s3.Close()
+
+	storage, err := server.NewObjectStorage(server.ReplayStorageConfig{
+		Endpoint:  s3.URL,
+		Bucket:    "replays",
+		Prefix:    "rec/",
+		AccessKey: "AK",
+		SecretKey: "SK",
+		PathStyle: true,
+	})
+	if err != nil {
+		t.Fatalf("创建对象存储失败: %v", err)
+	}
+
+	key := storage.ObjectKey("100/1/1730000000000.phirarec")
+	if key != "rec/100/1/1730000000000.phirarec" {
+		t.Errorf("对象键不匹配: %s", key)
+	}
+	if err := storage.PutObject(key, []byte("data"), "application/octet-stream"); err != nil {
+		t.Fatalf("上传失败: %v", err)
+	}
+	if objects["/replays/"+key] != "data" {
+		t.Errorf("对象内容不匹配: %v", objects)
+	}
+	if storage.ObjectURL(key) != s3.URL+"/replays/"+key {
+		t.Errorf("对象地址不匹配: %s", storage.ObjectURL(key))
+	}
+
+	url := storage.PresignGet(key, 10*time.Minute)
+	if !strings.Contains(url, "X-Amz-Expires=600") || !strings.Contains(url, "X-Amz-Signature=") {
+		t.Errorf("签名链接缺少参数: %s", url)
+	}
+
+	if err := storage.DeleteObject(key); err != nil {
+		t.Fatalf("删除失败: %v", err)
+	}
+	if len(objects) != 0 {
+		t.Errorf("对象应该已被删除: %v", objects)
+	}
+}
+
+// TestReplayURLSigner 测试回放下载签名链接
+func TestReplayURLSigner(t *testing.T) {
+	now := time.Now()
+	signer := server.NewReplayURLSigner("secret", "https://cdn.example.com/", time.Minute)
+
+	link, expiresAt := signer.Sign("abc", now)
+	if !strings.HasPrefix(link, "https://cdn.example.com/replay/download?") {
+		t.Fatalf("链接前缀不正确: %s", link)
+	}
+	if !expiresAt.Equal(now.Add(time.Minute)) {
+		t.Errorf("过期时间不正确: %v", expiresAt)
+	}
+
+	query, err := url.ParseQuery(link[strings.Index(link, "?")+1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, expires, sig := query.Get("id"), query.Get("expires"), query.Get("sig")
+	if _, ok := signer.Verify(id, expires, sig, now); !ok {
+		t.Error("有效签名应该通过校验")
+	}
+	if _, ok := signer.Verify("other", expires, sig, now); ok {
+		t.Error("篡改回放ID后签名不应该通过")
+	}
+	if _, ok := signer.Verify(id, expires+"0", sig, now); ok {
+		t.Error("篡改过期时间后签名不应该通过")
+	}
+	if _, ok := signer.Verify(id, expires, sig, now.Add(2*time.Minute)); ok {
+		t.Error("过期的链接不应该通过")
+	}
+
+	// 不同密钥的签名互不通用
+	other := server.NewReplayURLSigner("another", "", time.Minute)
+	if _, ok := other.Verify(id, expires, sig, now); ok {
+		t.Error("不同密钥的签名不应该通过")
+	}
+}
+
+// TestReplayEncryption 测试回放文件的加密、解密与截取
+func TestReplayEncryption(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "100", "1", "1730000000000.phirarec")
+	writeReplayFile(t, src, 1, 100, 123)
+	file, err := os.OpenFile(src, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for second := uint32(0); second < 10; second++ {
+		touch := make([]byte, 5)
+		binary.LittleEndian.PutUint32(touch, second)
+		file.Write(append([]byte{0x01, byte(len(touch))}, touch...))
+	}
+	file.Close()
+	plain, _ := os.ReadFile(src)
+
+	// 密钥通过 key_url 获取
+	key := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{7}, 32))
+	kms := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(key + "\n"))
+	}))
+	defer kms.Close()
+	cipher, err := server.LoadReplayCipher(server.ReplayEncryptionConfig{KeyURL: kms.URL})
+	if err != nil {
+		t.Fatalf("加载密钥失败: %v", err)
+	}
+
+	if err := cipher.EncryptFile(src); err != nil {
+		t.Fatalf("加密失败: %v", err)
+	}
+	sealed, _ := os.ReadFile(src)
+	if binary.LittleEndian.Uint16(sealed[0:2]) != 0x454D || !bytes.Equal(sealed[2:14], plain[2:14]) {
+		t.Errorf("加密文件头应带加密标识并保留谱面、用户与成绩ID: %x", sealed[:14])
+	}
+	if bytes.Contains(sealed, plain[14:]) {
+		t.Error("加密文件不应包含明文记录")
+	}
+	if err := cipher.EncryptFile(src); err != nil {
+		t.Fatalf("重复加密应跳过: %v", err)
+	}
+
+	opened, err := server.ReadReplayFile(src, cipher)
+	if err != nil || !bytes.Equal(opened, plain) {
+		t.Fatalf("解密结果与原文件不一致: %v", err)
+	}
+	if _, err := server.ReadReplayFile(src, nil); err == nil {
+		t.Error("未配置密钥时读取加密回放应返回错误")
+	}
+	other, _ := server.NewReplayCipher(bytes.Repeat([]byte{8}, 32))
+	if _, err := server.ReadReplayFile(src, other); err == nil {
+		t.Error("密钥不匹配时应返回错误")
+	}
+
+	// 篡改文件头（附加认证数据）后无法解密
+	tampered := append([]byte(nil), sealed...)
+	tampered[10] ^= 1
+	if _, err := cipher.Open(tampered); err == nil {
+		t.Error("文件头被篡改时应返回错误")
+	}
+
+	if _, err := server.ClipReplayFile(src, filepath.Join(dir, "clip.phirarec"), 0, 1); err == nil {
+		t.Error("未提供密钥时不能截取加密回放")
+	}
+
+	// 异常退出遗留的明文回放在登记后加密
+	leftover := filepath.Join(dir, "100", "2", "1730000001000.phirarec")
+	writeReplayFile(t, leftover, 2, 100, 0)
+	index := server.NewReplayIndex(filepath.Join(dir, "index.json"))
+	if added := index.Migrate(dir); added != 2 {
+		t.Fatalf("登记数量不匹配: 期望 2, 实际 %d", added)
+	}
+	if encrypted := index.EncryptPlaintext(cipher); encrypted != 1 {
+		t.Errorf("只应加密遗留的明文回放，实际 %d", encrypted)
+	}
+	if entry := index.Find(100, 2, 1730000001000); entry == nil || !entry.Encrypted {
+		t.Errorf("遗留回放应标记为已加密: %+v", entry)
+	}
+	if data, _ := os.ReadFile(leftover); binary.LittleEndian.Uint16(data[0:2]) != 0x454D {
+		t.Error("遗留回放文件应该被加密")
+	}
+	if _, err := os.Stat(leftover + ".tmp"); !os.IsNotExist(err) {
+		t.Error("加密后不应残留临时文件")
+	}
+}
+
+// TestGameManifest 测试对局清单记录参与玩家、最终成绩与回放文件校验和，以及过期清理与用户数据清除
+func TestGameManifest(t *testing.T) {
+	dir := t.TempDir()
+	startedAt := time.Now().UnixMilli()
+	manifest := &server.GameManifest{
+		Version:   server.GameManifestVersion,
+		RoomID:    "manifest",
+		ChartID:   1,
+		ChartName: "Test Chart",
+		StartedAt: startedAt,
+	}
+
+	for _, userID := range []int32{100, 200} {
+		path := filepath.Join(dir, fmt.Sprint(userID), "1", fmt.Sprintf("%d.phirarec", startedAt))
+		writeReplayFile(t, path, 1, userID, userID+1)
+		entry := &server.ReplayEntry{ID: fmt.Sprintf("r%d", userID), UserID: userID, ChartID: 1, RecordID: int64(userID + 1), Path: path}
+		if err := manifest.AddReplay(dir, fmt.Sprintf("player%d", userID), entry); err != nil {
+			t.Fatalf("登记回放失败: %v", err)
+		}
+	}
+	manifest.SetResults(&server.GameSummary{
+		ChartID:    1,
+		Aggregator: "score",
+		Ranking:    []server.RankEntry{{Rank: 1, UserID: 100, Name: "player100", Score: 1000000}},
+		Aborted:    []int32{200},
+		EndedAt:    startedAt + 120000,
+	})
+	if err := manifest.Save(dir); err != nil {
+		t.Fatalf("保存对局清单失败: %v", err)
+	}
+
+	loaded, err := server.LoadGameManifest(manifest.Path(dir))
+	if err != nil {
+		t.Fatalf("读取对局清单失败: %v", err)
+	}
+	if !loaded.Completed || loaded.EndedAt != startedAt+120000 || len(loaded.Participants) != 2 {
+		t.Fatalf("对局清单不匹配: %+v", loaded)
+	}
+	winner, aborted := loaded.Participants[0], loaded.Participants[1]
+	if winner.Result == nil || winner.Result.Score != 1000000 || winner.RecordID != 101 {
+		t.Errorf("玩家成绩不匹配: %+v", winner)
+	}
+	if !aborted.Aborted || aborted.Result != nil {
+		t.Errorf("放弃的玩家不应有成绩: %+v", aborted)
+	}
+	replay := winner.Replay
+	if replay == nil || replay.ID != "r100" || replay.File != fmt.Sprintf("100/1/%d.phirarec", startedAt) {
+		t.Fatalf("回放文件引用不匹配: %+v", replay)
+	}
+	data, _ := os.ReadFile(filepath.Join(dir, filepath.FromSlash(replay.File)))
+	sum := sha256.Sum256(data)
+	if replay.Size != int64(len(data)) || replay.SHA256 != hex.EncodeToString(sum[:]) {
+		t.Errorf("回放文件校验和不匹配: %+v", replay)
+	}
+
+	// 清除用户数据后清单中只剩其他玩家
+	if n := server.PurgeGameManifestUser(dir, 100); n != 1 {
+		t.Errorf("应修改1个对局清单，实际: %d", n)
+	}
+	if loaded, _ := server.LoadGameManifest(manifest.Path(dir)); loaded == nil || len(loaded.Participants) != 1 || loaded.Participants[0].UserID != 200 {
+		t.Errorf("清除用户后的清单不正确: %+v", loaded)
+	}
+
+	if n := server.PruneGameManifests(dir, time.UnixMilli(startedAt)); n != 0 {
+		t.Errorf("未过期的清单不应被删除: %d", n)
+	}
+	if n := server.PruneGameManifests(dir, time.UnixMilli(startedAt+1)); n != 1 {
+		t.Errorf("应删除1个过期清单，实际: %d", n)
+	}
+	if _, err := os.Stat(manifest.Path(dir)); !os.IsNotExist(err) {
+		t.Error("过期清单应已删除")
+	}
+}
+
+// TestReplayRoom 测试按对局清单创建回放房间：只能以观察者身份加入，跳转后按时间推送各玩家的记录，播放结束后提交清单中的成绩
+func TestReplayRoom(t *testing.T) {
+	config := server.DefaultConfig()
+	config.LiveMode = true
+	config.Monitors = []int32{2}
+	ts := startTestServer(t, config)
+
+	startedAt := time.Now().UnixMilli()
+	manifest := &server.GameManifest{
+		Version:   server.GameManifestVersion,
+		RoomID:    fmt.Sprintf("vodsrc%d", startedAt),
+		ChartID:   1,
+		ChartName: "Test Chart",
+		StartedAt: startedAt,
+	}
+	t.Cleanup(func() { os.RemoveAll(filepath.Dir(filepath.Dir(manifest.Path(server.ReplayDir)))) })
+
+	// 每名玩家在第0、1、2秒各有一条触摸帧与一条Perfect判定
+	for _, userID := range []int32{100, 200} {
+		path := filepath.Join(server.ReplayDir, fmt.Sprint(userID), "1", fmt.Sprintf("%d.phirarec", startedAt))
+		writeReplayFile(t, path, 1, userID, userID+1)
+		t.Cleanup(func() { os.Remove(path) })
+		file, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for second := uint32(0); second < 3; second++ {
+			touch := make([]byte, 10)
+			binary.LittleEndian.PutUint32(touch, second)
+			touch[4] = 1
+			judge := make([]byte, 13)
+			binary.LittleEndian.PutUint32(judge, second)
+			binary.LittleEndian.PutUint32(judge[8:], second)
+			file.Write(append([]byte{0x01, byte(len(touch))}, touch...))
+			file.Write(append([]byte{0x02, byte(len(judge))}, judge...))
+		}
+		file.Close()
+		entry := &server.ReplayEntry{ID: fmt.Sprintf("r%d", userID), UserID: userID, ChartID: 1, Path: path}
+		if err := manifest.AddReplay(server.ReplayDir, fmt.Sprintf("player%d", userID), entry); err != nil {
+			t.Fatalf("登记回放失败: %v", err)
+		}
+	}
+	manifest.SetResults(&server.GameSummary{
+		ChartID:    1,
+		Aggregator: "score",
+		Ranking: []server.RankEntry{
+			{Rank: 1, UserID: 100, Name: "player100", Score: 1000000, Accuracy: 1, MaxCombo: 3},
+			{Rank: 2, UserID: 200, Name: "player200", Score: 900000, Accuracy: 0.9, MaxCombo: 3},
+		},
+		EndedAt: startedAt + 3000,
+	})
+
+	roomID, _ := common.NewRoomId("vod")
+	room, err := ts.NewReplayRoom(roomID, manifest)
+	if err != nil {
+		t.Fatalf("创建回放房间失败: %v", err)
+	}
+	players := room.GetReplayPlayback().Status(room).Players
+	if len(players) != 2 || players[0].UserID != 100 || !players[0].Replay || players[0].ID > server.BotIDBase {
+		t.Fatalf("回放玩家不正确: %+v", players)
+	}
+
+	player := ts.connect(t, 3)
+	player.JoinRoom(roomID, false)
+	monitor := ts.connect(t, 2)
+	monitor.JoinRoom(roomID, true)
+	waitFor(t, "观察者加入", func() bool { return ts.GetUser(2).GetRoom() == room })
+	if ts.GetUser(3).GetRoom() != nil {
+		t.Fatal("不应能以玩家身份加入回放房间")
+	}
+
+	playback := room.GetReplayPlayback()
+	if err := playback.Pause(room); err == nil {
+		t.Error("未开始时不应能暂停")
+	}
+	if err := playback.Seek(room, 1); err != nil {
+		t.Fatalf("跳转失败: %v", err)
+	}
+	if err := playback.Play(room); err != nil {
+		t.Fatalf("开始播放失败: %v", err)
+	}
+
+	// 跳转到第1秒后立即推送第1秒的记录，不推送第0秒的记录
+	waitFor(t, "收到第1秒的记录", func() bool {
+		for _, p := range players {
+			if frames, judges := monitor.LivePlayer(p.ID).Snapshot(); len(frames) == 0 || len(judges) == 0 {
+				return false
+			}
+		}
+		return true
+	})
+	if err := playback.Pause(room); err != nil {
+		t.Fatalf("暂停失败: %v", err)
+	}
+	if status := playback.Status(room); status.State != "paused" || room.GetState() != server.InternalStatePlaying {
+		t.Fatalf("暂停后状态不正确: %+v", status)
+	}
+	for _, p := range players {
+		frames, judges := monitor.LivePlayer(p.ID).Snapshot()
+		if len(frames) != 1 || len(judges) != 1 || frames[0].Time != 1 || len(frames[0].Points) != 1 || judges[0].NoteID != 1 {
+			t.Errorf("玩家 %d 跳转后应只收到第1秒的记录: %+v %+v", p.UserID, frames, judges)
+		}
+	}
+	if err := playback.Play(room); err != nil {
+		t.Fatalf("继续播放失败: %v", err)
+	}
+
+	played := make(map[int32]common.Message)
+	waitFor(t, "播放结束", func() bool {
+		for _, msg := range monitor.TakeMessages() {
+			if msg.Type == common.MsgPlayed {
+				played[msg.User] = msg
+			}
+		}
+		return len(played) == 2 && room.GetState() == server.InternalStateSelectChart
+	})
+	// 判定统计取自完整的回放
+	for _, p := range players {
+		if msg := played[p.ID]; msg.Perfect != 3 || !msg.FullCombo {
+			t.Errorf("玩家 %d 的成绩不正确: %+v", p.UserID, msg)
+		}
+	}
+	if played[players[0].ID].Score != 1000000 || played[players[1].ID].Score != 900000 {
+		t.Errorf("成绩应取自对局清单: %+v", played)
+	}
+	if ts.GetGameHistory().Len() != 0 {
+		t.Error("回放房间的对局不应计入对局历史")
+	}
+}
@@ -0,0 +1,60 @@
+package test
+
+import (
+	"fmt"
+	"testing"
+
+	"phira-mp/common"
+	"phira-mp/server"
+)
+
+// TestListRooms 测试通过游戏协议分页查询房间列表，锁定的房间不出现在列表中
+func TestListRooms(t *testing.T) {
+	ts := startTestServer(t, server.DefaultConfig())
+
+	const rooms = common.RoomListPageSize + 1
+	for i := 0; i < rooms; i++ {
+		host := ts.connect(t, int32(i+1))
+		roomID, _ := common.NewRoomId(fmt.Sprintf("lobby-%02d", i))
+		host.CreateRoom(roomID)
+	}
+	locker := ts.connect(t, rooms+1)
+	lockedID, _ := common.NewRoomId("lobby-locked")
+	locker.CreateRoom(lockedID)
+	waitFor(t, "创建房间", func() bool { return len(ts.GetAllRooms()) == rooms+1 })
+	locker.LockRoom(true)
+	waitFor(t, "锁定房间", func() bool { return ts.GetRoom(lockedID).IsLocked() })
+
+	browser := ts.connect(t, rooms+2)
+	list := func(page uint32) *common.RoomList {
+		t.Helper()
+		if err := browser.ListRooms(page); err != nil {
+			t.Fatalf("查询房间列表失败: %v", err)
+		}
+		waitFor(t, "房间列表", func() bool {
+			l := browser.RoomList()
+			return l != nil && l.Page == page
+		})
+		return browser.RoomList()
+	}
+
+	first := list(0)
+	if first.Total != rooms || len(first.Rooms) != common.RoomListPageSize {
+		t.Fatalf("第一页不正确: total=%d, rooms=%d", first.Total, len(first.Rooms))
+	}
+	entry := first.Rooms[0]
+	if entry.RoomId.Value != "lobby-00" || entry.Host.ID != 1 || entry.Players != 1 || entry.MaxPlayers != server.RoomMaxUsers {
+		t.Errorf("房间信息不匹配: %+v", entry)
+	}
+	if entry.State != uint8(common.RoomStateSelectChart) {
+		t.Errorf("房间状态不匹配: %d", entry.State)
+	}
+
+	second := list(1)
+	if len(second.Rooms) != 1 || second.Rooms[0].RoomId.Value != fmt.Sprintf("lobby-%02d", rooms-1) {
+		t.Errorf("第二页不正确: %+v", second.Rooms)
+	}
+	if empty := list(5); len(empty.Rooms) != 0 || empty.Total != rooms {
+		t.Errorf("超出范围的页应为空: %+v", empty)
+	}
+}
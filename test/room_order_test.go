@@ -0,0 +1,71 @@
+package test
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+
+	"phira-mp/client"
+	"phira-mp/common"
+	"phira-mp/server"
+)
+
+// TestRoomBroadcastOrder 测试多个协程同时广播房间消息时，所有成员收到的顺序相同且与时间戳一致
+func TestRoomBroadcastOrder(t *testing.T) {
+	ts := startTestServer(t, server.DefaultConfig())
+
+	host := ts.connect(t, 1)
+	players := []int32{2, 3}
+	clients := []*client.Client{host}
+	roomID, _ := common.NewRoomId("order")
+	host.CreateRoom(roomID)
+	waitFor(t, "创建房间", func() bool { return ts.GetRoom(roomID) != nil })
+	for _, id := range players {
+		c := ts.connect(t, id)
+		c.JoinRoom(roomID, false)
+		waitFor(t, "加入房间", func() bool { return ts.GetUser(id).GetRoom() != nil })
+		clients = append(clients, c)
+	}
+	room := ts.GetRoom(roomID)
+
+	const senders, perSender = 4, 50
+	var wg sync.WaitGroup
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			for j := 0; j < perSender; j++ {
+				room.SendMessage(common.Message{Type: common.MsgChat, Content: fmt.Sprintf("%d-%d", i, j)})
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	received := make([][]common.Message, len(clients))
+	waitFor(t, "收到全部消息", func() bool {
+		for i, c := range clients {
+			for _, msg := range c.TakeMessages() {
+				if msg.Type == common.MsgChat {
+					received[i] = append(received[i], msg)
+				}
+			}
+			if len(received[i]) < senders*perSender {
+				return false
+			}
+		}
+		return true
+	})
+
+	for i := 1; i < len(received); i++ {
+		for j := range received[0] {
+			if received[i][j].Content != received[0][j].Content {
+				t.Fatalf("成员 %d 第 %d 条消息顺序不同: %s != %s", i, j, received[i][j].Content, received[0][j].Content)
+			}
+		}
+	}
+	for j := 1; j < len(received[0]); j++ {
+		if received[0][j].Time < received[0][j-1].Time {
+			t.Fatalf("消息时间戳应随到达顺序递增: 第 %d 条 %d < %d", j, received[0][j].Time, received[0][j-1].Time)
+		}
+	}
+}
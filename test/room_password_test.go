@@ -0,0 +1,123 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"phira-mp/common"
+	"phira-mp/server"
+)
+
+// TestRoomPassword 测试有密码的房间：未提供或密码错误时无法加入，排队加入被拒绝
+func TestRoomPassword(t *testing.T) {
+	ts := startTestServer(t, server.DefaultConfig())
+
+	host := ts.connect(t, 1)
+	player := ts.connect(t, 2)
+	roomID, _ := common.NewRoomId("password")
+	if err := host.CreateRoomWithPassword(roomID, "secret"); err != nil {
+		t.Fatalf("创建房间失败: %v", err)
+	}
+	waitFor(t, "创建房间", func() bool { return ts.GetRoom(roomID) != nil })
+	room := ts.GetRoom(roomID)
+	if !room.HasPassword() || room.IsLocked() {
+		t.Fatalf("房间应该设置了密码且未锁定")
+	}
+
+	player.JoinRoom(roomID, false)
+	player.JoinRoomWithPassword(roomID, false, "wrong")
+	player.QueueJoin(roomID)
+	time.Sleep(200 * time.Millisecond)
+	if ts.GetUser(2).GetRoom() != nil {
+		t.Fatal("密码错误时不应加入房间")
+	}
+	if code := common.LookupErrorCode(server.ErrWrongPassword); code != common.ErrCodeWrongPassword {
+		t.Errorf("密码错误应对应错误码 %d，实际 %d", common.ErrCodeWrongPassword, code)
+	}
+
+	if err := player.JoinRoomWithPassword(roomID, false, "secret"); err != nil {
+		t.Fatalf("加入房间失败: %v", err)
+	}
+	waitFor(t, "使用密码加入房间", func() bool { return ts.GetUser(2).GetRoom() == room })
+}
+
+// TestSetPassword 测试房主修改房间密码与私密房间：私密房间不出现在房间列表中，仍可通过房间ID与密码加入
+func TestSetPassword(t *testing.T) {
+	ts := startTestServer(t, server.DefaultConfig())
+
+	host := ts.connect(t, 1)
+	player := ts.connect(t, 2)
+	roomID, _ := common.NewRoomId("private")
+	host.CreateRoom(roomID)
+	waitFor(t, "创建房间", func() bool { return ts.GetRoom(roomID) != nil })
+	room := ts.GetRoom(roomID)
+
+	if err := player.SetPassword("secret", true); err != nil {
+		t.Fatalf("发送修改密码失败: %v", err)
+	}
+	time.Sleep(200 * time.Millisecond)
+	if room.HasPassword() || room.IsPrivate() {
+		t.Fatal("不在房间中时不应修改房间密码")
+	}
+
+	if err := host.SetPassword("secret", true); err != nil {
+		t.Fatalf("发送修改密码失败: %v", err)
+	}
+	waitFor(t, "修改房间密码", func() bool { return room.HasPassword() && room.IsPrivate() })
+
+	if err := player.ListRooms(0); err != nil {
+		t.Fatalf("查询房间列表失败: %v", err)
+	}
+	waitFor(t, "房间列表", func() bool { return player.RoomList() != nil })
+	if list := player.RoomList(); list.Total != 0 || len(list.Rooms) != 0 {
+		t.Errorf("私密房间不应出现在房间列表中: %+v", list.Rooms)
+	}
+
+	player.JoinRoomWithPassword(roomID, false, "wrong")
+	time.Sleep(200 * time.Millisecond)
+	if ts.GetUser(2).GetRoom() != nil {
+		t.Fatal("密码错误时不应加入私密房间")
+	}
+	if err := player.JoinRoomWithPassword(roomID, false, "secret"); err != nil {
+		t.Fatalf("加入房间失败: %v", err)
+	}
+	waitFor(t, "通过房间ID加入私密房间", func() bool { return ts.GetUser(2).GetRoom() == room })
+
+	// 非房主不能修改
+	player.SetPassword("", false)
+	time.Sleep(200 * time.Millisecond)
+	if !room.HasPassword() || !room.IsPrivate() {
+		t.Fatal("非房主不应修改房间密码")
+	}
+
+	host.SetPassword("", false)
+	waitFor(t, "取消房间密码", func() bool { return !room.HasPassword() && !room.IsPrivate() })
+}
+
+// TestRoomPasswordBackoff 测试连续输错房间密码后须等待一段时间才能再次尝试，修改密码后重新计数
+func TestRoomPasswordBackoff(t *testing.T) {
+	ts := startTestServer(t, server.DefaultConfig())
+
+	host := ts.connect(t, 1)
+	player := ts.connect(t, 2)
+	roomID, _ := common.NewRoomId("password-backoff")
+	host.CreateRoomWithPassword(roomID, "secret")
+	waitFor(t, "创建房间", func() bool { return ts.GetRoom(roomID) != nil })
+	room := ts.GetRoom(roomID)
+
+	for i := 0; i < 3; i++ {
+		player.JoinRoomWithPassword(roomID, false, "wrong")
+	}
+	player.JoinRoomWithPassword(roomID, false, "secret")
+	time.Sleep(200 * time.Millisecond)
+	if ts.GetUser(2).GetRoom() != nil {
+		t.Fatal("连续输错密码后，退避期间即使密码正确也不应加入")
+	}
+	if code := common.LookupErrorCode(server.ErrPasswordBackoff); code != common.ErrCodeRateLimited {
+		t.Errorf("密码尝试退避应对应错误码 %d，实际 %d", common.ErrCodeRateLimited, code)
+	}
+
+	room.SetPassword("secret")
+	player.JoinRoomWithPassword(roomID, false, "secret")
+	waitFor(t, "修改密码后加入房间", func() bool { return ts.GetUser(2).GetRoom() == room })
+}
@@ -1,10 +1,15 @@
 package test
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"sync"
 	"testing"
 	"time"
 
+	"phira-mp/client"
 	"phira-mp/common"
 	"phira-mp/server"
 )
@@ -184,7 +189,7 @@ func TestRoomStateTransitions(t *testing.T) {
 	}
 
 	// 测试状态转换到客户端状态
-	chartID := int32(123)
+	chartID := int64(123)
 	clientState := room.GetState().ToClientState(&chartID)
 
 	if clientState.Type != common.RoomStatePlaying {
@@ -395,6 +400,39 @@ func TestRoomBroadcast(t *testing.T) {
 	})
 }
 
+// TestRoomMessageTime 测试服务器为房间消息记录时间，同一条广播消息的时间对所有玩家相同
+func TestRoomMessageTime(t *testing.T) {
+	ts := startTestServer(t, server.DefaultConfig())
+	host, player := ts.connect(t, 1), ts.connect(t, 2)
+	roomID, _ := common.NewRoomId("msg-time")
+	host.CreateRoom(roomID)
+	waitFor(t, "创建房间", func() bool { return ts.GetRoom(roomID) != nil })
+
+	before := time.Now().UnixMilli()
+	player.JoinRoom(roomID, false)
+	var hostMsg, playerMsg *common.Message
+	waitFor(t, "加入消息", func() bool {
+		for _, pair := range []struct {
+			c   *client.Client
+			msg **common.Message
+		}{{host, &hostMsg}, {player, &playerMsg}} {
+			for _, msg := range pair.c.TakeMessages() {
+				if msg.Type == common.MsgJoinRoom && msg.User == 2 {
+					msg := msg
+					*pair.msg = &msg
+				}
+			}
+		}
+		return hostMsg != nil && playerMsg != nil
+	})
+	if hostMsg.Time < before || hostMsg.Time > time.Now().UnixMilli() {
+		t.Errorf("消息时间不正确: %d，应在 %d 之后", hostMsg.Time, before)
+	}
+	if hostMsg.Time != playerMsg.Time {
+		t.Errorf("同一条消息的时间应相同: %d %d", hostMsg.Time, playerMsg.Time)
+	}
+}
+
 // TestRoomOnUserLeave 测试用户离开处理
 func TestRoomOnUserLeave(t *testing.T) {
 	config := server.DefaultConfig()
@@ -508,6 +546,11 @@ func TestRoomClientRoomState(t *testing.T) {
 	if user2State.IsHost {
 		t.Error("普通用户不应该识别为host")
 	}
+
+	// 选择谱面阶段没有已准备玩家
+	if len(hostState.ReadyUsers) != 0 {
+		t.Errorf("选择谱面阶段不应有已准备玩家，实际: %v", hostState.ReadyUsers)
+	}
 }
 
 // TestRoomCheckAllReady 测试检查所有玩家准备
@@ -573,4 +616,840 @@ func TestRoomLiveMode(t *testing.T) {
 	if room.IsLive() {
 		t.Error("房间不应该处于直播模式")
 	}
-}
\ No newline at end of file
+}
+
+// TestOfficialRoomTemplates 测试官方房间模板
+func TestOfficialRoomTemplates(t *testing.T) {
+	config := server.DefaultConfig()
+	config.RoomTemplates = []server.RoomTemplate{
+		{ID: "official-1", MaxUsers: 2, Cycle: true},
+		{ID: "official-fixed", ChartPolicy: server.ChartPolicyFixed, ChartID: 1},
+		{ID: "official-nochart", ChartPolicy: server.ChartPolicyFixed, ChartID: 0},
+	}
+	useFakeChartAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": 1, "name": "Fixed"})
+	})
+	t.Cleanup(func() { server.ConfigurePhiraAPI(server.DefaultPhiraAPIConfig()) })
+	srv := server.NewServer(config)
+	srv.EnsureOfficialRooms()
+
+	roomID, _ := common.NewRoomId("official-1")
+	room := srv.GetRoom(roomID)
+	if room == nil {
+		t.Fatal("官方房间应该在启动时创建")
+	}
+	if !room.IsOfficial() {
+		t.Error("房间应该标记为官方房间")
+	}
+	if room.GetMaxUsers() != 2 {
+		t.Errorf("最大人数不匹配，期望: 2, 实际: %d", room.GetMaxUsers())
+	}
+	if !room.IsCycle() {
+		t.Error("官方房间应该继承模板的循环设置")
+	}
+	if len(room.GetUsers()) != 0 {
+		t.Errorf("官方房间初始应为空，实际: %d", len(room.GetUsers()))
+	}
+
+	fixedID, _ := common.NewRoomId("official-fixed")
+	if fixed := srv.GetRoom(fixedID); fixed == nil || !fixed.IsChartFixed() {
+		t.Error("fixed策略的官方房间应该固定谱面")
+	}
+	noChartID, _ := common.NewRoomId("official-nochart")
+	if srv.GetRoom(noChartID) != nil {
+		t.Error("fixed策略但未填写谱面的模板不应创建房间")
+	}
+
+	// 移除后应自动重建
+	srv.RemoveRoom(roomID, "房间为空")
+	recreated := srv.GetRoom(roomID)
+	if recreated == nil {
+		t.Fatal("官方房间移除后应该自动重建")
+	}
+	if recreated == room {
+		t.Error("重建的官方房间应该是新实例")
+	}
+
+	// 重复调用不应覆盖已存在的房间
+	srv.EnsureOfficialRooms()
+	if srv.GetRoom(roomID) != recreated {
+		t.Error("EnsureOfficialRooms 不应替换已存在的房间")
+	}
+}
+
+// TestRoomQueue 测试房间等待队列
+func TestRoomQueue(t *testing.T) {
+	config := server.DefaultConfig()
+	config.RoomQueueSize = 2
+	srv := server.NewServer(config)
+
+	host := server.NewUser(1, "Host", "zh-CN", srv)
+	srv.AddUser(host)
+	roomID, _ := common.NewRoomId("test-room-queue")
+	room := server.NewRoom(roomID, host, srv)
+	srv.AddRoom(room)
+
+	user2 := server.NewUser(2, "User2", "zh-CN", srv)
+	user3 := server.NewUser(3, "User3", "zh-CN", srv)
+	user4 := server.NewUser(4, "User4", "zh-CN", srv)
+
+	if pos := room.Enqueue(user2); pos != 1 {
+		t.Errorf("排队位置不匹配，期望: 1, 实际: %d", pos)
+	}
+	if pos := room.Enqueue(user3); pos != 2 {
+		t.Errorf("排队位置不匹配，期望: 2, 实际: %d", pos)
+	}
+	if pos := room.Enqueue(user2); pos != 1 {
+		t.Errorf("重复排队应返回原位置，实际: %d", pos)
+	}
+	if pos := room.Enqueue(user4); pos != 0 {
+		t.Errorf("队列已满时应拒绝排队，实际位置: %d", pos)
+	}
+	if user2.GetQueuedRoom() != room {
+		t.Error("用户应记录正在排队的房间")
+	}
+
+	// 退出队列后位置前移
+	if !user2.LeaveQueue() {
+		t.Error("排队中的用户应能退出队列")
+	}
+	if user2.LeaveQueue() {
+		t.Error("不在队列中的用户退出队列应返回false")
+	}
+	queue := room.GetQueue()
+	if len(queue) != 1 || queue[0].ID != 3 {
+		t.Errorf("退出后队列不匹配: %v", queue)
+	}
+
+	// 无会话的排队用户在放行时被跳过
+	room.AdmitQueued()
+	if len(room.GetQueue()) != 0 {
+		t.Error("放行后队列应为空")
+	}
+	if user3.GetRoom() != nil {
+		t.Error("无会话的用户不应被放入房间")
+	}
+
+	// 设置密码时清空队列（放行时无法校验密码）
+	room.Enqueue(user4)
+	room.SetPassword("secret")
+	if len(room.GetQueue()) != 0 || user4.GetQueuedRoom() != nil {
+		t.Error("设置密码后应清空等待队列")
+	}
+	room.SetPassword("")
+
+	// 房间移除时清空队列
+	room.Enqueue(user4)
+	srv.RemoveRoom(roomID, "测试")
+	if user4.GetQueuedRoom() != nil {
+		t.Error("房间移除后用户不应再处于排队状态")
+	}
+}
+
+// TestRoomQueueConcurrentLeave 测试多名玩家同时离开、放行并发执行时，排队用户不会被取出后因空位被抢占而丢失
+func TestRoomQueueConcurrentLeave(t *testing.T) {
+	config := server.DefaultConfig()
+	config.RoomQueueSize = 4
+	ts := startTestServer(t, config)
+
+	host := ts.connect(t, 1)
+	roomID, _ := common.NewRoomId("queue-race")
+	host.CreateRoom(roomID)
+	waitFor(t, "创建房间", func() bool { return ts.GetRoom(roomID) != nil })
+	room := ts.GetRoom(roomID)
+	room.SetMaxUsers(3)
+
+	ts.connect(t, 2).JoinRoom(roomID, false)
+	ts.connect(t, 3).JoinRoom(roomID, false)
+	waitFor(t, "玩家加入", func() bool { return len(room.GetUsers()) == 3 })
+
+	queued := []int32{4, 5, 6, 7}
+	for _, id := range queued {
+		ts.connect(t, id).QueueJoin(roomID)
+		want := len(room.GetQueue()) + 1
+		waitFor(t, "加入等待队列", func() bool { return len(room.GetQueue()) == want })
+	}
+
+	// 两名玩家同时离开后，各自触发的放行与其他来源的放行并发执行
+	room.RemoveUser(2)
+	room.RemoveUser(3)
+	start := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+			room.AdmitQueued()
+		}()
+	}
+	close(start)
+	wg.Wait()
+
+	waitFor(t, "排队放行", func() bool { return len(room.GetUsers()) == 3 && len(room.GetQueue()) == 2 })
+	time.Sleep(200 * time.Millisecond)
+	if n := len(room.GetUsers()); n != 3 {
+		t.Errorf("房间人数不应超过上限，实际 %d", n)
+	}
+	for i, id := range queued {
+		user := ts.GetUser(id)
+		inRoom := user.GetRoom() == room
+		inQueue := user.GetQueuedRoom() == room
+		if inRoom == inQueue {
+			t.Errorf("排队用户 %d 应恰好在房间或队列之一（房间: %v, 队列: %v）", id, inRoom, inQueue)
+		}
+		if wantRoom := i < 2; inRoom != wantRoom {
+			t.Errorf("排队用户 %d 的放行顺序不正确（在房间: %v）", id, inRoom)
+		}
+	}
+}
+
+// TestRoomOverflow 测试满员转观察者设置
+func TestRoomOverflow(t *testing.T) {
+	config := server.DefaultConfig()
+	srv := server.NewServer(config)
+
+	host := server.NewUser(1, "Host", "zh-CN", srv)
+	roomID, _ := common.NewRoomId("test-room-overflow")
+	room := server.NewRoom(roomID, host, srv)
+	room.SetMaxUsers(2)
+
+	if room.IsOverflow() {
+		t.Error("新房间不应该开启满员转观察者")
+	}
+	room.SetOverflow(true)
+	if !room.IsOverflow() {
+		t.Error("房间应该开启满员转观察者")
+	}
+
+	if room.IsFull() {
+		t.Error("房间不应该已满")
+	}
+	room.AddUser(server.NewUser(2, "User2", "zh-CN", srv), false)
+	if !room.IsFull() {
+		t.Error("房间应该已满")
+	}
+
+	// 观察者不占用玩家位置
+	room.AddUser(server.NewUser(3, "Watcher", "zh-CN", srv), true)
+	if len(room.GetUsers()) != 2 || len(room.GetMonitors()) != 1 {
+		t.Errorf("人数不匹配，玩家: %d, 观察者: %d", len(room.GetUsers()), len(room.GetMonitors()))
+	}
+}
+
+func TestRoomSwitchRole(t *testing.T) {
+	config := server.DefaultConfig()
+	srv := server.NewServer(config)
+
+	host := server.NewUser(1, "Host", "zh-CN", srv)
+	roomID, _ := common.NewRoomId("test-room-switch")
+	room := server.NewRoom(roomID, host, srv)
+	room.SetMaxUsers(2)
+
+	player := server.NewUser(2, "Player", "zh-CN", srv)
+	watcher := server.NewUser(3, "Watcher", "zh-CN", srv)
+	room.AddUser(player, false)
+	room.AddUser(watcher, true)
+	watcher.SetMonitor(true)
+
+	// 房间已满时观察者无法转为玩家
+	if room.SwitchRole(watcher, false) {
+		t.Error("房间已满时不应该能转为玩家")
+	}
+
+	if !room.SwitchRole(player, true) {
+		t.Fatal("玩家应该能转为观察者")
+	}
+	if !player.IsMonitor() {
+		t.Error("玩家应该被标记为观察者")
+	}
+	if len(room.GetUsers()) != 1 || len(room.GetMonitors()) != 2 {
+		t.Errorf("人数不匹配，玩家: %d, 观察者: %d", len(room.GetUsers()), len(room.GetMonitors()))
+	}
+
+	if !room.SwitchRole(watcher, false) {
+		t.Fatal("有空位时观察者应该能转为玩家")
+	}
+	if watcher.IsMonitor() {
+		t.Error("观察者应该被标记为玩家")
+	}
+	if len(room.GetUsers()) != 2 || len(room.GetMonitors()) != 1 {
+		t.Errorf("人数不匹配，玩家: %d, 观察者: %d", len(room.GetUsers()), len(room.GetMonitors()))
+	}
+
+	// 不在对应列表中的用户切换失败
+	if room.SwitchRole(watcher, false) {
+		t.Error("已是玩家的用户不应该再次转为玩家")
+	}
+}
+
+// TestRoomLiveLastMonitorLeave 测试最后一个观察者离开后关闭直播
+func TestRoomLiveLastMonitorLeave(t *testing.T) {
+	config := server.DefaultConfig()
+	config.LiveMode = true
+	srv := server.NewServer(config)
+
+	host := server.NewUser(1, "Host", "zh-CN", srv)
+	roomID, _ := common.NewRoomId("test-room-live")
+	room := server.NewRoom(roomID, host, srv)
+
+	watchers := []*server.User{
+		server.NewUser(2, "Watcher1", "zh-CN", srv),
+		server.NewUser(3, "Watcher2", "zh-CN", srv),
+	}
+	for _, w := range watchers {
+		room.AddUser(w, true)
+		w.SetMonitor(true)
+		w.SetRoom(room)
+	}
+	room.SetLive(true)
+
+	room.OnUserLeave(watchers[0])
+	if !room.IsLive() {
+		t.Error("仍有观察者时应该保持直播")
+	}
+
+	room.OnUserLeave(watchers[1])
+	if room.IsLive() {
+		t.Error("最后一个观察者离开后应该关闭直播")
+	}
+}
+
+// nopTap 忽略所有数据的内部订阅者
+type nopTap struct{}
+
+func (nopTap) OnTouches(*server.Room, int32, []common.TouchFrame) {}
+func (nopTap) OnJudges(*server.Room, int32, []common.JudgeEvent)  {}
+
+// TestRoomTapKeepsLive 测试内部订阅者（回放录制器）使房间保持直播且不出现在用户列表中
+func TestRoomTapKeepsLive(t *testing.T) {
+	config := server.DefaultConfig()
+	config.LiveMode = true
+	srv := server.NewServer(config)
+
+	host := server.NewUser(1, "Host", "zh-CN", srv)
+	roomID, _ := common.NewRoomId("test-room-tap")
+	room := server.NewRoom(roomID, host, srv)
+
+	tap := nopTap{}
+	room.AddTap(tap)
+	if len(room.GetAllUsers()) != 1 {
+		t.Errorf("内部订阅者不应该出现在用户列表中，实际人数 %d", len(room.GetAllUsers()))
+	}
+
+	watcher := server.NewUser(2, "Watcher", "zh-CN", srv)
+	room.AddUser(watcher, true)
+	watcher.SetMonitor(true)
+	watcher.SetRoom(room)
+	room.SetLive(true)
+
+	room.OnUserLeave(watcher)
+	if !room.IsLive() {
+		t.Error("有内部订阅者时观察者离开后应该保持直播")
+	}
+
+	// 移除订阅者后，下一次观察者变动时关闭直播
+	room.RemoveTap(tap)
+	room.AddUser(watcher, true)
+	watcher.SetRoom(room)
+	room.OnUserLeave(watcher)
+	if room.IsLive() {
+		t.Error("没有订阅者与观察者时应该关闭直播")
+	}
+}
+
+// TestRoomMaxMonitors 测试房间观察者上限
+func TestRoomMaxMonitors(t *testing.T) {
+	config := server.DefaultConfig()
+	config.MaxMonitors = 1
+	srv := server.NewServer(config)
+
+	host := server.NewUser(1, "Host", "zh-CN", srv)
+	roomID, _ := common.NewRoomId("test-room-monitors")
+	room := server.NewRoom(roomID, host, srv)
+
+	if room.GetMaxMonitors() != 1 {
+		t.Errorf("默认观察者上限不匹配: 期望 1, 实际 %d", room.GetMaxMonitors())
+	}
+
+	first := server.NewUser(2, "First", "zh-CN", srv)
+	second := server.NewUser(3, "Second", "zh-CN", srv)
+	if !room.AddUser(first, true) {
+		t.Fatal("未达上限时观察者应该能加入")
+	}
+	if !room.IsMonitorsFull() || room.AddUser(second, true) {
+		t.Error("达到上限后观察者不应该能加入")
+	}
+
+	// 达到上限时玩家也不能转为观察者
+	room.AddUser(second, false)
+	if room.SwitchRole(second, true) {
+		t.Error("观察者已满时玩家不应该能转为观察者")
+	}
+
+	// 房间设置覆盖默认值，0恢复默认
+	room.SetMaxMonitors(2)
+	if room.IsMonitorsFull() || !room.SwitchRole(second, true) {
+		t.Error("调高上限后玩家应该能转为观察者")
+	}
+	room.SetMaxMonitors(0)
+	if room.GetMaxMonitors() != 1 {
+		t.Errorf("恢复默认后观察者上限不匹配: 期望 1, 实际 %d", room.GetMaxMonitors())
+	}
+}
+
+// TestRoomUniqueIP 测试房间同IP限制
+func TestRoomUniqueIP(t *testing.T) {
+	config := server.DefaultConfig()
+	srv := server.NewServer(config)
+
+	host := server.NewUser(1, "Host", "zh-CN", srv)
+	host.SetIP("203.0.113.10")
+	roomID, _ := common.NewRoomId("test-room-ip")
+	room := server.NewRoom(roomID, host, srv)
+
+	smurf := server.NewUser(2, "Smurf", "zh-CN", srv)
+	smurf.SetIP("203.0.113.10")
+	other := server.NewUser(3, "Other", "zh-CN", srv)
+	other.SetIP("198.51.100.7")
+
+	// 未启用时不限制
+	if room.FindSameIP(smurf) != nil {
+		t.Error("未启用同IP限制时不应该拒绝")
+	}
+
+	room.SetUniqueIP(true)
+	if found := room.FindSameIP(smurf); found == nil || found.ID != host.ID {
+		t.Error("启用后应该找到相同IP的用户")
+	}
+	if room.FindSameIP(other) != nil {
+		t.Error("不同IP的用户不应该被拒绝")
+	}
+
+	// 豁免名单内的用户可以共用IP
+	room.SetIPExempt([]int32{2})
+	if room.FindSameIP(smurf) != nil {
+		t.Error("豁免用户不应该被拒绝")
+	}
+	if exempt := room.GetIPExempt(); len(exempt) != 1 || exempt[0] != 2 {
+		t.Errorf("豁免名单不匹配: %v", exempt)
+	}
+}
+
+// TestMaskIP 测试IP打码
+func TestMaskIP(t *testing.T) {
+	cases := map[string]string{
+		"203.0.113.10":          "203.0.*.*",
+		"2001:db8:85a3:1::8a2e": "2001:db8:85a3:1::/64",
+		"::ffff:198.51.100.7":   "198.51.*.*",
+		"not-an-ip":             "",
+	}
+	for ip, want := range cases {
+		if got := server.MaskIP(ip); got != want {
+			t.Errorf("MaskIP(%q) = %q, 期望 %q", ip, got, want)
+		}
+	}
+}
+
+// TestRoomListETag 测试房间列表版本号随房间变化更新
+func TestRoomListETag(t *testing.T) {
+	srv := server.NewServer(server.DefaultConfig())
+	host := server.NewUser(1, "Host", "zh-CN", srv)
+	srv.AddUser(host)
+
+	etag := srv.RoomListETag()
+	if srv.RoomListETag() != etag {
+		t.Fatal("房间列表未变化时ETag不应该改变")
+	}
+
+	roomID, _ := common.NewRoomId("etag-room")
+	srv.AddRoom(server.NewRoom(roomID, host, srv))
+	created := srv.RoomListETag()
+	if created == etag {
+		t.Error("创建房间后ETag应该改变")
+	}
+
+	srv.RemoveRoom(roomID, "")
+	if srv.RoomListETag() == created {
+		t.Error("移除房间后ETag应该改变")
+	}
+}
+
+// TestMonitorChat 测试观察者聊天只投递给房间内的观察者
+func TestMonitorChat(t *testing.T) {
+	config := server.DefaultConfig()
+	config.LiveMode = true
+	config.Monitors = []int32{2, 3}
+	config.MonitorChat = true
+	ts := startTestServer(t, config)
+
+	player := ts.connect(t, 1)
+	monitorA := ts.connect(t, 2)
+	monitorB := ts.connect(t, 3)
+
+	roomID, _ := common.NewRoomId("monitor-chat")
+	player.CreateRoom(roomID)
+	waitFor(t, "创建房间", func() bool { return ts.GetRoom(roomID) != nil })
+	monitorA.JoinRoom(roomID, true)
+	monitorB.JoinRoom(roomID, true)
+	waitFor(t, "观察者加入", func() bool { return len(ts.GetRoom(roomID).GetMonitors()) == 2 })
+
+	// 玩家不能使用观察者聊天
+	player.MonitorChat("hello")
+	monitorA.MonitorChat("gg")
+
+	var got []common.Message
+	waitFor(t, "收到观察者聊天", func() bool {
+		for _, msg := range monitorB.TakeMessages() {
+			if msg.Type == common.MsgMonitorChat {
+				got = append(got, msg)
+			}
+		}
+		return len(got) > 0
+	})
+	if got[0].User != 2 || got[0].Content != "gg" {
+		t.Errorf("观察者聊天内容不匹配: %+v", got[0])
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	for _, msg := range append(player.TakeMessages(), monitorB.TakeMessages()...) {
+		if msg.Type == common.MsgMonitorChat {
+			t.Errorf("不应收到的观察者聊天: %+v", msg)
+		}
+	}
+}
+
+// TestRoomClosedNotification 测试房间移除时通知仍在房间内的观察者
+func TestRoomClosedNotification(t *testing.T) {
+	config := server.DefaultConfig()
+	config.LiveMode = true
+	config.Monitors = []int32{2}
+	ts := startTestServer(t, config)
+
+	player := ts.connect(t, 1)
+	monitor := ts.connect(t, 2)
+
+	roomID, _ := common.NewRoomId("closing")
+	player.CreateRoom(roomID)
+	waitFor(t, "创建房间", func() bool { return ts.GetRoom(roomID) != nil })
+	monitor.JoinRoom(roomID, true)
+	waitFor(t, "观察者加入", func() bool { return monitor.RoomState() != nil })
+
+	// 房主离开后房间只剩观察者，房间被移除
+	player.LeaveRoom()
+	waitFor(t, "房间关闭通知", func() bool { return monitor.RoomClosed() != nil })
+
+	closed := monitor.RoomClosed()
+	if closed.RoomId != roomID || closed.Reason != "房间为空" {
+		t.Errorf("房间关闭通知不匹配: %+v", closed)
+	}
+	if monitor.RoomState() != nil {
+		t.Error("收到关闭通知后客户端不应仍在房间内")
+	}
+	if ts.GetRoom(roomID) != nil {
+		t.Error("房间应已移除")
+	}
+	if u := ts.GetUser(2); u == nil || u.GetRoom() != nil {
+		t.Error("观察者应已被移出房间")
+	}
+}
+
+// TestSelectChartAsync 测试谱面查询在工作池中进行，不阻塞接收循环
+func TestSelectChartAsync(t *testing.T) {
+	ts := startTestServer(t, server.ServerConfig{FetchWorkers: 2, DefaultMaxUsers: 8})
+
+	release := make(chan struct{})
+	useFakeChartAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		json.NewEncoder(w).Encode(map[string]interface{}{"id": 7, "name": "Slow"})
+	})
+	t.Cleanup(func() {
+		select {
+		case <-release:
+		default:
+			close(release)
+		}
+	})
+
+	host := ts.connect(t, 1)
+	roomID, _ := common.NewRoomId("async-chart")
+	if err := host.CreateRoom(roomID); err != nil {
+		t.Fatalf("创建房间失败: %v", err)
+	}
+	waitFor(t, "房间创建", func() bool { return ts.GetRoom(roomID) != nil })
+	room := ts.GetRoom(roomID)
+
+	if err := host.SelectChart(7); err != nil {
+		t.Fatalf("选择谱面失败: %v", err)
+	}
+	// 谱面查询未完成时，后续命令照常处理
+	if err := host.LockRoom(true); err != nil {
+		t.Fatalf("锁定房间失败: %v", err)
+	}
+	waitFor(t, "查询期间处理其他命令", room.IsLocked)
+	if room.GetChart() != nil {
+		t.Fatal("谱面查询完成前不应设置谱面")
+	}
+
+	close(release)
+	waitFor(t, "谱面设置", func() bool {
+		chart := room.GetChart()
+		return chart != nil && chart.ID == 7 && chart.Name == "Slow"
+	})
+}
+
+// useFakeChartAPI 替换模拟主站，/chart/* 由 chart 处理，/me 与 startTestServer 相同
+func useFakeChartAPI(t *testing.T, chart http.HandlerFunc) {
+	t.Helper()
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/me":
+			serveFakeMe(w, r)
+		case strings.HasPrefix(r.URL.Path, "/chart/"):
+			chart(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(api.Close)
+	server.ConfigurePhiraAPI(server.PhiraAPIConfig{BaseURL: api.URL, Timeout: 5})
+}
+
+// TestChartPreview 测试选择谱面时随 MsgSelectChart 下发谱面预览
+func TestChartPreview(t *testing.T) {
+	ts := startTestServer(t, server.ServerConfig{DefaultMaxUsers: 8})
+	useFakeChartAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"id":           9,
+			"name":         "Preview",
+			"level":        "IN Lv.15",
+			"difficulty":   15.2,
+			"charter":      "someone",
+			"composer":     "composer",
+			"illustrator":  "artist",
+			"illustration": "https://example.com/illust.png",
+		})
+	})
+
+	host := ts.connect(t, 1)
+	roomID, _ := common.NewRoomId("chart-preview")
+	host.CreateRoom(roomID)
+	waitFor(t, "房间创建", func() bool { return ts.GetRoom(roomID) != nil })
+	if host.ChartPreview() != nil {
+		t.Fatal("选择谱面前不应有预览")
+	}
+
+	host.SelectChart(9)
+	waitFor(t, "收到谱面预览", func() bool { return host.ChartPreview() != nil })
+	preview := host.ChartPreview()
+	if preview.ID != 9 || preview.Level != "IN Lv.15" || preview.Difficulty != 15.2 || preview.Composer != "composer" ||
+		preview.Illustration != "https://example.com/illust.png" {
+		t.Errorf("谱面预览不正确: %+v", preview)
+	}
+	if level := host.ChartLevel(); level == nil || level.Name != "IN" || level.Level != "15" || level.String() != "IN 15" {
+		t.Errorf("谱面难度不正确: %+v", level)
+	}
+	if chart := ts.GetRoom(roomID).GetChart(); chart.DifficultyName != "IN" || chart.LevelNumber != "15" {
+		t.Errorf("房间谱面的难度不正确: %+v", chart)
+	}
+}
+
+// TestFrameBatch 测试合并发送的触摸帧与判定被拆分转发给观察者
+func TestFrameBatch(t *testing.T) {
+	config := server.DefaultConfig()
+	config.LiveMode = true
+	config.Monitors = []int32{2}
+	ts := startTestServer(t, config)
+
+	player := ts.connect(t, 1)
+	monitor := ts.connect(t, 2)
+
+	roomID, _ := common.NewRoomId("frame-batch")
+	player.CreateRoom(roomID)
+	waitFor(t, "创建房间", func() bool { return ts.GetRoom(roomID) != nil })
+	monitor.JoinRoom(roomID, true)
+	waitFor(t, "观察者加入", func() bool { return ts.GetRoom(roomID).IsLive() })
+
+	frames := []common.TouchFrame{{Time: 1.5, Points: []common.TouchPoint{{ID: 0, Pos: common.NewCompactPos(0.5, 0.5)}}}}
+	judges := []common.JudgeEvent{{Time: 1.5, LineID: 1, NoteID: 2, Judgement: common.JudgementPerfect}}
+	if err := player.SendFrameBatch(frames, judges); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+
+	var gotFrames []common.TouchFrame
+	var gotJudges []common.JudgeEvent
+	waitFor(t, "观察者收到触摸帧与判定", func() bool {
+		gotFrames, gotJudges = monitor.LivePlayer(1).Snapshot()
+		return len(gotFrames) == 1 && len(gotJudges) == 1
+	})
+	if gotFrames[0].Time != 1.5 || gotJudges[0].NoteID != 2 {
+		t.Errorf("转发的数据不正确: %+v %+v", gotFrames, gotJudges)
+	}
+}
+
+// TestScoreUpdate 测试对局中的实时成绩转发给观察者，不在对局中或无效的成绩不转发
+func TestScoreUpdate(t *testing.T) {
+	config := server.DefaultConfig()
+	config.LiveMode = true
+	config.Monitors = []int32{2}
+	ts := startTestServer(t, config)
+
+	player := ts.connect(t, 1)
+	monitor := ts.connect(t, 2)
+
+	roomID, _ := common.NewRoomId("live-score")
+	player.CreateRoom(roomID)
+	waitFor(t, "创建房间", func() bool { return ts.GetRoom(roomID) != nil })
+	monitor.JoinRoom(roomID, true)
+	waitFor(t, "观察者加入", func() bool { return ts.GetRoom(roomID).IsLive() })
+
+	// 选谱阶段的成绩不转发
+	if err := player.SendScoreUpdate(1000, 1, 1); err != nil {
+		t.Fatalf("发送失败: %v", err)
+	}
+	time.Sleep(100 * time.Millisecond)
+	ts.GetRoom(roomID).SetState(server.InternalStatePlaying)
+	// 无效的准确率不转发
+	player.SendScoreUpdate(2000, 2, 1.5)
+	time.Sleep(150 * time.Millisecond)
+	player.SendScoreUpdate(512345, 87, 0.985)
+
+	var got common.LiveScore
+	waitFor(t, "观察者收到实时成绩", func() bool {
+		got = monitor.LivePlayer(1).LiveScore()
+		return got.Score != 0
+	})
+	if got.Score != 512345 || got.Combo != 87 || got.Accuracy != 0.985 {
+		t.Errorf("转发的实时成绩不正确: %+v", got)
+	}
+}
+
+// TestMonitorFollowHost 测试观察者自动关注房主：加入时得知房主，房主变更时切换，只收到房主的实时数据
+func TestMonitorFollowHost(t *testing.T) {
+	config := server.DefaultConfig()
+	config.LiveMode = true
+	config.Monitors = []int32{2}
+	ts := startTestServer(t, config)
+
+	host := ts.connect(t, 1)
+	monitor := ts.connect(t, 2)
+	player := ts.connect(t, 3)
+
+	roomID, _ := common.NewRoomId("follow")
+	host.CreateRoom(roomID)
+	waitFor(t, "创建房间", func() bool { return ts.GetRoom(roomID) != nil })
+	player.JoinRoom(roomID, false)
+	monitor.JoinRoom(roomID, true)
+	waitFor(t, "观察者得知房主", func() bool { return monitor.Host() == 1 })
+
+	updates := monitor.FollowHost(true)
+	next := func() client.FollowUpdate {
+		t.Helper()
+		select {
+		case u := <-updates:
+			return u
+		case <-time.After(3 * time.Second):
+			t.Fatal("等待关注数据超时")
+			return client.FollowUpdate{}
+		}
+	}
+	if u := next(); !u.Switched || u.Player != 1 || monitor.Followed() != 1 {
+		t.Fatalf("开启后应关注房主: %+v", u)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	ts.GetRoom(roomID).SetState(server.InternalStatePlaying)
+	player.SendScoreUpdate(1000, 1, 1)
+	host.SendTouches([]common.TouchFrame{{Time: 1}})
+	host.SendScoreUpdate(2000, 2, 1)
+	if u := next(); u.Player != 1 || len(u.Touches) != 1 {
+		t.Errorf("应收到房主的触摸帧: %+v", u)
+	}
+	if u := next(); u.Player != 1 || u.Score == nil || u.Score.Score != 2000 {
+		t.Errorf("应收到房主的实时成绩（其他玩家的数据不转发）: %+v", u)
+	}
+
+	// 房主离开后关注新房主，切换通知附带已收到的本局数据
+	host.LeaveRoom()
+	u := next()
+	if !u.Switched || u.Player != 3 || monitor.Host() != 3 {
+		t.Fatalf("房主变更后应关注新房主: %+v", u)
+	}
+	if u.Score == nil || u.Score.Score != 1000 || monitor.FollowedPlayer().LiveScore().Score != 1000 {
+		t.Errorf("切换通知应附带新房主已有的实时成绩: %+v", u)
+	}
+
+	if monitor.FollowHost(false) != nil || monitor.Followed() != 0 {
+		t.Error("关闭后不应再关注")
+	}
+	if _, ok := <-updates; ok {
+		t.Error("关闭后通道应被关闭")
+	}
+}
+
+// TestValidateChart 测试谱面预检不修改房间谱面，并报告官方房间的定数限制
+func TestValidateChart(t *testing.T) {
+	config := server.DefaultConfig()
+	config.RoomTemplates = []server.RoomTemplate{{ID: "ranked", MaxDifficulty: 13}}
+	ts := startTestServer(t, config)
+	useFakeChartAPI(t, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/chart/1":
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": 1, "name": "Easy", "difficulty": 12.5})
+		case "/chart/2":
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": 2, "name": "Hard", "difficulty": 15.8})
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	host := ts.connect(t, 1)
+	member := ts.connect(t, 2)
+	roomID, _ := common.NewRoomId("ranked")
+	host.JoinRoom(roomID, false)
+	waitFor(t, "房主加入", func() bool { return host.IsHost() })
+	member.JoinRoom(roomID, false)
+	waitFor(t, "成员加入", func() bool { return len(ts.GetRoom(roomID).GetUsers()) == 2 })
+
+	validate := func(c *client.Client, chartID int64) *common.Result[common.ChartValidation] {
+		t.Helper()
+		before := c.ChartValidation()
+		if err := c.ValidateChart(chartID); err != nil {
+			t.Fatalf("发送预检失败: %v", err)
+		}
+		var result *common.Result[common.ChartValidation]
+		waitFor(t, "收到预检结果", func() bool {
+			result = c.ChartValidation()
+			return result != nil && (before == nil || result.Ok != before.Ok || result.Err != before.Err)
+		})
+		return result
+	}
+
+	if result := validate(host, 1); result.Ok == nil || result.Ok.Reason != "" || result.Ok.Chart.Name != "Easy" {
+		t.Errorf("符合限制的谱面应可选择: %+v", result.Ok)
+	}
+	if result := validate(host, 2); result.Ok == nil || !strings.Contains(result.Ok.Reason, "定数") {
+		t.Errorf("超出定数上限的谱面应报告原因: %+v", result.Ok)
+	}
+	if result := validate(member, 1); result.Ok == nil || result.Ok.Reason != "只有房主可以选择谱面" {
+		t.Errorf("非房主预检应报告原因: %+v", result.Ok)
+	}
+	if result := validate(host, 3); result.Err == nil || *result.Err != "谱面不存在" {
+		t.Errorf("不存在的谱面应返回错误: %+v", result)
+	}
+	if ts.GetRoom(roomID).GetChart() != nil {
+		t.Error("预检不应修改房间谱面")
+	}
+
+	// SelectChart 同样受定数限制
+	host.SelectChart(2)
+	time.Sleep(200 * time.Millisecond)
+	if ts.GetRoom(roomID).GetChart() != nil {
+		t.Fatal("超出定数上限的谱面不应被选择")
+	}
+	host.SelectChart(1)
+	waitFor(t, "选择符合限制的谱面", func() bool {
+		chart := ts.GetRoom(roomID).GetChart()
+		return chart != nil && chart.ID == 1
+	})
+}
@@ -0,0 +1,75 @@
+package test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"phira-mp/common"
+	"phira-mp/server"
+)
+
+// TestRoomSchedule 测试开放前拒绝加入、关闭前提醒，以及到达关闭时间后解散房间
+func TestRoomSchedule(t *testing.T) {
+	ts := startTestServer(t, server.DefaultConfig())
+
+	host := ts.connect(t, 1)
+	player := ts.connect(t, 2)
+	roomID, _ := common.NewRoomId("schedule")
+	host.CreateRoom(roomID)
+	waitFor(t, "创建房间", func() bool { return ts.GetRoom(roomID) != nil })
+	room := ts.GetRoom(roomID)
+
+	// 房主设置一小时后开放
+	openAt := time.Now().Add(time.Hour).UnixMilli()
+	host.SetSchedule(common.RoomSchedule{OpenAt: openAt})
+	waitFor(t, "设置开放时间", func() bool { return room.GetSchedule() != nil })
+	if s := room.GetSchedule(); s.OpenAt != openAt || s.CloseAt != 0 {
+		t.Fatalf("开放时间不匹配: %+v", s)
+	}
+
+	player.JoinRoom(roomID, false)
+	time.Sleep(200 * time.Millisecond)
+	if ts.GetUser(2).GetRoom() != nil {
+		t.Fatal("开放前不应能加入房间")
+	}
+
+	// 改为已开放、两秒后关闭
+	room.SetSchedule(&common.RoomSchedule{CloseAt: time.Now().Add(2 * time.Second).UnixMilli()})
+	player.JoinRoom(roomID, false)
+	waitFor(t, "加入房间", func() bool { return ts.GetUser(2).GetRoom() != nil })
+
+	var warned bool
+	waitFor(t, "关闭提醒", func() bool {
+		for _, msg := range player.TakeMessages() {
+			if msg.Type == common.MsgChat && msg.User == 0 && strings.HasPrefix(msg.Content, "房间将在") {
+				warned = true
+			}
+		}
+		return warned
+	})
+	waitFor(t, "到时解散", func() bool { return ts.GetRoom(roomID) == nil })
+	if ts.GetUser(2).GetRoom() != nil {
+		t.Error("房间解散后玩家应已不在房间中")
+	}
+}
+
+// TestRoomScheduleLock 测试到达关闭时间时锁定房间而不解散
+func TestRoomScheduleLock(t *testing.T) {
+	ts := startTestServer(t, server.DefaultConfig())
+
+	host := ts.connect(t, 1)
+	roomID, _ := common.NewRoomId("schedule-lock")
+	host.CreateRoom(roomID)
+	waitFor(t, "创建房间", func() bool { return ts.GetRoom(roomID) != nil })
+	room := ts.GetRoom(roomID)
+
+	room.SetSchedule(&common.RoomSchedule{CloseAt: time.Now().Add(500 * time.Millisecond).UnixMilli(), Lock: true})
+	waitFor(t, "到时锁定", func() bool { return room.IsLocked() })
+	if ts.GetRoom(roomID) == nil {
+		t.Error("锁定的房间不应被解散")
+	}
+	if room.GetSchedule() != nil {
+		t.Errorf("锁定后应清除关闭时间: %+v", room.GetSchedule())
+	}
+}
@@ -1,6 +1,11 @@
 package test
 
 import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -320,6 +325,7 @@ func TestServerPrintStats(t *testing.T) {
 // TestServerStop 测试服务器停止
 func TestServerStop(t *testing.T) {
 	config := server.DefaultConfig()
+	config.ActivityStatsPath = filepath.Join(t.TempDir(), "activity_stats.json")
 	srv := server.NewServer(config)
 
 	// 添加一些用户和房间
@@ -335,6 +341,104 @@ func TestServerStop(t *testing.T) {
 	srv.Stop()
 }
 
+// TestLifecycleOrder 测试组件按注册顺序启动、按相反顺序停止，单个组件超时不影响后续组件
+func TestLifecycleOrder(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		order []string
+	)
+	record := func(step string) {
+		mu.Lock()
+		defer mu.Unlock()
+		order = append(order, step)
+	}
+	release := make(chan struct{})
+	defer close(release)
+
+	l := server.NewLifecycle(50 * time.Millisecond)
+	for _, name := range []string{"storage", "http", "listener"} {
+		name := name
+		l.Add(name, func() error {
+			record("start " + name)
+			return nil
+		}, func(ctx context.Context) error {
+			if name == "http" {
+				<-release
+			}
+			record("stop " + name)
+			return nil
+		})
+	}
+
+	if err := l.Start(); err != nil {
+		t.Fatalf("启动失败: %v", err)
+	}
+	l.Stop()
+	l.Stop()
+
+	// http 超时未返回，不计入顺序
+	want := []string{"start storage", "start http", "start listener", "stop listener", "stop storage"}
+	mu.Lock()
+	defer mu.Unlock()
+	if strings.Join(order, ",") != strings.Join(want, ",") {
+		t.Errorf("启动与停止顺序不正确: %v", order)
+	}
+	if err := l.Start(); err == nil {
+		t.Error("停止后不应再启动组件")
+	}
+}
+
+// TestServerStopDrainsSessions 测试停止服务器时等待会话完成断线处理
+func TestServerStopDrainsSessions(t *testing.T) {
+	ts := startTestServer(t, server.ServerConfig{})
+	ts.connect(t, 1)
+	ts.connect(t, 2)
+
+	ts.Stop()
+	if n := ts.GetStats()["sessions"]; n != 0 {
+		t.Errorf("停止后应没有会话，实际 %v", n)
+	}
+	if conn, err := net.Dial("tcp", ts.addr); err == nil {
+		conn.Close()
+		t.Error("停止后不应再接受连接")
+	}
+}
+
+// TestServerDrain 测试平滑升级时停止接受新连接，现有会话继续服务直到断开
+func TestServerDrain(t *testing.T) {
+	ts := startTestServer(t, server.ServerConfig{})
+	client := ts.connect(t, 1)
+
+	done := make(chan struct{})
+	go func() {
+		ts.Drain()
+		close(done)
+	}()
+
+	waitFor(t, "停止接受新连接", func() bool {
+		conn, err := net.Dial("tcp", ts.addr)
+		if err == nil {
+			conn.Close()
+		}
+		return err != nil
+	})
+	if err := client.Ping(); err != nil {
+		t.Errorf("现有会话应继续服务: %v", err)
+	}
+	select {
+	case <-done:
+		t.Fatal("仍有会话时不应停止服务器")
+	default:
+	}
+
+	ts.GetUser(1).GetSession().Stop()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("会话结束后应停止服务器")
+	}
+}
+
 // TestServerStressTest 测试服务器压力测试
 func TestServerStressTest(t *testing.T) {
 	config := server.DefaultConfig()
@@ -441,3 +545,239 @@ func TestServerLongRunning(t *testing.T) {
 		t.Errorf("用户数应该是100，实际: %d", stats["users"])
 	}
 }
+
+// TestGlobalChat 测试全服频道
+func TestGlobalChat(t *testing.T) {
+	config := server.DefaultConfig()
+	config.LiveMode = true
+	config.Monitors = []int32{2}
+	config.GlobalChatCasters = []int32{3}
+	srv := server.NewServer(config)
+
+	host := server.NewUser(1, "Host", "zh-CN", srv)
+	roomID, _ := common.NewRoomId("global-chat-room")
+	srv.AddRoom(server.NewRoom(roomID, host, srv))
+	srv.AddUser(host)
+
+	monitor := server.NewUser(2, "Monitor", "zh-CN", srv)
+	caster := server.NewUser(3, "Caster", "zh-CN", srv)
+	player := server.NewUser(4, "Player", "zh-CN", srv)
+	srv.AddUser(monitor)
+	srv.AddUser(caster)
+	srv.AddUser(player)
+
+	if !srv.IsGlobalCaster(monitor) || !srv.IsGlobalCaster(caster) {
+		t.Error("观察者和指定解说应该可以在全服频道发言")
+	}
+	if srv.IsGlobalCaster(player) {
+		t.Error("普通玩家不应该可以在全服频道发言")
+	}
+
+	caster.SetGlobalSubscribed(true)
+	delivered, err := srv.PostGlobalChat(server.GlobalChatAdminID, "管理员", "测试", server.GlobalChatScopeAll)
+	if err != nil {
+		t.Fatalf("发言失败: %v", err)
+	}
+	if delivered != 2 {
+		t.Errorf("送达人数不匹配: 期望 2, 实际 %d", delivered)
+	}
+
+	// 同一发送者在间隔内再次发言应被限流
+	if _, err := srv.PostGlobalChat(server.GlobalChatAdminID, "管理员", "测试", ""); err == nil {
+		t.Error("间隔内再次发言应该被限流")
+	}
+
+	delivered, err = srv.PostGlobalChat(caster.ID, caster.Name, "测试", server.GlobalChatScopeSubscribers)
+	if err != nil {
+		t.Fatalf("发言失败: %v", err)
+	}
+	if delivered != 1 {
+		t.Errorf("仅订阅用户时送达人数不匹配: 期望 1, 实际 %d", delivered)
+	}
+}
+
+// TestActivityStats 测试活动统计的聚合与报告
+func TestActivityStats(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "activity_stats.json")
+	stats := server.NewActivityStats(path)
+
+	// 周一 20:00 与 21:00 各采样两次，20:00 开局两次
+	monday := time.Date(2024, 10, 28, 20, 0, 0, 0, time.UTC)
+	stats.Sample(10, map[string]int{"room1": 4}, monday)
+	stats.Sample(20, map[string]int{"room1": 6}, monday.Add(30*time.Minute))
+	stats.Sample(4, nil, monday.Add(time.Hour))
+	stats.Sample(2, nil, monday.Add(90*time.Minute))
+	stats.RecordMatch("room1", 4, monday.Add(10*time.Minute))
+	stats.RecordMatch("room2", 2, monday.Add(20*time.Minute))
+
+	if err := stats.Save(); err != nil {
+		t.Fatalf("保存活动统计失败: %v", err)
+	}
+	loaded := server.NewActivityStats(path)
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("加载活动统计失败: %v", err)
+	}
+
+	buckets := loaded.Query("", monday.Add(-time.Hour), monday.Add(2*time.Hour))
+	if len(buckets) != 2 {
+		t.Fatalf("小时统计数量不匹配: 期望 2, 实际 %d", len(buckets))
+	}
+	if buckets[0].Matches != 2 || buckets[0].Players != 6 || buckets[0].AvgUsers() != 15 || buckets[0].PeakUsers != 20 {
+		t.Errorf("20点统计不匹配: %+v", buckets[0])
+	}
+
+	room := loaded.Query("room1", monday, monday.Add(time.Hour))
+	if len(room) != 1 || room[0].Matches != 1 || room[0].AvgUsers() != 5 {
+		t.Errorf("房间统计不匹配: %+v", room)
+	}
+
+	report := server.BuildActivityReport(buckets, true, time.UTC)
+	if len(report.Series) != 1 || report.Series[0].Matches != 2 || report.Series[0].AvgUsers != 9 {
+		t.Errorf("按天聚合不匹配: %+v", report.Series)
+	}
+	if report.Heatmap[1][20] != 15 || report.Heatmap[1][21] != 3 {
+		t.Errorf("热力图不匹配: %v", report.Heatmap[1])
+	}
+	if len(report.PeakHours) != 2 || report.PeakHours[0] != 20 || report.QuietHours[0] != 21 {
+		t.Errorf("高峰时段不匹配: peak=%v quiet=%v", report.PeakHours, report.QuietHours)
+	}
+
+	// 过期统计清理后房间记录一并删除
+	if pruned := loaded.Prune(monday.Add(24 * time.Hour)); pruned != 4 {
+		t.Errorf("清理数量不匹配: 期望 4, 实际 %d", pruned)
+	}
+	if len(loaded.Query("room1", monday, monday.Add(time.Hour))) != 0 {
+		t.Error("清理后不应该再有房间统计")
+	}
+}
+
+// TestActivityStatsSaveRetry 测试保存失败后保留未保存的统计，下次保存时重试
+func TestActivityStatsSaveRetry(t *testing.T) {
+	blocker := filepath.Join(t.TempDir(), "data")
+	if err := os.WriteFile(blocker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(blocker, "activity_stats.json")
+	stats := server.NewActivityStats(path)
+	at := time.Date(2024, 10, 28, 20, 0, 0, 0, time.UTC)
+	stats.RecordMatch("room1", 2, at)
+	if err := stats.Save(); err == nil {
+		t.Fatal("目录无法创建时保存应失败")
+	}
+
+	os.Remove(blocker)
+	if err := stats.Save(); err != nil {
+		t.Fatalf("重试保存失败: %v", err)
+	}
+	loaded := server.NewActivityStats(path)
+	if err := loaded.Load(); err != nil || len(loaded.Query("room1", at, at.Add(time.Hour))) != 1 {
+		t.Errorf("保存失败的统计应在重试时写入: %v", err)
+	}
+}
+
+// TestAdvertisedAddresses 测试对外公布地址的规范化与排序
+func TestAdvertisedAddresses(t *testing.T) {
+	config := server.DefaultConfig()
+	if addrs := server.NewServer(config).AdvertisedAddresses(); addrs != nil {
+		t.Errorf("未配置时不应该返回地址: %v", addrs)
+	}
+
+	config.AdvertiseAddresses = []server.AdvertiseAddress{
+		{Host: "203.0.113.10", Port: 443, Priority: 2},
+		{Host: "2001:db8::1", Priority: 1, Region: "cn-east"},
+		{Host: ""},
+		{Host: "mp.example.com", Priority: 0},
+	}
+	addrs := server.NewServer(config).AdvertisedAddresses()
+	if len(addrs) != 3 {
+		t.Fatalf("地址数量不匹配: 期望 3, 实际 %d", len(addrs))
+	}
+
+	expected := []struct{ typ, address string }{
+		{server.AddressTypeDomain, "mp.example.com:12346"},
+		{server.AddressTypeIPv6, "[2001:db8::1]:12346"},
+		{server.AddressTypeIPv4, "203.0.113.10:443"},
+	}
+	for i, want := range expected {
+		if addrs[i].Type != want.typ || addrs[i].Address != want.address {
+			t.Errorf("第%d个地址不匹配: 期望 %s %s, 实际 %s %s", i, want.typ, want.address, addrs[i].Type, addrs[i].Address)
+		}
+	}
+}
+
+// TestPeakStats 测试负载峰值统计
+func TestPeakStats(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "activity_stats.json")
+	stats := server.NewActivityStats(path)
+
+	day1 := time.Date(2024, 10, 28, 20, 0, 0, 0, time.Local)
+	stats.ObservePeaks(server.LoadCounts{Sessions: 10, Users: 8, Rooms: 3, PlayingRooms: 1}, day1)
+	stats.ObservePeaks(server.LoadCounts{Sessions: 5, Users: 12, Rooms: 2}, day1.Add(time.Hour))
+	day2 := day1.Add(24 * time.Hour)
+	stats.ObservePeaks(server.LoadCounts{Sessions: 6, Users: 4, Rooms: 1, PlayingRooms: 1}, day2)
+
+	if err := stats.Save(); err != nil {
+		t.Fatalf("保存活动统计失败: %v", err)
+	}
+	loaded := server.NewActivityStats(path)
+	if err := loaded.Load(); err != nil {
+		t.Fatalf("加载活动统计失败: %v", err)
+	}
+
+	allTime, today, daily := loaded.PeakReport(day1.Add(-time.Hour), day2)
+	if allTime.Sessions.Value != 10 || allTime.Sessions.At != day1.Unix() || allTime.Users.Value != 12 {
+		t.Errorf("历史峰值不匹配: %+v", allTime)
+	}
+	if today.Sessions.Value != 6 || today.Users.Value != 4 {
+		t.Errorf("当日峰值不匹配: %+v", today)
+	}
+	if len(daily) != 2 || daily[0].Users.Value != 12 || daily[1].Rooms.Value != 1 {
+		t.Errorf("每日峰值不匹配: %+v", daily)
+	}
+
+	// 每日峰值随统计一起清理，历史峰值保留
+	loaded.Prune(day2)
+	allTime, _, daily = loaded.PeakReport(day1.Add(-time.Hour), day2)
+	if len(daily) != 1 || allTime.Sessions.Value != 10 {
+		t.Errorf("清理后峰值不匹配: %+v %+v", allTime, daily)
+	}
+}
+
+func TestCommandLatency(t *testing.T) {
+	latency := server.NewCommandLatency()
+	for i := 0; i < 98; i++ {
+		latency.Observe(common.ClientCmdTouches, 200*time.Microsecond, false)
+	}
+	latency.Observe(common.ClientCmdTouches, 300*time.Millisecond, false)
+	latency.Observe(common.ClientCmdTouches, 8*time.Second, true)
+
+	stats, ok := latency.Snapshot()["Touches"]
+	if !ok {
+		t.Fatal("缺少 Touches 的统计")
+	}
+	if stats.Count != 100 || stats.Errors != 1 {
+		t.Errorf("计数不正确: %+v", stats)
+	}
+	if stats.P50 <= 0.0001 || stats.P50 > 0.0005 {
+		t.Errorf("P50 应落在 0.1ms～0.5ms 桶内: %v", stats.P50)
+	}
+	if stats.P99 <= 0.1 || stats.P99 > 0.5 {
+		t.Errorf("P99 应落在 100ms～500ms 桶内: %v", stats.P99)
+	}
+	if stats.Max != 8 {
+		t.Errorf("最大值不正确: %v", stats.Max)
+	}
+
+	// 连接后处理的命令计入服务器统计
+	ts := startTestServer(t, server.ServerConfig{})
+	c := ts.connect(t, 1)
+	if err := c.Ping(); err != nil {
+		t.Fatalf("Ping失败: %v", err)
+	}
+	waitFor(t, "Ping 计入统计", func() bool {
+		return ts.GetCommandLatency().Snapshot()["Ping"].Count > 0
+	})
+	if stats := ts.GetCommandLatency().Snapshot()["Authenticate"]; stats.Count != 1 {
+		t.Errorf("Authenticate 计数不正确: %+v", stats)
+	}
+}
@@ -0,0 +1,111 @@
+package test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"phira-mp/common"
+	"phira-mp/server"
+)
+
+// TestTeamMode 测试房主分配队伍：非房主与无效队伍被拒绝，客户端房间状态随 MsgTeamChange 更新，结算按队伍汇总成绩
+func TestTeamMode(t *testing.T) {
+	ts := startTestServer(t, server.ServerConfig{DefaultMaxUsers: 8, GameHistorySize: 16})
+
+	records := map[string]string{
+		"/record/101": `{"id": 101, "player": 1, "score": 990000, "accuracy": 0.95, "perfect": 10}`,
+		"/record/102": `{"id": 102, "player": 2, "score": 980000, "accuracy": 0.99, "perfect": 10}`,
+		"/record/103": `{"id": 103, "player": 3, "score": 900000, "accuracy": 0.85, "perfect": 10}`,
+	}
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/me":
+			serveFakeMe(w, r)
+		case strings.HasPrefix(r.URL.Path, "/chart/"):
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": 5, "name": "Teams"})
+		case records[r.URL.Path] != "":
+			w.Write([]byte(records[r.URL.Path]))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(api.Close)
+	server.ConfigurePhiraAPI(server.PhiraAPIConfig{BaseURL: api.URL, Timeout: 5})
+
+	host := ts.connect(t, 1)
+	p2 := ts.connect(t, 2)
+	p3 := ts.connect(t, 3)
+	roomID, _ := common.NewRoomId("teams")
+	host.CreateRoom(roomID)
+	waitFor(t, "创建房间", func() bool { return ts.GetRoom(roomID) != nil })
+	room := ts.GetRoom(roomID)
+	p2.JoinRoom(roomID, false)
+	p3.JoinRoom(roomID, false)
+	waitFor(t, "玩家加入", func() bool { return len(room.GetUsers()) == 3 })
+
+	p2.SetTeam(3, 1)
+	host.SetTeam(2, common.RoomMaxTeams+1)
+	time.Sleep(200 * time.Millisecond)
+	if teams := room.GetTeams(); teams != nil {
+		t.Fatalf("非房主或无效队伍编号不应修改队伍: %v", teams)
+	}
+
+	if err := host.SetTeam(1, 1); err != nil {
+		t.Fatalf("发送分配队伍失败: %v", err)
+	}
+	host.SetTeam(3, 1)
+	host.SetTeam(2, 2)
+	waitFor(t, "分配队伍", func() bool { return len(room.GetTeams()) == 3 })
+	waitFor(t, "客户端队伍更新", func() bool {
+		state := p2.RoomState()
+		return state != nil && state.Teams[1] == 1 && state.Teams[2] == 2 && state.Teams[3] == 1
+	})
+
+	host.SelectChart(5)
+	waitFor(t, "选择谱面", func() bool { return room.GetChart() != nil })
+	host.RequestStart()
+	waitFor(t, "等待准备", func() bool { return room.GetState() == server.InternalStateWaitForReady })
+	p2.Ready()
+	p3.Ready()
+	waitFor(t, "开始游戏", func() bool { return room.GetState() == server.InternalStatePlaying })
+
+	host.SetTeam(2, 1)
+	time.Sleep(200 * time.Millisecond)
+	if room.GetTeam(2) != 2 {
+		t.Fatal("游戏中不应调整队伍")
+	}
+
+	host.Played(101)
+	p2.Played(102)
+	p3.Played(103)
+	waitFor(t, "生成结算", func() bool { return room.GetLastSummary() != nil })
+
+	summary := room.GetLastSummary()
+	if len(summary.Teams) != 2 {
+		t.Fatalf("结算应包含两支队伍: %+v", summary.Teams)
+	}
+	first, second := summary.Teams[0], summary.Teams[1]
+	if first.Team != 1 || first.Rank != 1 || len(first.Players) != 2 || first.Score != 990000+900000 {
+		t.Errorf("队伍1汇总不正确: %+v", first)
+	}
+	if second.Team != 2 || second.Rank != 2 || len(second.Players) != 1 || second.Score != 980000 {
+		t.Errorf("队伍2汇总不正确: %+v", second)
+	}
+	for _, entry := range summary.Ranking {
+		if entry.Team != room.GetTeam(entry.UserID) {
+			t.Errorf("个人排名中的队伍不正确: %+v", entry)
+		}
+	}
+
+	// 离开房间的玩家退出队伍，其他客户端同步移除
+	p3.LeaveRoom()
+	waitFor(t, "离开房间退出队伍", func() bool { return room.GetTeam(3) == 0 })
+	waitFor(t, "客户端移除队伍", func() bool {
+		_, ok := p2.RoomState().Teams[3]
+		return !ok
+	})
+}
@@ -0,0 +1,39 @@
+package test
+
+import (
+	"testing"
+	"time"
+
+	"phira-mp/common"
+	"phira-mp/server"
+)
+
+// TestTransferHost 测试房主主动移交房主，以及非房主或目标不在房间时移交失败
+func TestTransferHost(t *testing.T) {
+	ts := startTestServer(t, server.DefaultConfig())
+
+	host := ts.connect(t, 1)
+	player := ts.connect(t, 2)
+	roomID, _ := common.NewRoomId("transfer")
+	host.CreateRoom(roomID)
+	waitFor(t, "创建房间", func() bool { return ts.GetRoom(roomID) != nil })
+	player.JoinRoom(roomID, false)
+	waitFor(t, "加入房间", func() bool { return ts.GetUser(2).GetRoom() != nil })
+	room := ts.GetRoom(roomID)
+
+	player.TransferHost(2)
+	host.TransferHost(99)
+	time.Sleep(200 * time.Millisecond)
+	if room.GetHost().ID != 1 {
+		t.Fatalf("移交失败时房主不应改变: %d", room.GetHost().ID)
+	}
+
+	if err := host.TransferHost(2); err != nil {
+		t.Fatalf("移交房主失败: %v", err)
+	}
+	waitFor(t, "移交房主", func() bool { return room.GetHost().ID == 2 })
+	waitFor(t, "客户端得知房主变更", func() bool { return player.IsHost() && !host.IsHost() })
+	if got := host.Host(); got != 2 {
+		t.Errorf("原房主看到的房主不正确: %d", got)
+	}
+}
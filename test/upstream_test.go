@@ -0,0 +1,175 @@
+package test
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"phira-mp/server"
+)
+
+// TestUpstreamAPI 测试Phira主站API配置、重试与指标
+func TestUpstreamAPI(t *testing.T) {
+	var failures atomic.Int32
+	failures.Store(1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/chart/1":
+			// 第一次请求返回5xx，验证重试
+			if failures.Add(-1) >= 0 {
+				w.WriteHeader(http.StatusBadGateway)
+				return
+			}
+			w.Write([]byte(`{"id":1,"name":"Test Chart"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	server.ConfigurePhiraAPI(server.PhiraAPIConfig{BaseURL: ts.URL + "/", Timeout: 5, Retries: 1})
+	defer server.ConfigurePhiraAPI(server.DefaultPhiraAPIConfig())
+
+	before := server.UpstreamMetrics()["chart"]
+
+	chart, err := server.FetchChart(1)
+	if err != nil {
+		t.Fatalf("重试后应该获取成功: %v", err)
+	}
+	if chart.Name != "Test Chart" {
+		t.Errorf("谱面名称不匹配: %s", chart.Name)
+	}
+
+	// 404 不重试、不计入错误
+	if _, err := server.FetchChart(2); err == nil {
+		t.Error("不存在的谱面应该返回错误")
+	}
+
+	after := server.UpstreamMetrics()["chart"]
+	if after.Requests-before.Requests != 3 {
+		t.Errorf("请求次数不匹配: 期望 3, 实际 %d", after.Requests-before.Requests)
+	}
+	if after.Errors-before.Errors != 1 {
+		t.Errorf("错误次数不匹配: 期望 1, 实际 %d", after.Errors-before.Errors)
+	}
+
+	var sb strings.Builder
+	server.WriteUpstreamMetrics(&sb)
+	for _, want := range []string{
+		`phira_upstream_requests_total{endpoint="chart"}`,
+		`phira_upstream_request_duration_seconds_bucket{endpoint="chart",le="+Inf"}`,
+	} {
+		if !strings.Contains(sb.String(), want) {
+			t.Errorf("指标输出缺少 %s", want)
+		}
+	}
+}
+
+// TestHTTPRecordProvider 测试第三方成绩服务
+func TestHTTPRecordProvider(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-Key") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Path != "/api/record/42" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"id":42,"player":100,"score":1000000,"accuracy":1,"full_combo":true}`))
+	}))
+	defer ts.Close()
+
+	provider := server.NewHTTPRecordProvider(server.RecordProviderConfig{
+		URL:          ts.URL + "/api/record/{id}",
+		APIKey:       "secret",
+		APIKeyHeader: "X-API-Key",
+	})
+	record, err := provider.FetchRecord(42)
+	if err != nil {
+		t.Fatalf("获取成绩失败: %v", err)
+	}
+	if record.ID != 42 || record.Player != 100 || record.Score != 1000000 || !record.FullCombo {
+		t.Errorf("成绩内容不匹配: %+v", record)
+	}
+	if _, err := provider.FetchRecord(7); err == nil {
+		t.Error("不存在的成绩应该返回错误")
+	}
+
+	// 服务器默认使用主站，可替换为自定义来源
+	srv := server.NewServer(server.DefaultConfig())
+	if _, ok := srv.GetRecordProvider().(server.PhiraRecordProvider); !ok {
+		t.Error("未配置第三方服务时应该使用Phira主站")
+	}
+	srv.SetRecordProvider(provider)
+	if srv.GetRecordProvider() != server.RecordProvider(provider) {
+		t.Error("替换成绩来源失败")
+	}
+}
+
+// TestScoreSubmitter 测试成绩代提交转发
+func TestScoreSubmitter(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Authorization") != "Bearer player-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != `{"score":990000}` {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"id":77,"player":100,"score":990000}`))
+	}))
+	defer ts.Close()
+
+	submitter := server.NewScoreSubmitter(server.ScoreSubmitConfig{URL: ts.URL})
+	record, err := submitter.Submit("player-token", `{"score":990000}`)
+	if err != nil {
+		t.Fatalf("提交成绩失败: %v", err)
+	}
+	if record.ID != 77 || record.Player != 100 || record.Score != 990000 {
+		t.Errorf("成绩内容不匹配: %+v", record)
+	}
+	if _, err := submitter.Submit("other", `{"score":990000}`); err == nil {
+		t.Error("成绩服务拒绝时应该返回错误")
+	}
+	if _, ok := server.UpstreamMetrics()["score_submit"]; !ok {
+		t.Error("成绩代提交应该计入上游指标")
+	}
+}
+
+// TestUserInfoTokenRejected 测试认证服务拒绝token与网络错误的区分
+func TestUserInfoTokenRejected(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer valid-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte(`{"id":100,"name":"Player","language":"zh-CN"}`))
+	}))
+
+	server.ConfigurePhiraAPI(server.PhiraAPIConfig{BaseURL: ts.URL, Timeout: 5})
+	defer server.ConfigurePhiraAPI(server.DefaultPhiraAPIConfig())
+
+	user, _, err := server.UserInfoFromAPI("valid-token")
+	if err != nil || user.ID != 100 {
+		t.Fatalf("有效token应该认证成功: %v", err)
+	}
+
+	_, _, err = server.UserInfoFromAPI("revoked-token")
+	if !errors.Is(err, server.ErrTokenRejected) {
+		t.Errorf("被拒绝的token应该返回 ErrTokenRejected，实际: %v", err)
+	}
+
+	// 认证服务不可用时不视为token被拒绝
+	ts.Close()
+	_, _, err = server.UserInfoFromAPI("other-token")
+	if err == nil || errors.Is(err, server.ErrTokenRejected) {
+		t.Errorf("网络错误不应该视为token被拒绝，实际: %v", err)
+	}
+}
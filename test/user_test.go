@@ -1,6 +1,7 @@
 package test
 
 import (
+	"fmt"
 	"sync"
 	"testing"
 	"time"
@@ -50,6 +51,33 @@ func TestUserToInfo(t *testing.T) {
 	}
 }
 
+// TestUserProfile 测试用户显示资料
+func TestUserProfile(t *testing.T) {
+	srv := server.NewServer(server.DefaultConfig())
+	user := server.NewUser(1, "TestUser", "zh-CN", srv)
+
+	if user.DisplayName() != "TestUser" || user.Avatar() != "" {
+		t.Errorf("未设置资料时应使用账号名称，实际: %s %q", user.DisplayName(), user.Avatar())
+	}
+
+	user.SetProfile("新名字", "preset:3")
+	if info := user.ToInfo(); info.Name != "新名字" {
+		t.Errorf("Info Name应为显示名称，实际: %s", info.Name)
+	}
+	if user.Name != "TestUser" {
+		t.Errorf("账号名称不应改变，实际: %s", user.Name)
+	}
+	if p := user.ProfileInfo(); p.ID != 1 || p.Avatar != "preset:3" {
+		t.Errorf("资料信息不匹配: %+v", p)
+	}
+
+	// 空名称恢复账号名称
+	user.SetProfile("", "")
+	if user.DisplayName() != "TestUser" {
+		t.Errorf("空名称应恢复账号名称，实际: %s", user.DisplayName())
+	}
+}
+
 // TestUserMonitorStatus 测试用户观察者状态
 func TestUserMonitorStatus(t *testing.T) {
 	config := server.DefaultConfig()
@@ -260,3 +288,148 @@ func TestUserServerAssociation(t *testing.T) {
 		t.Error("用户应该能观察")
 	}
 }
+
+// TestUserIdle 测试用户闲置状态
+func TestUserIdle(t *testing.T) {
+	config := server.DefaultConfig()
+	srv := server.NewServer(config)
+	user := server.NewUser(1, "Idle", "zh-CN", srv)
+
+	if user.IdleFor() > time.Second {
+		t.Errorf("新用户不应处于闲置状态，实际闲置: %v", user.IdleFor())
+	}
+	if user.IsAFK() {
+		t.Error("未启用闲置检测时不应判定为闲置")
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	before := user.IdleFor()
+	user.MarkActive()
+	if user.IdleFor() >= before {
+		t.Error("记录活动后闲置时长应重置")
+	}
+}
+
+// TestUserConnectionInfo 测试用户连接信息与IP打码
+func TestUserConnectionInfo(t *testing.T) {
+	config := server.DefaultConfig()
+	srv := server.NewServer(config)
+
+	user := server.NewUser(1, "User1", "zh-CN", srv)
+	if user.ConnectionInfo() != nil {
+		t.Error("未建立连接的用户不应该有连接信息")
+	}
+
+	if got := srv.DisplayIP("203.0.113.10"); got != "203.0.*.*" {
+		t.Errorf("默认应该打码显示IP，实际: %s", got)
+	}
+
+	config.ShowClientIP = true
+	srv = server.NewServer(config)
+	if got := srv.DisplayIP("203.0.113.10"); got != "203.0.113.10" {
+		t.Errorf("开启show_client_ip后应该显示完整IP，实际: %s", got)
+	}
+}
+
+// TestGuestUser 测试游客身份判定与观察权限
+func TestGuestUser(t *testing.T) {
+	config := server.DefaultConfig()
+	config.GuestMode = true
+	srv := server.NewServer(config)
+	if !srv.IsGuestModeEnabled() {
+		t.Error("配置开启后游客模式应该启用")
+	}
+
+	guest := server.NewUser(server.GuestIDBase-1, "游客1", "zh-CN", srv)
+	player := server.NewUser(100, "Player", "zh-CN", srv)
+	if !guest.IsGuest() || player.IsGuest() {
+		t.Error("游客判定错误")
+	}
+
+	roomID, _ := common.NewRoomId("test-room-guest")
+	room := server.NewRoom(roomID, player, srv)
+	if !room.CanMonitor(guest) {
+		t.Error("游客应该能观察房间")
+	}
+	if room.CanMonitor(player) {
+		t.Error("未开启直播模式时普通玩家不应该能观察")
+	}
+}
+
+// TestSearchUsers 测试按名称搜索用户
+func TestSearchUsers(t *testing.T) {
+	ts := startTestServer(t, server.DefaultConfig())
+	ts.connect(t, 1)
+	ts.connect(t, 12)
+	ts.connect(t, 3)
+	ts.GetUser(3).SetProfile("1号", "")
+
+	results := ts.SearchUsers("PLAYER1", 0)
+	if len(results) != 2 || results[0].ID != 1 || results[1].ID != 12 {
+		t.Fatalf("应不区分大小写匹配 player1 与 player12，实际: %+v", results)
+	}
+	if !results[0].Connected || results[0].LastActive == 0 {
+		t.Errorf("搜索结果应包含连接状态与最后活动时间: %+v", results[0])
+	}
+
+	// 显示名称前缀匹配排在账号名称子串匹配之前
+	results = ts.SearchUsers("1", 0)
+	if len(results) != 3 || results[0].ID != 3 || results[0].DisplayName != "1号" {
+		t.Fatalf("前缀匹配应排在前面，实际: %+v", results)
+	}
+	if results = ts.SearchUsers("1", 1); len(results) != 1 {
+		t.Errorf("应按limit截断，实际: %d", len(results))
+	}
+	if results = ts.SearchUsers("nobody", 0); len(results) != 0 {
+		t.Errorf("不应有匹配结果: %+v", results)
+	}
+}
+
+// TestRecentUsers 测试最近离线用户缓存
+func TestRecentUsers(t *testing.T) {
+	config := server.DefaultConfig()
+	srv := server.NewServer(config)
+
+	recent := server.NewRecentUsers(2)
+	for i := int32(1); i <= 3; i++ {
+		user := server.NewUser(i, fmt.Sprintf("player%d", i), "zh-CN", srv)
+		user.SetIP("203.0.113.1")
+		roomID, _ := common.NewRoomId(fmt.Sprintf("room%d", i))
+		user.SetRoom(server.NewRoom(roomID, user, srv))
+		user.SetRoom(nil)
+		recent.Add(user, time.Now())
+	}
+
+	// 超出容量时淘汰最早离开的
+	if recent.Get(1) != nil {
+		t.Error("用户1应已被淘汰")
+	}
+	list := recent.List(0)
+	if len(list) != 2 || list[0].ID != 3 || list[1].ID != 2 {
+		t.Fatalf("应按离开时间从新到旧排列，实际: %+v", list)
+	}
+	if list[0].Room != "room3" || list[0].IP != "203.0.113.1" || list[0].LastSeen == 0 {
+		t.Errorf("记录内容不完整: %+v", list[0])
+	}
+
+	recent.Remove(3)
+	if recent.Get(3) != nil || len(recent.List(0)) != 1 {
+		t.Error("重新连接的用户应从缓存中移除")
+	}
+}
+
+// TestRecentUsersOnRemove 测试用户被移除后可以被查询与搜索
+func TestRecentUsersOnRemove(t *testing.T) {
+	ts := startTestServer(t, server.DefaultConfig())
+	ts.connect(t, 7)
+
+	ts.RemoveUser(7)
+	entry := ts.GetRecentUsers().Get(7)
+	if entry == nil || entry.Name != "player7" {
+		t.Fatalf("移除的用户应记录在最近离线用户中: %+v", entry)
+	}
+	results := ts.SearchUsers("player7", 0)
+	if len(results) != 1 || !results[0].Recent || results[0].Connected {
+		t.Errorf("搜索应包含最近离线用户: %+v", results)
+	}
+}
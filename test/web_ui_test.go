@@ -0,0 +1,79 @@
+package test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"phira-mp/server"
+)
+
+// TestAdminUI 测试内置管理面板静态资源
+func TestAdminUI(t *testing.T) {
+	ts := httptest.NewServer(server.AdminUIHandler())
+	defer ts.Close()
+
+	for path, want := range map[string]string{
+		server.AdminUIPath:               "Phira MP 管理面板",
+		server.AdminUIPath + "app.js":    "admin_subscribe",
+		server.AdminUIPath + "style.css": ".panel",
+	} {
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), want) {
+			t.Errorf("%s 返回内容不正确: 状态码 %d", path, resp.StatusCode)
+		}
+		if resp.Header.Get("X-Frame-Options") != "DENY" {
+			t.Errorf("%s 应该禁止被嵌入", path)
+		}
+	}
+
+	resp, err := http.Post(ts.URL+server.AdminUIPath, "text/plain", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("POST 应该返回405，实际: %d", resp.StatusCode)
+	}
+}
+
+// TestPublicPage 测试公开房间列表页
+func TestPublicPage(t *testing.T) {
+	ts := httptest.NewServer(server.PublicPageHandler())
+	defer ts.Close()
+
+	for path, want := range map[string]string{
+		"/":       "Phira MP 房间列表",
+		"/app.js": "/room",
+	} {
+		resp, err := http.Get(ts.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || !strings.Contains(string(body), want) {
+			t.Errorf("%s 返回内容不正确: 状态码 %d", path, resp.StatusCode)
+		}
+	}
+
+	resp, err := http.Get(ts.URL + "/not-exist")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("不存在的路径应该返回404，实际: %d", resp.StatusCode)
+	}
+
+	if !server.DefaultConfig().PublicPage {
+		t.Error("公开房间列表页应该默认开启")
+	}
+}
@@ -609,3 +609,46 @@ func TestWebSocketMessageFormat(t *testing.T) {
 		})
 	}
 }
+
+// TestWebSocketLatencyMeta 测试延迟回报
+func TestWebSocketLatencyMeta(t *testing.T) {
+	srv, httpServer := setupTestServerWithHTTP(t)
+	defer srv.Stop()
+
+	testServer := httptest.NewServer(http.HandlerFunc(httpServer.HandleWebSocket))
+	defer testServer.Close()
+
+	wsURL := "ws" + strings.TrimPrefix(testServer.URL, "http")
+	ws, _, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err != nil {
+		t.Fatalf("连接 WebSocket 失败: %v", err)
+	}
+	defer ws.Close()
+
+	if err := ws.WriteJSON(map[string]interface{}{"type": "latency_subscribe"}); err != nil {
+		t.Fatalf("发送消息失败: %v", err)
+	}
+
+	// 读取消息时默认的ping处理会原样回复pong，服务器据此计算往返延迟
+	ws.SetReadDeadline(time.Now().Add(3 * time.Second))
+	var subscribed, meta bool
+	for !meta {
+		var response map[string]interface{}
+		if err := ws.ReadJSON(&response); err != nil {
+			t.Fatalf("读取响应失败: %v", err)
+		}
+		switch response["type"] {
+		case "latency_subscribed":
+			subscribed = true
+		case "meta":
+			meta = true
+			data, _ := response["data"].(map[string]interface{})
+			if _, ok := data["rtt_ms"].(float64); !ok {
+				t.Errorf("meta 消息应该包含 rtt_ms: %v", data)
+			}
+		}
+	}
+	if !subscribed {
+		t.Error("未收到 latency_subscribed")
+	}
+}